@@ -24,6 +24,7 @@
 {{- else if eq $ttl._1 "HOUR" }}
 {{- $ttlTimeout = min (mul $ttl._0 60 60) $ttlTimeout }}
 {{- end }}
+{{- $viewsTTL := .Values.clickhouse.retention.viewsTTL | default .Values.clickhouse.ttl }}
 
 function createTable {
 clickhouse client -n -h 127.0.0.1 <<-EOSQL
@@ -80,6 +81,7 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
         clusterUUID String,
         trusted UInt8 DEFAULT 0
     ) engine=ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+    PARTITION BY toYYYYMMDD(timeInserted)
     ORDER BY (timeInserted, flowEndSeconds)
     TTL timeInserted + INTERVAL {{ .Values.clickhouse.ttl }}
     SETTINGS merge_with_ttl_timeout = {{ $ttlTimeout }};
@@ -87,6 +89,7 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
     --Create a Materialized View to aggregate data for pods
     CREATE MATERIALIZED VIEW IF NOT EXISTS flows_pod_view_local
     ENGINE = ReplicatedSummingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+    PARTITION BY toYYYYMMDD(timeInserted)
     ORDER BY (
         timeInserted,
         flowEndSeconds,
@@ -103,7 +106,7 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
         sourceTransportPort,
         destinationTransportPort,
         clusterUUID)
-    TTL timeInserted + INTERVAL {{ .Values.clickhouse.ttl }}
+    TTL timeInserted + INTERVAL {{ $viewsTTL }}
     SETTINGS merge_with_ttl_timeout = {{ $ttlTimeout }}
     POPULATE
     AS SELECT
@@ -149,6 +152,7 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
     --Create a Materialized View to aggregate data for nodes
     CREATE MATERIALIZED VIEW IF NOT EXISTS flows_node_view_local
     ENGINE = ReplicatedSummingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+    PARTITION BY toYYYYMMDD(timeInserted)
     ORDER BY (
         timeInserted,
         flowEndSeconds,
@@ -159,7 +163,7 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
         sourcePodNamespace,
         destinationPodNamespace,
         clusterUUID)
-    TTL timeInserted + INTERVAL {{ .Values.clickhouse.ttl }}
+    TTL timeInserted + INTERVAL {{ $viewsTTL }}
     SETTINGS merge_with_ttl_timeout = {{ $ttlTimeout }}
     POPULATE
     AS SELECT
@@ -195,6 +199,7 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
     --Create a Materialized View to aggregate data for network policies
     CREATE MATERIALIZED VIEW IF NOT EXISTS flows_policy_view_local
     ENGINE = ReplicatedSummingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+    PARTITION BY toYYYYMMDD(timeInserted)
     ORDER BY (
         timeInserted,
         flowEndSeconds,
@@ -216,7 +221,7 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
         destinationServicePortName,
         destinationIP,
         clusterUUID)
-    TTL timeInserted + INTERVAL {{ .Values.clickhouse.ttl }}
+    TTL timeInserted + INTERVAL {{ $viewsTTL }}
     SETTINGS merge_with_ttl_timeout = {{ $ttlTimeout }}
     POPULATE
     AS SELECT
@@ -271,14 +276,18 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
         destinationIP,
         clusterUUID;
 
-    --Create a table to store the network policy recommendation results
+    --Create a table to store the network policy recommendation results, one
+    --row for each part of the recommended policies of each Namespace, the
+    --cluster-scoped ones having an empty Namespace
     CREATE TABLE IF NOT EXISTS recommendations_local (
         id String,
         type String,
         timeCreated DateTime,
-        yamls String
+        yamls String,
+        part UInt32 DEFAULT 0,
+        namespace String DEFAULT ''
     ) engine=ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
-    ORDER BY (timeCreated);
+    ORDER BY (id, namespace, part);
 
     --Create distributed tables for cluster
     CREATE TABLE IF NOT EXISTS flows AS flows_local
@@ -295,5 +304,113 @@ clickhouse client -n -h 127.0.0.1 <<-EOSQL
 
     CREATE TABLE IF NOT EXISTS recommendations AS recommendations_local
     engine=Distributed('{cluster}', default, recommendations_local, rand());
+
+    --Create a table to store the reproducibility manifests of the policy
+    --recommendation jobs
+    CREATE TABLE IF NOT EXISTS recommendation_manifests_local (
+        id String,
+        timeUpdated DateTime DEFAULT now(),
+        manifest String
+    ) engine=ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}', timeUpdated)
+    ORDER BY (id);
+
+    CREATE TABLE IF NOT EXISTS recommendation_manifests AS recommendation_manifests_local
+    engine=Distributed('{cluster}', default, recommendation_manifests_local, rand());
+
+    --Create a table to store the recommended policies rejected by users, whose
+    --rules are not recommended again
+    CREATE TABLE IF NOT EXISTS recommendation_exclusions_local (
+        id String,
+        policyName String,
+        kind String,
+        namespace String,
+        timeCreated DateTime,
+        policy String
+    ) engine=ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}', timeCreated)
+    ORDER BY (id, policyName);
+
+    CREATE TABLE IF NOT EXISTS recommendation_exclusions AS recommendation_exclusions_local
+    engine=Distributed('{cluster}', default, recommendation_exclusions_local, rand());
+
+    --Create a table to store the Namespaces, Services and Pod label sets of the
+    --cluster at the time policy recommendation jobs were submitted
+    CREATE TABLE IF NOT EXISTS cluster_snapshots_local (
+        id String,
+        timeCreated DateTime,
+        kind String,
+        namespace String,
+        name String,
+        labels String
+    ) engine=ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+    ORDER BY (id, kind, namespace, name);
+
+    CREATE TABLE IF NOT EXISTS cluster_snapshots AS cluster_snapshots_local
+    engine=Distributed('{cluster}', default, cluster_snapshots_local, rand());
+
+    --Create a table to store Theia metadata, e.g. the flow schema version
+    CREATE TABLE IF NOT EXISTS theia_metadata_local (
+        key String,
+        value String,
+        timeUpdated DateTime DEFAULT now()
+    ) engine=ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}', timeUpdated)
+    ORDER BY (key);
+
+    CREATE TABLE IF NOT EXISTS theia_metadata AS theia_metadata_local
+    engine=Distributed('{cluster}', default, theia_metadata_local, rand());
+
+    --Create a table to store the flow coverage of each Namespace, computed by
+    --theia-manager
+    CREATE TABLE IF NOT EXISTS flow_coverage_local (
+        timeCreated DateTime,
+        namespace String,
+        flows UInt64,
+        coveredFlows UInt64
+    ) engine=ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+    ORDER BY (namespace, timeCreated);
+
+    CREATE TABLE IF NOT EXISTS flow_coverage AS flow_coverage_local
+    engine=Distributed('{cluster}', default, flow_coverage_local, rand());
+
+    --Create a table to store the state transitions of the policy
+    --recommendation jobs, and the Kubernetes events of their objects, recorded
+    --by theia-manager
+    CREATE TABLE IF NOT EXISTS recommendation_events_local (
+        id String,
+        timeCreated DateTime,
+        type String,
+        reason String,
+        message String
+    ) engine=ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+    ORDER BY (id, timeCreated);
+
+    CREATE TABLE IF NOT EXISTS recommendation_events AS recommendation_events_local
+    engine=Distributed('{cluster}', default, recommendation_events_local, rand());
 EOSQL
 }
+
+# Set the TTLs of the retention spec on existing tables, as CREATE TABLE IF NOT
+# EXISTS does not update them. The TTLs are only applied to the existing data
+# during the next merges, so that restarting ClickHouse does not trigger a
+# rewrite of all the data.
+function applyRetention {
+{{- range .Values.clickhouse.retention.columnTTLs }}
+{{- $columnTTL := .ttl }}
+{{- range .columns }}
+{{- if has . (list "timeInserted" "flowEndSeconds") }}
+{{- fail (printf "clickhouse.retention.columnTTLs: column %s is in the sorting key of the flows table and cannot have a TTL" .) }}
+{{- end }}
+    type=$(clickhouse client -h 127.0.0.1 -q "SELECT type FROM system.columns WHERE database = currentDatabase() AND table = 'flows_local' AND name = '{{ . }}'")
+    if [[ -z $type ]]; then
+        echo "=== Column {{ . }} of the retention spec does not exist in table flows_local ==="
+        exit 1
+    fi
+    clickhouse client -h 127.0.0.1 -q "ALTER TABLE flows_local MODIFY COLUMN {{ . }} $type TTL timeInserted + INTERVAL {{ $columnTTL }} SETTINGS materialize_ttl_after_modify = 0"
+{{- end }}
+{{- end }}
+    # The data of the materialized views is stored in their inner tables.
+    for view in flows_pod_view_local flows_node_view_local flows_policy_view_local; do
+        table=$(clickhouse client -h 127.0.0.1 -q "SELECT if(d.engine = 'Atomic', concat('.inner_id.', toString(t.uuid)), concat('.inner.', t.name)) FROM system.tables AS t INNER JOIN system.databases AS d ON t.database = d.name WHERE t.database = currentDatabase() AND t.name = '$view'")
+        clickhouse client -h 127.0.0.1 -q "ALTER TABLE \`$table\` MODIFY TTL timeInserted + INTERVAL {{ $viewsTTL }} SETTINGS materialize_ttl_after_modify = 0"
+    done
+    echo "=== Applied retention spec ==="
+}
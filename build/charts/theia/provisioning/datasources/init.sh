@@ -32,7 +32,15 @@ function setDataVersion {
     echo "=== Set data schema version to {{ .Chart.Version }} ==="
 }
 
+# The flow schema version must match FlowSchemaVersion in pkg/clickhouse/schema.go
+function setFlowSchemaVersion {
+    clickhouse client -h 127.0.0.1 -q "INSERT INTO theia_metadata_local (key, value) VALUES ('flowSchemaVersion', '1')"
+    echo "=== Set flow schema version to 1 ==="
+}
+
 ../clickhouse-schema-management
 createTable
+applyRetention
 setDataVersion
+setFlowSchemaVersion
 
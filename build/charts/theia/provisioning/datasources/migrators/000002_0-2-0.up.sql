@@ -3,3 +3,7 @@ ALTER TABLE flows
 ADD COLUMN clusterUUID String;
 ALTER TABLE flows_local
 ADD COLUMN clusterUUID String;
+ALTER TABLE recommendations
+ADD COLUMN part UInt32 DEFAULT 0;
+ALTER TABLE recommendations_local
+ADD COLUMN part UInt32 DEFAULT 0;
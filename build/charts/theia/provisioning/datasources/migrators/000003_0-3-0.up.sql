@@ -0,0 +1,21 @@
+--Recreate the recommendations tables sorted by job ID and Namespace, the
+--sorting key of a table cannot be changed in place
+CREATE TABLE recommendations_backup
+engine=MergeTree ORDER BY tuple()
+AS SELECT id, type, timeCreated, yamls, part FROM recommendations_local;
+DROP TABLE recommendations;
+DROP TABLE recommendations_local SYNC;
+CREATE TABLE recommendations_local (
+    id String,
+    type String,
+    timeCreated DateTime,
+    yamls String,
+    part UInt32 DEFAULT 0,
+    namespace String DEFAULT ''
+) engine=ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+ORDER BY (id, namespace, part);
+CREATE TABLE recommendations AS recommendations_local
+engine=Distributed('{cluster}', default, recommendations_local, rand());
+INSERT INTO recommendations_local (id, type, timeCreated, yamls, part)
+SELECT id, type, timeCreated, yamls, part FROM recommendations_backup;
+DROP TABLE recommendations_backup;
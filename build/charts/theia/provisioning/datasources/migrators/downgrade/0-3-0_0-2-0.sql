@@ -3,3 +3,7 @@ ALTER TABLE flows
 DROP clusterUUID String;
 ALTER TABLE flows_local
 DROP clusterUUID String;
+ALTER TABLE recommendations
+DROP COLUMN part;
+ALTER TABLE recommendations_local
+DROP COLUMN part;
@@ -0,0 +1,26 @@
+--Recreate the recommendations tables sorted by creation time, joining the
+--parts of each result
+CREATE TABLE recommendations_backup
+engine=MergeTree ORDER BY tuple()
+AS SELECT id, any(type) AS type, any(timeCreated) AS timeCreated,
+    arrayStringConcat(arrayFilter(x -> x != '', groupArray(yamls)), '---\n') AS yamls
+FROM (
+    SELECT id, type, timeCreated, yamls FROM recommendations_local
+    ORDER BY id, namespace, part
+)
+GROUP BY id;
+DROP TABLE recommendations;
+DROP TABLE recommendations_local SYNC;
+CREATE TABLE recommendations_local (
+    id String,
+    type String,
+    timeCreated DateTime,
+    yamls String,
+    part UInt32 DEFAULT 0
+) engine=ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')
+ORDER BY (timeCreated);
+CREATE TABLE recommendations AS recommendations_local
+engine=Distributed('{cluster}', default, recommendations_local, rand());
+INSERT INTO recommendations_local (id, type, timeCreated, yamls)
+SELECT id, type, timeCreated, yamls FROM recommendations_backup;
+DROP TABLE recommendations_backup;
@@ -126,6 +126,10 @@
 {{- end }}
 {{- end -}}
 
+{{- define "ipfixExporterImage" -}}
+{{- print .Values.ipfixExporter.image.repository ":" (include "theiaImageTag" (dict "tag" .Values.ipfixExporter.image.tag "Chart" .Chart)) -}}
+{{- end -}}
+
 {{- define "theiaManagerImage" -}}
 {{- print .Values.theiaManager.image.repository ":" (include "theiaManagerImageTag" .) -}}
 {{- end -}}
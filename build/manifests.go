@@ -0,0 +1,34 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package build embeds the manifests of the Theia components, so that they can
+// be installed by "theia install flow-visibility" without a copy of the
+// repository. The manifests are generated by "make manifest".
+package build
+
+import _ "embed"
+
+var (
+	// ClickHouseOperator is the manifest of the ClickHouse Operator and of
+	// its CRDs, which must be installed before the other components.
+	//go:embed charts/theia/crds/clickhouse-operator-install-bundle.yaml
+	ClickHouseOperator []byte
+	// FlowVisibility is the manifest of ClickHouse and Grafana.
+	//go:embed yamls/flow-visibility.yml
+	FlowVisibility []byte
+	// SparkOperator is the manifest of the Spark Operator and of its CRDs,
+	// which is applied together with FlowVisibility.
+	//go:embed yamls/spark-operator.yml
+	SparkOperator []byte
+)
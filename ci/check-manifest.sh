@@ -27,6 +27,7 @@ pushd $THIS_DIR/.. > /dev/null
 
 YAMLS=(
     "build/yamls/flow-visibility.yml"
+    "build/yamls/spark-operator.yml"
 )
 
 rm "${YAMLS[@]}"
@@ -23,8 +23,14 @@ import (
 
 	"antrea.io/theia/pkg/apis"
 	managerconfig "antrea.io/theia/pkg/config/theiamanager"
+	"antrea.io/theia/pkg/controller/flowcoverage"
+	"antrea.io/theia/pkg/controller/jobevents"
+	"antrea.io/theia/pkg/controller/kafkaexport"
+	"antrea.io/theia/pkg/controller/report"
 )
 
+const defaultClickHouseURL = "tcp://clickhouse-clickhouse.flow-visibility.svc:9000"
+
 type Options struct {
 	// The path of configuration file.
 	configFile string
@@ -84,6 +90,24 @@ func (o *Options) setDefaults() {
 	if o.config.APIServer.SelfSignedCert == nil {
 		o.config.APIServer.SelfSignedCert = ptrBool(true)
 	}
+	if o.config.ClickHouse.DatabaseURL == "" {
+		o.config.ClickHouse.DatabaseURL = defaultClickHouseURL
+	}
+	if o.config.FlowCoverage.Interval == "" {
+		o.config.FlowCoverage.Interval = flowcoverage.DefaultInterval.String()
+	}
+	if o.config.JobEvents.Interval == "" {
+		o.config.JobEvents.Interval = jobevents.DefaultInterval.String()
+	}
+	if o.config.Report.Interval == "" {
+		o.config.Report.Interval = report.DefaultInterval.String()
+	}
+	if o.config.KafkaExport.JobEventsTopic == "" {
+		o.config.KafkaExport.JobEventsTopic = kafkaexport.DefaultJobEventsTopic
+	}
+	if o.config.KafkaExport.RecommendationsTopic == "" {
+		o.config.KafkaExport.RecommendationsTopic = kafkaexport.DefaultRecommendationsTopic
+	}
 }
 
 func ptrBool(value bool) *bool {
@@ -16,6 +16,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net"
 	"os"
@@ -25,6 +26,7 @@ import (
 	"antrea.io/antrea/pkg/log"
 	"antrea.io/antrea/pkg/signals"
 	"antrea.io/antrea/pkg/util/cipher"
+	_ "github.com/ClickHouse/clickhouse-go"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	genericoptions "k8s.io/apiserver/pkg/server/options"
 	clientset "k8s.io/client-go/kubernetes"
@@ -33,10 +35,21 @@ import (
 
 	"antrea.io/theia/pkg/apiserver"
 	"antrea.io/theia/pkg/apiserver/certificate"
+	"antrea.io/theia/pkg/clickhouse"
 	crdclientset "antrea.io/theia/pkg/client/clientset/versioned"
 	crdinformers "antrea.io/theia/pkg/client/informers/externalversions"
+	managerconfig "antrea.io/theia/pkg/config/theiamanager"
+	"antrea.io/theia/pkg/controller/flowcoverage"
+	"antrea.io/theia/pkg/controller/jobevents"
+	"antrea.io/theia/pkg/controller/kafkaexport"
 	"antrea.io/theia/pkg/controller/networkpolicyrecommendation"
+	"antrea.io/theia/pkg/controller/report"
 	"antrea.io/theia/pkg/querier"
+	"antrea.io/theia/pkg/util/env"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/faultinjection"
+	"antrea.io/theia/pkg/util/kafka"
+	"antrea.io/theia/pkg/util/sink"
 )
 
 // informerDefaultResync is the default resync period if a handler doesn't specify one.
@@ -93,6 +106,73 @@ func createAPIServerConfig(
 		nprq), nil
 }
 
+// openClickHouse returns a handle to the ClickHouse database. The connection is
+// established lazily, so that theia-manager can start before ClickHouse. The
+// credentials are read from the environment, populated from the
+// clickhouse-secret Secret.
+func openClickHouse(databaseURL string) (*sql.DB, error) {
+	username := os.Getenv("CH_USERNAME")
+	password := os.Getenv("CH_PASSWORD")
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("unable to load environment variables, CH_USERNAME and CH_PASSWORD must be defined")
+	}
+	return sql.Open("clickhouse", fmt.Sprintf("%s?debug=false&username=%s&password=%s", databaseURL, username, password))
+}
+
+// kafkaTopic returns the topic to which messages are published, "-" meaning
+// that they are not published.
+func kafkaTopic(topic string) string {
+	if topic == "-" {
+		return ""
+	}
+	return topic
+}
+
+// newKafkaProducer returns the producer publishing to the brokers of the
+// configuration. The SASL credentials are read from the environment,
+// populated from the Secret given in the Helm values.
+func newKafkaProducer(config *managerconfig.KafkaExportConfig) (*kafka.Producer, error) {
+	producerConfig := kafka.Config{
+		Brokers:       config.Brokers,
+		ClientID:      "theia-manager",
+		SASLMechanism: config.SASLMechanism,
+	}
+	if config.TLS.Enable {
+		producerConfig.TLS = &kafka.TLSConfig{
+			CAFile:             config.TLS.CAFile,
+			CertFile:           config.TLS.CertFile,
+			KeyFile:            config.TLS.KeyFile,
+			InsecureSkipVerify: config.TLS.InsecureSkipVerify,
+		}
+	}
+	return kafka.NewProducer(producerConfig)
+}
+
+// newReportController returns the controller delivering the reports to the
+// configured sinks.
+func newReportController(config *managerconfig.ReportConfig, db *sql.DB, governor *clickhouse.QueryGovernor, client clientset.Interface) (*report.ReportController, error) {
+	interval, err := time.ParseDuration(config.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report interval: %v", err)
+	}
+	if len(config.Sinks) == 0 {
+		return nil, fmt.Errorf("at least one report sink is required")
+	}
+	var sinks []sink.Sink
+	for _, uri := range config.Sinks {
+		s, err := sink.New(uri, sink.Options{Out: os.Stdout, KubeClient: client})
+		if err != nil {
+			return nil, fmt.Errorf("invalid report sink: %v", err)
+		}
+		sinks = append(sinks, s)
+	}
+	tmpl, err := report.ParseTemplate(config.TemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	return report.NewReportController(db, governor, sinks, tmpl, interval, config.TopTalkers), nil
+}
+
 func run(o *Options) error {
 	klog.InfoS("Theia manager starting...")
 	// Set up signal capture: the first SIGTERM / SIGINT signal is handled gracefully and will
@@ -121,7 +201,23 @@ func run(o *Options) error {
 	}
 	crdInformerFactory := crdinformers.NewSharedInformerFactory(crdClient, informerDefaultResync)
 	npRecommendationInformer := crdInformerFactory.Crd().V1alpha1().NetworkPolicyRecommendations()
-	npRecoController := networkpolicyrecommendation.NewNPRecommendationController(crdClient, npRecommendationInformer)
+	faultInjector, err := faultinjection.NewInjectorFromSpec(o.config.FaultInjection)
+	if err != nil {
+		return fmt.Errorf("invalid fault injection configuration: %v", err)
+	}
+	quota := networkpolicyrecommendation.Quota{
+		MaxConcurrentJobs: o.config.JobQuota.MaxConcurrentJobsPerUser,
+		MaxDailyJobs:      o.config.JobQuota.MaxDailyJobsPerUser,
+	}
+	// ClickHouse is used by the NetworkPolicyRecommendation controller to
+	// read the results written to the sinks of the jobs, and by the
+	// optional controllers below.
+	db, err := openClickHouse(o.config.ClickHouse.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("error when opening ClickHouse: %v", err)
+	}
+	defer db.Close()
+	npRecoController := networkpolicyrecommendation.NewNPRecommendationController(crdClient, client, npRecommendationInformer, quota, faultInjector, db)
 
 	cipherSuites, err := cipher.GenerateCipherSuitesList(o.config.APIServer.TLSCipherSuites)
 	if err != nil {
@@ -143,6 +239,46 @@ func run(o *Options) error {
 		return fmt.Errorf("error when creating API server: %v", err)
 	}
 
+	// The heavy queries of the optional controllers share the same limit.
+	governor := clickhouse.NewQueryGovernor(o.config.ClickHouse.MaxConcurrentQueries)
+	if o.config.FlowCoverage.Enable {
+		interval, err := time.ParseDuration(o.config.FlowCoverage.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid flow coverage interval: %v", err)
+		}
+		flowcoverage.InitializeMetrics()
+		flowCoverageController := flowcoverage.NewFlowCoverageController(db, governor, interval)
+		go flowCoverageController.Run(stopCh)
+	}
+	if o.config.Report.Enable {
+		reportController, err := newReportController(&o.config.Report, db, governor, client)
+		if err != nil {
+			return err
+		}
+		go reportController.Run(stopCh)
+	}
+	if o.config.JobEvents.Enable {
+		interval, err := time.ParseDuration(o.config.JobEvents.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid job events interval: %v", err)
+		}
+		jobEventsController := jobevents.NewJobEventsController(db, client, executor.All(executor.Options{Clientset: client, Namespace: env.GetTheiaNamespace()}), interval)
+		go jobEventsController.Run(stopCh)
+	}
+	if o.config.KafkaExport.Enable {
+		producer, err := newKafkaProducer(&o.config.KafkaExport)
+		if err != nil {
+			return fmt.Errorf("error when creating Kafka producer: %v", err)
+		}
+		defer producer.Close()
+		topics := kafkaexport.Topics{
+			JobEvents:       kafkaTopic(o.config.KafkaExport.JobEventsTopic),
+			Recommendations: kafkaTopic(o.config.KafkaExport.RecommendationsTopic),
+		}
+		kafkaExportController := kafkaexport.NewKafkaExportController(producer, topics, db, npRecommendationInformer)
+		go kafkaExportController.Run(stopCh)
+	}
+
 	crdInformerFactory.Start(stopCh)
 	go npRecoController.Run(stopCh)
 	go apiServer.Run(ctx)
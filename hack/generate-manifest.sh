@@ -28,6 +28,9 @@ Kustomize, and print it to stdout.
                                             repository, which only includes a ClickHouse server with default
                                             credentials.
         --spark-operator                    Generate a manifest with Spark Operator enabled.
+        --spark-operator-only               Generate a manifest with only Spark Operator and its CRDs, which
+                                            is applied together with the default manifest by
+                                            "theia install flow-visibility --with-spark-operator".
         --theia-manager                     Generate a manifest with Theia Manager enabled.
         --no-grafana                        Generate a manifest with Grafana disabled.
         --ch-size <size>                    Deploy the ClickHouse with a specific storage size. Can be a 
@@ -54,6 +57,7 @@ function print_help {
 
 MODE="dev"
 SPARK_OP=false
+SPARK_OP_ONLY=false
 THEIA_MANAGER=false
 GRAFANA=true
 CH_SIZE="8Gi"
@@ -73,6 +77,10 @@ case $key in
     SPARK_OP=true
     shift 1
     ;;
+    --spark-operator-only)
+    SPARK_OP_ONLY=true
+    shift 1
+    ;;
     --theia-manager)
     THEIA_MANAGER=true
     shift 1
@@ -177,6 +185,23 @@ fi
 
 THEIA_CHART=$THIS_DIR/../build/charts/theia
 
+# The Spark Operator only manifest is made of the Spark Operator CRDs and the
+# Spark Operator templates of the chart.
+if $SPARK_OP_ONLY; then
+    SPARK_OP_TEMPLATES=()
+    for template in $THEIA_CHART/templates/spark-operator/*.yaml; do
+        SPARK_OP_TEMPLATES+=("--show-only" "templates/spark-operator/$(basename $template)")
+    done
+    cat $THEIA_CHART/crds/spark-operator-crds.yaml
+    $HELM template \
+      --namespace flow-visibility \
+      --set sparkOperator.enable=true \
+      "${SPARK_OP_TEMPLATES[@]}" \
+      "$THEIA_CHART"\
+      2> >(grep -v 'This is insecure' >&2)
+    exit 0
+fi
+
 # For antrea-e2e mode, extra Namespace resource is not required.
 # The manifest can be generated with Helm directly.
 if [ "$MODE" == "antrea-e2e" ]; then
@@ -48,10 +48,17 @@ type NetworkPolicyRecommendationSpec struct {
 	DriverMemory        string      `json:"driverMemory,omitempty"`
 	ExecutorCoreRequest string      `json:"executorCoreRequest,omitempty"`
 	ExecutorMemory      string      `json:"executorMemory,omitempty"`
+	Backend             string      `json:"backend,omitempty"`
+	ArtifactsURI        string      `json:"artifactsURI,omitempty"`
+	ArtifactsEndpoint   string      `json:"artifactsEndpoint,omitempty"`
+	ArtifactsSecret     string      `json:"artifactsSecret,omitempty"`
+	Sink                string      `json:"sink,omitempty"`
 }
 
 type NetworkPolicyRecommendationStatus struct {
-	State string `json:"state,omitempty"`
+	State     string `json:"state,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	ErrorMsg  string `json:"errorMsg,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -24,6 +24,7 @@ const (
 	NPRecommendationStateRunning   string = "RUNNING"
 	NPRecommendationStateCompleted string = "COMPLETED"
 	NPRecommendationStateFailed    string = "FAILED"
+	NPRecommendationStateCancelled string = "CANCELLED"
 )
 
 // +genclient
@@ -47,6 +48,11 @@ type NetworkPolicyRecommendation struct {
 	DriverMemory        string                            `json:"driverMemory,omitempty"`
 	ExecutorCoreRequest string                            `json:"executorCoreRequest,omitempty"`
 	ExecutorMemory      string                            `json:"executorMemory,omitempty"`
+	Backend             string                            `json:"backend,omitempty"`
+	ArtifactsURI        string                            `json:"artifactsURI,omitempty"`
+	ArtifactsEndpoint   string                            `json:"artifactsEndpoint,omitempty"`
+	ArtifactsSecret     string                            `json:"artifactsSecret,omitempty"`
+	Sink                string                            `json:"sink,omitempty"`
 	Status              NetworkPolicyRecommendationStatus `json:"status,omitempty"`
 }
 
@@ -30,6 +30,7 @@ import (
 	intelligenceinstall "antrea.io/theia/pkg/apis/intelligence/install"
 	intelligence "antrea.io/theia/pkg/apis/intelligence/v1alpha1"
 	"antrea.io/theia/pkg/apiserver/certificate"
+	"antrea.io/theia/pkg/apiserver/handlers/grafana"
 	"antrea.io/theia/pkg/apiserver/registry/intelligence/networkpolicyrecommendation"
 	"antrea.io/theia/pkg/querier"
 )
@@ -123,6 +124,12 @@ func installAPIGroup(s *TheiaManagerAPIServer) error {
 	return nil
 }
 
+func installHandlers(c *ExtraConfig, s *genericapiserver.GenericAPIServer) {
+	grafanaHandler := grafana.HandleFunc(c.npRecommendationQuerier, c.k8sClient)
+	s.Handler.NonGoRestfulMux.HandleFunc(grafana.Path, grafanaHandler)
+	s.Handler.NonGoRestfulMux.HandlePrefix(grafana.Path+"/", grafanaHandler)
+}
+
 func (c Config) New() (*TheiaManagerAPIServer, error) {
 	completedServerCfg := c.genericConfig.Complete(nil)
 	s, err := completedServerCfg.New(Name, genericapiserver.NewEmptyDelegate())
@@ -136,6 +143,7 @@ func (c Config) New() (*TheiaManagerAPIServer, error) {
 	if err := installAPIGroup(apiServer); err != nil {
 		return nil, err
 	}
+	installHandlers(&c.extraConfig, s)
 	return apiServer, nil
 }
 
@@ -0,0 +1,290 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grafana implements the API of the Grafana JSON datasource
+// (https://grafana.com/grafana/plugins/simpod-json-datasource/), so that
+// Grafana dashboards can show the status of the Theia Manager jobs without
+// access to ClickHouse.
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	intelligence "antrea.io/theia/pkg/apis/intelligence/v1alpha1"
+	"antrea.io/theia/pkg/querier"
+	"antrea.io/theia/pkg/util/env"
+)
+
+const (
+	// Path is the prefix under which the datasource API is served.
+	Path = "/grafana"
+
+	// MetricRecommendationJobs is a table listing the NetworkPolicy
+	// recommendation jobs.
+	MetricRecommendationJobs = "recommendation_jobs"
+	// MetricRecommendationJobsByState is a time series per job state, with
+	// the number of jobs in that state.
+	MetricRecommendationJobsByState = "recommendation_jobs_by_state"
+	// MetricRecommendationNamespaceCoverage is the percentage of Namespaces
+	// for which policies have been recommended by at least one completed job.
+	MetricRecommendationNamespaceCoverage = "recommendation_namespace_coverage"
+	// metricAnomalySeries would be the time series of the detected
+	// anomalies, but Theia does not run anomaly detection jobs yet.
+	metricAnomalySeries = "anomaly_series"
+)
+
+// errUnsupportedTarget is returned for the targets which are not supported by
+// this version of Theia.
+var errUnsupportedTarget = errors.New("unsupported target")
+
+var metrics = []string{
+	MetricRecommendationJobs,
+	MetricRecommendationJobsByState,
+	MetricRecommendationNamespaceCoverage,
+}
+
+// defaultNSAllowList is the list of Namespaces in which all traffic is allowed
+// when no allow list is provided for a NetworkPolicy recommendation job.
+var defaultNSAllowList = []string{"kube-system", "flow-aggregator", "flow-visibility"}
+
+var jobStates = []string{
+	intelligence.NPRecommendationStateNew,
+	intelligence.NPRecommendationStateScheduled,
+	intelligence.NPRecommendationStateRunning,
+	intelligence.NPRecommendationStateCompleted,
+	intelligence.NPRecommendationStateFailed,
+	intelligence.NPRecommendationStateCancelled,
+}
+
+type queryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+	} `json:"targets"`
+}
+
+type timeSeries struct {
+	Target string `json:"target"`
+	// Datapoints are [value, timestamp in milliseconds] pairs.
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type tableColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type table struct {
+	Type    string          `json:"type"`
+	Columns []tableColumn   `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+type metricOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// HandleFunc returns the handler of the Grafana JSON datasource API:
+// - GET /grafana is used by Grafana to test the datasource.
+// - POST /grafana/search (and /grafana/metrics for newer plugin versions)
+// lists the available metrics.
+// - POST /grafana/query returns the data of the requested metrics.
+// The jobs are listed in the Namespace in which Theia is running.
+func HandleFunc(nprq querier.NPRecommendationQuerier, k8sClient kubernetes.Interface) http.HandlerFunc {
+	namespace := env.GetTheiaNamespace()
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, Path), "/") {
+		case "":
+			w.WriteHeader(http.StatusOK)
+		case "/search":
+			writeJSON(w, metrics)
+		case "/metrics":
+			options := make([]metricOption, 0, len(metrics))
+			for _, metric := range metrics {
+				options = append(options, metricOption{Label: metric, Value: metric})
+			}
+			writeJSON(w, options)
+		case "/query":
+			if r.Method != http.MethodPost {
+				http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+				return
+			}
+			var request queryRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			results, err := query(r.Context(), nprq, k8sClient, namespace, &request)
+			if errors.Is(err, errUnsupportedTarget) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else if err != nil {
+				klog.ErrorS(err, "Error when running Grafana query")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, results)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func query(ctx context.Context, nprq querier.NPRecommendationQuerier, k8sClient kubernetes.Interface, namespace string, request *queryRequest) ([]interface{}, error) {
+	timestamp := request.Range.To
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	results := []interface{}{}
+	for _, target := range request.Targets {
+		switch target.Target {
+		case MetricRecommendationJobs:
+			jobs, err := nprq.ListNetworkPolicyRecommendations(namespace)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, jobsTable(jobs))
+		case MetricRecommendationJobsByState:
+			jobs, err := nprq.ListNetworkPolicyRecommendations(namespace)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, jobsByState(jobs, timestamp)...)
+		case MetricRecommendationNamespaceCoverage:
+			jobs, err := nprq.ListNetworkPolicyRecommendations(namespace)
+			if err != nil {
+				return nil, err
+			}
+			namespaces, err := k8sClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, 0, len(namespaces.Items))
+			for _, namespace := range namespaces.Items {
+				names = append(names, namespace.Name)
+			}
+			results = append(results, timeSeries{
+				Target:     MetricRecommendationNamespaceCoverage,
+				Datapoints: [][2]float64{{namespaceCoverage(jobs, names), toMillis(timestamp)}},
+			})
+		case metricAnomalySeries:
+			return nil, fmt.Errorf("%w %s: anomaly detection is not supported by this version of Theia", errUnsupportedTarget, target.Target)
+		}
+	}
+	return results, nil
+}
+
+func jobsTable(jobs []*crdv1alpha1.NetworkPolicyRecommendation) table {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreationTimestamp.After(jobs[j].CreationTimestamp.Time)
+	})
+	result := table{
+		Type: "table",
+		Columns: []tableColumn{
+			{Text: "Name", Type: "string"},
+			{Text: "Type", Type: "string"},
+			{Text: "Policy Type", Type: "string"},
+			{Text: "State", Type: "string"},
+			{Text: "Created", Type: "time"},
+		},
+		Rows: [][]interface{}{},
+	}
+	for _, job := range jobs {
+		result.Rows = append(result.Rows, []interface{}{
+			job.Name,
+			job.Spec.Type,
+			job.Spec.PolicyType,
+			job.Status.State,
+			toMillis(job.CreationTimestamp.Time),
+		})
+	}
+	return result
+}
+
+func jobsByState(jobs []*crdv1alpha1.NetworkPolicyRecommendation, timestamp time.Time) []interface{} {
+	counts := map[string]int{}
+	for _, job := range jobs {
+		state := job.Status.State
+		if state == "" {
+			state = intelligence.NPRecommendationStateNew
+		}
+		counts[state]++
+	}
+	series := make([]interface{}, 0, len(jobStates))
+	for _, state := range jobStates {
+		series = append(series, timeSeries{
+			Target:     state,
+			Datapoints: [][2]float64{{float64(counts[state]), toMillis(timestamp)}},
+		})
+	}
+	return series
+}
+
+// namespaceCoverage returns the percentage of the given Namespaces for which
+// policies have been recommended by at least one completed job, i.e. which are
+// not in the Namespace allow list of that job.
+func namespaceCoverage(jobs []*crdv1alpha1.NetworkPolicyRecommendation, namespaces []string) float64 {
+	if len(namespaces) == 0 {
+		return 0
+	}
+	covered := map[string]bool{}
+	for _, job := range jobs {
+		if job.Status.State != intelligence.NPRecommendationStateCompleted {
+			continue
+		}
+		nsAllowList := job.Spec.NSAllowList
+		if len(nsAllowList) == 0 {
+			nsAllowList = defaultNSAllowList
+		}
+		allowed := map[string]bool{}
+		for _, namespace := range nsAllowList {
+			allowed[namespace] = true
+		}
+		for _, namespace := range namespaces {
+			if !allowed[namespace] {
+				covered[namespace] = true
+			}
+		}
+	}
+	return float64(len(covered)) * 100 / float64(len(namespaces))
+}
+
+func toMillis(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func writeJSON(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		klog.ErrorS(err, "Error when encoding Grafana response")
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
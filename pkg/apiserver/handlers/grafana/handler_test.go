@@ -0,0 +1,169 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+)
+
+// testTheiaNamespace is the Namespace of the jobs, in which Theia is running.
+const testTheiaNamespace = "theia"
+
+type fakeQuerier struct {
+	jobs []*crdv1alpha1.NetworkPolicyRecommendation
+}
+
+func (q *fakeQuerier) GetNetworkPolicyRecommendation(namespace, name string) (*crdv1alpha1.NetworkPolicyRecommendation, error) {
+	return nil, nil
+}
+
+func (q *fakeQuerier) ListNetworkPolicyRecommendations(namespace string) ([]*crdv1alpha1.NetworkPolicyRecommendation, error) {
+	var jobs []*crdv1alpha1.NetworkPolicyRecommendation
+	for _, job := range q.jobs {
+		if job.Namespace == namespace {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func newJob(name string, state string, created time.Time, nsAllowList []string) *crdv1alpha1.NetworkPolicyRecommendation {
+	return &crdv1alpha1.NetworkPolicyRecommendation{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testTheiaNamespace, CreationTimestamp: metav1.NewTime(created)},
+		Spec:       crdv1alpha1.NetworkPolicyRecommendationSpec{Type: "initial", PolicyType: "anp-deny-applied", NSAllowList: nsAllowList},
+		Status:     crdv1alpha1.NetworkPolicyRecommendationStatus{State: state},
+	}
+}
+
+func TestHandleFunc(t *testing.T) {
+	created := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	querier := &fakeQuerier{jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+		newJob("pr-1", "COMPLETED", created, []string{"kube-system", "default"}),
+		newJob("pr-2", "RUNNING", created.Add(time.Hour), nil),
+		newJob("pr-3", "", created.Add(2*time.Hour), nil),
+	}}
+	k8sClient := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "frontend"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "backend"}},
+	)
+	// Theia is installed in a custom Namespace.
+	t.Setenv("POD_NAMESPACE", testTheiaNamespace)
+	handler := HandleFunc(querier, k8sClient)
+
+	testCases := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedResponse string
+		expectedError    string
+	}{
+		{
+			name:           "test datasource",
+			method:         http.MethodGet,
+			path:           "/grafana",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "search metrics",
+			method:           http.MethodPost,
+			path:             "/grafana/search",
+			body:             `{"target": ""}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `["recommendation_jobs","recommendation_jobs_by_state","recommendation_namespace_coverage"]`,
+		},
+		{
+			name:           "query jobs",
+			method:         http.MethodPost,
+			path:           "/grafana/query",
+			body:           `{"range": {"from": "2022-08-01T00:00:00Z", "to": "2022-08-02T00:00:00Z"}, "targets": [{"target": "recommendation_jobs", "refId": "A"}]}`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `[{"type":"table","columns":[{"text":"Name","type":"string"},{"text":"Type","type":"string"},{"text":"Policy Type","type":"string"},{"text":"State","type":"string"},{"text":"Created","type":"time"}],` +
+				`"rows":[["pr-3","initial","anp-deny-applied","",1659355200000],["pr-2","initial","anp-deny-applied","RUNNING",1659351600000],["pr-1","initial","anp-deny-applied","COMPLETED",1659348000000]]}]`,
+		},
+		{
+			name:           "query jobs by state",
+			method:         http.MethodPost,
+			path:           "/grafana/query",
+			body:           `{"range": {"from": "2022-08-01T00:00:00Z", "to": "2022-08-02T00:00:00Z"}, "targets": [{"target": "recommendation_jobs_by_state", "refId": "A"}]}`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `[{"target":"NEW","datapoints":[[1,1659398400000]]},{"target":"SCHEDULED","datapoints":[[0,1659398400000]]},` +
+				`{"target":"RUNNING","datapoints":[[1,1659398400000]]},{"target":"COMPLETED","datapoints":[[1,1659398400000]]},{"target":"FAILED","datapoints":[[0,1659398400000]]},` +
+				`{"target":"CANCELLED","datapoints":[[0,1659398400000]]}]`,
+		},
+		{
+			name:             "query namespace coverage",
+			method:           http.MethodPost,
+			path:             "/grafana/query",
+			body:             `{"range": {"from": "2022-08-01T00:00:00Z", "to": "2022-08-02T00:00:00Z"}, "targets": [{"target": "recommendation_namespace_coverage", "refId": "A"}]}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `[{"target":"recommendation_namespace_coverage","datapoints":[[50,1659398400000]]}]`,
+		},
+		{
+			name:           "query anomaly series",
+			method:         http.MethodPost,
+			path:           "/grafana/query",
+			body:           `{"range": {"from": "2022-08-01T00:00:00Z", "to": "2022-08-02T00:00:00Z"}, "targets": [{"target": "recommendation_jobs", "refId": "A"}, {"target": "anomaly_series", "refId": "B"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "unsupported target anomaly_series: anomaly detection is not supported by this version of Theia",
+		},
+		{
+			name:           "invalid query",
+			method:         http.MethodPost,
+			path:           "/grafana/query",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown path",
+			method:         http.MethodGet,
+			path:           "/grafana/annotations",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedError != "" {
+				assert.Equal(t, tt.expectedError, strings.TrimSpace(recorder.Body.String()))
+			}
+			if tt.expectedResponse != "" {
+				var expected, actual interface{}
+				require.NoError(t, json.Unmarshal([]byte(tt.expectedResponse), &expected))
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &actual))
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+}
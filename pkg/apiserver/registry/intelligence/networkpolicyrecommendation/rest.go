@@ -68,6 +68,14 @@ func (r *REST) getNetworkPolicyRecommendation(name string) *intelligence.Network
 	job.DriverMemory = npReco.Spec.DriverMemory
 	job.ExecutorCoreRequest = npReco.Spec.ExecutorCoreRequest
 	job.ExecutorMemory = npReco.Spec.ExecutorMemory
+	job.Backend = npReco.Spec.Backend
+	job.ArtifactsURI = npReco.Spec.ArtifactsURI
+	job.ArtifactsEndpoint = npReco.Spec.ArtifactsEndpoint
+	job.ArtifactsSecret = npReco.Spec.ArtifactsSecret
+	job.Sink = npReco.Spec.Sink
+	job.Status.State = npReco.Status.State
+	job.Status.ErrorCode = npReco.Status.ErrorCode
+	job.Status.ErrorMsg = npReco.Status.ErrorMsg
 	return job
 }
 
@@ -0,0 +1,243 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultBatchSize     = 10000
+	defaultFlushInterval = 5 * time.Second
+	defaultMaxRetries    = 3
+	defaultRetryInterval = time.Second
+	maxRetryInterval     = 30 * time.Second
+)
+
+// ErrWriterClosed is returned when writing to a closed BatchWriter.
+var ErrWriterClosed = errors.New("batch writer is closed")
+
+// BatchWriterConfig configures a BatchWriter. Zero values are replaced with
+// defaults.
+type BatchWriterConfig struct {
+	// Query is the INSERT statement, with one placeholder per column.
+	Query string
+	// BatchSize is the number of rows after which a batch is inserted.
+	// Defaults to 10000.
+	BatchSize int
+	// FlushInterval is the maximum time rows are buffered before being
+	// inserted. Defaults to 5s.
+	FlushInterval time.Duration
+	// QueueSize is the number of rows which can be queued while a batch is
+	// being inserted, before Write blocks. Defaults to BatchSize.
+	QueueSize int
+	// MaxRetries is the number of times the insertion of a batch is retried,
+	// with an exponential backoff starting at RetryInterval. Defaults to 3.
+	// Set it to a negative value to disable retries.
+	MaxRetries int
+	// RetryInterval defaults to 1s.
+	RetryInterval time.Duration
+}
+
+// BatchWriterStats reports the activity of a BatchWriter.
+type BatchWriterStats struct {
+	Rows    int64
+	Batches int64
+	Retries int64
+}
+
+// BatchWriter inserts rows into ClickHouse in batches, instead of one row at a
+// time. Rows are queued by Write and inserted asynchronously, when the batch
+// is full or when the flush interval expires. Write blocks when the queue is
+// full, which applies backpressure to the producer. If a batch cannot be
+// inserted after all retries, the writer fails: the error is returned by all
+// subsequent calls to Write and by Close.
+type BatchWriter struct {
+	db     *sql.DB
+	config BatchWriterConfig
+	rows   chan []interface{}
+	done   chan struct{}
+
+	// closeMutex is held for reading while queueing rows, so that rows is
+	// not closed while a row is being sent.
+	closeMutex sync.RWMutex
+	closed     bool
+
+	mutex sync.Mutex
+	err   error
+	stats BatchWriterStats
+}
+
+func NewBatchWriter(db *sql.DB, config BatchWriterConfig) *BatchWriter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = config.BatchSize
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaultRetryInterval
+	}
+	w := &BatchWriter{
+		db:     db,
+		config: config,
+		rows:   make(chan []interface{}, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues a row, with one value per placeholder of the query. It blocks
+// until there is room in the queue or ctx is done.
+func (w *BatchWriter) Write(ctx context.Context, row ...interface{}) error {
+	w.closeMutex.RLock()
+	defer w.closeMutex.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+	select {
+	case w.rows <- row:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close inserts the queued rows and stops the writer. It returns the error
+// which caused the writer to fail, if any.
+func (w *BatchWriter) Close() error {
+	w.closeMutex.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.rows)
+	}
+	w.closeMutex.Unlock()
+	<-w.done
+	return w.Err()
+}
+
+// Err returns the error which caused the writer to fail, if any.
+func (w *BatchWriter) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+func (w *BatchWriter) Stats() BatchWriterStats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.stats
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([][]interface{}, 0, w.config.BatchSize)
+	for {
+		select {
+		case row, ok := <-w.rows:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			// Once the writer has failed, rows are discarded so that
+			// blocked producers can return.
+			if w.Err() != nil {
+				continue
+			}
+			batch = append(batch, row)
+			if len(batch) >= w.config.BatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (w *BatchWriter) flush(batch [][]interface{}) {
+	if len(batch) == 0 || w.Err() != nil {
+		return
+	}
+	retryInterval := w.config.RetryInterval
+	for attempt := 0; ; attempt++ {
+		err := w.insert(batch)
+		if err == nil {
+			w.mutex.Lock()
+			w.stats.Rows += int64(len(batch))
+			w.stats.Batches++
+			w.mutex.Unlock()
+			return
+		}
+		if attempt >= w.config.MaxRetries {
+			w.mutex.Lock()
+			w.err = fmt.Errorf("failed to insert %d rows after %d attempts: %v", len(batch), attempt+1, err)
+			w.mutex.Unlock()
+			return
+		}
+		klog.V(2).InfoS("Failed to insert rows, retrying", "rows", len(batch), "attempt", attempt+1, "error", err)
+		w.mutex.Lock()
+		w.stats.Retries++
+		w.mutex.Unlock()
+		time.Sleep(retryInterval)
+		retryInterval *= 2
+		if retryInterval > maxRetryInterval {
+			retryInterval = maxRetryInterval
+		}
+	}
+}
+
+func (w *BatchWriter) insert(batch [][]interface{}) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	stmt, err := tx.Prepare(w.config.Query)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+	for _, row := range batch {
+		if _, err := stmt.Exec(row...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert row: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
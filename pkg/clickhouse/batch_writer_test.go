@@ -0,0 +1,134 @@
+// Copyright 2022 Antrea Authors
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const insertQuery = "INSERT INTO flows (sourceIP, destinationIP) VALUES (?, ?)"
+
+func expectBatch(mock sqlmock.Sqlmock, rows ...[]string) {
+	mock.ExpectBegin()
+	prepare := mock.ExpectPrepare(regexp.QuoteMeta(insertQuery))
+	for _, row := range rows {
+		prepare.ExpectExec().WithArgs(row[0], row[1]).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestBatchWriter(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        BatchWriterConfig
+		rows          int
+		prepareMock   func(mock sqlmock.Sqlmock)
+		expectedStats BatchWriterStats
+		expectedErr   string
+	}{
+		{
+			name:   "size-based flush",
+			config: BatchWriterConfig{BatchSize: 2, FlushInterval: time.Hour},
+			rows:   3,
+			prepareMock: func(mock sqlmock.Sqlmock) {
+				expectBatch(mock, []string{"10.0.0.0", "10.0.1.0"}, []string{"10.0.0.1", "10.0.1.1"})
+				expectBatch(mock, []string{"10.0.0.2", "10.0.1.2"})
+			},
+			expectedStats: BatchWriterStats{Rows: 3, Batches: 2},
+		},
+		{
+			name:   "retry",
+			config: BatchWriterConfig{BatchSize: 1, FlushInterval: time.Hour, RetryInterval: time.Millisecond},
+			rows:   1,
+			prepareMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("connection reset"))
+				expectBatch(mock, []string{"10.0.0.0", "10.0.1.0"})
+			},
+			expectedStats: BatchWriterStats{Rows: 1, Batches: 1, Retries: 1},
+		},
+		{
+			name:   "too many failures",
+			config: BatchWriterConfig{BatchSize: 1, FlushInterval: time.Hour, MaxRetries: -1},
+			rows:   1,
+			prepareMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectPrepare(regexp.QuoteMeta(insertQuery)).ExpectExec().WillReturnError(fmt.Errorf("timeout"))
+				mock.ExpectRollback()
+			},
+			expectedErr: "failed to insert 1 rows after 1 attempts: failed to insert row: timeout",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			tt.prepareMock(mock)
+			tt.config.Query = insertQuery
+			writer := NewBatchWriter(db, tt.config)
+			for i := 0; i < tt.rows; i++ {
+				require.NoError(t, writer.Write(context.Background(), fmt.Sprintf("10.0.0.%d", i), fmt.Sprintf("10.0.1.%d", i)))
+			}
+			err = writer.Close()
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.EqualError(t, writer.Write(context.Background(), "10.0.0.0", "10.0.1.0"), ErrWriterClosed.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedStats, writer.Stats())
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBatchWriterTimeBasedFlush(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	expectBatch(mock, []string{"10.0.0.0", "10.0.1.0"})
+	writer := NewBatchWriter(db, BatchWriterConfig{Query: insertQuery, BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer writer.Close()
+	require.NoError(t, writer.Write(context.Background(), "10.0.0.0", "10.0.1.0"))
+	assert.Eventually(t, func() bool {
+		return writer.Stats().Batches == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchWriterBackpressure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	// The first batch is blocked, so the queue fills up.
+	mock.ExpectBegin().WillDelayFor(time.Second)
+	writer := NewBatchWriter(db, BatchWriterConfig{Query: insertQuery, BatchSize: 1, QueueSize: 1, FlushInterval: time.Hour, MaxRetries: -1})
+	defer writer.Close()
+	require.NoError(t, writer.Write(context.Background(), "10.0.0.0", "10.0.1.0"))
+	require.NoError(t, writer.Write(context.Background(), "10.0.0.1", "10.0.1.1"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = writer.Write(ctx, "10.0.0.2", "10.0.1.2")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
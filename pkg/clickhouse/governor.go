@@ -0,0 +1,132 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"context"
+	"sync"
+)
+
+// QueryGovernor limits the number of heavy ClickHouse queries running at the
+// same time, so that analytics do not starve the flow ingestion. Requests
+// exceeding the limit are queued per client, and the clients are served in
+// turn, so that a client issuing many queries does not delay the others.
+// A nil QueryGovernor does not limit the queries.
+type QueryGovernor struct {
+	mutex   sync.Mutex
+	limit   int
+	running int
+	// queues are the waiting requests of each client, in arrival order.
+	queues map[string][]chan struct{}
+	// clients are the clients with waiting requests, in the order in which
+	// they are served.
+	clients []string
+}
+
+// NewQueryGovernor returns a QueryGovernor allowing up to maxConcurrentQueries
+// queries at the same time. 0 means no limit.
+func NewQueryGovernor(maxConcurrentQueries int) *QueryGovernor {
+	return &QueryGovernor{
+		limit:  maxConcurrentQueries,
+		queues: map[string][]chan struct{}{},
+	}
+}
+
+// Acquire waits until client can run a query, or until ctx is done. The
+// returned function must be called when the query completes.
+func (g *QueryGovernor) Acquire(ctx context.Context, client string) (func(), error) {
+	if g == nil || g.limit <= 0 {
+		return func() {}, nil
+	}
+	g.mutex.Lock()
+	if g.running < g.limit && len(g.clients) == 0 {
+		g.running++
+		g.mutex.Unlock()
+		return g.releaseFunc(), nil
+	}
+	ready := make(chan struct{})
+	if len(g.queues[client]) == 0 {
+		g.clients = append(g.clients, client)
+	}
+	g.queues[client] = append(g.queues[client], ready)
+	g.mutex.Unlock()
+
+	select {
+	case <-ready:
+		return g.releaseFunc(), nil
+	case <-ctx.Done():
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		select {
+		case <-ready:
+			// The query was allowed concurrently, let the next one run.
+			g.releaseLocked()
+		default:
+			g.dequeueLocked(client, ready)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (g *QueryGovernor) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+			g.releaseLocked()
+		})
+	}
+}
+
+// releaseLocked hands the slot of a completed query over to the first waiting
+// request of the next client, which then moves to the end of the clients.
+func (g *QueryGovernor) releaseLocked() {
+	if len(g.clients) == 0 {
+		g.running--
+		return
+	}
+	client := g.clients[0]
+	g.clients = g.clients[1:]
+	queue := g.queues[client]
+	close(queue[0])
+	if len(queue) > 1 {
+		g.queues[client] = queue[1:]
+		g.clients = append(g.clients, client)
+	} else {
+		delete(g.queues, client)
+	}
+}
+
+func (g *QueryGovernor) dequeueLocked(client string, ready chan struct{}) {
+	queue := g.queues[client]
+	for i := range queue {
+		if queue[i] == ready {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		g.queues[client] = queue
+		return
+	}
+	delete(g.queues, client)
+	for i := range g.clients {
+		if g.clients[i] == client {
+			g.clients = append(g.clients[:i], g.clients[i+1:]...)
+			break
+		}
+	}
+}
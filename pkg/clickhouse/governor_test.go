@@ -0,0 +1,103 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// waiting returns the number of queued requests.
+func (g *QueryGovernor) waiting() int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	count := 0
+	for _, queue := range g.queues {
+		count += len(queue)
+	}
+	return count
+}
+
+func TestQueryGovernorFairness(t *testing.T) {
+	governor := NewQueryGovernor(1)
+	release, err := governor.Acquire(context.Background(), "report")
+	require.NoError(t, err)
+
+	// Two more report queries are queued before a coverage query. The
+	// coverage query runs before the second report query.
+	order := make(chan string, 3)
+	for i, client := range []string{"report", "report", "coverage"} {
+		go func(client string) {
+			release, err := governor.Acquire(context.Background(), client)
+			if err != nil {
+				order <- err.Error()
+				return
+			}
+			order <- client
+			release()
+		}(client)
+		require.NoError(t, wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+			return governor.waiting() == i+1, nil
+		}))
+	}
+	release()
+	// Releasing twice has no effect.
+	release()
+	assert.Equal(t, "report", <-order)
+	assert.Equal(t, "coverage", <-order)
+	assert.Equal(t, "report", <-order)
+	assert.NoError(t, wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		governor.mutex.Lock()
+		defer governor.mutex.Unlock()
+		return governor.running == 0, nil
+	}))
+}
+
+func TestQueryGovernorCancel(t *testing.T) {
+	governor := NewQueryGovernor(1)
+	release, err := governor.Acquire(context.Background(), "report")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = governor.Acquire(ctx, "coverage")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, governor.waiting())
+	assert.Empty(t, governor.clients)
+
+	release()
+	release, err = governor.Acquire(context.Background(), "coverage")
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, 0, governor.running)
+}
+
+func TestQueryGovernorNoLimit(t *testing.T) {
+	var governor *QueryGovernor
+	release, err := governor.Acquire(context.Background(), "report")
+	require.NoError(t, err)
+	release()
+
+	governor = NewQueryGovernor(0)
+	for i := 0; i < 3; i++ {
+		_, err := governor.Acquire(context.Background(), "report")
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// unpartitionedPartitionID is the ID of the only partition of a table without
+// partition key. Tables created before the flow tables were partitioned by day
+// only have this partition, which is never dropped.
+const unpartitionedPartitionID = "all"
+
+// DeletionPlan describes how the rows matching a condition are deleted from a
+// table.
+type DeletionPlan struct {
+	// Partitions are the IDs of the partitions in which all the rows match the
+	// condition, and which can be dropped instead of being rewritten.
+	Partitions []string
+	// Mutate is true when some rows matching the condition are outside of the
+	// dropped partitions, and must be deleted with a mutation.
+	Mutate bool
+}
+
+// PlanDeletion plans the deletion of the rows of table matching condition,
+// preferring DROP PARTITION for the partitions in which all the rows match,
+// and falling back to a mutation for the remaining rows. The partition of the
+// current day is never dropped, as records may still be inserted into it.
+// When onCluster is true, table is the name of a local table in the current
+// database and all the shards are taken into account.
+func PlanDeletion(connect *sql.DB, table string, onCluster bool, condition string, args ...interface{}) (*DeletionPlan, error) {
+	source := table
+	if onCluster {
+		source = fmt.Sprintf("cluster('{cluster}', currentDatabase(), '%s')", table)
+	}
+	// #nosec G201: table names and conditions are not provided by users
+	query := fmt.Sprintf("SELECT _partition_id AS partition, countIf(%s) AS matched, partition != '%s' AND matched = count() AND max(timeInserted) < toStartOfDay(now()) AS droppable FROM %s GROUP BY partition ORDER BY partition",
+		condition, unpartitionedPartitionID, source)
+	rows, err := connect.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error when getting the partitions of table %s: %v", table, err)
+	}
+	defer rows.Close()
+	plan := &DeletionPlan{}
+	for rows.Next() {
+		var partition string
+		var matched uint64
+		var droppable bool
+		if err := rows.Scan(&partition, &matched, &droppable); err != nil {
+			return nil, fmt.Errorf("failed to parse the partitions of table %s: %v", table, err)
+		}
+		if droppable {
+			plan.Partitions = append(plan.Partitions, partition)
+		} else if matched > 0 {
+			plan.Mutate = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when getting the partitions of table %s: %v", table, err)
+	}
+	return plan, nil
+}
+
+// DropPartition drops a partition of table, on all the shards when onCluster
+// is true. Dropping a partition only removes its parts, unlike a mutation
+// which rewrites them.
+func DropPartition(connect *sql.DB, table string, onCluster bool, partition string) error {
+	query := "ALTER TABLE " + table
+	if onCluster {
+		query += " ON CLUSTER '{cluster}'"
+	}
+	query += " DROP PARTITION ID ?"
+	if _, err := connect.Exec(query, partition); err != nil {
+		return fmt.Errorf("error when dropping partition %s of table %s: %v", partition, table, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanDeletion(t *testing.T) {
+	testCases := []struct {
+		name         string
+		onCluster    bool
+		rows         [][]driver.Value
+		expectedFrom string
+		expectedPlan *DeletionPlan
+	}{
+		{
+			name:      "drop partitions",
+			onCluster: true,
+			rows: [][]driver.Value{
+				{"20230101", uint64(100), true},
+				{"20230102", uint64(100), true},
+				{"20230103", uint64(0), false},
+			},
+			expectedFrom: "FROM cluster('{cluster}', currentDatabase(), 'flows_local')",
+			expectedPlan: &DeletionPlan{Partitions: []string{"20230101", "20230102"}},
+		},
+		{
+			name:      "drop partitions and mutate",
+			onCluster: true,
+			rows: [][]driver.Value{
+				{"20230101", uint64(100), true},
+				{"20230102", uint64(40), false},
+			},
+			expectedFrom: "FROM cluster('{cluster}', currentDatabase(), 'flows_local')",
+			expectedPlan: &DeletionPlan{Partitions: []string{"20230101"}, Mutate: true},
+		},
+		{
+			name:         "unpartitioned table",
+			rows:         [][]driver.Value{{"all", uint64(40), false}},
+			expectedFrom: "FROM flows_local",
+			expectedPlan: &DeletionPlan{Mutate: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			rows := sqlmock.NewRows([]string{"partition", "matched", "droppable"})
+			for _, row := range tc.rows {
+				rows.AddRow(row...)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT _partition_id AS partition, countIf(timeInserted < ?) AS matched") + ".*" + regexp.QuoteMeta(tc.expectedFrom)).
+				WithArgs("2023-01-03 00:00:00").
+				WillReturnRows(rows)
+			plan, err := PlanDeletion(db, "flows_local", tc.onCluster, "timeInserted < ?", "2023-01-03 00:00:00")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPlan, plan)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDropPartition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE flows_local ON CLUSTER '{cluster}' DROP PARTITION ID ?")).
+		WithArgs("20230101").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE default.flows_local DROP PARTITION ID ?")).
+		WithArgs("20230102").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, DropPartition(db, "flows_local", true, "20230101"))
+	require.NoError(t, DropPartition(db, "default.flows_local", false, "20230102"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlowSchemaVersion is the version of the flows table schema expected by this
+// version of Theia. It must be incremented whenever the columns of the flows
+// table change, together with the version set by the ClickHouse init script.
+const FlowSchemaVersion = 1
+
+const (
+	// MetadataTable stores Theia metadata as key-value pairs. It is a
+	// distributed table, with one row for each key on each shard.
+	MetadataTable         = "theia_metadata"
+	FlowSchemaVersionKey  = "flowSchemaVersion"
+	flowsTable            = "flows"
+	unknownSchemaVersion  = 0
+	schemaVersionQuery    = "SELECT value FROM " + MetadataTable + " WHERE key = ? ORDER BY timeUpdated DESC LIMIT 1"
+	metadataTableQuery    = "SELECT count() FROM system.tables WHERE database = currentDatabase() AND name = ?"
+	flowTableColumnsQuery = "SELECT name FROM system.columns WHERE database = currentDatabase() AND table = ?"
+)
+
+// FlowColumns are the columns of the flows table in FlowSchemaVersion.
+var FlowColumns = []string{
+	"timeInserted",
+	"flowStartSeconds",
+	"flowEndSeconds",
+	"flowEndSecondsFromSourceNode",
+	"flowEndSecondsFromDestinationNode",
+	"flowEndReason",
+	"sourceIP",
+	"destinationIP",
+	"sourceTransportPort",
+	"destinationTransportPort",
+	"protocolIdentifier",
+	"packetTotalCount",
+	"octetTotalCount",
+	"packetDeltaCount",
+	"octetDeltaCount",
+	"reversePacketTotalCount",
+	"reverseOctetTotalCount",
+	"reversePacketDeltaCount",
+	"reverseOctetDeltaCount",
+	"sourcePodName",
+	"sourcePodNamespace",
+	"sourceNodeName",
+	"destinationPodName",
+	"destinationPodNamespace",
+	"destinationNodeName",
+	"destinationClusterIP",
+	"destinationServicePort",
+	"destinationServicePortName",
+	"ingressNetworkPolicyName",
+	"ingressNetworkPolicyNamespace",
+	"ingressNetworkPolicyRuleName",
+	"ingressNetworkPolicyRuleAction",
+	"ingressNetworkPolicyType",
+	"egressNetworkPolicyName",
+	"egressNetworkPolicyNamespace",
+	"egressNetworkPolicyRuleName",
+	"egressNetworkPolicyRuleAction",
+	"egressNetworkPolicyType",
+	"tcpState",
+	"flowType",
+	"sourcePodLabels",
+	"destinationPodLabels",
+	"throughput",
+	"reverseThroughput",
+	"throughputFromSourceNode",
+	"throughputFromDestinationNode",
+	"reverseThroughputFromSourceNode",
+	"reverseThroughputFromDestinationNode",
+	"clusterUUID",
+	"trusted",
+}
+
+// ErrIncompatibleFlowSchema is wrapped by the errors returned when the flows
+// table does not have the expected columns.
+var ErrIncompatibleFlowSchema = errors.New("incompatible flow schema")
+
+// FlowSchema describes the flows table deployed in ClickHouse.
+type FlowSchema struct {
+	// Version is 0 if it is not recorded in ClickHouse, i.e. if the flows
+	// table was created before flow schemas were versioned.
+	Version int
+	Columns map[string]bool
+}
+
+// GetFlowSchema returns the version and the columns of the flows table.
+func GetFlowSchema(db *sql.DB) (*FlowSchema, error) {
+	schema := &FlowSchema{Version: unknownSchemaVersion, Columns: make(map[string]bool)}
+	var tables int
+	if err := db.QueryRow(metadataTableQuery, MetadataTable).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("failed to check for the %s table: %v", MetadataTable, err)
+	}
+	if tables > 0 {
+		var version string
+		err := db.QueryRow(schemaVersionQuery, FlowSchemaVersionKey).Scan(&version)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to get the flow schema version: %v", err)
+		}
+		if err == nil {
+			schema.Version, err = strconv.Atoi(version)
+			if err != nil {
+				return nil, fmt.Errorf("invalid flow schema version %q: %v", version, err)
+			}
+		}
+	}
+	rows, err := db.Query(flowTableColumnsQuery, flowsTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the columns of the flows table: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to get the columns of the flows table: %v", err)
+		}
+		schema.Columns[column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get the columns of the flows table: %v", err)
+	}
+	return schema, nil
+}
+
+// MissingColumns returns the columns which are not in the flows table, sorted
+// by name.
+func (s *FlowSchema) MissingColumns(columns []string) []string {
+	var missing []string
+	for _, column := range columns {
+		if !s.Columns[column] {
+			missing = append(missing, column)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Check returns an error wrapping ErrIncompatibleFlowSchema if some of the
+// required columns are not in the flows table. Columns which are not required
+// can be filtered with AvailableColumns.
+func (s *FlowSchema) Check(required []string) error {
+	missing := s.MissingColumns(required)
+	if len(missing) == 0 {
+		return nil
+	}
+	hint := "ClickHouse may need to be upgraded"
+	if s.Version > FlowSchemaVersion {
+		hint = "theia may need to be upgraded"
+	}
+	return fmt.Errorf("%w: the flows table (schema version %d) is missing columns %s, this version of Theia expects schema version %d, %s",
+		ErrIncompatibleFlowSchema, s.Version, strings.Join(missing, ", "), FlowSchemaVersion, hint)
+}
+
+// AvailableColumns returns the columns which are in the flows table, in the
+// same order, so that queries can be adapted to older schemas.
+func (s *FlowSchema) AvailableColumns(columns []string) []string {
+	var available []string
+	for _, column := range columns {
+		if s.Columns[column] {
+			available = append(available, column)
+		}
+	}
+	return available
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Antrea Authors
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFlowSchema(t *testing.T) {
+	testCases := []struct {
+		name            string
+		metadataTables  int
+		version         *string
+		expectedVersion int
+		expectedErr     string
+	}{
+		{
+			name:            "no metadata table",
+			metadataTables:  0,
+			expectedVersion: 0,
+		},
+		{
+			name:            "no version",
+			metadataTables:  1,
+			expectedVersion: 0,
+		},
+		{
+			name:            "version",
+			metadataTables:  1,
+			version:         func() *string { v := "1"; return &v }(),
+			expectedVersion: 1,
+		},
+		{
+			name:           "invalid version",
+			metadataTables: 1,
+			version:        func() *string { v := "v1"; return &v }(),
+			expectedErr:    "invalid flow schema version \"v1\": strconv.Atoi: parsing \"v1\": invalid syntax",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta(metadataTableQuery)).WithArgs(MetadataTable).
+				WillReturnRows(sqlmock.NewRows([]string{"count()"}).AddRow(tt.metadataTables))
+			if tt.metadataTables > 0 {
+				rows := sqlmock.NewRows([]string{"value"})
+				if tt.version != nil {
+					rows.AddRow(*tt.version)
+				}
+				mock.ExpectQuery(regexp.QuoteMeta(schemaVersionQuery)).WithArgs(FlowSchemaVersionKey).WillReturnRows(rows)
+			}
+			if tt.expectedErr == "" {
+				mock.ExpectQuery(regexp.QuoteMeta(flowTableColumnsQuery)).WithArgs("flows").
+					WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("sourceIP").AddRow("destinationIP"))
+			}
+			schema, err := GetFlowSchema(db)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedVersion, schema.Version)
+				assert.Equal(t, map[string]bool{"sourceIP": true, "destinationIP": true}, schema.Columns)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFlowSchemaCheck(t *testing.T) {
+	schema := &FlowSchema{
+		Version: 0,
+		Columns: map[string]bool{"sourceIP": true, "destinationIP": true},
+	}
+	assert.NoError(t, schema.Check([]string{"sourceIP", "destinationIP"}))
+	err := schema.Check([]string{"sourceIP", "egressNetworkPolicyType", "clusterUUID"})
+	assert.True(t, errors.Is(err, ErrIncompatibleFlowSchema))
+	assert.EqualError(t, err, "incompatible flow schema: the flows table (schema version 0) is missing columns clusterUUID, egressNetworkPolicyType, this version of Theia expects schema version 1, ClickHouse may need to be upgraded")
+
+	schema.Version = FlowSchemaVersion + 1
+	err = schema.Check([]string{"clusterUUID"})
+	assert.Contains(t, err.Error(), "theia may need to be upgraded")
+
+	assert.Equal(t, []string{"destinationIP", "sourceIP"}, schema.AvailableColumns([]string{"destinationIP", "clusterUUID", "sourceIP"}))
+}
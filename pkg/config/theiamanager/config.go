@@ -17,6 +17,124 @@ package config
 type TheiaManagerConfig struct {
 	// apiServer contains APIServer related configuration options.
 	APIServer APIServerConfig `yaml:"apiServer,omitempty"`
+	// FaultInjection specifies the faults to inject, e.g.
+	// "spark-submission-failure=0.1". It is only used for testing and is
+	// ignored unless theia-manager is built with the "faultinjection" tag.
+	FaultInjection string `yaml:"faultInjection,omitempty"`
+	// JobQuota contains the per-user quotas of NetworkPolicyRecommendation
+	// jobs.
+	JobQuota JobQuotaConfig `yaml:"jobQuota,omitempty"`
+	// ClickHouse contains the configuration to connect to ClickHouse.
+	ClickHouse ClickHouseConfig `yaml:"clickHouse,omitempty"`
+	// FlowCoverage contains the configuration of the flow coverage
+	// computation.
+	FlowCoverage FlowCoverageConfig `yaml:"flowCoverage,omitempty"`
+	// JobEvents contains the configuration of the recording of the timeline
+	// of policy recommendation jobs.
+	JobEvents JobEventsConfig `yaml:"jobEvents,omitempty"`
+	// KafkaExport contains the configuration of the publication of the
+	// policy recommendation jobs events and results to Kafka.
+	KafkaExport KafkaExportConfig `yaml:"kafkaExport,omitempty"`
+	// Report contains the configuration of the periodic traffic reports.
+	Report ReportConfig `yaml:"report,omitempty"`
+}
+
+type ClickHouseConfig struct {
+	// DatabaseURL is the URL of the ClickHouse TCP endpoint. Defaults to
+	// tcp://clickhouse-clickhouse.flow-visibility.svc:9000. The credentials
+	// are read from the CH_USERNAME and CH_PASSWORD environment variables.
+	DatabaseURL string `yaml:"databaseURL,omitempty"`
+	// MaxConcurrentQueries is the maximum number of heavy queries, e.g. the
+	// flow coverage computation, which theia-manager runs in ClickHouse at
+	// the same time. The other queries wait for their turn, and the clients
+	// issuing them are served in turn. Defaults to 0, which means no limit.
+	MaxConcurrentQueries int `yaml:"maxConcurrentQueries,omitempty"`
+}
+
+type FlowCoverageConfig struct {
+	// Enable computes periodically, for each Namespace, the fraction of the
+	// flows matched by a network policy. Defaults to false.
+	Enable bool `yaml:"enable,omitempty"`
+	// Interval is the period over which the coverage is computed, and how
+	// often it is computed. Defaults to 1h.
+	Interval string `yaml:"interval,omitempty"`
+}
+
+type ReportConfig struct {
+	// Enable delivers periodically an HTML report of the traffic of the
+	// cluster to the sinks. Defaults to false.
+	Enable bool `yaml:"enable,omitempty"`
+	// Interval is the period covered by each report, and how often reports
+	// are delivered. Periods are aligned on Monday 00:00 UTC, e.g. weekly
+	// reports cover Monday to Sunday. Defaults to 168h.
+	Interval string `yaml:"interval,omitempty"`
+	// Sinks are the URIs of the sinks the reports are delivered to, with
+	// the same schemes as the sinks of the policy recommendation jobs, e.g.
+	// s3://<bucket>/<prefix> or https://<host>/<path>.
+	Sinks []string `yaml:"sinks,omitempty"`
+	// TemplateFile is the path of the html/template rendering the reports.
+	// Defaults to the built-in template.
+	TemplateFile string `yaml:"templateFile,omitempty"`
+	// TopTalkers is the number of top talkers listed in the reports.
+	// Defaults to 10.
+	TopTalkers int `yaml:"topTalkers,omitempty"`
+}
+
+type JobEventsConfig struct {
+	// Enable records the state transitions of the policy recommendation
+	// jobs, and the Kubernetes events of their objects, in ClickHouse.
+	// Defaults to false.
+	Enable bool `yaml:"enable,omitempty"`
+	// Interval is how often the jobs and their events are polled. Defaults
+	// to 30s.
+	Interval string `yaml:"interval,omitempty"`
+}
+
+type KafkaExportConfig struct {
+	// Enable publishes the state transitions of the policy recommendation
+	// jobs, and the policies recommended by the completed jobs, to Kafka.
+	// Defaults to false.
+	Enable bool `yaml:"enable,omitempty"`
+	// Brokers are the addresses of the Kafka brokers, e.g. kafka:9092.
+	Brokers []string `yaml:"brokers,omitempty"`
+	// JobEventsTopic is the topic of the state transitions of the jobs.
+	// Defaults to theia-job-events. Set it to "-" to not publish them.
+	JobEventsTopic string `yaml:"jobEventsTopic,omitempty"`
+	// RecommendationsTopic is the topic of the recommended policies, one
+	// message per policy. Defaults to theia-recommendations. Set it to "-"
+	// to not publish them.
+	RecommendationsTopic string `yaml:"recommendationsTopic,omitempty"`
+	// TLS contains the TLS configuration of the connection to the brokers.
+	TLS KafkaTLSConfig `yaml:"tls,omitempty"`
+	// SASLMechanism is the SASL mechanism used to authenticate with the
+	// brokers, one of PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512. The
+	// credentials are read from the KAFKA_USERNAME and KAFKA_PASSWORD
+	// environment variables. Defaults to no SASL authentication.
+	SASLMechanism string `yaml:"saslMechanism,omitempty"`
+}
+
+type KafkaTLSConfig struct {
+	// Enable connects to the brokers over TLS. Defaults to false.
+	Enable bool `yaml:"enable,omitempty"`
+	// CAFile is the path of the CA certificate used to verify the brokers.
+	// Defaults to the system CAs.
+	CAFile string `yaml:"caFile,omitempty"`
+	// CertFile and KeyFile are the paths of the client certificate and key,
+	// for mutual TLS.
+	CertFile string `yaml:"certFile,omitempty"`
+	KeyFile  string `yaml:"keyFile,omitempty"`
+	// InsecureSkipVerify skips the verification of the certificates of the
+	// brokers. Defaults to false.
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify,omitempty"`
+}
+
+type JobQuotaConfig struct {
+	// MaxConcurrentJobsPerUser is the maximum number of jobs of a user which
+	// can be active at the same time. Defaults to 0, which means no limit.
+	MaxConcurrentJobsPerUser int `yaml:"maxConcurrentJobsPerUser,omitempty"`
+	// MaxDailyJobsPerUser is the maximum number of jobs a user can submit
+	// over the last 24 hours. Defaults to 0, which means no limit.
+	MaxDailyJobsPerUser int `yaml:"maxDailyJobsPerUser,omitempty"`
 }
 
 type APIServerConfig struct {
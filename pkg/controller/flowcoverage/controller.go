@@ -0,0 +1,209 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flowcoverage computes the flow coverage of each Namespace, i.e. the
+// fraction of the flows of the Namespace which were matched by a network
+// policy, so that teams can set and track isolation objectives. The coverage is
+// computed periodically from the flows table, stored in ClickHouse to track it
+// over time, and exposed as Prometheus metrics.
+package flowcoverage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/clickhouse"
+)
+
+const (
+	controllerName = "FlowCoverageController"
+	// queryClient identifies the coverage queries in the QueryGovernor.
+	queryClient = "flow-coverage"
+	// DefaultInterval is the default period over which the coverage is
+	// computed.
+	DefaultInterval = time.Hour
+)
+
+// A flow is counted once for its source Namespace, where it is covered if an
+// egress rule matched it, and once for its destination Namespace, where it is
+// covered if an ingress rule matched it.
+const coverageQuery = `
+SELECT
+	namespace,
+	sum(flows),
+	sum(coveredFlows)
+FROM (
+	SELECT
+		sourcePodNamespace AS namespace,
+		count() AS flows,
+		countIf(egressNetworkPolicyName != '') AS coveredFlows
+	FROM flows
+	WHERE timeInserted >= ? AND timeInserted < ? AND sourcePodNamespace != ''
+	GROUP BY namespace
+	UNION ALL
+	SELECT
+		destinationPodNamespace AS namespace,
+		count() AS flows,
+		countIf(ingressNetworkPolicyName != '') AS coveredFlows
+	FROM flows
+	WHERE timeInserted >= ? AND timeInserted < ? AND destinationPodNamespace != ''
+	GROUP BY namespace
+)
+GROUP BY namespace
+ORDER BY namespace`
+
+const insertQuery = "INSERT INTO flow_coverage (timeCreated, namespace, flows, coveredFlows) VALUES (?, ?, ?, ?)"
+
+// NamespaceCoverage is the flow coverage of a Namespace over an interval.
+type NamespaceCoverage struct {
+	Namespace    string
+	Flows        uint64
+	CoveredFlows uint64
+}
+
+// Ratio returns the fraction of the flows which were covered, or 0 if there
+// was no flow.
+func (c NamespaceCoverage) Ratio() float64 {
+	if c.Flows == 0 {
+		return 0
+	}
+	return float64(c.CoveredFlows) / float64(c.Flows)
+}
+
+type FlowCoverageController struct {
+	db       *sql.DB
+	governor *clickhouse.QueryGovernor
+	interval time.Duration
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewFlowCoverageController returns a controller computing the coverage over
+// the flows inserted during each interval. The coverage queries wait for their
+// turn in governor, which may be nil.
+func NewFlowCoverageController(db *sql.DB, governor *clickhouse.QueryGovernor, interval time.Duration) *FlowCoverageController {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &FlowCoverageController{
+		db:       db,
+		governor: governor,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Run computes the coverage every interval until stopCh is closed.
+func (c *FlowCoverageController) Run(stopCh <-chan struct{}) {
+	klog.InfoS("Starting controller", "name", controllerName, "interval", c.interval)
+	defer klog.InfoS("Shutting down controller", "name", controllerName)
+	wait.Until(func() {
+		if err := c.sync(); err != nil {
+			klog.ErrorS(err, "Error when computing the flow coverage")
+		}
+	}, c.interval, stopCh)
+}
+
+// sync computes the coverage of the last interval, records it in ClickHouse
+// and updates the metrics.
+func (c *FlowCoverageController) sync() error {
+	end := c.now().UTC().Truncate(time.Second)
+	start := end.Add(-c.interval)
+	coverages, err := c.computeCoverage(start, end)
+	if err != nil {
+		return err
+	}
+	if err := c.recordCoverage(end, coverages); err != nil {
+		return err
+	}
+	// Namespaces without flows during the interval are removed from the
+	// metrics instead of keeping a stale value.
+	flowCoverageRatio.Reset()
+	flowCoverageFlows.Reset()
+	for _, coverage := range coverages {
+		flowCoverageRatio.WithLabelValues(coverage.Namespace).Set(coverage.Ratio())
+		flowCoverageFlows.WithLabelValues(coverage.Namespace).Set(float64(coverage.Flows))
+	}
+	flowCoverageLastSuccess.Set(float64(end.Unix()))
+	klog.V(2).InfoS("Computed the flow coverage", "namespaces", len(coverages), "start", start, "end", end)
+	return nil
+}
+
+func (c *FlowCoverageController) computeCoverage(start, end time.Time) ([]NamespaceCoverage, error) {
+	// The coverage is not computed if it would not complete before the next
+	// interval.
+	ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+	defer cancel()
+	release, err := c.governor.Acquire(ctx, queryClient)
+	if err != nil {
+		return nil, fmt.Errorf("error when waiting to query the flow coverage: %v", err)
+	}
+	defer release()
+	return QueryCoverage(ctx, c.db, start, end)
+}
+
+// QueryCoverage returns the coverage of each Namespace over the flows inserted
+// between start and end, ordered by Namespace.
+func QueryCoverage(ctx context.Context, db *sql.DB, start, end time.Time) ([]NamespaceCoverage, error) {
+	rows, err := db.QueryContext(ctx, coverageQuery, start, end, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("error when querying the flow coverage: %v", err)
+	}
+	defer rows.Close()
+	var coverages []NamespaceCoverage
+	for rows.Next() {
+		var coverage NamespaceCoverage
+		if err := rows.Scan(&coverage.Namespace, &coverage.Flows, &coverage.CoveredFlows); err != nil {
+			return nil, fmt.Errorf("error when scanning the flow coverage: %v", err)
+		}
+		coverages = append(coverages, coverage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when querying the flow coverage: %v", err)
+	}
+	return coverages, nil
+}
+
+// recordCoverage inserts the coverages in the flow_coverage table. With the
+// ClickHouse driver, the rows of a transaction are inserted as one block.
+func (c *FlowCoverageController) recordCoverage(timeCreated time.Time, coverages []NamespaceCoverage) error {
+	if len(coverages) == 0 {
+		return nil
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error when recording the flow coverage: %v", err)
+	}
+	stmt, err := tx.Prepare(insertQuery)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error when recording the flow coverage: %v", err)
+	}
+	defer stmt.Close()
+	for _, coverage := range coverages {
+		if _, err := stmt.Exec(timeCreated, coverage.Namespace, coverage.Flows, coverage.CoveredFlows); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error when recording the flow coverage: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error when recording the flow coverage: %v", err)
+	}
+	return nil
+}
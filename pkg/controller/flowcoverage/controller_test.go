@@ -0,0 +1,88 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowcoverage
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestNamespaceCoverageRatio(t *testing.T) {
+	assert.Equal(t, 0.0, NamespaceCoverage{Namespace: "idle"}.Ratio())
+	assert.Equal(t, 0.25, NamespaceCoverage{Namespace: "app", Flows: 8, CoveredFlows: 2}.Ratio())
+}
+
+func TestSync(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	registry := metrics.NewKubeRegistry()
+	registry.MustRegister(flowCoverageRatio, flowCoverageFlows, flowCoverageLastSuccess)
+	defer func() {
+		flowCoverageRatio.Reset()
+		flowCoverageFlows.Reset()
+	}()
+
+	end := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	start := end.Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM flows")).
+		WithArgs(start, end, start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"namespace", "flows", "coveredFlows"}).
+			AddRow("app-a", uint64(10), uint64(10)).
+			AddRow("app-b", uint64(4), uint64(1)))
+	mock.ExpectBegin()
+	insert := mock.ExpectPrepare(regexp.QuoteMeta(insertQuery))
+	insert.ExpectExec().WithArgs(end, "app-a", uint64(10), uint64(10)).WillReturnResult(sqlmock.NewResult(0, 1))
+	insert.ExpectExec().WithArgs(end, "app-b", uint64(4), uint64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	c := NewFlowCoverageController(db, nil, 0)
+	c.now = func() time.Time { return end.Add(300 * time.Millisecond) }
+	require.NoError(t, c.sync())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	expected := `
+# HELP theia_flow_coverage_flows [ALPHA] Number of flows of the Namespace during the last interval.
+# TYPE theia_flow_coverage_flows gauge
+theia_flow_coverage_flows{namespace="app-a"} 10
+theia_flow_coverage_flows{namespace="app-b"} 4
+# HELP theia_flow_coverage_ratio [ALPHA] Fraction of the flows of the Namespace which were matched by a network policy during the last interval.
+# TYPE theia_flow_coverage_ratio gauge
+theia_flow_coverage_ratio{namespace="app-a"} 1
+theia_flow_coverage_ratio{namespace="app-b"} 0.25
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "theia_flow_coverage_flows", "theia_flow_coverage_ratio"))
+}
+
+func TestSyncQueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM flows")).WillReturnError(assert.AnError)
+	c := NewFlowCoverageController(db, nil, time.Hour)
+	err = c.sync()
+	assert.ErrorContains(t, err, "error when querying the flow coverage")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowcoverage
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	// flowCoverageRatio is the fraction of the flows of each Namespace which
+	// were matched by a network policy during the last interval.
+	flowCoverageRatio = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "theia",
+			Subsystem:      "flow_coverage",
+			Name:           "ratio",
+			Help:           "Fraction of the flows of the Namespace which were matched by a network policy during the last interval.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace"},
+	)
+	// flowCoverageFlows is the number of flows of each Namespace during the
+	// last interval.
+	flowCoverageFlows = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "theia",
+			Subsystem:      "flow_coverage",
+			Name:           "flows",
+			Help:           "Number of flows of the Namespace during the last interval.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace"},
+	)
+	// flowCoverageLastSuccess is the time of the last successful computation
+	// of the flow coverage, to alert when the metrics are stale.
+	flowCoverageLastSuccess = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      "theia",
+			Subsystem:      "flow_coverage",
+			Name:           "last_success_timestamp_seconds",
+			Help:           "Unix time of the last successful computation of the flow coverage.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+)
+
+var registerMetricsOnce sync.Once
+
+// InitializeMetrics registers the flow coverage metrics, which are then
+// exposed by the /metrics endpoint of the theia-manager API server.
+func InitializeMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(flowCoverageRatio, flowCoverageFlows, flowCoverageLastSuccess)
+	})
+}
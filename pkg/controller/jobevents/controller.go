@@ -0,0 +1,253 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jobevents records the timeline of the policy recommendation jobs in
+// ClickHouse: the state transitions and error messages reported by their
+// backend, and the Kubernetes events of their SparkApplications, Jobs and
+// Pods. Kubernetes events expire after one hour by default, and the Pods of
+// failed jobs may be gone, so the timeline is the only way to find out why a
+// job failed afterwards.
+package jobevents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/util/env"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+const (
+	controllerName = "JobEventsController"
+	// DefaultInterval is the default period at which the jobs and their
+	// events are polled.
+	DefaultInterval = 30 * time.Second
+	// StateEventType is the type of the events recording the state
+	// transitions of the jobs. The other events have the type of the
+	// Kubernetes events, Normal or Warning.
+	StateEventType = "State"
+)
+
+const (
+	selectQuery = "SELECT timeCreated, type, reason, message FROM recommendation_events WHERE id = ?"
+	insertQuery = "INSERT INTO recommendation_events (id, timeCreated, type, reason, message) VALUES (?, ?, ?, ?, ?)"
+)
+
+// Event is an event of the timeline of a policy recommendation job.
+type Event struct {
+	ID          string
+	TimeCreated time.Time
+	Type        string
+	// Reason is the state of the job for state transitions, and the reason
+	// of the Kubernetes event otherwise.
+	Reason  string
+	Message string
+}
+
+// key identifies an event, so that it is only recorded once. The state
+// transitions are recorded when they are observed, so their time is not part
+// of their key.
+func (e Event) key() string {
+	if e.Type == StateEventType {
+		return strings.Join([]string{e.Type, e.Reason, e.Message}, "/")
+	}
+	return strings.Join([]string{e.Type, e.Reason, e.Message, e.TimeCreated.UTC().Format(time.RFC3339)}, "/")
+}
+
+type JobEventsController struct {
+	db         *sql.DB
+	kubeClient kubernetes.Interface
+	// namespace is the Namespace of the jobs and of their events, in which
+	// Theia is running.
+	namespace string
+	executors []executor.Executor
+	interval  time.Duration
+	// recorded holds the keys of the recorded events of each job. The
+	// events of a job are loaded from ClickHouse the first time the job is
+	// seen, so that restarting theia-manager does not record them again.
+	recorded map[string]map[string]bool
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewJobEventsController returns a controller recording the timeline of the
+// jobs of the given executors every interval.
+func NewJobEventsController(db *sql.DB, kubeClient kubernetes.Interface, executors []executor.Executor, interval time.Duration) *JobEventsController {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &JobEventsController{
+		db:         db,
+		kubeClient: kubeClient,
+		namespace:  env.GetTheiaNamespace(),
+		executors:  executors,
+		interval:   interval,
+		recorded:   make(map[string]map[string]bool),
+		now:        time.Now,
+	}
+}
+
+// Run records the timeline of the jobs every interval until stopCh is closed.
+func (c *JobEventsController) Run(stopCh <-chan struct{}) {
+	klog.InfoS("Starting controller", "name", controllerName, "interval", c.interval)
+	defer klog.InfoS("Shutting down controller", "name", controllerName)
+	wait.Until(func() {
+		if err := c.sync(); err != nil {
+			klog.ErrorS(err, "Error when recording the events of policy recommendation jobs")
+		}
+	}, c.interval, stopCh)
+}
+
+// sync records the current state of each job, and the Kubernetes events of
+// its objects, which were not recorded yet.
+func (c *JobEventsController) sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+	defer cancel()
+	jobs, err := executor.ListJobs(ctx, c.executors)
+	if err != nil {
+		return fmt.Errorf("error when listing policy recommendation jobs: %v", err)
+	}
+	events := make(map[string][]Event, len(jobs))
+	now := c.now().UTC().Truncate(time.Second)
+	for _, job := range jobs {
+		state := job.State
+		if state == "" {
+			state = "NEW"
+		}
+		events[job.ID] = append(events[job.ID], Event{ID: job.ID, TimeCreated: now, Type: StateEventType, Reason: state, Message: job.ErrorMessage})
+	}
+	eventList, err := c.kubeClient.CoreV1().Events(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error when listing events: %v", err)
+	}
+	for i := range eventList.Items {
+		event := &eventList.Items[i]
+		id := jobID(event.InvolvedObject.Name)
+		if _, ok := events[id]; !ok {
+			continue
+		}
+		events[id] = append(events[id], Event{ID: id, TimeCreated: eventTime(event), Type: event.Type, Reason: event.Reason, Message: event.Message})
+	}
+
+	var newEvents []Event
+	for id, jobEvents := range events {
+		recorded, err := c.recordedEvents(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, event := range jobEvents {
+			if !recorded[event.key()] {
+				newEvents = append(newEvents, event)
+			}
+		}
+	}
+	if err := c.recordEvents(newEvents); err != nil {
+		return err
+	}
+	for _, event := range newEvents {
+		c.recorded[event.ID][event.key()] = true
+	}
+	// The jobs which were deleted are forgotten.
+	for id := range c.recorded {
+		if _, ok := events[id]; !ok {
+			delete(c.recorded, id)
+		}
+	}
+	klog.V(4).InfoS("Recorded the events of policy recommendation jobs", "jobs", len(jobs), "events", len(newEvents))
+	return nil
+}
+
+// recordedEvents returns the keys of the recorded events of a job.
+func (c *JobEventsController) recordedEvents(ctx context.Context, id string) (map[string]bool, error) {
+	if recorded, ok := c.recorded[id]; ok {
+		return recorded, nil
+	}
+	rows, err := c.db.QueryContext(ctx, selectQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("error when querying the events of job %s: %v", id, err)
+	}
+	defer rows.Close()
+	recorded := make(map[string]bool)
+	for rows.Next() {
+		event := Event{ID: id}
+		if err := rows.Scan(&event.TimeCreated, &event.Type, &event.Reason, &event.Message); err != nil {
+			return nil, fmt.Errorf("error when scanning the events of job %s: %v", id, err)
+		}
+		recorded[event.key()] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when querying the events of job %s: %v", id, err)
+	}
+	c.recorded[id] = recorded
+	return recorded, nil
+}
+
+// recordEvents inserts the events in the recommendation_events table. With the
+// ClickHouse driver, the rows of a transaction are inserted as one block.
+func (c *JobEventsController) recordEvents(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error when recording the events of policy recommendation jobs: %v", err)
+	}
+	stmt, err := tx.Prepare(insertQuery)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error when recording the events of policy recommendation jobs: %v", err)
+	}
+	defer stmt.Close()
+	for _, event := range events {
+		if _, err := stmt.Exec(event.ID, event.TimeCreated, event.Type, event.Reason, event.Message); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error when recording the events of policy recommendation jobs: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error when recording the events of policy recommendation jobs: %v", err)
+	}
+	return nil
+}
+
+// jobID returns the ID of the job of an object, e.g. the SparkApplication
+// pr-<ID>, or its driver Pod pr-<ID>-driver.
+func jobID(name string) string {
+	const idLength = 36
+	if !strings.HasPrefix(name, "pr-") || len(name) < len("pr-")+idLength {
+		return ""
+	}
+	return name[len("pr-") : len("pr-")+idLength]
+}
+
+// eventTime returns the time at which a Kubernetes event first occurred.
+func eventTime(event *v1.Event) time.Time {
+	switch {
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.UTC().Truncate(time.Second)
+	case !event.EventTime.IsZero():
+		return event.EventTime.UTC().Truncate(time.Second)
+	default:
+		return event.CreationTimestamp.UTC().Truncate(time.Second)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobevents
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/util/executor"
+)
+
+const (
+	testID = "e998433e-accb-4888-9fc8-06563f073e86"
+	// testNamespace is a custom Namespace in which Theia is installed.
+	testNamespace = "theia"
+)
+
+func TestJobID(t *testing.T) {
+	assert.Equal(t, testID, jobID("pr-"+testID))
+	assert.Equal(t, testID, jobID("pr-"+testID+"-driver"))
+	assert.Equal(t, "", jobID("pr-1234"))
+	assert.Equal(t, "", jobID("clickhouse-0"))
+}
+
+func TestSync(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-" + testID,
+			Namespace: testNamespace,
+			Labels:    map[string]string{executor.RecommendationIDLabel: testID},
+		},
+		Status: batchv1.JobStatus{Active: 1},
+	}
+	podEventTime := time.Date(2022, 8, 1, 11, 59, 0, 0, time.UTC)
+	podEvent := &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "pr-" + testID + "-x7v2k.1", Namespace: testNamespace},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "pr-" + testID + "-x7v2k"},
+		Type:           v1.EventTypeWarning,
+		Reason:         "Failed",
+		Message:        "Error: ErrImagePull",
+		FirstTimestamp: metav1.NewTime(podEventTime),
+	}
+	otherEvent := &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "clickhouse-0.1", Namespace: testNamespace},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "clickhouse-0"},
+		Type:           v1.EventTypeNormal,
+		Reason:         "Started",
+	}
+	kubeClient := fake.NewSimpleClientset(job, podEvent, otherEvent)
+	jobExecutor, err := executor.New(executor.K8sJobBackend, executor.Options{Clientset: kubeClient, Namespace: testNamespace})
+	require.NoError(t, err)
+	t.Setenv("POD_NAMESPACE", testNamespace)
+	c := NewJobEventsController(db, kubeClient, []executor.Executor{jobExecutor}, 0)
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// The NEW state was recorded before theia-manager restarted.
+	mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(testID).
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated", "type", "reason", "message"}).
+			AddRow(now.Add(-time.Hour), StateEventType, "NEW", ""))
+	mock.ExpectBegin()
+	insert := mock.ExpectPrepare(regexp.QuoteMeta(insertQuery))
+	insert.ExpectExec().WithArgs(testID, now, StateEventType, "RUNNING", "").WillReturnResult(sqlmock.NewResult(0, 1))
+	insert.ExpectExec().WithArgs(testID, podEventTime, v1.EventTypeWarning, "Failed", "Error: ErrImagePull").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, c.sync())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Only the state transition to FAILED is recorded on the next sync.
+	job.Status = batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Message: "Job has reached the specified backoff limit"}}}
+	_, err = kubeClient.BatchV1().Jobs(testNamespace).UpdateStatus(context.TODO(), job, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	mock.ExpectBegin()
+	insert = mock.ExpectPrepare(regexp.QuoteMeta(insertQuery))
+	insert.ExpectExec().WithArgs(testID, now, StateEventType, "FAILED", "Job has reached the specified backoff limit").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, c.sync())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The deleted jobs are forgotten.
+	require.NoError(t, kubeClient.BatchV1().Jobs(testNamespace).Delete(context.TODO(), job.Name, metav1.DeleteOptions{}))
+	require.NoError(t, c.sync())
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, c.recorded)
+}
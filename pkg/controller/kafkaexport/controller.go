@@ -0,0 +1,329 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafkaexport publishes the output of Theia to Kafka, so that SIEM
+// pipelines can consume it: the state transitions of the policy
+// recommendation jobs, and the policies recommended by the completed jobs.
+// Messages are keyed by job ID, so that the messages of a job are published to
+// the same partition, in order: when publishing fails, the messages are
+// retried with backoff before the next messages are published. The messages are published at least once:
+// they may be published again when theia-manager is restarted while they are
+// being published.
+package kafkaexport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	intelligence "antrea.io/theia/pkg/apis/intelligence/v1alpha1"
+	crdv1a1informers "antrea.io/theia/pkg/client/informers/externalversions/crd/v1alpha1"
+	"antrea.io/theia/pkg/util/kafka"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+)
+
+const (
+	controllerName = "KafkaExportController"
+	// Set resyncPeriod to 0 to disable resyncing.
+	resyncPeriod time.Duration = 0
+	// How long to wait before retrying to publish messages. The delay is
+	// doubled after each failure.
+	minRetryDelay  = 5 * time.Second
+	maxRetryDelay  = 300 * time.Second
+	publishTimeout = 30 * time.Second
+
+	DefaultJobEventsTopic       = "theia-job-events"
+	DefaultRecommendationsTopic = "theia-recommendations"
+)
+
+const (
+	// JobStateChangedKind is the kind of the job events published when a
+	// job changes state.
+	JobStateChangedKind = "JobStateChanged"
+	// JobDeletedKind is the kind of the job events published when a job is
+	// deleted.
+	JobDeletedKind = "JobDeleted"
+)
+
+// Topics are the topics to which the messages are published. Messages are not
+// published to empty topics.
+type Topics struct {
+	JobEvents       string
+	Recommendations string
+}
+
+// JobEvent is the message published to the job events topic.
+type JobEvent struct {
+	Kind          string    `json:"kind"`
+	ID            string    `json:"id"`
+	Namespace     string    `json:"namespace"`
+	Name          string    `json:"name"`
+	State         string    `json:"state,omitempty"`
+	PreviousState string    `json:"previousState,omitempty"`
+	ErrorCode     string    `json:"errorCode,omitempty"`
+	ErrorMessage  string    `json:"errorMessage,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// Recommendation is the message published to the recommendations topic for
+// each policy recommended by a job.
+type Recommendation struct {
+	ID                 string    `json:"id"`
+	Namespace          string    `json:"namespace"`
+	Name               string    `json:"name"`
+	RecommendationType string    `json:"recommendationType,omitempty"`
+	PolicyType         string    `json:"policyType,omitempty"`
+	Time               time.Time `json:"time"`
+	// Policy is the recommended policy, or ClusterGroup, as JSON.
+	Policy json.RawMessage `json:"policy"`
+}
+
+// item is an item of the queue: a job event, or the recommendations of a
+// job to read from ClickHouse when recommendations is true.
+type item struct {
+	event           JobEvent
+	recommendations bool
+	// recommendationType and policyType are copied from the spec of the
+	// job to the recommendations.
+	recommendationType string
+	policyType         string
+}
+
+type KafkaExportController struct {
+	publisher kafka.Publisher
+	topics    Topics
+	// db is used to read the results of the completed jobs.
+	db *sql.DB
+
+	npRecommendationSynced cache.InformerSynced
+	queue                  workqueue.Interface
+	// retryLimiter returns the delays before retrying to publish an item.
+	retryLimiter workqueue.RateLimiter
+	// now and after are overridden in tests.
+	now   func() time.Time
+	after func(d time.Duration) <-chan time.Time
+}
+
+// NewKafkaExportController returns a controller publishing the events of the
+// NetworkPolicyRecommendations of the informer, and their results read from
+// db, to the given topics.
+func NewKafkaExportController(publisher kafka.Publisher, topics Topics, db *sql.DB, npRecommendationInformer crdv1a1informers.NetworkPolicyRecommendationInformer) *KafkaExportController {
+	c := &KafkaExportController{
+		publisher:              publisher,
+		topics:                 topics,
+		db:                     db,
+		npRecommendationSynced: npRecommendationInformer.Informer().HasSynced,
+		queue:                  workqueue.NewNamed("kafkaExport"),
+		retryLimiter:           workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay),
+		now:                    time.Now,
+		after:                  time.After,
+	}
+	// The existing jobs are not published when the informer lists them,
+	// so that restarting theia-manager does not publish their state again.
+	npRecommendationInformer.Informer().AddEventHandlerWithResyncPeriod(
+		cache.ResourceEventHandlerFuncs{
+			UpdateFunc: c.updateNPRecommendation,
+			DeleteFunc: c.deleteNPRecommendation,
+		},
+		resyncPeriod,
+	)
+	return c
+}
+
+func (c *KafkaExportController) jobEvent(kind string, npReco *crdv1alpha1.NetworkPolicyRecommendation) JobEvent {
+	return JobEvent{
+		Kind:         kind,
+		ID:           string(npReco.UID),
+		Namespace:    npReco.Namespace,
+		Name:         npReco.Name,
+		State:        npReco.Status.State,
+		ErrorCode:    npReco.Status.ErrorCode,
+		ErrorMessage: npReco.Status.ErrorMsg,
+		Time:         c.now().UTC(),
+	}
+}
+
+func (c *KafkaExportController) updateNPRecommendation(old, cur interface{}) {
+	oldNPReco, _ := old.(*crdv1alpha1.NetworkPolicyRecommendation)
+	npReco, _ := cur.(*crdv1alpha1.NetworkPolicyRecommendation)
+	if oldNPReco.Status.State == npReco.Status.State {
+		return
+	}
+	event := c.jobEvent(JobStateChangedKind, npReco)
+	event.PreviousState = oldNPReco.Status.State
+	if c.topics.JobEvents != "" {
+		c.queue.Add(item{event: event})
+	}
+	if npReco.Status.State == intelligence.NPRecommendationStateCompleted && c.topics.Recommendations != "" {
+		c.queue.Add(item{
+			event:              event,
+			recommendations:    true,
+			recommendationType: npReco.Spec.Type,
+			policyType:         npReco.Spec.PolicyType,
+		})
+	}
+}
+
+func (c *KafkaExportController) deleteNPRecommendation(old interface{}) {
+	npReco, ok := old.(*crdv1alpha1.NetworkPolicyRecommendation)
+	if !ok {
+		tombstone, ok := old.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Error decoding object when deleting NP Recommendation, invalid type: %v", old)
+			return
+		}
+		npReco, ok = tombstone.Obj.(*crdv1alpha1.NetworkPolicyRecommendation)
+		if !ok {
+			klog.Errorf("Error decoding object tombstone when deleting NP Recommendation, invalid type: %v", tombstone.Obj)
+			return
+		}
+	}
+	if c.topics.JobEvents != "" {
+		c.queue.Add(item{event: c.jobEvent(JobDeletedKind, npReco)})
+	}
+}
+
+// Run publishes the queued messages until stopCh is closed.
+func (c *KafkaExportController) Run(stopCh <-chan struct{}) {
+	defer c.queue.ShutDown()
+
+	klog.InfoS("Starting controller", "name", controllerName)
+	defer klog.InfoS("Shutting down controller", "name", controllerName)
+
+	if !cache.WaitForNamedCacheSync(controllerName, stopCh, c.npRecommendationSynced) {
+		return
+	}
+	// The messages are published by a single worker, so that the messages
+	// of a job are published in order.
+	go wait.Until(func() { c.worker(stopCh) }, time.Second, stopCh)
+	<-stopCh
+}
+
+func (c *KafkaExportController) worker(stopCh <-chan struct{}) {
+	for c.processNextWorkItem(stopCh) {
+	}
+}
+
+// processNextWorkItem publishes the next item of the queue. Failures are
+// retried in place, rather than by requeuing the item, so that the later
+// items of the same job are not published before it. It returns false when
+// the queue is shut down, or when stopCh is closed while waiting to retry.
+func (c *KafkaExportController) processNextWorkItem(stopCh <-chan struct{}) bool {
+	obj, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(obj)
+	defer c.retryLimiter.Forget(obj)
+	i, ok := obj.(item)
+	if !ok {
+		klog.Errorf("Expected Kafka export item in work queue but got %#v", obj)
+		return true
+	}
+	for {
+		err := c.publish(i)
+		if err == nil {
+			return true
+		}
+		delay := c.retryLimiter.When(obj)
+		klog.ErrorS(err, "Error when publishing to Kafka, retrying", "id", i.event.ID, "delay", delay)
+		select {
+		case <-stopCh:
+			return false
+		case <-c.after(delay):
+		}
+	}
+}
+
+// publish publishes the job event or the recommendations of an item.
+func (c *KafkaExportController) publish(i item) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if !i.recommendations {
+		value, err := json.Marshal(i.event)
+		if err != nil {
+			return err
+		}
+		return c.publisher.Publish(ctx, c.topics.JobEvents, kafka.Message{Key: []byte(i.event.ID), Value: value})
+	}
+	messages, err := c.recommendationMessages(ctx, i)
+	if err != nil {
+		return err
+	}
+	if err := c.publisher.Publish(ctx, c.topics.Recommendations, messages...); err != nil {
+		return err
+	}
+	klog.V(2).InfoS("Published the recommended policies to Kafka", "id", i.event.ID, "policies", len(messages))
+	return nil
+}
+
+// recommendationMessages reads the result of a completed job from ClickHouse,
+// and returns a message for each recommended policy.
+func (c *KafkaExportController) recommendationMessages(ctx context.Context, i item) ([]kafka.Message, error) {
+	if c.db == nil {
+		return nil, fmt.Errorf("the result cannot be read because the connection to ClickHouse is not configured")
+	}
+	yamls, err := policyrecommendation.QueryResult(ctx, c.db, i.event.ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recommendation result with id %s: %v", i.event.ID, err)
+	}
+	var messages []kafka.Message
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(strings.NewReader(yamls)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error when parsing the recommended policies of job %s: %v", i.event.ID, err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		policy, err := k8syaml.ToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("error when converting the recommended policies of job %s to JSON: %v", i.event.ID, err)
+		}
+		// Documents which only hold comments are left out.
+		if string(policy) == "null" {
+			continue
+		}
+		value, err := json.Marshal(Recommendation{
+			ID:                 i.event.ID,
+			Namespace:          i.event.Namespace,
+			Name:               i.event.Name,
+			RecommendationType: i.recommendationType,
+			PolicyType:         i.policyType,
+			Time:               i.event.Time,
+			Policy:             policy,
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, kafka.Message{Key: []byte(i.event.ID), Value: value})
+	}
+}
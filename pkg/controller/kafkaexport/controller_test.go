@@ -0,0 +1,226 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	"antrea.io/theia/pkg/client/clientset/versioned/fake"
+	crdinformers "antrea.io/theia/pkg/client/informers/externalversions"
+	"antrea.io/theia/pkg/util/kafka"
+)
+
+var testTime = time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+type fakePublisher struct {
+	messages map[string][]kafka.Message
+	// topics are the topics of the successful calls to Publish, in order.
+	topics []string
+	err    error
+	// failures is the number of calls to Publish failing with err, or all
+	// of them when it is 0.
+	failures int
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, topic string, messages ...kafka.Message) error {
+	if err := p.err; err != nil {
+		if p.failures > 0 {
+			p.failures--
+			if p.failures == 0 {
+				p.err = nil
+			}
+		}
+		return err
+	}
+	p.messages[topic] = append(p.messages[topic], messages...)
+	p.topics = append(p.topics, topic)
+	return nil
+}
+
+func newTestController(t *testing.T, publisher kafka.Publisher, topics Topics) (*KafkaExportController, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	crdClient := fake.NewSimpleClientset()
+	informerFactory := crdinformers.NewSharedInformerFactory(crdClient, 0)
+	c := NewKafkaExportController(publisher, topics, db, informerFactory.Crd().V1alpha1().NetworkPolicyRecommendations())
+	c.now = func() time.Time { return testTime }
+	return c, mock
+}
+
+func newNPRecommendation(state string) *crdv1alpha1.NetworkPolicyRecommendation {
+	return &crdv1alpha1.NetworkPolicyRecommendation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-1",
+			Namespace: "flow-visibility",
+			UID:       types.UID("e998433e-accb-4888-9fc8-06563f073e86"),
+		},
+		Spec: crdv1alpha1.NetworkPolicyRecommendationSpec{
+			Type:       "initial",
+			PolicyType: "anp-deny-applied",
+		},
+		Status: crdv1alpha1.NetworkPolicyRecommendationStatus{State: state},
+	}
+}
+
+func processAll(c *KafkaExportController) {
+	for c.queue.Len() > 0 {
+		c.processNextWorkItem(make(chan struct{}))
+	}
+}
+
+func TestPublishCompletedJob(t *testing.T) {
+	const result = `apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-a
+  namespace: app-a
+---
+# Comment only
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-acnp-b
+`
+	publisher := &fakePublisher{messages: map[string][]kafka.Message{}}
+	c, mock := newTestController(t, publisher, Topics{JobEvents: DefaultJobEventsTopic, Recommendations: DefaultRecommendationsTopic})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT yamls FROM recommendations WHERE id = (?) ORDER BY namespace, part;")).
+		WithArgs("e998433e-accb-4888-9fc8-06563f073e86").
+		WillReturnRows(sqlmock.NewRows([]string{"yamls"}).AddRow(result))
+
+	// Updates which do not change the state are not published.
+	c.updateNPRecommendation(newNPRecommendation("RUNNING"), newNPRecommendation("RUNNING"))
+	c.updateNPRecommendation(newNPRecommendation("RUNNING"), newNPRecommendation("COMPLETED"))
+	processAll(c)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, publisher.messages[DefaultJobEventsTopic], 1)
+	message := publisher.messages[DefaultJobEventsTopic][0]
+	assert.Equal(t, "e998433e-accb-4888-9fc8-06563f073e86", string(message.Key))
+	assert.JSONEq(t, `{
+		"kind": "JobStateChanged",
+		"id": "e998433e-accb-4888-9fc8-06563f073e86",
+		"namespace": "flow-visibility",
+		"name": "pr-1",
+		"state": "COMPLETED",
+		"previousState": "RUNNING",
+		"time": "2022-08-01T12:00:00Z"
+	}`, string(message.Value))
+
+	recommendations := publisher.messages[DefaultRecommendationsTopic]
+	require.Len(t, recommendations, 2)
+	var recommendation Recommendation
+	require.NoError(t, json.Unmarshal(recommendations[0].Value, &recommendation))
+	assert.Equal(t, "initial", recommendation.RecommendationType)
+	assert.Equal(t, "anp-deny-applied", recommendation.PolicyType)
+	assert.JSONEq(t, `{
+		"apiVersion": "crd.antrea.io/v1alpha1",
+		"kind": "NetworkPolicy",
+		"metadata": {"name": "recommend-allow-anp-a", "namespace": "app-a"}
+	}`, string(recommendation.Policy))
+	require.NoError(t, json.Unmarshal(recommendations[1].Value, &recommendation))
+	assert.Contains(t, string(recommendation.Policy), "recommend-reject-acnp-b")
+}
+
+func TestPublishFailedAndDeletedJob(t *testing.T) {
+	publisher := &fakePublisher{messages: map[string][]kafka.Message{}}
+	// The recommendations are not published without a topic.
+	c, mock := newTestController(t, publisher, Topics{JobEvents: "events"})
+	failed := newNPRecommendation("FAILED")
+	failed.Status.ErrorCode = "JobFailed"
+	failed.Status.ErrorMsg = "driver Pod failed"
+	c.updateNPRecommendation(newNPRecommendation("RUNNING"), failed)
+	c.deleteNPRecommendation(failed)
+	processAll(c)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, publisher.messages["events"], 2)
+	var event JobEvent
+	require.NoError(t, json.Unmarshal(publisher.messages["events"][0].Value, &event))
+	assert.Equal(t, JobEvent{
+		Kind:          JobStateChangedKind,
+		ID:            "e998433e-accb-4888-9fc8-06563f073e86",
+		Namespace:     "flow-visibility",
+		Name:          "pr-1",
+		State:         "FAILED",
+		PreviousState: "RUNNING",
+		ErrorCode:     "JobFailed",
+		ErrorMessage:  "driver Pod failed",
+		Time:          testTime,
+	}, event)
+	require.NoError(t, json.Unmarshal(publisher.messages["events"][1].Value, &event))
+	assert.Equal(t, JobDeletedKind, event.Kind)
+}
+
+func TestPublishRetry(t *testing.T) {
+	const result = `apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-acnp-b
+`
+	publisher := &fakePublisher{messages: map[string][]kafka.Message{}, err: fmt.Errorf("broker unreachable"), failures: 2}
+	c, mock := newTestController(t, publisher, Topics{JobEvents: DefaultJobEventsTopic, Recommendations: DefaultRecommendationsTopic})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT yamls FROM recommendations WHERE id = (?) ORDER BY namespace, part;")).
+		WithArgs("e998433e-accb-4888-9fc8-06563f073e86").
+		WillReturnRows(sqlmock.NewRows([]string{"yamls"}).AddRow(result))
+	var delays []time.Duration
+	c.after = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- testTime
+		return ch
+	}
+
+	c.updateNPRecommendation(newNPRecommendation("RUNNING"), newNPRecommendation("COMPLETED"))
+	// The first publish of the COMPLETED event fails twice, and is retried
+	// before the recommendations of the job are published.
+	require.True(t, c.processNextWorkItem(make(chan struct{})))
+	assert.Equal(t, []time.Duration{minRetryDelay, 2 * minRetryDelay}, delays)
+	assert.Equal(t, []string{DefaultJobEventsTopic}, publisher.topics)
+	processAll(c)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{DefaultJobEventsTopic, DefaultRecommendationsTopic}, publisher.topics)
+	assert.Len(t, delays, 2)
+}
+
+func TestPublishErrorStop(t *testing.T) {
+	publisher := &fakePublisher{messages: map[string][]kafka.Message{}, err: fmt.Errorf("broker unreachable")}
+	c, _ := newTestController(t, publisher, Topics{JobEvents: "events"})
+	c.after = func(d time.Duration) <-chan time.Time {
+		return make(chan time.Time)
+	}
+	c.updateNPRecommendation(newNPRecommendation("NEW"), newNPRecommendation("SCHEDULED"))
+	c.updateNPRecommendation(newNPRecommendation("SCHEDULED"), newNPRecommendation("RUNNING"))
+	stopCh := make(chan struct{})
+	close(stopCh)
+	// The worker stops while waiting to retry, without publishing the next
+	// event of the job.
+	assert.False(t, c.processNextWorkItem(stopCh))
+	assert.Equal(t, 1, c.queue.Len())
+	assert.Empty(t, publisher.messages)
+}
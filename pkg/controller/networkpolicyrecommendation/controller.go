@@ -15,19 +15,29 @@
 package networkpolicyrecommendation
 
 import (
+	"context"
+	"database/sql"
 	"time"
 
 	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	apimachinerytypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 
 	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	intelligence "antrea.io/theia/pkg/apis/intelligence/v1alpha1"
 	"antrea.io/theia/pkg/client/clientset/versioned"
 	crdv1a1informers "antrea.io/theia/pkg/client/informers/externalversions/crd/v1alpha1"
 	"antrea.io/theia/pkg/client/listers/crd/v1alpha1"
+	"antrea.io/theia/pkg/util/env"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/faultinjection"
+	"antrea.io/theia/pkg/util/policyrecommendation"
 )
 
 const (
@@ -43,29 +53,49 @@ const (
 
 type NPRecommendationController struct {
 	crdClient versioned.Interface
+	// kubeClient is used by the executors to run the jobs.
+	kubeClient kubernetes.Interface
+	// namespace is the Namespace of the jobs, in which Theia is running.
+	namespace string
 
 	npRecommendationInformer cache.SharedIndexInformer
 	npRecommendationLister   v1alpha1.NetworkPolicyRecommendationLister
 	npRecommendationSynced   cache.InformerSynced
 	// queue maintains the Service objects that need to be synced.
 	queue workqueue.RateLimitingInterface
+	// quota limits the jobs of each user.
+	quota Quota
+	// faultInjector injects failures when testing, it is nil otherwise.
+	faultInjector *faultinjection.Injector
+	// db is used to read the results written to the sinks of the jobs.
+	db *sql.DB
 }
 
 func NewNPRecommendationController(
 	crdClient versioned.Interface,
+	kubeClient kubernetes.Interface,
 	npRecommendationInformer crdv1a1informers.NetworkPolicyRecommendationInformer,
+	quota Quota,
+	faultInjector *faultinjection.Injector,
+	db *sql.DB,
 ) *NPRecommendationController {
 	c := &NPRecommendationController{
 		crdClient:                crdClient,
+		kubeClient:               kubeClient,
+		namespace:                env.GetTheiaNamespace(),
 		queue:                    workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "npRecommendation"),
 		npRecommendationInformer: npRecommendationInformer.Informer(),
 		npRecommendationLister:   npRecommendationInformer.Lister(),
 		npRecommendationSynced:   npRecommendationInformer.Informer().HasSynced,
+		quota:                    quota,
+		faultInjector:            faultInjector,
+		db:                       db,
 	}
 
 	c.npRecommendationInformer.AddEventHandlerWithResyncPeriod(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc:    c.addNPRecommendation,
+			UpdateFunc: c.updateNPRecommendation,
 			DeleteFunc: c.deleteNPRecommendation,
 		},
 		resyncPeriod,
@@ -84,6 +114,16 @@ func (c *NPRecommendationController) addNPRecommendation(obj interface{}) {
 	c.queue.Add(namespacedName)
 }
 
+func (c *NPRecommendationController) updateNPRecommendation(_, cur interface{}) {
+	npReco, _ := cur.(*crdv1alpha1.NetworkPolicyRecommendation)
+	klog.V(2).Infof("Processing NP Recommendation %s UPDATE event, labels: %v", npReco.Name, npReco.Labels)
+	namespacedName := apimachinerytypes.NamespacedName{
+		Namespace: npReco.Namespace,
+		Name:      npReco.Name,
+	}
+	c.queue.Add(namespacedName)
+}
+
 func (c *NPRecommendationController) deleteNPRecommendation(old interface{}) {
 	npReco, ok := old.(*crdv1alpha1.NetworkPolicyRecommendation)
 	if !ok {
@@ -169,10 +209,110 @@ func (c *NPRecommendationController) syncNPRecommendation(key apimachinerytypes.
 	}
 
 	klog.V(4).Infof("Syncing NP Recommendation %v", npReco)
-	// TODO (wshaoquan): add handling logic here
+	switch npReco.Status.State {
+	case "":
+		return c.admitNPRecommendation(npReco)
+	case intelligence.NPRecommendationStateNew:
+		ready, reason, err := checkDependencies(npReco, c.npRecommendationLister.NetworkPolicyRecommendations(npReco.Namespace))
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			klog.InfoS("Rejecting NP Recommendation with unsatisfiable dependencies", "name", npReco.Name, "reason", reason)
+			return c.failNPRecommendation(npReco, DependencyFailedErrorCode, reason)
+		}
+		if !ready {
+			c.queue.AddAfter(key, dependencyCheckInterval)
+			return nil
+		}
+		return c.startNPRecommendation(npReco)
+	case intelligence.NPRecommendationStateScheduled, intelligence.NPRecommendationStateRunning:
+		active, err := c.updateJobStatus(npReco)
+		if active {
+			c.queue.AddAfter(key, jobStatusCheckInterval)
+		}
+		return err
+	}
 	return nil
 }
 
+// admitNPRecommendation moves a new NetworkPolicyRecommendation to the NEW
+// state, or to the FAILED state if it exceeds the quota of its user.
+func (c *NPRecommendationController) admitNPRecommendation(npReco *crdv1alpha1.NetworkPolicyRecommendation) error {
+	jobs, err := c.npRecommendationLister.NetworkPolicyRecommendations(npReco.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	update := npReco.DeepCopy()
+	if reason := c.quota.check(npReco, jobs); reason != "" {
+		klog.InfoS("Rejecting NP Recommendation exceeding quota", "name", npReco.Name, "reason", reason)
+		update.Status.State = intelligence.NPRecommendationStateFailed
+		update.Status.ErrorCode = QuotaExceededErrorCode
+		update.Status.ErrorMsg = "Quota exceeded: " + reason
+	} else {
+		update.Status.State = intelligence.NPRecommendationStateNew
+	}
+	_, err = c.crdClient.CrdV1alpha1().NetworkPolicyRecommendations(npReco.Namespace).UpdateStatus(context.TODO(), update, metav1.UpdateOptions{})
+	return err
+}
+
+// startNPRecommendation submits the job of a NEW NetworkPolicyRecommendation to
+// the executor of the requested backend, and moves it to the SCHEDULED state.
+// Invalid jobs are moved to the FAILED state.
+func (c *NPRecommendationController) startNPRecommendation(npReco *crdv1alpha1.NetworkPolicyRecommendation) error {
+	update := npReco.DeepCopy()
+	backend := npReco.Spec.Backend
+	if backend == "" {
+		backend = executor.DefaultBackend
+	}
+	jobExecutor, err := executor.New(backend, executor.Options{Clientset: c.kubeClient, Namespace: c.namespace, FaultInjector: c.faultInjector})
+	var request *executor.Request
+	if err == nil {
+		request, err = newJobRequest(npReco)
+	}
+	if err != nil {
+		klog.InfoS("Rejecting invalid NP Recommendation", "name", npReco.Name, "err", err)
+		update.Status.State = intelligence.NPRecommendationStateFailed
+		update.Status.ErrorCode = InvalidJobErrorCode
+		update.Status.ErrorMsg = err.Error()
+	} else {
+		// The Services whose endpoints are reached directly are resolved
+		// when the job is submitted. Errors are retried.
+		endpointServices, err := policyrecommendation.EndpointServices(context.TODO(), c.kubeClient)
+		if err != nil {
+			return err
+		}
+		endpointServicesArgs, err := policyrecommendation.EndpointServicesArgs(endpointServices)
+		if err != nil {
+			return err
+		}
+		request.Args = append(request.Args, endpointServicesArgs...)
+		// Jobs submitted by theia-manager always use the auto Windows
+		// compatibility mode.
+		windowsNodes, err := policyrecommendation.WindowsNodes(context.TODO(), c.kubeClient)
+		if err != nil {
+			return err
+		}
+		if policyrecommendation.WindowsCompatibilityAuto.Enabled(windowsNodes) {
+			klog.InfoS("Recommending policies compatible with Windows Nodes", "name", npReco.Name, "windowsNodes", windowsNodes)
+			request.Args = append(request.Args, policyrecommendation.WindowsCompatibilityArgs(true)...)
+		}
+		// The job already exists if the status update failed after a
+		// previous submission.
+		if err := jobExecutor.Submit(context.TODO(), request); err != nil && !apimachineryerrors.IsAlreadyExists(err) {
+			return err
+		}
+		klog.InfoS("Submitted NP Recommendation job", "name", npReco.Name, "id", request.ID, "backend", backend)
+		update.Status.State = intelligence.NPRecommendationStateScheduled
+	}
+	_, err = c.crdClient.CrdV1alpha1().NetworkPolicyRecommendations(npReco.Namespace).UpdateStatus(context.TODO(), update, metav1.UpdateOptions{})
+	return err
+}
+
 func (c *NPRecommendationController) GetNetworkPolicyRecommendation(namespace, name string) (*crdv1alpha1.NetworkPolicyRecommendation, error) {
 	return c.npRecommendationLister.NetworkPolicyRecommendations(namespace).Get(name)
 }
+
+func (c *NPRecommendationController) ListNetworkPolicyRecommendations(namespace string) ([]*crdv1alpha1.NetworkPolicyRecommendation, error) {
+	return c.npRecommendationLister.NetworkPolicyRecommendations(namespace).List(labels.Everything())
+}
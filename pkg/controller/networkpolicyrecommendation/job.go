@@ -0,0 +1,136 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+	s3client "antrea.io/theia/pkg/util/s3"
+)
+
+const (
+	// InvalidJobErrorCode is the error code of jobs which cannot be run
+	// because their spec is invalid.
+	InvalidJobErrorCode = "InvalidJob"
+
+	defaultExecutorInstances = 1
+	defaultCoreRequest       = "200m"
+	defaultMemory            = "512M"
+)
+
+func defaultString(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// newJobRequest returns the request to run the job of a
+// NetworkPolicyRecommendation. The ID of the job is the UID of the
+// NetworkPolicyRecommendation.
+func newJobRequest(npReco *crdv1alpha1.NetworkPolicyRecommendation) (*executor.Request, error) {
+	spec := &npReco.Spec
+	recoType, err := policyrecommendation.ParseRecommendationType("type", spec.Type)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Limit < 0 {
+		return nil, fmt.Errorf("limit should be an integer >= 0")
+	}
+	policyType, err := policyrecommendation.ParsePolicyType("policyType", spec.PolicyType)
+	if err != nil {
+		return nil, err
+	}
+	id := string(npReco.UID)
+	args := []string{
+		"--type", string(recoType),
+		"--limit", strconv.Itoa(spec.Limit),
+		"--option", policyType.OptionArg(),
+	}
+	if !spec.StartTime.IsZero() {
+		args = append(args, "--start_time", spec.StartTime.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !spec.EndTime.IsZero() {
+		args = append(args, "--end_time", spec.EndTime.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if len(spec.NSAllowList) > 0 {
+		nsAllowList, err := json.Marshal(spec.NSAllowList)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--ns_allow_list", string(nsAllowList))
+	}
+	args = append(args,
+		"--rm_labels", strconv.FormatBool(spec.ExcludeLabels),
+		"--to_services", strconv.FormatBool(spec.ToServices),
+		"--id", id,
+	)
+	if spec.ArtifactsURI != "" {
+		if _, err := s3client.ParseArtifactsURI(spec.ArtifactsURI, spec.ArtifactsEndpoint); err != nil {
+			return nil, err
+		}
+		args = append(args, "--artifacts_uri", spec.ArtifactsURI)
+		if spec.ArtifactsEndpoint != "" {
+			args = append(args, "--artifacts_endpoint", spec.ArtifactsEndpoint)
+		}
+	}
+
+	if spec.Sink != "" {
+		if err := validateSink(spec.Sink); err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.ExecutorInstances < 0 {
+		return nil, fmt.Errorf("executorInstances should be an integer >= 0")
+	}
+	executorInstances := int32(spec.ExecutorInstances)
+	if executorInstances == 0 {
+		executorInstances = defaultExecutorInstances
+	}
+	resources := executor.Resources{
+		ExecutorInstances:   executorInstances,
+		DriverCoreRequest:   defaultString(spec.DriverCoreRequest, defaultCoreRequest),
+		DriverMemory:        defaultString(spec.DriverMemory, defaultMemory),
+		ExecutorCoreRequest: defaultString(spec.ExecutorCoreRequest, defaultCoreRequest),
+		ExecutorMemory:      defaultString(spec.ExecutorMemory, defaultMemory),
+	}
+	for _, quantity := range []struct {
+		name  string
+		value string
+	}{
+		{"driverCoreRequest", resources.DriverCoreRequest},
+		{"driverMemory", resources.DriverMemory},
+		{"executorCoreRequest", resources.ExecutorCoreRequest},
+		{"executorMemory", resources.ExecutorMemory},
+	} {
+		if _, err := resource.ParseQuantity(quantity.value); err != nil {
+			return nil, fmt.Errorf("%s should conform to the Kubernetes resource quantity convention", quantity.name)
+		}
+	}
+	return &executor.Request{
+		ID:              id,
+		Args:            args,
+		Resources:       resources,
+		ArtifactsSecret: spec.ArtifactsSecret,
+	}, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	"antrea.io/theia/pkg/client/clientset/versioned/fake"
+	crdinformers "antrea.io/theia/pkg/client/informers/externalversions"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+func TestNewJobRequest(t *testing.T) {
+	testCases := []struct {
+		name              string
+		spec              crdv1alpha1.NetworkPolicyRecommendationSpec
+		expectedArgs      []string
+		expectedResources executor.Resources
+		expectedErr       string
+	}{
+		{
+			name: "default spec",
+			spec: crdv1alpha1.NetworkPolicyRecommendationSpec{},
+			expectedArgs: []string{
+				"--type", "initial", "--limit", "0", "--option", "1",
+				"--rm_labels", "false", "--to_services", "false", "--id", "pr-1",
+			},
+			expectedResources: executor.Resources{
+				ExecutorInstances:   1,
+				DriverCoreRequest:   "200m",
+				DriverMemory:        "512M",
+				ExecutorCoreRequest: "200m",
+				ExecutorMemory:      "512M",
+			},
+		},
+		{
+			name: "full spec",
+			spec: crdv1alpha1.NetworkPolicyRecommendationSpec{
+				Type:                "Subsequent",
+				Limit:               100,
+				PolicyType:          "k8s-np",
+				StartTime:           metav1.NewTime(time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)),
+				EndTime:             metav1.NewTime(time.Date(2022, 8, 1, 3, 0, 0, 0, time.FixedZone("PDT", -7*3600))),
+				NSAllowList:         []string{"kube-system"},
+				ExcludeLabels:       true,
+				ToServices:          true,
+				ExecutorInstances:   4,
+				DriverCoreRequest:   "1",
+				DriverMemory:        "1G",
+				ExecutorCoreRequest: "500m",
+				ExecutorMemory:      "2G",
+				ArtifactsURI:        "gs://my-bucket/theia",
+				ArtifactsSecret:     "theia-artifacts",
+			},
+			expectedArgs: []string{
+				"--type", "subsequent", "--limit", "100", "--option", "3",
+				"--start_time", "2022-08-01 10:00:00", "--end_time", "2022-08-01 10:00:00",
+				"--ns_allow_list", `["kube-system"]`,
+				"--rm_labels", "true", "--to_services", "true", "--id", "pr-1",
+				"--artifacts_uri", "gs://my-bucket/theia",
+			},
+			expectedResources: executor.Resources{
+				ExecutorInstances:   4,
+				DriverCoreRequest:   "1",
+				DriverMemory:        "1G",
+				ExecutorCoreRequest: "500m",
+				ExecutorMemory:      "2G",
+			},
+		},
+		{
+			name:        "invalid type",
+			spec:        crdv1alpha1.NetworkPolicyRecommendationSpec{Type: "periodic"},
+			expectedErr: "type should be initial or subsequent",
+		},
+		{
+			name:        "invalid policy type",
+			spec:        crdv1alpha1.NetworkPolicyRecommendationSpec{PolicyType: "deny-all"},
+			expectedErr: "policyType should be anp-deny-applied, anp-deny-all or k8s-np",
+		},
+		{
+			name:        "invalid memory",
+			spec:        crdv1alpha1.NetworkPolicyRecommendationSpec{ExecutorMemory: "2 GB"},
+			expectedErr: "executorMemory should conform to the Kubernetes resource quantity convention",
+		},
+		{
+			name:        "invalid artifacts URI",
+			spec:        crdv1alpha1.NetworkPolicyRecommendationSpec{ArtifactsURI: "my-bucket/theia"},
+			expectedErr: "artifacts URI should be s3://<bucket>/<prefix> or gs://<bucket>/<prefix>",
+		},
+		{
+			name:        "unsupported sink",
+			spec:        crdv1alpha1.NetworkPolicyRecommendationSpec{Sink: "file:///tmp/result.yaml"},
+			expectedErr: "sink scheme should be one of: configmap, gs, http, https, s3, secret",
+		},
+		{
+			name:        "invalid sink",
+			spec:        crdv1alpha1.NetworkPolicyRecommendationSpec{Sink: "configmap://flow-visibility"},
+			expectedErr: "configmap sink should be configmap://<namespace>/<name>",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			npReco := newJob("pr-1", "", testCreated, "NEW")
+			npReco.Spec = tt.spec
+			request, err := newJobRequest(npReco)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "pr-1", request.ID)
+			assert.Equal(t, tt.expectedArgs, request.Args)
+			assert.Equal(t, tt.expectedResources, request.Resources)
+			assert.Equal(t, tt.spec.ArtifactsSecret, request.ArtifactsSecret)
+		})
+	}
+}
+
+func TestStartNPRecommendation(t *testing.T) {
+	scheduled := newJob("pr-1", "", testCreated, "NEW")
+	scheduled.Spec.Backend = executor.K8sJobBackend
+	invalidBackend := newJob("pr-2", "", testCreated, "NEW")
+	invalidBackend.Spec.Backend = "flink"
+	crdClient := fake.NewSimpleClientset(scheduled, invalidBackend)
+	headless := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "antrea-test"},
+		Spec:       v1.ServiceSpec{ClusterIP: v1.ClusterIPNone},
+	}
+	windowsNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "win-1", Labels: map[string]string{v1.LabelOSStable: "windows"}}}
+	kubeClient := kubefake.NewSimpleClientset(headless, windowsNode)
+	informerFactory := crdinformers.NewSharedInformerFactory(crdClient, 0)
+	informer := informerFactory.Crd().V1alpha1().NetworkPolicyRecommendations()
+	// Theia is installed in a custom Namespace, in which the jobs run.
+	t.Setenv("POD_NAMESPACE", "theia")
+	c := NewNPRecommendationController(crdClient, kubeClient, informer, Quota{}, nil, nil)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+
+	for _, name := range []string{"pr-1", "pr-2"} {
+		require.NoError(t, c.syncNPRecommendation(types.NamespacedName{Namespace: "flow-visibility", Name: name}))
+	}
+	npReco, err := crdClient.CrdV1alpha1().NetworkPolicyRecommendations("flow-visibility").Get(context.TODO(), "pr-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "SCHEDULED", npReco.Status.State)
+	job, err := executor.GetJob(context.TODO(), executor.All(executor.Options{Clientset: kubeClient, Namespace: "theia"}), "pr-1")
+	require.NoError(t, err)
+	assert.Equal(t, executor.K8sJobBackend, job.Backend)
+	k8sJobs, err := kubeClient.BatchV1().Jobs("theia").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, k8sJobs.Items, 1)
+	assert.Subset(t, k8sJobs.Items[0].Spec.Template.Spec.Containers[0].Args, []string{"--endpoint_svcs", `["antrea-test/headless"]`})
+	assert.Subset(t, k8sJobs.Items[0].Spec.Template.Spec.Containers[0].Args, []string{"--windows_compat", "true"})
+
+	npReco, err = crdClient.CrdV1alpha1().NetworkPolicyRecommendations("flow-visibility").Get(context.TODO(), "pr-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, crdv1alpha1.NetworkPolicyRecommendationStatus{
+		State:     "FAILED",
+		ErrorCode: InvalidJobErrorCode,
+		ErrorMsg:  "backend should be one of: k8s-job, spark",
+	}, npReco.Status)
+}
@@ -0,0 +1,202 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	intelligence "antrea.io/theia/pkg/apis/intelligence/v1alpha1"
+	"antrea.io/theia/pkg/client/listers/crd/v1alpha1"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+const (
+	// DependsOnAnnotation is the annotation of NetworkPolicyRecommendations
+	// with the comma-separated names of the NetworkPolicyRecommendations, in
+	// the same Namespace, which must complete before its job is started. It is
+	// used to run the stages of a pipeline in order.
+	DependsOnAnnotation = "crd.theia.antrea.io/depends-on"
+	// PipelineLabel is the label of the NetworkPolicyRecommendations created
+	// for the stages of a pipeline, with the name of the pipeline.
+	PipelineLabel = "crd.theia.antrea.io/pipeline"
+	// PipelineStageLabel is the label with the name of the pipeline stage.
+	PipelineStageLabel = "crd.theia.antrea.io/pipeline-stage"
+
+	// DependencyFailedErrorCode is the error code of jobs which are not run
+	// because one of their dependencies failed or does not exist.
+	DependencyFailedErrorCode = "DependencyFailed"
+	// JobFailedErrorCode is the error code of jobs which failed on their
+	// backend.
+	JobFailedErrorCode = "JobFailed"
+
+	// How often the dependencies of a waiting job, and the state of a
+	// running job, are checked.
+	dependencyCheckInterval = 10 * time.Second
+	jobStatusCheckInterval  = 30 * time.Second
+)
+
+// getDependencies returns the names of the NetworkPolicyRecommendations the
+// job depends on.
+func getDependencies(npReco *crdv1alpha1.NetworkPolicyRecommendation) []string {
+	var dependencies []string
+	for _, name := range strings.Split(npReco.Annotations[DependsOnAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			dependencies = append(dependencies, name)
+		}
+	}
+	return dependencies
+}
+
+// checkDependencies returns whether all the dependencies of the job have
+// completed. It returns a non-empty reason if the job can never be started,
+// because a dependency failed, does not exist, or depends on the job itself.
+func checkDependencies(npReco *crdv1alpha1.NetworkPolicyRecommendation, lister v1alpha1.NetworkPolicyRecommendationNamespaceLister) (bool, string, error) {
+	ready := true
+	for _, name := range getDependencies(npReco) {
+		dependency, err := lister.Get(name)
+		if apimachineryerrors.IsNotFound(err) {
+			return false, fmt.Sprintf("dependency %s does not exist", name), nil
+		} else if err != nil {
+			return false, "", err
+		}
+		switch dependency.Status.State {
+		case intelligence.NPRecommendationStateCompleted:
+		case intelligence.NPRecommendationStateFailed:
+			return false, fmt.Sprintf("dependency %s failed", name), nil
+		case intelligence.NPRecommendationStateCancelled:
+			return false, fmt.Sprintf("dependency %s was cancelled", name), nil
+		default:
+			ready = false
+		}
+	}
+	if !ready {
+		cyclic, err := dependsOn(npReco, npReco.Name, lister, map[string]bool{})
+		if err != nil {
+			return false, "", err
+		}
+		if cyclic {
+			return false, "dependencies form a cycle", nil
+		}
+	}
+	return ready, "", nil
+}
+
+// dependsOn returns whether the job depends, directly or transitively, on the
+// NetworkPolicyRecommendation with the given name.
+func dependsOn(npReco *crdv1alpha1.NetworkPolicyRecommendation, name string, lister v1alpha1.NetworkPolicyRecommendationNamespaceLister, visited map[string]bool) (bool, error) {
+	for _, dependencyName := range getDependencies(npReco) {
+		if dependencyName == name {
+			return true, nil
+		}
+		if visited[dependencyName] {
+			continue
+		}
+		visited[dependencyName] = true
+		dependency, err := lister.Get(dependencyName)
+		if apimachineryerrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if found, err := dependsOn(dependency, name, lister, visited); found || err != nil {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// jobState returns the state of a NetworkPolicyRecommendation, with an error
+// message for failed jobs, given the job reported by its backend.
+func jobState(job *executor.Job) (string, string) {
+	if job == nil {
+		return intelligence.NPRecommendationStateFailed, "job not found on its backend"
+	}
+	switch job.State {
+	case "COMPLETED":
+		return intelligence.NPRecommendationStateCompleted, ""
+	case executor.CancelledState:
+		return intelligence.NPRecommendationStateCancelled, ""
+	case "FAILED", "SUBMISSION_FAILED", "FAILING", "INVALIDATING":
+		message := job.ErrorMessage
+		if message == "" {
+			message = fmt.Sprintf("job is in state %s", job.State)
+		}
+		return intelligence.NPRecommendationStateFailed, message
+	case "RUNNING", "SUCCEEDING":
+		return intelligence.NPRecommendationStateRunning, ""
+	}
+	return intelligence.NPRecommendationStateScheduled, ""
+}
+
+// updateJobStatus updates the state of a SCHEDULED or RUNNING
+// NetworkPolicyRecommendation from the state of its job. It returns whether
+// the job is still active.
+func (c *NPRecommendationController) updateJobStatus(npReco *crdv1alpha1.NetworkPolicyRecommendation) (bool, error) {
+	backend := npReco.Spec.Backend
+	if backend == "" {
+		backend = executor.DefaultBackend
+	}
+	jobExecutor, err := executor.New(backend, executor.Options{Clientset: c.kubeClient, Namespace: c.namespace, FaultInjector: c.faultInjector})
+	if err != nil {
+		return false, err
+	}
+	job, err := jobExecutor.Get(context.TODO(), string(npReco.UID))
+	if err != nil {
+		return true, err
+	}
+	state, message := jobState(job)
+	active := state == intelligence.NPRecommendationStateScheduled || state == intelligence.NPRecommendationStateRunning
+	if state == npReco.Status.State {
+		return active, nil
+	}
+	klog.InfoS("NP Recommendation job changed state", "name", npReco.Name, "state", state)
+	update := npReco.DeepCopy()
+	update.Status.State = state
+	if state == intelligence.NPRecommendationStateFailed {
+		update.Status.ErrorCode = JobFailedErrorCode
+		update.Status.ErrorMsg = message
+	}
+	// The delivery of the result is not retried: the job stays COMPLETED,
+	// and the result can still be retrieved with the CLI.
+	if state == intelligence.NPRecommendationStateCompleted && npReco.Spec.Sink != "" {
+		if err := c.writeResult(npReco); err != nil {
+			klog.ErrorS(err, "Failed to write NP Recommendation result to its sink", "name", npReco.Name)
+			update.Status.ErrorCode = ResultDeliveryErrorCode
+			update.Status.ErrorMsg = err.Error()
+		} else {
+			klog.InfoS("Wrote NP Recommendation result to its sink", "name", npReco.Name)
+		}
+	}
+	_, err = c.crdClient.CrdV1alpha1().NetworkPolicyRecommendations(npReco.Namespace).UpdateStatus(context.TODO(), update, metav1.UpdateOptions{})
+	return active, err
+}
+
+// failNPRecommendation moves a NetworkPolicyRecommendation to the FAILED state.
+func (c *NPRecommendationController) failNPRecommendation(npReco *crdv1alpha1.NetworkPolicyRecommendation, errorCode, errorMsg string) error {
+	update := npReco.DeepCopy()
+	update.Status.State = intelligence.NPRecommendationStateFailed
+	update.Status.ErrorCode = errorCode
+	update.Status.ErrorMsg = errorMsg
+	_, err := c.crdClient.CrdV1alpha1().NetworkPolicyRecommendations(npReco.Namespace).UpdateStatus(context.TODO(), update, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	"antrea.io/theia/pkg/client/clientset/versioned/fake"
+	crdinformers "antrea.io/theia/pkg/client/informers/externalversions"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+func newStage(name string, state string, dependencies string) *crdv1alpha1.NetworkPolicyRecommendation {
+	job := newJob(name, "", testCreated, state)
+	if dependencies != "" {
+		job.Annotations = map[string]string{DependsOnAnnotation: dependencies}
+	}
+	return job
+}
+
+func TestCheckDependencies(t *testing.T) {
+	testCases := []struct {
+		name           string
+		jobs           []*crdv1alpha1.NetworkPolicyRecommendation
+		expectedReady  bool
+		expectedReason string
+	}{
+		{
+			name:          "no dependencies",
+			jobs:          []*crdv1alpha1.NetworkPolicyRecommendation{newStage("pr-1", "NEW", "")},
+			expectedReady: true,
+		},
+		{
+			name: "dependencies completed",
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newStage("pr-1", "NEW", "pr-2, pr-3"),
+				newStage("pr-2", "COMPLETED", ""),
+				newStage("pr-3", "COMPLETED", ""),
+			},
+			expectedReady: true,
+		},
+		{
+			name: "dependency running",
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newStage("pr-1", "NEW", "pr-2,pr-3"),
+				newStage("pr-2", "COMPLETED", ""),
+				newStage("pr-3", "RUNNING", ""),
+			},
+		},
+		{
+			name: "dependency failed",
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newStage("pr-1", "NEW", "pr-2,pr-3"),
+				newStage("pr-2", "RUNNING", ""),
+				newStage("pr-3", "FAILED", ""),
+			},
+			expectedReason: "dependency pr-3 failed",
+		},
+		{
+			name: "dependency cancelled",
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newStage("pr-1", "NEW", "pr-2"),
+				newStage("pr-2", "CANCELLED", ""),
+			},
+			expectedReason: "dependency pr-2 was cancelled",
+		},
+		{
+			name:           "missing dependency",
+			jobs:           []*crdv1alpha1.NetworkPolicyRecommendation{newStage("pr-1", "NEW", "pr-2")},
+			expectedReason: "dependency pr-2 does not exist",
+		},
+		{
+			name: "cyclic dependencies",
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newStage("pr-1", "NEW", "pr-2"),
+				newStage("pr-2", "NEW", "pr-3"),
+				newStage("pr-3", "NEW", "pr-1"),
+			},
+			expectedReason: "dependencies form a cycle",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			crdClient := fake.NewSimpleClientset()
+			informerFactory := crdinformers.NewSharedInformerFactory(crdClient, 0)
+			informer := informerFactory.Crd().V1alpha1().NetworkPolicyRecommendations()
+			for _, job := range tt.jobs {
+				require.NoError(t, informer.Informer().GetIndexer().Add(job))
+			}
+			ready, reason, err := checkDependencies(tt.jobs[0], informer.Lister().NetworkPolicyRecommendations("flow-visibility"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedReady, ready)
+			assert.Equal(t, tt.expectedReason, reason)
+		})
+	}
+}
+
+func TestJobState(t *testing.T) {
+	testCases := []struct {
+		job             *executor.Job
+		expectedState   string
+		expectedMessage string
+	}{
+		{nil, "FAILED", "job not found on its backend"},
+		{&executor.Job{State: "SUBMITTED"}, "SCHEDULED", ""},
+		{&executor.Job{State: "RUNNING"}, "RUNNING", ""},
+		{&executor.Job{State: "COMPLETED"}, "COMPLETED", ""},
+		{&executor.Job{State: "FAILED", ErrorMessage: "driver OOMKilled"}, "FAILED", "driver OOMKilled"},
+		{&executor.Job{State: "CANCELLED"}, "CANCELLED", ""},
+		{&executor.Job{State: "SUBMISSION_FAILED"}, "FAILED", "job is in state SUBMISSION_FAILED"},
+	}
+	for _, tt := range testCases {
+		state, message := jobState(tt.job)
+		assert.Equal(t, tt.expectedState, state)
+		assert.Equal(t, tt.expectedMessage, message)
+	}
+}
+
+func TestSyncNPRecommendationDependencyFailed(t *testing.T) {
+	jobs := []*crdv1alpha1.NetworkPolicyRecommendation{
+		newStage("pr-1", "FAILED", ""),
+		newStage("pr-2", "NEW", "pr-1"),
+	}
+	crdClient := fake.NewSimpleClientset(jobs[0], jobs[1])
+	informerFactory := crdinformers.NewSharedInformerFactory(crdClient, 0)
+	informer := informerFactory.Crd().V1alpha1().NetworkPolicyRecommendations()
+	c := NewNPRecommendationController(crdClient, kubefake.NewSimpleClientset(), informer, Quota{}, nil, nil)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+
+	require.NoError(t, c.syncNPRecommendation(types.NamespacedName{Namespace: "flow-visibility", Name: "pr-2"}))
+	job, err := crdClient.CrdV1alpha1().NetworkPolicyRecommendations("flow-visibility").Get(context.TODO(), "pr-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, crdv1alpha1.NetworkPolicyRecommendationStatus{
+		State:     "FAILED",
+		ErrorCode: DependencyFailedErrorCode,
+		ErrorMsg:  "dependency pr-1 failed",
+	}, job.Status)
+}
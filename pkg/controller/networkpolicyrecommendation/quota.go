@@ -0,0 +1,100 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"fmt"
+	"time"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	intelligence "antrea.io/theia/pkg/apis/intelligence/v1alpha1"
+)
+
+const (
+	// RequestedByAnnotation is the annotation of NetworkPolicyRecommendation
+	// jobs identifying the user or ServiceAccount which submitted them.
+	RequestedByAnnotation = "crd.theia.antrea.io/requested-by"
+	// QuotaExceededErrorCode is the error code of jobs rejected because they
+	// exceed the quota of their user.
+	QuotaExceededErrorCode = "QuotaExceeded"
+	// anonymousUser is the user of jobs without RequestedByAnnotation.
+	anonymousUser = "anonymous"
+	quotaPeriod   = 24 * time.Hour
+)
+
+// Quota limits the NetworkPolicyRecommendation jobs of each user, so that a
+// single user cannot use all the Spark capacity. A limit of 0 means no limit.
+type Quota struct {
+	MaxConcurrentJobs int
+	MaxDailyJobs      int
+}
+
+func requester(job *crdv1alpha1.NetworkPolicyRecommendation) string {
+	if user := job.Annotations[RequestedByAnnotation]; user != "" {
+		return user
+	}
+	return anonymousUser
+}
+
+func isActive(job *crdv1alpha1.NetworkPolicyRecommendation) bool {
+	switch job.Status.State {
+	case "", intelligence.NPRecommendationStateNew, intelligence.NPRecommendationStateScheduled, intelligence.NPRecommendationStateRunning:
+		return true
+	}
+	return false
+}
+
+// submittedBefore orders jobs by creation time, and by name for jobs created
+// within the same second, so that admission does not depend on the order in
+// which jobs are processed.
+func submittedBefore(a, b *crdv1alpha1.NetworkPolicyRecommendation) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+// check returns why the job cannot be admitted, or an empty string if the job
+// is within the quota of its user. Only the jobs of the same user submitted
+// before the job and not rejected themselves are counted.
+func (q Quota) check(job *crdv1alpha1.NetworkPolicyRecommendation, jobs []*crdv1alpha1.NetworkPolicyRecommendation) string {
+	if q.MaxConcurrentJobs <= 0 && q.MaxDailyJobs <= 0 {
+		return ""
+	}
+	user := requester(job)
+	concurrentJobs, dailyJobs := 0, 0
+	periodStart := job.CreationTimestamp.Add(-quotaPeriod)
+	for _, other := range jobs {
+		if other.UID == job.UID || requester(other) != user || !submittedBefore(other, job) {
+			continue
+		}
+		if other.Status.ErrorCode == QuotaExceededErrorCode {
+			continue
+		}
+		if isActive(other) {
+			concurrentJobs++
+		}
+		if other.CreationTimestamp.Time.After(periodStart) {
+			dailyJobs++
+		}
+	}
+	if q.MaxConcurrentJobs > 0 && concurrentJobs >= q.MaxConcurrentJobs {
+		return fmt.Sprintf("user %s already has %d active jobs, which is the maximum number of concurrent jobs per user, please retry after one of them completes or delete it", user, concurrentJobs)
+	}
+	if q.MaxDailyJobs > 0 && dailyJobs >= q.MaxDailyJobs {
+		return fmt.Sprintf("user %s already submitted %d jobs over the last 24 hours, which is the maximum number of daily jobs per user", user, dailyJobs)
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	"antrea.io/theia/pkg/client/clientset/versioned/fake"
+	crdinformers "antrea.io/theia/pkg/client/informers/externalversions"
+)
+
+var testCreated = time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+
+func newJob(name string, user string, created time.Time, state string) *crdv1alpha1.NetworkPolicyRecommendation {
+	job := &crdv1alpha1.NetworkPolicyRecommendation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "flow-visibility",
+			UID:               types.UID(name),
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: crdv1alpha1.NetworkPolicyRecommendationStatus{State: state},
+	}
+	if user != "" {
+		job.Annotations = map[string]string{RequestedByAnnotation: user}
+	}
+	return job
+}
+
+func TestQuotaCheck(t *testing.T) {
+	rejected := newJob("pr-rejected", "alice", testCreated.Add(-time.Hour), "FAILED")
+	rejected.Status.ErrorCode = QuotaExceededErrorCode
+	testCases := []struct {
+		name           string
+		quota          Quota
+		job            *crdv1alpha1.NetworkPolicyRecommendation
+		jobs           []*crdv1alpha1.NetworkPolicyRecommendation
+		expectedReason string
+	}{
+		{
+			name:  "no quota",
+			quota: Quota{},
+			job:   newJob("pr-2", "alice", testCreated, ""),
+			jobs:  []*crdv1alpha1.NetworkPolicyRecommendation{newJob("pr-1", "alice", testCreated.Add(-time.Minute), "RUNNING")},
+		},
+		{
+			name:  "within concurrent quota",
+			quota: Quota{MaxConcurrentJobs: 2},
+			job:   newJob("pr-3", "alice", testCreated, ""),
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newJob("pr-1", "alice", testCreated.Add(-time.Minute), "RUNNING"),
+				newJob("pr-2", "alice", testCreated.Add(-2*time.Minute), "COMPLETED"),
+				newJob("pr-4", "bob", testCreated.Add(-time.Minute), "RUNNING"),
+				rejected,
+			},
+		},
+		{
+			name:  "concurrent quota exceeded",
+			quota: Quota{MaxConcurrentJobs: 2},
+			job:   newJob("pr-3", "alice", testCreated, ""),
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newJob("pr-1", "alice", testCreated.Add(-time.Minute), "RUNNING"),
+				newJob("pr-2", "alice", testCreated.Add(-2*time.Minute), "NEW"),
+			},
+			expectedReason: "user alice already has 2 active jobs, which is the maximum number of concurrent jobs per user, please retry after one of them completes or delete it",
+		},
+		{
+			name:  "later jobs are not counted",
+			quota: Quota{MaxConcurrentJobs: 1},
+			job:   newJob("pr-1", "alice", testCreated, ""),
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newJob("pr-1", "alice", testCreated, ""),
+				newJob("pr-2", "alice", testCreated, ""),
+				newJob("pr-3", "alice", testCreated.Add(time.Second), ""),
+			},
+		},
+		{
+			name:  "daily quota exceeded",
+			quota: Quota{MaxConcurrentJobs: 2, MaxDailyJobs: 2},
+			job:   newJob("pr-3", "", testCreated, ""),
+			jobs: []*crdv1alpha1.NetworkPolicyRecommendation{
+				newJob("pr-1", "", testCreated.Add(-time.Hour), "COMPLETED"),
+				newJob("pr-2", "", testCreated.Add(-23*time.Hour), "FAILED"),
+				newJob("pr-0", "", testCreated.Add(-25*time.Hour), "COMPLETED"),
+			},
+			expectedReason: "user anonymous already submitted 2 jobs over the last 24 hours, which is the maximum number of daily jobs per user",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedReason, tt.quota.check(tt.job, tt.jobs))
+		})
+	}
+}
+
+func TestAdmitNPRecommendation(t *testing.T) {
+	jobs := []*crdv1alpha1.NetworkPolicyRecommendation{
+		newJob("pr-1", "alice", testCreated, "RUNNING"),
+		newJob("pr-2", "alice", testCreated.Add(time.Minute), ""),
+		newJob("pr-3", "bob", testCreated.Add(time.Minute), ""),
+	}
+	crdClient := fake.NewSimpleClientset(jobs[0], jobs[1], jobs[2])
+	informerFactory := crdinformers.NewSharedInformerFactory(crdClient, 0)
+	informer := informerFactory.Crd().V1alpha1().NetworkPolicyRecommendations()
+	c := NewNPRecommendationController(crdClient, kubefake.NewSimpleClientset(), informer, Quota{MaxConcurrentJobs: 1}, nil, nil)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+
+	for _, name := range []string{"pr-2", "pr-3"} {
+		require.NoError(t, c.syncNPRecommendation(types.NamespacedName{Namespace: "flow-visibility", Name: name}))
+	}
+	rejected, err := crdClient.CrdV1alpha1().NetworkPolicyRecommendations("flow-visibility").Get(context.TODO(), "pr-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, crdv1alpha1.NetworkPolicyRecommendationStatus{
+		State:     "FAILED",
+		ErrorCode: QuotaExceededErrorCode,
+		ErrorMsg:  "Quota exceeded: user alice already has 1 active jobs, which is the maximum number of concurrent jobs per user, please retry after one of them completes or delete it",
+	}, rejected.Status)
+	admitted, err := crdClient.CrdV1alpha1().NetworkPolicyRecommendations("flow-visibility").Get(context.TODO(), "pr-3", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "NEW", admitted.Status.State)
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+	"antrea.io/theia/pkg/util/sink"
+)
+
+const (
+	// ResultDeliveryErrorCode is the error code of COMPLETED jobs whose
+	// result could not be written to their sink.
+	ResultDeliveryErrorCode = "ResultDeliveryFailed"
+
+	resultDeliveryTimeout = 30 * time.Second
+)
+
+// sinkSchemes are the sink schemes supported by theia-manager. The sinks
+// writing to the local filesystem or to stdout are only useful in the CLI.
+var sinkSchemes = sets.NewString("configmap", "gs", "http", "https", "s3", "secret")
+
+// validateSink checks that a sink URI is supported by theia-manager.
+func validateSink(uri string) error {
+	u, err := sink.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if !sinkSchemes.Has(u.Scheme) {
+		return fmt.Errorf("sink scheme should be one of: %s", strings.Join(sinkSchemes.List(), ", "))
+	}
+	_, err = sink.New(uri, sink.Options{})
+	return err
+}
+
+// writeResult reads the result of a COMPLETED NetworkPolicyRecommendation from
+// ClickHouse, and writes it to its sink.
+func (c *NPRecommendationController) writeResult(npReco *crdv1alpha1.NetworkPolicyRecommendation) error {
+	if c.db == nil {
+		return fmt.Errorf("the result cannot be read because the connection to ClickHouse is not configured")
+	}
+	s, err := sink.New(npReco.Spec.Sink, sink.Options{KubeClient: c.kubeClient})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), resultDeliveryTimeout)
+	defer cancel()
+	id := string(npReco.UID)
+	yamls, err := policyrecommendation.QueryResult(ctx, c.db, id, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get recommendation result with id %s: %v", id, err)
+	}
+	if err := s.Write(ctx, &sink.Result{ID: id, Policies: []byte(yamls)}); err != nil {
+		return fmt.Errorf("failed to write recommendation result to sink %s: %v", s, err)
+	}
+	return nil
+}
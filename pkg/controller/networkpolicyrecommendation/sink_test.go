@@ -0,0 +1,105 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicyrecommendation
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/client/clientset/versioned/fake"
+	crdinformers "antrea.io/theia/pkg/client/informers/externalversions"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/sink"
+)
+
+func TestUpdateJobStatusWritesResult(t *testing.T) {
+	const policies = "apiVersion: crd.antrea.io/v1alpha1\nkind: ClusterNetworkPolicy\n"
+	testCases := []struct {
+		name              string
+		queryErr          error
+		expectedErrorCode string
+		expectedErrorMsg  string
+		expectedData      map[string]string
+	}{
+		{
+			name:         "result written",
+			expectedData: map[string]string{sink.PoliciesKey: policies},
+		},
+		{
+			name:              "result not found",
+			queryErr:          sql.ErrNoRows,
+			expectedErrorCode: ResultDeliveryErrorCode,
+			expectedErrorMsg:  "failed to get recommendation result with id pr-1: sql: no rows in result set",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			npReco := newJob("pr-1", "", testCreated, "RUNNING")
+			npReco.Spec.Backend = executor.K8sJobBackend
+			npReco.Spec.Sink = "configmap://flow-visibility/results"
+			crdClient := fake.NewSimpleClientset(npReco)
+			kubeClient := kubefake.NewSimpleClientset(&batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pr-pr-1",
+					Namespace: "flow-visibility",
+					Labels:    map[string]string{executor.RecommendationIDLabel: "pr-1"},
+				},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}},
+				},
+			})
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			query := mock.ExpectQuery(regexp.QuoteMeta("SELECT yamls FROM recommendations WHERE id = (?) ORDER BY namespace, part;")).WithArgs("pr-1")
+			if tc.queryErr != nil {
+				query.WillReturnError(tc.queryErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"yamls"}).AddRow(policies))
+			}
+			informerFactory := crdinformers.NewSharedInformerFactory(crdClient, 0)
+			informer := informerFactory.Crd().V1alpha1().NetworkPolicyRecommendations()
+			c := NewNPRecommendationController(crdClient, kubeClient, informer, Quota{}, nil, db)
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			informerFactory.Start(stopCh)
+			informerFactory.WaitForCacheSync(stopCh)
+
+			require.NoError(t, c.syncNPRecommendation(types.NamespacedName{Namespace: "flow-visibility", Name: "pr-1"}))
+			require.NoError(t, mock.ExpectationsWereMet())
+			npReco, err = crdClient.CrdV1alpha1().NetworkPolicyRecommendations("flow-visibility").Get(context.TODO(), "pr-1", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "COMPLETED", npReco.Status.State)
+			assert.Equal(t, tc.expectedErrorCode, npReco.Status.ErrorCode)
+			assert.Equal(t, tc.expectedErrorMsg, npReco.Status.ErrorMsg)
+			if tc.expectedData != nil {
+				configMap, err := kubeClient.CoreV1().ConfigMaps("flow-visibility").Get(context.TODO(), "results", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedData, configMap.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report renders periodic HTML reports of the traffic of the cluster,
+// e.g. the top talkers, the flows denied by network policies, the flow
+// coverage of each Namespace and the policy recommendations produced during
+// the period, and delivers them to sinks, e.g. an S3 bucket or an HTTP
+// endpoint, for the teams who do not look at the dashboards.
+package report
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/controller/flowcoverage"
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/sink"
+)
+
+const (
+	controllerName = "ReportController"
+	// queryClient identifies the report queries in the QueryGovernor.
+	queryClient = "report"
+	// DefaultInterval is the default period covered by the reports.
+	DefaultInterval = 7 * 24 * time.Hour
+	// DefaultTopTalkers is the default number of top talkers in the reports.
+	DefaultTopTalkers = 10
+	// reportTimeout bounds the time spent querying ClickHouse and delivering
+	// a report.
+	reportTimeout = time.Hour
+	// contentType is the media type of the reports.
+	contentType = "text/html; charset=utf-8"
+)
+
+// The recommendations table has a row per part and Namespace of the results.
+const recommendationsQuery = `
+SELECT
+	id,
+	any(type),
+	min(timeCreated) AS created
+FROM recommendations
+WHERE timeCreated >= ? AND timeCreated < ?
+GROUP BY id
+ORDER BY created`
+
+//go:embed report.html.tmpl
+var defaultTemplate string
+
+// Recommendation is a policy recommendation result created during the period
+// of a report.
+type Recommendation struct {
+	ID          string
+	Type        string
+	TimeCreated time.Time
+}
+
+// Report is the data rendered by the report templates.
+type Report struct {
+	Start time.Time
+	End   time.Time
+	// Flows and DeniedFlows summarize all the flows and the flows dropped or
+	// rejected by a network policy which ended during the period.
+	Flows       *flows.Summary
+	DeniedFlows *flows.Summary
+	// TopPods and TopNamespaces are the pairs of Pods and of Namespaces
+	// which exchanged the most bytes.
+	TopPods       []flows.Talker
+	TopNamespaces []flows.Talker
+	// Coverage is the flow coverage of each Namespace.
+	Coverage []flowcoverage.NamespaceCoverage
+	// Recommendations are the policy recommendation results created during
+	// the period.
+	Recommendations []Recommendation
+}
+
+// ID returns the ID of the report, derived from the end of its period.
+func (r *Report) ID() string {
+	return "theia-report-" + r.End.UTC().Format("2006-01-02")
+}
+
+var templateFuncs = template.FuncMap{
+	"bytes": formatBytes,
+	"percent": func(ratio float64) string {
+		return fmt.Sprintf("%.1f%%", ratio*100)
+	},
+}
+
+// ParseTemplate parses the html/template of the reports in file, or returns
+// the built-in template if file is empty.
+func ParseTemplate(file string) (*template.Template, error) {
+	text := defaultTemplate
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading the report template: %v", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("report").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error when parsing the report template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// formatBytes formats a number of bytes with a binary unit, e.g. 1.5 MiB.
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+type ReportController struct {
+	db         *sql.DB
+	backend    flows.Backend
+	governor   *clickhouse.QueryGovernor
+	sinks      []sink.Sink
+	template   *template.Template
+	interval   time.Duration
+	topTalkers int
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewReportController returns a controller delivering a report of each
+// interval to sinks. The report queries wait for their turn in governor, which
+// may be nil.
+func NewReportController(db *sql.DB, governor *clickhouse.QueryGovernor, sinks []sink.Sink, tmpl *template.Template, interval time.Duration, topTalkers int) *ReportController {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if topTalkers <= 0 {
+		topTalkers = DefaultTopTalkers
+	}
+	return &ReportController{
+		db:         db,
+		backend:    flows.NewBackend(db, flows.ClickHouse),
+		governor:   governor,
+		sinks:      sinks,
+		template:   tmpl,
+		interval:   interval,
+		topTalkers: topTalkers,
+		now:        time.Now,
+	}
+}
+
+// Run delivers a report at the end of each period until stopCh is closed.
+func (c *ReportController) Run(stopCh <-chan struct{}) {
+	klog.InfoS("Starting controller", "name", controllerName, "interval", c.interval)
+	defer klog.InfoS("Shutting down controller", "name", controllerName)
+	for {
+		end := c.nextEnd()
+		timer := time.NewTimer(end.Sub(c.now()))
+		select {
+		case <-stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := c.sync(end); err != nil {
+			klog.ErrorS(err, "Error when delivering the report", "end", end)
+		}
+	}
+}
+
+// nextEnd returns the end of the current period. Periods are aligned on
+// multiples of the interval since the zero time, which was a Monday at
+// midnight UTC, so that weekly reports cover Monday to Sunday and restarting
+// theia-manager does not deliver the same report twice.
+func (c *ReportController) nextEnd() time.Time {
+	return c.now().UTC().Truncate(c.interval).Add(c.interval)
+}
+
+// sync renders the report of the period ending at end and delivers it to all
+// the sinks.
+func (c *ReportController) sync(end time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	report, err := c.collect(ctx, end.Add(-c.interval), end)
+	if err != nil {
+		return err
+	}
+	var content bytes.Buffer
+	if err := c.template.Execute(&content, report); err != nil {
+		return fmt.Errorf("error when rendering the report: %v", err)
+	}
+	result := &sink.Result{
+		ID:          report.ID(),
+		Policies:    content.Bytes(),
+		Name:        report.ID() + ".html",
+		ContentType: contentType,
+	}
+	var errs []error
+	for _, s := range c.sinks {
+		if err := s.Write(ctx, result); err != nil {
+			errs = append(errs, fmt.Errorf("error when delivering the report to %s: %v", s, err))
+		}
+	}
+	if len(errs) == 0 {
+		klog.InfoS("Delivered the report", "id", result.ID, "sinks", len(c.sinks))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// collect queries the data of the report of the period from start to end.
+func (c *ReportController) collect(ctx context.Context, start, end time.Time) (*Report, error) {
+	release, err := c.governor.Acquire(ctx, queryClient)
+	if err != nil {
+		return nil, fmt.Errorf("error when waiting to query the report: %v", err)
+	}
+	defer release()
+	report := &Report{Start: start, End: end}
+	filter := flows.Filter{Start: start}
+	if report.Flows, err = c.backend.Summary(ctx, filter); err != nil {
+		return nil, err
+	}
+	if report.DeniedFlows, err = c.backend.Summary(ctx, flows.Filter{Start: start, Denied: true}); err != nil {
+		return nil, err
+	}
+	if report.TopPods, err = c.backend.Top(ctx, filter, flows.GroupByPod, c.topTalkers); err != nil {
+		return nil, err
+	}
+	if report.TopNamespaces, err = c.backend.Top(ctx, filter, flows.GroupByNamespace, c.topTalkers); err != nil {
+		return nil, err
+	}
+	if report.Coverage, err = flowcoverage.QueryCoverage(ctx, c.db, start, end); err != nil {
+		return nil, err
+	}
+	if report.Recommendations, err = c.queryRecommendations(ctx, start, end); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (c *ReportController) queryRecommendations(ctx context.Context, start, end time.Time) ([]Recommendation, error) {
+	rows, err := c.db.QueryContext(ctx, recommendationsQuery, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("error when querying the policy recommendations: %v", err)
+	}
+	defer rows.Close()
+	var recommendations []Recommendation
+	for rows.Next() {
+		var recommendation Recommendation
+		if err := rows.Scan(&recommendation.ID, &recommendation.Type, &recommendation.TimeCreated); err != nil {
+			return nil, fmt.Errorf("error when scanning the policy recommendations: %v", err)
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when querying the policy recommendations: %v", err)
+	}
+	return recommendations, nil
+}
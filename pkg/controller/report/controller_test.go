@@ -0,0 +1,129 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/controller/flowcoverage"
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/sink"
+)
+
+type fakeSink struct {
+	results []*sink.Result
+	err     error
+}
+
+func (s *fakeSink) Write(ctx context.Context, result *sink.Result) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.results = append(s.results, result)
+	return nil
+}
+
+func (s *fakeSink) String() string {
+	return "fake"
+}
+
+func TestNextEnd(t *testing.T) {
+	c := NewReportController(nil, nil, nil, nil, 0, 0)
+	c.now = func() time.Time { return time.Date(2022, 8, 3, 10, 30, 0, 0, time.UTC) }
+	assert.Equal(t, time.Date(2022, 8, 8, 0, 0, 0, 0, time.UTC), c.nextEnd())
+	c.interval = 24 * time.Hour
+	assert.Equal(t, time.Date(2022, 8, 4, 0, 0, 0, 0, time.UTC), c.nextEnd())
+}
+
+func TestSync(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	end := time.Date(2022, 8, 8, 0, 0, 0, 0, time.UTC)
+	start := end.Add(-DefaultInterval)
+	summaryColumns := []string{"flows", "bytes", "packets", "reverseBytes", "reversePackets", "firstFlowEnd", "lastFlowEnd"}
+	mock.ExpectQuery("FROM flows").WithArgs(start).
+		WillReturnRows(sqlmock.NewRows(summaryColumns).AddRow(uint64(120), uint64(3<<20), uint64(2000), uint64(1024), uint64(10), "2022-08-01 00:00:01", "2022-08-07 23:59:59"))
+	mock.ExpectQuery(regexp.QuoteMeta("RuleAction IN (2, 3)")).WithArgs(start).
+		WillReturnRows(sqlmock.NewRows(summaryColumns).AddRow(uint64(7), uint64(700), uint64(7), uint64(0), uint64(0), "2022-08-02 10:00:00", "2022-08-02 11:00:00"))
+	mock.ExpectQuery("sourcePodName").WithArgs(start).
+		WillReturnRows(sqlmock.NewRows([]string{"sns", "sn", "sip", "dns", "dn", "dip", "flows", "bytes", "packets"}).
+			AddRow("app", "frontend", "10.0.0.1", "app", "backend", "10.0.0.2", uint64(50), uint64(2<<20), uint64(900)))
+	mock.ExpectQuery("sourcePodNamespace").WithArgs(start).
+		WillReturnRows(sqlmock.NewRows([]string{"sns", "dns", "flows", "bytes", "packets"}).
+			AddRow("app", "", uint64(20), uint64(4096), uint64(40)))
+	mock.ExpectQuery("UNION ALL").WithArgs(start, end, start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"namespace", "flows", "coveredFlows"}).
+			AddRow("app", uint64(8), uint64(6)))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM recommendations")).WithArgs(start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "created"}).
+			AddRow("db2134ea-7169-46f8-b56d-d643d4751d1d", "initial", time.Date(2022, 8, 3, 9, 0, 0, 0, time.UTC)))
+
+	tmpl, err := ParseTemplate("")
+	require.NoError(t, err)
+	delivered := &fakeSink{}
+	failed := &fakeSink{err: fmt.Errorf("403 Forbidden")}
+	c := NewReportController(db, nil, []sink.Sink{failed, delivered}, tmpl, 0, 0)
+	err = c.sync(end)
+	assert.ErrorContains(t, err, "error when delivering the report to fake: 403 Forbidden")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, delivered.results, 1)
+	result := delivered.results[0]
+	assert.Equal(t, "theia-report-2022-08-08", result.ID)
+	assert.Equal(t, "theia-report-2022-08-08.html", result.Name)
+	assert.Equal(t, "text/html; charset=utf-8", result.ContentType)
+	html := string(result.Policies)
+	assert.Contains(t, html, "From 2022-08-01 00:00 to 2022-08-08 00:00 UTC.")
+	assert.Contains(t, html, `<tr><td>All flows</td><td class="number">120</td><td class="number">3.0 MiB</td><td class="number">1.0 KiB</td></tr>`)
+	assert.Contains(t, html, `<tr><td>Denied flows</td><td class="number">7</td><td class="number">700 B</td>`)
+	assert.Contains(t, html, `<tr><td>app/frontend</td><td>app/backend</td><td class="number">50</td><td class="number">2.0 MiB</td></tr>`)
+	assert.Contains(t, html, `<tr><td>app</td><td>external</td><td class="number">20</td><td class="number">4.0 KiB</td></tr>`)
+	assert.Contains(t, html, `<tr><td>app</td><td class="number">8</td><td class="number">6</td><td class="number">75.0%</td></tr>`)
+	assert.Contains(t, html, `<tr><td>db2134ea-7169-46f8-b56d-d643d4751d1d</td><td>initial</td><td>2022-08-03 09:00</td></tr>`)
+}
+
+func TestParseTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.html.tmpl")
+	require.NoError(t, os.WriteFile(file, []byte(`{{.ID}}: {{bytes .Flows.Bytes}} <b>{{range .Coverage}}{{.Namespace}}{{end}}</b>`), 0644))
+	tmpl, err := ParseTemplate(file)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	// html/template escapes the data, not the template.
+	require.NoError(t, tmpl.Execute(&out, &Report{
+		End:      time.Date(2022, 8, 8, 0, 0, 0, 0, time.UTC),
+		Flows:    &flows.Summary{Bytes: 1536},
+		Coverage: []flowcoverage.NamespaceCoverage{{Namespace: "<app>"}},
+	}))
+	assert.Equal(t, "theia-report-2022-08-08: 1.5 KiB <b>&lt;app&gt;</b>", out.String())
+
+	_, err = ParseTemplate(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(t, err, "error when reading the report template")
+	require.NoError(t, os.WriteFile(file, []byte(`{{.ID`), 0644))
+	_, err = ParseTemplate(file)
+	assert.ErrorContains(t, err, "error when parsing the report template")
+}
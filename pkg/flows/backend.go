@@ -0,0 +1,265 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flows queries the flow records stored by Theia, so that the flow
+// commands of the CLI work the same way whether the flows are stored in the
+// ClickHouse database deployed with Theia, in Snowflake, in BigQuery or in
+// PostgreSQL. The queries are shared by the backends, and adapted to their SQL
+// dialect by the templates embedded from the queries directory.
+package flows
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Filter selects the flows considered by the queries.
+type Filter struct {
+	// Start is the minimum end time of the flows.
+	Start time.Time
+	// Namespace selects the flows from or to this Namespace when it is not
+	// empty.
+	Namespace string
+	// Denied selects the flows dropped or rejected by a network policy.
+	Denied bool
+}
+
+// Summary aggregates the flows selected by a Filter.
+type Summary struct {
+	Flows          uint64
+	Bytes          uint64
+	Packets        uint64
+	ReverseBytes   uint64
+	ReversePackets uint64
+	// FirstFlowEnd and LastFlowEnd are the end times of the first and last
+	// flows, in the "YYYY-MM-DD hh:mm:ss" format. They are empty when no
+	// flows are selected.
+	FirstFlowEnd string
+	LastFlowEnd  string
+}
+
+// GroupBy selects the endpoints by which the flows are grouped to find the top
+// talkers.
+type GroupBy string
+
+const (
+	GroupByPod       GroupBy = "pod"
+	GroupByNamespace GroupBy = "namespace"
+	GroupByNode      GroupBy = "node"
+)
+
+// GroupBys are the supported GroupBy values.
+var GroupBys = []string{string(GroupByPod), string(GroupByNamespace), string(GroupByNode)}
+
+// groupByColumns are the columns identifying the source and the destination of
+// the flows for each GroupBy. The first non-empty column identifies the
+// endpoint, e.g. Pods are identified by their IP when the flow is to an
+// external destination.
+var groupByColumns = map[GroupBy][2][]string{
+	GroupByPod: {
+		{"sourcePodNamespace", "sourcePodName", "sourceIP"},
+		{"destinationPodNamespace", "destinationPodName", "destinationIP"},
+	},
+	GroupByNamespace: {{"sourcePodNamespace"}, {"destinationPodNamespace"}},
+	GroupByNode:      {{"sourceNodeName"}, {"destinationNodeName"}},
+}
+
+// ExternalEndpoint identifies the endpoints which are not in the cluster,
+// e.g. the destination Namespace of the flows to the Internet.
+const ExternalEndpoint = "external"
+
+// Talker is the traffic between a source and a destination.
+type Talker struct {
+	Source      string
+	Destination string
+	Flows       uint64
+	Bytes       uint64
+	Packets     uint64
+}
+
+// MatrixCell is the traffic from a source Namespace to a destination
+// Namespace. ExternalEndpoint is used for the endpoints outside the cluster.
+type MatrixCell struct {
+	SourceNamespace      string
+	DestinationNamespace string
+	Flows                uint64
+	Bytes                uint64
+}
+
+// Backend queries the flows stored in a database.
+type Backend interface {
+	Dialect() Dialect
+	// Summary aggregates the selected flows.
+	Summary(ctx context.Context, filter Filter) (*Summary, error)
+	// Top returns the limit pairs of endpoints which exchanged the most
+	// bytes, in descending order.
+	Top(ctx context.Context, filter Filter, groupBy GroupBy, limit int) ([]Talker, error)
+	// Matrix returns the traffic between each pair of Namespaces, ordered
+	// by source and destination.
+	Matrix(ctx context.Context, filter Filter) ([]MatrixCell, error)
+	// Export calls fn with the values of the given columns of each selected
+	// flow, as strings, ordered by end time. A limit of 0 means no limit.
+	// The values are only valid until fn returns.
+	Export(ctx context.Context, filter Filter, columns []string, limit int, fn func(values []string) error) error
+}
+
+// NewBackend returns a Backend querying the flows table of db.
+func NewBackend(db *sql.DB, dialect Dialect) Backend {
+	return &sqlBackend{db: db, dialect: dialect}
+}
+
+type sqlBackend struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func (b *sqlBackend) Dialect() Dialect {
+	return b.dialect
+}
+
+func (b *sqlBackend) queryError(err error) error {
+	return fmt.Errorf("failed to get flows from %s: %v", b.dialect.Name(), err)
+}
+
+func (b *sqlBackend) Summary(ctx context.Context, filter Filter) (*Summary, error) {
+	query, args, err := SummaryQuery(b.dialect, filter)
+	if err != nil {
+		return nil, err
+	}
+	var summary Summary
+	if err := b.db.QueryRowContext(ctx, query, args...).Scan(&summary.Flows, &summary.Bytes, &summary.Packets, &summary.ReverseBytes, &summary.ReversePackets, &summary.FirstFlowEnd, &summary.LastFlowEnd); err != nil {
+		return nil, b.queryError(err)
+	}
+	// ClickHouse returns the zero timestamp when no flows are selected.
+	if summary.Flows == 0 {
+		summary.FirstFlowEnd = ""
+		summary.LastFlowEnd = ""
+	}
+	return &summary, nil
+}
+
+func (b *sqlBackend) Top(ctx context.Context, filter Filter, groupBy GroupBy, limit int) ([]Talker, error) {
+	query, args, err := TopQuery(b.dialect, filter, groupBy, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, b.queryError(err)
+	}
+	defer rows.Close()
+	columns := groupByColumns[groupBy]
+	sourceValues := make([]string, len(columns[0]))
+	destinationValues := make([]string, len(columns[1]))
+	var talkers []Talker
+	for rows.Next() {
+		var talker Talker
+		dest := make([]interface{}, 0, len(sourceValues)+len(destinationValues)+3)
+		for i := range sourceValues {
+			dest = append(dest, &sourceValues[i])
+		}
+		for i := range destinationValues {
+			dest = append(dest, &destinationValues[i])
+		}
+		dest = append(dest, &talker.Flows, &talker.Bytes, &talker.Packets)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to parse the data returned by database: %v", err)
+		}
+		talker.Source = endpointName(groupBy, sourceValues)
+		talker.Destination = endpointName(groupBy, destinationValues)
+		talkers = append(talkers, talker)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, b.queryError(err)
+	}
+	return talkers, nil
+}
+
+// endpointName returns the name of an endpoint given the values of its
+// groupByColumns.
+func endpointName(groupBy GroupBy, values []string) string {
+	if groupBy == GroupByPod {
+		namespace, name, ip := values[0], values[1], values[2]
+		if name != "" {
+			return namespace + "/" + name
+		}
+		if ip != "" {
+			return ip
+		}
+	} else if values[0] != "" {
+		return values[0]
+	}
+	return ExternalEndpoint
+}
+
+func (b *sqlBackend) Matrix(ctx context.Context, filter Filter) ([]MatrixCell, error) {
+	query, args, err := MatrixQuery(b.dialect, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, b.queryError(err)
+	}
+	defer rows.Close()
+	var cells []MatrixCell
+	for rows.Next() {
+		var cell MatrixCell
+		if err := rows.Scan(&cell.SourceNamespace, &cell.DestinationNamespace, &cell.Flows, &cell.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to parse the data returned by database: %v", err)
+		}
+		if cell.SourceNamespace == "" {
+			cell.SourceNamespace = ExternalEndpoint
+		}
+		if cell.DestinationNamespace == "" {
+			cell.DestinationNamespace = ExternalEndpoint
+		}
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, b.queryError(err)
+	}
+	return cells, nil
+}
+
+func (b *sqlBackend) Export(ctx context.Context, filter Filter, columns []string, limit int, fn func(values []string) error) error {
+	query, args, err := ExportQuery(b.dialect, filter, columns, limit)
+	if err != nil {
+		return err
+	}
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return b.queryError(err)
+	}
+	defer rows.Close()
+	values := make([]string, len(columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to parse the data returned by database: %v", err)
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return b.queryError(err)
+	}
+	return nil
+}
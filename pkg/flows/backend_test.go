@@ -0,0 +1,119 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	summaryColumns := []string{"flows", "bytes", "packets", "reverseBytes", "reversePackets", "firstFlowEnd", "lastFlowEnd"}
+	testCases := []struct {
+		name     string
+		row      []driver.Value
+		expected Summary
+	}{
+		{
+			name: "flows",
+			row:  []driver.Value{uint64(3), uint64(3000), uint64(30), uint64(1500), uint64(15), "2022-08-01 12:01:00", "2022-08-01 12:05:00"},
+			expected: Summary{
+				Flows:          3,
+				Bytes:          3000,
+				Packets:        30,
+				ReverseBytes:   1500,
+				ReversePackets: 15,
+				FirstFlowEnd:   "2022-08-01 12:01:00",
+				LastFlowEnd:    "2022-08-01 12:05:00",
+			},
+		},
+		{
+			name:     "no flows",
+			row:      []driver.Value{uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), "1970-01-01 00:00:00", "1970-01-01 00:00:00"},
+			expected: Summary{},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*), COALESCE(SUM(octetDeltaCount), 0)")).
+				WithArgs(start).
+				WillReturnRows(sqlmock.NewRows(summaryColumns).AddRow(tt.row...))
+			summary, err := NewBackend(db, ClickHouse).Summary(context.TODO(), Filter{Start: start})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *summary)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTop(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY sourcePodNamespace, sourcePodName, sourceIP, destinationPodNamespace, destinationPodName, destinationIP ORDER BY bytes DESC LIMIT 2")).
+		WithArgs("2022-08-01T12:00:00Z").
+		WillReturnRows(sqlmock.NewRows([]string{"srcNs", "srcPod", "srcIP", "dstNs", "dstPod", "dstIP", "flows", "bytes", "packets"}).
+			AddRow("app-a", "frontend", "10.10.0.4", "app-b", "backend", "10.10.1.5", uint64(4), uint64(4000), uint64(40)).
+			AddRow("app-a", "frontend", "10.10.0.4", "", "", "8.8.8.8", uint64(2), uint64(200), uint64(2)))
+	talkers, err := NewBackend(db, Snowflake).Top(context.TODO(), Filter{Start: start}, GroupByPod, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Talker{
+		{Source: "app-a/frontend", Destination: "app-b/backend", Flows: 4, Bytes: 4000, Packets: 40},
+		{Source: "app-a/frontend", Destination: "8.8.8.8", Flows: 2, Bytes: 200, Packets: 2},
+	}, talkers)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = NewBackend(db, Snowflake).Top(context.TODO(), Filter{Start: start}, GroupBy("service"), 2)
+	assert.EqualError(t, err, `unsupported group by "service", supported values: [pod namespace node]`)
+}
+
+func TestMatrix(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT toString(sourcePodNamespace), toString(destinationPodNamespace), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, destinationPodNamespace")).
+		WithArgs(start, "app-a", "app-a").
+		WillReturnRows(sqlmock.NewRows([]string{"src", "dst", "flows", "bytes"}).
+			AddRow("app-a", "", uint64(1), uint64(100)).
+			AddRow("app-a", "app-b", uint64(2), uint64(2000)))
+	cells, err := NewBackend(db, ClickHouse).Matrix(context.TODO(), Filter{Start: start, Namespace: "app-a"})
+	require.NoError(t, err)
+	assert.Equal(t, []MatrixCell{
+		{SourceNamespace: "app-a", DestinationNamespace: ExternalEndpoint, Flows: 1, Bytes: 100},
+		{SourceNamespace: "app-a", DestinationNamespace: "app-b", Flows: 2, Bytes: 2000},
+	}, cells)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDialectFor(t *testing.T) {
+	dialect, err := DialectFor(SnowflakeBackend)
+	require.NoError(t, err)
+	assert.Equal(t, Snowflake, dialect)
+	_, err = DialectFor("mysql")
+	assert.EqualError(t, err, `unsupported flows backend "mysql", supported backends: [clickhouse snowflake bigquery postgres]`)
+}
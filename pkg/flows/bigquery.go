@@ -0,0 +1,104 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"database/sql"
+	"fmt"
+
+	"antrea.io/theia/pkg/util/gcp"
+)
+
+// BigQueryConfig is the configuration of the connection to a BigQuery dataset
+// created by "theia bigquery onboard".
+type BigQueryConfig struct {
+	Project  string
+	Dataset  string
+	Location string
+}
+
+// OpenBigQuery opens a connection to BigQuery. The requests are authenticated
+// with the token of the GOOGLE_OAUTH_ACCESS_TOKEN environment variable, or of
+// the active gcloud account.
+func OpenBigQuery(config BigQueryConfig) (*sql.DB, error) {
+	if config.Project == "" {
+		return nil, fmt.Errorf("the Google Cloud project is required with the %s backend", BigQueryBackend)
+	}
+	if config.Dataset == "" {
+		return nil, fmt.Errorf("the BigQuery dataset is required with the %s backend", BigQueryBackend)
+	}
+	client := gcp.NewClient(gcp.DefaultTokenSource())
+	return gcp.OpenBigQuery(client, config.Project, config.Dataset, config.Location), nil
+}
+
+// BigQueryFlowsTable is the flows table created in BigQuery. Its columns are
+// those of the flows table created by theia-sf in Snowflake, in the same
+// order, so that the CSV files uploaded by the Flow Aggregator can be loaded
+// in both. The table is partitioned by day, by flow end time, which is used by
+// all the queries.
+var BigQueryFlowsTable = &gcp.Table{
+	Name: "flows",
+	Fields: []gcp.Field{
+		{Name: "flowStartSeconds", Type: "TIMESTAMP"},
+		{Name: "flowEndSeconds", Type: "TIMESTAMP"},
+		{Name: "flowEndSecondsFromSourceNode", Type: "TIMESTAMP"},
+		{Name: "flowEndSecondsFromDestinationNode", Type: "TIMESTAMP"},
+		{Name: "flowEndReason", Type: "INT64"},
+		{Name: "sourceIP", Type: "STRING"},
+		{Name: "destinationIP", Type: "STRING"},
+		{Name: "sourceTransportPort", Type: "INT64"},
+		{Name: "destinationTransportPort", Type: "INT64"},
+		{Name: "protocolIdentifier", Type: "INT64"},
+		{Name: "packetTotalCount", Type: "INT64"},
+		{Name: "octetTotalCount", Type: "INT64"},
+		{Name: "packetDeltaCount", Type: "INT64"},
+		{Name: "octetDeltaCount", Type: "INT64"},
+		{Name: "reversePacketTotalCount", Type: "INT64"},
+		{Name: "reverseOctetTotalCount", Type: "INT64"},
+		{Name: "reversePacketDeltaCount", Type: "INT64"},
+		{Name: "reverseOctetDeltaCount", Type: "INT64"},
+		{Name: "sourcePodName", Type: "STRING"},
+		{Name: "sourcePodNamespace", Type: "STRING"},
+		{Name: "sourceNodeName", Type: "STRING"},
+		{Name: "destinationPodName", Type: "STRING"},
+		{Name: "destinationPodNamespace", Type: "STRING"},
+		{Name: "destinationNodeName", Type: "STRING"},
+		{Name: "destinationClusterIP", Type: "STRING"},
+		{Name: "destinationServicePort", Type: "INT64"},
+		{Name: "destinationServicePortName", Type: "STRING"},
+		{Name: "ingressNetworkPolicyName", Type: "STRING"},
+		{Name: "ingressNetworkPolicyNamespace", Type: "STRING"},
+		{Name: "ingressNetworkPolicyRuleName", Type: "STRING"},
+		{Name: "ingressNetworkPolicyRuleAction", Type: "INT64"},
+		{Name: "ingressNetworkPolicyType", Type: "INT64"},
+		{Name: "egressNetworkPolicyName", Type: "STRING"},
+		{Name: "egressNetworkPolicyNamespace", Type: "STRING"},
+		{Name: "egressNetworkPolicyRuleName", Type: "STRING"},
+		{Name: "egressNetworkPolicyRuleAction", Type: "INT64"},
+		{Name: "egressNetworkPolicyType", Type: "INT64"},
+		{Name: "tcpState", Type: "STRING"},
+		{Name: "flowType", Type: "INT64"},
+		{Name: "sourcePodLabels", Type: "STRING"},
+		{Name: "destinationPodLabels", Type: "STRING"},
+		{Name: "throughput", Type: "INT64"},
+		{Name: "reverseThroughput", Type: "INT64"},
+		{Name: "throughputFromSourceNode", Type: "INT64"},
+		{Name: "throughputFromDestinationNode", Type: "INT64"},
+		{Name: "reverseThroughputFromSourceNode", Type: "INT64"},
+		{Name: "reverseThroughputFromDestinationNode", Type: "INT64"},
+		{Name: "clusterUUID", Type: "STRING"},
+	},
+	PartitionField: "flowEndSeconds",
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Dialect adapts the flow queries, which are shared by all backends, to the
+// SQL dialect of the database storing the flows. Column names are the same in
+// all databases, as Snowflake and PostgreSQL unquoted identifiers are
+// case-insensitive.
+//
+// The SQL of a dialect is defined by queries/dialects/<name>.sql, which
+// defines the "string", "timestamp" and "timeParam" templates used by the
+// queries, and can redefine any of the queries when the shared one does not
+// work in the database.
+type Dialect interface {
+	// Name is the name of the backend, e.g. used in error messages.
+	Name() string
+	// TimeArg returns the argument of a timestamp parameter.
+	TimeArg(t time.Time) interface{}
+	// render renders the query template with the given name.
+	render(name string, data *queryData) (string, error)
+}
+
+const (
+	ClickHouseBackend = "clickhouse"
+	SnowflakeBackend  = "snowflake"
+	BigQueryBackend   = "bigquery"
+	PostgresBackend   = "postgres"
+)
+
+var (
+	// ClickHouse is the dialect of the ClickHouse database deployed with
+	// Theia.
+	ClickHouse Dialect = newTemplateDialect(ClickHouseBackend, func(t time.Time) interface{} {
+		return t
+	})
+	// Snowflake is the dialect of the Snowflake databases created by
+	// theia-sf. Timestamps are formatted, as they are parsed in Snowflake.
+	Snowflake Dialect = newTemplateDialect(SnowflakeBackend, snowflakeTimeArg)
+	// SnowflakeAllFlows is the dialect of the Snowflake databases created
+	// by theia-sf with the external flows table. The flows are read from
+	// the view over the flows table and the flow records in the bucket, so
+	// that the flows deleted from the table after the retention period are
+	// included.
+	SnowflakeAllFlows Dialect = newTemplateDialect(SnowflakeBackend, snowflakeTimeArg).withTable(snowflakeAllFlowsView)
+	// BigQuery is the dialect of the BigQuery datasets created by "theia
+	// bigquery onboard".
+	BigQuery Dialect = newTemplateDialect(BigQueryBackend, func(t time.Time) interface{} {
+		return t
+	})
+	// Postgres is the dialect of the PostgreSQL databases migrated by "theia
+	// postgres migrate", with or without TimescaleDB.
+	Postgres Dialect = newTemplateDialect(PostgresBackend, func(t time.Time) interface{} {
+		return t
+	})
+)
+
+// Backends are the names of the supported backends.
+var Backends = []string{ClickHouseBackend, SnowflakeBackend, BigQueryBackend, PostgresBackend}
+
+// DialectFor returns the dialect of the backend with the given name.
+func DialectFor(backend string) (Dialect, error) {
+	switch backend {
+	case ClickHouseBackend:
+		return ClickHouse, nil
+	case SnowflakeBackend:
+		return Snowflake, nil
+	case BigQueryBackend:
+		return BigQuery, nil
+	case PostgresBackend:
+		return Postgres, nil
+	}
+	return nil, fmt.Errorf("unsupported flows backend %q, supported backends: %v", backend, Backends)
+}
+
+func snowflakeTimeArg(t time.Time) interface{} {
+	return t.UTC().Format(time.RFC3339)
+}
+
+type templateDialect struct {
+	name      string
+	table     string
+	timeArg   func(t time.Time) interface{}
+	templates *template.Template
+}
+
+// newTemplateDialect returns the dialect defined by
+// queries/dialects/<name>.sql. It panics if the templates cannot be parsed, as
+// they are embedded in the binary.
+func newTemplateDialect(name string, timeArg func(t time.Time) interface{}) *templateDialect {
+	templates := template.Must(template.New(name).Funcs(queryFuncs).ParseFS(queries, queriesPattern))
+	templates = template.Must(templates.ParseFS(queries, fmt.Sprintf(dialectPattern, name)))
+	return &templateDialect{
+		name:      name,
+		table:     "flows",
+		timeArg:   timeArg,
+		templates: templates,
+	}
+}
+
+// withTable makes the queries read the flows from another table or view with
+// the same columns as the flows table.
+func (d *templateDialect) withTable(table string) *templateDialect {
+	d.table = table
+	return d
+}
+
+func (d *templateDialect) Name() string {
+	return d.name
+}
+
+func (d *templateDialect) TimeArg(t time.Time) interface{} {
+	return d.timeArg(t)
+}
+
+func (d *templateDialect) render(name string, data *queryData) (string, error) {
+	var query strings.Builder
+	data.Table = d.table
+	if err := d.templates.ExecuteTemplate(&query, name, data); err != nil {
+		return "", fmt.Errorf("failed to render the %s query for %s: %v", name, d.name, err)
+	}
+	// The templates are indented for readability, the queries are sent on a
+	// single line. String literals in the templates do not contain
+	// consecutive spaces.
+	return strings.Join(strings.Fields(query.String()), " "), nil
+}
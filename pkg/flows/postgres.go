@@ -0,0 +1,31 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"database/sql"
+	"fmt"
+
+	"antrea.io/theia/pkg/postgres"
+)
+
+// OpenPostgres opens a connection to the PostgreSQL database with the given
+// URL.
+func OpenPostgres(url string) (*sql.DB, error) {
+	if url == "" {
+		return nil, fmt.Errorf("the PostgreSQL URL is required with the %s backend", PostgresBackend)
+	}
+	return postgres.Open(url)
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// queries are the templates of the flow queries. The queries shared by all
+// dialects are in the queries directory, and each dialect is in the
+// queries/dialects directory.
+//
+//go:embed queries/*.sql queries/dialects/*.sql
+var queries embed.FS
+
+const (
+	queriesPattern = "queries/*.sql"
+	dialectPattern = "queries/dialects/%s.sql"
+)
+
+// timestampColumns are the columns of the flows table storing timestamps.
+var timestampColumns = map[string]bool{
+	"flowStartSeconds":                  true,
+	"flowEndSeconds":                    true,
+	"flowEndSecondsFromSourceNode":      true,
+	"flowEndSecondsFromDestinationNode": true,
+	"timeInserted":                      true,
+}
+
+// QueryColumns are the columns of the flows table used by the Summary, Top and
+// Matrix queries, and by the filters of all the queries. The columns of the
+// exports are selected by the caller.
+var QueryColumns = []string{
+	"flowEndSeconds",
+	"sourceIP",
+	"destinationIP",
+	"packetDeltaCount",
+	"octetDeltaCount",
+	"reversePacketDeltaCount",
+	"reverseOctetDeltaCount",
+	"sourcePodName",
+	"sourcePodNamespace",
+	"sourceNodeName",
+	"destinationPodName",
+	"destinationPodNamespace",
+	"destinationNodeName",
+	"ingressNetworkPolicyRuleAction",
+	"egressNetworkPolicyRuleAction",
+}
+
+// queryFuncs are the functions available in the templates.
+var queryFuncs = template.FuncMap{
+	"isTimestamp": func(column string) bool {
+		return timestampColumns[column]
+	},
+	"join": strings.Join,
+}
+
+// queryData is the data of the query templates.
+type queryData struct {
+	// Table is the table or view from which the flows are read, set by the
+	// dialect.
+	Table   string
+	Filter  Filter
+	Columns []string
+	// Limit is the maximum number of rows, 0 means no limit.
+	Limit int
+}
+
+// whereArgs returns the arguments of the "where" template.
+func whereArgs(dialect Dialect, filter Filter) []interface{} {
+	args := []interface{}{dialect.TimeArg(filter.Start)}
+	if filter.Namespace != "" {
+		args = append(args, filter.Namespace, filter.Namespace)
+	}
+	return args
+}
+
+// ExportQuery returns the query of Backend.Export.
+func ExportQuery(dialect Dialect, filter Filter, columns []string, limit int) (string, []interface{}, error) {
+	query, err := dialect.render("export", &queryData{Filter: filter, Columns: columns, Limit: limit})
+	if err != nil {
+		return "", nil, err
+	}
+	return query, whereArgs(dialect, filter), nil
+}
+
+// SummaryQuery returns the query of Backend.Summary.
+func SummaryQuery(dialect Dialect, filter Filter) (string, []interface{}, error) {
+	query, err := dialect.render("summary", &queryData{Filter: filter})
+	if err != nil {
+		return "", nil, err
+	}
+	return query, whereArgs(dialect, filter), nil
+}
+
+// TopQuery returns the query of Backend.Top.
+func TopQuery(dialect Dialect, filter Filter, groupBy GroupBy, limit int) (string, []interface{}, error) {
+	columns, ok := groupByColumns[groupBy]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported group by %q, supported values: %v", groupBy, GroupBys)
+	}
+	keys := append(append([]string{}, columns[0]...), columns[1]...)
+	query, err := dialect.render("top", &queryData{Filter: filter, Columns: keys, Limit: limit})
+	if err != nil {
+		return "", nil, err
+	}
+	return query, whereArgs(dialect, filter), nil
+}
+
+// MatrixQuery returns the query of Backend.Matrix.
+func MatrixQuery(dialect Dialect, filter Filter) (string, []interface{}, error) {
+	query, err := dialect.render("matrix", &queryData{Filter: filter})
+	if err != nil {
+		return "", nil, err
+	}
+	return query, whereArgs(dialect, filter), nil
+}
@@ -0,0 +1,9 @@
+{{- /* BigQuery datasets created by "theia bigquery onboard". */ -}}
+
+{{- /* NULL values, which ClickHouse does not store, are converted to empty strings. */ -}}
+{{define "string"}}IFNULL(CAST({{.}} AS STRING), ''){{end}}
+
+{{define "timestamp"}}IFNULL(FORMAT_TIMESTAMP('%Y-%m-%d %H:%M:%S', {{.}}, 'UTC'), ''){{end}}
+
+{{- /* time.Time arguments are bound as TIMESTAMP query parameters. */ -}}
+{{define "timeParam"}}?{{end}}
@@ -0,0 +1,11 @@
+{{- /* ClickHouse database deployed with Theia. */ -}}
+
+{{define "string"}}toString({{.}}){{end}}
+
+{{- /*
+The flows table stores timestamps as DateTime in UTC, which toString formats
+as "YYYY-MM-DD hh:mm:ss".
+*/ -}}
+{{define "timestamp"}}toString({{.}}){{end}}
+
+{{define "timeParam"}}?{{end}}
@@ -0,0 +1,9 @@
+{{- /* PostgreSQL databases migrated by "theia postgres migrate". */ -}}
+
+{{- /* NULL values, which ClickHouse does not store, are converted to empty strings. */ -}}
+{{define "string"}}COALESCE(CAST({{.}} AS TEXT), ''){{end}}
+
+{{define "timestamp"}}COALESCE(TO_CHAR({{.}} AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'), ''){{end}}
+
+{{- /* The "?" placeholders are rebound to "$n" by the connections of postgres.Open. */ -}}
+{{define "timeParam"}}?{{end}}
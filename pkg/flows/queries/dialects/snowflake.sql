@@ -0,0 +1,13 @@
+{{- /* Snowflake databases created by theia-sf. */ -}}
+
+{{- /* NULL values, which ClickHouse does not store, are converted to empty strings. */ -}}
+{{define "string"}}COALESCE(TO_VARCHAR({{.}}), ''){{end}}
+
+{{define "timestamp"}}COALESCE(TO_VARCHAR(CONVERT_TIMEZONE('UTC', {{.}}), 'YYYY-MM-DD HH24:MI:SS'), ''){{end}}
+
+{{- /*
+The timestamp is parsed in Snowflake, as the driver binds time.Time arguments
+as TIMESTAMP_NTZ, which would be compared to the TIMESTAMP_TZ columns of the
+flows table in the time zone of the session.
+*/ -}}
+{{define "timeParam"}}TO_TIMESTAMP_TZ(?){{end}}
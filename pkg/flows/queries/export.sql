@@ -0,0 +1,13 @@
+{{- /* export selects the Columns of the flows, as strings. */ -}}
+{{define "export"}}
+SELECT
+{{- range $i, $column := .Columns}}{{if $i}},{{end}}
+  {{if isTimestamp $column}}{{template "timestamp" $column}}{{else}}{{template "string" $column}}{{end}} AS {{$column}}
+{{- end}}
+FROM {{.Table}}
+{{template "where" .}}
+ORDER BY flowEndSeconds
+{{- if .Limit}}
+LIMIT {{.Limit}}
+{{- end}}
+{{end}}
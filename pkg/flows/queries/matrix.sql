@@ -0,0 +1,12 @@
+{{- /* matrix aggregates the flows by source and destination Namespace. */ -}}
+{{define "matrix"}}
+SELECT
+  {{template "string" "sourcePodNamespace"}},
+  {{template "string" "destinationPodNamespace"}},
+  COUNT(*),
+  COALESCE(SUM(octetDeltaCount), 0)
+FROM {{.Table}}
+{{template "where" .}}
+GROUP BY sourcePodNamespace, destinationPodNamespace
+ORDER BY sourcePodNamespace, destinationPodNamespace
+{{end}}
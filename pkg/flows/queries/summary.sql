@@ -0,0 +1,13 @@
+{{- /* summary aggregates the flows into a single row. */ -}}
+{{define "summary"}}
+SELECT
+  COUNT(*),
+  COALESCE(SUM(octetDeltaCount), 0),
+  COALESCE(SUM(packetDeltaCount), 0),
+  COALESCE(SUM(reverseOctetDeltaCount), 0),
+  COALESCE(SUM(reversePacketDeltaCount), 0),
+  {{template "timestamp" "MIN(flowEndSeconds)"}},
+  {{template "timestamp" "MAX(flowEndSeconds)"}}
+FROM {{.Table}}
+{{template "where" .}}
+{{end}}
@@ -0,0 +1,20 @@
+{{- /*
+top groups the flows by the Columns identifying their source and destination,
+and returns the groups which exchanged the most bytes first.
+*/ -}}
+{{define "top"}}
+SELECT
+{{- range .Columns}}
+  {{template "string" .}},
+{{- end}}
+  COUNT(*),
+  COALESCE(SUM(octetDeltaCount), 0) AS bytes,
+  COALESCE(SUM(packetDeltaCount), 0)
+FROM {{.Table}}
+{{template "where" .}}
+GROUP BY {{join .Columns ", "}}
+ORDER BY bytes DESC
+{{- if .Limit}}
+LIMIT {{.Limit}}
+{{- end}}
+{{end}}
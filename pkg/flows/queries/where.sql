@@ -0,0 +1,14 @@
+{{- /*
+where selects the flows of the Filter. The arguments of its placeholders are
+added in this order by the Go code, so dialects overriding it must keep them.
+*/ -}}
+{{define "where"}}
+WHERE flowEndSeconds >= {{template "timeParam"}}
+{{- if .Filter.Namespace}}
+  AND (sourcePodNamespace = ? OR destinationPodNamespace = ?)
+{{- end}}
+{{- if .Filter.Denied}}
+  {{- /* 2 and 3 are the Drop and Reject rule actions. */}}
+  AND (ingressNetworkPolicyRuleAction IN (2, 3) OR egressNetworkPolicyRuleAction IN (2, 3))
+{{- end}}
+{{end}}
@@ -0,0 +1,154 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the queries in testdata")
+
+// TestQueries compares the queries rendered for each dialect with the golden
+// files in testdata/queries/<dialect>. After changing the templates, or to add
+// a dialect, run "go test ./pkg/flows -run TestQueries -update" and review the
+// changes of the golden files.
+func TestQueries(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	filter := Filter{Start: start}
+	namespaceFilter := Filter{Start: start, Namespace: "app-a"}
+	deniedFilter := Filter{Start: start, Denied: true}
+	testCases := []struct {
+		name  string
+		query func(dialect Dialect) (string, []interface{}, error)
+	}{
+		{
+			name: "export",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return ExportQuery(dialect, filter, []string{"flowStartSeconds", "sourceIP", "octetDeltaCount"}, 0)
+			},
+		},
+		{
+			name: "export-namespace-limit",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return ExportQuery(dialect, namespaceFilter, []string{"flowStartSeconds", "sourceIP"}, 10)
+			},
+		},
+		{
+			name: "export-denied",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return ExportQuery(dialect, deniedFilter, []string{"flowEndSeconds", "sourceIP"}, 0)
+			},
+		},
+		{
+			name: "summary",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return SummaryQuery(dialect, filter)
+			},
+		},
+		{
+			name: "top-pod",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return TopQuery(dialect, namespaceFilter, GroupByPod, 10)
+			},
+		},
+		{
+			name: "top-namespace",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return TopQuery(dialect, filter, GroupByNamespace, 0)
+			},
+		},
+		{
+			name: "top-node",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return TopQuery(dialect, filter, GroupByNode, 5)
+			},
+		},
+		{
+			name: "matrix",
+			query: func(dialect Dialect) (string, []interface{}, error) {
+				return MatrixQuery(dialect, namespaceFilter)
+			},
+		},
+	}
+	for _, backend := range Backends {
+		dialect, err := DialectFor(backend)
+		require.NoError(t, err)
+		for _, tt := range testCases {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				query, _, err := tt.query(dialect)
+				require.NoError(t, err)
+				path := filepath.Join("testdata", "queries", backend, tt.name+".sql")
+				if *update {
+					require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+					require.NoError(t, os.WriteFile(path, []byte(query+"\n"), 0644))
+				}
+				expected, err := os.ReadFile(path)
+				require.NoError(t, err, "run the test with -update to create the golden file")
+				assert.Equal(t, string(expected), query+"\n")
+			})
+		}
+	}
+}
+
+func TestQueryArgs(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	_, args, err := ExportQuery(ClickHouse, Filter{Start: start}, []string{"sourceIP"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{start}, args)
+
+	_, args, err = MatrixQuery(ClickHouse, Filter{Start: start, Namespace: "app-a"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{start, "app-a", "app-a"}, args)
+
+	_, args, err = SummaryQuery(Snowflake, Filter{Start: start, Namespace: "app-a"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"2022-08-01T12:00:00Z", "app-a", "app-a"}, args)
+}
+
+func TestSnowflakeAllFlowsQueries(t *testing.T) {
+	filter := Filter{Start: time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC), Namespace: "app-a"}
+	for name, query := range map[string]func(dialect Dialect) (string, []interface{}, error){
+		"export": func(dialect Dialect) (string, []interface{}, error) {
+			return ExportQuery(dialect, filter, []string{"flowStartSeconds", "sourceIP"}, 10)
+		},
+		"summary": func(dialect Dialect) (string, []interface{}, error) {
+			return SummaryQuery(dialect, filter)
+		},
+		"top": func(dialect Dialect) (string, []interface{}, error) {
+			return TopQuery(dialect, filter, GroupByPod, 10)
+		},
+		"matrix": func(dialect Dialect) (string, []interface{}, error) {
+			return MatrixQuery(dialect, filter)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			flowsQuery, flowsArgs, err := query(Snowflake)
+			require.NoError(t, err)
+			allFlowsQuery, allFlowsArgs, err := query(SnowflakeAllFlows)
+			require.NoError(t, err)
+			assert.Contains(t, flowsQuery, " FROM flows WHERE ")
+			assert.Equal(t, strings.Replace(flowsQuery, " FROM flows WHERE ", " FROM flows_all WHERE ", 1), allFlowsQuery)
+			assert.Equal(t, flowsArgs, allFlowsArgs)
+		})
+	}
+}
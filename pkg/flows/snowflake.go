@@ -0,0 +1,89 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// snowflakeAllFlowsView is the view created by theia-sf over the flows table
+// and the external table reading the flow records in the bucket.
+const snowflakeAllFlowsView = "flows_all"
+
+// SnowflakeConfig is the configuration of the connection to a Snowflake
+// database created by theia-sf.
+type SnowflakeConfig struct {
+	Database string
+	Schema   string
+	// Warehouse defaults to the query warehouse created by theia-sf for the
+	// database.
+	Warehouse string
+}
+
+// OpenSnowflake opens a connection to Snowflake. Like with theia-sf, the
+// credentials are read from the SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER and
+// SNOWFLAKE_PASSWORD environment variables.
+func OpenSnowflake(config SnowflakeConfig) (*sql.DB, error) {
+	if config.Database == "" {
+		return nil, fmt.Errorf("the Snowflake database is required with the %s backend", SnowflakeBackend)
+	}
+	cfg := &sf.Config{
+		Database:  config.Database,
+		Schema:    config.Schema,
+		Warehouse: config.Warehouse,
+	}
+	if cfg.Warehouse == "" {
+		cfg.Warehouse = config.Database + "_QUERY_WH"
+	}
+	for _, env := range []struct {
+		name  string
+		value *string
+	}{
+		{"SNOWFLAKE_ACCOUNT", &cfg.Account},
+		{"SNOWFLAKE_USER", &cfg.User},
+		{"SNOWFLAKE_PASSWORD", &cfg.Password},
+	} {
+		*env.value = os.Getenv(env.name)
+		if *env.value == "" {
+			return nil, fmt.Errorf("%s environment variable is not set", env.name)
+		}
+	}
+	dsn, err := sf.DSN(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error when building the Snowflake DSN: %v", err)
+	}
+	return sql.Open("snowflake", dsn)
+}
+
+// SnowflakeDialectFor returns the dialect reading the view over the hot and
+// cold flows when it was created by theia-sf (with "onboard --cold-flows"),
+// and the dialect reading the flows table otherwise.
+func SnowflakeDialectFor(ctx context.Context, db *sql.DB, config SnowflakeConfig) (Dialect, error) {
+	query := "SELECT COUNT(*) FROM INFORMATION_SCHEMA.VIEWS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	var count int
+	if err := db.QueryRowContext(ctx, query, strings.ToUpper(config.Schema), strings.ToUpper(snowflakeAllFlowsView)).Scan(&count); err != nil {
+		return nil, fmt.Errorf("error when looking for the %s view in Snowflake: %v", snowflakeAllFlowsView, err)
+	}
+	if count == 0 {
+		return Snowflake, nil
+	}
+	return SnowflakeAllFlows, nil
+}
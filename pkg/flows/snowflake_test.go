@@ -0,0 +1,73 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnowflakeDialectFor(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT COUNT(*) FROM INFORMATION_SCHEMA.VIEWS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?")
+	for _, tt := range []struct {
+		name            string
+		count           int
+		err             error
+		expectedDialect Dialect
+		expectedErr     string
+	}{
+		{
+			name:            "without cold flows",
+			count:           0,
+			expectedDialect: Snowflake,
+		},
+		{
+			name:            "with cold flows",
+			count:           1,
+			expectedDialect: SnowflakeAllFlows,
+		},
+		{
+			name:        "query error",
+			err:         errors.New("warehouse does not exist"),
+			expectedErr: "error when looking for the flows_all view in Snowflake: warehouse does not exist",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			expectedQuery := mock.ExpectQuery(query).WithArgs("THEIA", "FLOWS_ALL")
+			if tt.err != nil {
+				expectedQuery.WillReturnError(tt.err)
+			} else {
+				expectedQuery.WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.count))
+			}
+			dialect, err := SnowflakeDialectFor(context.Background(), db, SnowflakeConfig{Database: "ANTREA_E4Y7TBQ9", Schema: "theia"})
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedDialect, dialect)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
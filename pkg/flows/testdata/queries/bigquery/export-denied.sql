@@ -0,0 +1 @@
+SELECT IFNULL(FORMAT_TIMESTAMP('%Y-%m-%d %H:%M:%S', flowEndSeconds, 'UTC'), '') AS flowEndSeconds, IFNULL(CAST(sourceIP AS STRING), '') AS sourceIP FROM flows WHERE flowEndSeconds >= ? AND (ingressNetworkPolicyRuleAction IN (2, 3) OR egressNetworkPolicyRuleAction IN (2, 3)) ORDER BY flowEndSeconds
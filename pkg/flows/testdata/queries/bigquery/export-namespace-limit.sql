@@ -0,0 +1 @@
+SELECT IFNULL(FORMAT_TIMESTAMP('%Y-%m-%d %H:%M:%S', flowStartSeconds, 'UTC'), '') AS flowStartSeconds, IFNULL(CAST(sourceIP AS STRING), '') AS sourceIP FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) ORDER BY flowEndSeconds LIMIT 10
@@ -0,0 +1 @@
+SELECT IFNULL(FORMAT_TIMESTAMP('%Y-%m-%d %H:%M:%S', flowStartSeconds, 'UTC'), '') AS flowStartSeconds, IFNULL(CAST(sourceIP AS STRING), '') AS sourceIP, IFNULL(CAST(octetDeltaCount AS STRING), '') AS octetDeltaCount FROM flows WHERE flowEndSeconds >= ? ORDER BY flowEndSeconds
@@ -0,0 +1 @@
+SELECT IFNULL(CAST(sourcePodNamespace AS STRING), ''), IFNULL(CAST(destinationPodNamespace AS STRING), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, destinationPodNamespace ORDER BY sourcePodNamespace, destinationPodNamespace
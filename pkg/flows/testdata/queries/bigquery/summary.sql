@@ -0,0 +1 @@
+SELECT COUNT(*), COALESCE(SUM(octetDeltaCount), 0), COALESCE(SUM(packetDeltaCount), 0), COALESCE(SUM(reverseOctetDeltaCount), 0), COALESCE(SUM(reversePacketDeltaCount), 0), IFNULL(FORMAT_TIMESTAMP('%Y-%m-%d %H:%M:%S', MIN(flowEndSeconds), 'UTC'), ''), IFNULL(FORMAT_TIMESTAMP('%Y-%m-%d %H:%M:%S', MAX(flowEndSeconds), 'UTC'), '') FROM flows WHERE flowEndSeconds >= ?
@@ -0,0 +1 @@
+SELECT IFNULL(CAST(sourcePodNamespace AS STRING), ''), IFNULL(CAST(destinationPodNamespace AS STRING), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? GROUP BY sourcePodNamespace, destinationPodNamespace ORDER BY bytes DESC
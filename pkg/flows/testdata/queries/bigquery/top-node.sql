@@ -0,0 +1 @@
+SELECT IFNULL(CAST(sourceNodeName AS STRING), ''), IFNULL(CAST(destinationNodeName AS STRING), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? GROUP BY sourceNodeName, destinationNodeName ORDER BY bytes DESC LIMIT 5
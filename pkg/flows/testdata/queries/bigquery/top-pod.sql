@@ -0,0 +1 @@
+SELECT IFNULL(CAST(sourcePodNamespace AS STRING), ''), IFNULL(CAST(sourcePodName AS STRING), ''), IFNULL(CAST(sourceIP AS STRING), ''), IFNULL(CAST(destinationPodNamespace AS STRING), ''), IFNULL(CAST(destinationPodName AS STRING), ''), IFNULL(CAST(destinationIP AS STRING), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, sourcePodName, sourceIP, destinationPodNamespace, destinationPodName, destinationIP ORDER BY bytes DESC LIMIT 10
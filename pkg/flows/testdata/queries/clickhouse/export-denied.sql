@@ -0,0 +1 @@
+SELECT toString(flowEndSeconds) AS flowEndSeconds, toString(sourceIP) AS sourceIP FROM flows WHERE flowEndSeconds >= ? AND (ingressNetworkPolicyRuleAction IN (2, 3) OR egressNetworkPolicyRuleAction IN (2, 3)) ORDER BY flowEndSeconds
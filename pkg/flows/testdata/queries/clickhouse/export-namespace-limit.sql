@@ -0,0 +1 @@
+SELECT toString(flowStartSeconds) AS flowStartSeconds, toString(sourceIP) AS sourceIP FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) ORDER BY flowEndSeconds LIMIT 10
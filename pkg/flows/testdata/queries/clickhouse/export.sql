@@ -0,0 +1 @@
+SELECT toString(flowStartSeconds) AS flowStartSeconds, toString(sourceIP) AS sourceIP, toString(octetDeltaCount) AS octetDeltaCount FROM flows WHERE flowEndSeconds >= ? ORDER BY flowEndSeconds
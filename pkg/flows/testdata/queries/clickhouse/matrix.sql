@@ -0,0 +1 @@
+SELECT toString(sourcePodNamespace), toString(destinationPodNamespace), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, destinationPodNamespace ORDER BY sourcePodNamespace, destinationPodNamespace
@@ -0,0 +1 @@
+SELECT COUNT(*), COALESCE(SUM(octetDeltaCount), 0), COALESCE(SUM(packetDeltaCount), 0), COALESCE(SUM(reverseOctetDeltaCount), 0), COALESCE(SUM(reversePacketDeltaCount), 0), toString(MIN(flowEndSeconds)), toString(MAX(flowEndSeconds)) FROM flows WHERE flowEndSeconds >= ?
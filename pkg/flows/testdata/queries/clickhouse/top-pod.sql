@@ -0,0 +1 @@
+SELECT toString(sourcePodNamespace), toString(sourcePodName), toString(sourceIP), toString(destinationPodNamespace), toString(destinationPodName), toString(destinationIP), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, sourcePodName, sourceIP, destinationPodNamespace, destinationPodName, destinationIP ORDER BY bytes DESC LIMIT 10
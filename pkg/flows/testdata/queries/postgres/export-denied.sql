@@ -0,0 +1 @@
+SELECT COALESCE(TO_CHAR(flowEndSeconds AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'), '') AS flowEndSeconds, COALESCE(CAST(sourceIP AS TEXT), '') AS sourceIP FROM flows WHERE flowEndSeconds >= ? AND (ingressNetworkPolicyRuleAction IN (2, 3) OR egressNetworkPolicyRuleAction IN (2, 3)) ORDER BY flowEndSeconds
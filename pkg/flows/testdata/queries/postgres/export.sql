@@ -0,0 +1 @@
+SELECT COALESCE(TO_CHAR(flowStartSeconds AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'), '') AS flowStartSeconds, COALESCE(CAST(sourceIP AS TEXT), '') AS sourceIP, COALESCE(CAST(octetDeltaCount AS TEXT), '') AS octetDeltaCount FROM flows WHERE flowEndSeconds >= ? ORDER BY flowEndSeconds
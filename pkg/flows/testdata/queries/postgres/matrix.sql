@@ -0,0 +1 @@
+SELECT COALESCE(CAST(sourcePodNamespace AS TEXT), ''), COALESCE(CAST(destinationPodNamespace AS TEXT), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, destinationPodNamespace ORDER BY sourcePodNamespace, destinationPodNamespace
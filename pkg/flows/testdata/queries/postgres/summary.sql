@@ -0,0 +1 @@
+SELECT COUNT(*), COALESCE(SUM(octetDeltaCount), 0), COALESCE(SUM(packetDeltaCount), 0), COALESCE(SUM(reverseOctetDeltaCount), 0), COALESCE(SUM(reversePacketDeltaCount), 0), COALESCE(TO_CHAR(MIN(flowEndSeconds) AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'), ''), COALESCE(TO_CHAR(MAX(flowEndSeconds) AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'), '') FROM flows WHERE flowEndSeconds >= ?
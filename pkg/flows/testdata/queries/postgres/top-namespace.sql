@@ -0,0 +1 @@
+SELECT COALESCE(CAST(sourcePodNamespace AS TEXT), ''), COALESCE(CAST(destinationPodNamespace AS TEXT), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? GROUP BY sourcePodNamespace, destinationPodNamespace ORDER BY bytes DESC
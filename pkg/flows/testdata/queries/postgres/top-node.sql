@@ -0,0 +1 @@
+SELECT COALESCE(CAST(sourceNodeName AS TEXT), ''), COALESCE(CAST(destinationNodeName AS TEXT), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? GROUP BY sourceNodeName, destinationNodeName ORDER BY bytes DESC LIMIT 5
@@ -0,0 +1 @@
+SELECT COALESCE(CAST(sourcePodNamespace AS TEXT), ''), COALESCE(CAST(sourcePodName AS TEXT), ''), COALESCE(CAST(sourceIP AS TEXT), ''), COALESCE(CAST(destinationPodNamespace AS TEXT), ''), COALESCE(CAST(destinationPodName AS TEXT), ''), COALESCE(CAST(destinationIP AS TEXT), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, sourcePodName, sourceIP, destinationPodNamespace, destinationPodName, destinationIP ORDER BY bytes DESC LIMIT 10
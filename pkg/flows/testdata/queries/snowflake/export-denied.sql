@@ -0,0 +1 @@
+SELECT COALESCE(TO_VARCHAR(CONVERT_TIMEZONE('UTC', flowEndSeconds), 'YYYY-MM-DD HH24:MI:SS'), '') AS flowEndSeconds, COALESCE(TO_VARCHAR(sourceIP), '') AS sourceIP FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?) AND (ingressNetworkPolicyRuleAction IN (2, 3) OR egressNetworkPolicyRuleAction IN (2, 3)) ORDER BY flowEndSeconds
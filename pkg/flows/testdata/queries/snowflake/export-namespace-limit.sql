@@ -0,0 +1 @@
+SELECT COALESCE(TO_VARCHAR(CONVERT_TIMEZONE('UTC', flowStartSeconds), 'YYYY-MM-DD HH24:MI:SS'), '') AS flowStartSeconds, COALESCE(TO_VARCHAR(sourceIP), '') AS sourceIP FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?) AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) ORDER BY flowEndSeconds LIMIT 10
@@ -0,0 +1 @@
+SELECT COALESCE(TO_VARCHAR(CONVERT_TIMEZONE('UTC', flowStartSeconds), 'YYYY-MM-DD HH24:MI:SS'), '') AS flowStartSeconds, COALESCE(TO_VARCHAR(sourceIP), '') AS sourceIP, COALESCE(TO_VARCHAR(octetDeltaCount), '') AS octetDeltaCount FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?) ORDER BY flowEndSeconds
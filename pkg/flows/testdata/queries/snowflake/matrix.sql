@@ -0,0 +1 @@
+SELECT COALESCE(TO_VARCHAR(sourcePodNamespace), ''), COALESCE(TO_VARCHAR(destinationPodNamespace), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?) AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, destinationPodNamespace ORDER BY sourcePodNamespace, destinationPodNamespace
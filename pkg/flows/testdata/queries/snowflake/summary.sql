@@ -0,0 +1 @@
+SELECT COUNT(*), COALESCE(SUM(octetDeltaCount), 0), COALESCE(SUM(packetDeltaCount), 0), COALESCE(SUM(reverseOctetDeltaCount), 0), COALESCE(SUM(reversePacketDeltaCount), 0), COALESCE(TO_VARCHAR(CONVERT_TIMEZONE('UTC', MIN(flowEndSeconds)), 'YYYY-MM-DD HH24:MI:SS'), ''), COALESCE(TO_VARCHAR(CONVERT_TIMEZONE('UTC', MAX(flowEndSeconds)), 'YYYY-MM-DD HH24:MI:SS'), '') FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?)
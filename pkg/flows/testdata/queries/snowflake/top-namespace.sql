@@ -0,0 +1 @@
+SELECT COALESCE(TO_VARCHAR(sourcePodNamespace), ''), COALESCE(TO_VARCHAR(destinationPodNamespace), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?) GROUP BY sourcePodNamespace, destinationPodNamespace ORDER BY bytes DESC
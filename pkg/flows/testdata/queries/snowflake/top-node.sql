@@ -0,0 +1 @@
+SELECT COALESCE(TO_VARCHAR(sourceNodeName), ''), COALESCE(TO_VARCHAR(destinationNodeName), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?) GROUP BY sourceNodeName, destinationNodeName ORDER BY bytes DESC LIMIT 5
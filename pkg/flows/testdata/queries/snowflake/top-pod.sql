@@ -0,0 +1 @@
+SELECT COALESCE(TO_VARCHAR(sourcePodNamespace), ''), COALESCE(TO_VARCHAR(sourcePodName), ''), COALESCE(TO_VARCHAR(sourceIP), ''), COALESCE(TO_VARCHAR(destinationPodNamespace), ''), COALESCE(TO_VARCHAR(destinationPodName), ''), COALESCE(TO_VARCHAR(destinationIP), ''), COUNT(*), COALESCE(SUM(octetDeltaCount), 0) AS bytes, COALESCE(SUM(packetDeltaCount), 0) FROM flows WHERE flowEndSeconds >= TO_TIMESTAMP_TZ(?) AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) GROUP BY sourcePodNamespace, sourcePodName, sourceIP, destinationPodNamespace, destinationPodName, destinationIP ORDER BY bytes DESC LIMIT 10
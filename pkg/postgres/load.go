@@ -0,0 +1,172 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v2"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// LoadFlows loads the flow records of r, in the CSV format of the files
+// uploaded by the S3 uploader of the Flow Aggregator, into the flows table,
+// and returns the number of loaded records. Records with fewer fields than
+// FlowsTableColumns, e.g. written by an older Flow Aggregator, are loaded with
+// NULL values for the missing columns. The records are loaded in a single
+// transaction, so that a file is either loaded completely or not at all.
+func LoadFlows(ctx context.Context, db *sql.DB, r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// The timestamps of the records are in UTC.
+	if _, err := tx.ExecContext(ctx, "SET LOCAL TIME ZONE 'UTC'"); err != nil {
+		return 0, err
+	}
+	// Identifiers are quoted by CopyIn, and unquoted identifiers are folded
+	// to lower case by PostgreSQL when the table is created.
+	columns := make([]string, len(FlowsTableColumns))
+	for i, column := range FlowsTableColumns {
+		columns[i] = strings.ToLower(column.Name)
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("flows", columns...))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	values := make([]interface{}, len(FlowsTableColumns))
+	count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse the flow records: %v", err)
+		}
+		if len(record) > len(FlowsTableColumns) {
+			return 0, fmt.Errorf("flow record %d has %d fields, but the flows table only has %d columns", count+1, len(record), len(FlowsTableColumns))
+		}
+		for i, column := range FlowsTableColumns {
+			values[i] = nil
+			// Empty fields are empty strings in text columns, and
+			// NULL values in the other ones.
+			if i < len(record) && (record[i] != "" || column.Type == "TEXT") {
+				values[i] = record[i]
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return 0, fmt.Errorf("failed to load flow record %d: %v", count+1, err)
+		}
+		count++
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, fmt.Errorf("failed to load the flow records: %v", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// resultPart is the part of a policy recommendation result with the policies
+// of a Namespace, the cluster-scoped ones having an empty Namespace.
+type resultPart struct {
+	namespace string
+	yamls     string
+}
+
+// splitResult splits the recommended policies by Namespace, like the policy
+// recommendation job does when it writes its result, so that the result can
+// be read by policyrecommendation.QueryResult.
+func splitResult(yamls string) ([]resultPart, error) {
+	docs := make(map[string][]string)
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(strings.NewReader(yamls)))
+	for i := 1; ; i++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error when parsing the recommended policies: %v", err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		var policy struct {
+			Metadata struct {
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal(doc, &policy); err != nil {
+			return nil, fmt.Errorf("error when parsing the recommended policies: document %d: %v", i, err)
+		}
+		namespace := policy.Metadata.Namespace
+		docs[namespace] = append(docs[namespace], string(doc))
+	}
+	parts := make([]resultPart, 0, len(docs))
+	for namespace, nsDocs := range docs {
+		parts = append(parts, resultPart{namespace: namespace, yamls: strings.Join(nsDocs, "---\n")})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].namespace < parts[j].namespace
+	})
+	return parts, nil
+}
+
+// SaveResult saves the recommended policies of a policy recommendation job in
+// the recommendations table, replacing any result previously saved with the
+// same ID.
+func SaveResult(ctx context.Context, db *sql.DB, id string, recommendationType string, yamls string) error {
+	parts, err := splitResult(yamls)
+	if err != nil {
+		return err
+	}
+	// An empty result is saved as an empty cluster-scoped part, so that it
+	// can be told apart from a missing result.
+	if len(parts) == 0 {
+		parts = []resultPart{{}}
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recommendations WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete the previous result: %v", err)
+	}
+	for _, part := range parts {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recommendations (id, type, yamls, part, namespace) VALUES (?, ?, ?, 0, ?)", id, recommendationType, part.yamls, part.namespace); err != nil {
+			return fmt.Errorf("failed to save the result: %v", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFlows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	full := make([]string, len(FlowsTableColumns))
+	fullArgs := make([]driver.Value, len(FlowsTableColumns))
+	for i, column := range FlowsTableColumns {
+		switch column.Type {
+		case "TEXT":
+			full[i] = ""
+			fullArgs[i] = ""
+		case "TIMESTAMPTZ":
+			full[i] = "2022-08-01 12:00:00"
+			fullArgs[i] = full[i]
+		default:
+			full[i] = "1"
+			fullArgs[i] = full[i]
+		}
+	}
+	// A record written by an older Flow Aggregator, without the last
+	// column.
+	short := full[:len(full)-1]
+	shortArgs := append(append([]driver.Value{}, fullArgs[:len(fullArgs)-1]...), nil)
+	input := strings.Join(full, ",") + "\n" + strings.Join(short, ",") + "\n"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL TIME ZONE 'UTC'")).WillReturnResult(sqlmock.NewResult(0, 0))
+	prepare := mock.ExpectPrepare(regexp.QuoteMeta(`COPY "flows" ("flowstartseconds", "flowendseconds"`))
+	prepare.ExpectExec().WithArgs(fullArgs...).WillReturnResult(sqlmock.NewResult(0, 1))
+	prepare.ExpectExec().WithArgs(shortArgs...).WillReturnResult(sqlmock.NewResult(0, 1))
+	prepare.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	count, err := LoadFlows(context.TODO(), db, strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadFlowsTooManyFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL TIME ZONE 'UTC'")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare(regexp.QuoteMeta(`COPY "flows"`))
+	mock.ExpectRollback()
+
+	input := strings.Repeat("1,", len(FlowsTableColumns)) + "1\n"
+	_, err = LoadFlows(context.TODO(), db, strings.NewReader(input))
+	assert.EqualError(t, err, "flow record 1 has 49 fields, but the flows table only has 48 columns")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const testResult = `apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-acnp-xyz
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-b
+  namespace: app-b
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-a
+  namespace: app-a
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-a2
+  namespace: app-a
+`
+
+func TestSplitResult(t *testing.T) {
+	parts, err := splitResult(testResult)
+	require.NoError(t, err)
+	require.Len(t, parts, 3)
+	assert.Equal(t, "", parts[0].namespace)
+	assert.Contains(t, parts[0].yamls, "recommend-reject-acnp-xyz")
+	assert.Equal(t, "app-a", parts[1].namespace)
+	assert.Equal(t, `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-a
+  namespace: app-a
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-a2
+  namespace: app-a
+`, parts[1].yamls)
+	assert.Equal(t, "app-b", parts[2].namespace)
+
+	_, err = splitResult("metadata: [")
+	assert.Error(t, err)
+}
+
+func TestSaveResult(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM recommendations WHERE id = ?")).
+		WithArgs("e998433e").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, namespace := range []string{"", "app-a", "app-b"} {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO recommendations")).
+			WithArgs("e998433e", "initial", sqlmock.AnyArg(), namespace).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	require.NoError(t, SaveResult(context.TODO(), db, "e998433e", "initial", testResult))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,63 @@
+CREATE TABLE IF NOT EXISTS flows (
+  flowStartSeconds TIMESTAMPTZ,
+  flowEndSeconds TIMESTAMPTZ NOT NULL,
+  flowEndSecondsFromSourceNode TIMESTAMPTZ,
+  flowEndSecondsFromDestinationNode TIMESTAMPTZ,
+  flowEndReason SMALLINT,
+  sourceIP TEXT,
+  destinationIP TEXT,
+  sourceTransportPort INTEGER,
+  destinationTransportPort INTEGER,
+  protocolIdentifier SMALLINT,
+  packetTotalCount BIGINT,
+  octetTotalCount BIGINT,
+  packetDeltaCount BIGINT,
+  octetDeltaCount BIGINT,
+  reversePacketTotalCount BIGINT,
+  reverseOctetTotalCount BIGINT,
+  reversePacketDeltaCount BIGINT,
+  reverseOctetDeltaCount BIGINT,
+  sourcePodName TEXT,
+  sourcePodNamespace TEXT,
+  sourceNodeName TEXT,
+  destinationPodName TEXT,
+  destinationPodNamespace TEXT,
+  destinationNodeName TEXT,
+  destinationClusterIP TEXT,
+  destinationServicePort INTEGER,
+  destinationServicePortName TEXT,
+  ingressNetworkPolicyName TEXT,
+  ingressNetworkPolicyNamespace TEXT,
+  ingressNetworkPolicyRuleName TEXT,
+  ingressNetworkPolicyRuleAction SMALLINT,
+  ingressNetworkPolicyType SMALLINT,
+  egressNetworkPolicyName TEXT,
+  egressNetworkPolicyNamespace TEXT,
+  egressNetworkPolicyRuleName TEXT,
+  egressNetworkPolicyRuleAction SMALLINT,
+  egressNetworkPolicyType SMALLINT,
+  tcpState TEXT,
+  flowType SMALLINT,
+  sourcePodLabels TEXT,
+  destinationPodLabels TEXT,
+  throughput BIGINT,
+  reverseThroughput BIGINT,
+  throughputFromSourceNode BIGINT,
+  throughputFromDestinationNode BIGINT,
+  reverseThroughputFromSourceNode BIGINT,
+  reverseThroughputFromDestinationNode BIGINT,
+  clusterUUID TEXT,
+  timeInserted TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS flows_flowendseconds_idx ON flows (flowEndSeconds);
+
+-- With TimescaleDB, the flows table is a hypertable partitioned by flow end
+-- time, which is used by all the queries.
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+    PERFORM create_hypertable('flows', 'flowendseconds', if_not_exists => TRUE, migrate_data => TRUE);
+  END IF;
+END
+$$;
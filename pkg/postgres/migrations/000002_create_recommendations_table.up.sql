@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS recommendations (
+  id TEXT NOT NULL,
+  type TEXT NOT NULL,
+  timeCreated TIMESTAMPTZ NOT NULL DEFAULT now(),
+  yamls TEXT NOT NULL,
+  part INTEGER NOT NULL DEFAULT 0,
+  namespace TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (id, namespace, part)
+);
@@ -0,0 +1,50 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "no placeholders",
+			query:    "SELECT COUNT(*) FROM flows",
+			expected: "SELECT COUNT(*) FROM flows",
+		},
+		{
+			name:     "placeholders",
+			query:    "SELECT yamls FROM recommendations WHERE id = (?) AND namespace IN ('', ?, ?)",
+			expected: "SELECT yamls FROM recommendations WHERE id = ($1) AND namespace IN ('', $2, $3)",
+		},
+		{
+			name:     "quoted question marks",
+			query:    `SELECT '?', 'it''s?', "col?" FROM flows WHERE flowEndSeconds >= ?`,
+			expected: `SELECT '?', 'it''s?', "col?" FROM flows WHERE flowEndSeconds >= $1`,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Rebind(tt.query))
+		})
+	}
+}
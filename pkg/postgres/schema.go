@@ -0,0 +1,186 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/golang-migrate/migrate"
+	migratepostgres "github.com/golang-migrate/migrate/database/postgres"
+	_ "github.com/golang-migrate/migrate/source/file"
+)
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+const MigrationsPath = "migrations"
+
+// Column describes a column of a table, using the PostgreSQL data type names.
+type Column struct {
+	Name string
+	Type string
+}
+
+// FlowsTableColumns are the columns of the flows table which are loaded from
+// the flow records, in the order of the CSV files uploaded by the Flow
+// Aggregator, which is also the order of the flows tables created in
+// Snowflake and BigQuery. It must be updated every time a migration modifies
+// the flows table.
+var FlowsTableColumns = []Column{
+	{Name: "flowStartSeconds", Type: "TIMESTAMPTZ"},
+	{Name: "flowEndSeconds", Type: "TIMESTAMPTZ"},
+	{Name: "flowEndSecondsFromSourceNode", Type: "TIMESTAMPTZ"},
+	{Name: "flowEndSecondsFromDestinationNode", Type: "TIMESTAMPTZ"},
+	{Name: "flowEndReason", Type: "SMALLINT"},
+	{Name: "sourceIP", Type: "TEXT"},
+	{Name: "destinationIP", Type: "TEXT"},
+	{Name: "sourceTransportPort", Type: "INTEGER"},
+	{Name: "destinationTransportPort", Type: "INTEGER"},
+	{Name: "protocolIdentifier", Type: "SMALLINT"},
+	{Name: "packetTotalCount", Type: "BIGINT"},
+	{Name: "octetTotalCount", Type: "BIGINT"},
+	{Name: "packetDeltaCount", Type: "BIGINT"},
+	{Name: "octetDeltaCount", Type: "BIGINT"},
+	{Name: "reversePacketTotalCount", Type: "BIGINT"},
+	{Name: "reverseOctetTotalCount", Type: "BIGINT"},
+	{Name: "reversePacketDeltaCount", Type: "BIGINT"},
+	{Name: "reverseOctetDeltaCount", Type: "BIGINT"},
+	{Name: "sourcePodName", Type: "TEXT"},
+	{Name: "sourcePodNamespace", Type: "TEXT"},
+	{Name: "sourceNodeName", Type: "TEXT"},
+	{Name: "destinationPodName", Type: "TEXT"},
+	{Name: "destinationPodNamespace", Type: "TEXT"},
+	{Name: "destinationNodeName", Type: "TEXT"},
+	{Name: "destinationClusterIP", Type: "TEXT"},
+	{Name: "destinationServicePort", Type: "INTEGER"},
+	{Name: "destinationServicePortName", Type: "TEXT"},
+	{Name: "ingressNetworkPolicyName", Type: "TEXT"},
+	{Name: "ingressNetworkPolicyNamespace", Type: "TEXT"},
+	{Name: "ingressNetworkPolicyRuleName", Type: "TEXT"},
+	{Name: "ingressNetworkPolicyRuleAction", Type: "SMALLINT"},
+	{Name: "ingressNetworkPolicyType", Type: "SMALLINT"},
+	{Name: "egressNetworkPolicyName", Type: "TEXT"},
+	{Name: "egressNetworkPolicyNamespace", Type: "TEXT"},
+	{Name: "egressNetworkPolicyRuleName", Type: "TEXT"},
+	{Name: "egressNetworkPolicyRuleAction", Type: "SMALLINT"},
+	{Name: "egressNetworkPolicyType", Type: "SMALLINT"},
+	{Name: "tcpState", Type: "TEXT"},
+	{Name: "flowType", Type: "SMALLINT"},
+	{Name: "sourcePodLabels", Type: "TEXT"},
+	{Name: "destinationPodLabels", Type: "TEXT"},
+	{Name: "throughput", Type: "BIGINT"},
+	{Name: "reverseThroughput", Type: "BIGINT"},
+	{Name: "throughputFromSourceNode", Type: "BIGINT"},
+	{Name: "throughputFromDestinationNode", Type: "BIGINT"},
+	{Name: "reverseThroughputFromSourceNode", Type: "BIGINT"},
+	{Name: "reverseThroughputFromDestinationNode", Type: "BIGINT"},
+	{Name: "clusterUUID", Type: "TEXT"},
+}
+
+// Migrate applies the migrations which have not been applied yet to the
+// database with the given URL, and returns the schema version before and after
+// the migrations. A version of 0 means that no migrations have been applied.
+// It uses its own connection, which is closed by golang-migrate.
+func Migrate(url string) (uint, uint, error) {
+	dir, err := os.MkdirTemp("", "theia-postgres-migrations")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(dir)
+	if err := writeMigrationsToDisk(Migrations, MigrationsPath, dir); err != nil {
+		return 0, 0, fmt.Errorf("failed to write the migrations to disk: %v", err)
+	}
+	db, err := Open(url)
+	if err != nil {
+		return 0, 0, err
+	}
+	driver, err := migratepostgres.WithInstance(db, &migratepostgres.Config{})
+	if err != nil {
+		db.Close()
+		return 0, 0, fmt.Errorf("failed to create the migration driver: %v", err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://"+filepath.ToSlash(dir), "postgres", driver)
+	if err != nil {
+		driver.Close()
+		return 0, 0, fmt.Errorf("failed to create the migrate object: %v", err)
+	}
+	defer m.Close()
+	from, err := version(m)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return from, 0, fmt.Errorf("failed to apply the migrations: %v", err)
+	}
+	to, err := version(m)
+	if err != nil {
+		return from, 0, err
+	}
+	return from, to, nil
+}
+
+func version(m *migrate.Migrate) (uint, error) {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the schema version: %v", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("the schema version %d is dirty, the migration needs to be fixed manually", version)
+	}
+	return version, nil
+}
+
+// writeMigrationsToDisk copies the embedded migrations to dest, as the
+// migrations are read from disk by golang-migrate.
+func writeMigrationsToDisk(fsys fs.FS, migrationsPath string, dest string) error {
+	entries, err := fs.ReadDir(fsys, migrationsPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if err := func() error {
+			in, err := fsys.Open(path.Join(migrationsPath, e.Name()))
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			out, err := os.Create(filepath.Join(dest, e.Name()))
+			if err != nil {
+				return err
+			}
+			defer out.Close()
+			if _, err := io.Copy(out, in); err != nil {
+				return err
+			}
+			return out.Close()
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -20,4 +20,5 @@ import (
 
 type NPRecommendationQuerier interface {
 	GetNetworkPolicyRecommendation(namespace, name string) (*v1alpha1.NetworkPolicyRecommendation, error)
+	ListNetworkPolicyRecommendations(namespace string) ([]*v1alpha1.NetworkPolicyRecommendation, error)
 }
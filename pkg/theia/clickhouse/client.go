@@ -0,0 +1,185 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clickhouse is the client of the ClickHouse database deployed with
+// Theia, shared by the theia commands: it connects to ClickHouse, optionally
+// over TLS and through a proxy, with the credentials of the ClickHouse Secret,
+// and exposes typed queries of the tables written by Theia.
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	clickhousego "github.com/ClickHouse/clickhouse-go"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"antrea.io/theia/pkg/util/policyrecommendation"
+)
+
+const (
+	connRetryInterval = 1 * time.Second
+	connTimeout       = 10 * time.Second
+
+	defaultMaxIdleConns    = 2
+	defaultConnMaxLifetime = 5 * time.Minute
+)
+
+// Config is the configuration of the connection to ClickHouse.
+type Config struct {
+	// Endpoint is the address of the native interface of ClickHouse, e.g.
+	// "tcp://localhost:9000".
+	Endpoint string
+	Username string
+	Password string
+	// External is true when Endpoint is given by the user and may be outside
+	// the cluster, e.g. behind an ingress: the proxy defined in the
+	// environment is used, and TLS is enabled when CACertPath is not empty.
+	External bool
+	// CACertPath is the file of the CA certificates trusted, in addition to
+	// the system ones, to verify an External endpoint.
+	CACertPath string
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure the pool of
+	// connections. The defaults are used when they are 0, and the number of
+	// open connections is not limited by default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// ReadOnly sets the readonly=1 setting on the connections, so that
+	// ClickHouse rejects the statements which write data or change settings.
+	ReadOnly bool
+}
+
+// Client queries ClickHouse. It is safe for concurrent use.
+type Client struct {
+	db *sql.DB
+}
+
+// NewClient returns a Client using db, e.g. a mock in tests.
+func NewClient(db *sql.DB) *Client {
+	return &Client{db: db}
+}
+
+// Connect connects to ClickHouse, retrying for 10 seconds, and returns a Client
+// with a pool of connections.
+func Connect(config Config) (*Client, error) {
+	var transportParams string
+	if config.External {
+		var err error
+		if transportParams, err = registerTransport(config.CACertPath); err != nil {
+			return nil, err
+		}
+	}
+	url := dataSourceName(config, transportParams)
+	var db *sql.DB
+	var connErr error
+	if err := wait.PollImmediate(connRetryInterval, connTimeout, func() (bool, error) {
+		// Open the database and ping it
+		var err error
+		db, err = sql.Open("clickhouse", url)
+		if err != nil {
+			connErr = fmt.Errorf("failed to open ClickHouse: %v", err)
+			return false, nil
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			if exception, ok := err.(*clickhousego.Exception); ok {
+				connErr = fmt.Errorf("failed to ping ClickHouse: %v", exception.Message)
+			} else {
+				connErr = fmt.Errorf("failed to ping ClickHouse: %v", err)
+			}
+			return false, nil
+		}
+		return true, nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to connect to ClickHouse after %s: %v", connTimeout, connErr)
+	}
+	configurePool(db, config)
+	return NewClient(db), nil
+}
+
+func dataSourceName(config Config, transportParams string) string {
+	url := fmt.Sprintf("%s?debug=false&username=%s&password=%s%s", config.Endpoint, config.Username, config.Password, transportParams)
+	if config.ReadOnly {
+		url += "&readonly=1"
+	}
+	return url
+}
+
+func configurePool(db *sql.DB, config Config) {
+	maxIdleConns := config.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	connMaxLifetime := config.ConnMaxLifetime
+	if connMaxLifetime == 0 {
+		connMaxLifetime = defaultConnMaxLifetime
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
+}
+
+// DB returns the database of the client, for the queries which have no typed
+// method yet.
+func (c *Client) DB() *sql.DB {
+	return c.db
+}
+
+// Close closes the connections of the client.
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// Query runs a query returning rows.
+func (c *Client) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+// GetRecommendationResult returns the policies recommended by a job, as YAML
+// documents. When namespaces are provided, only the policies in these
+// Namespaces and the cluster-scoped ones are returned.
+func (c *Client) GetRecommendationResult(ctx context.Context, id string, namespaces ...string) (string, error) {
+	return policyrecommendation.QueryResult(ctx, c.db, id, namespaces)
+}
+
+// Recommendation is a result stored in the recommendations table.
+type Recommendation struct {
+	ID          string
+	TimeCreated time.Time
+}
+
+// ListRecommendations returns the results stored in the recommendations
+// table.
+func (c *Client) ListRecommendations(ctx context.Context) ([]Recommendation, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT DISTINCT timeCreated, id FROM recommendations;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recommendation jobs: %v", err)
+	}
+	defer rows.Close()
+	var recommendations []Recommendation
+	for rows.Next() {
+		var recommendation Recommendation
+		if err := rows.Scan(&recommendation.TimeCreated, &recommendation.ID); err != nil {
+			return nil, fmt.Errorf("err when scanning recommendations row %v", err)
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get recommendation jobs: %v", err)
+	}
+	return recommendations, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRecommendationResult(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery("SELECT yamls FROM recommendations WHERE id = (?) ORDER BY namespace, part;").
+		WithArgs("db2134ea-7169-46f8-b56d-d643d4751d1d").
+		WillReturnRows(sqlmock.NewRows([]string{"yamls"}).AddRow("recommend-allow-acnp-kube-system-rpeal"))
+	result, err := NewClient(db).GetRecommendationResult(context.TODO(), "db2134ea-7169-46f8-b56d-d643d4751d1d")
+	require.NoError(t, err)
+	assert.Equal(t, "recommend-allow-acnp-kube-system-rpeal", result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListRecommendations(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+	timeCreated := time.Date(2022, 6, 17, 18, 35, 2, 0, time.UTC)
+	mock.ExpectQuery("SELECT DISTINCT timeCreated, id FROM recommendations;").
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated", "id"}).
+			AddRow(timeCreated, "db2134ea-7169-46f8-b56d-d643d4751d1d").
+			AddRow(timeCreated.Add(time.Hour), "e998433e-accb-4888-9fc8-06563f073e86"))
+	recommendations, err := NewClient(db).ListRecommendations(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, []Recommendation{
+		{ID: "db2134ea-7169-46f8-b56d-d643d4751d1d", TimeCreated: timeCreated},
+		{ID: "e998433e-accb-4888-9fc8-06563f073e86", TimeCreated: timeCreated.Add(time.Hour)},
+	}, recommendations)
+
+	mock.ExpectQuery("SELECT DISTINCT timeCreated, id FROM recommendations;").
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated", "id"}).AddRow("not a time", "db2134ea-7169-46f8-b56d-d643d4751d1d"))
+	_, err = NewClient(db).ListRecommendations(context.TODO())
+	assert.ErrorContains(t, err, "err when scanning recommendations row")
+}
+
+func TestDataSourceName(t *testing.T) {
+	config := Config{Endpoint: "tcp://localhost:9000", Username: "user", Password: "pass"}
+	assert.Equal(t, "tcp://localhost:9000?debug=false&username=user&password=pass", dataSourceName(config, ""))
+	config.ReadOnly = true
+	assert.Equal(t, "tcp://localhost:9000?debug=false&username=user&password=pass&secure=true&readonly=1", dataSourceName(config, "&secure=true"))
+}
+
+func TestConfigurePool(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	configurePool(db, Config{})
+	assert.Equal(t, 0, db.Stats().MaxOpenConnections)
+	configurePool(db, Config{MaxOpenConns: 8})
+	assert.Equal(t, 8, db.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretName is the name of the Secret with the username and password of
+// ClickHouse, in the Namespace in which Theia is installed.
+const SecretName = "clickhouse-secret"
+
+// GetCredentials returns the username and password of ClickHouse read from
+// the ClickHouse Secret of the namespace.
+func GetCredentials(ctx context.Context, clientset kubernetes.Interface, namespace string) (username []byte, password []byte, err error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, SecretName, metav1.GetOptions{})
+	if err != nil {
+		return username, password, fmt.Errorf("error %v when finding the ClickHouse secret, please check the deployment of ClickHouse", err)
+	}
+	username, ok := secret.Data["username"]
+	if !ok {
+		return username, password, fmt.Errorf("error when getting the ClickHouse username")
+	}
+	password, ok = secret.Data["password"]
+	if !ok {
+		return username, password, fmt.Errorf("error when getting the ClickHouse password")
+	}
+	return username, password, nil
+}
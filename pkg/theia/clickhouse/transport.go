@@ -0,0 +1,194 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	clickhousego "github.com/ClickHouse/clickhouse-go"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// tlsConfigName is the key under which the TLS configuration built from
+// Config.CACertPath is registered with the ClickHouse driver.
+const tlsConfigName = "theia"
+
+// proxyFunc resolves the proxy to use for a given target URL. It honors the
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables (and their
+// lowercase versions) in the same way as net/http.
+var proxyFunc = func(target *url.URL) (*url.URL, error) {
+	return httpproxy.FromEnvironment().ProxyFunc()(target)
+}
+
+// registerTransport configures the ClickHouse driver to reach a
+// user-provided endpoint, going through the proxy defined in the environment if
+// any, and trusting the CA certificates from caCertPath if not empty. It returns
+// the extra DSN parameters required to enable TLS.
+func registerTransport(caCertPath string) (string, error) {
+	clickhousego.RegisterDial(dial)
+	if caCertPath == "" {
+		return "", nil
+	}
+	tlsConfig, err := loadTLSConfig(caCertPath)
+	if err != nil {
+		return "", err
+	}
+	if err := clickhousego.RegisterTLSConfig(tlsConfigName, tlsConfig); err != nil {
+		return "", fmt.Errorf("error when registering TLS config for ClickHouse: %v", err)
+	}
+	return fmt.Sprintf("&secure=true&tls_config=%s", tlsConfigName), nil
+}
+
+func loadTLSConfig(caCertPath string) (*tls.Config, error) {
+	caCert, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading ClickHouse CA certificate: %v", err)
+	}
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		certPool = x509.NewCertPool()
+	}
+	if ok := certPool.AppendCertsFromPEM(caCert); !ok {
+		return nil, fmt.Errorf("no valid PEM certificate found in %s", caCertPath)
+	}
+	return &tls.Config{
+		RootCAs:    certPool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// dial implements clickhousego.DialFunc. When a proxy is configured for
+// the ClickHouse address, a tunnel is established through it (HTTP CONNECT for
+// http and https proxies, SOCKS5 otherwise) before the optional TLS handshake.
+func dial(network, address string, timeout time.Duration, tlsConfig *tls.Config) (net.Conn, error) {
+	target := &url.URL{Scheme: "http", Host: address}
+	if tlsConfig != nil {
+		target.Scheme = "https"
+	}
+	proxyURL, err := proxyFunc(target)
+	if err != nil {
+		return nil, fmt.Errorf("error when resolving proxy for ClickHouse endpoint %s: %v", address, err)
+	}
+	var conn net.Conn
+	if proxyURL == nil {
+		conn, err = net.DialTimeout(network, address, timeout)
+	} else {
+		conn, err = dialThroughProxy(proxyURL, network, address, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return conn, nil
+	}
+	config := tlsConfig.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		config.ServerName = host
+	}
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		tlsConn.Close()
+		return nil, fmt.Errorf("TLS handshake with ClickHouse endpoint %s failed: %v", address, err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+func dialThroughProxy(proxyURL *url.URL, network, address string, timeout time.Duration) (net.Conn, error) {
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, &net.Dialer{Timeout: timeout})
+		if err != nil {
+			return nil, fmt.Errorf("error when creating SOCKS5 dialer for proxy %s: %v", proxyURL.Host, err)
+		}
+		return dialer.Dial(network, address)
+	case "http", "https":
+		return dialHTTPConnect(proxyURL, address, timeout)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+func dialHTTPConnect(proxyURL *url.URL, address string, timeout time.Duration) (net.Conn, error) {
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", proxyAddress, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error when connecting to proxy %s: %v", proxyAddress, err)
+	}
+	if proxyURL.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error when sending CONNECT request to proxy %s: %v", proxyAddress, err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error when reading CONNECT response from proxy %s: %v", proxyAddress, err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", proxyAddress, address, response.Status)
+	}
+	if reader.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected data received from proxy %s after CONNECT", proxyAddress)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
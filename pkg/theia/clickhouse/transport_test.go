@@ -0,0 +1,149 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startEchoServer starts a TCP server which echoes back everything it receives.
+func startEchoServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+// startConnectProxy starts a minimal HTTP proxy which only supports CONNECT and
+// replies with the provided status code.
+func startConnectProxy(t *testing.T, statusCode int) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	targets := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				request, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				targets <- request.Host
+				response := &http.Response{StatusCode: statusCode, ProtoMajor: 1, ProtoMinor: 1}
+				response.Write(conn)
+				if statusCode != http.StatusOK {
+					return
+				}
+				backend, err := net.Dial("tcp", request.Host)
+				if err != nil {
+					return
+				}
+				defer backend.Close()
+				go io.Copy(backend, conn)
+				io.Copy(conn, backend)
+			}()
+		}
+	}()
+	return listener, targets
+}
+
+func TestDialClickHouseThroughProxy(t *testing.T) {
+	backend := startEchoServer(t)
+	defer backend.Close()
+
+	testCases := []struct {
+		name             string
+		statusCode       int
+		expectedErrorMsg string
+	}{
+		{
+			name:       "tunnel established",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:             "tunnel refused",
+			statusCode:       http.StatusProxyAuthRequired,
+			expectedErrorMsg: "refused to connect",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyListener, targets := startConnectProxy(t, tt.statusCode)
+			defer proxyListener.Close()
+			originalProxyFunc := proxyFunc
+			defer func() { proxyFunc = originalProxyFunc }()
+			proxyFunc = func(target *url.URL) (*url.URL, error) {
+				return &url.URL{Scheme: "http", Host: proxyListener.Addr().String()}, nil
+			}
+
+			conn, err := dial("tcp", backend.Addr().String(), time.Second, nil)
+			assert.Equal(t, backend.Addr().String(), <-targets)
+			if tt.expectedErrorMsg != "" {
+				assert.ErrorContains(t, err, tt.expectedErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+			_, err = conn.Write([]byte("ping"))
+			require.NoError(t, err)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(conn, buf)
+			require.NoError(t, err)
+			assert.Equal(t, "ping", string(buf))
+		})
+	}
+}
+
+func TestDialClickHouseWithoutProxy(t *testing.T) {
+	backend := startEchoServer(t)
+	defer backend.Close()
+	originalProxyFunc := proxyFunc
+	defer func() { proxyFunc = originalProxyFunc }()
+	proxyFunc = func(target *url.URL) (*url.URL, error) {
+		return nil, nil
+	}
+	conn, err := dial("tcp", backend.Addr().String(), time.Second, nil)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestLoadClickHouseTLSConfig(t *testing.T) {
+	_, err := loadTLSConfig("/non-existent/ca.crt")
+	assert.ErrorContains(t, err, "error when reading ClickHouse CA certificate")
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+)
+
+// namespacedPermission returns a permission on a resource of the Namespace in
+// which Theia is installed.
+func namespacedPermission(verb, group, resource string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{Namespace: config.FlowVisibilityNS, Verb: verb, Group: group, Resource: resource}
+}
+
+// clusterPermission returns a permission on a resource of all the Namespaces,
+// or on a cluster-scoped resource.
+func clusterPermission(verb, group, resource string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{Verb: verb, Group: group, Resource: resource}
+}
+
+// clickHousePermissions returns the permissions required by CheckClickHousePod
+// and SetupClickHouseConnection.
+func clickHousePermissions(endpoint string, useClusterIP bool) []authorizationv1.ResourceAttributes {
+	permissions := []authorizationv1.ResourceAttributes{
+		namespacedPermission("list", "", "pods"),
+		namespacedPermission("get", "", "secrets"),
+	}
+	if endpoint == "" {
+		permissions = append(permissions, namespacedPermission("get", "", "services"))
+		if !useClusterIP {
+			portForward := namespacedPermission("create", "", "pods")
+			portForward.Subresource = "portforward"
+			permissions = append(permissions, portForward)
+		}
+	}
+	return permissions
+}
+
+// describePermission returns a description of the permission like
+// "create sparkapplications.sparkoperator.k8s.io in Namespace flow-visibility".
+func describePermission(permission authorizationv1.ResourceAttributes) string {
+	resource := permission.Resource
+	if permission.Group != "" {
+		resource += "." + permission.Group
+	}
+	if permission.Subresource != "" {
+		resource += "/" + permission.Subresource
+	}
+	if permission.Namespace == "" {
+		return fmt.Sprintf("%s %s in all Namespaces", permission.Verb, resource)
+	}
+	return fmt.Sprintf("%s %s in Namespace %s", permission.Verb, resource, permission.Namespace)
+}
+
+// checkPermissions checks with SelfSubjectAccessReviews that the user of the
+// CLI has all the permissions, so that a missing permission is reported before
+// anything is created, instead of an opaque Forbidden error in the middle of
+// the command. The check is skipped if the access reviews fail, as the API
+// server then reports the missing permissions itself.
+func checkPermissions(ctx context.Context, clientset kubernetes.Interface, permissions []authorizationv1.ResourceAttributes) error {
+	var missing []string
+	checked := make(map[authorizationv1.ResourceAttributes]bool)
+	for _, permission := range permissions {
+		if checked[permission] {
+			continue
+		}
+		checked[permission] = true
+		permission := permission
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &permission},
+		}
+		response, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			klog.V(2).ErrorS(err, "Failed to review the permissions of the user, skipping the check")
+			return nil
+		}
+		if !response.Status.Allowed {
+			missing = append(missing, describePermission(permission))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the user is missing the permissions to %s, please ask the cluster administrator to grant them", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	sparkExecutor, err := executor.New(executor.SparkOperatorBackend, executor.Options{Namespace: config.FlowVisibilityNS})
+	require.NoError(t, err)
+	permissions := append(clickHousePermissions("", false), sparkExecutor.Permissions()...)
+
+	newClientset := func(denied map[string]bool, reviewErr error) (*fake.Clientset, *int) {
+		reviews := 0
+		clientset := fake.NewSimpleClientset()
+		clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			reviews++
+			if reviewErr != nil {
+				return true, nil, reviewErr
+			}
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			attributes := review.Spec.ResourceAttributes
+			review.Status.Allowed = !denied[attributes.Verb+" "+attributes.Resource]
+			return true, review, nil
+		})
+		return clientset, &reviews
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		clientset, reviews := newClientset(nil, nil)
+		require.NoError(t, checkPermissions(context.TODO(), clientset, append(permissions, permissions...)))
+		// The list of Pods is required by both ClickHouse and the Spark
+		// Operator, and only reviewed once.
+		assert.Equal(t, len(permissions)-1, *reviews)
+	})
+
+	t.Run("missing permissions", func(t *testing.T) {
+		clientset, _ := newClientset(map[string]bool{"get secrets": true, "create sparkapplications": true, "create pods": true}, nil)
+		err := checkPermissions(context.TODO(), clientset, permissions)
+		assert.EqualError(t, err, "the user is missing the permissions to get secrets in Namespace flow-visibility, "+
+			"create pods/portforward in Namespace flow-visibility, create sparkapplications.sparkoperator.k8s.io in Namespace flow-visibility, "+
+			"please ask the cluster administrator to grant them")
+	})
+
+	t.Run("review failure", func(t *testing.T) {
+		clientset, reviews := newClientset(nil, fmt.Errorf("the server could not find the requested resource"))
+		assert.NoError(t, checkPermissions(context.TODO(), clientset, permissions))
+		assert.Equal(t, 1, *reviews)
+	})
+}
+
+func TestDescribePermission(t *testing.T) {
+	assert.Equal(t, "list nodes in all Namespaces", describePermission(clusterPermission("list", "", "nodes")))
+	assert.Equal(t, "create jobs.batch in Namespace flow-visibility", describePermission(namespacedPermission("create", "batch", "jobs")))
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// googleCloudProjectEnv is the environment variable with the default
+	// Google Cloud project, as with the Google Cloud client libraries.
+	googleCloudProjectEnv   = "GOOGLE_CLOUD_PROJECT"
+	defaultBigQueryDataset  = "antrea_flows"
+	defaultBigQueryLocation = "US"
+)
+
+var bigQueryCmd = &cobra.Command{
+	Use:     "bigquery",
+	Aliases: []string{"bq"},
+	Short:   "Commands of Theia BigQuery feature",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand to run like onboard")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bigQueryCmd)
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/gcp"
+)
+
+var bigQueryOnboardCmd = &cobra.Command{
+	Use:   "onboard",
+	Short: "Create or update the Google Cloud resources storing flows in BigQuery",
+	Long: `Create or update the Google Cloud resources storing flows in BigQuery, so
+that the flow commands can query them with "--flows-backend bigquery":
+
+1. a BigQuery dataset, with a flows table partitioned by flow end time
+2. a Cloud Storage bucket, in which the flow records are uploaded as CSV
+   files, in the format of the S3 uploader of the Flow Aggregator
+3. a Pub/Sub topic, to which the result of each load is published
+4. a BigQuery Data Transfer Service configuration, which loads the files of
+   the bucket folder into the flows table on a schedule, and deletes them
+
+The requests are authenticated with the access token of the
+GOOGLE_OAUTH_ACCESS_TOKEN environment variable, or of the active gcloud
+account, which needs to be allowed to create these resources.
+
+You can run the "onboard" command multiple times as it is idempotent. The
+schema of an existing flows table is not updated.`,
+	Example: `Onboard the project of the GOOGLE_CLOUD_PROJECT environment variable
+$ theia bigquery onboard --bucket-name my-theia-flows
+Onboard a project in the EU, loading the flows every hour
+$ theia bigquery onboard --project my-project --location EU --bucket-name my-theia-flows --schedule "every 1 hours"`,
+	Args: cobra.NoArgs,
+	RunE: bigQueryOnboard,
+}
+
+// bigQueryOnboardConfig is the configuration of the onboarded resources.
+type bigQueryOnboardConfig struct {
+	project      string
+	location     string
+	dataset      string
+	bucketName   string
+	bucketPrefix string
+	topic        string
+	schedule     string
+}
+
+func bigQueryOnboard(cmd *cobra.Command, args []string) error {
+	var config bigQueryOnboardConfig
+	var err error
+	config.project, err = getGoogleCloudProject(cmd, "project")
+	if err != nil {
+		return err
+	}
+	if config.project == "" {
+		return fmt.Errorf("the Google Cloud project must be set with --project or the %s environment variable", googleCloudProjectEnv)
+	}
+	config.location, err = cmd.Flags().GetString("location")
+	if err != nil {
+		return err
+	}
+	config.dataset, err = cmd.Flags().GetString("dataset")
+	if err != nil {
+		return err
+	}
+	config.bucketName, err = cmd.Flags().GetString("bucket-name")
+	if err != nil {
+		return err
+	}
+	if config.bucketName == "" {
+		return fmt.Errorf("bucket-name is required")
+	}
+	config.bucketPrefix, err = cmd.Flags().GetString("bucket-prefix")
+	if err != nil {
+		return err
+	}
+	config.topic, err = cmd.Flags().GetString("topic")
+	if err != nil {
+		return err
+	}
+	config.schedule, err = cmd.Flags().GetString("schedule")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	client := gcp.NewClient(gcp.DefaultTokenSource())
+	result, err := onboardBigQuery(ctx, client, &config)
+	// The resources created before an error are still shown.
+	if len(result) > 1 {
+		TableOutput(result)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Query the flows with: theia flows summary --flows-backend bigquery --bigquery-project %s --bigquery-dataset %s --bigquery-location %s\n", config.project, config.dataset, config.location)
+	return nil
+}
+
+// onboardBigQuery creates or updates the resources, and returns a table
+// describing them.
+func onboardBigQuery(ctx context.Context, client *gcp.Client, config *bigQueryOnboardConfig) ([][]string, error) {
+	result := [][]string{{"Resource", "Name", "Status"}}
+	addResult := func(resource string, name string, created bool) {
+		status := "Exists"
+		if created {
+			status = "Created"
+		}
+		result = append(result, []string{resource, name, status})
+	}
+	created, err := client.CreateDataset(ctx, config.project, config.dataset, config.location)
+	if err != nil {
+		return result, err
+	}
+	addResult("BigQuery dataset", config.dataset, created)
+	table := flows.BigQueryFlowsTable
+	created, err = client.CreateTable(ctx, config.project, config.dataset, table)
+	if err != nil {
+		return result, err
+	}
+	addResult("BigQuery table", config.dataset+"."+table.Name, created)
+	created, err = client.CreateBucket(ctx, config.project, config.bucketName, config.location)
+	if err != nil {
+		return result, err
+	}
+	addResult("Cloud Storage bucket", config.bucketName, created)
+	created, err = client.CreateTopic(ctx, config.project, config.topic)
+	if err != nil {
+		return result, err
+	}
+	addResult("Pub/Sub topic", config.topic, created)
+	transfer := &gcp.StorageTransfer{
+		DisplayName: fmt.Sprintf("theia-%s-%s", config.dataset, table.Name),
+		Dataset:     config.dataset,
+		Table:       table.Name,
+		DataPath:    storageDataPath(config.bucketName, config.bucketPrefix),
+		Schedule:    config.schedule,
+		Topic:       gcp.TopicName(config.project, config.topic),
+	}
+	// Transfer configurations use lowercase locations, e.g. "us" for the US
+	// multi-region.
+	name, created, err := client.EnsureStorageTransfer(ctx, config.project, strings.ToLower(config.location), transfer)
+	if err != nil {
+		return result, err
+	}
+	status := "Updated"
+	if created {
+		status = "Created"
+	}
+	result = append(result, []string{"Scheduled load", name, status})
+	return result, nil
+}
+
+// storageDataPath returns the URI of the CSV files uploaded under the prefix
+// of the bucket.
+func storageDataPath(bucketName string, bucketPrefix string) string {
+	bucketPrefix = strings.Trim(bucketPrefix, "/")
+	if bucketPrefix == "" {
+		return fmt.Sprintf("gs://%s/*", bucketName)
+	}
+	return fmt.Sprintf("gs://%s/%s/*", bucketName, bucketPrefix)
+}
+
+func init() {
+	bigQueryCmd.AddCommand(bigQueryOnboardCmd)
+	bigQueryOnboardCmd.Flags().String(
+		"project",
+		"",
+		fmt.Sprintf("The Google Cloud project in which the resources are created. Defaults to the %s environment variable.", googleCloudProjectEnv),
+	)
+	bigQueryOnboardCmd.Flags().String(
+		"location",
+		defaultBigQueryLocation,
+		"The location of the BigQuery dataset and of the Cloud Storage bucket, e.g. US, EU or us-west1.",
+	)
+	bigQueryOnboardCmd.Flags().String(
+		"dataset",
+		defaultBigQueryDataset,
+		"The BigQuery dataset in which the flows table is created.",
+	)
+	bigQueryOnboardCmd.Flags().String(
+		"bucket-name",
+		"",
+		"The Cloud Storage bucket to which the flow records are uploaded. It is created if it does not exist.",
+	)
+	bigQueryOnboardCmd.Flags().String(
+		"bucket-prefix",
+		"flows",
+		"The folder of the bucket to which the flow records are uploaded.",
+	)
+	bigQueryOnboardCmd.Flags().String(
+		"topic",
+		"theia-flows-load",
+		"The Pub/Sub topic to which the result of each load is published.",
+	)
+	bigQueryOnboardCmd.Flags().String(
+		"schedule",
+		"every 15 minutes",
+		`The schedule of the loads, in the format of the BigQuery Data Transfer Service,
+e.g. "every 1 hours". Loads cannot run more often than every 15 minutes.`,
+	)
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageDataPath(t *testing.T) {
+	assert.Equal(t, "gs://theia/flows/*", storageDataPath("theia", "flows"))
+	assert.Equal(t, "gs://theia/cluster-a/flows/*", storageDataPath("theia", "/cluster-a/flows/"))
+	assert.Equal(t, "gs://theia/*", storageDataPath("theia", ""))
+}
+
+func TestGetGoogleCloudProject(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("project", "", "")
+	t.Setenv(googleCloudProjectEnv, "env-project")
+	project, err := getGoogleCloudProject(cmd, "project")
+	require.NoError(t, err)
+	assert.Equal(t, "env-project", project)
+
+	require.NoError(t, cmd.Flags().Set("project", "flag-project"))
+	project, err = getGoogleCloudProject(cmd, "project")
+	require.NoError(t, err)
+	assert.Equal(t, "flag-project", project)
+}
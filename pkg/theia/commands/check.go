@@ -0,0 +1,209 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+)
+
+const (
+	checkStatusPass = "PASS"
+	checkStatusFail = "FAIL"
+
+	sparkOperatorDeploymentName = "policy-recommendation-spark-operator"
+	// clickHouseInstallationLabel is set by the ClickHouse Operator on the
+	// StatefulSets created for the "clickhouse" ClickHouseInstallation.
+	clickHouseInstallationLabel = "clickhouse.altinity.com/chi=clickhouse"
+)
+
+// checkResult is the result of the health check of a Theia component.
+type checkResult struct {
+	component string
+	status    string
+	details   string
+}
+
+func newCheckResult(component string, details string, err error) checkResult {
+	if err != nil {
+		return checkResult{component: component, status: checkStatusFail, details: err.Error()}
+	}
+	return checkResult{component: component, status: checkStatusPass, details: details}
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the health of the Theia deployment",
+	Long: `Check the health of the Theia components deployed in the cluster: the
+Theia Namespace, the CRDs, the Spark Operator, the ClickHouse StatefulSets and
+Grafana. Each component is reported as PASS or FAIL, in which case the details
+explain what is wrong and how to fix it.`,
+	Args: cobra.NoArgs,
+	Example: `
+Check the health of the Theia deployment
+$ theia check
+Check the health of the Theia deployment in the theia Namespace
+$ theia check --theia-namespace theia
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		cache, err := discoveryCache(cmd, kubeconfig)
+		if err != nil {
+			return err
+		}
+		var results []checkResult
+		results = append(results, checkNamespaceHealth(clientset))
+		for _, crd := range upgradeCRDs {
+			served, err := getCRDServedVersions(clientset, cache, crd.name)
+			results = append(results, checkCRDServed(crd.name, crd.version, served, err))
+		}
+		results = append(results, checkSparkOperatorHealth(clientset), checkClickHouseHealth(clientset), checkGrafanaHealth(clientset))
+		table := [][]string{{"Component", "Status", "Details"}}
+		failed := 0
+		for _, result := range results {
+			table = append(table, []string{result.component, result.status, result.details})
+			if result.status == checkStatusFail {
+				failed++
+			}
+		}
+		TableOutput(table)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func checkNamespaceHealth(clientset kubernetes.Interface) checkResult {
+	component := fmt.Sprintf("Namespace %s", config.FlowVisibilityNS)
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.TODO(), config.FlowVisibilityNS, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("can't find the Namespace, please install Theia or use --theia-namespace if it is installed in another Namespace")
+		}
+		return newCheckResult(component, "", err)
+	}
+	if namespace.Status.Phase != "Active" {
+		return newCheckResult(component, "", fmt.Errorf("the Namespace is %s, please reinstall Theia once it is deleted", namespace.Status.Phase))
+	}
+	return newCheckResult(component, "Active", nil)
+}
+
+func checkCRDServed(name string, version string, served []string, err error) checkResult {
+	component := "CRD " + name
+	if err != nil {
+		return newCheckResult(component, "", err)
+	}
+	if len(served) == 0 {
+		return newCheckResult(component, "", fmt.Errorf("can't find the CRD, please install the Theia Helm chart"))
+	}
+	for _, v := range served {
+		if v == version {
+			return newCheckResult(component, fmt.Sprintf("%s served", version), nil)
+		}
+	}
+	return newCheckResult(component, "", fmt.Errorf("version %s is not served (served: %s), please run \"theia upgrade plan\"", version, strings.Join(served, ",")))
+}
+
+// checkDeploymentReady checks that the Deployment has all its replicas ready.
+func checkDeploymentReady(clientset kubernetes.Interface, name string) (*appsv1.Deployment, error) {
+	deployment, err := clientset.AppsV1().Deployments(config.FlowVisibilityNS).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("can't find the %s Deployment", name)
+		}
+		return nil, fmt.Errorf("error %v when getting the %s Deployment", err, name)
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ReadyReplicas < replicas {
+		return nil, fmt.Errorf("%d/%d replicas of the %s Deployment are ready, please check its Pods with \"kubectl -n %s describe pods\"", deployment.Status.ReadyReplicas, replicas, name, config.FlowVisibilityNS)
+	}
+	return deployment, nil
+}
+
+func checkSparkOperatorHealth(clientset kubernetes.Interface) checkResult {
+	component := "Spark Operator"
+	if _, err := checkDeploymentReady(clientset, sparkOperatorDeploymentName); err != nil {
+		return newCheckResult(component, "", fmt.Errorf("%v; the Spark Operator is required by policy recommendation", err))
+	}
+	if err := CheckSparkOperatorPod(clientset); err != nil {
+		return newCheckResult(component, "", err)
+	}
+	return newCheckResult(component, "Deployment ready", nil)
+}
+
+func checkClickHouseHealth(clientset kubernetes.Interface) checkResult {
+	component := "ClickHouse"
+	statefulSets, err := clientset.AppsV1().StatefulSets(config.FlowVisibilityNS).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: clickHouseInstallationLabel,
+	})
+	if err != nil {
+		return newCheckResult(component, "", fmt.Errorf("error %v when finding the ClickHouse StatefulSets", err))
+	}
+	if len(statefulSets.Items) == 0 {
+		return newCheckResult(component, "", fmt.Errorf("can't find the ClickHouse StatefulSets, please check that the ClickHouse Operator is running"))
+	}
+	var notReady []string
+	for _, statefulSet := range statefulSets.Items {
+		replicas := int32(1)
+		if statefulSet.Spec.Replicas != nil {
+			replicas = *statefulSet.Spec.Replicas
+		}
+		if statefulSet.Status.ReadyReplicas < replicas {
+			notReady = append(notReady, fmt.Sprintf("%s (%d/%d ready)", statefulSet.Name, statefulSet.Status.ReadyReplicas, replicas))
+		}
+	}
+	if len(notReady) > 0 {
+		return newCheckResult(component, "", fmt.Errorf("StatefulSets not ready: %s, please check their Pods with \"kubectl -n %s describe pods -l app=clickhouse\"", strings.Join(notReady, ", "), config.FlowVisibilityNS))
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return newCheckResult(component, "", err)
+	}
+	return newCheckResult(component, fmt.Sprintf("%d StatefulSet(s) ready", len(statefulSets.Items)), nil)
+}
+
+func checkGrafanaHealth(clientset kubernetes.Interface) checkResult {
+	component := "Grafana"
+	if _, err := checkDeploymentReady(clientset, config.GrafanaServiceName); err != nil {
+		return newCheckResult(component, "", err)
+	}
+	if _, err := clientset.CoreV1().Services(config.FlowVisibilityNS).Get(context.TODO(), config.GrafanaServiceName, metav1.GetOptions{}); err != nil {
+		return newCheckResult(component, "", fmt.Errorf("error %v when finding the %s Service", err, config.GrafanaServiceName))
+	}
+	return newCheckResult(component, "Deployment ready", nil)
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+)
+
+func newCheckDeployment(name string, replicas int32, readyReplicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: config.FlowVisibilityNS},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: readyReplicas},
+	}
+}
+
+func newCheckPod(name string, labels map[string]string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: config.FlowVisibilityNS, Labels: labels},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+}
+
+func newCheckStatefulSet(name string, replicas int32, readyReplicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: config.FlowVisibilityNS,
+			Labels:    map[string]string{"clickhouse.altinity.com/chi": "clickhouse"},
+		},
+		Spec:   appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: readyReplicas},
+	}
+}
+
+func TestCheckNamespaceHealth(t *testing.T) {
+	testCases := []struct {
+		name           string
+		objects        []runtime.Object
+		expectedResult checkResult
+	}{
+		{
+			name: "active",
+			objects: []runtime.Object{&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: config.FlowVisibilityNS},
+				Status:     v1.NamespaceStatus{Phase: v1.NamespaceActive},
+			}},
+			expectedResult: checkResult{component: "Namespace flow-visibility", status: checkStatusPass, details: "Active"},
+		},
+		{
+			name: "terminating",
+			objects: []runtime.Object{&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: config.FlowVisibilityNS},
+				Status:     v1.NamespaceStatus{Phase: v1.NamespaceTerminating},
+			}},
+			expectedResult: checkResult{component: "Namespace flow-visibility", status: checkStatusFail, details: "the Namespace is Terminating, please reinstall Theia once it is deleted"},
+		},
+		{
+			name:           "missing",
+			expectedResult: checkResult{component: "Namespace flow-visibility", status: checkStatusFail, details: "can't find the Namespace, please install Theia or use --theia-namespace if it is installed in another Namespace"},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedResult, checkNamespaceHealth(fake.NewSimpleClientset(tt.objects...)))
+		})
+	}
+}
+
+func TestCheckCRDServed(t *testing.T) {
+	const name = "networkpolicyrecommendations.crd.theia.antrea.io"
+	testCases := []struct {
+		name           string
+		served         []string
+		err            error
+		expectedStatus string
+		expectedDetail string
+	}{
+		{name: "served", served: []string{"v1alpha1"}, expectedStatus: checkStatusPass, expectedDetail: "v1alpha1 served"},
+		{name: "missing", expectedStatus: checkStatusFail, expectedDetail: "can't find the CRD, please install the Theia Helm chart"},
+		{name: "other version", served: []string{"v1alpha2"}, expectedStatus: checkStatusFail, expectedDetail: "version v1alpha1 is not served (served: v1alpha2), please run \"theia upgrade plan\""},
+		{name: "error", err: fmt.Errorf("forbidden"), expectedStatus: checkStatusFail, expectedDetail: "forbidden"},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checkCRDServed(name, "v1alpha1", tt.served, tt.err)
+			assert.Equal(t, "CRD "+name, result.component)
+			assert.Equal(t, tt.expectedStatus, result.status)
+			assert.Equal(t, tt.expectedDetail, result.details)
+		})
+	}
+}
+
+func TestCheckSparkOperatorHealth(t *testing.T) {
+	sparkLabels := map[string]string{"app.kubernetes.io/name": "spark-operator"}
+	testCases := []struct {
+		name           string
+		objects        []runtime.Object
+		expectedStatus string
+		expectedDetail string
+	}{
+		{
+			name: "ready",
+			objects: []runtime.Object{
+				newCheckDeployment(sparkOperatorDeploymentName, 1, 1),
+				newCheckPod("spark-operator", sparkLabels, v1.PodRunning),
+			},
+			expectedStatus: checkStatusPass,
+			expectedDetail: "Deployment ready",
+		},
+		{
+			name:           "missing",
+			expectedStatus: checkStatusFail,
+			expectedDetail: "can't find the policy-recommendation-spark-operator Deployment; the Spark Operator is required by policy recommendation",
+		},
+		{
+			name: "not ready",
+			objects: []runtime.Object{
+				newCheckDeployment(sparkOperatorDeploymentName, 1, 0),
+				newCheckPod("spark-operator", sparkLabels, v1.PodPending),
+			},
+			expectedStatus: checkStatusFail,
+			expectedDetail: "0/1 replicas of the policy-recommendation-spark-operator Deployment are ready, please check its Pods with \"kubectl -n flow-visibility describe pods\"; the Spark Operator is required by policy recommendation",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checkSparkOperatorHealth(fake.NewSimpleClientset(tt.objects...))
+			assert.Equal(t, tt.expectedStatus, result.status)
+			assert.Equal(t, tt.expectedDetail, result.details)
+		})
+	}
+}
+
+func TestCheckClickHouseHealth(t *testing.T) {
+	clickHouseLabels := map[string]string{"app": "clickhouse"}
+	testCases := []struct {
+		name           string
+		objects        []runtime.Object
+		expectedStatus string
+		expectedDetail string
+	}{
+		{
+			name: "ready",
+			objects: []runtime.Object{
+				newCheckStatefulSet("chi-clickhouse-clickhouse-0-0", 1, 1),
+				newCheckStatefulSet("chi-clickhouse-clickhouse-1-0", 1, 1),
+				newCheckPod("chi-clickhouse-clickhouse-0-0-0", clickHouseLabels, v1.PodRunning),
+			},
+			expectedStatus: checkStatusPass,
+			expectedDetail: "2 StatefulSet(s) ready",
+		},
+		{
+			name:           "missing",
+			expectedStatus: checkStatusFail,
+			expectedDetail: "can't find the ClickHouse StatefulSets, please check that the ClickHouse Operator is running",
+		},
+		{
+			name: "not ready",
+			objects: []runtime.Object{
+				newCheckStatefulSet("chi-clickhouse-clickhouse-0-0", 1, 1),
+				newCheckStatefulSet("chi-clickhouse-clickhouse-1-0", 1, 0),
+			},
+			expectedStatus: checkStatusFail,
+			expectedDetail: "StatefulSets not ready: chi-clickhouse-clickhouse-1-0 (0/1 ready), please check their Pods with \"kubectl -n flow-visibility describe pods -l app=clickhouse\"",
+		},
+		{
+			name: "no running Pod",
+			objects: []runtime.Object{
+				newCheckStatefulSet("chi-clickhouse-clickhouse-0-0", 1, 1),
+			},
+			expectedStatus: checkStatusFail,
+			expectedDetail: "can't find the ClickHouse Pod, please check the deployment of ClickHouse",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checkClickHouseHealth(fake.NewSimpleClientset(tt.objects...))
+			assert.Equal(t, tt.expectedStatus, result.status)
+			assert.Equal(t, tt.expectedDetail, result.details)
+		})
+	}
+}
+
+func TestCheckGrafanaHealth(t *testing.T) {
+	grafanaService := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: config.GrafanaServiceName, Namespace: config.FlowVisibilityNS}}
+	testCases := []struct {
+		name           string
+		objects        []runtime.Object
+		expectedStatus string
+		expectedDetail string
+	}{
+		{
+			name:           "ready",
+			objects:        []runtime.Object{newCheckDeployment(config.GrafanaServiceName, 1, 1), grafanaService},
+			expectedStatus: checkStatusPass,
+			expectedDetail: "Deployment ready",
+		},
+		{
+			name:           "missing Deployment",
+			objects:        []runtime.Object{grafanaService},
+			expectedStatus: checkStatusFail,
+			expectedDetail: "can't find the grafana Deployment",
+		},
+		{
+			name:           "missing Service",
+			objects:        []runtime.Object{newCheckDeployment(config.GrafanaServiceName, 1, 1)},
+			expectedStatus: checkStatusFail,
+			expectedDetail: "error services \"grafana\" not found when finding the grafana Service",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checkGrafanaHealth(fake.NewSimpleClientset(tt.objects...))
+			assert.Equal(t, tt.expectedStatus, result.status)
+			assert.Equal(t, tt.expectedDetail, result.details)
+		})
+	}
+}
@@ -41,4 +41,11 @@ func init() {
 		`Enable this option will use ClusterIP instead of port forwarding when connecting to the ClickHouse Service.
 It can only be used when running in cluster.`,
 	)
+	clickHouseCmd.PersistentFlags().String(
+		"clickhouse-ca-cert",
+		"",
+		`Path to a PEM file with the CA certificate(s) used to verify the ClickHouse endpoint. Providing it enables TLS.
+It is only used together with clickhouse-endpoint. The proxy used to reach the endpoint is taken from the
+HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.`,
+	)
 }
@@ -0,0 +1,491 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// backupTables maps the tables which can be exported and imported with
+// "--table" to the column whose time range is selected with "--start-time"
+// and "--end-time".
+var backupTables = map[string]string{
+	"flows":                 "timeInserted",
+	"recommendations":       "timeCreated",
+	"flow_coverage":         "timeCreated",
+	"recommendation_events": "timeCreated",
+}
+
+// backupFormats are the supported formats of the table exports. Parquet is
+// not supported: the Go Parquet libraries depend on Apache Thrift, which is
+// not a dependency of theia.
+var backupFormats = []string{"csv"}
+
+// backupOnlyFlags are the flags of the export command which are only used
+// with "--table", and flowOnlyFlags are the ones which are not used with it.
+var (
+	backupOnlyFlags = []string{"start-time", "end-time", "chunk-interval"}
+	flowOnlyFlags   = []string{"last", "namespace", "limit", "denied", "anonymize", "anonymize-key", "anonymize-mapping", "syslog-server", "syslog-ca-cert"}
+)
+
+const (
+	tableColumnsQuery   = "SELECT name, type FROM system.columns WHERE database = currentDatabase() AND table = ? ORDER BY position"
+	tableTimeRangeQuery = "SELECT min(%[1]s), max(%[1]s) FROM %[2]s"
+	// defaultBackupChunkInterval is the default period of the rows read by
+	// each query of a table export.
+	defaultBackupChunkInterval = "1h"
+	backupTimestampLayout      = "2006-01-02 15:04:05"
+)
+
+// tableColumn is a column of a ClickHouse table, with its ClickHouse type.
+type tableColumn struct {
+	name   string
+	chType string
+}
+
+type tableExportOptions struct {
+	table string
+	start time.Time
+	end   time.Time
+	// chunkInterval is the period of the rows read by each query, which
+	// bounds the memory used by ClickHouse and by the CLI.
+	chunkInterval time.Duration
+}
+
+var clickHouseImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import rows into a ClickHouse table",
+	Long: `Import the rows of a file exported with "theia clickhouse export --table"
+into the table, e.g. to restore a backup. The columns are read from the header
+of the file, so that the file can be imported into a table with more columns,
+which are set to their default value. The rows are inserted in batches.
+
+The rows are added to the ones already in the table: importing the same file
+twice duplicates them. If the import fails, e.g. because of an invalid value,
+the rows read before the error may have been inserted.`,
+	Args: cobra.NoArgs,
+	Example: `
+Restore the flows exported to flows.csv
+$ theia clickhouse import --table flows --file flows.csv
+`,
+	RunE: importTable,
+}
+
+// getTableFlag returns the table selected with "--table", or an empty string
+// if it is not set.
+func getTableFlag(cmd *cobra.Command) (string, error) {
+	table, err := cmd.Flags().GetString("table")
+	if err != nil {
+		return "", err
+	}
+	if table == "" {
+		return "", nil
+	}
+	tables := make([]string, 0, len(backupTables))
+	for name := range backupTables {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	if err := validation.OneOf("table", table, tables...); err != nil {
+		return "", err
+	}
+	return table, nil
+}
+
+// validateBackupFormat returns an error if the format of a table export is not
+// supported.
+func validateBackupFormat(format string) error {
+	if format == "parquet" {
+		return fmt.Errorf("format parquet is not supported, tables can only be exported and imported as csv")
+	}
+	return validation.OneOf("format", format, backupFormats...)
+}
+
+// checkUnusedFlags returns an error if one of the flags is set.
+func checkUnusedFlags(cmd *cobra.Command, names []string, reason string) error {
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("%s cannot be used %s", name, reason)
+		}
+	}
+	return nil
+}
+
+// exportTableCmd runs "theia clickhouse export" with "--table".
+func exportTableCmd(cmd *cobra.Command, table string) error {
+	if err := checkUnusedFlags(cmd, flowOnlyFlags, "together with table"); err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if err := validateBackupFormat(format); err != nil {
+		return err
+	}
+	startTime, err := cmd.Flags().GetString("start-time")
+	if err != nil {
+		return err
+	}
+	endTime, err := cmd.Flags().GetString("end-time")
+	if err != nil {
+		return err
+	}
+	chunkIntervalStr, err := cmd.Flags().GetString("chunk-interval")
+	if err != nil {
+		return err
+	}
+	chunkInterval, err := ParseDuration(chunkIntervalStr)
+	if err != nil {
+		return fmt.Errorf("parsing chunk-interval: %v", err)
+	}
+	if chunkInterval <= 0 {
+		return fmt.Errorf("chunk-interval should be a positive duration")
+	}
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	options := tableExportOptions{table: table, chunkInterval: chunkInterval}
+	if startTime != "" {
+		if options.start, err = ParseTimestamp(startTime, time.UTC); err != nil {
+			return fmt.Errorf("parsing start-time: %v", err)
+		}
+	}
+	if endTime != "" {
+		if options.end, err = ParseTimestamp(endTime, time.UTC); err != nil {
+			return fmt.Errorf("parsing end-time: %v", err)
+		}
+	}
+	if !options.start.IsZero() && !options.end.IsZero() && !options.end.After(options.start) {
+		return fmt.Errorf("end-time should be after start-time")
+	}
+
+	db, closeDB, err := openClickHouse(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	out := cmd.OutOrStdout()
+	if filePath != "" {
+		file, err := os.Create(filePath)
+		if err != nil {
+			return fmt.Errorf("error when creating file %s: %v", filePath, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	count, err := writeTable(db, out, options)
+	if err != nil {
+		return err
+	}
+	if filePath != "" {
+		fmt.Fprintf(infoOut(cmd), "Exported %d rows of table %s to %s\n", count, table, filePath)
+	}
+	return nil
+}
+
+// getTableColumns returns the columns of a table, in the order of the table
+// definition.
+func getTableColumns(db *sql.DB, table string) ([]tableColumn, error) {
+	rows, err := db.Query(tableColumnsQuery, table)
+	if err != nil {
+		return nil, fmt.Errorf("error when getting the columns of table %s: %v", table, err)
+	}
+	defer rows.Close()
+	var columns []tableColumn
+	for rows.Next() {
+		var column tableColumn
+		if err := rows.Scan(&column.name, &column.chType); err != nil {
+			return nil, fmt.Errorf("error when getting the columns of table %s: %v", table, err)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when getting the columns of table %s: %v", table, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", table)
+	}
+	return columns, nil
+}
+
+// tableChunkQuery returns the query of the rows of a table in a time range.
+// All values are converted to strings by ClickHouse, timestamps being in UTC.
+func tableChunkQuery(table string, timeColumn string, columns []tableColumn) string {
+	expressions := make([]string, len(columns))
+	for i, column := range columns {
+		if strings.HasPrefix(column.chType, "DateTime") {
+			expressions[i] = fmt.Sprintf("toString(%s, 'UTC')", column.name)
+		} else {
+			expressions[i] = fmt.Sprintf("toString(%s)", column.name)
+		}
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s >= ? AND %s < ? ORDER BY %s",
+		strings.Join(expressions, ", "), table, timeColumn, timeColumn, timeColumn)
+}
+
+// writeTable writes the rows of a table in the time range of options to out as
+// CSV, with a header, and returns the number of rows written. The rows are
+// read one chunk interval at a time. The time range defaults to the one of the
+// rows of the table.
+func writeTable(db *sql.DB, out io.Writer, options tableExportOptions) (int, error) {
+	timeColumn := backupTables[options.table]
+	columns, err := getTableColumns(db, options.table)
+	if err != nil {
+		return 0, err
+	}
+	if options.start.IsZero() || options.end.IsZero() {
+		var oldest, newest time.Time
+		if err := db.QueryRow(fmt.Sprintf(tableTimeRangeQuery, timeColumn, options.table)).Scan(&oldest, &newest); err != nil {
+			return 0, fmt.Errorf("error when getting the time range of table %s: %v", options.table, err)
+		}
+		if options.start.IsZero() {
+			options.start = oldest.UTC()
+		}
+		if options.end.IsZero() {
+			// The end of the range is excluded.
+			options.end = newest.UTC().Add(time.Second)
+		}
+	}
+
+	csvWriter := csv.NewWriter(out)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return 0, fmt.Errorf("error when writing row: %v", err)
+	}
+	query := tableChunkQuery(options.table, timeColumn, columns)
+	values := make([]string, len(columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	count := 0
+	for chunkStart := options.start; chunkStart.Before(options.end); chunkStart = chunkStart.Add(options.chunkInterval) {
+		chunkEnd := chunkStart.Add(options.chunkInterval)
+		if chunkEnd.After(options.end) {
+			chunkEnd = options.end
+		}
+		if err := func() error {
+			rows, err := db.QueryContext(context.TODO(), query, chunkStart, chunkEnd)
+			if err != nil {
+				return fmt.Errorf("error when getting the rows of table %s: %v", options.table, err)
+			}
+			defer rows.Close()
+			for rows.Next() {
+				if err := rows.Scan(dest...); err != nil {
+					return fmt.Errorf("failed to parse the data returned by database: %v", err)
+				}
+				if err := csvWriter.Write(values); err != nil {
+					return fmt.Errorf("error when writing row: %v", err)
+				}
+				count++
+			}
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("error when getting the rows of table %s: %v", options.table, err)
+			}
+			// Flush each chunk, so that the rows are not buffered until
+			// the end of the export.
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return fmt.Errorf("error when writing row: %v", err)
+			}
+			return nil
+		}(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// columnParser returns the function converting the values of a column
+// exported by writeTable to the type expected by the ClickHouse driver.
+func columnParser(column tableColumn) (func(value string) (interface{}, error), error) {
+	switch {
+	case column.chType == "String":
+		return func(value string) (interface{}, error) {
+			return value, nil
+		}, nil
+	case column.chType == "DateTime":
+		return func(value string) (interface{}, error) {
+			return time.ParseInLocation(backupTimestampLayout, value, time.UTC)
+		}, nil
+	case strings.HasPrefix(column.chType, "UInt"):
+		return func(value string) (interface{}, error) {
+			return strconv.ParseUint(value, 10, 64)
+		}, nil
+	case strings.HasPrefix(column.chType, "Int"):
+		return func(value string) (interface{}, error) {
+			return strconv.ParseInt(value, 10, 64)
+		}, nil
+	case strings.HasPrefix(column.chType, "Float"):
+		return func(value string) (interface{}, error) {
+			return strconv.ParseFloat(value, 64)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s of column %s", column.chType, column.name)
+}
+
+func importTable(cmd *cobra.Command, args []string) error {
+	table, err := getTableFlag(cmd)
+	if err != nil {
+		return err
+	}
+	if table == "" {
+		return fmt.Errorf("table is required")
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if err := validateBackupFormat(format); err != nil {
+		return err
+	}
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	if filePath == "" {
+		return fmt.Errorf("file is required")
+	}
+	batchSize, err := cmd.Flags().GetInt("batch-size")
+	if err != nil {
+		return err
+	}
+	if err := validation.Positive("batch-size", int64(batchSize)); err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error when opening file %s: %v", filePath, err)
+	}
+	defer file.Close()
+
+	db, closeDB, err := openClickHouse(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	count, err := readTable(db, file, table, batchSize)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(infoOut(cmd), "Imported %d rows from %s to table %s\n", count, filePath, table)
+	return nil
+}
+
+// readTable inserts the rows read from in, in the CSV format written by
+// writeTable, into a table in batches of batchSize rows, and returns the number
+// of rows inserted.
+func readTable(db *sql.DB, in io.Reader, table string, batchSize int) (int, error) {
+	tableColumns, err := getTableColumns(db, table)
+	if err != nil {
+		return 0, err
+	}
+	columnsByName := make(map[string]tableColumn, len(tableColumns))
+	for _, column := range tableColumns {
+		columnsByName[column.name] = column
+	}
+	csvReader := csv.NewReader(in)
+	header, err := csvReader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("error when reading the header of the file: %v", err)
+	}
+	parsers := make([]func(value string) (interface{}, error), len(header))
+	for i, name := range header {
+		column, ok := columnsByName[name]
+		if !ok {
+			return 0, fmt.Errorf("column %s is not in table %s", name, table)
+		}
+		if parsers[i], err = columnParser(column); err != nil {
+			return 0, err
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(header)), ", ")
+	writer := clickhouse.NewBatchWriter(db, clickhouse.BatchWriterConfig{
+		Query:     fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(header, ", "), placeholders),
+		BatchSize: batchSize,
+	})
+	count := 0
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writer.Close()
+			return count, fmt.Errorf("error when reading the file: %v", err)
+		}
+		row := make([]interface{}, len(record))
+		for i, value := range record {
+			if row[i], err = parsers[i](value); err != nil {
+				writer.Close()
+				line, _ := csvReader.FieldPos(i)
+				return count, fmt.Errorf("invalid value %q of column %s on line %d: %v", value, header[i], line, err)
+			}
+		}
+		if err := writer.Write(context.TODO(), row...); err != nil {
+			writer.Close()
+			return count, fmt.Errorf("error when importing rows into table %s: %v", table, err)
+		}
+		count++
+	}
+	if err := writer.Close(); err != nil {
+		return count, fmt.Errorf("error when importing rows into table %s: %v", table, err)
+	}
+	return count, nil
+}
+
+func init() {
+	clickHouseCmd.AddCommand(clickHouseImportCmd)
+	clickHouseImportCmd.Flags().String(
+		"table",
+		"",
+		"The table to import the rows into: flows, recommendations, flow_coverage or recommendation_events.",
+	)
+	clickHouseImportCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file exported with \"theia clickhouse export --table\" to import.",
+	)
+	clickHouseImportCmd.Flags().String(
+		"format",
+		"csv",
+		"The format of the file. Only csv is supported, Parquet is not supported.",
+	)
+	clickHouseImportCmd.Flags().Int(
+		"batch-size",
+		10000,
+		"The number of rows inserted at once.",
+	)
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTableColumnRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"name", "type"}).
+		AddRow("timeInserted", "DateTime").
+		AddRow("sourceIP", "String").
+		AddRow("octetTotalCount", "UInt64")
+}
+
+func TestWriteTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	mock.ExpectQuery(regexp.QuoteMeta(tableColumnsQuery)).
+		WithArgs("flows").
+		WillReturnRows(newTableColumnRows())
+	chunkQuery := regexp.QuoteMeta("SELECT toString(timeInserted, 'UTC'), toString(sourceIP), toString(octetTotalCount) FROM flows WHERE timeInserted >= ? AND timeInserted < ? ORDER BY timeInserted")
+	mock.ExpectQuery(chunkQuery).
+		WithArgs(start, start.Add(time.Hour)).
+		WillReturnRows(sqlmock.NewRows([]string{"timeInserted", "sourceIP", "octetTotalCount"}).
+			AddRow("2023-01-01 00:10:00", "10.10.0.4", "120").
+			AddRow("2023-01-01 00:20:00", "10.10.0.5", "240"))
+	mock.ExpectQuery(chunkQuery).
+		WithArgs(start.Add(time.Hour), end).
+		WillReturnRows(sqlmock.NewRows([]string{"timeInserted", "sourceIP", "octetTotalCount"}).
+			AddRow("2023-01-01 01:10:00", "10.10.0.6", "360"))
+
+	var out bytes.Buffer
+	count, err := writeTable(db, &out, tableExportOptions{table: "flows", start: start, end: end, chunkInterval: time.Hour})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 3, count)
+	assert.Equal(t, `timeInserted,sourceIP,octetTotalCount
+2023-01-01 00:10:00,10.10.0.4,120
+2023-01-01 00:20:00,10.10.0.5,240
+2023-01-01 01:10:00,10.10.0.6,360
+`, out.String())
+}
+
+func TestWriteTableDefaultTimeRange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	oldest := time.Date(2023, 1, 1, 0, 10, 0, 0, time.UTC)
+	newest := time.Date(2023, 1, 1, 0, 20, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(tableColumnsQuery)).
+		WithArgs("recommendations").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "type"}).
+			AddRow("id", "String").
+			AddRow("timeCreated", "DateTime"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT min(timeCreated), max(timeCreated) FROM recommendations")).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max"}).AddRow(oldest, newest))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM recommendations WHERE timeCreated >= ? AND timeCreated < ?")).
+		WithArgs(oldest, newest.Add(time.Second)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "timeCreated"}).
+			AddRow("e998433e-accb-4888-9fc8-06563f073e86", "2023-01-01 00:10:00"))
+
+	var out bytes.Buffer
+	count, err := writeTable(db, &out, tableExportOptions{table: "recommendations", chunkInterval: time.Hour})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, count)
+}
+
+func TestReadTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(tableColumnsQuery)).
+		WithArgs("flows").
+		WillReturnRows(newTableColumnRows())
+	insertQuery := regexp.QuoteMeta("INSERT INTO flows (sourceIP, timeInserted, octetTotalCount) VALUES (?, ?, ?)")
+	mock.ExpectBegin()
+	prepare := mock.ExpectPrepare(insertQuery)
+	prepare.ExpectExec().
+		WithArgs("10.10.0.4", time.Date(2023, 1, 1, 0, 10, 0, 0, time.UTC), uint64(120)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prepare.ExpectExec().
+		WithArgs("10.10.0.5", time.Date(2023, 1, 1, 0, 20, 0, 0, time.UTC), uint64(240)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectPrepare(insertQuery).ExpectExec().
+		WithArgs("10.10.0.6", time.Date(2023, 1, 1, 1, 10, 0, 0, time.UTC), uint64(360)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	in := strings.NewReader(`sourceIP,timeInserted,octetTotalCount
+10.10.0.4,2023-01-01 00:10:00,120
+10.10.0.5,2023-01-01 00:20:00,240
+10.10.0.6,2023-01-01 01:10:00,360
+`)
+	count, err := readTable(db, in, "flows", 2)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 3, count)
+}
+
+func TestReadTableErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		in            string
+		expectedError string
+	}{
+		{
+			name:          "unknown column",
+			in:            "sourceIP,destinationIP\n10.10.0.4,10.10.0.5\n",
+			expectedError: "column destinationIP is not in table flows",
+		},
+		{
+			name:          "invalid value",
+			in:            "sourceIP,octetTotalCount\n10.10.0.4,120\n10.10.0.5,-1\n",
+			expectedError: `invalid value "-1" of column octetTotalCount on line 3`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta(tableColumnsQuery)).
+				WithArgs("flows").
+				WillReturnRows(newTableColumnRows())
+			// The rows read before the invalid value are inserted.
+			mock.MatchExpectationsInOrder(false)
+			mock.ExpectBegin()
+			mock.ExpectPrepare("INSERT INTO flows").ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			_, err = readTable(db, strings.NewReader(tc.in), "flows", 10)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedError)
+		})
+	}
+}
+
+func TestColumnParser(t *testing.T) {
+	_, err := columnParser(tableColumn{name: "labels", chType: "Map(String, String)"})
+	assert.EqualError(t, err, "unsupported type Map(String, String) of column labels")
+
+	parse, err := columnParser(tableColumn{name: "throughput", chType: "Float64"})
+	require.NoError(t, err)
+	value, err := parse("1.5")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, value)
+}
+
+func TestValidateBackupFormat(t *testing.T) {
+	assert.NoError(t, validateBackupFormat("csv"))
+	assert.EqualError(t, validateBackupFormat("parquet"), "format parquet is not supported, tables can only be exported and imported as csv")
+	assert.Error(t, validateBackupFormat("json"))
+}
@@ -0,0 +1,465 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/anonymize"
+	"antrea.io/theia/pkg/util/syslog"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// anonymizeKeyEnv is the environment variable with the key used by the hash
+// anonymization mode, to avoid providing it on the command line.
+const anonymizeKeyEnv = "THEIA_ANONYMIZE_KEY"
+
+// exportColumn is a column of the flows table included in the exports, with
+// the kind of identifier it holds if it has to be anonymized.
+type exportColumn struct {
+	name string
+	kind anonymize.Kind
+}
+
+// flowExportColumns are the columns of the flows table included in the
+// exports. The Pod labels are left out since they cannot be anonymized
+// without losing their meaning.
+var flowExportColumns = []exportColumn{
+	{name: "flowStartSeconds"},
+	{name: "flowEndSeconds"},
+	{name: "flowEndReason"},
+	{name: "sourceIP", kind: anonymize.KindIP},
+	{name: "destinationIP", kind: anonymize.KindIP},
+	{name: "sourceTransportPort"},
+	{name: "destinationTransportPort"},
+	{name: "protocolIdentifier"},
+	{name: "packetTotalCount"},
+	{name: "octetTotalCount"},
+	{name: "reversePacketTotalCount"},
+	{name: "reverseOctetTotalCount"},
+	{name: "sourcePodName", kind: anonymize.KindPod},
+	{name: "sourcePodNamespace", kind: anonymize.KindNamespace},
+	{name: "sourceNodeName", kind: anonymize.KindNode},
+	{name: "destinationPodName", kind: anonymize.KindPod},
+	{name: "destinationPodNamespace", kind: anonymize.KindNamespace},
+	{name: "destinationNodeName", kind: anonymize.KindNode},
+	{name: "destinationClusterIP", kind: anonymize.KindIP},
+	{name: "destinationServicePort"},
+	// The kind is only used to select the column in anonymizeFlow, Service
+	// port names are anonymized with AnonymizeServicePortName.
+	{name: "destinationServicePortName", kind: anonymize.KindService},
+	{name: "ingressNetworkPolicyName", kind: anonymize.KindPolicy},
+	{name: "ingressNetworkPolicyNamespace", kind: anonymize.KindNamespace},
+	{name: "ingressNetworkPolicyRuleName"},
+	{name: "ingressNetworkPolicyRuleAction"},
+	{name: "egressNetworkPolicyName", kind: anonymize.KindPolicy},
+	{name: "egressNetworkPolicyNamespace", kind: anonymize.KindNamespace},
+	{name: "egressNetworkPolicyRuleName"},
+	{name: "egressNetworkPolicyRuleAction"},
+	{name: "tcpState"},
+	{name: "flowType"},
+	{name: "throughput"},
+	{name: "reverseThroughput"},
+}
+
+type flowExportOptions struct {
+	start     time.Time
+	namespace string
+	limit     int
+	format    string
+	// denied only exports the flows dropped or rejected by a network
+	// policy.
+	denied bool
+	// syslogWriter sends the syslog and cef exports to a syslog server
+	// instead of writing them to out when it is not nil.
+	syslogWriter *syslog.Writer
+}
+
+// flowExportFormats are the supported export formats.
+var flowExportFormats = []string{"csv", "json", syslog.FormatRFC5424, syslog.FormatCEF}
+
+var clickHouseExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export flow records from ClickHouse",
+	Long: `Export the flow records stored in ClickHouse as CSV or JSON.
+The IP addresses, and the names of the Pods, Nodes, Namespaces, Services and
+network policies can be anonymized, so that the flows can be shared, e.g.
+with support, without disclosing the topology of the cluster. The same
+identifier is always replaced with the same pseudonym, so that the flows can
+still be correlated:
+- with "--anonymize hash", identifiers are replaced with a keyed hash. The
+  pseudonyms are consistent across exports using the same key.
+- with "--anonymize map", identifiers are replaced with sequential pseudonyms,
+  e.g. pod-1, and the mapping is saved to the file provided with
+  "--anonymize-mapping" so that the pseudonyms can be translated back. The
+  mapping is loaded from this file first if it exists, to keep the pseudonyms
+  consistent across exports.
+
+With "--format syslog" or "--format cef", each flow is exported as an RFC 5424
+syslog message or as a CEF record, the flows denied by network policies being
+"denied-flow" events, so that they can be ingested by SIEM and SOC tooling.
+With "--syslog-server", the events are sent to a syslog server instead.
+
+With "--table", all the columns of the rows of a table inserted or created
+between "--start-time" and "--end-time" are exported as CSV instead, without
+anonymization, so that they can be analyzed offline or restored with "theia
+clickhouse import". The rows are read one "--chunk-interval" at a time, to
+bound the memory used by large exports. Only CSV is supported with "--table":
+Parquet is not supported, as the Go Parquet libraries depend on Apache Thrift,
+which is not a dependency of theia.`,
+	Args: cobra.NoArgs,
+	Example: `
+Export the flows of the last hour as CSV
+$ theia clickhouse export --last 1h --file flows.csv
+Export the flows of namespace app-a as JSON, with hashed identifiers
+$ THEIA_ANONYMIZE_KEY=<key> theia clickhouse export --namespace app-a --format json --anonymize hash
+Export the flows of the last day, with sequential pseudonyms
+$ theia clickhouse export --last 1d --anonymize map --anonymize-mapping mapping.json --file flows.csv
+Send the flows denied in the last 5 minutes to a syslog server as CEF records
+$ theia clickhouse export --last 5m --denied --format cef --syslog-server tls://siem.example.com:6514
+Back up the flows inserted in January 2023
+$ theia clickhouse export --table flows --start-time '2023-01-01 00:00:00' --end-time '2023-02-01 00:00:00' --file flows.csv
+`,
+	RunE: exportClickHouse,
+}
+
+// exportClickHouse exports the rows of a table with "--table", and the flows
+// otherwise.
+func exportClickHouse(cmd *cobra.Command, args []string) error {
+	table, err := getTableFlag(cmd)
+	if err != nil {
+		return err
+	}
+	if table != "" {
+		return exportTableCmd(cmd, table)
+	}
+	if err := checkUnusedFlags(cmd, backupOnlyFlags, "without table"); err != nil {
+		return err
+	}
+	return exportFlows(cmd, args)
+}
+
+func exportFlows(cmd *cobra.Command, args []string) error {
+	filter, err := getFlowsFilter(cmd)
+	if err != nil {
+		return err
+	}
+	limit, err := getFlowsLimit(cmd)
+	if err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if err := validation.OneOf("format", format, flowExportFormats...); err != nil {
+		return err
+	}
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	denied, err := cmd.Flags().GetBool("denied")
+	if err != nil {
+		return err
+	}
+	syslogServer, err := cmd.Flags().GetString("syslog-server")
+	if err != nil {
+		return err
+	}
+	syslogCACert, err := cmd.Flags().GetString("syslog-ca-cert")
+	if err != nil {
+		return err
+	}
+	if syslogServer != "" {
+		if format != syslog.FormatRFC5424 && format != syslog.FormatCEF {
+			return fmt.Errorf("syslog-server requires the %s or %s format", syslog.FormatRFC5424, syslog.FormatCEF)
+		}
+		if filePath != "" {
+			return fmt.Errorf("file and syslog-server cannot be used together")
+		}
+	}
+	anonymizer, mappingPath, err := newFlowAnonymizer(cmd)
+	if err != nil {
+		return err
+	}
+	backend, closeBackend, err := openFlowsBackend(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeBackend()
+
+	var syslogWriter *syslog.Writer
+	if syslogServer != "" {
+		syslogWriter, err = syslog.Dial(syslogServer, syslogCACert)
+		if err != nil {
+			return err
+		}
+		defer syslogWriter.Close()
+	}
+
+	out := cmd.OutOrStdout()
+	if filePath != "" {
+		file, err := os.Create(filePath)
+		if err != nil {
+			return fmt.Errorf("error when creating file %s: %v", filePath, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	options := flowExportOptions{
+		start:        filter.Start,
+		namespace:    filter.Namespace,
+		limit:        limit,
+		format:       format,
+		denied:       denied,
+		syslogWriter: syslogWriter,
+	}
+	count, err := writeFlows(backend, out, options, anonymizer)
+	if err != nil {
+		return err
+	}
+	if mappingPath != "" {
+		if err := anonymizer.SaveMapping(mappingPath); err != nil {
+			return err
+		}
+	}
+	if filePath != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "Exported %d flows to %s\n", count, filePath)
+	} else if syslogServer != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "Sent %d flows to syslog server %s\n", count, syslogServer)
+	}
+	return nil
+}
+
+// newFlowAnonymizer returns the Anonymizer selected by the flags, and the path
+// of the mapping file to save in the map mode.
+func newFlowAnonymizer(cmd *cobra.Command) (*anonymize.Anonymizer, string, error) {
+	modeStr, err := cmd.Flags().GetString("anonymize")
+	if err != nil {
+		return nil, "", err
+	}
+	mode, err := anonymize.ParseMode(modeStr)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := cmd.Flags().GetString("anonymize-key")
+	if err != nil {
+		return nil, "", err
+	}
+	if key == "" {
+		key = os.Getenv(anonymizeKeyEnv)
+	}
+	mappingPath, err := cmd.Flags().GetString("anonymize-mapping")
+	if err != nil {
+		return nil, "", err
+	}
+	if mode == anonymize.ModeMap && mappingPath == "" {
+		return nil, "", fmt.Errorf("anonymize-mapping is required with the %s anonymization mode, otherwise the pseudonyms cannot be kept consistent across exports", anonymize.ModeMap)
+	}
+	if mode != anonymize.ModeMap {
+		mappingPath = ""
+	}
+	anonymizer, err := anonymize.New(mode, []byte(key))
+	if err != nil {
+		return nil, "", fmt.Errorf("%v, provide it with anonymize-key or the %s environment variable", err, anonymizeKeyEnv)
+	}
+	if mappingPath != "" {
+		if err := anonymizer.LoadMapping(mappingPath); err != nil {
+			return nil, "", err
+		}
+	}
+	return anonymizer, mappingPath, nil
+}
+
+// anonymizeFlow anonymizes in place the values of a flow, given in the order
+// of flowExportColumns.
+func anonymizeFlow(anonymizer *anonymize.Anonymizer, values []string) {
+	for i, column := range flowExportColumns {
+		switch column.kind {
+		case "":
+		case anonymize.KindService:
+			values[i] = anonymizer.AnonymizeServicePortName(values[i])
+		default:
+			values[i] = anonymizer.Anonymize(column.kind, values[i])
+		}
+	}
+}
+
+// writeFlows writes the flows selected by options to out, and returns the
+// number of flows written.
+func writeFlows(backend flows.Backend, out io.Writer, options flowExportOptions, anonymizer *anonymize.Anonymizer) (int, error) {
+	if backend.Dialect() == flows.ClickHouse {
+		if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+			return 0, fmt.Errorf("failed to get flows from clickhouse: %v", err)
+		}
+	}
+	columnNames := make([]string, len(flowExportColumns))
+	for i, column := range flowExportColumns {
+		columnNames[i] = column.name
+	}
+	var csvWriter *csv.Writer
+	var jsonEncoder *json.Encoder
+	var eventFormatter *syslog.Formatter
+	switch options.format {
+	case "csv":
+		csvWriter = csv.NewWriter(out)
+		if err := csvWriter.Write(columnNames); err != nil {
+			return 0, err
+		}
+	case "json":
+		// Flows are written as JSON lines, so that large exports can be
+		// processed without loading them at once.
+		jsonEncoder = json.NewEncoder(out)
+	default:
+		hostname, _ := os.Hostname()
+		var err error
+		eventFormatter, err = syslog.NewFormatter(options.format, options.syslogWriter != nil, hostname, "theia", theiaVersion())
+		if err != nil {
+			return 0, err
+		}
+	}
+	filter := flows.Filter{Start: options.start, Namespace: options.namespace, Denied: options.denied}
+	count := 0
+	err := backend.Export(context.TODO(), filter, columnNames, options.limit, func(values []string) error {
+		anonymizeFlow(anonymizer, values)
+		var err error
+		switch {
+		case csvWriter != nil:
+			err = csvWriter.Write(values)
+		case jsonEncoder != nil:
+			flow := make(map[string]string, len(values))
+			for i, name := range columnNames {
+				flow[name] = values[i]
+			}
+			err = jsonEncoder.Encode(flow)
+		case options.syslogWriter != nil:
+			err = options.syslogWriter.WriteMessage(eventFormatter.Format(flowEvent(columnNames, values)))
+		default:
+			_, err = fmt.Fprintln(out, eventFormatter.Format(flowEvent(columnNames, values)))
+		}
+		if err != nil {
+			return fmt.Errorf("error when writing flow: %v", err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return count, fmt.Errorf("error when writing flow: %v", err)
+		}
+	}
+	return count, nil
+}
+
+// addFlowExportFlags adds the flags of the export commands, which are the
+// same whatever the backend.
+func addFlowExportFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"last",
+		"1h",
+		"Only export the flows recorded in this duration before now, e.g. 12h or 7d.",
+	)
+	cmd.Flags().StringP(
+		"namespace",
+		"n",
+		"",
+		"Only export the flows from or to this Namespace.",
+	)
+	cmd.Flags().Int(
+		"limit",
+		0,
+		"The maximum number of flows to export. 0 means no limit.",
+	)
+	cmd.Flags().String(
+		"format",
+		"csv",
+		"The format of the export: csv, json (one JSON object per line), syslog (RFC 5424 messages) or cef (CEF records).",
+	)
+	cmd.Flags().Bool(
+		"denied",
+		false,
+		"Only export the flows dropped or rejected by a network policy.",
+	)
+	cmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file path where you want to save the flows. The flows are written to stdout by default.",
+	)
+	cmd.Flags().String(
+		"anonymize",
+		string(anonymize.ModeNone),
+		"How to anonymize the IP addresses and the object names in the flows: none, hash or map.",
+	)
+	cmd.Flags().String(
+		"anonymize-key",
+		"",
+		fmt.Sprintf("The key used with \"--anonymize hash\". Defaults to the %s environment variable.", anonymizeKeyEnv),
+	)
+	cmd.Flags().String(
+		"anonymize-mapping",
+		"",
+		"The file where the mapping of the pseudonyms is loaded from and saved to with \"--anonymize map\".",
+	)
+	cmd.Flags().String(
+		"syslog-server",
+		"",
+		"Send the syslog or cef export to this syslog server, e.g. udp://siem:514, tcp://siem:514 or tls://siem:6514.",
+	)
+	cmd.Flags().String(
+		"syslog-ca-cert",
+		"",
+		"The CA certificate used to verify the syslog server with a tls:// server. Defaults to the system CA certificates.",
+	)
+}
+
+func init() {
+	clickHouseCmd.AddCommand(clickHouseExportCmd)
+	addFlowExportFlags(clickHouseExportCmd)
+	clickHouseExportCmd.Flags().String(
+		"table",
+		"",
+		"Export all the columns of this table for backup instead of the flows: flows, recommendations, flow_coverage or recommendation_events.",
+	)
+	clickHouseExportCmd.Flags().String(
+		"start-time",
+		"",
+		"With table, the start of the time range of the exported rows, in RFC3339 or YYYY-MM-DD hh:mm:ss UTC format. Defaults to the oldest row.",
+	)
+	clickHouseExportCmd.Flags().String(
+		"end-time",
+		"",
+		"With table, the end of the time range of the exported rows, excluded. Defaults to after the newest row.",
+	)
+	clickHouseExportCmd.Flags().String(
+		"chunk-interval",
+		defaultBackupChunkInterval,
+		"With table, the period of the rows read from ClickHouse at once, e.g. 30m or 1d.",
+	)
+}
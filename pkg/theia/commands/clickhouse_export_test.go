@@ -0,0 +1,199 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/anonymize"
+)
+
+func testExportFlowRow(sourcePod, sourceIP string) []driver.Value {
+	values := map[string]string{
+		"flowEndSeconds":             "2022-08-01 12:00:00",
+		"sourceIP":                   sourceIP,
+		"destinationIP":              "10.10.1.5",
+		"destinationTransportPort":   "80",
+		"sourcePodName":              sourcePod,
+		"sourcePodNamespace":         "app-a",
+		"destinationPodName":         "backend",
+		"destinationPodNamespace":    "app-b",
+		"destinationClusterIP":       "10.96.0.10",
+		"destinationServicePortName": "app-b/backend:http",
+	}
+	row := make([]driver.Value, len(flowExportColumns))
+	for i, column := range flowExportColumns {
+		row[i] = values[column.name]
+	}
+	return row
+}
+
+func newExportFlowRows() *sqlmock.Rows {
+	names := make([]string, len(flowExportColumns))
+	for i, column := range flowExportColumns {
+		names[i] = column.name
+	}
+	return sqlmock.NewRows(names).
+		AddRow(testExportFlowRow("frontend-a", "10.10.0.4")...).
+		AddRow(testExportFlowRow("frontend-b", "10.10.0.5")...)
+}
+
+func TestWriteFlowsCSV(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM flows WHERE flowEndSeconds >= ?")).
+		WithArgs(start).
+		WillReturnRows(newExportFlowRows())
+	anonymizer, err := anonymize.New(anonymize.ModeMap, nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	count, err := writeFlows(flows.NewBackend(db, flows.ClickHouse), &out, flowExportOptions{start: start, format: "csv"}, anonymizer)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, count)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "flowStartSeconds,flowEndSeconds,flowEndReason,sourceIP,destinationIP,"))
+	assert.Equal(t, ",2022-08-01 12:00:00,,10.0.0.1,10.0.0.2,,80,,,,,,pod-1,ns-1,,pod-2,ns-2,,10.0.0.3,,ns-2/svc-1:http,,,,,,,,,,,,", lines[1])
+	assert.Equal(t, ",2022-08-01 12:00:00,,10.0.0.4,10.0.0.2,,80,,,,,,pod-3,ns-1,,pod-2,ns-2,,10.0.0.3,,ns-2/svc-1:http,,,,,,,,,,,,", lines[2])
+	assert.NotContains(t, out.String(), "frontend")
+	assert.NotContains(t, out.String(), "10.10.")
+}
+
+func TestWriteFlowsJSON(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?)")).
+		WithArgs(start, "app-a", "app-a").
+		WillReturnRows(newExportFlowRows())
+	anonymizer, err := anonymize.New(anonymize.ModeNone, nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	count, err := writeFlows(flows.NewBackend(db, flows.ClickHouse), &out, flowExportOptions{start: start, namespace: "app-a", format: "json"}, anonymizer)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, count)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"sourcePodName":"frontend-a"`)
+	assert.Contains(t, lines[1], `"sourceIP":"10.10.0.5"`)
+}
+
+func TestWriteFlowsCEF(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	names := make([]string, len(flowExportColumns))
+	row := make([]driver.Value, len(flowExportColumns))
+	values := map[string]string{
+		"flowStartSeconds":               "2022-08-01 11:59:00",
+		"flowEndSeconds":                 "2022-08-01 12:00:00",
+		"sourceIP":                       "10.10.0.4",
+		"destinationIP":                  "10.10.1.5",
+		"sourceTransportPort":            "36512",
+		"destinationTransportPort":       "5432",
+		"protocolIdentifier":             "6",
+		"octetTotalCount":                "120",
+		"reverseOctetTotalCount":         "0",
+		"sourcePodName":                  "frontend",
+		"sourcePodNamespace":             "app-a",
+		"ingressNetworkPolicyName":       "allow-frontend",
+		"ingressNetworkPolicyNamespace":  "app-b",
+		"ingressNetworkPolicyRuleAction": "1",
+		"egressNetworkPolicyName":        "deny-db",
+		"egressNetworkPolicyRuleName":    "deny=db",
+		"egressNetworkPolicyRuleAction":  "2",
+	}
+	for i, column := range flowExportColumns {
+		names[i] = column.name
+		row[i] = values[column.name]
+	}
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM flows WHERE flowEndSeconds >= ? AND (ingressNetworkPolicyRuleAction IN (2, 3) OR egressNetworkPolicyRuleAction IN (2, 3))")).
+		WithArgs(start).
+		WillReturnRows(sqlmock.NewRows(names).AddRow(row...))
+	anonymizer, err := anonymize.New(anonymize.ModeNone, nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	count, err := writeFlows(flows.NewBackend(db, flows.ClickHouse), &out, flowExportOptions{start: start, format: "cef", denied: true}, anonymizer)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, count)
+
+	record := strings.TrimSpace(out.String())
+	assert.Regexp(t, `^CEF:0\|Antrea\|Theia\|[^|]+\|denied-flow\|Flow denied by network policy\|5\|`, record)
+	assert.True(t, strings.HasSuffix(record, "|rt=1659355200000 src=10.10.0.4 spt=36512 dst=10.10.1.5 dpt=5432 proto=TCP start=1659355140000 end=1659355200000 out=120 in=0 act=Drop cs1Label=sourcePod cs1=app-a/frontend cs4Label=networkPolicy cs4=deny-db cs5Label=networkPolicyRule cs5=deny\\=db cs6Label=networkPolicyDirection cs6=egress"), record)
+}
+
+func TestWriteFlowsOlderSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// the throughput columns are missing from the flows table
+	schema := &clickhouse.FlowSchema{Version: 0, Columns: map[string]bool{}}
+	var names []string
+	var row []driver.Value
+	fullRow := testExportFlowRow("frontend-a", "10.10.0.4")
+	for i, column := range flowExportColumns {
+		if column.name == "throughput" || column.name == "reverseThroughput" {
+			continue
+		}
+		schema.Columns[column.name] = true
+		names = append(names, column.name)
+		row = append(row, fullRow[i])
+	}
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`flowType\) AS flowType\s+FROM flows WHERE flowEndSeconds >= \?`).
+		WithArgs(start).
+		WillReturnRows(sqlmock.NewRows(names).AddRow(row...))
+	anonymizer, err := anonymize.New(anonymize.ModeNone, nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	backend := &clickHouseFlowsBackend{Backend: flows.NewBackend(db, flows.ClickHouse), schema: schema}
+	count, err := writeFlows(backend, &out, flowExportOptions{start: start, format: "csv"}, anonymizer)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, count)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[0], ",tcpState,flowType,throughput,reverseThroughput"))
+	assert.Equal(t, ",2022-08-01 12:00:00,,10.10.0.4,10.10.1.5,,80,,,,,,frontend-a,app-a,,backend,app-b,,10.96.0.10,,app-b/backend:http,,,,,,,,,,,,", lines[1])
+}
@@ -0,0 +1,371 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/theia/commands/config"
+)
+
+// purgeSelectorColumns maps the keys supported by the purge selectors to the
+// columns they match. A flow matches a key if any of the columns matches.
+var purgeSelectorColumns = map[string][]string{
+	"podNamespace": {"sourcePodNamespace", "destinationPodNamespace"},
+	"podName":      {"sourcePodName", "destinationPodName"},
+	"nodeName":     {"sourceNodeName", "destinationNodeName"},
+	"ip":           {"sourceIP", "destinationIP"},
+	"clusterUUID":  {"clusterUUID"},
+}
+
+// purgeTables are the local tables storing flow records. The flows of the
+// materialized views are aggregated from the flows table, and are purged as
+// long as they have the columns used by the purge.
+var purgeTables = []string{
+	"flows_local",
+	"flows_pod_view_local",
+	"flows_node_view_local",
+	"flows_policy_view_local",
+}
+
+type purgeSelector struct {
+	key   string
+	value string
+}
+
+type flowPurge struct {
+	selectors []purgeSelector
+	before    time.Time
+}
+
+var clickHousePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete flow records from ClickHouse",
+	Long: `Delete the flow records matching the selectors from ClickHouse, e.g. to
+comply with data deletion or retention requests. The flows are deleted from the
+flows table, and from the aggregated views which have the columns used by the
+selectors, with ClickHouse mutations. The command waits for the mutations to
+complete on all the shards.
+
+The supported selectors are podNamespace, podName, nodeName and ip, which match
+the source or the destination of the flows, and clusterUUID. When several
+selectors are provided, the flows must match all of them.`,
+	Args: cobra.NoArgs,
+	Example: `
+Delete the flows of Namespace team-x recorded before 2023-01-01 UTC
+$ theia clickhouse purge --selector podNamespace=team-x --before 2023-01-01
+Delete the flows of a Pod without asking for confirmation
+$ theia clickhouse purge --selector podNamespace=team-x,podName=web-0 --yes
+`,
+	RunE: purgeFlows,
+}
+
+func purgeFlows(cmd *cobra.Command, args []string) error {
+	selectorStrs, err := cmd.Flags().GetStringSlice("selector")
+	if err != nil {
+		return err
+	}
+	selectors, err := parsePurgeSelectors(selectorStrs)
+	if err != nil {
+		return err
+	}
+	beforeStr, err := cmd.Flags().GetString("before")
+	if err != nil {
+		return err
+	}
+	purge := flowPurge{selectors: selectors}
+	if beforeStr != "" {
+		purge.before, err = parsePurgeBefore(beforeStr)
+		if err != nil {
+			return err
+		}
+	}
+	if len(purge.selectors) == 0 && purge.before.IsZero() {
+		return fmt.Errorf("at least one of selector or before must be provided, purging all the flows is not supported")
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+	assumeYes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return err
+	}
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return err
+	}
+	if endpoint != "" {
+		err = ParseEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return err
+	}
+	connect, pf, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if pf != nil {
+		defer pf.Stop()
+	}
+	if err != nil {
+		return err
+	}
+	defer connect.Close()
+
+	out := infoOut(cmd)
+	condition, conditionArgs := purge.condition()
+	var count uint64
+	if err := connect.QueryRow("SELECT count() FROM flows WHERE "+condition, conditionArgs...).Scan(&count); err != nil {
+		return fmt.Errorf("error when counting the flows to purge: %v", err)
+	}
+	if count == 0 {
+		fmt.Fprintln(out, "No flows match the purge criteria")
+		return nil
+	}
+	if !assumeYes {
+		confirmed, err := promptConfirmation(bufio.NewReader(cmd.InOrStdin()), cmd.OutOrStdout(), fmt.Sprintf("%d flows will be deleted permanently. Continue?", count))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Fprintln(out, "Purge cancelled")
+			return nil
+		}
+	}
+	tables, err := purge.startMutations(connect, out)
+	if err != nil {
+		return err
+	}
+	if len(tables) > 0 {
+		fmt.Fprintf(out, "Waiting for the deletion to complete on all the shards\n")
+		if err := waitForPurgeMutations(connect, tables, timeout); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "Purged %d flows\n", count)
+	return nil
+}
+
+func parsePurgeSelectors(selectorStrs []string) ([]purgeSelector, error) {
+	var selectors []purgeSelector
+	for _, selectorStr := range selectorStrs {
+		key, value, found := strings.Cut(selectorStr, "=")
+		if !found || value == "" {
+			return nil, fmt.Errorf("selector %s does not seem valid, it should be like podNamespace=team-x", selectorStr)
+		}
+		if _, ok := purgeSelectorColumns[key]; !ok {
+			keys := make([]string, 0, len(purgeSelectorColumns))
+			for k := range purgeSelectorColumns {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return nil, fmt.Errorf("unsupported selector key %s, it should be one of %s", key, strings.Join(keys, ", "))
+		}
+		selectors = append(selectors, purgeSelector{key: key, value: value})
+	}
+	return selectors, nil
+}
+
+// parsePurgeBefore parses a timestamp accepted by ParseTimestamp, or a date in
+// the "YYYY-MM-DD" format, in UTC.
+func parsePurgeBefore(before string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", before); err == nil {
+		return t, nil
+	}
+	return ParseTimestamp(before, time.UTC)
+}
+
+// columns returns the columns used by the purge condition.
+func (p *flowPurge) columns() []string {
+	var columns []string
+	if !p.before.IsZero() {
+		columns = append(columns, "flowEndSeconds")
+	}
+	for _, selector := range p.selectors {
+		columns = append(columns, purgeSelectorColumns[selector.key]...)
+	}
+	return columns
+}
+
+func (p *flowPurge) condition() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if !p.before.IsZero() {
+		conditions = append(conditions, "flowEndSeconds < ?")
+		args = append(args, p.before)
+	}
+	for _, selector := range p.selectors {
+		var matches []string
+		for _, column := range purgeSelectorColumns[selector.key] {
+			matches = append(matches, column+" = ?")
+			args = append(args, selector.value)
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// startMutations deletes the flows from the tables which have all the columns
+// used by the purge. The other tables do not store the identifiers of the
+// selectors. The partitions in which all the flows match the purge are
+// dropped, and mutations are started for the remaining flows. It returns the
+// tables with mutations.
+func (p *flowPurge) startMutations(connect *sql.DB, out io.Writer) ([]string, error) {
+	rows, err := connect.Query("SELECT table, name FROM system.columns WHERE database = currentDatabase() AND table IN (?, ?, ?, ?)",
+		purgeTables[0], purgeTables[1], purgeTables[2], purgeTables[3])
+	if err != nil {
+		return nil, fmt.Errorf("error when getting the columns of the flow tables: %v", err)
+	}
+	defer rows.Close()
+	tableColumns := map[string]map[string]bool{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("failed to parse the data returned by database: %v", err)
+		}
+		if tableColumns[table] == nil {
+			tableColumns[table] = map[string]bool{}
+		}
+		tableColumns[table][column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when getting the columns of the flow tables: %v", err)
+	}
+
+	condition, args := p.condition()
+	var tables []string
+	found := false
+	for _, table := range purgeTables {
+		columns, ok := tableColumns[table]
+		if !ok {
+			continue
+		}
+		hasColumns := true
+		for _, column := range p.columns() {
+			if !columns[column] {
+				hasColumns = false
+				break
+			}
+		}
+		if !hasColumns {
+			fmt.Fprintf(out, "Skipping table %s, which does not have the columns used by the purge\n", table)
+			continue
+		}
+		found = true
+		plan, err := clickhouse.PlanDeletion(connect, table, true, condition, args...)
+		if err != nil {
+			return tables, err
+		}
+		for _, partition := range plan.Partitions {
+			if err := clickhouse.DropPartition(connect, table, true, partition); err != nil {
+				return tables, err
+			}
+			fmt.Fprintf(out, "Dropped partition %s of table %s\n", partition, table)
+		}
+		if !plan.Mutate {
+			continue
+		}
+		query := fmt.Sprintf("ALTER TABLE %s ON CLUSTER '{cluster}' DELETE WHERE %s", table, condition)
+		if _, err := connect.Exec(query, args...); err != nil {
+			return tables, fmt.Errorf("error when deleting flows from table %s: %v", table, err)
+		}
+		fmt.Fprintf(out, "Started deleting flows from table %s\n", table)
+		tables = append(tables, table)
+	}
+	if !found {
+		return nil, fmt.Errorf("no flow table was found")
+	}
+	return tables, nil
+}
+
+// waitForPurgeMutations waits for the mutations of the tables to complete on
+// all the shards. The mutations of materialized views are run on their inner
+// tables, which are not named after the views, so the mutations of all the
+// inner tables are waited for, including the deletions of clickhouse-monitor.
+func waitForPurgeMutations(connect *sql.DB, tables []string, timeout time.Duration) error {
+	var pending uint64
+	var failReason string
+	query := fmt.Sprintf(`
+SELECT
+	count(),
+	anyIf(latest_fail_reason, latest_fail_reason != '')
+FROM cluster('{cluster}', system.mutations)
+WHERE database = currentDatabase() AND NOT is_done AND (table IN (%s) OR match(table, '^\\.inner'))`,
+		strings.TrimSuffix(strings.Repeat("?, ", len(tables)), ", "))
+	args := make([]interface{}, len(tables))
+	for i, table := range tables {
+		args[i] = table
+	}
+	err := wait.PollImmediate(config.MutationPollInterval, timeout, func() (bool, error) {
+		if err := connect.QueryRow(query, args...).Scan(&pending, &failReason); err != nil {
+			return false, fmt.Errorf("error when getting the status of the deletion: %v", err)
+		}
+		if failReason != "" {
+			return false, fmt.Errorf("deletion failed: %s", failReason)
+		}
+		return pending == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("%d deletions are still running after %v, check system.mutations in ClickHouse for their progress", pending, timeout)
+	}
+	return err
+}
+
+func init() {
+	clickHouseCmd.AddCommand(clickHousePurgeCmd)
+	clickHousePurgeCmd.Flags().StringSlice(
+		"selector",
+		nil,
+		"The flows to delete, as key=value pairs. The keys can be podNamespace, podName, nodeName, ip and clusterUUID.",
+	)
+	clickHousePurgeCmd.Flags().String(
+		"before",
+		"",
+		`Only delete the flows which ended before this time, as a date like 2023-01-01, or a timestamp in RFC3339 format
+or in 'YYYY-MM-DD hh:mm:ss' format. Dates and timestamps without offset are in UTC.`,
+	)
+	clickHousePurgeCmd.Flags().Duration(
+		"timeout",
+		10*time.Minute,
+		"How long to wait for the deletion to complete.",
+	)
+}
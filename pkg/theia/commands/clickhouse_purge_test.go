@@ -0,0 +1,112 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePurgeSelectors(t *testing.T) {
+	selectors, err := parsePurgeSelectors([]string{"podNamespace=team-x", "podName=web-0"})
+	require.NoError(t, err)
+	assert.Equal(t, []purgeSelector{{key: "podNamespace", value: "team-x"}, {key: "podName", value: "web-0"}}, selectors)
+
+	_, err = parsePurgeSelectors([]string{"podNamespace"})
+	assert.ErrorContains(t, err, "does not seem valid")
+	_, err = parsePurgeSelectors([]string{"label=app"})
+	assert.ErrorContains(t, err, "unsupported selector key label, it should be one of clusterUUID, ip, nodeName, podName, podNamespace")
+}
+
+func TestParsePurgeBefore(t *testing.T) {
+	before, err := parsePurgeBefore("2023-01-01")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), before)
+	before, err = parsePurgeBefore("2023-01-01 12:30:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 1, 1, 12, 30, 0, 0, time.UTC), before)
+	_, err = parsePurgeBefore("yesterday")
+	assert.Error(t, err)
+}
+
+func TestFlowPurgeCondition(t *testing.T) {
+	before := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	purge := flowPurge{selectors: []purgeSelector{{key: "podNamespace", value: "team-x"}, {key: "clusterUUID", value: "abc"}}, before: before}
+	condition, args := purge.condition()
+	assert.Equal(t, "flowEndSeconds < ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?) AND (clusterUUID = ?)", condition)
+	assert.Equal(t, []interface{}{before, "team-x", "team-x", "abc"}, args)
+	assert.Equal(t, []string{"flowEndSeconds", "sourcePodNamespace", "destinationPodNamespace", "clusterUUID"}, purge.columns())
+}
+
+func TestPurgeMutations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns := sqlmock.NewRows([]string{"table", "name"})
+	for _, table := range purgeTables {
+		columns.AddRow(table, "flowEndSeconds").AddRow(table, "sourcePodNamespace").AddRow(table, "destinationPodNamespace")
+		if table != "flows_node_view_local" {
+			columns.AddRow(table, "sourcePodName").AddRow(table, "destinationPodName")
+		}
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT table, name FROM system.columns")).WillReturnRows(columns)
+	for _, table := range []string{"flows_local", "flows_pod_view_local", "flows_policy_view_local"} {
+		// All the flows of the pod view match the purge, so its partition is dropped.
+		droppable := table == "flows_pod_view_local"
+		mock.ExpectQuery(regexp.QuoteMeta("FROM cluster('{cluster}', currentDatabase(), '"+table+"')")).
+			WithArgs("team-x", "team-x", "web-0", "web-0").
+			WillReturnRows(sqlmock.NewRows([]string{"partition", "matched", "droppable"}).AddRow("20230101", uint64(10), droppable))
+		if droppable {
+			mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE " + table + " ON CLUSTER '{cluster}' DROP PARTITION ID ?")).
+				WithArgs("20230101").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			continue
+		}
+		mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE "+table+" ON CLUSTER '{cluster}' DELETE WHERE (sourcePodNamespace = ? OR destinationPodNamespace = ?) AND (sourcePodName = ? OR destinationPodName = ?)")).
+			WithArgs("team-x", "team-x", "web-0", "web-0").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM cluster('{cluster}', system.mutations)")).
+		WithArgs("flows_local", "flows_policy_view_local").
+		WillReturnRows(sqlmock.NewRows([]string{"count()", "reason"}).AddRow(uint64(0), ""))
+
+	purge := flowPurge{selectors: []purgeSelector{{key: "podNamespace", value: "team-x"}, {key: "podName", value: "web-0"}}}
+	var out bytes.Buffer
+	tables, err := purge.startMutations(db, &out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"flows_local", "flows_policy_view_local"}, tables)
+	assert.Contains(t, out.String(), "Skipping table flows_node_view_local")
+	assert.Contains(t, out.String(), "Dropped partition 20230101 of table flows_pod_view_local")
+	require.NoError(t, waitForPurgeMutations(db, tables, time.Second))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWaitForPurgeMutationsFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM cluster('{cluster}', system.mutations)")).
+		WillReturnRows(sqlmock.NewRows([]string{"count()", "reason"}).AddRow(uint64(1), "Memory limit exceeded"))
+	err = waitForPurgeMutations(db, []string{"flows_local"}, time.Second)
+	assert.EqualError(t, err, "deletion failed: Memory limit exceeded")
+}
@@ -0,0 +1,307 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// namedQuery renders a canned query of "theia clickhouse query".
+type namedQuery struct {
+	render func(filter flows.Filter, limit int) (string, []interface{}, error)
+}
+
+// deniedConnectionColumns are the columns of the denied-connections query.
+var deniedConnectionColumns = []string{
+	"flowEndSeconds",
+	"sourcePodNamespace",
+	"sourcePodName",
+	"sourceIP",
+	"destinationPodNamespace",
+	"destinationPodName",
+	"destinationIP",
+	"destinationTransportPort",
+	"protocolIdentifier",
+	"ingressNetworkPolicyNamespace",
+	"ingressNetworkPolicyName",
+	"ingressNetworkPolicyRuleAction",
+	"egressNetworkPolicyNamespace",
+	"egressNetworkPolicyName",
+	"egressNetworkPolicyRuleAction",
+}
+
+// namedQueries are the canned queries, which are the queries of the flows
+// commands rendered for ClickHouse: top-talkers returns the pairs of Pods which
+// exchanged the most bytes, namespace-matrix the traffic between each pair of
+// Namespaces, and denied-connections the flows dropped or rejected by a network
+// policy.
+var namedQueries = map[string]namedQuery{
+	"top-talkers": {
+		render: func(filter flows.Filter, limit int) (string, []interface{}, error) {
+			return flows.TopQuery(flows.ClickHouse, filter, flows.GroupByPod, limit)
+		},
+	},
+	"namespace-matrix": {
+		render: func(filter flows.Filter, limit int) (string, []interface{}, error) {
+			return flows.MatrixQuery(flows.ClickHouse, filter)
+		},
+	},
+	"denied-connections": {
+		render: func(filter flows.Filter, limit int) (string, []interface{}, error) {
+			filter.Denied = true
+			return flows.ExportQuery(flows.ClickHouse, filter, deniedConnectionColumns, limit)
+		},
+	},
+}
+
+// namedQueryNames returns the sorted names of the canned queries.
+func namedQueryNames() []string {
+	names := make([]string, 0, len(namedQueries))
+	for name := range namedQueries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readOnlyStatements are the statements which can be run by "theia clickhouse
+// query".
+var readOnlyStatements = []string{"SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXPLAIN", "EXISTS"}
+
+var clickHouseQueryCmd = &cobra.Command{
+	Use:   "query [SQL]",
+	Short: "Run a query against the flow records in ClickHouse",
+	Long: `Run a read-only SQL statement, e.g. a SELECT of the flows table, or a named
+canned query against ClickHouse, and print the result as a table, as CSV or
+as JSON with one row per line. ClickHouse is reached through port forwarding,
+or through the Service ClusterIP with "--use-cluster-ip", so no ClickHouse
+client is needed. The statements are run with the ClickHouse readonly=1
+setting, so they cannot write data or change settings.
+
+The canned queries are selected with "--named", and consider the flows
+selected by "--last" and "--namespace", which are ignored with a SQL
+statement:
+- top-talkers: the pairs of Pods which exchanged the most bytes.
+- namespace-matrix: the traffic between each pair of Namespaces.
+- denied-connections: the flows dropped or rejected by a network policy, oldest
+  first.`,
+	Args: cobra.MaximumNArgs(1),
+	Example: `
+Print the number of flows per destination port
+$ theia clickhouse query "SELECT destinationTransportPort, count() AS flows FROM flows GROUP BY destinationTransportPort ORDER BY flows DESC LIMIT 10"
+Print the 20 pairs of Pods which exchanged the most bytes during the last day, as CSV
+$ theia clickhouse query --named top-talkers --last 1d --limit 20 --output csv
+Print the connections denied in namespace app-a during the last hour, as JSON
+$ theia clickhouse query --named denied-connections --namespace app-a --output json
+`,
+	RunE: queryClickHouse,
+}
+
+func queryClickHouse(cmd *cobra.Command, args []string) error {
+	name, err := cmd.Flags().GetString("named")
+	if err != nil {
+		return err
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if err := validation.OneOf("output", output, "table", "csv", "json"); err != nil {
+		return err
+	}
+	var query string
+	var queryArgs []interface{}
+	switch {
+	case name != "" && len(args) > 0:
+		return fmt.Errorf("a SQL statement and a named query cannot be provided together")
+	case name != "":
+		named, ok := namedQueries[name]
+		if !ok {
+			return fmt.Errorf("unknown named query %q, supported named queries: %v", name, namedQueryNames())
+		}
+		filter, err := getFlowsFilter(cmd)
+		if err != nil {
+			return err
+		}
+		limit, err := getFlowsLimit(cmd)
+		if err != nil {
+			return err
+		}
+		query, queryArgs, err = named.render(filter, limit)
+		if err != nil {
+			return err
+		}
+	case len(args) > 0:
+		query = args[0]
+		if err := checkReadOnlyStatement(query); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("a SQL statement or a named query is required")
+	}
+
+	connect, closeConnection, err := openReadOnlyClickHouse(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		connect.Close()
+		closeConnection()
+	}()
+	_, err = runClickHouseQuery(context.TODO(), connect, cmd.OutOrStdout(), output, query, queryArgs...)
+	return err
+}
+
+// checkReadOnlyStatement returns an error if the statement does not start
+// with one of readOnlyStatements. It only reports the mistakes early: the
+// statements are run with the readonly=1 setting, so that ClickHouse rejects
+// any statement writing data or changing settings.
+func checkReadOnlyStatement(query string) error {
+	fields := strings.Fields(strings.TrimLeft(query, "( \t\n"))
+	if len(fields) > 0 {
+		for _, statement := range readOnlyStatements {
+			if strings.EqualFold(fields[0], statement) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("only read-only statements can be run, the statement should start with one of %v", readOnlyStatements)
+}
+
+// formatQueryValue formats a value returned by ClickHouse.
+func formatQueryValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// runClickHouseQuery runs the query and writes its result to out in the given
+// output format, and returns the number of rows.
+func runClickHouseQuery(ctx context.Context, connect *sql.DB, out io.Writer, output string, query string, args ...interface{}) (int, error) {
+	if err := faultInjector.ClickHouseQuery(ctx); err != nil {
+		return 0, fmt.Errorf("failed to get data from clickhouse: %v", err)
+	}
+	rows, err := connect.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get data from clickhouse: %v", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the name of columns: %v", err)
+	}
+	var writeRow func(values []string) error
+	var flush func() error
+	switch output {
+	case "csv":
+		csvWriter := csv.NewWriter(out)
+		if err := csvWriter.Write(columns); err != nil {
+			return 0, err
+		}
+		writeRow = csvWriter.Write
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	case "json":
+		jsonEncoder := json.NewEncoder(out)
+		writeRow = func(values []string) error {
+			row := make(map[string]string, len(values))
+			for i, column := range columns {
+				row[column] = values[i]
+			}
+			return jsonEncoder.Encode(row)
+		}
+		flush = func() error { return nil }
+	default:
+		tableWriter := tabwriter.NewWriter(out, 15, 0, 1, ' ', 0)
+		writeRow = func(values []string) error {
+			_, err := fmt.Fprintln(tableWriter, strings.Join(values, "\t")+"\t")
+			return err
+		}
+		if err := writeRow(columns); err != nil {
+			return 0, err
+		}
+		flush = tableWriter.Flush
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	row := make([]string, len(columns))
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, fmt.Errorf("failed to parse the data returned by database: %v", err)
+		}
+		for i, value := range values {
+			row[i] = formatQueryValue(value)
+		}
+		if err := writeRow(row); err != nil {
+			return count, fmt.Errorf("error when writing result: %v", err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to get data from clickhouse: %v", err)
+	}
+	if err := flush(); err != nil {
+		return count, fmt.Errorf("error when writing result: %v", err)
+	}
+	return count, nil
+}
+
+func init() {
+	clickHouseCmd.AddCommand(clickHouseQueryCmd)
+	addFlowsFilterFlags(clickHouseQueryCmd)
+	clickHouseQueryCmd.Flags().String(
+		"named",
+		"",
+		fmt.Sprintf("The named query to run instead of a SQL statement, one of %v.", namedQueryNames()),
+	)
+	clickHouseQueryCmd.Flags().Int(
+		"limit",
+		10,
+		"The maximum number of rows of the top-talkers and denied-connections named queries. 0 means no limit.",
+	)
+	clickHouseQueryCmd.Flags().StringP(
+		"output",
+		"o",
+		"table",
+		"The output format: table, csv or json (one JSON object per line).",
+	)
+}
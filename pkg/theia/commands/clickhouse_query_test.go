@@ -0,0 +1,109 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/flows"
+)
+
+func TestCheckReadOnlyStatement(t *testing.T) {
+	for _, query := range []string{
+		"SELECT count() FROM flows",
+		"  select 1",
+		"WITH 1 AS x SELECT x",
+		"(SELECT 1) UNION ALL (SELECT 2)",
+		"SHOW TABLES",
+		"describe flows",
+	} {
+		assert.NoError(t, checkReadOnlyStatement(query), query)
+	}
+	for _, query := range []string{
+		"",
+		"DROP TABLE flows",
+		"ALTER TABLE flows DELETE WHERE 1",
+		"INSERT INTO flows SELECT * FROM flows",
+	} {
+		assert.EqualError(t, checkReadOnlyStatement(query), "only read-only statements can be run, the statement should start with one of [SELECT WITH SHOW DESCRIBE DESC EXPLAIN EXISTS]", query)
+	}
+}
+
+func TestRunClickHouseQuery(t *testing.T) {
+	endTime := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"destinationTransportPort", "flows", "lastFlowEnd"}).
+			AddRow(uint16(443), uint64(120), endTime).
+			AddRow(uint16(80), uint64(12), nil)
+	}
+	query := "SELECT destinationTransportPort, count() AS flows, max(flowEndSeconds) AS lastFlowEnd FROM flows GROUP BY destinationTransportPort"
+	for _, tt := range []struct {
+		output   string
+		expected string
+	}{
+		{
+			output: "table",
+			expected: "destinationTransportPort flows          lastFlowEnd         \n" +
+				"443                      120            2022-08-01 12:00:00 \n" +
+				"80                       12                                 \n",
+		},
+		{
+			output:   "csv",
+			expected: "destinationTransportPort,flows,lastFlowEnd\n443,120,2022-08-01 12:00:00\n80,12,\n",
+		},
+		{
+			output: "json",
+			expected: `{"destinationTransportPort":"443","flows":"120","lastFlowEnd":"2022-08-01 12:00:00"}` + "\n" +
+				`{"destinationTransportPort":"80","flows":"12","lastFlowEnd":""}` + "\n",
+		},
+	} {
+		t.Run(tt.output, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(newRows())
+
+			var out bytes.Buffer
+			count, err := runClickHouseQuery(context.TODO(), db, &out, tt.output, query)
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, 2, count)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func TestNamedQueries(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	filter := flows.Filter{Start: start, Namespace: "app-a"}
+	query, args, err := namedQueries["denied-connections"].render(filter, 5)
+	require.NoError(t, err)
+	assert.Contains(t, query, "AND (ingressNetworkPolicyRuleAction IN (2, 3) OR egressNetworkPolicyRuleAction IN (2, 3))")
+	assert.Contains(t, query, "LIMIT 5")
+	assert.Equal(t, []interface{}{start, "app-a", "app-a"}, args)
+
+	query, _, err = namedQueries["top-talkers"].render(filter, 5)
+	require.NoError(t, err)
+	assert.Contains(t, query, "LIMIT 5")
+	assert.Equal(t, []string{"denied-connections", "namespace-matrix", "top-talkers"}, namedQueryNames())
+}
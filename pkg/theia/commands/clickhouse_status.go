@@ -15,12 +15,18 @@
 package commands
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/url"
 	"strings"
 
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/theia/commands/config"
 )
 
 type chOptions struct {
@@ -28,6 +34,9 @@ type chOptions struct {
 	tableInfo   bool
 	insertRate  bool
 	stackTraces bool
+	schemaInfo  bool
+	podInfo     bool
+	ttlInfo     bool
 }
 
 type diskInfo struct {
@@ -54,6 +63,12 @@ type insertRate struct {
 	bytesPerSec string
 }
 
+type ttlInfo struct {
+	shard     string
+	tableName string
+	ttl       string
+}
+
 type stackTraces struct {
 	shard          string
 	traceFunctions string
@@ -66,6 +81,7 @@ const (
 	// average writing rate for all tables per second
 	insertRateQuery
 	stackTracesQuery
+	ttlQuery
 )
 
 var queryMap = map[int]string{
@@ -133,6 +149,16 @@ FROM cluster('{cluster}', system.stack_trace)
 GROUP BY trace_function, Shard
 ORDER BY count()
 DESC SETTINGS allow_introspection_functions=1`,
+	// TTL clause of the tables of the default database, e.g. the flows table and
+	// the aggregated views
+	ttlQuery: `
+SELECT
+	shardNum() as Shard,
+	name as TableName,
+	extract(engine_full, 'TTL (.+?)(?: SETTINGS |$)') as TTL
+FROM cluster('{cluster}', system.tables)
+WHERE database = 'default' AND engine_full LIKE '% TTL %'
+ORDER BY Shard, TableName`,
 }
 
 var options *chOptions
@@ -149,6 +175,8 @@ var example = strings.Trim(`
 theia clickhouse status --diskInfo
 theia clickhouse status --diskInfo --tableInfo
 theia clickhouse status --diskInfo --tableInfo --insertRate
+theia clickhouse status --schemaInfo
+theia clickhouse status --podInfo --ttlInfo
 `, "\n")
 
 func init() {
@@ -158,10 +186,13 @@ func init() {
 	clickHouseStatusCmd.Flags().BoolVar(&options.tableInfo, "tableInfo", false, "check basic table information")
 	clickHouseStatusCmd.Flags().BoolVar(&options.insertRate, "insertRate", false, "check the insertion-rate of clickhouse")
 	clickHouseStatusCmd.Flags().BoolVar(&options.stackTraces, "stackTraces", false, "check stacktrace of clickhouse")
+	clickHouseStatusCmd.Flags().BoolVar(&options.schemaInfo, "schemaInfo", false, "check the flow schema version of clickhouse")
+	clickHouseStatusCmd.Flags().BoolVar(&options.podInfo, "podInfo", false, "check the health of the clickhouse Pods")
+	clickHouseStatusCmd.Flags().BoolVar(&options.ttlInfo, "ttlInfo", false, "check the TTL settings of the clickhouse tables")
 }
 
 func getClickHouseStatus(cmd *cobra.Command, args []string) error {
-	if !options.diskInfo && !options.tableInfo && !options.insertRate && !options.stackTraces {
+	if !options.diskInfo && !options.tableInfo && !options.insertRate && !options.stackTraces && !options.schemaInfo && !options.podInfo && !options.ttlInfo {
 		return fmt.Errorf("no metric related flag is specified")
 	}
 	kubeconfig, err := ResolveKubeConfig(cmd)
@@ -172,6 +203,18 @@ func getClickHouseStatus(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
 	}
+	// The Pods are reported before connecting to ClickHouse, so that their
+	// health can be checked when no Pod is running.
+	if options.podInfo {
+		data, err := getClickHousePodInfo(clientset)
+		if err != nil {
+			return fmt.Errorf("error when getting podInfo from clickhouse: %v", err)
+		}
+		TableOutput(data)
+		if !options.diskInfo && !options.tableInfo && !options.insertRate && !options.stackTraces && !options.schemaInfo && !options.ttlInfo {
+			return nil
+		}
+	}
 
 	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
 	if err != nil {
@@ -183,6 +226,10 @@ func getClickHouseStatus(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to decode input endpoint %s into a url, err: %v", endpoint, err)
 		}
 	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return err
+	}
 	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
 	if err != nil {
 		return err
@@ -191,7 +238,7 @@ func getClickHouseStatus(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	// Connect to ClickHouse and get the result
-	connect, pf, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, useClusterIP)
+	connect, pf, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
 	if err != nil {
 		return err
 	}
@@ -226,10 +273,82 @@ func getClickHouseStatus(cmd *cobra.Command, args []string) error {
 		}
 		TableOutputVertical(data)
 	}
+	if options.ttlInfo {
+		data, err := getDataFromClickHouse(connect, ttlQuery)
+		if err != nil {
+			return fmt.Errorf("error when getting ttlInfo from clickhouse: %v", err)
+		}
+		TableOutput(data)
+	}
+	if options.schemaInfo {
+		data, err := getFlowSchemaInfo(connect)
+		if err != nil {
+			return fmt.Errorf("error when getting schemaInfo from clickhouse: %v", err)
+		}
+		TableOutput(data)
+	}
 	return nil
 }
 
+// getClickHousePodInfo returns the phase, readiness and restart count of the
+// ClickHouse Pods.
+func getClickHousePodInfo(clientset kubernetes.Interface) ([][]string, error) {
+	pods, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app=clickhouse",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing the ClickHouse Pods: %v", err)
+	}
+	if len(pods.Items) < 1 {
+		return nil, fmt.Errorf("can't find the ClickHouse Pod, please check the deployment of ClickHouse")
+	}
+	data := [][]string{{"PodName", "NodeName", "Phase", "Ready", "Restarts"}}
+	for _, pod := range pods.Items {
+		ready, restarts := 0, int32(0)
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+			restarts += status.RestartCount
+		}
+		data = append(data, []string{
+			pod.Name,
+			pod.Spec.NodeName,
+			string(pod.Status.Phase),
+			fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)),
+			fmt.Sprint(restarts),
+		})
+	}
+	return data, nil
+}
+
+func getFlowSchemaInfo(connect *sql.DB) ([][]string, error) {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get data from clickhouse: %v", err)
+	}
+	schema, err := clickhouse.GetFlowSchema(connect)
+	if err != nil {
+		return nil, err
+	}
+	deployedVersion := "unknown"
+	if schema.Version > 0 {
+		deployedVersion = fmt.Sprint(schema.Version)
+	}
+	compatible := "yes"
+	missingColumns := schema.MissingColumns(clickhouse.FlowColumns)
+	if len(missingColumns) > 0 {
+		compatible = "no"
+	}
+	return [][]string{
+		{"ExpectedVersion", "DeployedVersion", "Compatible", "MissingColumns"},
+		{fmt.Sprint(clickhouse.FlowSchemaVersion), deployedVersion, compatible, strings.Join(missingColumns, ",")},
+	}, nil
+}
+
 func getDataFromClickHouse(connect *sql.DB, query int) ([][]string, error) {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get data from clickhouse: %v", err)
+	}
 	result, err := connect.Query(queryMap[query])
 	if err != nil {
 		return nil, fmt.Errorf("failed to get data from clickhouse: %v", err)
@@ -263,6 +382,10 @@ func getDataFromClickHouse(connect *sql.DB, query int) ([][]string, error) {
 			res := stackTraces{}
 			err = result.Scan(&res.shard, &res.traceFunctions, &res.count)
 			data = append(data, []string{res.shard, res.traceFunctions, res.count})
+		case ttlQuery:
+			res := ttlInfo{}
+			err = result.Scan(&res.shard, &res.tableName, &res.ttl)
+			data = append(data, []string{res.shard, res.tableName, res.ttl})
 		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse the data returned by database: %v", err)
@@ -0,0 +1,78 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+)
+
+func TestGetClickHousePodInfo(t *testing.T) {
+	_, err := getClickHousePodInfo(fake.NewSimpleClientset())
+	assert.EqualError(t, err, "can't find the ClickHouse Pod, please check the deployment of ClickHouse")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "chi-clickhouse-clickhouse-0-0-0",
+			Namespace: config.FlowVisibilityNS,
+			Labels:    map[string]string{"app": "clickhouse"},
+		},
+		Spec: v1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []v1.Container{{Name: "clickhouse"}, {Name: "clickhouse-monitor"}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "clickhouse", Ready: true, RestartCount: 2},
+				{Name: "clickhouse-monitor", RestartCount: 1},
+			},
+		},
+	}
+	data, err := getClickHousePodInfo(fake.NewSimpleClientset(pod))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"PodName", "NodeName", "Phase", "Ready", "Restarts"},
+		{"chi-clickhouse-clickhouse-0-0-0", "node-1", "Running", "1/2", "3"},
+	}, data)
+}
+
+func TestGetTTLInfo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("extract(engine_full, 'TTL (.+?)(?: SETTINGS |$)') as TTL")).
+		WillReturnRows(sqlmock.NewRows([]string{"Shard", "TableName", "TTL"}).
+			AddRow("1", "flows_local", "timeInserted + toIntervalSecond(43200)").
+			AddRow("1", ".inner.flows_pod_view", "timeInserted + toIntervalSecond(43200)"))
+	data, err := getDataFromClickHouse(db, ttlQuery)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, [][]string{
+		{"Shard", "TableName", "TTL"},
+		{"1", "flows_local", "timeInserted + toIntervalSecond(43200)"},
+		{"1", ".inner.flows_pod_view", "timeInserted + toIntervalSecond(43200)"},
+	}, data)
+}
@@ -0,0 +1,298 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/clickhouse"
+)
+
+// trimTables are the local tables trimmed by clickhouse-monitor when the
+// storage grows above its threshold, i.e. the flows table and the
+// materialized views aggregated from it.
+var trimTables = []string{
+	"flows_local",
+	"flows_pod_view_local",
+	"flows_node_view_local",
+	"flows_policy_view_local",
+}
+
+// trimCondition matches the records inserted before the trim boundary.
+const trimCondition = "timeInserted < ?"
+
+// trimPartition is the number of rows to be deleted from a partition of a
+// trimmed table on a shard, and whether the partition is dropped.
+type trimPartition struct {
+	table     string
+	shard     uint32
+	partition string
+	rows      uint64
+	drop      bool
+}
+
+var clickHouseTrimCmd = &cobra.Command{
+	Use:   "trim",
+	Short: "Delete the oldest flow records from ClickHouse",
+	Long: `Delete the oldest flow records from ClickHouse to reclaim space, like
+clickhouse-monitor does when the storage grows above its threshold. The
+percentage of the flows to delete is converted to a timeInserted boundary, and
+the records inserted before the boundary are deleted from the flows table and
+from the aggregated views, on all the shards. The daily partitions which only
+have records inserted before the boundary are dropped, and the other records are
+deleted with mutations.
+
+With --dry-run, the command only prints the boundary, and the number of rows
+which would be deleted from each partition of the tables and how.`,
+	Args: cobra.NoArgs,
+	Example: `
+Print the records which would be deleted when trimming 20% of the flows
+$ theia clickhouse trim --percentage 20 --dry-run
+Delete the oldest 20% of the flows without asking for confirmation
+$ theia clickhouse trim --percentage 20 --yes
+`,
+	RunE: trimFlows,
+}
+
+func trimFlows(cmd *cobra.Command, args []string) error {
+	percentage, err := cmd.Flags().GetFloat64("percentage")
+	if err != nil {
+		return err
+	}
+	if percentage <= 0 || percentage > 100 {
+		return fmt.Errorf("percentage should be larger than 0 and no larger than 100")
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+	assumeYes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return err
+	}
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return err
+	}
+	if endpoint != "" {
+		err = ParseEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return err
+	}
+	connect, pf, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if pf != nil {
+		defer pf.Stop()
+	}
+	if err != nil {
+		return err
+	}
+	defer connect.Close()
+
+	// The deletion plan is the result of a dry run.
+	out := infoOut(cmd)
+	if dryRun {
+		out = cmd.OutOrStdout()
+	}
+	boundary, found, err := getTrimBoundary(connect, percentage)
+	if err != nil {
+		return err
+	}
+	if !found {
+		fmt.Fprintf(out, "No flows would be deleted when trimming %g%% of the flows\n", percentage)
+		return nil
+	}
+	plans := map[string]*clickhouse.DeletionPlan{}
+	for _, table := range trimTables {
+		plans[table], err = clickhouse.PlanDeletion(connect, table, true, trimCondition, boundary)
+		if err != nil {
+			return err
+		}
+	}
+	partitions, err := getTrimPartitions(connect, boundary, plans)
+	if err != nil {
+		return err
+	}
+	var count uint64
+	for _, partition := range partitions {
+		if partition.table == trimTables[0] {
+			count += partition.rows
+		}
+	}
+	fmt.Fprintf(out, "Records inserted before %s will be deleted\n", FormatTimestamp(boundary))
+	if err := printTrimPartitions(out, partitions); err != nil {
+		return err
+	}
+	if dryRun || count == 0 {
+		return nil
+	}
+	if !assumeYes {
+		confirmed, err := promptConfirmation(bufio.NewReader(cmd.InOrStdin()), cmd.OutOrStdout(), fmt.Sprintf("%d flows will be deleted permanently. Continue?", count))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Fprintln(out, "Trim cancelled")
+			return nil
+		}
+	}
+	var tables []string
+	for _, table := range trimTables {
+		for _, partition := range plans[table].Partitions {
+			if err := clickhouse.DropPartition(connect, table, true, partition); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Dropped partition %s of table %s\n", partition, table)
+		}
+		if !plans[table].Mutate {
+			continue
+		}
+		// #nosec G201: the table names are constants
+		query := fmt.Sprintf("ALTER TABLE %s ON CLUSTER '{cluster}' DELETE WHERE %s", table, trimCondition)
+		if _, err := connect.Exec(query, boundary); err != nil {
+			return fmt.Errorf("error when deleting records from table %s: %v", table, err)
+		}
+		fmt.Fprintf(out, "Started deleting records from table %s\n", table)
+		tables = append(tables, table)
+	}
+	if len(tables) > 0 {
+		fmt.Fprintf(out, "Waiting for the deletion to complete on all the shards\n")
+		if err := waitForPurgeMutations(connect, tables, timeout); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "Trimmed %d flows\n", count)
+	return nil
+}
+
+// getTrimBoundary returns the timeInserted of the latest flow to be deleted
+// when trimming the given percentage of the flows, the same way as
+// clickhouse-monitor. The flows inserted before the boundary are deleted. It
+// returns false if no flow would be deleted.
+func getTrimBoundary(connect *sql.DB, percentage float64) (time.Time, bool, error) {
+	var boundary time.Time
+	var count uint64
+	if err := connect.QueryRow("SELECT COUNT() FROM flows").Scan(&count); err != nil {
+		return boundary, false, fmt.Errorf("error when counting the flows: %v", err)
+	}
+	deleteRowNum := uint64(float64(count) * percentage / 100)
+	if deleteRowNum == 0 {
+		return boundary, false, nil
+	}
+	if err := connect.QueryRow("SELECT timeInserted FROM flows ORDER BY timeInserted LIMIT 1 OFFSET (?)", deleteRowNum-1).Scan(&boundary); err != nil {
+		return boundary, false, fmt.Errorf("error when getting the timeInserted boundary: %v", err)
+	}
+	return boundary, true, nil
+}
+
+// getTrimPartitions returns the number of rows inserted before the boundary
+// in each partition of the trimmed tables, on each shard. The partitions are
+// dropped if they are in the deletion plan of their table.
+func getTrimPartitions(connect *sql.DB, boundary time.Time, plans map[string]*clickhouse.DeletionPlan) ([]trimPartition, error) {
+	var partitions []trimPartition
+	for _, table := range trimTables {
+		rows, err := connect.Query(`
+SELECT
+	shardNum() AS Shard,
+	_partition_id AS Partition,
+	count() AS Rows
+FROM cluster('{cluster}', currentDatabase(), ?)
+WHERE `+trimCondition+`
+GROUP BY Shard, Partition
+ORDER BY Shard, Partition`, table, boundary)
+		if err != nil {
+			return nil, fmt.Errorf("error when getting the records to delete from table %s: %v", table, err)
+		}
+		for rows.Next() {
+			partition := trimPartition{table: table}
+			if err := rows.Scan(&partition.shard, &partition.partition, &partition.rows); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to parse the data returned by database: %v", err)
+			}
+			for _, id := range plans[table].Partitions {
+				partition.drop = partition.drop || id == partition.partition
+			}
+			partitions = append(partitions, partition)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error when getting the records to delete from table %s: %v", table, err)
+		}
+	}
+	return partitions, nil
+}
+
+func printTrimPartitions(out io.Writer, partitions []trimPartition) error {
+	w := tabwriter.NewWriter(out, 15, 0, 1, ' ', 0)
+	fmt.Fprintln(w, "Table\tShard\tPartition\tRows\tDeletion\t")
+	for _, partition := range partitions {
+		deletion := "mutation"
+		if partition.drop {
+			deletion = "drop partition"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t\n", partition.table, partition.shard, partition.partition, partition.rows, deletion)
+	}
+	return w.Flush()
+}
+
+func init() {
+	clickHouseCmd.AddCommand(clickHouseTrimCmd)
+	clickHouseTrimCmd.Flags().Float64(
+		"percentage",
+		0,
+		"The percentage of the flows to delete, starting from the oldest ones, e.g. 20 for 20%.",
+	)
+	clickHouseTrimCmd.Flags().Bool(
+		"dry-run",
+		false,
+		"Only print the records which would be deleted.",
+	)
+	clickHouseTrimCmd.Flags().Duration(
+		"timeout",
+		10*time.Minute,
+		"How long to wait for the deletion to complete.",
+	)
+}
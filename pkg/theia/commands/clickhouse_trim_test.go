@@ -0,0 +1,87 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/clickhouse"
+)
+
+func TestGetTrimBoundary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	timeInserted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT() FROM flows")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(uint64(1000)))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT timeInserted FROM flows ORDER BY timeInserted LIMIT 1 OFFSET (?)")).
+		WithArgs(uint64(199)).
+		WillReturnRows(sqlmock.NewRows([]string{"timeInserted"}).AddRow(timeInserted))
+	boundary, found, err := getTrimBoundary(db, 20)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, timeInserted, boundary)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT() FROM flows")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(uint64(4)))
+	_, found, err = getTrimBoundary(db, 20)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTrimPartitions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	boundary := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, table := range trimTables {
+		rows := sqlmock.NewRows([]string{"Shard", "Partition", "Rows"})
+		if table != "flows_node_view_local" {
+			rows.AddRow(uint32(1), "20230101", uint64(100)).AddRow(uint32(2), "20230101", uint64(80))
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("FROM cluster('{cluster}', currentDatabase(), ?)")).
+			WithArgs(table, boundary).
+			WillReturnRows(rows)
+	}
+	plans := map[string]*clickhouse.DeletionPlan{
+		"flows_local":             {Partitions: []string{"20230101"}},
+		"flows_pod_view_local":    {Mutate: true},
+		"flows_node_view_local":   {},
+		"flows_policy_view_local": {Partitions: []string{"20230101"}},
+	}
+	partitions, err := getTrimPartitions(db, boundary, plans)
+	require.NoError(t, err)
+	assert.Len(t, partitions, 6)
+	assert.Equal(t, trimPartition{table: "flows_local", shard: 2, partition: "20230101", rows: 80, drop: true}, partitions[1])
+	assert.Equal(t, trimPartition{table: "flows_pod_view_local", shard: 1, partition: "20230101", rows: 100}, partitions[2])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var out bytes.Buffer
+	require.NoError(t, printTrimPartitions(&out, partitions[1:3]))
+	assert.Equal(t, "Table                Shard          Partition      Rows           Deletion       \n"+
+		"flows_local          2              20230101       80             drop partition \n"+
+		"flows_pod_view_local 1              20230101       100            mutation       \n", out.String())
+}
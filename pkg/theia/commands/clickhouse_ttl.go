@@ -0,0 +1,380 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// ttlTables maps the tables whose TTL can be changed from the CLI to their
+// local tables. The TTL of the aggregated views is not included, as ClickHouse
+// resets it to the clickhouse.retention.viewsTTL Helm value when it restarts.
+var ttlTables = map[string]string{
+	"flows": "flows_local",
+}
+
+const getTTLQuery = `
+SELECT
+	shardNum() as Shard,
+	name as TableName,
+	extract(engine_full, 'TTL (.+?)(?: SETTINGS |$)') as TTL
+FROM cluster('{cluster}', system.tables)
+WHERE database = 'default' AND name = ?
+ORDER BY Shard`
+
+// ttlCapacityQuery returns, for each shard, the size of a table on disk, the
+// period covered by its data, and the free space of the disks.
+const ttlCapacityQuery = `
+SELECT
+	Shard,
+	Bytes,
+	Oldest,
+	Newest,
+	Free
+FROM (
+	SELECT
+		shardNum() AS Shard,
+		sum(bytes_on_disk) AS Bytes,
+		min(min_time) AS Oldest,
+		max(max_time) AS Newest
+	FROM cluster('{cluster}', system.parts)
+	WHERE database = 'default' AND table = ? AND active
+	GROUP BY Shard
+) AS parts
+INNER JOIN (
+	SELECT
+		shardNum() AS Shard,
+		sum(free_space) AS Free
+	FROM cluster('{cluster}', system.disks)
+	GROUP BY Shard
+) AS disks USING Shard
+ORDER BY Shard`
+
+// minTTLEstimationPeriod is the minimum period covered by the data of a shard
+// to estimate the size of the data retained with a new TTL.
+const minTTLEstimationPeriod = time.Hour
+
+// shardCapacity is the size of a table on a shard, and the free space of its
+// disks.
+type shardCapacity struct {
+	shard  uint32
+	bytes  uint64
+	oldest time.Time
+	newest time.Time
+	free   uint64
+}
+
+var clickHouseGetTTLCmd = &cobra.Command{
+	Use:   "get-ttl",
+	Short: "Get the TTL of a ClickHouse table",
+	Long: `Get the TTL of a ClickHouse table on each shard, i.e. how long the records
+are kept after they are inserted.`,
+	Args: cobra.NoArgs,
+	Example: `
+Get the TTL of the flows table
+$ theia clickhouse get-ttl --table flows
+`,
+	RunE: getTTL,
+}
+
+var clickHouseSetTTLCmd = &cobra.Command{
+	Use:   "set-ttl",
+	Short: "Set the TTL of a ClickHouse table",
+	Long: `Set the TTL of a ClickHouse table on all the shards, i.e. how long the records
+are kept after they are inserted, without editing the Helm values and
+redeploying ClickHouse. The new TTL is applied to the existing records during
+the next merges.
+
+Before extending the TTL, the size of the data retained with the new TTL is
+estimated from the size of the table and the period covered by its data. The
+TTL is not set if the estimated size is above --max-disk-usage of the space
+available to ClickHouse on any shard, as clickhouse-monitor would delete the
+oldest records before they expire. Use --force to set it anyway.
+
+When records older than the new TTL will be deleted, the command asks for
+confirmation unless --yes is set.
+
+The TTL of the aggregated views used by the Grafana dashboards is set by the
+clickhouse.retention.viewsTTL Helm value.`,
+	Args: cobra.NoArgs,
+	Example: `
+Keep the flows for 7 days
+$ theia clickhouse set-ttl --table flows --days 7
+Keep the flows for 12 hours, without asking for confirmation
+$ theia clickhouse set-ttl --table flows --hours 12 --yes
+`,
+	RunE: setTTL,
+}
+
+func getTTLTable(cmd *cobra.Command) (string, error) {
+	table, err := cmd.Flags().GetString("table")
+	if err != nil {
+		return "", err
+	}
+	tables := make([]string, 0, len(ttlTables))
+	for name := range ttlTables {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	if err := validation.OneOf("table", table, tables...); err != nil {
+		return "", err
+	}
+	return ttlTables[table], nil
+}
+
+func getTTL(cmd *cobra.Command, args []string) error {
+	table, err := getTTLTable(cmd)
+	if err != nil {
+		return err
+	}
+	connect, stop, err := openClickHouse(cmd)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer connect.Close()
+	data, err := getTableTTL(connect, table)
+	if err != nil {
+		return err
+	}
+	TableOutput(data)
+	return nil
+}
+
+// getTableTTL returns the TTL of a local table on each shard, with a header
+// row.
+func getTableTTL(connect *sql.DB, table string) ([][]string, error) {
+	rows, err := connect.Query(getTTLQuery, table)
+	if err != nil {
+		return nil, fmt.Errorf("error when getting the TTL of table %s: %v", table, err)
+	}
+	defer rows.Close()
+	data := [][]string{{"Shard", "TableName", "TTL"}}
+	for rows.Next() {
+		var shard, name, ttl string
+		if err := rows.Scan(&shard, &name, &ttl); err != nil {
+			return nil, fmt.Errorf("error when scanning the TTL of table %s: %v", table, err)
+		}
+		if ttl == "" {
+			ttl = "none"
+		}
+		data = append(data, []string{shard, name, ttl})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when getting the TTL of table %s: %v", table, err)
+	}
+	if len(data) == 1 {
+		return nil, fmt.Errorf("table %s was not found", table)
+	}
+	return data, nil
+}
+
+func setTTL(cmd *cobra.Command, args []string) error {
+	table, err := getTTLTable(cmd)
+	if err != nil {
+		return err
+	}
+	days, err := cmd.Flags().GetInt("days")
+	if err != nil {
+		return err
+	}
+	hours, err := cmd.Flags().GetInt("hours")
+	if err != nil {
+		return err
+	}
+	if (days > 0) == (hours > 0) || days < 0 || hours < 0 {
+		return fmt.Errorf("exactly one of days and hours should be provided, as a positive number")
+	}
+	interval := fmt.Sprintf("%d DAY", days)
+	ttl := time.Duration(days) * 24 * time.Hour
+	if hours > 0 {
+		interval = fmt.Sprintf("%d HOUR", hours)
+		ttl = time.Duration(hours) * time.Hour
+	}
+	maxDiskUsage, err := cmd.Flags().GetFloat64("max-disk-usage")
+	if err != nil {
+		return err
+	}
+	if maxDiskUsage <= 0 || maxDiskUsage > 1 {
+		return fmt.Errorf("max-disk-usage should be larger than 0 and no larger than 1")
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	assumeYes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+	connect, stop, err := openClickHouse(cmd)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer connect.Close()
+
+	capacities, err := getShardCapacities(connect, table)
+	if err != nil {
+		return err
+	}
+	if !force {
+		if err := checkTTLCapacity(capacities, ttl, maxDiskUsage); err != nil {
+			return err
+		}
+	}
+	if boundary, expired := expiredBefore(capacities, ttl, time.Now()); expired && !assumeYes {
+		confirmed, err := promptConfirmation(bufio.NewReader(cmd.InOrStdin()), cmd.OutOrStdout(), fmt.Sprintf("The records inserted before %s will be deleted. Continue?", FormatTimestamp(boundary)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Fprintln(infoOut(cmd), "TTL change cancelled")
+			return nil
+		}
+	}
+	if err := setTableTTL(connect, table, interval); err != nil {
+		return err
+	}
+	fmt.Fprintf(infoOut(cmd), "Set the TTL of table %s to %s\n", table, interval)
+	return nil
+}
+
+// getShardCapacities returns the size of a local table and the free space of
+// the disks on each shard.
+func getShardCapacities(connect *sql.DB, table string) ([]shardCapacity, error) {
+	rows, err := connect.Query(ttlCapacityQuery, table)
+	if err != nil {
+		return nil, fmt.Errorf("error when getting the disk usage of table %s: %v", table, err)
+	}
+	defer rows.Close()
+	var capacities []shardCapacity
+	for rows.Next() {
+		var capacity shardCapacity
+		if err := rows.Scan(&capacity.shard, &capacity.bytes, &capacity.oldest, &capacity.newest, &capacity.free); err != nil {
+			return nil, fmt.Errorf("error when scanning the disk usage of table %s: %v", table, err)
+		}
+		capacities = append(capacities, capacity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error when getting the disk usage of table %s: %v", table, err)
+	}
+	return capacities, nil
+}
+
+// checkTTLCapacity checks that the data retained with the TTL fits in
+// maxDiskUsage of the space available to the table on each shard, i.e. its
+// current size and the free space of the disks. The size of the retained data
+// is extrapolated from the period covered by the current data, and is not
+// estimated for the shards with less than minTTLEstimationPeriod of data.
+func checkTTLCapacity(capacities []shardCapacity, ttl time.Duration, maxDiskUsage float64) error {
+	var errs []string
+	for _, capacity := range capacities {
+		period := capacity.newest.Sub(capacity.oldest)
+		if period < minTTLEstimationPeriod || ttl <= period {
+			continue
+		}
+		estimated := float64(capacity.bytes) * ttl.Seconds() / period.Seconds()
+		available := float64(capacity.bytes + capacity.free)
+		if estimated > maxDiskUsage*available {
+			errs = append(errs, fmt.Sprintf("shard %d would retain about %s, above %g%% of the %s available",
+				capacity.shard, formatSize(uint64(estimated)), maxDiskUsage*100, formatSize(uint64(available))))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("the disks are too small for a TTL of %v: %s, use --force to set it anyway", ttl, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// expiredBefore returns the time before which the records inserted are expired
+// with the TTL, and whether any shard has such records.
+func expiredBefore(capacities []shardCapacity, ttl time.Duration, now time.Time) (time.Time, bool) {
+	boundary := now.Add(-ttl)
+	for _, capacity := range capacities {
+		if capacity.bytes > 0 && capacity.oldest.Before(boundary) {
+			return boundary, true
+		}
+	}
+	return boundary, false
+}
+
+// setTableTTL sets the TTL of a local table on all the shards. Like for the
+// retention spec of the Helm chart, the existing data is not rewritten, and
+// the TTL is applied to it during the next merges.
+func setTableTTL(connect *sql.DB, table string, interval string) error {
+	// #nosec G201: the table is validated, and the interval is built from integers
+	query := fmt.Sprintf("ALTER TABLE %s ON CLUSTER '{cluster}' MODIFY TTL timeInserted + INTERVAL %s SETTINGS materialize_ttl_after_modify = 0", table, interval)
+	if _, err := connect.Exec(query); err != nil {
+		return fmt.Errorf("error when setting the TTL of table %s: %v", table, err)
+	}
+	return nil
+}
+
+// formatSize formats a number of bytes with a binary unit, like the
+// formatReadableSize function of ClickHouse.
+func formatSize(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	clickHouseCmd.AddCommand(clickHouseGetTTLCmd)
+	clickHouseCmd.AddCommand(clickHouseSetTTLCmd)
+	for _, cmd := range []*cobra.Command{clickHouseGetTTLCmd, clickHouseSetTTLCmd} {
+		cmd.Flags().String(
+			"table",
+			"flows",
+			"The table whose TTL is managed.",
+		)
+	}
+	clickHouseSetTTLCmd.Flags().Int(
+		"days",
+		0,
+		"The number of days the records are kept.",
+	)
+	clickHouseSetTTLCmd.Flags().Int(
+		"hours",
+		0,
+		"The number of hours the records are kept.",
+	)
+	clickHouseSetTTLCmd.Flags().Float64(
+		"max-disk-usage",
+		0.5,
+		`The maximum fraction of the space available to ClickHouse which the table may use with the new TTL.
+It defaults to the default threshold of clickhouse-monitor.`,
+	)
+	clickHouseSetTTLCmd.Flags().Bool(
+		"force",
+		false,
+		"Set the TTL even if the disks are estimated to be too small.",
+	)
+}
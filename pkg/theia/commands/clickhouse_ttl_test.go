@@ -0,0 +1,108 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTableTTL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM cluster('{cluster}', system.tables)")).WithArgs("flows_local").
+		WillReturnRows(sqlmock.NewRows([]string{"Shard", "TableName", "TTL"}).
+			AddRow("1", "flows_local", "timeInserted + toIntervalHour(12)").
+			AddRow("2", "flows_local", ""))
+	data, err := getTableTTL(db, "flows_local")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Shard", "TableName", "TTL"},
+		{"1", "flows_local", "timeInserted + toIntervalHour(12)"},
+		{"2", "flows_local", "none"},
+	}, data)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM cluster('{cluster}', system.tables)")).WithArgs("flows_local").
+		WillReturnRows(sqlmock.NewRows([]string{"Shard", "TableName", "TTL"}))
+	_, err = getTableTTL(db, "flows_local")
+	assert.EqualError(t, err, "table flows_local was not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckTTLCapacity(t *testing.T) {
+	newest := time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC)
+	capacities := []shardCapacity{
+		// 1 GiB per day, 10 GiB available.
+		{shard: 1, bytes: 1 << 30, oldest: newest.Add(-24 * time.Hour), newest: newest, free: 9 << 30},
+		// Not enough data to estimate the retained size.
+		{shard: 2, bytes: 1 << 30, oldest: newest.Add(-time.Minute), newest: newest},
+	}
+	assert.NoError(t, checkTTLCapacity(capacities, 12*time.Hour, 0.5))
+	assert.NoError(t, checkTTLCapacity(capacities, 5*24*time.Hour, 0.5))
+	assert.EqualError(t, checkTTLCapacity(capacities, 7*24*time.Hour, 0.5),
+		"the disks are too small for a TTL of 168h0m0s: shard 1 would retain about 7.00 GiB, above 50% of the 10.00 GiB available, use --force to set it anyway")
+	assert.NoError(t, checkTTLCapacity(capacities, 7*24*time.Hour, 0.8))
+}
+
+func TestExpiredBefore(t *testing.T) {
+	now := time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC)
+	capacities := []shardCapacity{
+		{shard: 1, bytes: 1024, oldest: now.Add(-6 * time.Hour), newest: now},
+		{shard: 2},
+	}
+	boundary, expired := expiredBefore(capacities, 12*time.Hour, now)
+	assert.False(t, expired)
+	assert.Equal(t, now.Add(-12*time.Hour), boundary)
+	boundary, expired = expiredBefore(capacities, time.Hour, now)
+	assert.True(t, expired)
+	assert.Equal(t, now.Add(-time.Hour), boundary)
+}
+
+func TestSetTableTTL(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(ttlCapacityQuery).WithArgs("flows_local").
+		WillReturnRows(sqlmock.NewRows([]string{"Shard", "Bytes", "Oldest", "Newest", "Free"}).
+			AddRow(uint32(1), uint64(2048), time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC), uint64(1<<20)))
+	capacities, err := getShardCapacities(db, "flows_local")
+	require.NoError(t, err)
+	assert.Equal(t, []shardCapacity{{
+		shard:  1,
+		bytes:  2048,
+		oldest: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+		newest: time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC),
+		free:   1 << 20,
+	}}, capacities)
+
+	mock.ExpectExec("ALTER TABLE flows_local ON CLUSTER '{cluster}' MODIFY TTL timeInserted + INTERVAL 7 DAY SETTINGS materialize_ttl_after_modify = 0").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, setTableTTL(db, "flows_local", "7 DAY"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.50 KiB", formatSize(1536))
+	assert.Equal(t, "3.00 GiB", formatSize(3<<30))
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ingestionCheck is a data quality check of the flow records, which counts the
+// records inserted since a given time violating an expectation. The query has
+// a single parameter, the insertion time from which records are checked.
+type ingestionCheck struct {
+	name        string
+	description string
+	query       string
+}
+
+// ingestionChecks catch the bugs of flow exporters early. Byte counts are
+// unsigned in ClickHouse, so negative values exported by mistake are stored as
+// values larger than the maximum Int64.
+var ingestionChecks = []ingestionCheck{
+	{
+		name:        "null-key-columns",
+		description: "flow timestamps, IPs or cluster UUID are missing",
+		query: `
+SELECT count()
+FROM flows
+WHERE timeInserted >= ?
+	AND (flowStartSeconds = toDateTime(0) OR flowEndSeconds = toDateTime(0)
+		OR sourceIP = '' OR destinationIP = '' OR clusterUUID = '')`,
+	},
+	{
+		name:        "negative-byte-counts",
+		description: "byte counts are negative",
+		query: `
+SELECT count()
+FROM flows
+WHERE timeInserted >= ?
+	AND (toInt64(octetDeltaCount) < 0 OR toInt64(octetTotalCount) < 0
+		OR toInt64(reverseOctetDeltaCount) < 0 OR toInt64(reverseOctetTotalCount) < 0)`,
+	},
+	{
+		name:        "duplicate-flow-ids",
+		description: "records are exported more than once for the same flow and time range",
+		query: `
+SELECT sum(records - 1)
+FROM (
+	SELECT count() AS records
+	FROM flows
+	WHERE timeInserted >= ?
+	GROUP BY flowStartSeconds, flowEndSeconds, sourceIP, destinationIP, sourceTransportPort,
+		destinationTransportPort, protocolIdentifier, clusterUUID
+	HAVING records > 1
+)`,
+	},
+	{
+		name:        "clock-skew",
+		description: "flows end before they start, or more than 5 minutes after being inserted",
+		query: `
+SELECT count()
+FROM flows
+WHERE timeInserted >= ?
+	AND (flowStartSeconds > flowEndSeconds OR flowEndSeconds > timeInserted + INTERVAL 5 MINUTE)`,
+	},
+}
+
+// ingestionCheckResult is the number of records violating a check.
+type ingestionCheckResult struct {
+	check      ingestionCheck
+	violations uint64
+}
+
+var clickHouseValidateIngestionCmd = &cobra.Command{
+	Use:   "validate-ingestion",
+	Short: "Check the quality of the flow records ingested into ClickHouse",
+	Long: `Check the quality of the flow records recently inserted into
+ClickHouse, to catch the bugs of flow exporters early. The command counts the
+records with missing key columns (timestamps, IPs, cluster UUID), with negative
+byte counts, exported more than once (same 5-tuple, time range and cluster), or
+with inconsistent timestamps indicating clock skew. It fails if any record
+violates a check.`,
+	Args: cobra.NoArgs,
+	Example: `
+Check the flow records inserted during the last hour
+$ theia clickhouse validate-ingestion
+Check the flow records inserted during the last day
+$ theia clickhouse validate-ingestion --since 24h
+`,
+	RunE: validateIngestion,
+}
+
+func validateIngestion(cmd *cobra.Command, args []string) error {
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		return err
+	}
+	if since <= 0 {
+		return fmt.Errorf("since should be a positive duration")
+	}
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return err
+	}
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return err
+	}
+	if endpoint != "" {
+		err = ParseEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return err
+	}
+	connect, pf, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if pf != nil {
+		defer pf.Stop()
+	}
+	if err != nil {
+		return err
+	}
+	defer connect.Close()
+
+	results, err := runIngestionChecks(connect, time.Now().Add(-since))
+	if err != nil {
+		return err
+	}
+	if err := printIngestionCheckResults(cmd.OutOrStdout(), results); err != nil {
+		return err
+	}
+	failed := 0
+	for _, result := range results {
+		if result.violations > 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d data quality checks failed", failed, len(results))
+	}
+	return nil
+}
+
+func runIngestionChecks(connect *sql.DB, since time.Time) ([]ingestionCheckResult, error) {
+	var results []ingestionCheckResult
+	for _, check := range ingestionChecks {
+		result := ingestionCheckResult{check: check}
+		if err := connect.QueryRow(check.query, since).Scan(&result.violations); err != nil {
+			return nil, fmt.Errorf("error when running data quality check %s: %v", check.name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func printIngestionCheckResults(out io.Writer, results []ingestionCheckResult) error {
+	writer := tabwriter.NewWriter(out, 15, 0, 1, ' ', 0)
+	fmt.Fprintln(writer, "Check\tStatus\tViolations\tDescription\t")
+	for _, result := range results {
+		status := checkStatusPass
+		if result.violations > 0 {
+			status = checkStatusFail
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t\n", result.check.name, status, result.violations, result.check.description)
+	}
+	return writer.Flush()
+}
+
+func init() {
+	clickHouseCmd.AddCommand(clickHouseValidateIngestionCmd)
+	clickHouseValidateIngestionCmd.Flags().Duration(
+		"since",
+		time.Hour,
+		"Check the flow records inserted during this duration, e.g. 1h or 24h.",
+	)
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunIngestionChecks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	since := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, check := range ingestionChecks {
+		mock.ExpectQuery(regexp.QuoteMeta(check.query)).
+			WithArgs(since).
+			WillReturnRows(sqlmock.NewRows([]string{"violations"}).AddRow(uint64(i)))
+	}
+	results, err := runIngestionChecks(db, since)
+	require.NoError(t, err)
+	require.Len(t, results, len(ingestionChecks))
+	for i, result := range results {
+		assert.Equal(t, ingestionChecks[i].name, result.check.name)
+		assert.Equal(t, uint64(i), result.violations)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var out bytes.Buffer
+	require.NoError(t, printIngestionCheckResults(&out, results))
+	assert.Regexp(t, `null-key-columns\s+PASS\s+0\s+`, out.String())
+	assert.Regexp(t, `clock-skew\s+FAIL\s+3\s+`, out.String())
+}
+
+func TestRunIngestionChecksError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(ingestionChecks[0].query)).
+		WillReturnError(fmt.Errorf("table flows does not exist"))
+	_, err = runIngestionChecks(db, time.Now())
+	assert.EqualError(t, err, "error when running data quality check null-key-columns: table flows does not exist")
+}
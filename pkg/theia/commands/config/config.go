@@ -14,16 +14,32 @@
 
 package config
 
-import "time"
+import (
+	"time"
+
+	"antrea.io/theia/pkg/util/executor"
+)
+
+// FlowVisibilityNS is the Namespace in which Theia is installed. The CLI
+// overrides it with the theia-namespace flag.
+var FlowVisibilityNS = "flow-visibility"
 
 const (
-	FlowVisibilityNS        = "flow-visibility"
-	K8sQuantitiesReg        = "^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$"
-	SparkImage              = "projects.registry.vmware.com/antrea/theia-policy-recommendation:latest"
-	SparkImagePullPolicy    = "IfNotPresent"
-	SparkAppFile            = "local:///opt/spark/work-dir/policy_recommendation_job.py"
-	SparkServiceAccount     = "policy-recommendation-spark"
-	SparkVersion            = "3.1.1"
+	SparkImage              = executor.DefaultSparkImage
+	SparkImagePullPolicy    = executor.DefaultSparkImagePullPolicy
+	SparkAppFile            = executor.SparkAppFile
+	SparkServiceAccount     = executor.SparkServiceAccount
+	SparkVersion            = executor.DefaultSparkVersion
 	StatusCheckPollInterval = 5 * time.Second
 	StatusCheckPollTimeout  = 60 * time.Minute
+	MutationPollInterval    = 2 * time.Second
+	GrafanaServiceName      = "grafana"
+	GrafanaSecretName       = "grafana-secret"
 )
+
+// SparkImages maps the Node architectures supported by the policy
+// recommendation job to the Spark image to use on Nodes of that architecture.
+// Architectures sharing a multi-arch image map to the same image.
+var SparkImages = map[string]string{
+	"amd64": SparkImage,
+}
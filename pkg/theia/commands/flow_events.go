@@ -0,0 +1,149 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"antrea.io/theia/pkg/util/syslog"
+)
+
+const (
+	deniedFlowEventID = "denied-flow"
+	flowEventID       = "flow"
+)
+
+// ruleActions are the names of the network policy rule actions.
+var ruleActions = map[string]string{
+	"1": "Allow",
+	"2": "Drop",
+	"3": "Reject",
+}
+
+// protocolNames are the names of the most common IANA protocol numbers.
+var protocolNames = map[string]string{
+	"1":   "ICMP",
+	"6":   "TCP",
+	"17":  "UDP",
+	"58":  "IPv6-ICMP",
+	"132": "SCTP",
+}
+
+// theiaVersion returns the version of the theia binary, used as the Device
+// Version of the CEF records.
+func theiaVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// flowTimeMillis returns a flow timestamp, in the "YYYY-MM-DD hh:mm:ss"
+// format, as milliseconds since the epoch, or "" if it cannot be parsed.
+func flowTimeMillis(value string) string {
+	t, err := time.Parse("2006-01-02 15:04:05", value)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// qualifiedName returns namespace/name, or name if namespace is empty.
+func qualifiedName(namespace, name string) string {
+	if name == "" || namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// flowEvent returns the security event of a flow, given the values of the
+// columns with the given names. Flows dropped or rejected by a network policy
+// are denied-flow events, the other flows are informational flow events.
+func flowEvent(columnNames []string, values []string) *syslog.Event {
+	flow := make(map[string]string, len(columnNames))
+	for i, name := range columnNames {
+		flow[name] = values[i]
+	}
+	event := &syslog.Event{
+		ID:       flowEventID,
+		Name:     "Network flow",
+		Severity: 1,
+	}
+	if t, err := time.Parse("2006-01-02 15:04:05", flow["flowEndSeconds"]); err == nil {
+		event.Time = t
+	} else {
+		event.Time = time.Now()
+	}
+	// direction is the direction of the network policy rule reported with
+	// the flow: the rule which denied the flow if any, or else the rule which
+	// allowed it, the egress rule first as it is enforced first.
+	var direction string
+	for _, d := range []string{"egress", "ingress"} {
+		action := flow[d+"NetworkPolicyRuleAction"]
+		if action == "2" || action == "3" {
+			direction = d
+			event.ID = deniedFlowEventID
+			event.Name = "Flow denied by network policy"
+			event.Severity = 5
+			break
+		}
+		if direction == "" && ruleActions[action] != "" {
+			direction = d
+		}
+	}
+	protocol := flow["protocolIdentifier"]
+	if name, ok := protocolNames[protocol]; ok {
+		protocol = name
+	}
+	event.Fields = []syslog.Field{
+		{Key: "src", Value: flow["sourceIP"]},
+		{Key: "spt", Value: flow["sourceTransportPort"]},
+		{Key: "dst", Value: flow["destinationIP"]},
+		{Key: "dpt", Value: flow["destinationTransportPort"]},
+		{Key: "proto", Value: protocol},
+		{Key: "start", Value: flowTimeMillis(flow["flowStartSeconds"])},
+		{Key: "end", Value: flowTimeMillis(flow["flowEndSeconds"])},
+		{Key: "out", Value: flow["octetTotalCount"]},
+		{Key: "in", Value: flow["reverseOctetTotalCount"]},
+	}
+	// The Kubernetes details are custom strings, whose labels are only
+	// included when they have a value.
+	customStrings := [][2]string{
+		{"sourcePod", qualifiedName(flow["sourcePodNamespace"], flow["sourcePodName"])},
+		{"destinationPod", qualifiedName(flow["destinationPodNamespace"], flow["destinationPodName"])},
+		{"destinationService", flow["destinationServicePortName"]},
+	}
+	if direction != "" {
+		event.Fields = append(event.Fields, syslog.Field{Key: "act", Value: ruleActions[flow[direction+"NetworkPolicyRuleAction"]]})
+		customStrings = append(customStrings,
+			[2]string{"networkPolicy", qualifiedName(flow[direction+"NetworkPolicyNamespace"], flow[direction+"NetworkPolicyName"])},
+			[2]string{"networkPolicyRule", flow[direction+"NetworkPolicyRuleName"]},
+			[2]string{"networkPolicyDirection", direction},
+		)
+	}
+	for i, custom := range customStrings {
+		if custom[1] == "" {
+			continue
+		}
+		key := "cs" + strconv.Itoa(i+1)
+		event.Fields = append(event.Fields,
+			syslog.Field{Key: key + "Label", Value: custom[0]},
+			syslog.Field{Key: key, Value: custom[1]},
+		)
+	}
+	return event
+}
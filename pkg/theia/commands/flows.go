@@ -0,0 +1,370 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+var flowsCmd = &cobra.Command{
+	Use:   "flows",
+	Short: "Query the flow records",
+	Long: `Query the flow records stored by Theia. The flows are read from the
+ClickHouse database deployed with Theia by default, from the Snowflake
+database created by theia-sf with "--flows-backend snowflake", from the
+BigQuery dataset created by "theia bigquery onboard" with
+"--flows-backend bigquery", or from the PostgreSQL database migrated by
+"theia postgres migrate" with "--flows-backend postgres". The backend is usually set once in the config
+file, e.g.:
+
+  flows-backend: snowflake
+  snowflake-database: ANTREA_E4Y7TBQ9
+
+The Snowflake credentials are read from the SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER
+and SNOWFLAKE_PASSWORD environment variables, like with theia-sf. The BigQuery
+requests are authenticated with the access token of the
+GOOGLE_OAUTH_ACCESS_TOKEN environment variable, or of the active gcloud
+account. The PostgreSQL URL, which may include the password, can be set with
+the THEIA_POSTGRES_URL environment variable.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand to run like summary")
+	},
+}
+
+// openFlowsBackend returns the flows backend selected by the flags of cmd, and
+// a function to release its connection. Commands without the flows-backend
+// flag, e.g. "theia clickhouse export", use the ClickHouse backend.
+func openFlowsBackend(cmd *cobra.Command) (flows.Backend, func(), error) {
+	backendName := flows.ClickHouseBackend
+	if cmd.Flags().Lookup("flows-backend") != nil {
+		var err error
+		backendName, err = cmd.Flags().GetString("flows-backend")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	dialect, err := flows.DialectFor(backendName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var connect *sql.DB
+	cleanup := func() {}
+	switch dialect {
+	case flows.Snowflake:
+		config, err := getSnowflakeConfig(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+		connect, err = flows.OpenSnowflake(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the cold flows are included when theia-sf created the view
+		// over all the flows
+		dialect, err = flows.SnowflakeDialectFor(context.TODO(), connect, config)
+		if err != nil {
+			connect.Close()
+			return nil, nil, err
+		}
+	case flows.BigQuery:
+		config, err := getBigQueryConfig(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+		connect, err = flows.OpenBigQuery(config)
+		if err != nil {
+			return nil, nil, err
+		}
+	case flows.Postgres:
+		url, err := cmd.Flags().GetString("postgres-url")
+		if err != nil {
+			return nil, nil, err
+		}
+		connect, err = flows.OpenPostgres(url)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		connect, cleanup, err = openClickHouse(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the flows table deployed in ClickHouse may have been created
+		// by another version of Theia
+		schema, err := clickhouse.GetFlowSchema(connect)
+		if err == nil {
+			err = schema.Check(flows.QueryColumns)
+		}
+		if err != nil {
+			connect.Close()
+			cleanup()
+			return nil, nil, err
+		}
+		return &clickHouseFlowsBackend{Backend: flows.NewBackend(connect, dialect), schema: schema}, func() {
+			connect.Close()
+			cleanup()
+		}, nil
+	}
+	return flows.NewBackend(connect, dialect), func() {
+		connect.Close()
+		cleanup()
+	}, nil
+}
+
+// clickHouseFlowsBackend adapts the exports to the flows table deployed in
+// ClickHouse: the columns which are missing from older flow schemas are
+// exported as empty values.
+type clickHouseFlowsBackend struct {
+	flows.Backend
+	schema *clickhouse.FlowSchema
+}
+
+func (b *clickHouseFlowsBackend) Export(ctx context.Context, filter flows.Filter, columns []string, limit int, fn func(values []string) error) error {
+	available := b.schema.AvailableColumns(columns)
+	if len(available) == len(columns) {
+		return b.Backend.Export(ctx, filter, columns, limit, fn)
+	}
+	values := make([]string, len(columns))
+	return b.Backend.Export(ctx, filter, available, limit, func(availableValues []string) error {
+		j := 0
+		for i, column := range columns {
+			values[i] = ""
+			if b.schema.Columns[column] {
+				values[i] = availableValues[j]
+				j++
+			}
+		}
+		return fn(values)
+	})
+}
+
+func getSnowflakeConfig(cmd *cobra.Command) (flows.SnowflakeConfig, error) {
+	var config flows.SnowflakeConfig
+	var err error
+	config.Database, err = cmd.Flags().GetString("snowflake-database")
+	if err != nil {
+		return config, err
+	}
+	config.Schema, err = cmd.Flags().GetString("snowflake-schema")
+	if err != nil {
+		return config, err
+	}
+	config.Warehouse, err = cmd.Flags().GetString("snowflake-warehouse")
+	if err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+func getBigQueryConfig(cmd *cobra.Command) (flows.BigQueryConfig, error) {
+	var config flows.BigQueryConfig
+	var err error
+	config.Project, err = getGoogleCloudProject(cmd, "bigquery-project")
+	if err != nil {
+		return config, err
+	}
+	config.Dataset, err = cmd.Flags().GetString("bigquery-dataset")
+	if err != nil {
+		return config, err
+	}
+	config.Location, err = cmd.Flags().GetString("bigquery-location")
+	if err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// getGoogleCloudProject returns the value of the given flag, or of the
+// GOOGLE_CLOUD_PROJECT environment variable if the flag is not set.
+func getGoogleCloudProject(cmd *cobra.Command, flag string) (string, error) {
+	project, err := cmd.Flags().GetString(flag)
+	if err != nil {
+		return "", err
+	}
+	if project == "" {
+		project = os.Getenv(googleCloudProjectEnv)
+	}
+	return project, nil
+}
+
+// openClickHouse connects to ClickHouse, and returns a function to stop the
+// port forwarding used to reach it.
+func openClickHouse(cmd *cobra.Command) (*sql.DB, func(), error) {
+	return connectClickHouseFromFlags(cmd, false)
+}
+
+// openReadOnlyClickHouse is like openClickHouse, with the readonly=1 setting
+// on the connections, for the statements given by the user.
+func openReadOnlyClickHouse(cmd *cobra.Command) (*sql.DB, func(), error) {
+	return connectClickHouseFromFlags(cmd, true)
+}
+
+func connectClickHouseFromFlags(cmd *cobra.Command, readOnly bool) (*sql.DB, func(), error) {
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return nil, nil, err
+	}
+	if endpoint != "" {
+		err = ParseEndpoint(endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return nil, nil, err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return nil, nil, err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return nil, nil, err
+	}
+	client, pf, err := connectClickHouse(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, readOnly)
+	if err != nil {
+		if pf != nil {
+			pf.Stop()
+		}
+		return nil, nil, err
+	}
+	return client.DB(), func() {
+		if pf != nil {
+			pf.Stop()
+		}
+	}, nil
+}
+
+// getFlowsFilter returns the filter selected by the last and namespace flags.
+func getFlowsFilter(cmd *cobra.Command) (flows.Filter, error) {
+	last, err := cmd.Flags().GetString("last")
+	if err != nil {
+		return flows.Filter{}, err
+	}
+	start, _, err := parseFlowTimeRange("", "", last, time.UTC, time.Now())
+	if err != nil {
+		return flows.Filter{}, err
+	}
+	namespace, err := cmd.Flags().GetString("namespace")
+	if err != nil {
+		return flows.Filter{}, err
+	}
+	return flows.Filter{Start: start, Namespace: namespace}, nil
+}
+
+func addFlowsFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"last",
+		"1h",
+		"Only consider the flows recorded in this duration before now, e.g. 12h or 7d.",
+	)
+	cmd.Flags().StringP(
+		"namespace",
+		"n",
+		"",
+		"Only consider the flows from or to this Namespace.",
+	)
+}
+
+// getFlowsLimit returns the value of the limit flag, which must not be
+// negative.
+func getFlowsLimit(cmd *cobra.Command) (int, error) {
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		return 0, err
+	}
+	if err := validation.NonNegative("limit", int64(limit)); err != nil {
+		return 0, err
+	}
+	return limit, nil
+}
+
+func init() {
+	rootCmd.AddCommand(flowsCmd)
+	flowsCmd.PersistentFlags().String(
+		"flows-backend",
+		flows.ClickHouseBackend,
+		fmt.Sprintf("The database from which the flows are read, one of %v.", flows.Backends),
+	)
+	flowsCmd.PersistentFlags().String(
+		"snowflake-database",
+		"",
+		"The Snowflake database created by theia-sf. Required with the snowflake backend.",
+	)
+	flowsCmd.PersistentFlags().String(
+		"snowflake-schema",
+		"THEIA",
+		"The Snowflake schema created by theia-sf.",
+	)
+	flowsCmd.PersistentFlags().String(
+		"snowflake-warehouse",
+		"",
+		"The Snowflake Virtual Warehouse running the queries. Defaults to the query warehouse created by theia-sf.",
+	)
+	flowsCmd.PersistentFlags().String(
+		"bigquery-project",
+		"",
+		fmt.Sprintf("The Google Cloud project of the BigQuery dataset. Defaults to the %s environment variable.", googleCloudProjectEnv),
+	)
+	flowsCmd.PersistentFlags().String(
+		"bigquery-dataset",
+		defaultBigQueryDataset,
+		"The BigQuery dataset created by \"theia bigquery onboard\".",
+	)
+	flowsCmd.PersistentFlags().String(
+		"bigquery-location",
+		defaultBigQueryLocation,
+		"The location of the BigQuery dataset.",
+	)
+	flowsCmd.PersistentFlags().String(
+		"postgres-url",
+		"",
+		"The URL of the PostgreSQL database migrated by \"theia postgres migrate\". Required with the postgres backend.",
+	)
+	flowsCmd.PersistentFlags().String(
+		"clickhouse-endpoint",
+		"",
+		"The ClickHouse service endpoint.")
+	flowsCmd.PersistentFlags().Bool(
+		"use-cluster-ip",
+		false,
+		`Enable this option will use ClusterIP instead of port forwarding when connecting to the ClickHouse Service.
+It can only be used when running in cluster.`,
+	)
+	flowsCmd.PersistentFlags().String(
+		"clickhouse-ca-cert",
+		"",
+		`Path to a PEM file with the CA certificate(s) used to verify the ClickHouse endpoint. Providing it enables TLS.
+It is only used together with clickhouse-endpoint.`,
+	)
+}
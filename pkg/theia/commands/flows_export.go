@@ -0,0 +1,41 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var flowsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export flow records",
+	Long: `Export the flow records as CSV or JSON, from the backend selected with
+--flows-backend. The exports have the same columns and formats whatever the
+backend, and the identifiers can be anonymized in the same way as with
+"theia clickhouse export".`,
+	Args: cobra.NoArgs,
+	Example: `
+Export the flows of the last hour stored in Snowflake as CSV
+$ theia flows export --last 1h --file flows.csv --flows-backend snowflake --snowflake-database ANTREA_E4Y7TBQ9
+Export the flows of namespace app-a as JSON, with hashed identifiers
+$ THEIA_ANONYMIZE_KEY=<key> theia flows export --namespace app-a --format json --anonymize hash
+`,
+	RunE: exportFlows,
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsExportCmd)
+	addFlowExportFlags(flowsExportCmd)
+}
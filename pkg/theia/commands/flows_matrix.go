@@ -0,0 +1,97 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/flows"
+)
+
+var flowsMatrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Print the traffic matrix between Namespaces",
+	Long: `Print the number of bytes sent from each Namespace, in rows, to each
+Namespace, in columns. The endpoints outside the cluster are grouped in the
+"external" row and column.`,
+	Args: cobra.NoArgs,
+	Example: `
+Print the traffic matrix of the last day
+$ theia flows matrix --last 1d
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := getFlowsFilter(cmd)
+		if err != nil {
+			return err
+		}
+		backend, closeBackend, err := openFlowsBackend(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeBackend()
+		cells, err := backend.Matrix(context.TODO(), filter)
+		if err != nil {
+			return err
+		}
+		return printFlowsMatrix(cmd.OutOrStdout(), cells)
+	},
+}
+
+func printFlowsMatrix(out io.Writer, cells []flows.MatrixCell) error {
+	if len(cells) == 0 {
+		_, err := fmt.Fprintln(out, "No flows found")
+		return err
+	}
+	sourceSet := make(map[string]bool)
+	destinationSet := make(map[string]bool)
+	bytes := make(map[[2]string]uint64)
+	for _, cell := range cells {
+		sourceSet[cell.SourceNamespace] = true
+		destinationSet[cell.DestinationNamespace] = true
+		bytes[[2]string{cell.SourceNamespace, cell.DestinationNamespace}] += cell.Bytes
+	}
+	sortedKeys := func(set map[string]bool) []string {
+		keys := make([]string, 0, len(set))
+		for key := range set {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	sources := sortedKeys(sourceSet)
+	destinations := sortedKeys(destinationSet)
+	writer := tabwriter.NewWriter(out, 15, 0, 1, ' ', 0)
+	fmt.Fprintf(writer, "Source\\Destination\t%s\t\n", strings.Join(destinations, "\t"))
+	for _, source := range sources {
+		row := []string{source}
+		for _, destination := range destinations {
+			row = append(row, fmt.Sprint(bytes[[2]string{source, destination}]))
+		}
+		fmt.Fprintf(writer, "%s\t\n", strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsMatrixCmd)
+	addFlowsFilterFlags(flowsMatrixCmd)
+}
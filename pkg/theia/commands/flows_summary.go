@@ -0,0 +1,77 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/flows"
+)
+
+var flowsSummaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Summarize the flow records",
+	Long: `Print the number of flow records, and the bytes and packets they
+carried, in both directions.`,
+	Args: cobra.NoArgs,
+	Example: `
+Summarize the flows of the last day
+$ theia flows summary --last 1d
+Summarize the flows of Namespace app-a stored in Snowflake
+$ theia flows summary --namespace app-a --flows-backend snowflake --snowflake-database ANTREA_E4Y7TBQ9
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := getFlowsFilter(cmd)
+		if err != nil {
+			return err
+		}
+		backend, closeBackend, err := openFlowsBackend(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeBackend()
+		summary, err := backend.Summary(context.TODO(), filter)
+		if err != nil {
+			return err
+		}
+		return printFlowsSummary(cmd.OutOrStdout(), summary)
+	},
+}
+
+func printFlowsSummary(out io.Writer, summary *flows.Summary) error {
+	firstFlowEnd, lastFlowEnd := summary.FirstFlowEnd, summary.LastFlowEnd
+	if summary.Flows == 0 {
+		firstFlowEnd, lastFlowEnd = "N/A", "N/A"
+	}
+	writer := tabwriter.NewWriter(out, 15, 0, 1, ' ', 0)
+	fmt.Fprintf(writer, "Flows:\t%d\t\n", summary.Flows)
+	fmt.Fprintf(writer, "Bytes:\t%d\t\n", summary.Bytes)
+	fmt.Fprintf(writer, "Packets:\t%d\t\n", summary.Packets)
+	fmt.Fprintf(writer, "Reverse bytes:\t%d\t\n", summary.ReverseBytes)
+	fmt.Fprintf(writer, "Reverse packets:\t%d\t\n", summary.ReversePackets)
+	fmt.Fprintf(writer, "First flow end:\t%s\t\n", firstFlowEnd)
+	fmt.Fprintf(writer, "Last flow end:\t%s\t\n", lastFlowEnd)
+	return writer.Flush()
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsSummaryCmd)
+	addFlowsFilterFlags(flowsSummaryCmd)
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/flows"
+)
+
+func TestGetFlowsFilter(t *testing.T) {
+	cmd := &cobra.Command{}
+	addFlowsFilterFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("namespace", "app-a"))
+	filter, err := getFlowsFilter(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "app-a", filter.Namespace)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), filter.Start, time.Minute)
+
+	require.NoError(t, cmd.Flags().Set("last", "7d"))
+	filter, err = getFlowsFilter(cmd)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-7*24*time.Hour), filter.Start, time.Minute)
+
+	require.NoError(t, cmd.Flags().Set("last", "0s"))
+	_, err = getFlowsFilter(cmd)
+	assert.ErrorContains(t, err, "last should be a positive duration")
+}
+
+func TestPrintFlowsSummary(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, printFlowsSummary(&out, &flows.Summary{}))
+	assert.Contains(t, out.String(), "Flows:           0")
+	assert.Contains(t, out.String(), "First flow end:  N/A")
+
+	out.Reset()
+	require.NoError(t, printFlowsSummary(&out, &flows.Summary{Flows: 3, Bytes: 3000, FirstFlowEnd: "2022-08-01 12:01:00", LastFlowEnd: "2022-08-01 12:05:00"}))
+	assert.Contains(t, out.String(), "Bytes:           3000")
+	assert.Contains(t, out.String(), "Last flow end:   2022-08-01 12:05:00")
+}
+
+func TestPrintTopTalkers(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, printTopTalkers(&out, nil))
+	assert.Equal(t, "No flows found\n", out.String())
+
+	out.Reset()
+	require.NoError(t, printTopTalkers(&out, []flows.Talker{
+		{Source: "app-a/frontend", Destination: "app-b/backend", Flows: 4, Bytes: 4000, Packets: 40},
+	}))
+	assert.Equal(t, "Source         Destination    Flows          Bytes          Packets        \n"+
+		"app-a/frontend app-b/backend  4              4000           40             \n", out.String())
+}
+
+func TestPrintFlowsMatrix(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, printFlowsMatrix(&out, []flows.MatrixCell{
+		{SourceNamespace: "app-b", DestinationNamespace: "app-a", Bytes: 50},
+		{SourceNamespace: "app-a", DestinationNamespace: "app-b", Bytes: 2000},
+		{SourceNamespace: "app-a", DestinationNamespace: flows.ExternalEndpoint, Bytes: 100},
+	}))
+	assert.Equal(t, "Source\\Destination app-a          app-b          external       \n"+
+		"app-a              0              2000           100            \n"+
+		"app-b              50             0              0              \n", out.String())
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/flows"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+var flowsTopCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Print the top talkers",
+	Long: `Print the pairs of Pods, Namespaces or Nodes which exchanged the most
+bytes. Pods are identified by their IP, and Namespaces and Nodes by
+"external", when the flows are from or to outside the cluster.`,
+	Args: cobra.NoArgs,
+	Example: `
+Print the 10 pairs of Pods which exchanged the most bytes during the last hour
+$ theia flows top
+Print the 5 pairs of Namespaces which exchanged the most bytes during the last day
+$ theia flows top --by namespace --limit 5 --last 1d
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := getFlowsFilter(cmd)
+		if err != nil {
+			return err
+		}
+		groupBy, err := cmd.Flags().GetString("by")
+		if err != nil {
+			return err
+		}
+		if err := validation.OneOf("by", groupBy, flows.GroupBys...); err != nil {
+			return err
+		}
+		limit, err := getFlowsLimit(cmd)
+		if err != nil {
+			return err
+		}
+		backend, closeBackend, err := openFlowsBackend(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeBackend()
+		talkers, err := backend.Top(context.TODO(), filter, flows.GroupBy(groupBy), limit)
+		if err != nil {
+			return err
+		}
+		return printTopTalkers(cmd.OutOrStdout(), talkers)
+	},
+}
+
+func printTopTalkers(out io.Writer, talkers []flows.Talker) error {
+	if len(talkers) == 0 {
+		_, err := fmt.Fprintln(out, "No flows found")
+		return err
+	}
+	writer := tabwriter.NewWriter(out, 15, 0, 1, ' ', 0)
+	fmt.Fprintln(writer, "Source\tDestination\tFlows\tBytes\tPackets\t")
+	for _, talker := range talkers {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%d\t\n", talker.Source, talker.Destination, talker.Flows, talker.Bytes, talker.Packets)
+	}
+	return writer.Flush()
+}
+
+func init() {
+	flowsCmd.AddCommand(flowsTopCmd)
+	addFlowsFilterFlags(flowsTopCmd)
+	flowsTopCmd.Flags().String(
+		"by",
+		string(flows.GroupByPod),
+		fmt.Sprintf("The endpoints by which the flows are grouped, one of %v.", flows.GroupBys),
+	)
+	flowsTopCmd.Flags().Int(
+		"limit",
+		10,
+		"The number of top talkers to print. 0 means no limit.",
+	)
+}
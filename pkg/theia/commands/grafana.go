@@ -0,0 +1,176 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/theia/portforwarder"
+)
+
+var grafanaCmd = &cobra.Command{
+	Use:   "grafana",
+	Short: "Commands of Theia Grafana feature",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand to run like check-datasource or export")
+	},
+}
+
+// grafanaClient is a minimal client for the Grafana HTTP API, authenticated with
+// the admin credentials stored in the grafana-secret Secret.
+type grafanaClient struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+func getGrafanaSecret(clientset kubernetes.Interface) (username []byte, password []byte, err error) {
+	secret, err := clientset.CoreV1().Secrets(config.FlowVisibilityNS).Get(context.TODO(), config.GrafanaSecretName, metav1.GetOptions{})
+	if err != nil {
+		return username, password, fmt.Errorf("error %v when finding the Grafana secret, please check the deployment of Grafana", err)
+	}
+	username, ok := secret.Data["admin-username"]
+	if !ok {
+		return username, password, fmt.Errorf("error when getting the Grafana username")
+	}
+	password, ok = secret.Data["admin-password"]
+	if !ok {
+		return username, password, fmt.Errorf("error when getting the Grafana password")
+	}
+	return username, password, nil
+}
+
+// getGrafanaServiceAddr returns the ClusterIP and the port of the Grafana
+// Service, which only exposes the Grafana HTTP port.
+func getGrafanaServiceAddr(clientset kubernetes.Interface) (string, int, error) {
+	service, err := clientset.CoreV1().Services(config.FlowVisibilityNS).Get(context.TODO(), config.GrafanaServiceName, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("error when finding the Service %s: %v", config.GrafanaServiceName, err)
+	}
+	if len(service.Spec.Ports) == 0 {
+		return "", 0, fmt.Errorf("no port defined for the Service %s", config.GrafanaServiceName)
+	}
+	return service.Spec.ClusterIP, int(service.Spec.Ports[0].Port), nil
+}
+
+func SetupGrafanaConnection(clientset kubernetes.Interface, kubeconfig string, endpoint string, useClusterIP bool) (client *grafanaClient, portForward *portforwarder.PortForwarder, err error) {
+	if endpoint == "" {
+		serviceIP, servicePort, err := getGrafanaServiceAddr(clientset)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error when getting the Grafana Service address: %v", err)
+		}
+		if useClusterIP {
+			endpoint = fmt.Sprintf("http://%s:%d", serviceIP, servicePort)
+		} else {
+			listenAddress := "localhost"
+			listenPort := 3000
+			portForward, err = StartPortForward(kubeconfig, config.GrafanaServiceName, servicePort, listenAddress, listenPort)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error when forwarding port: %v", err)
+			}
+			endpoint = fmt.Sprintf("http://%s:%d", listenAddress, listenPort)
+		}
+	}
+	username, password, err := getGrafanaSecret(clientset)
+	if err != nil {
+		return nil, portForward, err
+	}
+	return &grafanaClient{
+		baseURL:  endpoint,
+		username: string(username),
+		password: string(password),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, portForward, nil
+}
+
+// do sends a request to the Grafana API and decodes the JSON response into
+// result if not nil.
+func (c *grafanaClient) do(method string, path string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(c.username, c.password)
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error when sending request to Grafana: %v", err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("error when reading response from Grafana: %v", err)
+	}
+	// The data source query API reports per-query errors with a 400 status
+	// code, together with a regular response body.
+	if response.StatusCode != http.StatusOK && !(response.StatusCode == http.StatusBadRequest && path == grafanaQueryPath) {
+		return fmt.Errorf("request %s %s failed with status %s: %s", method, path, response.Status, string(data))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("error when decoding response from Grafana: %v", err)
+	}
+	return nil
+}
+
+func (c *grafanaClient) get(path string, result interface{}) error {
+	return c.do(http.MethodGet, path, nil, result)
+}
+
+func (c *grafanaClient) post(path string, body interface{}, result interface{}) error {
+	return c.do(http.MethodPost, path, body, result)
+}
+
+func init() {
+	rootCmd.AddCommand(grafanaCmd)
+	grafanaCmd.PersistentFlags().String(
+		"grafana-endpoint",
+		"",
+		"The Grafana Service endpoint.",
+	)
+	grafanaCmd.PersistentFlags().Bool(
+		"use-cluster-ip",
+		false,
+		`Enable this option will use ClusterIP instead of port forwarding when connecting to the Grafana Service.
+It can only be used when running in cluster.`,
+	)
+}
@@ -0,0 +1,269 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	grafanaQueryPath = "/api/ds/query"
+
+	queryStatusOK             = "OK"
+	queryStatusNoData         = "NO DATA"
+	queryStatusSchemaMismatch = "SCHEMA MISMATCH"
+	queryStatusError          = "ERROR"
+)
+
+// canonicalDashboardUIDs are the UIDs of the dashboards shipped with Theia.
+var canonicalDashboardUIDs = []string{
+	"Yw6zwRkVk", // homepage
+	"t1UGX7t7k", // flow_records_dashboard
+	"Yxn0Ghh7k", // pod_to_pod_dashboard
+	"LGdxbW17z", // pod_to_service_dashboard
+	"K9SPrnJ7k", // pod_to_external_dashboard
+	"1F56RJh7z", // node_to_node_dashboard
+	"KJNMOwQnk", // networkpolicy_dashboard
+}
+
+// missingColumnsRegex matches the ClickHouse error returned when a query
+// references columns which do not exist, e.g. after an incomplete upgrade.
+var missingColumnsRegex = regexp.MustCompile(`Missing columns: ((?:'[^']+'\s*)+)`)
+
+type grafanaDataSource struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type grafanaPanel struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Targets []struct {
+		RefID  string `json:"refId"`
+		RawSQL string `json:"rawSql"`
+		Format int    `json:"format"`
+	} `json:"targets"`
+	Panels []grafanaPanel `json:"panels"`
+}
+
+type grafanaDashboard struct {
+	Dashboard struct {
+		UID    string         `json:"uid"`
+		Title  string         `json:"title"`
+		Panels []grafanaPanel `json:"panels"`
+	} `json:"dashboard"`
+}
+
+type grafanaQueryResponse struct {
+	Results map[string]struct {
+		Error  string `json:"error"`
+		Frames []struct {
+			Data struct {
+				Values [][]interface{} `json:"values"`
+			} `json:"data"`
+		} `json:"frames"`
+	} `json:"results"`
+}
+
+type datasourceCheckResult struct {
+	dashboard string
+	panel     string
+	status    string
+	details   string
+}
+
+var grafanaCheckDatasourceCmd = &cobra.Command{
+	Use:   "check-datasource",
+	Short: "Check that the ClickHouse datasource serves the Theia dashboards",
+	Long: `Check that the ClickHouse datasource in Grafana returns data for the
+queries of the dashboards shipped with Theia. Each query is run through Grafana,
+and reported as OK, NO DATA, SCHEMA MISMATCH (the query references columns which
+do not exist in ClickHouse, e.g. after an upgrade) or ERROR.`,
+	Args: cobra.NoArgs,
+	Example: `
+Check the ClickHouse datasource using the flow records of the last hour
+$ theia grafana check-datasource
+Check the ClickHouse datasource using the flow records of the last day
+$ theia grafana check-datasource --time-range 24h
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		datasourceName, err := cmd.Flags().GetString("datasource")
+		if err != nil {
+			return err
+		}
+		timeRange, err := cmd.Flags().GetString("time-range")
+		if err != nil {
+			return err
+		}
+		endpoint, err := cmd.Flags().GetString("grafana-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		client, pf, err := SetupGrafanaConnection(clientset, kubeconfig, endpoint, useClusterIP)
+		if pf != nil {
+			defer pf.Stop()
+		}
+		if err != nil {
+			return err
+		}
+		results, err := checkDatasource(client, datasourceName, timeRange)
+		if err != nil {
+			return err
+		}
+		table := [][]string{{"Dashboard", "Panel", "Status", "Details"}}
+		failed := 0
+		for _, result := range results {
+			table = append(table, []string{result.dashboard, result.panel, result.status, result.details})
+			if result.status == queryStatusError || result.status == queryStatusSchemaMismatch {
+				failed++
+			}
+		}
+		TableOutput(table)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d dashboard queries failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func checkDatasource(client *grafanaClient, datasourceName string, timeRange string) ([]datasourceCheckResult, error) {
+	var datasource grafanaDataSource
+	if err := client.get("/api/datasources/name/"+url.PathEscape(datasourceName), &datasource); err != nil {
+		return nil, fmt.Errorf("error when getting the %s datasource: %v", datasourceName, err)
+	}
+	var results []datasourceCheckResult
+	for _, uid := range canonicalDashboardUIDs {
+		var dashboard grafanaDashboard
+		if err := client.get("/api/dashboards/uid/"+uid, &dashboard); err != nil {
+			results = append(results, datasourceCheckResult{
+				dashboard: uid,
+				status:    queryStatusError,
+				details:   fmt.Sprintf("dashboard not found: %v", err),
+			})
+			continue
+		}
+		for _, panel := range flattenPanels(dashboard.Dashboard.Panels) {
+			for _, target := range panel.Targets {
+				if target.RawSQL == "" {
+					continue
+				}
+				result := datasourceCheckResult{
+					dashboard: dashboard.Dashboard.Title,
+					panel:     panel.Title,
+				}
+				result.status, result.details = runDashboardQuery(client, datasource, target.RefID, target.RawSQL, target.Format, timeRange)
+				results = append(results, result)
+			}
+		}
+	}
+	return results, nil
+}
+
+func flattenPanels(panels []grafanaPanel) []grafanaPanel {
+	var result []grafanaPanel
+	for _, panel := range panels {
+		result = append(result, panel)
+		result = append(result, flattenPanels(panel.Panels)...)
+	}
+	return result
+}
+
+func runDashboardQuery(client *grafanaClient, datasource grafanaDataSource, refID string, rawSQL string, format int, timeRange string) (string, string) {
+	if refID == "" {
+		refID = "A"
+	}
+	request := map[string]interface{}{
+		"from": "now-" + timeRange,
+		"to":   "now",
+		"queries": []map[string]interface{}{
+			{
+				"refId":         refID,
+				"datasource":    map[string]string{"uid": datasource.UID, "type": datasource.Type},
+				"queryType":     "sql",
+				"rawSql":        rawSQL,
+				"format":        format,
+				"intervalMs":    60000,
+				"maxDataPoints": 1000,
+			},
+		},
+	}
+	var response grafanaQueryResponse
+	if err := client.post(grafanaQueryPath, request, &response); err != nil {
+		return queryStatusError, err.Error()
+	}
+	result, ok := response.Results[refID]
+	if !ok {
+		return queryStatusError, "no result returned for query"
+	}
+	if result.Error != "" {
+		if matches := missingColumnsRegex.FindStringSubmatch(result.Error); matches != nil {
+			columns := strings.Fields(strings.ReplaceAll(matches[1], "'", " "))
+			return queryStatusSchemaMismatch, "missing columns: " + strings.Join(columns, ", ")
+		}
+		return queryStatusError, firstLine(result.Error)
+	}
+	rows := 0
+	for _, frame := range result.Frames {
+		if len(frame.Data.Values) > 0 {
+			rows += len(frame.Data.Values[0])
+		}
+	}
+	if rows == 0 {
+		return queryStatusNoData, ""
+	}
+	return queryStatusOK, fmt.Sprintf("%d rows", rows)
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
+
+func init() {
+	grafanaCmd.AddCommand(grafanaCheckDatasourceCmd)
+	grafanaCheckDatasourceCmd.Flags().String(
+		"datasource",
+		"ClickHouse",
+		"Name of the ClickHouse datasource in Grafana.",
+	)
+	grafanaCheckDatasourceCmd.Flags().String(
+		"time-range",
+		"1h",
+		"Time range of the flow records used to run the dashboard queries, relative to now. Example values include 30m, 1h, 7d.",
+	)
+}
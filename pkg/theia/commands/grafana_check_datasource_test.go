@@ -0,0 +1,92 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDatasource(t *testing.T) {
+	dashboard := `{"dashboard": {"uid": "t1UGX7t7k", "title": "Flow Records Dashboard", "panels": [
+		{"title": "Flow Records Table", "targets": [{"refId": "A", "rawSql": "SELECT ok"}]},
+		{"title": "Row", "panels": [
+			{"title": "Empty Panel", "targets": [{"refId": "A", "rawSql": "SELECT empty"}]},
+			{"title": "Upgraded Panel", "targets": [{"refId": "B", "rawSql": "SELECT missing"}]}
+		]},
+		{"title": "Text Panel"}
+	]}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/datasources/name/ClickHouse":
+			fmt.Fprint(w, `{"uid": "ch", "name": "ClickHouse", "type": "grafana-clickhouse-datasource"}`)
+		case r.URL.Path == "/api/dashboards/uid/t1UGX7t7k":
+			fmt.Fprint(w, dashboard)
+		case strings.HasPrefix(r.URL.Path, "/api/dashboards/uid/"):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Dashboard not found"}`)
+		case r.URL.Path == grafanaQueryPath:
+			var request struct {
+				Queries []map[string]interface{} `json:"queries"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			query := request.Queries[0]
+			refID := query["refId"].(string)
+			switch query["rawSql"] {
+			case "SELECT ok":
+				fmt.Fprintf(w, `{"results": {"%s": {"frames": [{"data": {"values": [[1, 2, 3]]}}]}}}`, refID)
+			case "SELECT empty":
+				fmt.Fprintf(w, `{"results": {"%s": {"frames": [{"data": {"values": [[]]}}]}}}`, refID)
+			case "SELECT missing":
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"results": {"%s": {"error": "code: 47, message: Missing columns: 'egressNetworkPolicyRuleName' 'egressNetworkPolicyRuleAction' while processing query"}}}`, refID)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &grafanaClient{baseURL: server.URL, username: "admin", password: "secret", httpClient: server.Client()}
+	results, err := checkDatasource(client, "ClickHouse", "1h")
+	require.NoError(t, err)
+
+	statuses := map[string]datasourceCheckResult{}
+	for _, result := range results {
+		statuses[result.dashboard+"/"+result.panel] = result
+	}
+	assert.Equal(t, len(canonicalDashboardUIDs)-1+3, len(results))
+	assert.Equal(t, queryStatusOK, statuses["Flow Records Dashboard/Flow Records Table"].status)
+	assert.Equal(t, "3 rows", statuses["Flow Records Dashboard/Flow Records Table"].details)
+	assert.Equal(t, queryStatusNoData, statuses["Flow Records Dashboard/Empty Panel"].status)
+	assert.Equal(t, queryStatusSchemaMismatch, statuses["Flow Records Dashboard/Upgraded Panel"].status)
+	assert.Equal(t, "missing columns: egressNetworkPolicyRuleName, egressNetworkPolicyRuleAction", statuses["Flow Records Dashboard/Upgraded Panel"].details)
+	assert.Equal(t, queryStatusError, statuses["Yxn0Ghh7k/"].status)
+
+	_, err = checkDatasource(client, "Unknown", "1h")
+	assert.ErrorContains(t, err, "error when getting the Unknown datasource")
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// volatileDashboardFields are set by Grafana when a dashboard is stored or
+// edited, and differ between Grafana instances for the same dashboard content.
+var volatileDashboardFields = []string{"id", "iteration", "version"}
+
+var (
+	invalidFileNameCharsRegex = regexp.MustCompile(`[^a-z0-9_\-]+`)
+	invalidUIDCharsRegex      = regexp.MustCompile(`[^A-Za-z0-9_\-]+`)
+)
+
+type grafanaSearchResult struct {
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+var grafanaExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the Grafana dashboards as JSON files",
+	Long: `Export the dashboards of Grafana as JSON files, one per dashboard.
+The dashboards are normalized so that they can be committed to a repository
+and provisioned again: the fields set by Grafana when storing a dashboard
+(id, iteration and version) are removed, and the keys are sorted. The files are
+named after the titles of the dashboards, and dashboards with the same title,
+e.g. in different folders, are named after their title and their UID.`,
+	Args: cobra.NoArgs,
+	Example: `
+Export all the dashboards to the dashboards directory
+$ theia grafana export --dir dashboards/
+Export the Flow Records dashboard only
+$ theia grafana export --dir dashboards/ --uid t1UGX7t7k
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := cmd.Flags().GetString("dir")
+		if err != nil {
+			return err
+		}
+		uids, err := cmd.Flags().GetStringSlice("uid")
+		if err != nil {
+			return err
+		}
+		endpoint, err := cmd.Flags().GetString("grafana-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		client, pf, err := SetupGrafanaConnection(clientset, kubeconfig, endpoint, useClusterIP)
+		if pf != nil {
+			defer pf.Stop()
+		}
+		if err != nil {
+			return err
+		}
+		files, err := exportDashboards(client, dir, uids)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fmt.Printf("Exported %s\n", file)
+		}
+		return nil
+	},
+}
+
+// exportDashboards writes the normalized dashboards with the given UIDs, or all
+// the dashboards if uids is empty, to dir and returns the paths of the files.
+func exportDashboards(client *grafanaClient, dir string, uids []string) ([]string, error) {
+	if len(uids) == 0 {
+		var dashboards []grafanaSearchResult
+		if err := client.get("/api/search?type=dash-db", &dashboards); err != nil {
+			return nil, fmt.Errorf("error when listing the Grafana dashboards: %v", err)
+		}
+		for _, dashboard := range dashboards {
+			uids = append(uids, dashboard.UID)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error when creating directory %s: %v", dir, err)
+	}
+	// The dashboards are all retrieved before writing them, so that the
+	// dashboards with the same title do not overwrite each other.
+	titles := make([]string, len(uids))
+	dashboards := make([][]byte, len(uids))
+	titleCounts := map[string]int{}
+	for i, uid := range uids {
+		var response struct {
+			Dashboard json.RawMessage `json:"dashboard"`
+		}
+		if err := client.get("/api/dashboards/uid/"+url.PathEscape(uid), &response); err != nil {
+			return nil, fmt.Errorf("error when getting the dashboard %s: %v", uid, err)
+		}
+		title, data, err := normalizeDashboard(response.Dashboard)
+		if err != nil {
+			return nil, fmt.Errorf("error when normalizing the dashboard %s: %v", uid, err)
+		}
+		titles[i], dashboards[i] = title, data
+		titleCounts[dashboardFileName(title, uid, false)]++
+	}
+	var files []string
+	for i, uid := range uids {
+		fileName := dashboardFileName(titles[i], uid, false)
+		filePath := filepath.Join(dir, dashboardFileName(titles[i], uid, titleCounts[fileName] > 1))
+		if err := os.WriteFile(filePath, dashboards[i], 0644); err != nil {
+			return files, fmt.Errorf("error when writing the dashboard %s to %s: %v", uid, filePath, err)
+		}
+		files = append(files, filePath)
+	}
+	return files, nil
+}
+
+// normalizeDashboard removes the volatile fields from the dashboard model and
+// re-encodes it with sorted keys, so that exporting the same dashboard twice
+// produces identical files.
+func normalizeDashboard(raw json.RawMessage) (string, []byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// Keep numbers as they are, e.g. to avoid converting large integers to floats.
+	decoder.UseNumber()
+	var dashboard map[string]interface{}
+	if err := decoder.Decode(&dashboard); err != nil {
+		return "", nil, err
+	}
+	if dashboard == nil {
+		return "", nil, fmt.Errorf("empty dashboard")
+	}
+	for _, field := range volatileDashboardFields {
+		delete(dashboard, field)
+	}
+	title, _ := dashboard["title"].(string)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	// encoding/json sorts map keys.
+	if err := encoder.Encode(dashboard); err != nil {
+		return "", nil, err
+	}
+	return title, buf.Bytes(), nil
+}
+
+// dashboardFileName returns the name of the file of a dashboard, made of its
+// title, and of its UID if withUID is set or if the title is empty.
+func dashboardFileName(title string, uid string, withUID bool) string {
+	name := strings.Trim(invalidFileNameCharsRegex.ReplaceAllString(strings.ToLower(title), "_"), "_")
+	safeUID := invalidUIDCharsRegex.ReplaceAllString(uid, "_")
+	if name == "" {
+		name = safeUID
+	} else if withUID {
+		name += "_" + safeUID
+	}
+	return name + ".json"
+}
+
+func init() {
+	grafanaCmd.AddCommand(grafanaExportCmd)
+	grafanaExportCmd.Flags().String(
+		"dir",
+		"dashboards",
+		"Directory to write the dashboards to. It is created if it does not exist.",
+	)
+	grafanaExportCmd.Flags().StringSlice(
+		"uid",
+		nil,
+		"UIDs of the dashboards to export. All the dashboards are exported if not set.",
+	)
+}
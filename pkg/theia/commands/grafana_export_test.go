@@ -0,0 +1,134 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDashboard(t *testing.T) {
+	testCases := []struct {
+		name             string
+		dashboard        string
+		expectedTitle    string
+		expectedData     string
+		expectedErrorMsg string
+	}{
+		{
+			name:          "volatile fields removed and keys sorted",
+			dashboard:     `{"uid": "abc", "version": 7, "id": 4, "title": "Flow Records", "iteration": 1659392847247, "panels": [{"type": "table", "id": 2}]}`,
+			expectedTitle: "Flow Records",
+			expectedData: `{
+  "panels": [
+    {
+      "id": 2,
+      "type": "table"
+    }
+  ],
+  "title": "Flow Records",
+  "uid": "abc"
+}
+`,
+		},
+		{
+			name:             "invalid dashboard",
+			dashboard:        `null`,
+			expectedErrorMsg: "empty dashboard",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			title, data, err := normalizeDashboard([]byte(tt.dashboard))
+			if tt.expectedErrorMsg != "" {
+				assert.ErrorContains(t, err, tt.expectedErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedTitle, title)
+			assert.Equal(t, tt.expectedData, string(data))
+		})
+	}
+}
+
+func TestExportDashboards(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/search":
+			assert.Equal(t, "dash-db", r.URL.Query().Get("type"))
+			fmt.Fprint(w, `[{"uid": "t1UGX7t7k", "title": "flow_records_dashboard"}, {"uid": "Yw6zwRkVk", "title": "Homepage"}]`)
+		case "/api/dashboards/uid/t1UGX7t7k":
+			fmt.Fprint(w, `{"meta": {"slug": "flow_records_dashboard"}, "dashboard": {"id": 4, "uid": "t1UGX7t7k", "title": "flow_records_dashboard"}}`)
+		case "/api/dashboards/uid/Yw6zwRkVk":
+			fmt.Fprint(w, `{"meta": {"slug": "homepage"}, "dashboard": {"id": 1, "uid": "Yw6zwRkVk", "title": "Homepage"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := &grafanaClient{baseURL: server.URL, httpClient: server.Client()}
+	dir := filepath.Join(t.TempDir(), "dashboards")
+
+	files, err := exportDashboards(client, dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "flow_records_dashboard.json"), filepath.Join(dir, "homepage.json")}, files)
+	data, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"title\": \"Homepage\",\n  \"uid\": \"Yw6zwRkVk\"\n}\n", string(data))
+
+	_, err = exportDashboards(client, dir, []string{"unknown"})
+	assert.ErrorContains(t, err, "error when getting the dashboard unknown")
+}
+
+func TestExportDashboardsSameTitle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/search":
+			fmt.Fprint(w, `[{"uid": "a1", "title": "Flows"}, {"uid": "b/2", "title": "Flows"}, {"uid": "c3", "title": "Homepage"}]`)
+		case "/api/dashboards/uid/a1":
+			fmt.Fprint(w, `{"dashboard": {"uid": "a1", "title": "Flows"}}`)
+		case "/api/dashboards/uid/b%2F2":
+			fmt.Fprint(w, `{"dashboard": {"uid": "b/2", "title": "Flows"}}`)
+		case "/api/dashboards/uid/c3":
+			fmt.Fprint(w, `{"dashboard": {"uid": "c3", "title": "Homepage"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := &grafanaClient{baseURL: server.URL, httpClient: server.Client()}
+	dir := t.TempDir()
+
+	files, err := exportDashboards(client, dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "flows_a1.json"), filepath.Join(dir, "flows_b_2.json"), filepath.Join(dir, "homepage.json")}, files)
+	data, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"uid": "b/2"`)
+}
+
+func TestDashboardFileName(t *testing.T) {
+	assert.Equal(t, "flow_records.json", dashboardFileName("Flow Records", "t1UGX7t7k", false))
+	assert.Equal(t, "flow_records_t1UGX7t7k.json", dashboardFileName("Flow Records", "t1UGX7t7k", true))
+	assert.Equal(t, "t1UGX7t7k.json", dashboardFileName("...", "t1UGX7t7k", false))
+	assert.Equal(t, "_etc.json", dashboardFileName("", "/../etc", false))
+}
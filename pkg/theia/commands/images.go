@@ -0,0 +1,76 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/install"
+)
+
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Commands to run Theia in disconnected environments",
+	Long: `Commands to run Theia in disconnected environments: "theia images export"
+lists the images to copy to a private registry, and "theia images import"
+renders the image overrides file which makes the other commands use the copies
+of the images, through their images-file flag.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand to run like export")
+	},
+}
+
+// theiaImages returns the sorted images of all the components installed by
+// "theia install flow-visibility" and of the policy recommendation jobs.
+func theiaImages() ([]string, error) {
+	objects, err := install.Manifests(install.Options{WithSparkOperator: true, WithGrafana: true})
+	if err != nil {
+		return nil, err
+	}
+	images := install.ContainerImages(objects)
+	found := make(map[string]bool, len(images))
+	for _, image := range images {
+		found[image] = true
+	}
+	for _, image := range config.SparkImages {
+		if !found[image] {
+			found[image] = true
+			images = append(images, image)
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// loadImageOverrides reads the image overrides file given with the
+// images-file flag, if any.
+func loadImageOverrides(cmd *cobra.Command) (install.ImageOverrides, error) {
+	path, err := cmd.Flags().GetString("images-file")
+	if err != nil {
+		return install.ImageOverrides{}, err
+	}
+	if path == "" {
+		return install.ImageOverrides{}, nil
+	}
+	return install.LoadImageOverrides(path)
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd)
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var imagesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "List the images used by Theia",
+	Long: `List the images of the components installed by "theia install flow-visibility"
+and of the policy recommendation jobs, one per line. The images can then be
+copied to the registry of a disconnected environment, e.g. with skopeo.`,
+	Example: `
+List the images used by Theia
+$ theia images export
+Save the list of images to a file
+$ theia images export --file images.txt
+`,
+	Args: cobra.NoArgs,
+	RunE: exportImages,
+}
+
+func exportImages(cmd *cobra.Command, args []string) error {
+	outputPath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	images, err := theiaImages()
+	if err != nil {
+		return err
+	}
+	list := strings.Join(images, "\n") + "\n"
+	if outputPath == "" {
+		fmt.Fprint(cmd.OutOrStdout(), list)
+		return nil
+	}
+	if err := os.WriteFile(outputPath, []byte(list), 0644); err != nil {
+		return fmt.Errorf("error when writing file %s: %v", outputPath, err)
+	}
+	return nil
+}
+
+func init() {
+	imagesCmd.AddCommand(imagesExportCmd)
+	imagesExportCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file path where you want to save the list of images. The list is written to stdout by default.",
+	)
+}
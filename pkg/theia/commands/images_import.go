@@ -0,0 +1,127 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/util/install"
+)
+
+var imagesImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Render the image overrides file of a private registry",
+	Long: `Render the image overrides file which maps the images used by Theia to their
+copies in a private registry, with the same names and tags. The file is then
+given to the commands which run images with their images-file flag, e.g.
+"theia install flow-visibility" and "theia policy-recommendation run". It can
+also be set once for all commands in the config file.`,
+	Example: `
+Render the image overrides file of a private registry
+$ theia images import --registry registry.example.com/theia --file images.yaml
+Render the image overrides file for a list of images exported by another CLI
+$ theia images import --registry registry.example.com/theia --images images.txt --file images.yaml
+Install Theia with the images of the private registry
+$ theia install flow-visibility --images-file images.yaml
+`,
+	Args: cobra.NoArgs,
+	RunE: importImages,
+}
+
+func importImages(cmd *cobra.Command, args []string) error {
+	registry, err := cmd.Flags().GetString("registry")
+	if err != nil {
+		return err
+	}
+	if registry == "" {
+		return fmt.Errorf("registry must be provided")
+	}
+	imagesPath, err := cmd.Flags().GetString("images")
+	if err != nil {
+		return err
+	}
+	outputPath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	var images []string
+	if imagesPath == "" {
+		images, err = theiaImages()
+		if err != nil {
+			return err
+		}
+	} else {
+		file, err := os.Open(imagesPath)
+		if err != nil {
+			return fmt.Errorf("error when opening file %s: %v", imagesPath, err)
+		}
+		defer file.Close()
+		images, err = readImageList(file)
+		if err != nil {
+			return fmt.Errorf("error when reading file %s: %v", imagesPath, err)
+		}
+	}
+	overrides := install.RenderImageOverrides(images, registry)
+	if outputPath == "" {
+		return overrides.Write(cmd.OutOrStdout())
+	}
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("error when creating file %s: %v", outputPath, err)
+	}
+	defer file.Close()
+	return overrides.Write(file)
+}
+
+// readImageList reads a list of images written by "theia images export",
+// skipping the empty lines and the comments.
+func readImageList(in io.Reader) ([]string, error) {
+	var images []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		images = append(images, line)
+	}
+	return images, scanner.Err()
+}
+
+func init() {
+	imagesCmd.AddCommand(imagesImportCmd)
+	imagesImportCmd.Flags().String(
+		"registry",
+		"",
+		"private registry to which the images were copied, e.g. registry.example.com/theia",
+	)
+	imagesImportCmd.Flags().String(
+		"images",
+		"",
+		"file with the list of images written by \"theia images export\", will use the images of this CLI if not specified",
+	)
+	imagesImportCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file path where you want to save the image overrides. They are written to stdout by default.",
+	)
+}
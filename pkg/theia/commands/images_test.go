@@ -0,0 +1,60 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/install"
+)
+
+func TestTheiaImages(t *testing.T) {
+	images, err := theiaImages()
+	require.NoError(t, err)
+	assert.Contains(t, images, config.SparkImage)
+	assert.Contains(t, images, "projects.registry.vmware.com/antrea/theia-spark-operator:v1beta2-1.3.3-3.1.1")
+	assert.IsIncreasing(t, images)
+}
+
+func TestReadImageList(t *testing.T) {
+	images, err := readImageList(strings.NewReader("# Theia images\nprojects.registry.vmware.com/antrea/busybox\n\n  projects.registry.vmware.com/antrea/theia-grafana:8.3.3\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"projects.registry.vmware.com/antrea/busybox", "projects.registry.vmware.com/antrea/theia-grafana:8.3.3"}, images)
+}
+
+func TestImportImages(t *testing.T) {
+	cmd := imagesImportCmd
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("registry", "registry.example.com/theia"))
+	defer cmd.Flags().Set("registry", "")
+	require.NoError(t, importImages(cmd, nil))
+	assert.Contains(t, out.String(), "\n  "+config.SparkImage+": registry.example.com/theia/theia-policy-recommendation:latest\n")
+}
+
+func TestOverrideSparkImages(t *testing.T) {
+	images := map[string]string{"amd64": config.SparkImage, "arm64": "projects.registry.vmware.com/antrea/theia-policy-recommendation-arm64:latest"}
+	overrides := install.ImageOverrides{Images: map[string]string{config.SparkImage: "registry.example.com/theia/theia-policy-recommendation:latest"}}
+	assert.Equal(t, map[string]string{
+		"amd64": "registry.example.com/theia/theia-policy-recommendation:latest",
+		"arm64": "projects.registry.vmware.com/antrea/theia-policy-recommendation-arm64:latest",
+	}, overrideSparkImages(images, overrides))
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/install"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+var installFlowVisibilityCmd = &cobra.Command{
+	Use:   "flow-visibility",
+	Short: "Install the Theia flow visibility components",
+	Long: `Install the ClickHouse Operator, ClickHouse and optionally Grafana and the
+Spark Operator in the flow-visibility Namespace, with the manifests embedded in
+this CLI, which match its version. The manifests are applied with server-side
+apply, so the command can be run again to update the components. The Theia Helm
+chart should be used to customize the installation further.`,
+	Example: `
+Install ClickHouse and Grafana
+$ theia install flow-visibility
+Install ClickHouse and the Spark Operator, without Grafana
+$ theia install flow-visibility --with-spark-operator --with-grafana=false
+Install the components with the images of a private registry
+$ theia install flow-visibility --image-registry registry.example.com/theia
+Install the components with the images of an image overrides file
+$ theia install flow-visibility --images-file images.yaml
+Print the manifests instead of installing them
+$ theia install flow-visibility --dry-run > flow-visibility.yml
+`,
+	RunE: installFlowVisibility,
+}
+
+func installFlowVisibility(cmd *cobra.Command, args []string) error {
+	var options install.Options
+	var err error
+	options.WithSparkOperator, err = cmd.Flags().GetBool("with-spark-operator")
+	if err != nil {
+		return err
+	}
+	options.WithGrafana, err = cmd.Flags().GetBool("with-grafana")
+	if err != nil {
+		return err
+	}
+	options.ImageRegistry, err = cmd.Flags().GetString("image-registry")
+	if err != nil {
+		return err
+	}
+	imageOverrides, err := loadImageOverrides(cmd)
+	if err != nil {
+		return err
+	}
+	options.Images = imageOverrides.Images
+	options.ImagePullPolicy, err = cmd.Flags().GetString("image-pull-policy")
+	if err != nil {
+		return err
+	}
+	if options.ImagePullPolicy != "" {
+		if err := validation.OneOf("image-pull-policy", options.ImagePullPolicy, "Always", "IfNotPresent", "Never"); err != nil {
+			return err
+		}
+	}
+	options.ClickHouseStorageSize, err = cmd.Flags().GetString("clickhouse-storage-size")
+	if err != nil {
+		return err
+	}
+	if options.ClickHouseStorageSize != "" {
+		if err := validation.Quantity("clickhouse-storage-size", options.ClickHouseStorageSize); err != nil {
+			return err
+		}
+	}
+	options.ClickHouseMonitorThreshold, err = cmd.Flags().GetString("clickhouse-monitor-threshold")
+	if err != nil {
+		return err
+	}
+	if options.ClickHouseMonitorThreshold != "" {
+		threshold, err := strconv.ParseFloat(options.ClickHouseMonitorThreshold, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			return fmt.Errorf("clickhouse-monitor-threshold should be a number between 0 and 1")
+		}
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	if config.FlowVisibilityNS != install.Namespace {
+		return fmt.Errorf("the embedded manifests can only be installed in the %s Namespace, use the Theia Helm chart to install Theia in the %s Namespace", install.Namespace, config.FlowVisibilityNS)
+	}
+	objects, err := install.Manifests(options)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return printManifests(os.Stdout, objects)
+	}
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return err
+	}
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	cache, err := discoveryCache(cmd, kubeconfig)
+	if err != nil {
+		return err
+	}
+	installer, err := install.NewInstallerForConfig(restConfig, cache, infoOut(cmd))
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	// The installation adds CRDs, which must not be missing from the cache
+	// for the next commands.
+	defer cache.Invalidate()
+	return installer.Apply(context.TODO(), objects)
+}
+
+// printManifests prints the objects as a multi-document YAML manifest.
+func printManifests(out io.Writer, objects []*unstructured.Unstructured) error {
+	for _, object := range objects {
+		data, err := yaml.Marshal(object.Object)
+		if err != nil {
+			return fmt.Errorf("error when encoding %s %s: %v", object.GetKind(), object.GetName(), err)
+		}
+		fmt.Fprintf(out, "---\n%s", data)
+	}
+	return nil
+}
+
+func init() {
+	installCmd.AddCommand(installFlowVisibilityCmd)
+	installFlowVisibilityCmd.Flags().Bool(
+		"with-spark-operator",
+		false,
+		"install the Spark Operator, which is required to run policy recommendation jobs with the spark-operator backend",
+	)
+	installFlowVisibilityCmd.Flags().Bool(
+		"with-grafana",
+		true,
+		"install Grafana and the Theia dashboards",
+	)
+	installFlowVisibilityCmd.Flags().String(
+		"image-registry",
+		"",
+		fmt.Sprintf("registry of the images, replacing %s, e.g. to pull the images from a private registry", install.DefaultImageRegistry),
+	)
+	installFlowVisibilityCmd.Flags().String(
+		"images-file",
+		"",
+		"image overrides file rendered by \"theia images import\", which takes precedence over image-registry",
+	)
+	installFlowVisibilityCmd.Flags().String(
+		"image-pull-policy",
+		"",
+		"pull policy of all the containers, one of Always, IfNotPresent or Never, will use the pull policies of the manifests if not specified",
+	)
+	installFlowVisibilityCmd.Flags().String(
+		"clickhouse-storage-size",
+		"",
+		"size of the ClickHouse storage, e.g. 100Mi, will use 8Gi if not specified",
+	)
+	installFlowVisibilityCmd.Flags().String(
+		"clickhouse-monitor-threshold",
+		"",
+		"ratio of the ClickHouse storage above which the ClickHouse monitor deletes the oldest records, between 0 and 1, will use 0.5 if not specified",
+	)
+	installFlowVisibilityCmd.Flags().Bool(
+		"dry-run",
+		false,
+		"print the manifests instead of installing them",
+	)
+}
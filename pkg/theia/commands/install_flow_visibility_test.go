@@ -0,0 +1,42 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/util/install"
+)
+
+func TestPrintManifests(t *testing.T) {
+	objects, err := install.Manifests(install.Options{WithSparkOperator: true, ImageRegistry: "registry.example.com/theia"})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, printManifests(&out, objects))
+	documents := strings.Split(out.String(), "---\n")[1:]
+	require.Len(t, documents, len(objects))
+	assert.Contains(t, out.String(), "image: registry.example.com/theia/theia-spark-operator:v1beta2-1.3.3-3.1.1\n")
+	assert.NotContains(t, out.String(), "image: "+install.DefaultImageRegistry+"/")
+	assert.NotContains(t, out.String(), "name: grafana\n")
+	// The printed manifests can be applied with kubectl.
+	decoded, err := decodeRBACManifests([]byte(documents[len(documents)-1]))
+	require.NoError(t, err)
+	assert.Len(t, decoded, 1)
+}
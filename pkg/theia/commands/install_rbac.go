@@ -0,0 +1,235 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+)
+
+//go:embed manifests/rbac.yaml
+var rbacManifestTemplate string
+
+var installRBACCmd = &cobra.Command{
+	Use:   "rbac",
+	Short: "Install the RBAC resources required by Theia",
+	Long: `Install the ServiceAccounts, Roles and RoleBindings required by the
+policy recommendation Spark jobs and by theia-manager in a Namespace. The
+Namespace is created if it does not exist, and the existing Roles and
+RoleBindings are updated. This is only required when Theia is installed in a
+custom Namespace without the Theia Helm chart.`,
+	Example: `
+Install the RBAC resources in the foo Namespace
+$ theia install rbac --namespace foo
+Print the RBAC resources without installing them
+$ theia install rbac --namespace foo --dry-run > rbac.yml
+`,
+	RunE: installRBAC,
+}
+
+func installRBAC(cmd *cobra.Command, args []string) error {
+	namespace, err := cmd.Flags().GetString("namespace")
+	if err != nil {
+		return err
+	}
+	if namespace == "" {
+		namespace = config.FlowVisibilityNS
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	manifests, err := renderRBACManifests(namespace)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		_, err := os.Stdout.Write(manifests)
+		return err
+	}
+	objects, err := decodeRBACManifests(manifests)
+	if err != nil {
+		return err
+	}
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	return applyRBACObjects(os.Stdout, clientset, objects)
+}
+
+// renderRBACManifests renders the RBAC manifests for the Namespace.
+func renderRBACManifests(namespace string) ([]byte, error) {
+	tmpl, err := template.New("rbac").Parse(rbacManifestTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error when parsing the RBAC manifests: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct {
+		Namespace           string
+		SparkServiceAccount string
+	}{
+		Namespace:           namespace,
+		SparkServiceAccount: config.SparkServiceAccount,
+	}); err != nil {
+		return nil, fmt.Errorf("error when rendering the RBAC manifests: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRBACManifests decodes the rendered RBAC manifests into typed objects,
+// in the order in which they must be created.
+func decodeRBACManifests(manifests []byte) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(manifests)))
+	for {
+		document, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error when reading the RBAC manifests: %v", err)
+		}
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		object, _, err := scheme.Codecs.UniversalDeserializer().Decode(document, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error when decoding the RBAC manifests: %v", err)
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// applyRBACObjects creates the objects, or updates them if they already exist,
+// and prints the result for each object. The existing Namespace and
+// ServiceAccounts are left unchanged.
+func applyRBACObjects(out io.Writer, clientset kubernetes.Interface, objects []runtime.Object) error {
+	for _, object := range objects {
+		kind, name, result, err := applyRBACObject(context.TODO(), clientset, object)
+		if err != nil {
+			return fmt.Errorf("error when applying %s %s: %v", kind, name, err)
+		}
+		fmt.Fprintf(out, "%s %s %s\n", kind, name, result)
+	}
+	return nil
+}
+
+func applyRBACObject(ctx context.Context, clientset kubernetes.Interface, object runtime.Object) (kind, name, result string, err error) {
+	result = "created"
+	switch o := object.(type) {
+	case *corev1.Namespace:
+		kind, name = "Namespace", o.Name
+		_, err = clientset.CoreV1().Namespaces().Create(ctx, o, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			result, err = "unchanged", nil
+		}
+	case *corev1.ServiceAccount:
+		kind, name = "ServiceAccount", o.Name
+		_, err = clientset.CoreV1().ServiceAccounts(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			result, err = "unchanged", nil
+		}
+	case *rbacv1.Role:
+		kind, name = "Role", o.Name
+		client := clientset.RbacV1().Roles(o.Namespace)
+		_, err = client.Create(ctx, o, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			var existing *rbacv1.Role
+			if existing, err = client.Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+				o.ResourceVersion = existing.ResourceVersion
+				result = "configured"
+				_, err = client.Update(ctx, o, metav1.UpdateOptions{})
+			}
+		}
+	case *rbacv1.RoleBinding:
+		kind, name = "RoleBinding", o.Name
+		client := clientset.RbacV1().RoleBindings(o.Namespace)
+		_, err = client.Create(ctx, o, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			var existing *rbacv1.RoleBinding
+			if existing, err = client.Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+				o.ResourceVersion = existing.ResourceVersion
+				result = "configured"
+				_, err = client.Update(ctx, o, metav1.UpdateOptions{})
+			}
+		}
+	case *rbacv1.ClusterRole:
+		kind, name = "ClusterRole", o.Name
+		client := clientset.RbacV1().ClusterRoles()
+		_, err = client.Create(ctx, o, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			var existing *rbacv1.ClusterRole
+			if existing, err = client.Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+				o.ResourceVersion = existing.ResourceVersion
+				result = "configured"
+				_, err = client.Update(ctx, o, metav1.UpdateOptions{})
+			}
+		}
+	case *rbacv1.ClusterRoleBinding:
+		kind, name = "ClusterRoleBinding", o.Name
+		client := clientset.RbacV1().ClusterRoleBindings()
+		_, err = client.Create(ctx, o, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			var existing *rbacv1.ClusterRoleBinding
+			if existing, err = client.Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+				o.ResourceVersion = existing.ResourceVersion
+				result = "configured"
+				_, err = client.Update(ctx, o, metav1.UpdateOptions{})
+			}
+		}
+	default:
+		return object.GetObjectKind().GroupVersionKind().Kind, "", "", fmt.Errorf("unsupported object")
+	}
+	return kind, name, result, err
+}
+
+func init() {
+	installCmd.AddCommand(installRBACCmd)
+	installRBACCmd.Flags().StringP(
+		"namespace",
+		"n",
+		"",
+		"Namespace in which to install the RBAC resources, will use the value of theia-namespace if not specified",
+	)
+	installRBACCmd.Flags().Bool(
+		"dry-run",
+		false,
+		"print the RBAC manifests instead of installing them",
+	)
+}
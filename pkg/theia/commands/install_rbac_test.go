@@ -0,0 +1,112 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInstallRBAC(t *testing.T) {
+	manifests, err := renderRBACManifests("foo")
+	require.NoError(t, err)
+	objects, err := decodeRBACManifests(manifests)
+	require.NoError(t, err)
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		require.NoError(t, err)
+		if namespace := accessor.GetNamespace(); namespace != "" {
+			assert.Equal(t, "foo", namespace)
+		}
+	}
+
+	clientset := fake.NewSimpleClientset()
+	var out bytes.Buffer
+	require.NoError(t, applyRBACObjects(&out, clientset, objects))
+	assert.Equal(t, `Namespace foo created
+ServiceAccount policy-recommendation-spark created
+Role spark-role created
+RoleBinding spark created
+ServiceAccount theia-manager created
+ClusterRole theia-manager-role created
+ClusterRoleBinding theia-manager-cluster-role-binding created
+`, out.String())
+	binding, err := clientset.RbacV1().ClusterRoleBindings().Get(context.TODO(), "theia-manager-cluster-role-binding", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{{Kind: "ServiceAccount", Name: "theia-manager", Namespace: "foo"}}, binding.Subjects)
+
+	// Applying the manifests again updates the existing objects.
+	objects, err = decodeRBACManifests(manifests)
+	require.NoError(t, err)
+	out.Reset()
+	require.NoError(t, applyRBACObjects(&out, clientset, objects))
+	assert.Equal(t, `Namespace foo unchanged
+ServiceAccount policy-recommendation-spark unchanged
+Role spark-role configured
+RoleBinding spark configured
+ServiceAccount theia-manager unchanged
+ClusterRole theia-manager-role configured
+ClusterRoleBinding theia-manager-cluster-role-binding configured
+`, out.String())
+}
+
+// TestRBACManifestsMatchChart checks that the rules of the embedded RBAC
+// manifests are kept in sync with the Theia Helm chart.
+func TestRBACManifestsMatchChart(t *testing.T) {
+	manifests, err := renderRBACManifests("flow-visibility")
+	require.NoError(t, err)
+	objects, err := decodeRBACManifests(manifests)
+	require.NoError(t, err)
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, object := range objects {
+		switch o := object.(type) {
+		case *rbacv1.Role:
+			rules[o.Name] = o.Rules
+		case *rbacv1.ClusterRole:
+			rules[o.Name] = o.Rules
+		}
+	}
+	for name, template := range map[string]string{
+		"spark-role":         "../../../build/charts/theia/templates/spark-operator/spark-role.yaml",
+		"theia-manager-role": "../../../build/charts/theia/templates/theia-manager/clusterrole.yaml",
+	} {
+		data, err := os.ReadFile(template)
+		require.NoError(t, err)
+		// Drop the Helm conditionals and template the Namespace, the rules
+		// do not depend on the values of the chart.
+		var lines []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(line, "{{") {
+				lines = append(lines, strings.ReplaceAll(line, "{{ .Release.Namespace }}", "flow-visibility"))
+			}
+		}
+		var chartRole struct {
+			Rules []rbacv1.PolicyRule `json:"rules"`
+		}
+		require.NoError(t, k8syaml.Unmarshal([]byte(strings.Join(lines, "\n")), &chartRole))
+		assert.Equal(t, chartRole.Rules, rules[name], "rules of %s differ from %s", name, template)
+	}
+}
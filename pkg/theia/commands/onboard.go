@@ -0,0 +1,50 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var onboardCmd = &cobra.Command{
+	Use:   "onboard",
+	Short: "Guided workflows to protect workloads with Antrea-native policies",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand to run like namespace")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(onboardCmd)
+	onboardCmd.PersistentFlags().String(
+		"clickhouse-endpoint",
+		"",
+		"The ClickHouse Service endpoint.",
+	)
+	onboardCmd.PersistentFlags().Bool(
+		"use-cluster-ip",
+		false,
+		`Enable this option will use ClusterIP instead of port forwarding when connecting to the ClickHouse Service.
+It can only be used when running in cluster.`,
+	)
+	onboardCmd.PersistentFlags().String(
+		"clickhouse-ca-cert",
+		"",
+		`Path to a PEM file with the CA certificate(s) used to verify the ClickHouse endpoint. Providing it enables TLS.
+It is only used together with clickhouse-endpoint.`,
+	)
+}
@@ -0,0 +1,549 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+)
+
+const (
+	// auditLabel is set on the policies applied in audit mode, so that they
+	// can be found and deleted before enforcing the recommended policies.
+	auditLabel = "theia.antrea.io/audit"
+	// Number of sample flows shown for the flows which would be rejected.
+	impactSampleFlows = 5
+)
+
+type flowCoverage struct {
+	flows      uint64
+	pods       int
+	unseenPods []string
+}
+
+// impactResult is the result of the simulation of the recommended policies
+// for the flows in one direction.
+type impactResult struct {
+	direction string
+	// flows is the number of flows of the Pods selected by the default deny
+	// rules.
+	flows    uint64
+	rejected uint64
+	samples  []sampleFlow
+}
+
+// onboardNamespaceCmd represents the onboard namespace command
+var onboardNamespaceCmd = &cobra.Command{
+	Use:   "namespace <namespace>",
+	Short: "Guided onboarding of a namespace to Antrea-native policies",
+	Long: `Guide an application team through the protection of a namespace with
+Antrea-native policies, in 4 steps:
+1. Check the flow coverage of the namespace: the policies are recommended from
+   the flows recorded in ClickHouse, so Pods without flows will not have rules.
+2. Run a policy recommendation job scoped to the namespace, with default deny
+   rules for the Pods which have allow rules (policy type anp-deny-applied).
+3. Simulate the impact of the recommended policies on the flows of the
+   namespace, by showing the flows which would be rejected.
+4. Apply the recommended policies in audit mode: default deny rules are
+   applied as Allow rules with logging enabled, so that no traffic is blocked
+   and the connections which would be rejected are logged by Antrea.
+
+Each step asks for confirmation before proceeding, unless --yes is provided.`,
+	Args: cobra.ExactArgs(1),
+	Example: `
+Onboard namespace app-a, using the flows of the last 7 days
+$ theia onboard namespace app-a --since 7d
+Onboard namespace app-a without confirmation, and save the recommended policies to enforce them later
+$ theia onboard namespace app-a --yes --file app-a-policies.yaml
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace := args[0]
+		sinceStr, err := cmd.Flags().GetString("since")
+		if err != nil {
+			return err
+		}
+		since, err := ParseDuration(sinceStr)
+		if err != nil {
+			return err
+		}
+		assumeYes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			return err
+		}
+		filePath, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		if _, err := clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{}); err != nil {
+			return fmt.Errorf("error when getting namespace %s: %v", namespace, err)
+		}
+		if err := PolicyRecoPreCheck(clientset); err != nil {
+			return err
+		}
+		connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+		if portForward != nil {
+			defer portForward.Stop()
+		}
+		if err != nil {
+			return err
+		}
+		defer connect.Close()
+
+		wizard := &onboardWizard{
+			clientset: clientset,
+			connect:   connect,
+			namespace: namespace,
+			start:     time.Now().Add(-since),
+			in:        bufio.NewReader(cmd.InOrStdin()),
+			out:       cmd.OutOrStdout(),
+			assumeYes: assumeYes,
+		}
+		return wizard.run(filePath)
+	},
+}
+
+type onboardWizard struct {
+	clientset kubernetes.Interface
+	connect   *sql.DB
+	namespace string
+	// start of the period of the flows used for the coverage check, the
+	// recommendation and the simulation.
+	start     time.Time
+	in        *bufio.Reader
+	out       io.Writer
+	assumeYes bool
+}
+
+// confirm asks a yes/no question, the default answer being no.
+func (w *onboardWizard) confirm(question string) (bool, error) {
+	if w.assumeYes {
+		return true, nil
+	}
+	return promptConfirmation(w.in, w.out, question)
+}
+
+func (w *onboardWizard) run(filePath string) error {
+	fmt.Fprintf(w.out, "Step 1/4: checking the flow coverage of namespace %s since %s\n", w.namespace, FormatTimestamp(w.start))
+	pods, err := w.clientset.CoreV1().Pods(w.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error when listing the Pods of namespace %s: %v", w.namespace, err)
+	}
+	var podNames []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodRunning {
+			podNames = append(podNames, pod.Name)
+		}
+	}
+	coverage, err := getFlowCoverage(w.connect, w.namespace, w.start, podNames)
+	if err != nil {
+		return fmt.Errorf("error when getting the flow coverage from ClickHouse, %v", err)
+	}
+	fmt.Fprintf(w.out, "%d flows, %d out of %d running Pods have flows\n", coverage.flows, coverage.pods-len(coverage.unseenPods), coverage.pods)
+	if coverage.flows == 0 {
+		return fmt.Errorf("no flows were recorded for namespace %s since %s, policies cannot be recommended", w.namespace, FormatTimestamp(w.start))
+	}
+	if len(coverage.unseenPods) > 0 {
+		fmt.Fprintf(w.out, "Pods without flows, for which no allow rules will be recommended: %s\n", strings.Join(coverage.unseenPods, ", "))
+		if ok, err := w.confirm("Continue anyway?"); err != nil || !ok {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w.out, "Step 2/4: running a policy recommendation job scoped to namespace %s\n", w.namespace)
+	yamls, err := w.recommend()
+	if err != nil {
+		return err
+	}
+	docs, err := filterNamespacePolicies(yamls, w.namespace)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		fmt.Fprintf(w.out, "No policies were recommended for namespace %s\n", w.namespace)
+		return nil
+	}
+	namespaceYamls := strings.Join(docs, "---\n")
+	fmt.Fprintf(w.out, "%d policies recommended for namespace %s\n", len(docs), w.namespace)
+	if filePath != "" {
+		if err := os.WriteFile(filePath, []byte(namespaceYamls), 0600); err != nil {
+			return fmt.Errorf("error when writing recommendation result to file: %v", err)
+		}
+		fmt.Fprintf(w.out, "Recommended policies saved to %s\n", filePath)
+	}
+
+	fmt.Fprintln(w.out, "Step 3/4: simulating the impact of the recommended policies")
+	rules, err := listRecommendedRules(namespaceYamls)
+	if err != nil {
+		return err
+	}
+	impact, err := simulateImpact(w.connect, rules, w.start)
+	if err != nil {
+		return fmt.Errorf("error when simulating the recommended policies, %v", err)
+	}
+	printImpact(w.out, impact)
+
+	fmt.Fprintln(w.out, "Step 4/4: applying the recommended policies in audit mode")
+	if ok, err := w.confirm(fmt.Sprintf("Apply the %d recommended policies in audit mode?", len(docs))); err != nil || !ok {
+		return err
+	}
+	for _, doc := range docs {
+		policy, err := auditModePolicy(doc)
+		if err != nil {
+			return err
+		}
+		if _, err := applyPolicy(w.clientset, policy, false); err != nil {
+			return err
+		}
+		metadata := policy["metadata"].(map[string]interface{})
+		fmt.Fprintf(w.out, "%s %s created\n", policy["kind"], metadata["name"])
+	}
+	fmt.Fprintf(w.out, `The recommended policies are applied in audit mode: the connections which would be rejected are
+logged by Antrea instead. To enforce the policies, delete the audited ones with
+"kubectl delete anp,acnp,cg -A -l %s=true" and apply the recommended policies.
+`, auditLabel)
+	return nil
+}
+
+// recommend runs a policy recommendation job scoped to the namespace and
+// returns the recommended policies.
+func (w *onboardWizard) recommend() (string, error) {
+	recommendationID := uuid.New().String()
+	scope, err := json.Marshal([]string{w.namespace})
+	if err != nil {
+		return "", err
+	}
+	recoJobArgs := []string{
+		"--type", string(policyrecommendation.RecommendationTypeInitial),
+		"--limit", "0",
+		"--option", policyrecommendation.PolicyTypeANPDenyApplied.OptionArg(),
+		"--start_time", w.start.UTC().Format("2006-01-02 15:04:05"),
+		"--ns_scope", string(scope),
+		"--rm_labels", "true",
+		"--to_services", "true",
+		"--id", recommendationID,
+	}
+	sparkResourceArgs := SparkResourceArgs{
+		executorInstances:   1,
+		driverCoreRequest:   "200m",
+		driverMemory:        "512M",
+		executorCoreRequest: "200m",
+		executorMemory:      "512M",
+	}
+	if err := sparkResourceArgs.selectSparkImage(w.clientset, config.SparkImages); err != nil {
+		return "", err
+	}
+	if err := createSparkApplication(w.clientset, newPolicyRecommendationApplication(recommendationID, recoJobArgs, &sparkResourceArgs)); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(w.out, "Waiting for policy recommendation job %s to complete\n", recommendationID)
+	if err := waitForPolicyRecommendationJob(context.TODO(), w.clientset, recommendationID, defaultJobWaitOptions); err != nil {
+		return "", err
+	}
+	yamls, err := getResultFromClickHouse(w.connect, recommendationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("error when getting result from ClickHouse, %v", err)
+	}
+	return yamls, nil
+}
+
+// getFlowCoverage returns the number of flows from or to the namespace since
+// start, and the running Pods which have none of these flows.
+func getFlowCoverage(connect *sql.DB, namespace string, start time.Time, pods []string) (*flowCoverage, error) {
+	coverage := &flowCoverage{pods: len(pods)}
+	query := "SELECT count() FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?)"
+	if err := connect.QueryRow(query, start, namespace, namespace).Scan(&coverage.flows); err != nil {
+		return nil, err
+	}
+	query = `SELECT DISTINCT podName FROM (
+SELECT sourcePodName AS podName FROM flows WHERE flowEndSeconds >= ? AND sourcePodNamespace = ?
+UNION ALL
+SELECT destinationPodName AS podName FROM flows WHERE flowEndSeconds >= ? AND destinationPodNamespace = ?)`
+	rows, err := connect.Query(query, start, namespace, start, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var pod string
+		if err := rows.Scan(&pod); err != nil {
+			return nil, err
+		}
+		seen[pod] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		if !seen[pod] {
+			coverage.unseenPods = append(coverage.unseenPods, pod)
+		}
+	}
+	sort.Strings(coverage.unseenPods)
+	return coverage, nil
+}
+
+// filterNamespacePolicies returns the recommended policies which apply to the
+// namespace, and the ClusterGroups they refer to. The recommendation job also
+// recommends policies for the peers of the namespace and for the namespaces of
+// the allow list, which are left out.
+func filterNamespacePolicies(yamls string, namespace string) ([]string, error) {
+	docs, policies, err := decodeRecommendedPolicies(yamls)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]bool, len(docs))
+	groups := make(map[string]bool)
+	for i, policy := range policies {
+		if policy.Kind == "ClusterGroup" {
+			continue
+		}
+		if policy.Metadata.Namespace == namespace {
+			kept[i] = true
+		}
+		for _, peer := range policy.Spec.AppliedTo {
+			if policy.Metadata.Namespace == "" && selectorNamespace(peer.NamespaceSelector) == namespace {
+				kept[i] = true
+			}
+		}
+		if !kept[i] {
+			continue
+		}
+		for _, rule := range policy.Spec.Egress {
+			for _, peer := range rule.To {
+				if peer.Group != "" {
+					groups[peer.Group] = true
+				}
+			}
+		}
+	}
+	var result []string
+	for i, policy := range policies {
+		if kept[i] || (policy.Kind == "ClusterGroup" && groups[policy.Metadata.Name]) {
+			result = append(result, docs[i])
+		}
+	}
+	return result, nil
+}
+
+// simulateImpact returns, for each direction, the number of flows since start
+// of the Pods selected by default deny rules, and the number of these flows
+// which no allow rule matches, i.e. which would be rejected.
+func simulateImpact(connect *sql.DB, rules []recommendedRule, start time.Time) ([]impactResult, error) {
+	timeFilter := flowFilter{condition: "flowEndSeconds >= ?", args: []interface{}{start}}
+	var results []impactResult
+	for _, direction := range []string{"ingress", "egress"} {
+		prefix := "source"
+		if direction == "ingress" {
+			prefix = "destination"
+		}
+		var denyScopes, allowed []flowFilter
+		for _, rule := range rules {
+			if rule.direction != direction {
+				continue
+			}
+			if rule.isDeny() {
+				denyScopes = append(denyScopes, appliedToFilter(rule.policy, prefix))
+			} else if rule.err == nil {
+				allowed = append(allowed, rule.filter)
+			}
+		}
+		result := impactResult{direction: direction}
+		if len(denyScopes) == 0 {
+			results = append(results, result)
+			continue
+		}
+		scope := andFilters(timeFilter, orFilters(denyScopes...))
+		if err := connect.QueryRow("SELECT count() FROM flows WHERE "+scope.condition, scope.args...).Scan(&result.flows); err != nil {
+			return nil, err
+		}
+		rejected := scope
+		if len(allowed) > 0 {
+			allowedFilter := orFilters(allowed...)
+			rejected = andFilters(scope, flowFilter{condition: "NOT (" + allowedFilter.condition + ")", args: allowedFilter.args})
+		}
+		if err := connect.QueryRow("SELECT count() FROM flows WHERE "+rejected.condition, rejected.args...).Scan(&result.rejected); err != nil {
+			return nil, err
+		}
+		if result.rejected > 0 {
+			samples, err := getSampleFlows(connect, rejected, impactSampleFlows)
+			if err != nil {
+				return nil, err
+			}
+			result.samples = samples
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func printImpact(out io.Writer, impact []impactResult) {
+	for _, result := range impact {
+		if result.flows == 0 {
+			fmt.Fprintf(out, "%s: no flows of the Pods selected by default deny rules\n", result.direction)
+			continue
+		}
+		fmt.Fprintf(out, "%s: %d out of %d flows of the Pods selected by default deny rules would be rejected\n", result.direction, result.rejected, result.flows)
+		for _, sample := range result.samples {
+			fmt.Fprintf(out, "  %s -> %s %d/%s, last seen %s\n", sample.Source, sample.Destination, sample.DestinationPort, sample.Protocol, sample.FlowEnd)
+		}
+	}
+}
+
+// auditModePolicy returns the recommended policy, with its default deny rules
+// turned into Allow rules with logging enabled.
+func auditModePolicy(doc string) (map[string]interface{}, error) {
+	policy, err := decodePolicy(doc)
+	if err != nil {
+		return nil, err
+	}
+	metadata, _ := policy["metadata"].(map[string]interface{})
+	if metadata == nil {
+		return nil, fmt.Errorf("recommended policy has no metadata")
+	}
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if labels == nil {
+		labels = make(map[string]interface{})
+	}
+	labels[auditLabel] = "true"
+	metadata["labels"] = labels
+	spec, _ := policy["spec"].(map[string]interface{})
+	for _, direction := range []string{"ingress", "egress"} {
+		rules, _ := spec[direction].([]interface{})
+		for _, r := range rules {
+			rule, _ := r.(map[string]interface{})
+			if rule == nil {
+				continue
+			}
+			if action, _ := rule["action"].(string); action == "Reject" || action == "Drop" {
+				rule["action"] = "Allow"
+				rule["enableLogging"] = true
+			}
+		}
+	}
+	return policy, nil
+}
+
+// applyPolicy creates the Antrea-native policy, ClusterGroup or Kubernetes
+// NetworkPolicy. If update is true, an existing resource with the same name
+// is replaced, and false is returned, instead of failing.
+func applyPolicy(clientset kubernetes.Interface, policy map[string]interface{}, update bool) (bool, error) {
+	apiVersion, _ := policy["apiVersion"].(string)
+	kind, _ := policy["kind"].(string)
+	metadata, _ := policy["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	resource, err := policyResource(apiVersion, kind)
+	if err != nil {
+		return false, err
+	}
+	newRequest := func(verb string) *rest.Request {
+		request := clientset.CoreV1().RESTClient().Verb(verb).
+			AbsPath("/apis/" + apiVersion)
+		if namespace != "" {
+			request = request.Namespace(namespace)
+		}
+		return request.Resource(resource)
+	}
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return false, err
+	}
+	err = newRequest("POST").Body(body).Do(context.TODO()).Error()
+	if err == nil {
+		return true, nil
+	}
+	if !update || !errors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("error when creating %s %s: %v", kind, name, err)
+	}
+	data, err := newRequest("GET").Name(name).Do(context.TODO()).Raw()
+	if err != nil {
+		return false, fmt.Errorf("error when getting %s %s: %v", kind, name, err)
+	}
+	var existing struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return false, fmt.Errorf("error when decoding %s %s: %v", kind, name, err)
+	}
+	metadata["resourceVersion"] = existing.Metadata.ResourceVersion
+	if body, err = json.Marshal(policy); err != nil {
+		return false, err
+	}
+	if err := newRequest("PUT").Name(name).Body(body).Do(context.TODO()).Error(); err != nil {
+		return false, fmt.Errorf("error when updating %s %s: %v", kind, name, err)
+	}
+	return false, nil
+}
+
+func init() {
+	onboardCmd.AddCommand(onboardNamespaceCmd)
+	onboardNamespaceCmd.Flags().String(
+		"since",
+		"24h",
+		"Only consider the flows recorded in this period, e.g. 12h or 7d.",
+	)
+	onboardNamespaceCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file path where you want to save the recommended policies, to enforce them after the audit.",
+	)
+}
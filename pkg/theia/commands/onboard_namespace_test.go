@@ -0,0 +1,226 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOtherNamespacePolicies = `apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-allow-acnp-kube-system-rpeal
+spec:
+  appliedTo:
+  - namespaceSelector:
+      matchLabels:
+        kubernetes.io/metadata.name: kube-system
+  egress:
+  - action: Allow
+    to:
+    - podSelector: {}
+  priority: 5
+  tier: Platform
+---
+apiVersion: crd.antrea.io/v1alpha2
+kind: ClusterGroup
+metadata:
+  name: cg-antrea-e2e-perftestsvc
+spec:
+  serviceReference:
+    name: perftestsvc
+    namespace: antrea-e2e
+---
+apiVersion: crd.antrea.io/v1alpha2
+kind: ClusterGroup
+metadata:
+  name: cg-other-svc
+spec:
+  serviceReference:
+    name: svc
+    namespace: other
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-svc-allow-acnp-l8c5z
+spec:
+  appliedTo:
+  - namespaceSelector:
+      matchLabels:
+        kubernetes.io/metadata.name: antrea-test
+    podSelector:
+      matchLabels:
+        podname: perftest-a
+  egress:
+  - action: Allow
+    ports:
+    - port: 5201
+      protocol: TCP
+    to:
+    - group: cg-antrea-e2e-perftestsvc
+  priority: 5
+  tier: Application
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-x1d0q
+  namespace: other
+spec:
+  appliedTo:
+  - podSelector:
+      matchLabels:
+        app: other
+  ingress:
+  - action: Allow
+    from:
+    - namespaceSelector:
+        matchLabels:
+          kubernetes.io/metadata.name: antrea-test
+      podSelector:
+        matchLabels:
+          podname: perftest-a
+`
+
+func TestFilterNamespacePolicies(t *testing.T) {
+	docs, err := filterNamespacePolicies(testRecommendedPolicies+"---\n"+testOtherNamespacePolicies, "antrea-test")
+	require.NoError(t, err)
+	var names []string
+	for _, doc := range docs {
+		policies, err := parseRecommendedPolicies(doc)
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		names = append(names, policies[0].Metadata.Name)
+	}
+	assert.Equal(t, []string{
+		"recommend-allow-anp-ab7fd",
+		"recommend-reject-acnp-9juz4",
+		"cg-antrea-e2e-perftestsvc",
+		"recommend-svc-allow-acnp-l8c5z",
+	}, names)
+}
+
+func TestAuditModePolicy(t *testing.T) {
+	docs, err := splitRecommendedPolicies(testRecommendedPolicies)
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	policy, err := auditModePolicy(docs[1])
+	require.NoError(t, err)
+	metadata := policy["metadata"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{auditLabel: "true"}, metadata["labels"])
+	rule := policy["spec"].(map[string]interface{})["egress"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Allow", rule["action"])
+	assert.Equal(t, true, rule["enableLogging"])
+
+	// allow rules are left unchanged
+	policy, err = auditModePolicy(docs[0])
+	require.NoError(t, err)
+	for _, r := range policy["spec"].(map[string]interface{})["egress"].([]interface{}) {
+		rule := r.(map[string]interface{})
+		assert.Equal(t, "Allow", rule["action"])
+		assert.NotContains(t, rule, "enableLogging")
+	}
+}
+
+func TestGetFlowCoverage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count() FROM flows WHERE flowEndSeconds >= ? AND (sourcePodNamespace = ? OR destinationPodNamespace = ?)")).
+		WithArgs(start, "antrea-test", "antrea-test").
+		WillReturnRows(sqlmock.NewRows([]string{"count()"}).AddRow(uint64(42)))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT podName FROM")).
+		WithArgs(start, "antrea-test", start, "antrea-test").
+		WillReturnRows(sqlmock.NewRows([]string{"podName"}).AddRow("perftest-a").AddRow("perftest-b").AddRow("deleted-pod"))
+
+	coverage, err := getFlowCoverage(db, "antrea-test", start, []string{"perftest-c", "perftest-b", "perftest-a", "idle"})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, &flowCoverage{flows: 42, pods: 4, unseenPods: []string{"idle", "perftest-c"}}, coverage)
+}
+
+func TestSimulateImpact(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	rules, err := listRecommendedRules(testRecommendedPolicies)
+	require.NoError(t, err)
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	countQuery := regexp.QuoteMeta("SELECT count() FROM flows WHERE ")
+	// egress flows of perftest-a, then the ones which no allow rule matches
+	mock.ExpectQuery(countQuery + ".*= \\?\\)\\)$").WillReturnRows(sqlmock.NewRows([]string{"count()"}).AddRow(uint64(10)))
+	mock.ExpectQuery(countQuery + ".* AND \\(NOT \\(.*\\)\\)$").WillReturnRows(sqlmock.NewRows([]string{"count()"}).AddRow(uint64(1)))
+	mock.ExpectQuery("SELECT sourcePodNamespace, .* LIMIT \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"sourcePodNamespace", "sourcePodName", "sourceIP", "destinationPodNamespace", "destinationPodName",
+			"destinationIP", "destinationServicePortName", "destinationTransportPort", "protocolIdentifier", "flowEndSeconds"}).
+			AddRow("antrea-test", "perftest-a", "10.10.0.4", "", "", "8.8.8.8", "", uint16(53), uint8(17), start))
+
+	impact, err := simulateImpact(db, rules, start)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []impactResult{
+		{direction: "ingress"},
+		{direction: "egress", flows: 10, rejected: 1, samples: []sampleFlow{{
+			Source:          "antrea-test/perftest-a",
+			Destination:     "8.8.8.8",
+			DestinationPort: 53,
+			Protocol:        "UDP",
+			FlowEnd:         "2022-08-01 12:00:00",
+		}}},
+	}, impact)
+
+	var out bytes.Buffer
+	printImpact(&out, impact)
+	assert.Equal(t, `ingress: no flows of the Pods selected by default deny rules
+egress: 1 out of 10 flows of the Pods selected by default deny rules would be rejected
+  antrea-test/perftest-a -> 8.8.8.8 53/UDP, last seen 2022-08-01 12:00:00
+`, out.String())
+}
+
+func TestOnboardWizardConfirm(t *testing.T) {
+	testCases := []struct {
+		input     string
+		assumeYes bool
+		expected  bool
+	}{
+		{input: "y\n", expected: true},
+		{input: "Yes\n", expected: true},
+		{input: "\n", expected: false},
+		{input: "no\n", expected: false},
+		{input: "", expected: false},
+		{input: "", assumeYes: true, expected: true},
+	}
+	for _, tt := range testCases {
+		var out bytes.Buffer
+		w := &onboardWizard{in: bufio.NewReader(strings.NewReader(tt.input)), out: &out, assumeYes: tt.assumeYes}
+		ok, err := w.confirm("Proceed?")
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, ok, "input %q", tt.input)
+	}
+}
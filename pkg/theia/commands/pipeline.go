@@ -0,0 +1,477 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+	"antrea.io/theia/pkg/client/clientset/versioned"
+	"antrea.io/theia/pkg/controller/networkpolicyrecommendation"
+	"antrea.io/theia/pkg/theia/commands/config"
+	s3client "antrea.io/theia/pkg/util/s3"
+)
+
+const (
+	pipelineStageRecommendation    = "recommendation"
+	pipelineStageReport            = "report"
+	pipelineStageAnomalyDetection  = "anomaly-detection"
+	pipelineDefinitionMaxFileBytes = 1 << 20
+)
+
+// pipelineDefinition is a pipeline of jobs, as a DAG of stages. The stages
+// share the artifacts location, so that the artifacts of a stage can be found
+// by the stages depending on it.
+type pipelineDefinition struct {
+	Name      string            `json:"name"`
+	Artifacts pipelineArtifacts `json:"artifacts,omitempty"`
+	Stages    []pipelineStage   `json:"stages"`
+}
+
+type pipelineArtifacts struct {
+	URI      string `json:"uri,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+type pipelineStage struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Spec is the spec of the NetworkPolicyRecommendation of recommendation
+	// stages.
+	Spec crdv1alpha1.NetworkPolicyRecommendationSpec `json:"spec,omitempty"`
+}
+
+// pipelineStageResult is the result of a stage, as written in the reports.
+type pipelineStageResult struct {
+	Stage     string `json:"stage"`
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	State     string `json:"state"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	ErrorMsg  string `json:"errorMsg,omitempty"`
+	Artifacts string `json:"artifacts,omitempty"`
+}
+
+// pipelineCmd represents the pipeline command group
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Commands of Theia job pipelines",
+	Long: `Command group of Theia job pipelines.
+Must specify a subcommand like run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand like run")
+	},
+}
+
+// pipelineRunCmd represents the pipeline run command
+var pipelineRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run a pipeline of jobs",
+	Long: `Run a pipeline of jobs defined as a DAG of stages in a YAML file.
+A NetworkPolicyRecommendation is created for each recommendation stage, and
+theia-manager starts its job once all the stages it depends on completed. A
+stage fails without running when one of the stages it depends on fails.
+
+Report stages run in the CLI once the stages they depend on are done, so
+pipelines with report stages must be run with --wait. A report is a JSON file
+in the report directory with the state of the stages the report stage depends
+on and the location of their artifacts.`,
+	Example: `
+Run the pipeline defined in pipeline.yaml
+$ theia pipeline run -f pipeline.yaml
+Run the pipeline, wait for its stages to complete and write the reports to the reports directory
+$ theia pipeline run -f pipeline.yaml --wait --report-dir reports
+
+The pipeline definition is like:
+name: weekly
+artifacts:
+  uri: s3://my-bucket/theia
+stages:
+- name: frontend
+  type: recommendation
+  spec:
+    nsAllowList: ["kube-system"]
+- name: backend
+  type: recommendation
+  dependsOn: [frontend]
+  spec:
+    type: subsequent
+- name: summary
+  type: report
+  dependsOn: [frontend, backend]
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filePath, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return err
+		}
+		if filePath == "" {
+			return fmt.Errorf("the pipeline definition file should be provided with --file")
+		}
+		waitFlag, err := cmd.Flags().GetBool("wait")
+		if err != nil {
+			return err
+		}
+		reportDir, err := cmd.Flags().GetString("report-dir")
+		if err != nil {
+			return err
+		}
+		data, err := readPipelineDefinition(filePath)
+		if err != nil {
+			return err
+		}
+		pipeline, err := parsePipeline(data)
+		if err != nil {
+			return err
+		}
+		if pipeline.hasReports() && !waitFlag {
+			return fmt.Errorf("pipelines with report stages must be run with --wait")
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		crdClient, err := CreateCRDClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create Theia CRD client: %v", err)
+		}
+		npRecos := pipeline.networkPolicyRecommendations()
+		for _, npReco := range npRecos {
+			if _, err := crdClient.CrdV1alpha1().NetworkPolicyRecommendations(config.FlowVisibilityNS).Create(context.TODO(), npReco, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("error when creating the NetworkPolicyRecommendation of stage %s: %v", npReco.Labels[networkpolicyrecommendation.PipelineStageLabel], err)
+			}
+		}
+		fmt.Fprintf(infoOut(cmd), "Successfully created pipeline %s with %d stages\n", pipeline.Name, len(pipeline.Stages))
+		if !waitFlag {
+			return nil
+		}
+		results, err := waitForPipeline(crdClient, pipeline)
+		if err != nil {
+			return err
+		}
+		if err := pipeline.writeReports(results, reportDir); err != nil {
+			return err
+		}
+		table := [][]string{{"Stage", "Name", "State", "Error"}}
+		for _, stage := range pipeline.Stages {
+			if result, ok := results[stage.Name]; ok {
+				table = append(table, []string{result.Stage, result.Name, result.State, result.ErrorMsg})
+			}
+		}
+		TableOutput(table)
+		for _, result := range results {
+			if result.State == "FAILED" || result.State == "CANCELLED" {
+				return fmt.Errorf("pipeline %s failed", pipeline.Name)
+			}
+		}
+		return nil
+	},
+}
+
+func readPipelineDefinition(filePath string) ([]byte, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading the pipeline definition: %v", err)
+	}
+	if info.Size() > pipelineDefinitionMaxFileBytes {
+		return nil, fmt.Errorf("the pipeline definition should not be larger than %d bytes", pipelineDefinitionMaxFileBytes)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading the pipeline definition: %v", err)
+	}
+	return data, nil
+}
+
+// parsePipeline parses and validates a pipeline definition. The stages of the
+// returned pipeline are sorted so that each stage comes after the stages it
+// depends on.
+func parsePipeline(data []byte) (*pipelineDefinition, error) {
+	var pipeline pipelineDefinition
+	if err := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), len(data)).Decode(&pipeline); err != nil {
+		return nil, fmt.Errorf("error when parsing the pipeline definition: %v", err)
+	}
+	if pipeline.Name == "" {
+		return nil, fmt.Errorf("the pipeline should have a name")
+	}
+	if len(pipeline.Stages) == 0 {
+		return nil, fmt.Errorf("the pipeline should have at least one stage")
+	}
+	if pipeline.Artifacts.URI != "" {
+		if _, err := s3client.ParseArtifactsURI(pipeline.Artifacts.URI, pipeline.Artifacts.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+	stages := make(map[string]*pipelineStage, len(pipeline.Stages))
+	for i := range pipeline.Stages {
+		stage := &pipeline.Stages[i]
+		if stage.Name == "" {
+			return nil, fmt.Errorf("stage %d should have a name", i)
+		}
+		if _, ok := stages[stage.Name]; ok {
+			return nil, fmt.Errorf("stage %s is defined more than once", stage.Name)
+		}
+		if errs := validation.IsDNS1123Subdomain(pipeline.stageResourceName(stage.Name)); len(errs) > 0 {
+			return nil, fmt.Errorf("pipeline and stage names should form a valid resource name, %s is invalid: %s", pipeline.stageResourceName(stage.Name), strings.Join(errs, ", "))
+		}
+		switch stage.Type {
+		case pipelineStageRecommendation, pipelineStageReport:
+		case pipelineStageAnomalyDetection:
+			return nil, fmt.Errorf("stage %s: anomaly detection stages are not supported by this version of Theia", stage.Name)
+		default:
+			return nil, fmt.Errorf("stage %s: type should be %s or %s", stage.Name, pipelineStageRecommendation, pipelineStageReport)
+		}
+		stages[stage.Name] = stage
+	}
+	for _, stage := range pipeline.Stages {
+		for _, dependency := range stage.DependsOn {
+			dependencyStage, ok := stages[dependency]
+			if !ok {
+				return nil, fmt.Errorf("stage %s depends on unknown stage %s", stage.Name, dependency)
+			}
+			if dependencyStage.Type == pipelineStageReport {
+				return nil, fmt.Errorf("stage %s cannot depend on report stage %s", stage.Name, dependency)
+			}
+		}
+	}
+	sorted, err := sortPipelineStages(pipeline.Stages)
+	if err != nil {
+		return nil, err
+	}
+	pipeline.Stages = sorted
+	return &pipeline, nil
+}
+
+// sortPipelineStages sorts the stages topologically, keeping the order of the
+// definition between independent stages.
+func sortPipelineStages(stages []pipelineStage) ([]pipelineStage, error) {
+	sorted := make([]pipelineStage, 0, len(stages))
+	done := make(map[string]bool, len(stages))
+	for len(sorted) < len(stages) {
+		progress := false
+		for _, stage := range stages {
+			if done[stage.Name] {
+				continue
+			}
+			ready := true
+			for _, dependency := range stage.DependsOn {
+				if !done[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, stage)
+				done[stage.Name] = true
+				progress = true
+			}
+		}
+		if !progress {
+			var remaining []string
+			for _, stage := range stages {
+				if !done[stage.Name] {
+					remaining = append(remaining, stage.Name)
+				}
+			}
+			return nil, fmt.Errorf("the dependencies of stages %s form a cycle", strings.Join(remaining, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+func (p *pipelineDefinition) stageResourceName(stage string) string {
+	return p.Name + "-" + stage
+}
+
+func (p *pipelineDefinition) hasReports() bool {
+	for _, stage := range p.Stages {
+		if stage.Type == pipelineStageReport {
+			return true
+		}
+	}
+	return false
+}
+
+// networkPolicyRecommendations returns the NetworkPolicyRecommendations of the
+// recommendation stages. The stages inherit the artifacts location of the
+// pipeline unless they have their own.
+func (p *pipelineDefinition) networkPolicyRecommendations() []*crdv1alpha1.NetworkPolicyRecommendation {
+	var npRecos []*crdv1alpha1.NetworkPolicyRecommendation
+	for _, stage := range p.Stages {
+		if stage.Type != pipelineStageRecommendation {
+			continue
+		}
+		spec := stage.Spec
+		if spec.ArtifactsURI == "" {
+			spec.ArtifactsURI = p.Artifacts.URI
+			spec.ArtifactsEndpoint = p.Artifacts.Endpoint
+			spec.ArtifactsSecret = p.Artifacts.Secret
+		}
+		npReco := &crdv1alpha1.NetworkPolicyRecommendation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      p.stageResourceName(stage.Name),
+				Namespace: config.FlowVisibilityNS,
+				Labels: map[string]string{
+					networkpolicyrecommendation.PipelineLabel:      p.Name,
+					networkpolicyrecommendation.PipelineStageLabel: stage.Name,
+				},
+			},
+			Spec: spec,
+		}
+		if len(stage.DependsOn) > 0 {
+			dependencies := make([]string, 0, len(stage.DependsOn))
+			for _, dependency := range stage.DependsOn {
+				dependencies = append(dependencies, p.stageResourceName(dependency))
+			}
+			npReco.Annotations = map[string]string{
+				networkpolicyrecommendation.DependsOnAnnotation: strings.Join(dependencies, ","),
+			}
+		}
+		npRecos = append(npRecos, npReco)
+	}
+	return npRecos
+}
+
+// waitForPipeline waits for all the recommendation stages to be COMPLETED or
+// FAILED, and returns their results by stage name.
+func waitForPipeline(crdClient versioned.Interface, pipeline *pipelineDefinition) (map[string]*pipelineStageResult, error) {
+	results := make(map[string]*pipelineStageResult)
+	err := wait.Poll(config.StatusCheckPollInterval, config.StatusCheckPollTimeout, func() (bool, error) {
+		done := true
+		for _, stage := range pipeline.Stages {
+			if stage.Type != pipelineStageRecommendation {
+				continue
+			}
+			npReco, err := crdClient.CrdV1alpha1().NetworkPolicyRecommendations(config.FlowVisibilityNS).Get(context.TODO(), pipeline.stageResourceName(stage.Name), metav1.GetOptions{})
+			if err != nil {
+				return false, fmt.Errorf("error when getting the NetworkPolicyRecommendation of stage %s: %v", stage.Name, err)
+			}
+			results[stage.Name] = pipeline.stageResult(stage.Name, npReco)
+			if npReco.Status.State != "COMPLETED" && npReco.Status.State != "FAILED" && npReco.Status.State != "CANCELLED" {
+				done = false
+			}
+		}
+		return done, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return nil, fmt.Errorf("pipeline %s is still running after %v, please check the state of its stages later", pipeline.Name, config.StatusCheckPollTimeout)
+	}
+	return results, err
+}
+
+func (p *pipelineDefinition) stageResult(stage string, npReco *crdv1alpha1.NetworkPolicyRecommendation) *pipelineStageResult {
+	result := &pipelineStageResult{
+		Stage:     stage,
+		Name:      npReco.Name,
+		ID:        string(npReco.UID),
+		State:     npReco.Status.State,
+		ErrorCode: npReco.Status.ErrorCode,
+		ErrorMsg:  npReco.Status.ErrorMsg,
+	}
+	if npReco.Spec.ArtifactsURI != "" && result.State == "COMPLETED" {
+		// the URI was validated when the job was started
+		if location, err := s3client.ParseArtifactsURI(npReco.Spec.ArtifactsURI, npReco.Spec.ArtifactsEndpoint); err == nil {
+			scheme := strings.SplitN(npReco.Spec.ArtifactsURI, "://", 2)[0]
+			result.Artifacts = fmt.Sprintf("%s://%s/%s", scheme, location.Bucket, location.JobPrefix(result.ID))
+		}
+	}
+	return result
+}
+
+// writeReports runs the report stages: the report of a stage is written to
+// <reportDir>/<stage>.json, with the results of the stages it depends on. The
+// report stages succeed only if all these stages completed.
+func (p *pipelineDefinition) writeReports(results map[string]*pipelineStageResult, reportDir string) error {
+	for _, stage := range p.Stages {
+		if stage.Type != pipelineStageReport {
+			continue
+		}
+		report := struct {
+			Pipeline  string                 `json:"pipeline"`
+			Stage     string                 `json:"stage"`
+			Generated string                 `json:"generated"`
+			Stages    []*pipelineStageResult `json:"stages"`
+		}{
+			Pipeline:  p.Name,
+			Stage:     stage.Name,
+			Generated: FormatTimestamp(time.Now()),
+		}
+		result := &pipelineStageResult{
+			Stage: stage.Name,
+			Name:  filepath.Join(reportDir, stage.Name+".json"),
+			State: "COMPLETED",
+		}
+		dependencies := append([]string(nil), stage.DependsOn...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			report.Stages = append(report.Stages, results[dependency])
+			if results[dependency].State != "COMPLETED" {
+				result.State = "FAILED"
+				result.ErrorCode = networkpolicyrecommendation.DependencyFailedErrorCode
+				result.ErrorMsg = fmt.Sprintf("dependency %s failed", dependency)
+			}
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(reportDir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(result.Name, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("error when writing the report of stage %s: %v", stage.Name, err)
+		}
+		results[stage.Name] = result
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+	pipelineCmd.AddCommand(pipelineRunCmd)
+	pipelineRunCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The YAML file with the pipeline definition.",
+	)
+	pipelineRunCmd.Flags().Bool(
+		"wait",
+		false,
+		"Enable this option will hold and wait until all the stages of the pipeline are done.",
+	)
+	pipelineRunCmd.Flags().String(
+		"report-dir",
+		".",
+		"The directory to which the reports of the report stages are written.",
+	)
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	crdv1alpha1 "antrea.io/theia/pkg/apis/crd/v1alpha1"
+)
+
+const testPipeline = `
+name: weekly
+artifacts:
+  uri: s3://my-bucket/theia
+stages:
+- name: summary
+  type: report
+  dependsOn: [frontend, backend]
+- name: backend
+  type: recommendation
+  dependsOn: [frontend]
+  spec:
+    type: subsequent
+    artifactsURI: gs://other-bucket
+- name: frontend
+  type: recommendation
+  spec:
+    nsAllowList: ["kube-system"]
+`
+
+func TestParsePipeline(t *testing.T) {
+	pipeline, err := parsePipeline([]byte(testPipeline))
+	require.NoError(t, err)
+	var stages []string
+	for _, stage := range pipeline.Stages {
+		stages = append(stages, stage.Name)
+	}
+	assert.Equal(t, []string{"frontend", "backend", "summary"}, stages)
+	assert.True(t, pipeline.hasReports())
+
+	npRecos := pipeline.networkPolicyRecommendations()
+	require.Len(t, npRecos, 2)
+	assert.Equal(t, "weekly-frontend", npRecos[0].Name)
+	assert.Equal(t, map[string]string{
+		"crd.theia.antrea.io/pipeline":       "weekly",
+		"crd.theia.antrea.io/pipeline-stage": "frontend",
+	}, npRecos[0].Labels)
+	assert.Empty(t, npRecos[0].Annotations)
+	assert.Equal(t, crdv1alpha1.NetworkPolicyRecommendationSpec{
+		NSAllowList:  []string{"kube-system"},
+		ArtifactsURI: "s3://my-bucket/theia",
+	}, npRecos[0].Spec)
+	assert.Equal(t, "weekly-backend", npRecos[1].Name)
+	assert.Equal(t, map[string]string{"crd.theia.antrea.io/depends-on": "weekly-frontend"}, npRecos[1].Annotations)
+	assert.Equal(t, "gs://other-bucket", npRecos[1].Spec.ArtifactsURI)
+}
+
+func TestParsePipelineErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		definition    string
+		expectedError string
+	}{
+		{
+			name:          "no name",
+			definition:    "stages: [{name: a, type: recommendation}]",
+			expectedError: "the pipeline should have a name",
+		},
+		{
+			name:          "no stages",
+			definition:    "name: p",
+			expectedError: "the pipeline should have at least one stage",
+		},
+		{
+			name:          "duplicate stage",
+			definition:    "name: p\nstages: [{name: a, type: recommendation}, {name: a, type: report}]",
+			expectedError: "stage a is defined more than once",
+		},
+		{
+			name:          "invalid name",
+			definition:    "name: p\nstages: [{name: A_1, type: recommendation}]",
+			expectedError: "pipeline and stage names should form a valid resource name, p-A_1 is invalid",
+		},
+		{
+			name:          "anomaly detection",
+			definition:    "name: p\nstages: [{name: a, type: anomaly-detection}]",
+			expectedError: "stage a: anomaly detection stages are not supported by this version of Theia",
+		},
+		{
+			name:          "unknown type",
+			definition:    "name: p\nstages: [{name: a, type: spark}]",
+			expectedError: "stage a: type should be recommendation or report",
+		},
+		{
+			name:          "unknown dependency",
+			definition:    "name: p\nstages: [{name: a, type: recommendation, dependsOn: [b]}]",
+			expectedError: "stage a depends on unknown stage b",
+		},
+		{
+			name:          "dependency on report",
+			definition:    "name: p\nstages: [{name: a, type: report}, {name: b, type: recommendation, dependsOn: [a]}]",
+			expectedError: "stage b cannot depend on report stage a",
+		},
+		{
+			name:          "cycle",
+			definition:    "name: p\nstages: [{name: a, type: recommendation}, {name: b, type: recommendation, dependsOn: [c]}, {name: c, type: recommendation, dependsOn: [b]}]",
+			expectedError: "the dependencies of stages b, c form a cycle",
+		},
+		{
+			name:          "invalid artifacts URI",
+			definition:    "name: p\nartifacts: {uri: http://bucket}\nstages: [{name: a, type: recommendation}]",
+			expectedError: "artifacts URI should be s3://<bucket>/<prefix> or gs://<bucket>/<prefix>",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePipeline([]byte(tt.definition))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestWritePipelineReports(t *testing.T) {
+	pipeline, err := parsePipeline([]byte(testPipeline))
+	require.NoError(t, err)
+	results := map[string]*pipelineStageResult{}
+	for _, npReco := range []*crdv1alpha1.NetworkPolicyRecommendation{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "weekly-frontend", UID: types.UID("e998433e-accb-4888-9fc8-06563f073e86")},
+			Spec:       crdv1alpha1.NetworkPolicyRecommendationSpec{ArtifactsURI: "s3://my-bucket/theia"},
+			Status:     crdv1alpha1.NetworkPolicyRecommendationStatus{State: "COMPLETED"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "weekly-backend", UID: types.UID("f998433e-accb-4888-9fc8-06563f073e86")},
+			Spec:       crdv1alpha1.NetworkPolicyRecommendationSpec{ArtifactsURI: "gs://other-bucket"},
+			Status:     crdv1alpha1.NetworkPolicyRecommendationStatus{State: "FAILED", ErrorCode: "JobFailed", ErrorMsg: "driver OOMKilled"},
+		},
+	} {
+		stage := npReco.Name[len("weekly-"):]
+		results[stage] = pipeline.stageResult(stage, npReco)
+	}
+	assert.Equal(t, "s3://my-bucket/theia/e998433e-accb-4888-9fc8-06563f073e86/", results["frontend"].Artifacts)
+	assert.Empty(t, results["backend"].Artifacts)
+
+	reportDir := filepath.Join(t.TempDir(), "reports")
+	require.NoError(t, pipeline.writeReports(results, reportDir))
+	assert.Equal(t, "FAILED", results["summary"].State)
+	assert.Equal(t, "dependency backend failed", results["summary"].ErrorMsg)
+
+	data, err := os.ReadFile(filepath.Join(reportDir, "summary.json"))
+	require.NoError(t, err)
+	var report struct {
+		Pipeline string                `json:"pipeline"`
+		Stages   []pipelineStageResult `json:"stages"`
+	}
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, "weekly", report.Pipeline)
+	require.Len(t, report.Stages, 2)
+	assert.Equal(t, "backend", report.Stages[0].Stage)
+	assert.Equal(t, "driver OOMKilled", report.Stages[0].ErrorMsg)
+	assert.Equal(t, "frontend", report.Stages[1].Stage)
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// appliedByLabel is set on the recommended policies applied by theia.
+	// Only these policies are pruned.
+	appliedByLabel = "app.kubernetes.io/managed-by"
+	appliedBy      = "theia"
+	// appliedScopeLabel is set to the scope given when applying the
+	// policies. Pruning only considers the policies applied with the same
+	// scope, or without scope.
+	appliedScopeLabel = "theia.antrea.io/apply-scope"
+	// appliedNamespacesAnnotation records the Namespaces of the recommended
+	// policies applied together, so that the cluster-scoped policies of a
+	// job are not pruned when applying the policies of a job targeting
+	// other Namespaces.
+	appliedNamespacesAnnotation = "theia.antrea.io/applied-namespaces"
+
+	clientDryRun = "client"
+	serverDryRun = "server"
+)
+
+// prunedPolicyResources are the resources of the recommended policies
+// considered for pruning.
+var prunedPolicyResources = []schema.GroupVersionResource{
+	{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+	{Group: "crd.antrea.io", Version: "v1alpha1", Resource: "networkpolicies"},
+	{Group: "crd.antrea.io", Version: "v1alpha1", Resource: "clusternetworkpolicies"},
+	{Group: "crd.antrea.io", Version: "v1alpha2", Resource: "clustergroups"},
+}
+
+// policyApplier applies the recommended policies with server-side apply, and
+// prunes the policies applied previously which are no longer recommended with
+// the dynamic client. With the client dry run, the cluster is only read.
+type policyApplier struct {
+	restClient     rest.Interface
+	client         dynamic.Interface
+	out            io.Writer
+	recoID         string
+	scope          string
+	dryRun         string
+	forceConflicts bool
+}
+
+func (a *policyApplier) dryRunSuffix() string {
+	switch a.dryRun {
+	case clientDryRun:
+		return " (dry run)"
+	case serverDryRun:
+		return " (server dry run)"
+	}
+	return ""
+}
+
+func (a *policyApplier) dryRunOption() []string {
+	if a.dryRun == serverDryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+func policyGroupVersionResource(policy map[string]interface{}) (schema.GroupVersionResource, error) {
+	apiVersion, _ := policy["apiVersion"].(string)
+	kind, _ := policy["kind"].(string)
+	resource, err := policyResource(apiVersion, kind)
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+	return gv.WithResource(resource), nil
+}
+
+// policyNamespaces returns the Namespaces of the namespaced policies.
+func policyNamespaces(policies []map[string]interface{}) sets.String {
+	namespaces := sets.NewString()
+	for _, policy := range policies {
+		metadata, _ := policy["metadata"].(map[string]interface{})
+		if namespace, _ := metadata["namespace"].(string); namespace != "" {
+			namespaces.Insert(namespace)
+		}
+	}
+	return namespaces
+}
+
+// apply labels the recommended policies as applied by theia, applies them with
+// server-side apply, and writes the result of each policy to out. With the
+// client dry run, the policies are only listed.
+func (a *policyApplier) apply(policies []map[string]interface{}) error {
+	if a.dryRun == clientDryRun {
+		for _, policy := range policies {
+			fmt.Fprintf(a.out, "%s %s%s\n", policy["kind"], policyObjectName(policy), a.dryRunSuffix())
+		}
+		return nil
+	}
+	return serverSideApplyPolicies(a.out, a.restClient, policies, applyOptions{
+		recoID:         a.recoID,
+		scope:          a.scope,
+		forceConflicts: a.forceConflicts,
+		dryRun:         a.dryRun == serverDryRun,
+	})
+}
+
+// inPruneScope returns true if a policy applied by theia can be pruned when
+// applying recommended policies in the given Namespaces: namespaced policies
+// must be in one of these Namespaces, and cluster-scoped policies must have
+// been applied together with policies in these Namespaces only. This prevents
+// the policies recommended by a job targeting some Namespaces from being
+// pruned when applying the policies of a job targeting other Namespaces.
+func inPruneScope(obj *unstructured.Unstructured, namespaces sets.String) bool {
+	if namespace := obj.GetNamespace(); namespace != "" {
+		return namespaces.Has(namespace)
+	}
+	applied := obj.GetAnnotations()[appliedNamespacesAnnotation]
+	if applied == "" {
+		return true
+	}
+	return namespaces.HasAll(strings.Split(applied, ",")...)
+}
+
+// prune deletes the policies applied by theia with the same scope which are
+// not in the recommended policies, in the scope of the recommended policies
+// (see inPruneScope).
+func (a *policyApplier) prune(policies []map[string]interface{}) error {
+	keep := sets.NewString()
+	for _, policy := range policies {
+		gvr, err := policyGroupVersionResource(policy)
+		if err != nil {
+			return err
+		}
+		keep.Insert(gvr.GroupResource().String() + "/" + policyObjectName(policy))
+	}
+	namespaces := policyNamespaces(policies)
+	selector := appliedByLabel + "=" + appliedBy + ",!" + appliedScopeLabel
+	if a.scope != "" {
+		selector = appliedByLabel + "=" + appliedBy + "," + appliedScopeLabel + "=" + a.scope
+	}
+	for _, gvr := range prunedPolicyResources {
+		list, err := a.client.Resource(gvr).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+		if errors.IsNotFound(err) {
+			// the CRDs of the Antrea-native policies are not installed
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error when listing the applied %s: %v", gvr.Resource, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if keep.Has(gvr.GroupResource().String()+"/"+policyObjectName(obj.Object)) || !inPruneScope(obj, namespaces) {
+				continue
+			}
+			if a.dryRun != clientDryRun {
+				err := a.client.Resource(gvr).Namespace(obj.GetNamespace()).Delete(context.TODO(), obj.GetName(), metav1.DeleteOptions{DryRun: a.dryRunOption()})
+				if err != nil && !errors.IsNotFound(err) {
+					return fmt.Errorf("error when pruning %s %s: %v", obj.GetKind(), policyObjectName(obj.Object), err)
+				}
+			}
+			fmt.Fprintf(a.out, "%s %s pruned%s\n", obj.GetKind(), policyObjectName(obj.Object), a.dryRunSuffix())
+		}
+	}
+	return nil
+}
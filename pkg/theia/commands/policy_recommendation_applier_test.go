@@ -0,0 +1,391 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"antrea.io/theia/pkg/util/sink"
+)
+
+var (
+	anpResource  = schema.GroupVersionResource{Group: "crd.antrea.io", Version: "v1alpha1", Resource: "networkpolicies"}
+	acnpResource = schema.GroupVersionResource{Group: "crd.antrea.io", Version: "v1alpha1", Resource: "clusternetworkpolicies"}
+)
+
+func newTestPolicy(kind, namespace, name string, labels map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "crd.antrea.io/v1alpha1",
+		"kind":       kind,
+		"metadata":   metadata,
+		"spec":       map[string]interface{}{"priority": int64(5)},
+	}}
+}
+
+// fakePolicyServer is a minimal apiserver for the policies, which supports
+// server-side apply, and the get, list and delete requests of the dynamic
+// client. Fields are owned per object rather than per field: applying a
+// policy with a different spec conflicts with the other field managers of
+// the policy, unless forced. The labels and annotations of the other field
+// managers are kept.
+type fakePolicyServer struct {
+	t        *testing.T
+	mutex    sync.Mutex
+	objects  map[string]map[string]interface{}
+	managers map[string]string
+	version  int
+}
+
+// newFakePolicyServer returns the server, and the clients used by the
+// policyApplier. The given policies exist and are managed by kubectl, unless
+// they are labelled as managed by theia.
+func newFakePolicyServer(t *testing.T, policies ...*unstructured.Unstructured) (rest.Interface, dynamic.Interface) {
+	s := &fakePolicyServer{t: t, objects: map[string]map[string]interface{}{}, managers: map[string]string{}}
+	for _, policy := range policies {
+		gvr, err := policyGroupVersionResource(policy.Object)
+		require.NoError(t, err)
+		path := "/apis/" + gvr.GroupVersion().String()
+		if policy.GetNamespace() != "" {
+			path += "/namespaces/" + policy.GetNamespace()
+		}
+		path += "/" + gvr.Resource + "/" + policy.GetName()
+		data, err := json.Marshal(policy.Object)
+		require.NoError(t, err)
+		var object map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &object))
+		s.version++
+		object["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(s.version)
+		s.objects[path] = object
+		s.managers[path] = "kubectl"
+		if policy.GetLabels()[appliedByLabel] == appliedBy {
+			s.managers[path] = fieldManager
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(server.Close)
+	config := &rest.Config{Host: server.URL}
+	clientset, err := kubernetes.NewForConfig(config)
+	require.NoError(t, err)
+	dynamicClient, err := dynamic.NewForConfig(config)
+	require.NoError(t, err)
+	return clientset.CoreV1().RESTClient(), dynamicClient
+}
+
+func (s *fakePolicyServer) writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, details *metav1.StatusDetails) {
+	w.WriteHeader(code)
+	status := metav1.StatusSuccess
+	if code != http.StatusOK {
+		status = metav1.StatusFailure
+	}
+	json.NewEncoder(w).Encode(metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: status, Reason: reason, Code: int32(code), Details: details})
+}
+
+func (s *fakePolicyServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	dryRun := r.URL.Query().Get("dryRun") == metav1.DryRunAll
+	existing := s.objects[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet:
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/apis/"), "/")
+		if len(parts) == 3 {
+			s.list(w, r, parts)
+			return
+		}
+		if existing == nil {
+			s.writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, nil)
+			return
+		}
+		json.NewEncoder(w).Encode(existing)
+	case http.MethodPatch:
+		assert.Equal(s.t, "application/apply-patch+yaml", r.Header.Get("Content-Type"))
+		assert.Equal(s.t, fieldManager, r.URL.Query().Get("fieldManager"))
+		body, _ := io.ReadAll(r.Body)
+		var applied map[string]interface{}
+		require.NoError(s.t, json.Unmarshal(body, &applied))
+		if existing == nil {
+			if !dryRun {
+				s.version++
+				applied["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(s.version)
+				s.objects[r.URL.Path] = applied
+				s.managers[r.URL.Path] = fieldManager
+			}
+			json.NewEncoder(w).Encode(applied)
+			return
+		}
+		if s.managers[r.URL.Path] != fieldManager && !reflect.DeepEqual(existing["spec"], applied["spec"]) && r.URL.Query().Get("force") != "true" {
+			s.writeStatus(w, http.StatusConflict, metav1.StatusReasonConflict, &metav1.StatusDetails{Causes: []metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: `conflict with "kubectl" using crd.antrea.io/v1alpha1`,
+				Field:   ".spec.priority",
+			}}})
+			return
+		}
+		existingMetadata := existing["metadata"].(map[string]interface{})
+		appliedMetadata := applied["metadata"].(map[string]interface{})
+		for _, field := range []string{"labels", "annotations"} {
+			values, _ := existingMetadata[field].(map[string]interface{})
+			merged := map[string]interface{}{}
+			for k, v := range values {
+				merged[k] = v
+			}
+			appliedValues, _ := appliedMetadata[field].(map[string]interface{})
+			for k, v := range appliedValues {
+				merged[k] = v
+			}
+			appliedMetadata[field] = merged
+		}
+		appliedMetadata["resourceVersion"] = existingMetadata["resourceVersion"]
+		if !reflect.DeepEqual(existing, applied) && !dryRun {
+			s.version++
+			appliedMetadata["resourceVersion"] = strconv.Itoa(s.version)
+			s.objects[r.URL.Path] = applied
+			s.managers[r.URL.Path] = fieldManager
+		}
+		json.NewEncoder(w).Encode(applied)
+	case http.MethodDelete:
+		if existing == nil {
+			s.writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, nil)
+			return
+		}
+		// the dynamic client sends the dry run in the DeleteOptions body
+		var options metav1.DeleteOptions
+		if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+			require.NoError(s.t, json.Unmarshal(body, &options))
+		}
+		if !dryRun && len(options.DryRun) == 0 {
+			delete(s.objects, r.URL.Path)
+		}
+		s.writeStatus(w, http.StatusOK, "", nil)
+	}
+}
+
+// list lists the objects of the resource of the request path, of all
+// Namespaces, matching the label selector.
+func (s *fakePolicyServer) list(w http.ResponseWriter, r *http.Request, parts []string) {
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	require.NoError(s.t, err)
+	items := []interface{}{}
+	for path, object := range s.objects {
+		pathParts := strings.Split(strings.TrimPrefix(path, "/apis/"), "/")
+		if pathParts[0] != parts[0] || pathParts[1] != parts[1] || pathParts[len(pathParts)-2] != parts[2] {
+			continue
+		}
+		objectLabels := labels.Set{}
+		values, _ := object["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+		for k, v := range values {
+			objectLabels[k] = v.(string)
+		}
+		if selector.Matches(objectLabels) {
+			items = append(items, object)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"apiVersion": parts[0] + "/" + parts[1],
+		"kind":       "List",
+		"metadata":   map[string]interface{}{},
+		"items":      items,
+	})
+}
+
+func TestPolicyApplier(t *testing.T) {
+	recoID := "e998433e-accb-4888-9fc8-06563f073e86"
+	theiaLabels := map[string]interface{}{appliedByLabel: appliedBy}
+	restClient, client := newFakePolicyServer(t,
+		newTestPolicy("NetworkPolicy", "antrea-test", "recommend-allow-anp-ab7fd", map[string]interface{}{"team": "web"}),
+		newTestPolicy("ClusterNetworkPolicy", "", "recommend-reject-acnp-stale", theiaLabels),
+		newTestPolicy("ClusterNetworkPolicy", "", "manual-acnp", nil),
+	)
+	var out bytes.Buffer
+	applier := &policyApplier{restClient: restClient, client: client, out: &out, recoID: recoID}
+
+	policies, err := decodePolicyBundle([]byte(testRecommendedPolicies))
+	require.NoError(t, err)
+	err = applier.apply(policies)
+	assert.EqualError(t, err, "1 out of 2 recommended policies could not be applied, use force-conflicts to take ownership of the conflicting fields")
+	assert.Equal(t, `NetworkPolicy antrea-test/recommend-allow-anp-ab7fd conflicts with other field managers:
+  .spec.priority: conflict with "kubectl" using crd.antrea.io/v1alpha1
+ClusterNetworkPolicy recommend-reject-acnp-9juz4 created
+`, out.String())
+	acnp, err := client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-9juz4", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{appliedByLabel: appliedBy, sink.RecommendationIDLabel: recoID}, acnp.GetLabels())
+
+	out.Reset()
+	applier.forceConflicts = true
+	policies, err = decodePolicyBundle([]byte(testRecommendedPolicies))
+	require.NoError(t, err)
+	require.NoError(t, applier.apply(policies))
+	require.NoError(t, applier.prune(policies))
+	assert.Equal(t, `NetworkPolicy antrea-test/recommend-allow-anp-ab7fd configured
+ClusterNetworkPolicy recommend-reject-acnp-9juz4 unchanged
+ClusterNetworkPolicy recommend-reject-acnp-stale pruned
+`, out.String())
+	anp, err := client.Resource(anpResource).Namespace("antrea-test").Get(context.TODO(), "recommend-allow-anp-ab7fd", metav1.GetOptions{})
+	require.NoError(t, err)
+	// the labels added by other actors are kept
+	assert.Equal(t, map[string]string{"team": "web", appliedByLabel: appliedBy, sink.RecommendationIDLabel: recoID}, anp.GetLabels())
+	_, err = client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-stale", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = client.Resource(acnpResource).Get(context.TODO(), "manual-acnp", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestPolicyApplierClientDryRun(t *testing.T) {
+	restClient, client := newFakePolicyServer(t,
+		newTestPolicy("ClusterNetworkPolicy", "", "recommend-reject-acnp-stale", map[string]interface{}{appliedByLabel: appliedBy}),
+	)
+	var out bytes.Buffer
+	applier := &policyApplier{restClient: restClient, client: client, out: &out, dryRun: clientDryRun}
+	policies, err := decodePolicyBundle([]byte(testRecommendedPolicies))
+	require.NoError(t, err)
+	require.NoError(t, applier.apply(policies))
+	require.NoError(t, applier.prune(policies))
+	assert.Equal(t, `NetworkPolicy antrea-test/recommend-allow-anp-ab7fd (dry run)
+ClusterNetworkPolicy recommend-reject-acnp-9juz4 (dry run)
+ClusterNetworkPolicy recommend-reject-acnp-stale pruned (dry run)
+`, out.String())
+	_, err = client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-stale", metav1.GetOptions{})
+	assert.NoError(t, err)
+	_, err = client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-9juz4", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPolicyApplierServerDryRun(t *testing.T) {
+	restClient, client := newFakePolicyServer(t,
+		newTestPolicy("ClusterNetworkPolicy", "", "recommend-reject-acnp-stale", map[string]interface{}{appliedByLabel: appliedBy}),
+	)
+	var out bytes.Buffer
+	applier := &policyApplier{restClient: restClient, client: client, out: &out, dryRun: serverDryRun}
+	policies, err := decodePolicyBundle([]byte(testRecommendedPolicies))
+	require.NoError(t, err)
+	require.NoError(t, applier.apply(policies))
+	require.NoError(t, applier.prune(policies))
+	assert.Equal(t, `NetworkPolicy antrea-test/recommend-allow-anp-ab7fd created (server dry run)
+ClusterNetworkPolicy recommend-reject-acnp-9juz4 created (server dry run)
+ClusterNetworkPolicy recommend-reject-acnp-stale pruned (server dry run)
+`, out.String())
+	_, err = client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-stale", metav1.GetOptions{})
+	assert.NoError(t, err)
+	_, err = client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-9juz4", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+// TestPolicyApplierPruneKubectlApplied checks that the policies applied by
+// "retrieve --kubectl-apply" or the k8s sink are pruned by apply.
+func TestPolicyApplierPruneKubectlApplied(t *testing.T) {
+	restClient, client := newFakePolicyServer(t)
+	var out bytes.Buffer
+	policies := []map[string]interface{}{newTestPolicy("NetworkPolicy", "app-a", "recommend-allow-anp-old", nil).Object}
+	require.NoError(t, serverSideApplyPolicies(&out, restClient, policies, applyOptions{recoID: "old"}))
+
+	out.Reset()
+	applier := &policyApplier{restClient: restClient, client: client, out: &out, recoID: "new"}
+	policies = []map[string]interface{}{newTestPolicy("NetworkPolicy", "app-a", "recommend-allow-anp-new", nil).Object}
+	require.NoError(t, applier.apply(policies))
+	require.NoError(t, applier.prune(policies))
+	assert.Equal(t, `NetworkPolicy app-a/recommend-allow-anp-new created
+NetworkPolicy app-a/recommend-allow-anp-old pruned
+`, out.String())
+}
+
+func TestPolicyApplierPruneScope(t *testing.T) {
+	jobA := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			newTestPolicy("NetworkPolicy", "app-a", "recommend-allow-anp-a", nil).Object,
+			newTestPolicy("ClusterNetworkPolicy", "", "recommend-reject-acnp-a", nil).Object,
+		}
+	}
+	jobB := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			newTestPolicy("NetworkPolicy", "app-b", "recommend-allow-anp-b", nil).Object,
+			newTestPolicy("ClusterNetworkPolicy", "", "recommend-reject-acnp-b", nil).Object,
+		}
+	}
+	restClient, client := newFakePolicyServer(t)
+	var out bytes.Buffer
+	applier := &policyApplier{restClient: restClient, client: client, out: &out}
+	for _, policies := range [][]map[string]interface{}{jobA(), jobB()} {
+		require.NoError(t, applier.apply(policies))
+		require.NoError(t, applier.prune(policies))
+	}
+	assert.Equal(t, `NetworkPolicy app-a/recommend-allow-anp-a created
+ClusterNetworkPolicy recommend-reject-acnp-a created
+NetworkPolicy app-b/recommend-allow-anp-b created
+ClusterNetworkPolicy recommend-reject-acnp-b created
+`, out.String())
+	acnp, err := client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-b", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "app-b", acnp.GetAnnotations()[appliedNamespacesAnnotation])
+
+	// a job selecting other Pods in app-a, applied with scope, neither
+	// prunes nor is pruned by the policies of the first job
+	out.Reset()
+	scoped := &policyApplier{restClient: restClient, client: client, out: &out, scope: "web"}
+	policies := []map[string]interface{}{newTestPolicy("NetworkPolicy", "app-a", "recommend-allow-anp-web", nil).Object}
+	require.NoError(t, scoped.apply(policies))
+	require.NoError(t, scoped.prune(policies))
+	policies = jobA()
+	require.NoError(t, applier.apply(policies))
+	require.NoError(t, applier.prune(policies))
+	assert.Equal(t, `NetworkPolicy app-a/recommend-allow-anp-web created
+NetworkPolicy app-a/recommend-allow-anp-a unchanged
+ClusterNetworkPolicy recommend-reject-acnp-a unchanged
+`, out.String())
+
+	out.Reset()
+	policies = []map[string]interface{}{newTestPolicy("NetworkPolicy", "app-b", "recommend-allow-anp-b2", nil).Object}
+	require.NoError(t, applier.apply(policies))
+	require.NoError(t, applier.prune(policies))
+	assert.Equal(t, `NetworkPolicy app-b/recommend-allow-anp-b2 created
+NetworkPolicy app-b/recommend-allow-anp-b pruned
+ClusterNetworkPolicy recommend-reject-acnp-b pruned
+`, out.String())
+	for _, name := range []string{"recommend-allow-anp-a", "recommend-allow-anp-web"} {
+		_, err = client.Resource(anpResource).Namespace("app-a").Get(context.TODO(), name, metav1.GetOptions{})
+		assert.NoError(t, err, "NetworkPolicy %s should not be pruned", name)
+	}
+	_, err = client.Resource(acnpResource).Get(context.TODO(), "recommend-reject-acnp-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,325 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+
+	"antrea.io/theia/pkg/util/oci"
+	"antrea.io/theia/pkg/util/signing"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// policyRecommendationApplyCmd represents the policy-recommendation apply command
+var policyRecommendationApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply recommended policies to the cluster",
+	Long: `Apply the recommended policies of a policy recommendation job, retrieved from
+ClickHouse by ID, saved with "theia policy-recommendation retrieve --file", or
+pushed to an OCI registry with "theia policy-recommendation retrieve --push".
+
+The policies are applied with server-side apply, with the theia field manager,
+like with "theia policy-recommendation retrieve --kubectl-apply", and labelled
+as managed by theia. Fields of existing policies owned by other field managers
+are reported as conflicts, unless force-conflicts is set. With prune, the policies
+applied previously which are no longer recommended are deleted, in the scope of
+the recommended policies: the namespaced policies in their Namespaces, and the
+cluster-scoped policies applied together with policies in these Namespaces
+only. The policies recommended by jobs targeting other Namespaces are kept.
+Policies applied with scope are only pruned when applying policies with the
+same scope, e.g. to keep the policies of jobs selecting different Pods in the
+same Namespaces.
+
+When key is provided, the policies are only applied if their signature is
+valid: the signature saved next to the file, or pushed with the policies.`,
+	Args: cobra.NoArgs,
+	Example: `
+Apply the recommended policies of the job e998433e-accb-4888-9fc8-06563f073e86
+$ theia policy-recommendation apply --id e998433e-accb-4888-9fc8-06563f073e86
+Apply the recommended policies of a job, deleting the ones applied previously which are no longer recommended
+$ theia policy-recommendation apply --id e998433e-accb-4888-9fc8-06563f073e86 --prune
+Apply and prune the recommended policies of a job selecting the frontend Pods, without deleting the ones of other jobs
+$ theia policy-recommendation apply --id e998433e-accb-4888-9fc8-06563f073e86 --prune --scope frontend
+Validate the recommended policies of a job with the apiserver, without persisting them
+$ theia policy-recommendation apply --id e998433e-accb-4888-9fc8-06563f073e86 --dry-run=server
+Apply the recommended policies saved to output.yaml
+$ theia policy-recommendation apply --file output.yaml
+Apply the recommended policies pushed to an OCI registry, after verifying their signature
+$ theia policy-recommendation apply --from-oci oci://registry.example.com/theia/policies:v1 --key cosign.pub
+Show the recommended policies which would be applied
+$ theia policy-recommendation apply --from-oci oci://registry.example.com/theia/policies:v1 --dry-run
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoID, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		filePath, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return err
+		}
+		fromOCI, err := cmd.Flags().GetString("from-oci")
+		if err != nil {
+			return err
+		}
+		sources := 0
+		for _, source := range []string{recoID, filePath, fromOCI} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("exactly one of id, file and from-oci should be provided")
+		}
+		if recoID != "" {
+			if err := ParseRecommendationID(recoID); err != nil {
+				return err
+			}
+		}
+		keyPath, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		if keyPath != "" && recoID != "" {
+			return fmt.Errorf("key cannot be used together with id")
+		}
+		signatureFilePath, err := cmd.Flags().GetString("signature")
+		if err != nil {
+			return err
+		}
+		if signatureFilePath == "" {
+			signatureFilePath = filePath + ".sig"
+		}
+		dryRun, err := cmd.Flags().GetString("dry-run")
+		if err != nil {
+			return err
+		}
+		if err := validation.OneOf("dry-run", dryRun, "none", clientDryRun, serverDryRun); err != nil {
+			return err
+		}
+		prune, err := cmd.Flags().GetBool("prune")
+		if err != nil {
+			return err
+		}
+		forceConflicts, err := cmd.Flags().GetBool("force-conflicts")
+		if err != nil {
+			return err
+		}
+		scope, err := cmd.Flags().GetString("scope")
+		if err != nil {
+			return err
+		}
+		if errs := k8svalidation.IsValidLabelValue(scope); len(errs) > 0 {
+			return fmt.Errorf("scope %q should be a valid label value: %s", scope, strings.Join(errs, ", "))
+		}
+
+		var bundle []byte
+		if recoID != "" {
+			if bundle, err = getRecommendedPoliciesByID(cmd, recoID); err != nil {
+				return err
+			}
+		} else if fromOCI != "" {
+			ref, err := oci.ParseReference(fromOCI)
+			if err != nil {
+				return err
+			}
+			registryClient, err := newRegistryClient(cmd)
+			if err != nil {
+				return err
+			}
+			var publicKey crypto.PublicKey
+			if keyPath != "" {
+				if publicKey, err = signing.LoadPublicKey(keyPath); err != nil {
+					return err
+				}
+			}
+			if bundle, err = pullPolicyBundle(context.TODO(), registryClient, ref, publicKey); err != nil {
+				return err
+			}
+		} else {
+			if keyPath != "" {
+				if err := verifyPolicyBundle(filePath, keyPath, signatureFilePath); err != nil {
+					return err
+				}
+			}
+			if bundle, err = os.ReadFile(filePath); err != nil {
+				return fmt.Errorf("error when reading recommendation result: %v", err)
+			}
+		}
+		policies, err := decodePolicyBundle(bundle)
+		if err != nil {
+			return err
+		}
+		if len(policies) == 0 {
+			return fmt.Errorf("no recommended policies to apply")
+		}
+
+		applier := &policyApplier{
+			out:            cmd.OutOrStdout(),
+			recoID:         recoID,
+			scope:          scope,
+			dryRun:         dryRun,
+			forceConflicts: forceConflicts,
+		}
+		if dryRun != clientDryRun || prune {
+			kubeconfig, err := ResolveKubeConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if applier.client, err = CreateDynamicClient(kubeconfig); err != nil {
+				return fmt.Errorf("couldn't create k8s client using given kubeconfig: %v", err)
+			}
+			clientset, err := CreateK8sClient(kubeconfig)
+			if err != nil {
+				return fmt.Errorf("couldn't create k8s client using given kubeconfig: %v", err)
+			}
+			applier.restClient = clientset.CoreV1().RESTClient()
+		}
+		if err := applier.apply(policies); err != nil {
+			return err
+		}
+		if prune {
+			return applier.prune(policies)
+		}
+		return nil
+	},
+}
+
+// getRecommendedPoliciesByID gets the recommended policies of a policy
+// recommendation job from ClickHouse.
+func getRecommendedPoliciesByID(cmd *cobra.Command, recoID string) ([]byte, error) {
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return nil, err
+	}
+	if endpoint != "" {
+		if err := ParseEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return nil, err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create k8s client using given kubeconfig: %v", err)
+	}
+	recoResult, err := getPolicyRecommendationResult(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, "", "", "", nil, recoID)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(recoResult), nil
+}
+
+func policyObjectName(policy map[string]interface{}) string {
+	metadata, _ := policy["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if namespace, _ := metadata["namespace"].(string); namespace != "" {
+		return namespace + "/" + name
+	}
+	return name
+}
+
+// decodePolicyBundle decodes the recommended policies. ClusterGroups are
+// returned first, as they may be referenced by the policies.
+func decodePolicyBundle(bundle []byte) ([]map[string]interface{}, error) {
+	docs, _, err := decodeRecommendedPolicies(string(bundle))
+	if err != nil {
+		return nil, err
+	}
+	var policies []map[string]interface{}
+	for _, doc := range docs {
+		policy, err := decodePolicy(doc)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return policies[i]["kind"] == "ClusterGroup" && policies[j]["kind"] != "ClusterGroup"
+	})
+	return policies, nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationApplyCmd)
+	policyRecommendationApplyCmd.Flags().StringP(
+		"id",
+		"i",
+		"",
+		"ID of the policy recommendation job whose recommended policies are applied.",
+	)
+	policyRecommendationApplyCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file path of the recommended policies to apply.",
+	)
+	policyRecommendationApplyCmd.Flags().String(
+		"from-oci",
+		"",
+		"Reference of the OCI artifact of the recommended policies to apply, e.g. oci://registry.example.com/theia/policies:v1.",
+	)
+	policyRecommendationApplyCmd.Flags().String(
+		"key",
+		"",
+		"PEM-encoded Ed25519 or ECDSA P-256 public key used to verify the signature of the recommended policies, e.g. cosign.pub.",
+	)
+	policyRecommendationApplyCmd.Flags().String(
+		"signature",
+		"",
+		"The file path of the signature, when applying policies from a file. Defaults to the file path with the .sig suffix.",
+	)
+	policyRecommendationApplyCmd.Flags().String(
+		"dry-run",
+		"none",
+		`Must be "none", "client" or "server". With client, only print the recommended policies which would be applied.
+With server, submit the requests to the apiserver without persisting the policies. --dry-run alone means client.`,
+	)
+	policyRecommendationApplyCmd.Flags().Lookup("dry-run").NoOptDefVal = clientDryRun
+	policyRecommendationApplyCmd.Flags().Bool(
+		"prune",
+		false,
+		"Delete the policies applied previously by theia which are not in the recommended policies, in their Namespaces and scope.",
+	)
+	policyRecommendationApplyCmd.Flags().String(
+		"scope",
+		"",
+		"Label the applied policies with this scope. With prune, only the policies applied with the same scope are deleted.",
+	)
+	policyRecommendationApplyCmd.Flags().Bool(
+		"force-conflicts",
+		false,
+		"Take ownership of the fields of the existing policies which conflict with other field managers.",
+	)
+	addRegistryFlags(policyRecommendationApplyCmd)
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/util/oci"
+	"antrea.io/theia/pkg/util/signing"
+)
+
+// fakeRegistryHandler is an anonymous in-memory OCI registry.
+func fakeRegistryHandler() http.Handler {
+	var mutex sync.Mutex
+	content := map[string][]byte{}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case req.Method == http.MethodPost:
+			w.Header().Set("Location", req.URL.Path+"upload")
+			w.WriteHeader(http.StatusAccepted)
+		case req.Method == http.MethodPut:
+			data, _ := io.ReadAll(req.Body)
+			path := req.URL.Path
+			if digest := req.URL.Query().Get("digest"); digest != "" {
+				path = strings.TrimSuffix(path, "uploads/upload") + digest
+			}
+			content[path] = data
+			content[path[:strings.LastIndex(path, "/")+1]+fmt.Sprintf("sha256:%x", sha256.Sum256(data))] = data
+			w.WriteHeader(http.StatusCreated)
+		default:
+			data, ok := content[req.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	})
+}
+
+func TestPushAndPullPolicyBundle(t *testing.T) {
+	server := httptest.NewServer(fakeRegistryHandler())
+	defer server.Close()
+	client := oci.NewClient("", "", true)
+	ref, err := oci.ParseReference("oci://" + strings.TrimPrefix(server.URL, "http://") + "/theia/policies:v1")
+	require.NoError(t, err)
+	unsignedRef := ref
+	unsignedRef.Reference = "unsigned"
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signature, err := signing.Sign(privateKey, []byte(testRecommendedPolicies))
+	require.NoError(t, err)
+	recoID := "e998433e-accb-4888-9fc8-06563f073e86"
+	_, err = pushPolicyBundle(context.TODO(), client, ref, recoID, []byte(testRecommendedPolicies), signature)
+	require.NoError(t, err)
+	_, err = pushPolicyBundle(context.TODO(), client, unsignedRef, recoID, []byte(testRecommendedPolicies), "")
+	require.NoError(t, err)
+
+	bundle, err := pullPolicyBundle(context.TODO(), client, ref, publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, testRecommendedPolicies, string(bundle))
+	bundle, err = pullPolicyBundle(context.TODO(), client, unsignedRef, nil)
+	require.NoError(t, err)
+	assert.Equal(t, testRecommendedPolicies, string(bundle))
+
+	_, err = pullPolicyBundle(context.TODO(), client, ref, otherPublicKey)
+	assert.ErrorContains(t, err, "invalid signature")
+	_, err = pullPolicyBundle(context.TODO(), client, unsignedRef, publicKey)
+	assert.ErrorContains(t, err, "is not signed")
+}
+
+func TestDecodePolicyBundle(t *testing.T) {
+	bundle := testRecommendedPolicies + `---
+apiVersion: crd.antrea.io/v1alpha3
+kind: ClusterGroup
+metadata:
+  name: cg-antrea-e2e-perftestsvc
+spec:
+  serviceReference:
+    name: perftestsvc
+    namespace: antrea-e2e
+`
+	policies, err := decodePolicyBundle([]byte(bundle))
+	require.NoError(t, err)
+	var names []string
+	for _, policy := range policies {
+		names = append(names, fmt.Sprintf("%s %s", policy["kind"], policyObjectName(policy)))
+	}
+	assert.Equal(t, []string{
+		"ClusterGroup cg-antrea-e2e-perftestsvc",
+		"NetworkPolicy antrea-test/recommend-allow-anp-ab7fd",
+		"ClusterNetworkPolicy recommend-reject-acnp-9juz4",
+	}, names)
+
+	_, err = decodePolicyBundle([]byte("kind: [NetworkPolicy\n"))
+	assert.ErrorContains(t, err, "error when parsing the recommended policies")
+}
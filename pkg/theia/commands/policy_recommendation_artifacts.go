@@ -0,0 +1,177 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+
+	s3client "antrea.io/theia/pkg/util/s3"
+)
+
+// policyRecommendationArtifactsCmd represents the policy-recommendation artifacts command
+var policyRecommendationArtifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "List or download the artifacts of a policy recommendation job",
+	Long: `List the artifacts uploaded by a policy recommendation job run with
+--artifacts-uri: the logs (job.log), the metrics (metrics.json) and the
+recommended policies (result.yaml) of the job. The artifacts are downloaded
+when --download-dir is provided.
+
+The credentials are read from the environment, from the shared configuration
+files or from the instance metadata, like for the AWS CLI. Google Cloud Storage
+is accessed through its S3-compatible API, with HMAC keys.`,
+	Args: cobra.RangeArgs(0, 1),
+	Example: `
+List the artifacts of the policy recommendation job with ID e998433e-accb-4888-9fc8-06563f073e86
+$ theia policy-recommendation artifacts e998433e-accb-4888-9fc8-06563f073e86 --artifacts-uri s3://my-bucket/theia
+Download the artifacts of the job to the artifacts directory
+$ theia policy-recommendation artifacts --id e998433e-accb-4888-9fc8-06563f073e86 --artifacts-uri gs://my-bucket/theia --download-dir artifacts
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoID, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		if recoID == "" && len(args) == 1 {
+			recoID = args[0]
+		}
+		err = ParseRecommendationID(recoID)
+		if err != nil {
+			return err
+		}
+		artifactsURI, err := cmd.Flags().GetString("artifacts-uri")
+		if err != nil {
+			return err
+		}
+		artifactsEndpoint, err := cmd.Flags().GetString("artifacts-endpoint")
+		if err != nil {
+			return err
+		}
+		location, err := s3client.ParseArtifactsURI(artifactsURI, artifactsEndpoint)
+		if err != nil {
+			return err
+		}
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			return err
+		}
+		downloadDir, err := cmd.Flags().GetString("download-dir")
+		if err != nil {
+			return err
+		}
+
+		var optFns []func(*awsconfig.LoadOptions) error
+		if region == "" && location.Endpoint == s3client.GCSEndpoint {
+			region = "auto"
+		}
+		if region != "" {
+			optFns = append(optFns, awsconfig.WithRegion(region))
+		}
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), optFns...)
+		if err != nil {
+			return fmt.Errorf("unable to load AWS SDK config: %v", err)
+		}
+		if awsCfg.Region == "" {
+			return fmt.Errorf("the region of the bucket is unknown, please provide it with --region")
+		}
+		artifactsTable, err := getJobArtifacts(context.TODO(), s3client.GetClient(awsCfg, location.Endpoint), location, recoID, downloadDir)
+		if err != nil {
+			return err
+		}
+		if len(artifactsTable) == 1 {
+			return fmt.Errorf("could not find artifacts of the policy recommendation job with ID %s in %s", recoID, artifactsURI)
+		}
+		TableOutput(artifactsTable)
+		return nil
+	},
+}
+
+// getJobArtifacts lists the artifacts of a job, and downloads them to
+// downloadDir if it is not empty. It returns the artifacts table, with a
+// header row.
+func getJobArtifacts(ctx context.Context, client s3client.Interface, location *s3client.ArtifactsLocation, recoID string, downloadDir string) ([][]string, error) {
+	objects, err := s3client.ListJobArtifacts(ctx, client, location, recoID)
+	if err != nil {
+		return nil, err
+	}
+	header := []string{"Name", "Size", "LastModified"}
+	if downloadDir != "" {
+		header = append(header, "Path")
+	}
+	artifactsTable := [][]string{header}
+	jobPrefix := location.JobPrefix(recoID)
+	for _, object := range objects {
+		name := strings.TrimPrefix(*object.Key, jobPrefix)
+		lastModified := "N/A"
+		if object.LastModified != nil {
+			lastModified = FormatTimestamp(*object.LastModified)
+		}
+		row := []string{name, strconv.FormatInt(object.Size, 10), lastModified}
+		if downloadDir != "" {
+			// keys are provided by the object storage service, never write
+			// outside of the download directory
+			cleanName := filepath.Clean(filepath.FromSlash(name))
+			if name == "" || filepath.IsAbs(cleanName) || cleanName == ".." || strings.HasPrefix(cleanName, ".."+string(os.PathSeparator)) {
+				fmt.Fprintf(os.Stderr, "Warning: skipping download of artifact with invalid name %q\n", name)
+				continue
+			}
+			path := filepath.Join(downloadDir, recoID, cleanName)
+			if _, err := s3client.DownloadObject(ctx, client, location.Bucket, *object.Key, path); err != nil {
+				return nil, err
+			}
+			row = append(row, path)
+		}
+		artifactsTable = append(artifactsTable, row)
+	}
+	return artifactsTable, nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationArtifactsCmd)
+	policyRecommendationArtifactsCmd.Flags().StringP(
+		"id",
+		"i",
+		"",
+		"ID of the policy recommendation job.",
+	)
+	policyRecommendationArtifactsCmd.Flags().String(
+		"artifacts-uri",
+		"",
+		"s3://<bucket>/<prefix> or gs://<bucket>/<prefix> URI provided with --artifacts-uri when running the job.",
+	)
+	policyRecommendationArtifactsCmd.Flags().String(
+		"artifacts-endpoint",
+		"",
+		"Endpoint of the S3-compatible object storage service, e.g. a MinIO server, if the artifacts are not stored in AWS S3 or Google Cloud Storage.",
+	)
+	policyRecommendationArtifactsCmd.Flags().String(
+		"region",
+		"",
+		"Region of the bucket. By default, the region of the AWS configuration (e.g. AWS_REGION) is used.",
+	)
+	policyRecommendationArtifactsCmd.Flags().String(
+		"download-dir",
+		"",
+		"Directory the artifacts are downloaded to, under a sub-directory named after the job ID. By default, the artifacts are only listed.",
+	)
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	s3client "antrea.io/theia/pkg/util/s3"
+)
+
+type fakeS3Client struct {
+	objects map[string]string
+}
+
+func (c *fakeS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(c.objects[*params.Key]))}, nil
+}
+
+func (c *fakeS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	lastModified := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	output := &s3.ListObjectsV2Output{}
+	for key, content := range c.objects {
+		if strings.HasPrefix(key, *params.Prefix) {
+			key := key
+			output.Contents = append(output.Contents, s3types.Object{Key: &key, Size: int64(len(content)), LastModified: &lastModified})
+		}
+	}
+	return output, nil
+}
+
+func (c *fakeS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	content, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.objects[*params.Key] = string(content)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestGetJobArtifacts(t *testing.T) {
+	recoID := "e998433e-accb-4888-9fc8-06563f073e86"
+	client := &fakeS3Client{objects: map[string]string{
+		"theia/" + recoID + "/job.log":     "log",
+		"theia/" + recoID + "/result.yaml": "apiVersion: v1",
+		"theia/" + recoID + "/../escape":   "escape",
+		"theia/other-id/job.log":           "other log",
+	}}
+	location := &s3client.ArtifactsLocation{Bucket: "my-bucket", Prefix: "theia"}
+
+	artifactsTable, err := getJobArtifacts(context.TODO(), client, location, recoID, "")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Name", "Size", "LastModified"},
+		{"../escape", "6", "2022-08-01 10:00:00"},
+		{"job.log", "3", "2022-08-01 10:00:00"},
+		{"result.yaml", "14", "2022-08-01 10:00:00"},
+	}, artifactsTable)
+
+	downloadDir := t.TempDir()
+	artifactsTable, err = getJobArtifacts(context.TODO(), client, location, recoID, downloadDir)
+	require.NoError(t, err)
+	resultPath := filepath.Join(downloadDir, recoID, "result.yaml")
+	assert.Equal(t, [][]string{
+		{"Name", "Size", "LastModified", "Path"},
+		{"job.log", "3", "2022-08-01 10:00:00", filepath.Join(downloadDir, recoID, "job.log")},
+		{"result.yaml", "14", "2022-08-01 10:00:00", resultPath},
+	}, artifactsTable)
+	content, err := os.ReadFile(resultPath)
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: v1", string(content))
+	_, err = os.Stat(filepath.Join(downloadDir, "escape"))
+	assert.True(t, os.IsNotExist(err))
+}
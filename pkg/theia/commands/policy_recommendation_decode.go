@@ -0,0 +1,114 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// splitRecommendedPolicies returns the YAML documents of the recommended
+// policies. Documents are separated by "---" lines, which may be followed by
+// a comment, and documents without content are left out.
+func splitRecommendedPolicies(yamls string) ([]string, error) {
+	var docs []string
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(strings.NewReader(yamls)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error when parsing the recommended policies: %v", err)
+		}
+		if len(bytes.TrimSpace(doc)) != 0 {
+			docs = append(docs, string(doc))
+		}
+	}
+}
+
+// decodeRecommendedPolicies decodes the YAML documents of a recommendation
+// result into typed policies. Every document must be a supported policy or
+// ClusterGroup with a name, so that a malformed result is rejected as a whole,
+// before any policy is applied. Documents which only hold comments are left
+// out. The YAML documents are returned with the policies decoded from them.
+func decodeRecommendedPolicies(yamls string) ([]string, []recommendedPolicy, error) {
+	docs, err := splitRecommendedPolicies(yamls)
+	if err != nil {
+		return nil, nil, err
+	}
+	var kept []string
+	var policies []recommendedPolicy
+	for i, doc := range docs {
+		var object interface{}
+		if err := yaml.Unmarshal([]byte(doc), &object); err != nil {
+			return nil, nil, fmt.Errorf("error when parsing the recommended policies: document %d: %v", i+1, err)
+		}
+		if object == nil {
+			continue
+		}
+		if _, ok := object.(map[interface{}]interface{}); !ok {
+			return nil, nil, fmt.Errorf("error when parsing the recommended policies: document %d is not an object", i+1)
+		}
+		var policy recommendedPolicy
+		if err := yaml.Unmarshal([]byte(doc), &policy); err != nil {
+			return nil, nil, fmt.Errorf("error when parsing the recommended policies: document %d: %v", i+1, err)
+		}
+		if _, err := policyResource(policy.APIVersion, policy.Kind); err != nil {
+			return nil, nil, fmt.Errorf("error when parsing the recommended policies: document %d: %v", i+1, err)
+		}
+		if policy.Metadata.Name == "" {
+			return nil, nil, fmt.Errorf("error when parsing the recommended policies: document %d has no name", i+1)
+		}
+		kept = append(kept, doc)
+		policies = append(policies, policy)
+	}
+	return kept, policies, nil
+}
+
+func parseRecommendedPolicies(yamls string) ([]recommendedPolicy, error) {
+	_, policies, err := decodeRecommendedPolicies(yamls)
+	return policies, err
+}
+
+// decodePolicy decodes a recommended policy.
+func decodePolicy(doc string) (map[string]interface{}, error) {
+	var policy map[string]interface{}
+	if err := k8syaml.NewYAMLOrJSONDecoder(strings.NewReader(doc), len(doc)).Decode(&policy); err != nil {
+		return nil, fmt.Errorf("error when parsing the recommended policies: %v", err)
+	}
+	return policy, nil
+}
+
+// policyResource returns the API resource of a recommended Antrea-native
+// policy, ClusterGroup or Kubernetes NetworkPolicy.
+func policyResource(apiVersion, kind string) (string, error) {
+	resources := map[string]string{
+		"NetworkPolicy":        "networkpolicies",
+		"ClusterNetworkPolicy": "clusternetworkpolicies",
+		"ClusterGroup":         "clustergroups",
+	}
+	resource, ok := resources[kind]
+	if !ok || !(strings.HasPrefix(apiVersion, "crd.antrea.io/") || apiVersion == "networking.k8s.io/v1" && kind == "NetworkPolicy") {
+		return "", fmt.Errorf("unsupported recommended policy %s %s", apiVersion, kind)
+	}
+	return resource, nil
+}
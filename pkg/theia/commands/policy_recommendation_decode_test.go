@@ -0,0 +1,113 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRecommendedPolicies(t *testing.T) {
+	testCases := []struct {
+		name          string
+		yamls         string
+		expectedNames []string
+		expectedError string
+	}{
+		{
+			name:          "recommended policies",
+			yamls:         testRecommendedPolicies,
+			expectedNames: []string{"recommend-allow-anp-ab7fd", "recommend-reject-acnp-9juz4"},
+		},
+		{
+			name:          "separators with comments and CRLF line endings",
+			yamls:         "--- # first\r\napiVersion: crd.antrea.io/v1alpha2\r\nkind: ClusterGroup\r\nmetadata:\r\n  name: cg-1\r\n---\r\n# no policy\r\n---\r\n",
+			expectedNames: []string{"cg-1"},
+		},
+		{
+			name:          "separator in a block scalar",
+			yamls:         "apiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\nmetadata:\n  name: np-1\n  annotations:\n    note: |\n      a---b\n",
+			expectedNames: []string{"np-1"},
+		},
+		{
+			name:  "empty result",
+			yamls: "",
+		},
+		{
+			name:          "malformed document",
+			yamls:         testRecommendedPolicies + "---\nkind: [NetworkPolicy\n",
+			expectedError: "error when parsing the recommended policies: document 3: yaml: line 1: did not find expected ',' or ']'",
+		},
+		{
+			name:          "scalar document",
+			yamls:         "NetworkPolicy\n",
+			expectedError: "error when parsing the recommended policies: document 1 is not an object",
+		},
+		{
+			name:          "wrong field type",
+			yamls:         "apiVersion: crd.antrea.io/v1alpha1\nkind: NetworkPolicy\nmetadata: policy\n",
+			expectedError: "error when parsing the recommended policies: document 1: yaml: unmarshal errors:\n  line 3: cannot unmarshal !!str `policy` into struct { Name string \"yaml:\\\"name\\\"\"; Namespace string \"yaml:\\\"namespace\\\"\" }",
+		},
+		{
+			name:          "unsupported kind",
+			yamls:         "apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod-1\n",
+			expectedError: "error when parsing the recommended policies: document 1: unsupported recommended policy v1 Pod",
+		},
+		{
+			name:          "missing name",
+			yamls:         "apiVersion: crd.antrea.io/v1alpha1\nkind: ClusterNetworkPolicy\nspec: {}\n",
+			expectedError: "error when parsing the recommended policies: document 1 has no name",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, policies, err := decodeRecommendedPolicies(tt.yamls)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, docs, len(policies))
+			var names []string
+			for _, policy := range policies {
+				names = append(names, policy.Metadata.Name)
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func FuzzDecodeRecommendedPolicies(f *testing.F) {
+	f.Add(testRecommendedPolicies)
+	f.Add(testRecommendedPolicies + "---\nkind: [NetworkPolicy\n")
+	f.Add("--- # comment\r\n---\napiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\nmetadata:\n  name: np-1\n")
+	f.Fuzz(func(t *testing.T, yamls string) {
+		docs, policies, err := decodeRecommendedPolicies(yamls)
+		if err != nil {
+			return
+		}
+		require.Len(t, docs, len(policies))
+		for _, doc := range docs {
+			// The documents of a valid result are applied, they must not fail
+			// to decode half-way.
+			policy, err := decodePolicy(doc)
+			require.NoError(t, err)
+			policyObjectName(policy)
+			auditModePolicy(doc)
+		}
+	})
+}
@@ -17,12 +17,13 @@ package commands
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/kubernetes"
 
 	"antrea.io/theia/pkg/theia/commands/config"
-	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
+	"antrea.io/theia/pkg/util/executor"
 )
 
 // policyRecommendationDeleteCmd represents the policy-recommendation delete command
@@ -62,6 +63,10 @@ $ theia policy-recommendation delete e998433e-accb-4888-9fc8-06563f073e86
 				return err
 			}
 		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
 		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
 		if err != nil {
 			return err
@@ -72,7 +77,7 @@ $ theia policy-recommendation delete e998433e-accb-4888-9fc8-06563f073e86
 			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
 		}
 
-		idMap, err := getPolicyRecommendationIdMap(clientset, kubeconfig, endpoint, useClusterIP)
+		idMap, err := getPolicyRecommendationIdMap(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
 		if err != nil {
 			return fmt.Errorf("err when getting policy recommendation ID map, %v", err)
 		}
@@ -81,39 +86,32 @@ $ theia policy-recommendation delete e998433e-accb-4888-9fc8-06563f073e86
 			return fmt.Errorf("could not find the policy recommendation job with given ID")
 		}
 
-		clientset.CoreV1().RESTClient().Delete().
-			AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
-			Namespace(config.FlowVisibilityNS).
-			Resource("sparkapplications").
-			Name("pr-" + recoID).
-			Do(context.TODO())
+		for _, jobExecutor := range executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}) {
+			if err := jobExecutor.Delete(context.TODO(), recoID); err != nil {
+				return fmt.Errorf("error when deleting policy recommendation job from the %s backend: %v", jobExecutor.Backend(), err)
+			}
+		}
 
-		err = deletePolicyRecommendationResult(clientset, kubeconfig, endpoint, useClusterIP, recoID)
+		err = deletePolicyRecommendationResult(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, recoID)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Successfully deleted policy recommendation job with ID %s\n", recoID)
+		fmt.Fprintf(infoOut(cmd), "Successfully deleted policy recommendation job with ID %s\n", recoID)
 		return nil
 	},
 }
 
-func getPolicyRecommendationIdMap(clientset kubernetes.Interface, kubeconfig string, endpoint string, useClusterIP bool) (idMap map[string]bool, err error) {
+func getPolicyRecommendationIdMap(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool) (idMap map[string]bool, err error) {
 	idMap = make(map[string]bool)
-	sparkApplicationList := &sparkv1.SparkApplicationList{}
-	err = clientset.CoreV1().RESTClient().Get().
-		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
-		Namespace(config.FlowVisibilityNS).
-		Resource("sparkapplications").
-		Do(context.TODO()).Into(sparkApplicationList)
+	jobs, err := executor.ListJobs(context.TODO(), executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}))
 	if err != nil {
 		return idMap, err
 	}
-	for _, sparkApplication := range sparkApplicationList.Items {
-		id := sparkApplication.ObjectMeta.Name[3:]
-		idMap[id] = true
+	for _, job := range jobs {
+		idMap[job.ID] = true
 	}
-	completedPolicyRecommendationList, err := getCompletedPolicyRecommendationList(clientset, kubeconfig, endpoint, useClusterIP)
+	completedPolicyRecommendationList, err := getCompletedPolicyRecommendationList(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
 	if err != nil {
 		return idMap, err
 	}
@@ -123,8 +121,8 @@ func getPolicyRecommendationIdMap(clientset kubernetes.Interface, kubeconfig str
 	return idMap, nil
 }
 
-func deletePolicyRecommendationResult(clientset kubernetes.Interface, kubeconfig string, endpoint string, useClusterIP bool, recoID string) (err error) {
-	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, useClusterIP)
+func deletePolicyRecommendationResult(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool, recoID string) (err error) {
+	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
 	if portForward != nil {
 		defer portForward.Stop()
 	}
@@ -136,6 +134,16 @@ func deletePolicyRecommendationResult(clientset kubernetes.Interface, kubeconfig
 	if err != nil {
 		return fmt.Errorf("failed to delete recommendation result with id %s: %v", recoID, err)
 	}
+	// Cluster snapshots are only needed to retrieve the result. The table does
+	// not exist with older versions of the Theia Helm chart.
+	query = "ALTER TABLE " + snapshotTable + "_local ON CLUSTER '{cluster}' DELETE WHERE id = (?);"
+	if _, err := connect.Exec(query, recoID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to delete the cluster snapshot of job %s: %v\n", recoID, err)
+	}
+	query = "ALTER TABLE " + jobEventsTable + "_local ON CLUSTER '{cluster}' DELETE WHERE id = (?);"
+	if _, err := connect.Exec(query, recoID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to delete the timeline of job %s: %v\n", recoID, err)
+	}
 	return nil
 }
 
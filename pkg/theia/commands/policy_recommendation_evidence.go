@@ -0,0 +1,468 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"antrea.io/theia/pkg/clickhouse"
+)
+
+// Number of sample flows included in the evidence of each recommended rule.
+const evidenceSampleFlows = 3
+
+// Label used in the namespace selectors of the recommended policies. K8s
+// NetworkPolicies use the "name" label instead.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+type labelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type policyPeer struct {
+	PodSelector       *labelSelector `yaml:"podSelector"`
+	NamespaceSelector *labelSelector `yaml:"namespaceSelector"`
+	IPBlock           *struct {
+		CIDR string `yaml:"cidr"`
+	} `yaml:"ipBlock"`
+	Group string `yaml:"group"`
+}
+
+type policyRule struct {
+	Action     string       `yaml:"action"`
+	From       []policyPeer `yaml:"from"`
+	To         []policyPeer `yaml:"to"`
+	ToServices []struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"toServices"`
+	Ports []struct {
+		Protocol string `yaml:"protocol"`
+		Port     int    `yaml:"port"`
+	} `yaml:"ports"`
+}
+
+// recommendedPolicy holds the fields of the recommended K8s NetworkPolicies,
+// Antrea NetworkPolicies, ClusterNetworkPolicies and ClusterGroups which are
+// needed to find the flows matching their rules.
+type recommendedPolicy struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		PodSelector      *labelSelector `yaml:"podSelector"`
+		AppliedTo        []policyPeer   `yaml:"appliedTo"`
+		Ingress          []policyRule   `yaml:"ingress"`
+		Egress           []policyRule   `yaml:"egress"`
+		ServiceReference *struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"serviceReference"`
+	} `yaml:"spec"`
+}
+
+type sampleFlow struct {
+	Source                     string `json:"source"`
+	Destination                string `json:"destination"`
+	DestinationServicePortName string `json:"destinationServicePortName,omitempty"`
+	DestinationPort            uint16 `json:"destinationPort"`
+	Protocol                   string `json:"protocol"`
+	FlowEnd                    string `json:"flowEnd"`
+}
+
+type ruleEvidence struct {
+	Policy    string `json:"policy"`
+	Direction string `json:"direction"`
+	// Index of the rule in the ingress or egress rules of the policy.
+	Rule      int          `json:"rule"`
+	Action    string       `json:"action,omitempty"`
+	FlowCount uint64       `json:"flowCount"`
+	FirstSeen string       `json:"firstSeen,omitempty"`
+	LastSeen  string       `json:"lastSeen,omitempty"`
+	Samples   []sampleFlow `json:"sampleFlows,omitempty"`
+	// Reason why no evidence is given for the rule, e.g. for default deny
+	// rules, which are not derived from flows.
+	Note string `json:"note,omitempty"`
+}
+
+type evidenceReport struct {
+	ID          string         `json:"id"`
+	TimeCreated string         `json:"timeCreated"`
+	Rules       []ruleEvidence `json:"rules"`
+}
+
+// flowFilter is a condition on the flows table, with its query arguments.
+type flowFilter struct {
+	condition string
+	args      []interface{}
+}
+
+func andFilters(filters ...flowFilter) flowFilter {
+	var nonEmpty []flowFilter
+	for _, f := range filters {
+		if f.condition != "" {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+	var conditions []string
+	var args []interface{}
+	for _, f := range filters {
+		if f.condition == "" {
+			continue
+		}
+		conditions = append(conditions, "("+f.condition+")")
+		args = append(args, f.args...)
+	}
+	return flowFilter{condition: strings.Join(conditions, " AND "), args: args}
+}
+
+func orFilters(filters ...flowFilter) flowFilter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	var conditions []string
+	var args []interface{}
+	for _, f := range filters {
+		// a peer or port without any condition matches all flows
+		if f.condition == "" {
+			return flowFilter{}
+		}
+		conditions = append(conditions, "("+f.condition+")")
+		args = append(args, f.args...)
+	}
+	return flowFilter{condition: strings.Join(conditions, " OR "), args: args}
+}
+
+// podFilter matches the flows whose source or destination (prefix) is a Pod in
+// the namespace with the labels. Labels are matched individually, as label
+// selectors do.
+func podFilter(prefix string, namespace string, labels map[string]string) flowFilter {
+	filters := []flowFilter{{condition: prefix + "PodNamespace != ''"}}
+	if namespace != "" {
+		filters = append(filters, flowFilter{condition: prefix + "PodNamespace = ?", args: []interface{}{namespace}})
+	}
+	for _, key := range sortedKeys(labels) {
+		filters = append(filters, flowFilter{
+			condition: fmt.Sprintf("JSONExtractString(%sPodLabels, ?) = ?", prefix),
+			args:      []interface{}{key, labels[key]},
+		})
+	}
+	return andFilters(filters...)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func selectorNamespace(selector *labelSelector) string {
+	if selector == nil {
+		return ""
+	}
+	if ns, ok := selector.MatchLabels[namespaceNameLabel]; ok {
+		return ns
+	}
+	return selector.MatchLabels["name"]
+}
+
+func selectorLabels(selector *labelSelector) map[string]string {
+	if selector == nil {
+		return nil
+	}
+	return selector.MatchLabels
+}
+
+func serviceFilter(namespace string, name string) flowFilter {
+	return flowFilter{condition: "destinationServicePortName LIKE ?", args: []interface{}{namespace + "/" + name + ":%"}}
+}
+
+// peerFilter matches the flows whose source or destination (prefix) is the
+// peer. defaultNamespace is the namespace of a Pod selector without namespace
+// selector, i.e. the namespace of the policy.
+func peerFilter(prefix string, peer policyPeer, defaultNamespace string, groups map[string]recommendedPolicy) (flowFilter, error) {
+	switch {
+	case peer.IPBlock != nil:
+		return flowFilter{condition: "isIPAddressInRange(" + prefix + "IP, ?)", args: []interface{}{peer.IPBlock.CIDR}}, nil
+	case peer.Group != "":
+		group, ok := groups[peer.Group]
+		if !ok || group.Spec.ServiceReference == nil {
+			return flowFilter{}, fmt.Errorf("unsupported ClusterGroup %s", peer.Group)
+		}
+		return serviceFilter(group.Spec.ServiceReference.Namespace, group.Spec.ServiceReference.Name), nil
+	}
+	namespace := defaultNamespace
+	if peer.NamespaceSelector != nil {
+		namespace = selectorNamespace(peer.NamespaceSelector)
+	}
+	return podFilter(prefix, namespace, selectorLabels(peer.PodSelector)), nil
+}
+
+func protocolNumber(protocol string) int {
+	switch strings.ToUpper(protocol) {
+	case "UDP":
+		return 17
+	case "SCTP":
+		return 132
+	default:
+		return 6
+	}
+}
+
+func protocolName(protocolIdentifier uint8) string {
+	switch protocolIdentifier {
+	case 6:
+		return "TCP"
+	case 17:
+		return "UDP"
+	case 132:
+		return "SCTP"
+	default:
+		return fmt.Sprint(protocolIdentifier)
+	}
+}
+
+// ruleFilter returns the filter matching the flows allowed by the rule of the
+// policy, given the filter matching the Pods the policy is applied to.
+func ruleFilter(rule policyRule, ingress bool, appliedTo flowFilter, namespace string, groups map[string]recommendedPolicy) (flowFilter, error) {
+	peerPrefix, peers := "destination", rule.To
+	if ingress {
+		peerPrefix, peers = "source", rule.From
+	}
+	var peerFilters []flowFilter
+	for _, peer := range peers {
+		f, err := peerFilter(peerPrefix, peer, namespace, groups)
+		if err != nil {
+			return flowFilter{}, err
+		}
+		peerFilters = append(peerFilters, f)
+	}
+	for _, svc := range rule.ToServices {
+		peerFilters = append(peerFilters, serviceFilter(svc.Namespace, svc.Name))
+	}
+	var portFilters []flowFilter
+	for _, port := range rule.Ports {
+		portFilters = append(portFilters, flowFilter{
+			condition: "destinationTransportPort = ? AND protocolIdentifier = ?",
+			args:      []interface{}{port.Port, protocolNumber(port.Protocol)},
+		})
+	}
+	return andFilters(appliedTo, orFilters(peerFilters...), orFilters(portFilters...)), nil
+}
+
+// appliedToFilter returns the filter matching the Pods the policy is applied
+// to, as the source of the flows (prefix "source") or their destination.
+func appliedToFilter(policy recommendedPolicy, prefix string) flowFilter {
+	if policy.Kind == "NetworkPolicy" && policy.Spec.PodSelector != nil {
+		return podFilter(prefix, policy.Metadata.Namespace, policy.Spec.PodSelector.MatchLabels)
+	}
+	var filters []flowFilter
+	for _, peer := range policy.Spec.AppliedTo {
+		namespace := policy.Metadata.Namespace
+		if peer.NamespaceSelector != nil {
+			namespace = selectorNamespace(peer.NamespaceSelector)
+		}
+		filters = append(filters, podFilter(prefix, namespace, selectorLabels(peer.PodSelector)))
+	}
+	return orFilters(filters...)
+}
+
+func policyName(policy recommendedPolicy) string {
+	if policy.Metadata.Namespace == "" {
+		return policy.Kind + "/" + policy.Metadata.Name
+	}
+	return policy.Kind + "/" + policy.Metadata.Namespace + "/" + policy.Metadata.Name
+}
+
+// recommendedRule is an ingress or egress rule of a recommended policy.
+type recommendedRule struct {
+	policy    recommendedPolicy
+	direction string
+	// Index of the rule in the ingress or egress rules of the policy.
+	index int
+	rule  policyRule
+	// filter matches the flows allowed by the rule. It is empty for default
+	// deny rules, and when the rule cannot be translated, in which case err
+	// is set.
+	filter flowFilter
+	err    error
+}
+
+func (r *recommendedRule) isDeny() bool {
+	return r.rule.Action != "" && r.rule.Action != "Allow"
+}
+
+// listRecommendedRules returns the rules of the recommended policies, in the
+// order of the policies, with the ingress rules of each policy first.
+func listRecommendedRules(yamls string) ([]recommendedRule, error) {
+	policies, err := parseRecommendedPolicies(yamls)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]recommendedPolicy)
+	for _, policy := range policies {
+		if policy.Kind == "ClusterGroup" {
+			groups[policy.Metadata.Name] = policy
+		}
+	}
+	var rules []recommendedRule
+	for _, policy := range policies {
+		if policy.Kind == "ClusterGroup" {
+			continue
+		}
+		for _, direction := range []string{"ingress", "egress"} {
+			policyRules, prefix := policy.Spec.Egress, "source"
+			if direction == "ingress" {
+				policyRules, prefix = policy.Spec.Ingress, "destination"
+			}
+			for i, rule := range policyRules {
+				r := recommendedRule{policy: policy, direction: direction, index: i, rule: rule}
+				if !r.isDeny() {
+					r.filter, r.err = ruleFilter(rule, direction == "ingress", appliedToFilter(policy, prefix), policy.Metadata.Namespace, groups)
+				}
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// evidenceColumns are the columns of the flows table used to find the flows
+// matching the recommended rules.
+var evidenceColumns = []string{
+	"flowStartSeconds",
+	"flowEndSeconds",
+	"sourceIP",
+	"destinationIP",
+	"destinationTransportPort",
+	"protocolIdentifier",
+	"sourcePodName",
+	"sourcePodNamespace",
+	"destinationPodName",
+	"destinationPodNamespace",
+	"destinationServicePortName",
+	"sourcePodLabels",
+	"destinationPodLabels",
+}
+
+func getRecommendationTime(connect *sql.DB, id string) (time.Time, error) {
+	var timeCreated time.Time
+	if err := connect.QueryRow("SELECT timeCreated FROM recommendations WHERE id = (?);", id).Scan(&timeCreated); err != nil {
+		return timeCreated, fmt.Errorf("failed to get recommendation with id %s: %v", id, err)
+	}
+	return timeCreated, nil
+}
+
+// getRecommendationEvidence returns, for each rule of the recommended
+// policies, the flows recorded before the recommendation was created which
+// match the rule.
+func getRecommendationEvidence(connect *sql.DB, id string, yamls string) (*evidenceReport, error) {
+	timeCreated, err := getRecommendationTime(connect, id)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := listRecommendedRules(yamls)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := clickhouse.GetFlowSchema(connect)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.Check(evidenceColumns); err != nil {
+		return nil, err
+	}
+	report := &evidenceReport{ID: id, TimeCreated: FormatTimestamp(timeCreated), Rules: []ruleEvidence{}}
+	timeFilter := flowFilter{condition: "flowEndSeconds <= ?", args: []interface{}{timeCreated}}
+	for _, rule := range rules {
+		evidence := ruleEvidence{Policy: policyName(rule.policy), Direction: rule.direction, Rule: rule.index, Action: rule.rule.Action}
+		if rule.isDeny() {
+			evidence.Note = "default deny rule, not derived from flows"
+		} else if rule.err != nil {
+			evidence.Note = rule.err.Error()
+		} else if err := getRuleEvidence(connect, andFilters(timeFilter, rule.filter), &evidence); err != nil {
+			return nil, fmt.Errorf("failed to get evidence for %s: %v", evidence.Policy, err)
+		}
+		report.Rules = append(report.Rules, evidence)
+	}
+	return report, nil
+}
+
+func getRuleEvidence(connect *sql.DB, filter flowFilter, evidence *ruleEvidence) error {
+	var firstSeen, lastSeen time.Time
+	query := "SELECT count(), min(flowStartSeconds), max(flowEndSeconds) FROM flows WHERE " + filter.condition
+	err := connect.QueryRow(query, filter.args...).Scan(&evidence.FlowCount, &firstSeen, &lastSeen)
+	if err != nil {
+		return err
+	}
+	if evidence.FlowCount == 0 {
+		return nil
+	}
+	evidence.FirstSeen = FormatTimestamp(firstSeen)
+	evidence.LastSeen = FormatTimestamp(lastSeen)
+	evidence.Samples, err = getSampleFlows(connect, filter, evidenceSampleFlows)
+	return err
+}
+
+// getSampleFlows returns the most recent flows matching the filter.
+func getSampleFlows(connect *sql.DB, filter flowFilter, limit int) ([]sampleFlow, error) {
+	query := `SELECT sourcePodNamespace, sourcePodName, sourceIP, destinationPodNamespace, destinationPodName,
+destinationIP, destinationServicePortName, destinationTransportPort, protocolIdentifier, flowEndSeconds
+FROM flows WHERE ` + filter.condition + " ORDER BY flowEndSeconds DESC LIMIT ?"
+	rows, err := connect.Query(query, append(filter.args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var samples []sampleFlow
+	for rows.Next() {
+		var srcNamespace, srcName, srcIP, dstNamespace, dstName, dstIP, svcPortName string
+		var port uint16
+		var protocol uint8
+		var flowEnd time.Time
+		if err := rows.Scan(&srcNamespace, &srcName, &srcIP, &dstNamespace, &dstName, &dstIP, &svcPortName, &port, &protocol, &flowEnd); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sampleFlow{
+			Source:                     flowEndpoint(srcNamespace, srcName, srcIP),
+			Destination:                flowEndpoint(dstNamespace, dstName, dstIP),
+			DestinationServicePortName: svcPortName,
+			DestinationPort:            port,
+			Protocol:                   protocolName(protocol),
+			FlowEnd:                    FormatTimestamp(flowEnd),
+		})
+	}
+	return samples, rows.Err()
+}
+
+func flowEndpoint(namespace string, name string, ip string) string {
+	if name == "" {
+		return ip
+	}
+	return namespace + "/" + name
+}
@@ -0,0 +1,223 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/clickhouse"
+)
+
+const testRecommendedPolicies = `apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-ab7fd
+  namespace: antrea-test
+spec:
+  appliedTo:
+  - podSelector:
+      matchLabels:
+        podname: perftest-a
+  egress:
+  - action: Allow
+    ports:
+    - port: 5201
+      protocol: TCP
+    to:
+    - namespaceSelector:
+        matchLabels:
+          kubernetes.io/metadata.name: antrea-test
+      podSelector:
+        matchLabels:
+          podname: perftest-b
+  - action: Allow
+    ports:
+    - port: 80
+      protocol: TCP
+    to:
+    - ipBlock:
+        cidr: 192.168.0.1/32
+  - action: Allow
+    toServices:
+    - name: perftestsvc
+      namespace: antrea-e2e
+  ingress: []
+  priority: 5
+  tier: Application
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-acnp-9juz4
+spec:
+  appliedTo:
+  - namespaceSelector:
+      matchLabels:
+        kubernetes.io/metadata.name: antrea-test
+    podSelector:
+      matchLabels:
+        podname: perftest-a
+  egress:
+  - action: Reject
+    to:
+    - podSelector: {}
+  priority: 5
+  tier: Baseline
+`
+
+func TestRuleFilter(t *testing.T) {
+	policies, err := parseRecommendedPolicies(testRecommendedPolicies)
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	policy := policies[0]
+	appliedTo := appliedToFilter(policy, "source")
+
+	testCases := []struct {
+		name              string
+		rule              policyRule
+		expectedCondition string
+		expectedArgs      []interface{}
+	}{
+		{
+			name:              "Pod peer",
+			rule:              policy.Spec.Egress[0],
+			expectedCondition: "((sourcePodNamespace != '') AND (sourcePodNamespace = ?) AND (JSONExtractString(sourcePodLabels, ?) = ?)) AND ((destinationPodNamespace != '') AND (destinationPodNamespace = ?) AND (JSONExtractString(destinationPodLabels, ?) = ?)) AND (destinationTransportPort = ? AND protocolIdentifier = ?)",
+			expectedArgs:      []interface{}{"antrea-test", "podname", "perftest-a", "antrea-test", "podname", "perftest-b", 5201, 6},
+		},
+		{
+			name:              "IPBlock peer",
+			rule:              policy.Spec.Egress[1],
+			expectedCondition: "((sourcePodNamespace != '') AND (sourcePodNamespace = ?) AND (JSONExtractString(sourcePodLabels, ?) = ?)) AND (isIPAddressInRange(destinationIP, ?)) AND (destinationTransportPort = ? AND protocolIdentifier = ?)",
+			expectedArgs:      []interface{}{"antrea-test", "podname", "perftest-a", "192.168.0.1/32", 80, 6},
+		},
+		{
+			name:              "Service peer",
+			rule:              policy.Spec.Egress[2],
+			expectedCondition: "((sourcePodNamespace != '') AND (sourcePodNamespace = ?) AND (JSONExtractString(sourcePodLabels, ?) = ?)) AND (destinationServicePortName LIKE ?)",
+			expectedArgs:      []interface{}{"antrea-test", "podname", "perftest-a", "antrea-e2e/perftestsvc:%"},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ruleFilter(tt.rule, false, appliedTo, policy.Metadata.Namespace, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCondition, filter.condition)
+			assert.Equal(t, tt.expectedArgs, filter.args)
+		})
+	}
+
+	_, err = ruleFilter(policyRule{To: []policyPeer{{Group: "cg-unknown"}}}, false, appliedTo, "", nil)
+	assert.EqualError(t, err, "unsupported ClusterGroup cg-unknown")
+}
+
+// expectFlowSchema expects the queries of clickhouse.GetFlowSchema, for a
+// flows table with the given columns.
+func expectFlowSchema(mock sqlmock.Sqlmock, version string, columns []string) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM system.tables")).WillReturnRows(sqlmock.NewRows([]string{"count()"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT value FROM theia_metadata")).WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(version))
+	rows := sqlmock.NewRows([]string{"name"})
+	for _, column := range columns {
+		rows.AddRow(column)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM system.columns")).WillReturnRows(rows)
+}
+
+func TestGetRecommendationEvidence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	id := "db2134ea-7169-46f8-b56d-d643d4751d1d"
+	timeCreated := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	firstSeen := timeCreated.Add(-2 * time.Hour)
+	lastSeen := timeCreated.Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT timeCreated FROM recommendations WHERE id = (?);")).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated"}).AddRow(timeCreated))
+	expectFlowSchema(mock, "1", clickhouse.FlowColumns)
+	// Pod peer
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(), min(flowStartSeconds), max(flowEndSeconds) FROM flows WHERE")).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "min", "max"}).AddRow(uint64(2), firstSeen, lastSeen))
+	mock.ExpectQuery("SELECT sourcePodNamespace, .* LIMIT \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"sourcePodNamespace", "sourcePodName", "sourceIP", "destinationPodNamespace", "destinationPodName",
+			"destinationIP", "destinationServicePortName", "destinationTransportPort", "protocolIdentifier", "flowEndSeconds"}).
+			AddRow("antrea-test", "perftest-a", "10.10.0.4", "antrea-test", "perftest-b", "10.10.0.5", "", uint16(5201), uint8(6), lastSeen))
+	// IPBlock peer, without matching flows
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(), min(flowStartSeconds), max(flowEndSeconds) FROM flows WHERE")).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "min", "max"}).AddRow(uint64(0), time.Unix(0, 0), time.Unix(0, 0)))
+	// Service peer
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(), min(flowStartSeconds), max(flowEndSeconds) FROM flows WHERE")).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "min", "max"}).AddRow(uint64(1), lastSeen, lastSeen))
+	mock.ExpectQuery("SELECT sourcePodNamespace, .* LIMIT \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"sourcePodNamespace", "sourcePodName", "sourceIP", "destinationPodNamespace", "destinationPodName",
+			"destinationIP", "destinationServicePortName", "destinationTransportPort", "protocolIdentifier", "flowEndSeconds"}).
+			AddRow("antrea-test", "perftest-a", "10.10.0.4", "antrea-test", "perftest-c", "10.10.0.6", "antrea-e2e/perftestsvc:5201", uint16(5201), uint8(6), lastSeen))
+
+	report, err := getRecommendationEvidence(db, id, testRecommendedPolicies)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, id, report.ID)
+	assert.Equal(t, "2022-08-01 12:00:00", report.TimeCreated)
+	require.Len(t, report.Rules, 4)
+	assert.Equal(t, ruleEvidence{
+		Policy:    "NetworkPolicy/antrea-test/recommend-allow-anp-ab7fd",
+		Direction: "egress",
+		Rule:      0,
+		Action:    "Allow",
+		FlowCount: 2,
+		FirstSeen: "2022-08-01 10:00:00",
+		LastSeen:  "2022-08-01 11:00:00",
+		Samples: []sampleFlow{{
+			Source:          "antrea-test/perftest-a",
+			Destination:     "antrea-test/perftest-b",
+			DestinationPort: 5201,
+			Protocol:        "TCP",
+			FlowEnd:         "2022-08-01 11:00:00",
+		}},
+	}, report.Rules[0])
+	assert.Equal(t, uint64(0), report.Rules[1].FlowCount)
+	assert.Empty(t, report.Rules[1].FirstSeen)
+	assert.Equal(t, "antrea-e2e/perftestsvc:5201", report.Rules[2].Samples[0].DestinationServicePortName)
+	assert.Equal(t, "ClusterNetworkPolicy/recommend-reject-acnp-9juz4", report.Rules[3].Policy)
+	assert.Equal(t, "default deny rule, not derived from flows", report.Rules[3].Note)
+}
+
+func TestGetRecommendationEvidenceIncompatibleSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	id := "db2134ea-7169-46f8-b56d-d643d4751d1d"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT timeCreated FROM recommendations WHERE id = (?);")).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated"}).AddRow(time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)))
+	var columns []string
+	for _, column := range clickhouse.FlowColumns {
+		if column != "sourcePodLabels" && column != "destinationPodLabels" {
+			columns = append(columns, column)
+		}
+	}
+	expectFlowSchema(mock, "0", columns)
+
+	_, err = getRecommendationEvidence(db, id, testRecommendedPolicies)
+	assert.ErrorIs(t, err, clickhouse.ErrIncompatibleFlowSchema)
+	assert.ErrorContains(t, err, "missing columns destinationPodLabels, sourcePodLabels")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
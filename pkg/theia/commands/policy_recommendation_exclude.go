@@ -0,0 +1,340 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"antrea.io/theia/pkg/util/executor"
+)
+
+// exclusionTable stores the recommended policies rejected by users. Their
+// rules are not recommended again by the subsequent jobs. Exclusions are kept
+// when the jobs which recommended the policies are deleted.
+const exclusionTable = "recommendation_exclusions"
+
+// policyExclusion is a recommended policy rejected by users.
+type policyExclusion struct {
+	// ID is the ID of the job which recommended the policy.
+	ID          string
+	PolicyName  string
+	Kind        string
+	Namespace   string
+	TimeCreated time.Time
+	// Policy is the recommended policy, as a JSON document.
+	Policy string
+}
+
+// policyRecommendationExcludeCmd represents the policy-recommendation exclude command
+var policyRecommendationExcludeCmd = &cobra.Command{
+	Use:   "exclude",
+	Short: "Exclude recommended policies from future recommendations",
+	Long: `Record that recommended policies were rejected, so that their rules are not
+recommended again by the subsequent policy recommendation jobs. The policies
+are identified by the ID of the job which recommended them and their name.
+The rules of the policies are removed from the policies recommended for the
+same Pods, and policies left without rules are not recommended. Exclusions
+are kept when the jobs are deleted, and are removed with --remove.`,
+	Args: cobra.RangeArgs(0, 1),
+	Example: `
+Exclude the policy recommend-allow-anp-nq9tk recommended by the job with ID e998433e-accb-4888-9fc8-06563f073e86
+$ theia policy-recommendation exclude e998433e-accb-4888-9fc8-06563f073e86 --policy recommend-allow-anp-nq9tk
+Stop excluding the policy recommend-allow-anp-nq9tk
+$ theia policy-recommendation exclude e998433e-accb-4888-9fc8-06563f073e86 --policy recommend-allow-anp-nq9tk --remove
+List the excluded policies
+$ theia policy-recommendation exclude --list
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoID, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		if recoID == "" && len(args) == 1 {
+			recoID = args[0]
+		}
+		policyNames, err := cmd.Flags().GetStringSlice("policy")
+		if err != nil {
+			return err
+		}
+		list, err := cmd.Flags().GetBool("list")
+		if err != nil {
+			return err
+		}
+		remove, err := cmd.Flags().GetBool("remove")
+		if err != nil {
+			return err
+		}
+		if list {
+			if recoID != "" || len(policyNames) > 0 || remove {
+				return fmt.Errorf("list cannot be used together with id, policy or remove")
+			}
+		} else {
+			if err := ParseRecommendationID(recoID); err != nil {
+				return err
+			}
+			if len(policyNames) == 0 {
+				return fmt.Errorf("at least one policy should be provided with --policy")
+			}
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+		if portForward != nil {
+			defer portForward.Stop()
+		}
+		if err != nil {
+			return err
+		}
+		defer connect.Close()
+
+		if list {
+			exclusions, err := getExclusions(connect)
+			if err != nil {
+				return err
+			}
+			table := [][]string{
+				{"ExclusionTime", "ID", "Policy", "Kind", "Namespace"},
+			}
+			for _, exclusion := range exclusions {
+				table = append(table, []string{
+					FormatTimestamp(exclusion.TimeCreated),
+					exclusion.ID,
+					exclusion.PolicyName,
+					exclusion.Kind,
+					exclusion.Namespace,
+				})
+			}
+			TableOutput(table)
+			return nil
+		}
+		if remove {
+			if err := removeExclusions(connect, recoID, policyNames); err != nil {
+				return err
+			}
+			fmt.Fprintf(infoOut(cmd), "Successfully removed the exclusion of %d policies recommended by job %s\n", len(policyNames), recoID)
+			return nil
+		}
+		recoResult, err := getResultFromClickHouse(connect, recoID, nil)
+		if err != nil {
+			return err
+		}
+		exclusions, err := newExclusions(recoID, recoResult, policyNames, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := saveExclusions(connect, exclusions); err != nil {
+			return fmt.Errorf("%v, upgrade ClickHouse with the Theia Helm chart to exclude policies", err)
+		}
+		fmt.Fprintf(infoOut(cmd), "Successfully excluded %d policies recommended by job %s\n", len(exclusions), recoID)
+		return nil
+	},
+}
+
+// newExclusions returns the exclusions of the policies with the given names,
+// among the recommended policies of a job.
+func newExclusions(id string, recoResult string, policyNames []string, now time.Time) ([]policyExclusion, error) {
+	docs, policies, err := decodeRecommendedPolicies(recoResult)
+	if err != nil {
+		return nil, err
+	}
+	var exclusions []policyExclusion
+	for _, name := range policyNames {
+		found := false
+		for i, policy := range policies {
+			if policy.Metadata.Name != name {
+				continue
+			}
+			data, err := k8syaml.ToJSON([]byte(docs[i]))
+			if err != nil {
+				return nil, fmt.Errorf("error when converting the recommended policies to JSON: %v", err)
+			}
+			exclusions = append(exclusions, policyExclusion{
+				ID:          id,
+				PolicyName:  name,
+				Kind:        policy.Kind,
+				Namespace:   policy.Metadata.Namespace,
+				TimeCreated: now.UTC().Truncate(time.Second),
+				Policy:      string(data),
+			})
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("could not find the policy %s in the result of policy recommendation job %s", name, id)
+		}
+	}
+	return exclusions, nil
+}
+
+func saveExclusions(connect *sql.DB, exclusions []policyExclusion) error {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return fmt.Errorf("failed to record the excluded policies: %v", err)
+	}
+	tx, err := connect.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record the excluded policies: %v", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO " + exclusionTable + " (id, policyName, kind, namespace, timeCreated, policy) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record the excluded policies: %v", err)
+	}
+	defer stmt.Close()
+	for _, exclusion := range exclusions {
+		if _, err := stmt.Exec(exclusion.ID, exclusion.PolicyName, exclusion.Kind, exclusion.Namespace, exclusion.TimeCreated, exclusion.Policy); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record the excluded policies: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record the excluded policies: %v", err)
+	}
+	return nil
+}
+
+// getExclusions returns the excluded policies, in the order they were
+// excluded. Policies excluded several times, which are only deduplicated when
+// ClickHouse merges the parts of the table, are returned once.
+func getExclusions(connect *sql.DB) ([]policyExclusion, error) {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get the excluded policies: %v", err)
+	}
+	rows, err := connect.Query("SELECT id, policyName, kind, namespace, timeCreated, policy FROM " + exclusionTable + " ORDER BY timeCreated, id, policyName")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the excluded policies: %v", err)
+	}
+	defer rows.Close()
+	var exclusions []policyExclusion
+	seen := make(map[[2]string]bool)
+	for rows.Next() {
+		var exclusion policyExclusion
+		if err := rows.Scan(&exclusion.ID, &exclusion.PolicyName, &exclusion.Kind, &exclusion.Namespace, &exclusion.TimeCreated, &exclusion.Policy); err != nil {
+			return nil, fmt.Errorf("failed to get the excluded policies: %v", err)
+		}
+		key := [2]string{exclusion.ID, exclusion.PolicyName}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		exclusions = append(exclusions, exclusion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get the excluded policies: %v", err)
+	}
+	return exclusions, nil
+}
+
+func removeExclusions(connect *sql.DB, id string, policyNames []string) error {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return fmt.Errorf("failed to remove the excluded policies: %v", err)
+	}
+	query := "ALTER TABLE " + exclusionTable + "_local ON CLUSTER '{cluster}' DELETE WHERE id = (?) AND policyName = (?);"
+	for _, name := range policyNames {
+		if _, err := connect.Exec(query, id, name); err != nil {
+			return fmt.Errorf("failed to remove the exclusion of policy %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// exclusionArgs returns the arguments passing the excluded policies to the
+// policy recommendation job, as a JSON list.
+func exclusionArgs(exclusions []policyExclusion) ([]string, error) {
+	if len(exclusions) == 0 {
+		return nil, nil
+	}
+	policies := make([]json.RawMessage, len(exclusions))
+	for i, exclusion := range exclusions {
+		policies[i] = json.RawMessage(exclusion.Policy)
+	}
+	data, err := json.Marshal(policies)
+	if err != nil {
+		return nil, fmt.Errorf("error when encoding the excluded policies: %v", err)
+	}
+	return []string{"--exclusions", string(data)}, nil
+}
+
+// appendExclusionArgs appends the arguments passing the excluded policies to
+// the arguments of the requested job.
+func appendExclusionArgs(connect *sql.DB, request *executor.Request) error {
+	exclusions, err := getExclusions(connect)
+	if err != nil {
+		return err
+	}
+	args, err := exclusionArgs(exclusions)
+	if err != nil {
+		return err
+	}
+	request.Args = append(request.Args, args...)
+	return nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationExcludeCmd)
+	policyRecommendationExcludeCmd.Flags().StringP(
+		"id",
+		"i",
+		"",
+		"ID of the policy recommendation job which recommended the policies.",
+	)
+	policyRecommendationExcludeCmd.Flags().StringSliceP(
+		"policy",
+		"p",
+		nil,
+		"Comma-separated list of the names of the excluded policies.",
+	)
+	policyRecommendationExcludeCmd.Flags().BoolP(
+		"list",
+		"l",
+		false,
+		"List the excluded policies.",
+	)
+	policyRecommendationExcludeCmd.Flags().Bool(
+		"remove",
+		false,
+		"Stop excluding the policies.",
+	)
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/util/executor"
+)
+
+const (
+	testExclusionID     = "e998433e-accb-4888-9fc8-06563f073e86"
+	testExclusionResult = `apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-nq9tk
+  namespace: ns-a
+spec:
+  appliedTo:
+  - podSelector:
+      matchLabels:
+        app: a
+  egress:
+  - action: Allow
+    ports:
+    - port: 80
+      protocol: TCP
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterGroup
+metadata:
+  name: cg-ns-a-svc-a
+spec:
+  serviceReference:
+    name: svc-a
+    namespace: ns-a
+`
+	testExclusionPolicy = `{"apiVersion":"crd.antrea.io/v1alpha1","kind":"NetworkPolicy","metadata":{"name":"recommend-allow-anp-nq9tk","namespace":"ns-a"},"spec":{"appliedTo":[{"podSelector":{"matchLabels":{"app":"a"}}}],"egress":[{"action":"Allow","ports":[{"port":80,"protocol":"TCP"}]}]}}`
+)
+
+func TestNewExclusions(t *testing.T) {
+	now := time.Date(2022, 8, 1, 10, 0, 0, 500, time.UTC)
+	exclusions, err := newExclusions(testExclusionID, testExclusionResult, []string{"recommend-allow-anp-nq9tk"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []policyExclusion{
+		{
+			ID:          testExclusionID,
+			PolicyName:  "recommend-allow-anp-nq9tk",
+			Kind:        "NetworkPolicy",
+			Namespace:   "ns-a",
+			TimeCreated: time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC),
+			Policy:      testExclusionPolicy,
+		},
+	}, exclusions)
+
+	_, err = newExclusions(testExclusionID, testExclusionResult, []string{"recommend-allow-anp-nq9tk", "recommend-allow-anp-xxxxx"}, now)
+	assert.EqualError(t, err, "could not find the policy recommend-allow-anp-xxxxx in the result of policy recommendation job e998433e-accb-4888-9fc8-06563f073e86")
+}
+
+func TestSaveExclusions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	timeCreated := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO recommendation_exclusions (id, policyName, kind, namespace, timeCreated, policy) VALUES (?, ?, ?, ?, ?, ?)")).
+		ExpectExec().WithArgs(testExclusionID, "recommend-allow-anp-nq9tk", "NetworkPolicy", "ns-a", timeCreated, testExclusionPolicy).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, saveExclusions(db, []policyExclusion{
+		{
+			ID:          testExclusionID,
+			PolicyName:  "recommend-allow-anp-nq9tk",
+			Kind:        "NetworkPolicy",
+			Namespace:   "ns-a",
+			TimeCreated: timeCreated,
+			Policy:      testExclusionPolicy,
+		},
+	}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendExclusionArgs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	timeCreated := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	columns := []string{"id", "policyName", "kind", "namespace", "timeCreated", "policy"}
+	// The exclusion is recorded twice, until ClickHouse merges the parts of
+	// the table.
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, policyName, kind, namespace, timeCreated, policy FROM recommendation_exclusions")).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(testExclusionID, "recommend-allow-anp-nq9tk", "NetworkPolicy", "ns-a", timeCreated, testExclusionPolicy).
+			AddRow(testExclusionID, "recommend-allow-anp-nq9tk", "NetworkPolicy", "ns-a", timeCreated.Add(time.Minute), testExclusionPolicy).
+			AddRow(testExclusionID, "cg-ns-a-svc-a", "ClusterGroup", "", timeCreated, `{"kind":"ClusterGroup"}`))
+	request := &executor.Request{Args: []string{"--type", "initial"}}
+	require.NoError(t, appendExclusionArgs(db, request))
+	assert.Equal(t, []string{"--type", "initial", "--exclusions", "[" + testExclusionPolicy + `,{"kind":"ClusterGroup"}]`}, request.Args)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, policyName, kind, namespace, timeCreated, policy FROM recommendation_exclusions")).
+		WillReturnRows(sqlmock.NewRows(columns))
+	request = &executor.Request{Args: []string{"--type", "initial"}}
+	require.NoError(t, appendExclusionArgs(db, request))
+	assert.Equal(t, []string{"--type", "initial"}, request.Args)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
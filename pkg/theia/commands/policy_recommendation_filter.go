@@ -0,0 +1,185 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// policyFilter selects the recommended policies relevant to a team, by the
+// Namespaces and the labels of the Pods they are applied to, and by their
+// kind. The ClusterGroups referenced by the selected policies are kept.
+type policyFilter struct {
+	namespaces sets.String
+	// podSelector is nil when the policies are not filtered by Pod labels.
+	podSelector labels.Selector
+	// kind is anp, acnp or k8snp, or empty.
+	kind string
+}
+
+// newPolicyFilter returns the filter of the filter-namespace, filter-label
+// and kind flags, or nil if none of them is set.
+func newPolicyFilter(cmd *cobra.Command) (*policyFilter, error) {
+	namespaces, err := cmd.Flags().GetStringSlice("filter-namespace")
+	if err != nil {
+		return nil, err
+	}
+	podLabels, err := cmd.Flags().GetString("filter-label")
+	if err != nil {
+		return nil, err
+	}
+	kind, err := cmd.Flags().GetString("kind")
+	if err != nil {
+		return nil, err
+	}
+	if len(namespaces) == 0 && podLabels == "" && kind == "" {
+		return nil, nil
+	}
+	filter := &policyFilter{namespaces: sets.NewString(namespaces...), kind: kind}
+	if podLabels != "" {
+		if filter.podSelector, err = labels.Parse(podLabels); err != nil {
+			return nil, fmt.Errorf("filter-label should be a label selector, e.g. app=web: %v", err)
+		}
+	}
+	if kind != "" {
+		if err := validation.OneOf("kind", kind, "anp", "acnp", "k8snp"); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+// policyKind returns the short kind of a recommended policy, anp, acnp or
+// k8snp, or an empty string for ClusterGroups.
+func policyKind(policy recommendedPolicy) string {
+	switch {
+	case policy.Kind == "ClusterNetworkPolicy":
+		return "acnp"
+	case policy.Kind == "NetworkPolicy" && policy.APIVersion == "networking.k8s.io/v1":
+		return "k8snp"
+	case policy.Kind == "NetworkPolicy":
+		return "anp"
+	}
+	return ""
+}
+
+// appliedToSelectors returns the Namespaces and the Pod labels selected by the
+// appliedTo of a policy. The Namespace is empty when the policy may be applied
+// to Pods in any Namespace, e.g. for default deny ClusterNetworkPolicies.
+func appliedToSelectors(policy recommendedPolicy) (namespaces []string, podLabels []map[string]string) {
+	if policy.Kind == "NetworkPolicy" && policy.Spec.PodSelector != nil {
+		return []string{policy.Metadata.Namespace}, []map[string]string{selectorLabels(policy.Spec.PodSelector)}
+	}
+	for _, peer := range policy.Spec.AppliedTo {
+		namespace := policy.Metadata.Namespace
+		if peer.NamespaceSelector != nil {
+			namespace = selectorNamespace(peer.NamespaceSelector)
+		}
+		namespaces = append(namespaces, namespace)
+		podLabels = append(podLabels, selectorLabels(peer.PodSelector))
+	}
+	return namespaces, podLabels
+}
+
+// matches returns whether a policy is selected by the filter. Policies applied
+// to Pods in any Namespace match all the Namespaces, as they also apply to
+// the Pods of the team.
+func (f *policyFilter) matches(policy recommendedPolicy) bool {
+	if f.kind != "" && policyKind(policy) != f.kind {
+		return false
+	}
+	if f.namespaces.Len() == 0 && f.podSelector == nil {
+		return true
+	}
+	namespaces, podLabels := appliedToSelectors(policy)
+	for i := range namespaces {
+		if f.namespaces.Len() > 0 && namespaces[i] != "" && !f.namespaces.Has(namespaces[i]) {
+			continue
+		}
+		if f.podSelector != nil && !f.podSelector.Matches(labels.Set(podLabels[i])) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// filterRecommendedPolicies returns the recommended policies selected by the
+// filter, and the ClusterGroups they reference, as YAML documents.
+func filterRecommendedPolicies(yamls string, filter *policyFilter) (string, error) {
+	docs, policies, err := decodeRecommendedPolicies(yamls)
+	if err != nil {
+		return "", err
+	}
+	selected := make([]bool, len(policies))
+	groups := sets.NewString()
+	for i, policy := range policies {
+		if policy.Kind == "ClusterGroup" || !filter.matches(policy) {
+			continue
+		}
+		selected[i] = true
+		for _, peer := range policy.Spec.AppliedTo {
+			groups.Insert(peer.Group)
+		}
+		for _, rules := range [][]policyRule{policy.Spec.Ingress, policy.Spec.Egress} {
+			for _, rule := range rules {
+				for _, peer := range rule.From {
+					groups.Insert(peer.Group)
+				}
+				for _, peer := range rule.To {
+					groups.Insert(peer.Group)
+				}
+			}
+		}
+	}
+	var kept []string
+	for i, policy := range policies {
+		if selected[i] || policy.Kind == "ClusterGroup" && groups.Has(policy.Metadata.Name) {
+			doc := docs[i]
+			if !strings.HasSuffix(doc, "\n") {
+				doc += "\n"
+			}
+			kept = append(kept, doc)
+		}
+	}
+	return strings.Join(kept, "---\n"), nil
+}
+
+func addPolicyFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice(
+		"filter-namespace",
+		nil,
+		`Only keep the recommended policies applied to Pods in these Namespaces, comma-separated. The policies which
+may be applied to Pods in any Namespace, e.g. default deny ClusterNetworkPolicies, are kept.`,
+	)
+	cmd.Flags().String(
+		"filter-label",
+		"",
+		"Only keep the recommended policies whose appliedTo Pod selector matches this label selector, e.g. team=payments.",
+	)
+	cmd.Flags().String(
+		"kind",
+		"",
+		`Only keep the recommended policies of this kind: anp for Antrea NetworkPolicies, acnp for Antrea
+ClusterNetworkPolicies or k8snp for Kubernetes NetworkPolicies.`,
+	)
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFilteredPolicies = `apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-payments
+  namespace: payments
+spec:
+  appliedTo:
+  - podSelector:
+      matchLabels:
+        app: api
+        team: payments
+  egress:
+  - action: Allow
+    to:
+    - group: cg-orders-db
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterGroup
+metadata:
+  name: cg-orders-db
+spec:
+  serviceReference:
+    name: db
+    namespace: orders
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterGroup
+metadata:
+  name: cg-other
+spec:
+  serviceReference:
+    name: other
+    namespace: other
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-orders
+  namespace: orders
+spec:
+  podSelector:
+    matchLabels:
+      app: db
+      team: orders
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-acnp
+spec:
+  appliedTo:
+  - podSelector: {}
+  egress:
+  - action: Reject
+    to:
+    - podSelector: {}
+`
+
+func TestFilterRecommendedPolicies(t *testing.T) {
+	testCases := []struct {
+		name             string
+		args             []string
+		expectedPolicies []string
+		expectedErr      string
+	}{
+		{
+			name:             "no filter",
+			expectedPolicies: nil,
+		},
+		{
+			name:             "namespace",
+			args:             []string{"--filter-namespace", "payments"},
+			expectedPolicies: []string{"NetworkPolicy/payments/recommend-allow-anp-payments", "ClusterGroup/cg-orders-db", "ClusterNetworkPolicy/recommend-reject-acnp"},
+		},
+		{
+			name:             "namespaces",
+			args:             []string{"--filter-namespace", "payments,orders", "--kind", "anp"},
+			expectedPolicies: []string{"NetworkPolicy/payments/recommend-allow-anp-payments", "ClusterGroup/cg-orders-db"},
+		},
+		{
+			name:             "label",
+			args:             []string{"--filter-label", "team in (orders)"},
+			expectedPolicies: []string{"NetworkPolicy/orders/recommend-k8s-np-orders"},
+		},
+		{
+			name:             "kind",
+			args:             []string{"--kind", "acnp"},
+			expectedPolicies: []string{"ClusterNetworkPolicy/recommend-reject-acnp"},
+		},
+		{
+			name:        "invalid kind",
+			args:        []string{"--kind", "acn"},
+			expectedErr: `kind should be anp, acnp or k8snp, did you mean "acnp"?`,
+		},
+		{
+			name:        "invalid label",
+			args:        []string{"--filter-label", "team in payments"},
+			expectedErr: "filter-label should be a label selector",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addPolicyFilterFlags(cmd)
+			require.NoError(t, cmd.Flags().Parse(tc.args))
+			filter, err := newPolicyFilter(cmd)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expectedPolicies == nil {
+				assert.Nil(t, filter)
+				return
+			}
+			filtered, err := filterRecommendedPolicies(testFilteredPolicies, filter)
+			require.NoError(t, err)
+			policies, err := parseRecommendedPolicies(filtered)
+			require.NoError(t, err)
+			var names []string
+			for _, policy := range policies {
+				names = append(names, policyName(policy))
+			}
+			assert.Equal(t, tc.expectedPolicies, names)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// jobSpecExcludedFlags are the flags of the run command which do not describe
+// the job, in addition to manifestExcludedFlags, and cannot be set in a job
+// spec file.
+var jobSpecExcludedFlags = map[string]bool{
+	"config":          true,
+	"theia-namespace": true,
+	"from-manifest":   true,
+}
+
+// setFlagsFromJobSpec sets the flags which are not set on the command line from
+// the job spec file given with job-spec, if the command has this flag. The job
+// spec file maps the names of the flags configuring the job to their values,
+// e.g. "type: initial", "start-time: 2022-01-01 00:00:00" or "allow-namespaces:
+// [kube-system]". Lists are JSON-encoded for the flags which expect JSON, e.g.
+// ns-allow-list. It must be called before the flags are set from the
+// environment and the config file, so that the job spec takes precedence over
+// them.
+func setFlagsFromJobSpec(flags *pflag.FlagSet) error {
+	specFlag := flags.Lookup("job-spec")
+	if specFlag == nil || specFlag.Value.String() == "" {
+		return nil
+	}
+	path := specFlag.Value.String()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error when reading job spec file: %v", err)
+	}
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("error when parsing job spec file %s: %v", path, err)
+	}
+	names := make([]string, 0, len(spec))
+	for name := range spec {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		if flag == nil || name == "job-spec" || manifestExcludedFlags[name] || jobSpecExcludedFlags[name] {
+			return fmt.Errorf("%s cannot be set in job spec file %s", name, path)
+		}
+		if flag.Changed || spec[name] == nil {
+			continue
+		}
+		value, err := jobSpecFlagValue(flag, spec[name])
+		if err != nil {
+			return fmt.Errorf("invalid value for %s in job spec file %s: %v", name, path, err)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s in job spec file %s: %v", value, name, path, err)
+		}
+	}
+	return nil
+}
+
+// jobSpecFlagValue converts a value of the job spec file to the string value of
+// the flag, like the values of the config file, except for the lists given to
+// string flags, which are JSON-encoded.
+func jobSpecFlagValue(flag *pflag.Flag, value interface{}) (string, error) {
+	if items, ok := value.([]interface{}); ok && flag.Value.Type() == "string" {
+		for i := range items {
+			items[i] = fmt.Sprint(items[i])
+		}
+		data, err := json.Marshal(items)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return configFileFlagValue(flag, value)
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetFlagsFromJobSpec(t *testing.T) {
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("job-spec", "", "")
+		flags.String("type", "initial", "")
+		flags.String("start-time", "", "")
+		flags.String("end-time", "", "")
+		flags.String("ns-allow-list", "", "")
+		flags.StringSlice("allow-namespaces", nil, "")
+		flags.Int("executor-instances", 1, "")
+		flags.String("driver-memory", "512M", "")
+		flags.Bool("wait", false, "")
+		return flags
+	}
+	writeJobSpec := func(t *testing.T, spec string) string {
+		path := filepath.Join(t.TempDir(), "jobspec.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(spec), 0600))
+		return path
+	}
+
+	t.Run("flags from job spec", func(t *testing.T) {
+		path := writeJobSpec(t, `type: subsequent
+start-time: 2022-01-01 00:00:00
+end-time: "2022-01-31 23:59:59"
+ns-allow-list: [kube-system, flow-visibility]
+allow-namespaces: [kube-system, flow-visibility]
+executor-instances: 4
+`)
+		flags := newFlags()
+		require.NoError(t, flags.Parse([]string{"--job-spec", path}))
+		require.NoError(t, setFlagsFromJobSpec(flags))
+		recoType, _ := flags.GetString("type")
+		assert.Equal(t, "subsequent", recoType)
+		startTime, _ := flags.GetString("start-time")
+		assert.Equal(t, "2022-01-01 00:00:00", startTime)
+		endTime, _ := flags.GetString("end-time")
+		assert.Equal(t, "2022-01-31 23:59:59", endTime)
+		nsAllowList, _ := flags.GetString("ns-allow-list")
+		assert.Equal(t, `["kube-system","flow-visibility"]`, nsAllowList)
+		allowNamespaces, _ := flags.GetStringSlice("allow-namespaces")
+		assert.Equal(t, []string{"kube-system", "flow-visibility"}, allowNamespaces)
+		executorInstances, _ := flags.GetInt("executor-instances")
+		assert.Equal(t, 4, executorInstances)
+		driverMemory, _ := flags.GetString("driver-memory")
+		assert.Equal(t, "512M", driverMemory)
+	})
+
+	t.Run("command line takes precedence", func(t *testing.T) {
+		path := writeJobSpec(t, "type: subsequent\ndriver-memory: 2G\n")
+		flags := newFlags()
+		require.NoError(t, flags.Parse([]string{"--job-spec", path, "--type", "initial"}))
+		require.NoError(t, setFlagsFromJobSpec(flags))
+		recoType, _ := flags.GetString("type")
+		assert.Equal(t, "initial", recoType)
+		driverMemory, _ := flags.GetString("driver-memory")
+		assert.Equal(t, "2G", driverMemory)
+	})
+
+	t.Run("no job spec", func(t *testing.T) {
+		flags := newFlags()
+		require.NoError(t, setFlagsFromJobSpec(flags))
+		assert.False(t, flags.Lookup("type").Changed)
+	})
+
+	for name, tc := range map[string]struct {
+		spec        string
+		expectedErr string
+	}{
+		"unknown flag":  {"type: initial\nexecutor-count: 4\n", "executor-count cannot be set in job spec file"},
+		"excluded flag": {"wait: true\n", "wait cannot be set in job spec file"},
+		"invalid value": {"executor-instances: four\n", `invalid value "four" for executor-instances in job spec file`},
+		"invalid file":  {"type: [initial\n", "error when parsing job spec file"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			flags := newFlags()
+			require.NoError(t, flags.Parse([]string{"--job-spec", writeJobSpec(t, tc.spec)}))
+			assert.ErrorContains(t, setFlagsFromJobSpec(flags), tc.expectedErr)
+		})
+	}
+}
@@ -17,14 +17,13 @@ package commands
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/kubernetes"
 
 	"antrea.io/theia/pkg/theia/commands/config"
-	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
+	"antrea.io/theia/pkg/util/executor"
 )
 
 type policyRecommendationRow struct {
@@ -35,11 +34,11 @@ type policyRecommendationRow struct {
 // policyRecommendationListCmd represents the policy-recommendation list command
 var policyRecommendationListCmd = &cobra.Command{
 	Use:     "list",
-	Short:   "List all policy recommendation Spark jobs",
-	Long:    `List all policy recommendation Spark jobs with name, creation time and status.`,
+	Short:   "List all policy recommendation jobs",
+	Long:    `List all policy recommendation jobs with name, creation time, backend and status.`,
 	Aliases: []string{"ls"},
 	Example: `
-List all policy recommendation Spark jobs
+List all policy recommendation jobs
 $ theia policy-recommendation list
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -61,87 +60,89 @@ $ theia policy-recommendation list
 				return err
 			}
 		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
 		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
 		if err != nil {
 			return err
 		}
 
-		err = PolicyRecoPreCheck(clientset)
+		// The Spark Operator is not checked, as jobs may run on the k8s-job
+		// backend.
+		err = CheckClickHousePod(clientset)
 		if err != nil {
 			return err
 		}
 
-		sparkApplicationList := &sparkv1.SparkApplicationList{}
-		err = clientset.CoreV1().RESTClient().Get().
-			AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
-			Namespace(config.FlowVisibilityNS).
-			Resource("sparkapplications").
-			Do(context.TODO()).Into(sparkApplicationList)
+		jobs, err := executor.ListJobs(context.TODO(), executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}))
 		if err != nil {
 			return err
 		}
 
-		completedPolicyRecommendationList, err := getCompletedPolicyRecommendationList(clientset, kubeconfig, endpoint, useClusterIP)
+		completedPolicyRecommendationList, err := getCompletedPolicyRecommendationList(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
 
 		if err != nil {
 			return err
 		}
 
-		sparkApplicationTable := [][]string{
-			{"CreationTime", "CompletionTime", "ID", "Status"},
+		jobTable := [][]string{
+			{"CreationTime", "CompletionTime", "ID", "Backend", "Status"},
 		}
 		idMap := make(map[string]bool)
-		for _, sparkApplication := range sparkApplicationList.Items {
-			id := sparkApplication.ObjectMeta.Name[3:]
-			idMap[id] = true
-			sparkApplicationTable = append(sparkApplicationTable,
+		for _, job := range jobs {
+			idMap[job.ID] = true
+			jobTable = append(jobTable,
 				[]string{
-					FormatTimestamp(sparkApplication.ObjectMeta.CreationTimestamp.Time),
-					FormatTimestamp(sparkApplication.Status.TerminationTime.Time),
-					id,
-					strings.TrimSpace(string(sparkApplication.Status.AppState.State)),
+					FormatTimestamp(job.CreationTime),
+					FormatTimestamp(job.CompletionTime),
+					job.ID,
+					job.Backend,
+					job.State,
 				})
 		}
 
 		for _, completedPolicyRecommendation := range completedPolicyRecommendationList {
 			if _, ok := idMap[completedPolicyRecommendation.id]; !ok {
 				idMap[completedPolicyRecommendation.id] = true
-				sparkApplicationTable = append(sparkApplicationTable,
+				jobTable = append(jobTable,
 					[]string{
 						"N/A",
 						FormatTimestamp(completedPolicyRecommendation.timeComplete),
 						completedPolicyRecommendation.id,
+						"N/A",
 						"COMPLETED",
 					})
 			}
 		}
 
-		TableOutput(sparkApplicationTable)
+		TableOutput(jobTable)
 		return nil
 	},
 }
 
-func getCompletedPolicyRecommendationList(clientset kubernetes.Interface, kubeconfig string, endpoint string, useClusterIP bool) (completedPolicyRecommendationList []policyRecommendationRow, err error) {
-	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, useClusterIP)
+func getCompletedPolicyRecommendationList(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool) (completedPolicyRecommendationList []policyRecommendationRow, err error) {
+	client, portForward, err := setupClickHouseClient(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
 	if portForward != nil {
 		defer portForward.Stop()
 	}
 	if err != nil {
 		return completedPolicyRecommendationList, err
 	}
-	query := "SELECT timeCreated, id FROM recommendations;"
-	rows, err := connect.Query(query)
-	if err != nil {
+	defer client.Close()
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
 		return completedPolicyRecommendationList, fmt.Errorf("failed to get recommendation jobs: %v", err)
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var row policyRecommendationRow
-		err := rows.Scan(&row.timeComplete, &row.id)
-		if err != nil {
-			return completedPolicyRecommendationList, fmt.Errorf("err when scanning recommendations row %v", err)
-		}
-		completedPolicyRecommendationList = append(completedPolicyRecommendationList, row)
+	recommendations, err := client.ListRecommendations(context.TODO())
+	if err != nil {
+		return completedPolicyRecommendationList, err
+	}
+	for _, recommendation := range recommendations {
+		completedPolicyRecommendationList = append(completedPolicyRecommendationList, policyRecommendationRow{
+			timeComplete: recommendation.TimeCreated,
+			id:           recommendation.ID,
+		})
 	}
 	return completedPolicyRecommendationList, nil
 }
@@ -0,0 +1,152 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+const (
+	// sparkDriverContainer is the container of the driver Pods created by
+	// the Spark Operator.
+	sparkDriverContainer = "spark-kubernetes-driver"
+	// k8sJobContainer is the container of the Pods of the Kubernetes Jobs
+	// run with the k8s-job backend.
+	k8sJobContainer = "policy-recommendation"
+)
+
+// policyRecommendationLogsCmd represents the policy-recommendation logs command
+var policyRecommendationLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Print the logs of the driver of a policy recommendation job",
+	Long: `Print the logs of the Spark driver Pod of a policy recommendation job by ID.
+With the k8s-job backend, the logs of the Pod of the Kubernetes Job are printed.
+The logs are no longer available once the driver Pod has been deleted.`,
+	Args: cobra.RangeArgs(0, 1),
+	Example: `
+Print the logs of the driver of job with ID e998433e-accb-4888-9fc8-06563f073e86
+$ theia policy-recommendation logs e998433e-accb-4888-9fc8-06563f073e86
+Stream the logs of the driver of job with ID e998433e-accb-4888-9fc8-06563f073e86 until it terminates
+$ theia policy-recommendation logs --id e998433e-accb-4888-9fc8-06563f073e86 --follow
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoID, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		if recoID == "" && len(args) == 1 {
+			recoID = args[0]
+		}
+		err = ParseRecommendationID(recoID)
+		if err != nil {
+			return err
+		}
+		follow, err := cmd.Flags().GetBool("follow")
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		ctx, cancel := newInterruptContext()
+		defer cancel()
+		pod, container, err := getDriverPod(ctx, clientset, recoID)
+		if err != nil {
+			return err
+		}
+		return printDriverLogs(ctx, clientset, pod, container, follow, os.Stdout)
+	},
+}
+
+// getDriverPod returns the driver Pod of the policy recommendation job and
+// the name of its container running Spark.
+func getDriverPod(ctx context.Context, clientset kubernetes.Interface, recoID string) (*v1.Pod, string, error) {
+	pod, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).Get(ctx, "pr-"+recoID+"-driver", metav1.GetOptions{})
+	if err == nil {
+		return pod, sparkDriverContainer, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, "", fmt.Errorf("error when getting the driver Pod of policy recommendation job %s: %v", recoID, err)
+	}
+	// Jobs run with the k8s-job backend have a single Pod, unless it was
+	// recreated, e.g. after being evicted, in which case the most recent
+	// one is used.
+	pods, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", executor.RecommendationIDLabel, recoID),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error when listing the Pods of policy recommendation job %s: %v", recoID, err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, "", fmt.Errorf("could not find the driver Pod of policy recommendation job %s, the job may not have started yet or its Pods may have been deleted", recoID)
+	}
+	latest := &pods.Items[0]
+	for i := range pods.Items {
+		if latest.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			latest = &pods.Items[i]
+		}
+	}
+	return latest, k8sJobContainer, nil
+}
+
+// printDriverLogs copies the logs of the container of the driver Pod to out.
+// With follow, it returns once the container has terminated or ctx is done.
+func printDriverLogs(ctx context.Context, clientset kubernetes.Interface, pod *v1.Pod, container string, follow bool, out io.Writer) error {
+	stream, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+		Container: container,
+		Follow:    follow,
+	}).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("error when getting the logs of Pod %s: %v", pod.Name, err)
+	}
+	defer stream.Close()
+	if _, err := io.Copy(out, stream); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error when reading the logs of Pod %s: %v", pod.Name, err)
+	}
+	return nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationLogsCmd)
+	policyRecommendationLogsCmd.Flags().StringP(
+		"id",
+		"i",
+		"",
+		"ID of the policy recommendation Spark job.",
+	)
+	policyRecommendationLogsCmd.Flags().BoolP(
+		"follow",
+		"f",
+		false,
+		"Stream the logs until the driver terminates.",
+	)
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+func TestGetDriverPod(t *testing.T) {
+	const id = "e998433e-accb-4888-9fc8-06563f073e86"
+	newK8sJobPod := func(name string, creationTime time.Time) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         config.FlowVisibilityNS,
+				Labels:            map[string]string{executor.RecommendationIDLabel: id},
+				CreationTimestamp: metav1.NewTime(creationTime),
+			},
+		}
+	}
+	testCases := []struct {
+		name              string
+		objects           []runtime.Object
+		expectedPod       string
+		expectedContainer string
+		expectedError     string
+	}{
+		{
+			name: "Spark driver Pod",
+			objects: []runtime.Object{&v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pr-" + id + "-driver",
+					Namespace: config.FlowVisibilityNS,
+				},
+			}},
+			expectedPod:       "pr-" + id + "-driver",
+			expectedContainer: sparkDriverContainer,
+		},
+		{
+			name: "most recent Pod of Kubernetes Job",
+			objects: []runtime.Object{
+				newK8sJobPod("pr-"+id+"-abcde", time.Date(2022, 8, 1, 11, 0, 0, 0, time.UTC)),
+				newK8sJobPod("pr-"+id+"-fghij", time.Date(2022, 8, 1, 11, 5, 0, 0, time.UTC)),
+			},
+			expectedPod:       "pr-" + id + "-fghij",
+			expectedContainer: k8sJobContainer,
+		},
+		{
+			name:          "no driver Pod",
+			expectedError: "could not find the driver Pod of policy recommendation job e998433e-accb-4888-9fc8-06563f073e86",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			pod, container, err := getDriverPod(context.TODO(), clientset, id)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPod, pod.Name)
+			assert.Equal(t, tt.expectedContainer, container)
+		})
+	}
+}
+
+func TestPrintDriverLogs(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-e998433e-accb-4888-9fc8-06563f073e86-driver",
+			Namespace: config.FlowVisibilityNS,
+		},
+	}
+	clientset := fake.NewSimpleClientset(pod)
+	var buf bytes.Buffer
+	err := printDriverLogs(context.TODO(), clientset, pod, sparkDriverContainer, false, &buf)
+	require.NoError(t, err)
+	// The fake clientset always returns these logs.
+	assert.Equal(t, "fake logs", buf.String())
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+)
+
+// manifestTable stores the reproducibility manifests of the policy
+// recommendation jobs, as JSON documents. Manifests are kept when jobs are
+// deleted, so that the jobs can still be rerun.
+const manifestTable = "recommendation_manifests"
+
+// manifestExcludedFlags are the flags which do not affect the result of the
+// job, and are not recorded in manifests.
+var manifestExcludedFlags = map[string]bool{
+	"help":                true,
+	"kubeconfig":          true,
+	"verbose":             true,
+	"fault-injection":     true,
+	"clickhouse-endpoint": true,
+	"clickhouse-ca-cert":  true,
+	"use-cluster-ip":      true,
+	"wait":                true,
+	"poll-interval":       true,
+	"timeout":             true,
+	"dry-run":             true,
+	"file":                true,
+	"job-spec":            true,
+}
+
+// manifestParameters returns the effective values of the flags of a command.
+func manifestParameters(flags *pflag.FlagSet) map[string]string {
+	parameters := make(map[string]string)
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if !manifestExcludedFlags[flag.Name] {
+			parameters[flag.Name] = flag.Value.String()
+		}
+	})
+	return parameters
+}
+
+// newJobManifest returns the manifest of a job submitted with the given
+// request. The flow schema version and the data watermark are read from
+// ClickHouse.
+func newJobManifest(connect *sql.DB, backend string, request *executor.Request, parameters map[string]string, now time.Time) (*policyrecommendation.Manifest, error) {
+	manifest := &policyrecommendation.Manifest{
+		ID:              request.ID,
+		TimeCreated:     now.UTC().Truncate(time.Second),
+		Backend:         backend,
+		Parameters:      parameters,
+		JobArgs:         request.Args,
+		Resources:       request.Resources,
+		ArtifactsSecret: request.ArtifactsSecret,
+		Image:           request.Resources.Image,
+	}
+	if manifest.Image == "" {
+		manifest.Image = config.SparkImage
+	}
+	schema, err := clickhouse.GetFlowSchema(connect)
+	if err != nil {
+		return nil, err
+	}
+	manifest.FlowSchemaVersion = schema.Version
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get the data watermark: %v", err)
+	}
+	if err := connect.QueryRow("SELECT max(timeInserted) FROM flows").Scan(&manifest.DataWatermark); err != nil {
+		return nil, fmt.Errorf("failed to get the data watermark: %v", err)
+	}
+	manifest.DataWatermark = manifest.DataWatermark.UTC()
+	return manifest, nil
+}
+
+// saveJobManifest records the manifest of a job. A later manifest with the
+// same ID replaces it, e.g. once the image digest is known.
+func saveJobManifest(connect *sql.DB, manifest *policyrecommendation.Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return fmt.Errorf("failed to record the manifest of job %s: %v", manifest.ID, err)
+	}
+	tx, err := connect.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record the manifest of job %s: %v", manifest.ID, err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO " + manifestTable + " (id, timeUpdated, manifest) VALUES (?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record the manifest of job %s: %v", manifest.ID, err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(manifest.ID, time.Now().UTC(), string(data)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record the manifest of job %s: %v", manifest.ID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record the manifest of job %s: %v", manifest.ID, err)
+	}
+	return nil
+}
+
+// getJobManifest returns the latest manifest of a job.
+func getJobManifest(connect *sql.DB, id string) (*policyrecommendation.Manifest, error) {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get the manifest of job %s: %v", id, err)
+	}
+	var data string
+	query := "SELECT manifest FROM " + manifestTable + " WHERE id = ? ORDER BY timeUpdated DESC LIMIT 1"
+	if err := connect.QueryRow(query, id).Scan(&data); err == sql.ErrNoRows {
+		return nil, fmt.Errorf("could not find the manifest of job %s, jobs submitted by older versions of Theia or through theia-manager have no manifest", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get the manifest of job %s: %v", id, err)
+	}
+	var manifest policyrecommendation.Manifest
+	if err := json.Unmarshal([]byte(data), &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of job %s: %v", id, err)
+	}
+	return &manifest, nil
+}
+
+// jobPodSelectors select the Pods running the job of each backend. The
+// digest of the image is read from the driver Pod.
+var jobPodSelectors = map[string]string{
+	executor.SparkOperatorBackend: "spark-role=driver,sparkoperator.k8s.io/app-name=pr-%s",
+	executor.K8sJobBackend:        "job-name=pr-%s",
+}
+
+// resolveJobImageDigest returns the digest of the image which ran the job, or
+// an empty string if the Pod of the job no longer exists.
+func resolveJobImageDigest(clientset kubernetes.Interface, backend string, id string) (string, error) {
+	selector, ok := jobPodSelectors[backend]
+	if !ok {
+		return "", nil
+	}
+	pods, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).List(context.TODO(), metav1.ListOptions{LabelSelector: fmt.Sprintf(selector, id)})
+	if err != nil {
+		return "", fmt.Errorf("error when listing the Pods of job %s: %v", id, err)
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if digest := policyrecommendation.ImageDigest(status.ImageID); digest != "" {
+				return digest, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// recordImageDigest completes the manifest of a job with the digest of its
+// image, once the job ran.
+func recordImageDigest(clientset kubernetes.Interface, connect *sql.DB, manifest *policyrecommendation.Manifest) error {
+	digest, err := resolveJobImageDigest(clientset, manifest.Backend, manifest.ID)
+	if err != nil || digest == "" {
+		return err
+	}
+	manifest.ImageDigest = digest
+	return saveJobManifest(connect, manifest)
+}
+
+// retrieveJobManifest prints the manifest of a job as indented JSON, or saves
+// it to filePath if it is not empty. The digest of the image is resolved if it
+// was not recorded yet and the Pod of the job still exists.
+func retrieveJobManifest(cmd *cobra.Command, clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool, filePath string, recoID string) error {
+	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if portForward != nil {
+		defer portForward.Stop()
+	}
+	if err != nil {
+		return err
+	}
+	defer connect.Close()
+	manifest, err := getJobManifest(connect, recoID)
+	if err != nil {
+		return err
+	}
+	if manifest.ImageDigest == "" {
+		if manifest.ImageDigest, err = resolveJobImageDigest(clientset, manifest.Backend, manifest.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not resolve the image digest of the job: %v\n", err)
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if filePath != "" {
+		if err := os.WriteFile(filePath, data, 0600); err != nil {
+			return fmt.Errorf("error when writing the manifest to file: %v", err)
+		}
+		return nil
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
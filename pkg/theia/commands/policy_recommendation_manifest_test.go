@@ -0,0 +1,121 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+)
+
+const testManifestID = "e998433e-accb-4888-9fc8-06563f073e86"
+
+func TestManifestParameters(t *testing.T) {
+	flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	flags.String("type", "initial", "")
+	flags.Int("limit", 0, "")
+	flags.String("clickhouse-endpoint", "", "")
+	flags.Bool("wait", false, "")
+	require.NoError(t, flags.Parse([]string{"--limit", "100", "--wait"}))
+	assert.Equal(t, map[string]string{"type": "initial", "limit": "100"}, manifestParameters(flags))
+}
+
+func TestJobManifest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	watermark := time.Date(2022, 8, 1, 9, 59, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM system.tables")).WillReturnRows(sqlmock.NewRows([]string{"count()"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT value FROM theia_metadata")).WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("1"))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM system.columns")).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("timeInserted"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT max(timeInserted) FROM flows")).WillReturnRows(sqlmock.NewRows([]string{"max(timeInserted)"}).AddRow(watermark))
+	request := &executor.Request{
+		ID:        testManifestID,
+		Args:      []string{"--type", "initial", "--id", testManifestID},
+		Resources: executor.Resources{ExecutorInstances: 1, DriverMemory: "512M"},
+	}
+	now := time.Date(2022, 8, 1, 10, 0, 0, 500, time.UTC)
+	manifest, err := newJobManifest(db, executor.SparkOperatorBackend, request, map[string]string{"type": "initial"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, &policyrecommendation.Manifest{
+		ID:                testManifestID,
+		TimeCreated:       time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC),
+		Backend:           executor.SparkOperatorBackend,
+		Parameters:        map[string]string{"type": "initial"},
+		JobArgs:           request.Args,
+		Resources:         request.Resources,
+		Image:             config.SparkImage,
+		FlowSchemaVersion: 1,
+		DataWatermark:     watermark,
+	}, manifest)
+
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO recommendation_manifests (id, timeUpdated, manifest) VALUES (?, ?, ?)")).
+		ExpectExec().WithArgs(testManifestID, sqlmock.AnyArg(), string(data)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, saveJobManifest(db, manifest))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT manifest FROM recommendation_manifests WHERE id = ? ORDER BY timeUpdated DESC LIMIT 1")).
+		WithArgs(testManifestID).WillReturnRows(sqlmock.NewRows([]string{"manifest"}).AddRow(string(data)))
+	retrieved, err := getJobManifest(db, testManifestID)
+	require.NoError(t, err)
+	assert.Equal(t, manifest, retrieved)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT manifest FROM recommendation_manifests")).
+		WithArgs("unknown").WillReturnRows(sqlmock.NewRows([]string{"manifest"}))
+	_, err = getJobManifest(db, "unknown")
+	assert.ErrorContains(t, err, "could not find the manifest of job unknown")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveJobImageDigest(t *testing.T) {
+	driver := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-" + testManifestID + "-driver",
+			Namespace: config.FlowVisibilityNS,
+			Labels: map[string]string{
+				"spark-role":                    "driver",
+				"sparkoperator.k8s.io/app-name": "pr-" + testManifestID,
+			},
+		},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+			ImageID: "docker-pullable://projects.registry.vmware.com/antrea/theia-policy-recommendation@sha256:0123",
+		}}},
+	}
+	clientset := fake.NewSimpleClientset(driver)
+	digest, err := resolveJobImageDigest(clientset, executor.SparkOperatorBackend, testManifestID)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:0123", digest)
+
+	digest, err = resolveJobImageDigest(clientset, executor.K8sJobBackend, testManifestID)
+	require.NoError(t, err)
+	assert.Equal(t, "", digest)
+}
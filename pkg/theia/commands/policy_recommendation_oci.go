@@ -0,0 +1,131 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/util/oci"
+	"antrea.io/theia/pkg/util/signing"
+)
+
+const (
+	// policyBundleArtifactType is the type of the OCI artifacts of the
+	// recommended policies, e.g. for "oras pull --artifact-type".
+	policyBundleArtifactType   = "application/vnd.antrea.theia.policy-recommendation.config.v1+json"
+	policyBundleMediaType      = "application/vnd.antrea.theia.policy-recommendation.policies.v1+yaml"
+	policySignatureMediaType   = "application/vnd.antrea.theia.policy-recommendation.signature.v1"
+	policyBundleFileName       = "policies.yaml"
+	recommendationIDAnnotation = "io.antrea.theia.recommendation-id"
+
+	// registryPasswordEnv is the environment variable with the registry
+	// password, to avoid providing it on the command line.
+	registryPasswordEnv = "THEIA_REGISTRY_PASSWORD"
+)
+
+func addRegistryFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"registry-username",
+		"",
+		"Username used to authenticate with the OCI registry.",
+	)
+	cmd.Flags().String(
+		"registry-password",
+		"",
+		fmt.Sprintf("Password used to authenticate with the OCI registry. Defaults to the %s environment variable.", registryPasswordEnv),
+	)
+	cmd.Flags().Bool(
+		"plain-http",
+		false,
+		"Access the OCI registry with HTTP instead of HTTPS.",
+	)
+}
+
+func newRegistryClient(cmd *cobra.Command) (*oci.Client, error) {
+	username, err := cmd.Flags().GetString("registry-username")
+	if err != nil {
+		return nil, err
+	}
+	password, err := cmd.Flags().GetString("registry-password")
+	if err != nil {
+		return nil, err
+	}
+	if password == "" {
+		password = os.Getenv(registryPasswordEnv)
+	}
+	plainHTTP, err := cmd.Flags().GetBool("plain-http")
+	if err != nil {
+		return nil, err
+	}
+	return oci.NewClient(username, password, plainHTTP), nil
+}
+
+// pushPolicyBundle pushes the recommended policies, and their signature if it
+// is not empty, as an OCI artifact. It returns the digest of the manifest.
+func pushPolicyBundle(ctx context.Context, client *oci.Client, ref oci.Reference, recoID string, bundle []byte, signature string) (string, error) {
+	artifact := &oci.Artifact{
+		ArtifactType: policyBundleArtifactType,
+		Layers: []oci.Layer{{
+			MediaType: policyBundleMediaType,
+			Title:     policyBundleFileName,
+			Data:      bundle,
+		}},
+		Annotations: map[string]string{recommendationIDAnnotation: recoID},
+	}
+	if signature != "" {
+		artifact.Layers = append(artifact.Layers, oci.Layer{
+			MediaType: policySignatureMediaType,
+			Title:     policyBundleFileName + ".sig",
+			Data:      []byte(signature),
+		})
+	}
+	digest, err := client.Push(ctx, ref, artifact)
+	if err != nil {
+		return "", fmt.Errorf("error when pushing recommendation result to %s: %v", ref, err)
+	}
+	return digest, nil
+}
+
+// pullPolicyBundle pulls the recommended policies pushed by pushPolicyBundle.
+// If publicKey is not nil, the artifact must include a valid signature of the
+// policies.
+func pullPolicyBundle(ctx context.Context, client *oci.Client, ref oci.Reference, publicKey crypto.PublicKey) ([]byte, error) {
+	artifact, err := client.Pull(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("error when pulling recommendation result from %s: %v", ref, err)
+	}
+	if artifact.ArtifactType != policyBundleArtifactType {
+		return nil, fmt.Errorf("%s is not a policy recommendation result, its type is %s", ref, artifact.ArtifactType)
+	}
+	bundle := artifact.Layer(policyBundleMediaType)
+	if bundle == nil {
+		return nil, fmt.Errorf("%s has no recommended policies", ref)
+	}
+	if publicKey != nil {
+		signature := artifact.Layer(policySignatureMediaType)
+		if signature == nil {
+			return nil, fmt.Errorf("%s is not signed", ref)
+		}
+		if err := signing.Verify(publicKey, bundle.Data, string(signature.Data)); err != nil {
+			return nil, fmt.Errorf("error when verifying %s: %v", ref, err)
+		}
+	}
+	return bundle.Data, nil
+}
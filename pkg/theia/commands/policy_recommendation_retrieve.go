@@ -15,12 +15,24 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/theia/clickhouse"
+	"antrea.io/theia/pkg/util/oci"
+	"antrea.io/theia/pkg/util/signing"
+	"antrea.io/theia/pkg/util/sink"
+	"antrea.io/theia/pkg/util/validation"
 )
 
 // policyRecommendationRetrieveCmd represents the policy-recommendation retrieve command
@@ -41,6 +53,25 @@ Use Service ClusterIP when connecting to ClickHouse to getting the result
 $ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --use-cluster-ip
 Save the recommendation result to file
 $ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --use-cluster-ip --file output.yaml
+Save the recommendation result to file, and sign it with a cosign key to output.yaml.sig
+$ COSIGN_PASSWORD=<password> theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --file output.yaml --sign-key cosign.key
+Push the recommendation result, signed with a cosign key, as an OCI artifact
+$ COSIGN_PASSWORD=<password> theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --push oci://registry.example.com/theia/policies:v1 --sign-key cosign.key
+Save the recommendation result to file, and the evidence of each recommended rule to output.yaml.evidence.json
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --file output.yaml --with-evidence
+Save the recommendation result to file, and the Pods and Services selected by each recommended policy in the
+cluster snapshot taken when the job was run to output.yaml.selectors.json
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --file output.yaml --resolve-selectors
+Stream the recommended policies as JSON, one policy per line, and apply them
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --output ndjson | kubectl apply -f -
+Apply the recommended policies to the cluster with server-side apply, taking ownership of fields edited manually
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --kubectl-apply --force-conflicts
+Upload the recommendation result to an S3 bucket, and store it in a ConfigMap
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --sink s3://bucket/results?region=us-west-2 --sink configmap://flow-visibility/results
+Get the recommended Antrea NetworkPolicies applied to the Pods labelled team=payments in the payments Namespace
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --filter-namespace payments --filter-label team=payments --kind anp
+Save the manifest of the job, to rerun it identically later
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --manifest --file manifest.json
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Parse the flags
@@ -69,6 +100,10 @@ $ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --us
 				return err
 			}
 		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
 		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
 		if err != nil {
 			return err
@@ -77,6 +112,111 @@ $ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --us
 		if err != nil {
 			return err
 		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		if err := validation.OneOf("output", output, "yaml", "ndjson"); err != nil {
+			return err
+		}
+		sinkURIs, err := cmd.Flags().GetStringArray("sink")
+		if err != nil {
+			return err
+		}
+		if output == "ndjson" {
+			for _, flag := range []string{"file", "sign-key", "push", "sink"} {
+				if cmd.Flags().Changed(flag) {
+					return fmt.Errorf("output ndjson cannot be used together with %s, the policies are streamed to stdout", flag)
+				}
+			}
+		}
+		manifestFlag, err := cmd.Flags().GetBool("manifest")
+		if err != nil {
+			return err
+		}
+		if manifestFlag {
+			for _, flag := range []string{"with-evidence", "evidence-file", "resolve-selectors", "sign-key", "signature-file", "push", "output", "kubectl-apply", "force-conflicts", "sink", "filter-namespace", "filter-label", "kind"} {
+				if cmd.Flags().Changed(flag) {
+					return fmt.Errorf("manifest cannot be used together with %s", flag)
+				}
+			}
+			clientset, err := CreateK8sClient(kubeconfig)
+			if err != nil {
+				return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+			}
+			if err := CheckClickHousePod(clientset); err != nil {
+				return err
+			}
+			return retrieveJobManifest(cmd, clientset, kubeconfig, endpoint, caCertPath, useClusterIP, filePath, recoID)
+		}
+		filter, err := newPolicyFilter(cmd)
+		if err != nil {
+			return err
+		}
+		withEvidence, err := cmd.Flags().GetBool("with-evidence")
+		if err != nil {
+			return err
+		}
+		evidenceFilePath, err := cmd.Flags().GetString("evidence-file")
+		if err != nil {
+			return err
+		}
+		resolveSelectorsFlag, err := cmd.Flags().GetBool("resolve-selectors")
+		if err != nil {
+			return err
+		}
+		signKey, err := cmd.Flags().GetString("sign-key")
+		if err != nil {
+			return err
+		}
+		signatureFilePath, err := cmd.Flags().GetString("signature-file")
+		if err != nil {
+			return err
+		}
+		pushRef, err := cmd.Flags().GetString("push")
+		if err != nil {
+			return err
+		}
+		kubectlApply, err := cmd.Flags().GetBool("kubectl-apply")
+		if err != nil {
+			return err
+		}
+		forceConflicts, err := cmd.Flags().GetBool("force-conflicts")
+		if err != nil {
+			return err
+		}
+		if forceConflicts && !kubectlApply {
+			return fmt.Errorf("force-conflicts can only be used together with kubectl-apply")
+		}
+		var ref oci.Reference
+		var registryClient *oci.Client
+		if pushRef != "" {
+			if ref, err = oci.ParseReference(pushRef); err != nil {
+				return err
+			}
+			if registryClient, err = newRegistryClient(cmd); err != nil {
+				return err
+			}
+		}
+		if signKey != "" && filePath == "" && pushRef == "" {
+			return fmt.Errorf("sign-key can only be used together with file or push")
+		}
+		if signatureFilePath == "" {
+			signatureFilePath = filePath + ".sig"
+		}
+		if !withEvidence {
+			evidenceFilePath = ""
+		} else if evidenceFilePath == "" && filePath != "" {
+			evidenceFilePath = filePath + ".evidence.json"
+		} else if evidenceFilePath == "" {
+			evidenceFilePath = recoID + ".evidence.json"
+		}
+		selectorsFilePath := ""
+		if resolveSelectorsFlag && filePath != "" {
+			selectorsFilePath = filePath + ".selectors.json"
+		} else if resolveSelectorsFlag {
+			selectorsFilePath = recoID + ".selectors.json"
+		}
 
 		// Verify Clickhouse is running
 		clientset, err := CreateK8sClient(kubeconfig)
@@ -86,31 +226,123 @@ $ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --us
 		if err := CheckClickHousePod(clientset); err != nil {
 			return err
 		}
+		sinks, err := newSinks(sinkURIs, cmd.OutOrStdout(), clientset)
+		if err != nil {
+			return err
+		}
 
-		recoResult, err := getPolicyRecommendationResult(clientset, kubeconfig, endpoint, useClusterIP, filePath, recoID)
+		recoResult, err := getPolicyRecommendationResult(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, filePath, evidenceFilePath, selectorsFilePath, filter, recoID)
 		if err != nil {
 			return err
-		} else {
-			if recoResult != "" {
-				fmt.Print(recoResult)
+		} else if output == "ndjson" {
+			if err := writeNDJSONPolicies(cmd.OutOrStdout(), recoResult); err != nil {
+				return err
 			}
+		} else if recoResult != "" && len(sinks) == 0 {
+			fmt.Print(recoResult)
+		}
+		if evidenceFilePath != "" {
+			fmt.Fprintf(os.Stderr, "Evidence of the recommended rules saved to %s\n", evidenceFilePath)
+		}
+		if selectorsFilePath != "" {
+			fmt.Fprintf(os.Stderr, "Resolved selectors of the recommended policies saved to %s\n", selectorsFilePath)
+		}
+		if signKey == "" && pushRef == "" && !kubectlApply && len(sinks) == 0 {
+			return nil
+		}
+		bundle := []byte(recoResult)
+		if filePath != "" {
+			if bundle, err = os.ReadFile(filePath); err != nil {
+				return fmt.Errorf("error when reading recommendation result: %v", err)
+			}
+		}
+		var signature string
+		if signKey != "" {
+			if signature, err = signPolicyBundle(bundle, signKey, []byte(os.Getenv(signingPasswordEnv))); err != nil {
+				return err
+			}
+			if filePath != "" {
+				if err := os.WriteFile(signatureFilePath, []byte(signature), 0644); err != nil {
+					return fmt.Errorf("error when writing signature to file: %v", err)
+				}
+				fmt.Fprintf(os.Stderr, "Signature of the recommendation result saved to %s\n", signatureFilePath)
+			}
+		}
+		if pushRef != "" {
+			digest, err := pushPolicyBundle(context.TODO(), registryClient, ref, recoID, bundle, signature)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Recommendation result pushed to %s (digest %s)\n", ref, digest)
+		}
+		if err := writeToSinks(context.TODO(), sinks, &sink.Result{ID: recoID, Policies: bundle}); err != nil {
+			return err
+		}
+		if kubectlApply {
+			policies, err := decodePolicyBundle(bundle)
+			if err != nil {
+				return err
+			}
+			return serverSideApplyPolicies(os.Stderr, clientset.CoreV1().RESTClient(), policies, applyOptions{recoID: recoID, forceConflicts: forceConflicts})
 		}
 		return nil
 	},
 }
 
-func getPolicyRecommendationResult(clientset kubernetes.Interface, kubeconfig string, endpoint string, useClusterIP bool, filePath string, recoID string) (recoResult string, err error) {
-	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, useClusterIP)
+// signPolicyBundle signs a policy bundle, and returns the base64-encoded
+// signature.
+func signPolicyBundle(bundle []byte, keyPath string, password []byte) (string, error) {
+	signer, err := signing.LoadPrivateKey(keyPath, password)
+	if err != nil {
+		return "", err
+	}
+	return signing.Sign(signer, bundle)
+}
+
+func getPolicyRecommendationResult(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool, filePath string, evidenceFilePath string, selectorsFilePath string, filter *policyFilter, recoID string) (recoResult string, err error) {
+	client, portForward, err := setupClickHouseClient(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
 	if portForward != nil {
 		defer portForward.Stop()
 	}
 	if err != nil {
 		return "", err
 	}
-	recoResult, err = getResultFromClickHouse(connect, recoID)
+	defer client.Close()
+	var namespaces []string
+	if filter != nil {
+		namespaces = filter.namespaces.List()
+	}
+	recoResult, err = getResultFromClickHouse(client.DB(), recoID, namespaces)
 	if err != nil {
 		return "", fmt.Errorf("error when getting result from ClickHouse, %v", err)
 	}
+	if filter != nil {
+		if recoResult, err = filterRecommendedPolicies(recoResult, filter); err != nil {
+			return "", err
+		}
+	}
+	if evidenceFilePath != "" {
+		report, err := getRecommendationEvidence(client.DB(), recoID, recoResult)
+		if err != nil {
+			return "", fmt.Errorf("error when getting evidence from ClickHouse, %v", err)
+		}
+		if err := writeJSONReport(report, evidenceFilePath); err != nil {
+			return "", err
+		}
+	}
+	if selectorsFilePath != "" {
+		snapshot, err := getClusterSnapshot(client.DB(), recoID)
+		if err != nil {
+			return "", err
+		}
+		report, err := resolveSelectors(snapshot, recoID, recoResult)
+		if err != nil {
+			return "", err
+		}
+		if err := writeJSONReport(report, selectorsFilePath); err != nil {
+			return "", err
+		}
+	}
 	if filePath != "" {
 		if err := os.WriteFile(filePath, []byte(recoResult), 0600); err != nil {
 			return "", fmt.Errorf("error when writing recommendation result to file: %v", err)
@@ -121,12 +353,42 @@ func getPolicyRecommendationResult(clientset kubernetes.Interface, kubeconfig st
 	return "", nil
 }
 
-func getResultFromClickHouse(connect *sql.DB, id string) (string, error) {
-	var recoResult string
-	query := "SELECT yamls FROM recommendations WHERE id = (?);"
-	err := connect.QueryRow(query, id).Scan(&recoResult)
+// writeNDJSONPolicies writes the recommended policies to out as JSON, one policy
+// per line. Each policy is written as soon as it is converted, so that the
+// output can be consumed while it is being written.
+func writeNDJSONPolicies(out io.Writer, yamls string) error {
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(strings.NewReader(yamls)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error when parsing the recommended policies: %v", err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		policy, err := k8syaml.ToJSON(doc)
+		if err != nil {
+			return fmt.Errorf("error when converting the recommended policies to JSON: %v", err)
+		}
+		if _, err := fmt.Fprintf(out, "%s\n", policy); err != nil {
+			return err
+		}
+	}
+}
+
+// getResultFromClickHouse returns the recommended policies of a job. When
+// namespaces are provided, only the policies in these Namespaces and the
+// cluster-scoped ones are read.
+func getResultFromClickHouse(connect *sql.DB, id string, namespaces []string) (string, error) {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return "", fmt.Errorf("failed to get recommendation result with id %s: %v", id, err)
+	}
+	recoResult, err := clickhouse.NewClient(connect).GetRecommendationResult(context.TODO(), id, namespaces...)
 	if err != nil {
-		return recoResult, fmt.Errorf("failed to get recommendation result with id %s: %v", id, err)
+		return "", fmt.Errorf("failed to get recommendation result with id %s: %v", id, err)
 	}
 	return recoResult, nil
 }
@@ -145,4 +407,77 @@ func init() {
 		"",
 		"The file path where you want to save the result.",
 	)
+	policyRecommendationRetrieveCmd.Flags().StringP(
+		"output",
+		"o",
+		"yaml",
+		`The output format of the result, yaml or ndjson. With ndjson, the policies are streamed to stdout as JSON, one
+policy per line, e.g. to be piped to jq or to "kubectl apply -f -".`,
+	)
+	policyRecommendationRetrieveCmd.Flags().Bool(
+		"manifest",
+		false,
+		`Retrieve the manifest of the job instead of its result: the effective parameters, image and image digest,
+flow schema version and data watermark of the job. The job can be rerun identically with "run --from-manifest".`,
+	)
+	policyRecommendationRetrieveCmd.Flags().Bool(
+		"with-evidence",
+		false,
+		`Save the evidence of each recommended rule (flow count, first and last seen times, sample flows) in a JSON
+report, to review why the rule was recommended.`,
+	)
+	policyRecommendationRetrieveCmd.Flags().String(
+		"evidence-file",
+		"",
+		`The file path where you want to save the evidence report. Defaults to the result file path with the .evidence.json
+suffix, or to <ID>.evidence.json. It is only used together with with-evidence.`,
+	)
+	policyRecommendationRetrieveCmd.Flags().Bool(
+		"resolve-selectors",
+		false,
+		`Save the Pods and Services selected by the appliedTo and the peers of each recommended policy, resolved in the
+cluster snapshot taken when the job was run with --snapshot, in a JSON report. The report is saved to the result
+file path with the .selectors.json suffix, or to <ID>.selectors.json.`,
+	)
+	policyRecommendationRetrieveCmd.Flags().String(
+		"sign-key",
+		"",
+		`PEM-encoded Ed25519 or ECDSA P-256 private key used to sign the result saved to file, e.g. cosign.key.
+Encrypted cosign keys are decrypted with the password in the COSIGN_PASSWORD environment variable.`,
+	)
+	policyRecommendationRetrieveCmd.Flags().String(
+		"signature-file",
+		"",
+		"The file path where you want to save the signature. Defaults to the result file path with the .sig suffix.",
+	)
+	policyRecommendationRetrieveCmd.Flags().String(
+		"push",
+		"",
+		`Push the result as an OCI artifact to this reference, e.g. oci://registry.example.com/theia/policies:v1.
+The artifact includes the signature of the result when sign-key is provided.`,
+	)
+	policyRecommendationRetrieveCmd.Flags().Bool(
+		"kubectl-apply",
+		false,
+		`Apply the recommended policies to the cluster with server-side apply, using the "theia" field manager. Applying
+the same result again leaves the policies unchanged. The result of each policy is printed to stderr, and the policies
+with fields owned by another field manager, e.g. edited manually, are reported with the conflicting fields.`,
+	)
+	policyRecommendationRetrieveCmd.Flags().Bool(
+		"force-conflicts",
+		false,
+		"Take ownership of the fields owned by other field managers when applying the recommended policies, instead of failing.",
+	)
+	policyRecommendationRetrieveCmd.Flags().StringArray(
+		"sink",
+		nil,
+		`Write the result to this sink, can be repeated. The result is not printed to stdout when a sink is provided,
+unless the stdout sink is used. Supported sinks are stdout (or -), file:<path> (<path>/<ID>.yaml for directories),
+s3://<bucket>/<prefix> and gs://<bucket>/<prefix> (<prefix>/<ID>.yaml, with optional region and endpoint query
+parameters), configmap://<namespace>/<name> and secret://<namespace>/<name> (policies.yaml key, replaced by each
+result, chunked over <name>-1, <name>-2, etc. above 1MB), http(s)://<host>/<path> (POST as application/yaml)
+and k8s: (server-side apply of the policies, k8s:?force-conflicts=true to take ownership of conflicting fields).`,
+	)
+	addPolicyFilterFlags(policyRecommendationRetrieveCmd)
+	addRegistryFlags(policyRecommendationRetrieveCmd)
 }
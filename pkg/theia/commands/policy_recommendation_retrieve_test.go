@@ -15,97 +15,13 @@
 package commands
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
-	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes/fake"
-
-	"antrea.io/theia/pkg/theia/commands/config"
 )
 
-func TestGetClickHouseSecret(t *testing.T) {
-	testCases := []struct {
-		name             string
-		fakeClientset    *fake.Clientset
-		expectedUsername string
-		expectedPassword string
-		expectedErrorMsg string
-	}{
-		{
-			name: "valid case",
-			fakeClientset: fake.NewSimpleClientset(
-				&v1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "clickhouse-secret",
-						Namespace: config.FlowVisibilityNS,
-					},
-					Data: map[string][]byte{
-						"username": []byte("clickhouse_operator"),
-						"password": []byte("clickhouse_operator_password"),
-					},
-				},
-			),
-			expectedUsername: "clickhouse_operator",
-			expectedPassword: "clickhouse_operator_password",
-			expectedErrorMsg: "",
-		},
-		{
-			name:             "clickhouse secret not found",
-			fakeClientset:    fake.NewSimpleClientset(),
-			expectedUsername: "",
-			expectedPassword: "",
-			expectedErrorMsg: `error secrets "clickhouse-secret" not found when finding the ClickHouse secret, please check the deployment of ClickHouse`,
-		},
-		{
-			name: "username not found",
-			fakeClientset: fake.NewSimpleClientset(
-				&v1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "clickhouse-secret",
-						Namespace: config.FlowVisibilityNS,
-					},
-					Data: map[string][]byte{
-						"password": []byte("clickhouse_operator_password"),
-					},
-				},
-			),
-			expectedUsername: "",
-			expectedPassword: "",
-			expectedErrorMsg: "error when getting the ClickHouse username",
-		},
-		{
-			name: "password not found",
-			fakeClientset: fake.NewSimpleClientset(
-				&v1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "clickhouse-secret",
-						Namespace: config.FlowVisibilityNS,
-					},
-					Data: map[string][]byte{
-						"username": []byte("clickhouse_operator"),
-					},
-				},
-			),
-			expectedUsername: "clickhouse_operator",
-			expectedPassword: "",
-			expectedErrorMsg: "error when getting the ClickHouse password",
-		},
-	}
-	for _, tt := range testCases {
-		t.Run(tt.name, func(t *testing.T) {
-			username, password, err := getClickHouseSecret(tt.fakeClientset)
-			if tt.expectedErrorMsg != "" {
-				assert.EqualErrorf(t, err, tt.expectedErrorMsg, "Error should be: %v, got: %v", tt.expectedErrorMsg, err)
-			}
-			assert.Equal(t, tt.expectedUsername, string(username))
-			assert.Equal(t, tt.expectedPassword, string(password))
-		})
-	}
-}
-
 func TestGetResultFromClickHouse(t *testing.T) {
 	testCases := []struct {
 		name             string
@@ -135,8 +51,8 @@ func TestGetResultFromClickHouse(t *testing.T) {
 			if tt.expectedResult != "" {
 				resultRow = sqlmock.NewRows([]string{"yamls"}).AddRow(tt.expectedResult)
 			}
-			mock.ExpectQuery("SELECT yamls FROM recommendations WHERE id = (?);").WithArgs(tt.recommendationID).WillReturnRows(resultRow)
-			result, err := getResultFromClickHouse(db, tt.recommendationID)
+			mock.ExpectQuery("SELECT yamls FROM recommendations WHERE id = (?) ORDER BY namespace, part;").WithArgs(tt.recommendationID).WillReturnRows(resultRow)
+			result, err := getResultFromClickHouse(db, tt.recommendationID, nil)
 			if tt.expectedErrorMsg != "" {
 				assert.EqualErrorf(t, err, tt.expectedErrorMsg, "Error should be: %v, got: %v", tt.expectedErrorMsg, err)
 			} else {
@@ -146,3 +62,34 @@ func TestGetResultFromClickHouse(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteNDJSONPolicies(t *testing.T) {
+	yamls := `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-a
+  namespace: app-a
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+---
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-acnp-b
+spec:
+  tier: Baseline
+`
+	var out bytes.Buffer
+	assert.NoError(t, writeNDJSONPolicies(&out, yamls))
+	assert.Equal(t, `{"apiVersion":"networking.k8s.io/v1","kind":"NetworkPolicy","metadata":{"name":"recommend-k8s-np-a","namespace":"app-a"},"spec":{"podSelector":{"matchLabels":{"app":"web"}}}}
+{"apiVersion":"crd.antrea.io/v1alpha1","kind":"ClusterNetworkPolicy","metadata":{"name":"recommend-reject-acnp-b"},"spec":{"tier":"Baseline"}}
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, writeNDJSONPolicies(&out, ""))
+	assert.Empty(t, out.String())
+	assert.ErrorContains(t, writeNDJSONPolicies(&out, "kind: [NetworkPolicy"), "error when converting the recommended policies to JSON")
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+// timeRangeFlags are the flags selecting the flow records considered by a job.
+// They are overridden together when retrying a job.
+var timeRangeFlags = map[string]bool{
+	"start-time": true,
+	"end-time":   true,
+	"last":       true,
+}
+
+// policyRecommendationRetryCmd represents the policy-recommendation retry command
+var policyRecommendationRetryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Retry a policy recommendation job",
+	Long: `Submit a new policy recommendation job with the same parameters as a previous
+job, e.g. after it failed because of a transient outage of the Spark Operator
+or ClickHouse. The parameters are recorded on the SparkApplication, or on the
+Job with the k8s-job backend, of the jobs submitted with "run", so the job
+cannot be retried once it has been deleted. The flow records considered by
+the new job are the same as the ones of the previous job, even if it was run
+with --last. The flags of the run command can be provided to override
+individual parameters, including from the environment or the config file.`,
+	Args: cobra.RangeArgs(0, 1),
+	Example: `
+Retry the policy recommendation job with ID e998433e-accb-4888-9fc8-06563f073e86
+$ theia policy-recommendation retry e998433e-accb-4888-9fc8-06563f073e86
+Retry the policy recommendation job with ID e998433e-accb-4888-9fc8-06563f073e86 with more executors, and wait for it to complete
+$ theia policy-recommendation retry --id e998433e-accb-4888-9fc8-06563f073e86 --executor-instances 4 --wait
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoID, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		if recoID == "" && len(args) == 1 {
+			recoID = args[0]
+		}
+		err = ParseRecommendationID(recoID)
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		job, err := executor.GetJob(context.TODO(), executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}), recoID)
+		if err != nil {
+			return err
+		}
+		if job.Parameters == nil {
+			return fmt.Errorf("the parameters of policy recommendation job %s were not recorded, it was submitted by an older version of theia, from a manifest or through theia-manager", recoID)
+		}
+		if err := setRetryFlags(cmd.Flags(), job.Parameters); err != nil {
+			return err
+		}
+		return policyRecommendationRunCmd.RunE(cmd, nil)
+	},
+}
+
+// retryParameters returns the parameters recorded on the backend object of a
+// job, so that it can be retried. The time range given with --last is
+// resolved, so that the retried job considers the same flow records.
+func retryParameters(parameters map[string]string, startTime, endTime time.Time) map[string]string {
+	retried := make(map[string]string, len(parameters))
+	for name, value := range parameters {
+		if name != "from-manifest" {
+			retried[name] = value
+		}
+	}
+	if parameters["last"] != "" {
+		retried["last"] = ""
+		retried["start-time"] = startTime.UTC().Format(time.RFC3339)
+		retried["end-time"] = endTime.UTC().Format(time.RFC3339)
+	}
+	return retried
+}
+
+// setRetryFlags sets the flags to the parameters of the retried job, unless
+// they are already set, e.g. on the command line.
+func setRetryFlags(flags *pflag.FlagSet, parameters map[string]string) error {
+	timeRangeChanged := false
+	for name := range timeRangeFlags {
+		if flags.Changed(name) {
+			timeRangeChanged = true
+		}
+	}
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		// Flags which no longer exist are ignored.
+		if flag == nil || flag.Changed || manifestExcludedFlags[name] {
+			continue
+		}
+		if timeRangeChanged && timeRangeFlags[name] {
+			continue
+		}
+		value := parameters[name]
+		if value == flag.Value.String() {
+			continue
+		}
+		// The values of the list and map flags are recorded as [a,b] and
+		// [k1=v1,k2=v2].
+		if flag.Value.Type() == "stringSlice" || flag.Value.Type() == "stringToString" {
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid recorded value %q for %s: %v", value, name, err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationRetryCmd)
+	policyRecommendationRetryCmd.Flags().StringP(
+		"id",
+		"i",
+		"",
+		"ID of the policy recommendation job to retry.",
+	)
+	// The flags of the run command are added in its init function.
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryParameters(t *testing.T) {
+	startTime := time.Date(2022, 8, 1, 9, 0, 0, 0, time.UTC)
+	endTime := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, map[string]string{"type": "initial", "start-time": "", "end-time": "", "last": ""}, retryParameters(map[string]string{"type": "initial", "start-time": "", "end-time": "", "last": "", "from-manifest": ""}, time.Time{}, time.Time{}))
+	assert.Equal(t, map[string]string{"type": "initial", "start-time": "2022-08-01T09:00:00Z", "end-time": "2022-08-01T10:00:00Z", "last": ""}, retryParameters(map[string]string{"type": "initial", "start-time": "", "end-time": "", "last": "1h"}, startTime, endTime))
+}
+
+func TestSetRetryFlags(t *testing.T) {
+	parameters := map[string]string{
+		"type":          "subsequent",
+		"limit":         "100",
+		"start-time":    "2022-08-01T09:00:00Z",
+		"end-time":      "2022-08-01T10:00:00Z",
+		"last":          "",
+		"ns-allow-list": "[kube-system,flow-visibility]",
+		"labels":        "[app=web]",
+		"wait":          "true",
+		"removed-flag":  "true",
+	}
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("retry", pflag.ContinueOnError)
+		flags.String("type", "initial", "")
+		flags.Int("limit", 0, "")
+		flags.String("start-time", "", "")
+		flags.String("end-time", "", "")
+		flags.String("last", "", "")
+		flags.StringSlice("ns-allow-list", nil, "")
+		flags.StringToString("labels", nil, "")
+		flags.Bool("wait", false, "")
+		return flags
+	}
+
+	t.Run("recorded parameters", func(t *testing.T) {
+		flags := newFlags()
+		require.NoError(t, setRetryFlags(flags, parameters))
+		assert.Equal(t, "subsequent", flags.Lookup("type").Value.String())
+		assert.Equal(t, "100", flags.Lookup("limit").Value.String())
+		assert.Equal(t, "2022-08-01T09:00:00Z", flags.Lookup("start-time").Value.String())
+		assert.Equal(t, "2022-08-01T10:00:00Z", flags.Lookup("end-time").Value.String())
+		assert.False(t, flags.Changed("last"))
+		nsAllowList, err := flags.GetStringSlice("ns-allow-list")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kube-system", "flow-visibility"}, nsAllowList)
+		labels, err := flags.GetStringToString("labels")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"app": "web"}, labels)
+		// Flags which do not affect the result of the job are not retried.
+		assert.False(t, flags.Changed("wait"))
+	})
+
+	t.Run("overridden parameters", func(t *testing.T) {
+		flags := newFlags()
+		require.NoError(t, flags.Parse([]string{"--limit", "50", "--last", "2h"}))
+		require.NoError(t, setRetryFlags(flags, parameters))
+		assert.Equal(t, "subsequent", flags.Lookup("type").Value.String())
+		assert.Equal(t, "50", flags.Lookup("limit").Value.String())
+		assert.Equal(t, "2h", flags.Lookup("last").Value.String())
+		assert.False(t, flags.Changed("start-time"))
+		assert.False(t, flags.Changed("end-time"))
+	})
+
+	t.Run("invalid parameter", func(t *testing.T) {
+		flags := newFlags()
+		assert.Error(t, setRetryFlags(flags, map[string]string{"limit": "many"}))
+	})
+}
@@ -16,29 +16,131 @@ package commands
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"regexp"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 
 	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
 
 	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/install"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+	s3client "antrea.io/theia/pkg/util/s3"
+	"antrea.io/theia/pkg/util/validation"
 )
 
 type SparkResourceArgs struct {
-	executorInstances   int32
-	driverCoreRequest   string
-	driverMemory        string
-	executorCoreRequest string
-	executorMemory      string
+	executorInstances    int32
+	driverCoreRequest    string
+	driverMemory         string
+	executorCoreRequest  string
+	executorMemory       string
+	executorNodeSelector map[string]string
+	executorTolerations  []v1.Toleration
+	sparkConf            map[string]string
+	// sparkImage defaults to config.SparkImage when empty.
+	sparkImage string
+	// imagePullPolicy defaults to config.SparkImagePullPolicy when empty.
+	imagePullPolicy string
+	// sparkVersion defaults to config.SparkVersion when empty.
+	sparkVersion string
+	// nodeArchitecture, if not empty, is the architecture of the Nodes the
+	// driver and executors are scheduled on.
+	nodeArchitecture string
+}
+
+type spotPreset struct {
+	nodeSelector map[string]string
+	tolerations  []v1.Toleration
+}
+
+// executorSpotPresets are the node selectors and tolerations of the spot (or
+// preemptible) nodes of the managed Kubernetes services.
+var executorSpotPresets = map[string]spotPreset{
+	"eks": {
+		nodeSelector: map[string]string{"eks.amazonaws.com/capacityType": "SPOT"},
+	},
+	"gke": {
+		nodeSelector: map[string]string{"cloud.google.com/gke-spot": "true"},
+		tolerations: []v1.Toleration{{
+			Key:      "cloud.google.com/gke-spot",
+			Operator: v1.TolerationOpEqual,
+			Value:    "true",
+			Effect:   v1.TaintEffectNoSchedule,
+		}},
+	},
+	"aks": {
+		nodeSelector: map[string]string{"kubernetes.azure.com/scalesetpriority": "spot"},
+		tolerations: []v1.Toleration{{
+			Key:      "kubernetes.azure.com/scalesetpriority",
+			Operator: v1.TolerationOpEqual,
+			Value:    "spot",
+			Effect:   v1.TaintEffectNoSchedule,
+		}},
+	},
+}
+
+// decommissionSparkConf enables the graceful decommissioning of executors,
+// which migrates their cached and shuffle blocks to other executors when
+// their nodes are preempted.
+var decommissionSparkConf = map[string]string{
+	"spark.decommission.enabled":                       "true",
+	"spark.storage.decommission.enabled":               "true",
+	"spark.storage.decommission.rddBlocks.enabled":     "true",
+	"spark.storage.decommission.shuffleBlocks.enabled": "true",
+}
+
+// setExecutorPlacement schedules the executors on the nodes matching the given
+// node selector and, if preset is not empty, on the spot nodes of the given
+// managed Kubernetes service. The placement of the driver is unchanged, as
+// losing it fails the job.
+func (a *SparkResourceArgs) setExecutorPlacement(preset string, nodeSelector map[string]string) error {
+	if preset == "" && len(nodeSelector) == 0 {
+		return nil
+	}
+	a.executorNodeSelector = map[string]string{}
+	if preset != "" {
+		if err := validation.OneOf("executor-spot-preset", preset, "eks", "gke", "aks"); err != nil {
+			return err
+		}
+		p := executorSpotPresets[preset]
+		for k, v := range p.nodeSelector {
+			a.executorNodeSelector[k] = v
+		}
+		a.executorTolerations = p.tolerations
+		if a.sparkConf == nil {
+			a.sparkConf = map[string]string{}
+		}
+		for k, v := range decommissionSparkConf {
+			a.sparkConf[k] = v
+		}
+	}
+	for k, v := range nodeSelector {
+		a.executorNodeSelector[k] = v
+	}
+	return nil
 }
 
 // policyRecommendationRunCmd represents the policy recommendation run command
@@ -53,79 +155,120 @@ Run an initial policy recommendation Spark job with policy type anp-deny-applied
 $ theia policy-recommendation run --type initial --policy-type anp-deny-applied --limit 10000
 Run an initial policy recommendation Spark job with policy type anp-deny-applied and limit on flow records from 2022-01-01 00:00:00 to 2022-01-31 23:59:59.
 $ theia policy-recommendation run --type initial --policy-type anp-deny-applied --start-time '2022-01-01 00:00:00' --end-time '2022-01-31 23:59:59'
+Run a policy recommendation Spark job on flow records from 2022-01-01 00:00:00 to 2022-01-31 23:59:59 in the Los Angeles time zone
+$ theia policy-recommendation run --start-time '2022-01-01 00:00:00' --end-time '2022-01-31 23:59:59' --timezone America/Los_Angeles
+Run a policy recommendation Spark job on the flow records of the last 7 days
+$ theia policy-recommendation run --last 7d
+Run a policy recommendation Spark job allowing all traffic in the system Namespaces and in the Namespaces labelled env=infra
+$ theia policy-recommendation run --auto-detect-system --allow-namespace-selector env=infra
+Run a policy recommendation Spark job recommending policies only for the Pods of the Namespaces app-a and app-b
+$ theia policy-recommendation run --target-namespaces app-a,app-b
+Run a policy recommendation Spark job recommending policies for the Pods of all the Namespaces except monitoring
+$ theia policy-recommendation run --target-namespaces '!monitoring'
+Run a policy recommendation Spark job recommending policies only for the Pods labelled app=web in the Namespace shop
+$ theia policy-recommendation run --target-namespaces shop --pod-label-selector app=web
+Run a policy recommendation Spark job with 8 executors on the spot nodes of a GKE cluster, checkpointing flow records to S3
+$ theia policy-recommendation run --executor-instances 8 --executor-spot-preset gke --checkpoint-dir s3a://my-bucket/checkpoints
+Run a policy recommendation Spark job uploading its logs, metrics and result to S3
+$ theia policy-recommendation run --artifacts-uri s3://my-bucket/theia --artifacts-secret theia-artifacts
+Run a policy recommendation Spark job writing its result to ClickHouse in parts of 500 policies, at most 2 per second
+$ theia policy-recommendation run --write-batch-size 500 --write-rate 2
 Run a policy recommendation Spark job with default configuration but doesn't recommend toServices ANPs
 $ theia policy-recommendation run --to-services=false
+Run a policy recommendation Spark job on the IPv6 flow records of a dual-stack cluster
+$ theia policy-recommendation run --address-family ipv6
+Run a policy recommendation Spark job recommending policies which can be enforced on Windows Nodes
+$ theia policy-recommendation run --windows-compatibility enabled
+Run a policy recommendation Spark job snapshotting the Namespaces, Services and Pod labels of the cluster
+$ theia policy-recommendation run --snapshot
+Run a policy recommendation Spark job with an image pulled from a private registry
+$ theia policy-recommendation run --spark-image registry.example.com/theia/theia-policy-recommendation:v0.5.0 --image-pull-policy Always
+Run a policy recommendation Spark job with the images of an image overrides file
+$ theia policy-recommendation run --images-file images.yaml
+Print the SparkApplication which would be created to run a policy recommendation Spark job, instead of creating it
+$ theia policy-recommendation run --dry-run > policy-recommendation.yaml
+Run a policy recommendation Spark job described in a job spec file, with a different end time
+$ theia policy-recommendation run --job-spec jobspec.yaml --end-time '2022-02-28 23:59:59'
+Rerun a policy recommendation Spark job identically from its manifest
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 --manifest -f manifest.json
+$ theia policy-recommendation run --from-manifest manifest.json
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		fromManifest, err := cmd.Flags().GetString("from-manifest")
+		if err != nil {
+			return err
+		}
+		if fromManifest != "" {
+			return runFromManifest(cmd, fromManifest)
+		}
 		var recoJobArgs []string
 		sparkResourceArgs := SparkResourceArgs{}
 
-		recoType, err := cmd.Flags().GetString("type")
+		recoTypeStr, err := cmd.Flags().GetString("type")
 		if err != nil {
 			return err
 		}
-		if recoType != "initial" && recoType != "subsequent" {
-			return fmt.Errorf("recommendation type should be 'initial' or 'subsequent'")
+		recoType, err := policyrecommendation.ParseRecommendationType("type", recoTypeStr)
+		if err != nil {
+			return err
 		}
-		recoJobArgs = append(recoJobArgs, "--type", recoType)
+		recoJobArgs = append(recoJobArgs, "--type", string(recoType))
 
 		limit, err := cmd.Flags().GetInt("limit")
 		if err != nil {
 			return err
 		}
-		if limit < 0 {
-			return fmt.Errorf("limit should be an integer >= 0")
+		if err := validation.NonNegative("limit", int64(limit)); err != nil {
+			return err
 		}
 		recoJobArgs = append(recoJobArgs, "--limit", strconv.Itoa(limit))
 
-		policyType, err := cmd.Flags().GetString("policy-type")
+		policyTypeStr, err := cmd.Flags().GetString("policy-type")
 		if err != nil {
 			return err
 		}
-		var policyTypeArg int
-		if policyType == "anp-deny-applied" {
-			policyTypeArg = 1
-		} else if policyType == "anp-deny-all" {
-			policyTypeArg = 2
-		} else if policyType == "k8s-np" {
-			policyTypeArg = 3
-		} else {
-			return fmt.Errorf(`type of generated NetworkPolicy should be
-anp-deny-applied or anp-deny-all or k8s-np`)
+		policyType, err := policyrecommendation.ParsePolicyType("policy-type", policyTypeStr)
+		if err != nil {
+			return err
 		}
-		recoJobArgs = append(recoJobArgs, "--option", strconv.Itoa(policyTypeArg))
+		recoJobArgs = append(recoJobArgs, "--option", policyType.OptionArg())
 
-		startTime, err := cmd.Flags().GetString("start-time")
+		timezone, err := cmd.Flags().GetString("timezone")
 		if err != nil {
 			return err
 		}
-		var startTimeObj time.Time
-		if startTime != "" {
-			startTimeObj, err = time.Parse("2006-01-02 15:04:05", startTime)
-			if err != nil {
-				return fmt.Errorf(`parsing start-time: %v, start-time should be in 
-'YYYY-MM-DD hh:mm:ss' format, for example: 2006-01-02 15:04:05`, err)
-			}
-			recoJobArgs = append(recoJobArgs, "--start_time", startTime)
+		location, err := validation.Timezone("timezone", timezone)
+		if err != nil {
+			return err
 		}
 
+		startTime, err := cmd.Flags().GetString("start-time")
+		if err != nil {
+			return err
+		}
 		endTime, err := cmd.Flags().GetString("end-time")
 		if err != nil {
 			return err
 		}
-		if endTime != "" {
-			endTimeObj, err := time.Parse("2006-01-02 15:04:05", endTime)
-			if err != nil {
-				return fmt.Errorf(`parsing end-time: %v, end-time should be in 
-'YYYY-MM-DD hh:mm:ss' format, for example: 2006-01-02 15:04:05`, err)
-			}
-			endAfterStart := endTimeObj.After(startTimeObj)
-			if !endAfterStart {
-				return fmt.Errorf("end-time should be after start-time")
-			}
-			recoJobArgs = append(recoJobArgs, "--end_time", endTime)
+		last, err := cmd.Flags().GetString("last")
+		if err != nil {
+			return err
+		}
+		startTimeObj, endTimeObj, err := parseFlowTimeRange(startTime, endTime, last, location, time.Now())
+		if err != nil {
+			return err
+		}
+		if !startTimeObj.IsZero() {
+			recoJobArgs = append(recoJobArgs, "--start_time", startTimeObj.Format("2006-01-02 15:04:05"))
+		}
+		if !endTimeObj.IsZero() {
+			recoJobArgs = append(recoJobArgs, "--end_time", endTimeObj.Format("2006-01-02 15:04:05"))
 		}
 
+		allowNamespaces, err := cmd.Flags().GetStringSlice("allow-namespaces")
+		if err != nil {
+			return err
+		}
 		nsAllowList, err := cmd.Flags().GetString("ns-allow-list")
 		if err != nil {
 			return err
@@ -137,8 +280,41 @@ anp-deny-applied or anp-deny-all or k8s-np`)
 				return fmt.Errorf(`parsing ns-allow-list: %v, ns-allow-list should 
 be a list of namespace string, for example: '["kube-system","flow-aggregator","flow-visibility"]'`, err)
 			}
-			recoJobArgs = append(recoJobArgs, "--ns_allow_list", nsAllowList)
+			allowNamespaces = append(allowNamespaces, parsedNsAllowList...)
+		}
+		allowNamespaceSelector, err := cmd.Flags().GetString("allow-namespace-selector")
+		if err != nil {
+			return err
+		}
+		if _, err := labels.Parse(allowNamespaceSelector); err != nil {
+			return fmt.Errorf("parsing allow-namespace-selector: %v", err)
+		}
+		autoDetectSystem, err := cmd.Flags().GetBool("auto-detect-system")
+		if err != nil {
+			return err
+		}
+		targetNamespaceValues, err := cmd.Flags().GetStringSlice("target-namespaces")
+		if err != nil {
+			return err
 		}
+		targetNamespaces, err := policyrecommendation.ParseTargetNamespaces("target-namespaces", targetNamespaceValues)
+		if err != nil {
+			return err
+		}
+		targetNamespacesArgs, err := targetNamespaces.Args()
+		if err != nil {
+			return err
+		}
+		recoJobArgs = append(recoJobArgs, targetNamespacesArgs...)
+		podLabelSelector, err := cmd.Flags().GetString("pod-label-selector")
+		if err != nil {
+			return err
+		}
+		podLabelSelectorArgs, err := policyrecommendation.PodLabelSelectorArgs("pod-label-selector", podLabelSelector)
+		if err != nil {
+			return err
+		}
+		recoJobArgs = append(recoJobArgs, podLabelSelectorArgs...)
 
 		excludeLabels, err := cmd.Flags().GetBool("exclude-labels")
 		if err != nil {
@@ -152,12 +328,39 @@ be a list of namespace string, for example: '["kube-system","flow-aggregator","f
 		}
 		recoJobArgs = append(recoJobArgs, "--to_services", strconv.FormatBool(toServices))
 
+		addressFamily, err := cmd.Flags().GetString("address-family")
+		if err != nil {
+			return err
+		}
+		if err := validation.OneOf("address-family", addressFamily, "ipv4", "ipv6", "dual"); err != nil {
+			return err
+		}
+		recoJobArgs = append(recoJobArgs, "--address_family", addressFamily)
+
+		ruleOrdering, err := cmd.Flags().GetString("rule-ordering")
+		if err != nil {
+			return err
+		}
+		if err := validation.OneOf("rule-ordering", ruleOrdering, "volume", "lexical"); err != nil {
+			return err
+		}
+		recoJobArgs = append(recoJobArgs, "--rule_ordering", ruleOrdering)
+
+		windowsCompatibilityStr, err := cmd.Flags().GetString("windows-compatibility")
+		if err != nil {
+			return err
+		}
+		windowsCompatibility, err := policyrecommendation.ParseWindowsCompatibility("windows-compatibility", windowsCompatibilityStr)
+		if err != nil {
+			return err
+		}
+
 		executorInstances, err := cmd.Flags().GetInt32("executor-instances")
 		if err != nil {
 			return err
 		}
-		if executorInstances < 0 {
-			return fmt.Errorf("executor-instances should be an integer >= 0")
+		if err := validation.NonNegative("executor-instances", int64(executorInstances)); err != nil {
+			return err
 		}
 		sparkResourceArgs.executorInstances = executorInstances
 
@@ -165,9 +368,8 @@ be a list of namespace string, for example: '["kube-system","flow-aggregator","f
 		if err != nil {
 			return err
 		}
-		matchResult, err := regexp.MatchString(config.K8sQuantitiesReg, driverCoreRequest)
-		if err != nil || !matchResult {
-			return fmt.Errorf("driver-core-request should conform to the Kubernetes resource quantity convention")
+		if err := validation.Quantity("driver-core-request", driverCoreRequest); err != nil {
+			return err
 		}
 		sparkResourceArgs.driverCoreRequest = driverCoreRequest
 
@@ -175,9 +377,8 @@ be a list of namespace string, for example: '["kube-system","flow-aggregator","f
 		if err != nil {
 			return err
 		}
-		matchResult, err = regexp.MatchString(config.K8sQuantitiesReg, driverMemory)
-		if err != nil || !matchResult {
-			return fmt.Errorf("driver-memory should conform to the Kubernetes resource quantity convention")
+		if err := validation.Quantity("driver-memory", driverMemory); err != nil {
+			return err
 		}
 		sparkResourceArgs.driverMemory = driverMemory
 
@@ -185,9 +386,8 @@ be a list of namespace string, for example: '["kube-system","flow-aggregator","f
 		if err != nil {
 			return err
 		}
-		matchResult, err = regexp.MatchString(config.K8sQuantitiesReg, executorCoreRequest)
-		if err != nil || !matchResult {
-			return fmt.Errorf("executor-core-request should conform to the Kubernetes resource quantity convention")
+		if err := validation.Quantity("executor-core-request", executorCoreRequest); err != nil {
+			return err
 		}
 		sparkResourceArgs.executorCoreRequest = executorCoreRequest
 
@@ -195,12 +395,104 @@ be a list of namespace string, for example: '["kube-system","flow-aggregator","f
 		if err != nil {
 			return err
 		}
-		matchResult, err = regexp.MatchString(config.K8sQuantitiesReg, executorMemory)
-		if err != nil || !matchResult {
-			return fmt.Errorf("executor-memory should conform to the Kubernetes resource quantity convention")
+		if err := validation.Quantity("executor-memory", executorMemory); err != nil {
+			return err
 		}
 		sparkResourceArgs.executorMemory = executorMemory
 
+		executorSpotPreset, err := cmd.Flags().GetString("executor-spot-preset")
+		if err != nil {
+			return err
+		}
+		executorNodeSelector, err := cmd.Flags().GetStringToString("executor-node-selector")
+		if err != nil {
+			return err
+		}
+		if err := sparkResourceArgs.setExecutorPlacement(executorSpotPreset, executorNodeSelector); err != nil {
+			return err
+		}
+
+		imagePullPolicy, err := cmd.Flags().GetString("image-pull-policy")
+		if err != nil {
+			return err
+		}
+		if err := validation.OneOf("image-pull-policy", imagePullPolicy, string(v1.PullAlways), string(v1.PullIfNotPresent), string(v1.PullNever)); err != nil {
+			return err
+		}
+		sparkResourceArgs.imagePullPolicy = imagePullPolicy
+		sparkVersion, err := cmd.Flags().GetString("spark-version")
+		if err != nil {
+			return err
+		}
+		if sparkVersion == "" {
+			return fmt.Errorf("spark-version should not be empty")
+		}
+		sparkResourceArgs.sparkVersion = sparkVersion
+
+		backend, err := cmd.Flags().GetString("backend")
+		if err != nil {
+			return err
+		}
+		if backend == executor.K8sJobBackend && (executorSpotPreset != "" || len(executorNodeSelector) > 0) {
+			return fmt.Errorf("executor-spot-preset and executor-node-selector cannot be used with the %s backend", executor.K8sJobBackend)
+		}
+
+		checkpointDir, err := cmd.Flags().GetString("checkpoint-dir")
+		if err != nil {
+			return err
+		}
+		if checkpointDir != "" {
+			recoJobArgs = append(recoJobArgs, "--checkpoint_dir", checkpointDir)
+		}
+
+		artifactsURI, err := cmd.Flags().GetString("artifacts-uri")
+		if err != nil {
+			return err
+		}
+		artifactsEndpoint, err := cmd.Flags().GetString("artifacts-endpoint")
+		if err != nil {
+			return err
+		}
+		artifactsSecret, err := cmd.Flags().GetString("artifacts-secret")
+		if err != nil {
+			return err
+		}
+		if artifactsURI != "" {
+			if _, err := s3client.ParseArtifactsURI(artifactsURI, artifactsEndpoint); err != nil {
+				return err
+			}
+			recoJobArgs = append(recoJobArgs, "--artifacts_uri", artifactsURI)
+			if artifactsEndpoint != "" {
+				recoJobArgs = append(recoJobArgs, "--artifacts_endpoint", artifactsEndpoint)
+			}
+		} else if artifactsEndpoint != "" || artifactsSecret != "" {
+			return fmt.Errorf("artifacts-endpoint and artifacts-secret can only be used with artifacts-uri")
+		}
+
+		writeBatchSize, err := cmd.Flags().GetInt("write-batch-size")
+		if err != nil {
+			return err
+		}
+		if err := validation.NonNegative("write-batch-size", int64(writeBatchSize)); err != nil {
+			return err
+		}
+		if writeBatchSize > 0 {
+			recoJobArgs = append(recoJobArgs, "--write_batch_size", strconv.Itoa(writeBatchSize))
+		}
+		writeRate, err := cmd.Flags().GetFloat64("write-rate")
+		if err != nil {
+			return err
+		}
+		if writeRate < 0 {
+			return fmt.Errorf("write-rate should be a number >= 0")
+		}
+		if writeRate > 0 {
+			if writeBatchSize == 0 {
+				return fmt.Errorf("write-rate can only be used with write-batch-size")
+			}
+			recoJobArgs = append(recoJobArgs, "--write_rate", strconv.FormatFloat(writeRate, 'f', -1, 64))
+		}
+
 		kubeconfig, err := ResolveKubeConfig(cmd)
 		if err != nil {
 			return err
@@ -209,148 +501,647 @@ be a list of namespace string, for example: '["kube-system","flow-aggregator","f
 		if err != nil {
 			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
 		}
+		jobExecutor, err := executor.New(backend, executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS, FaultInjector: faultInjector})
+		if err != nil {
+			return err
+		}
+		if err := checkRunPermissions(cmd, clientset, jobExecutor, allowNamespaceSelector != "", autoDetectSystem); err != nil {
+			return err
+		}
 
-		waitFlag, err := cmd.Flags().GetBool("wait")
+		err = CheckClickHousePod(clientset)
+		if err != nil {
+			return err
+		}
+		err = jobExecutor.PreCheck(context.TODO())
 		if err != nil {
 			return err
 		}
 
-		err = PolicyRecoPreCheck(clientset)
+		sparkImage, err := cmd.Flags().GetString("spark-image")
 		if err != nil {
 			return err
 		}
+		imageOverrides, err := loadImageOverrides(cmd)
+		if err != nil {
+			return err
+		}
+		if sparkImage != "" {
+			sparkResourceArgs.sparkImage = sparkImage
+		} else if err := sparkResourceArgs.selectSparkImage(clientset, overrideSparkImages(config.SparkImages, imageOverrides)); err != nil {
+			return err
+		}
 
-		recommendationID := uuid.New().String()
-		recoJobArgs = append(recoJobArgs, "--id", recommendationID)
-		recommendationApplication := &sparkv1.SparkApplication{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: "sparkoperator.k8s.io/v1beta2",
-				Kind:       "SparkApplication",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "pr-" + recommendationID,
-				Namespace: config.FlowVisibilityNS,
-			},
-			Spec: sparkv1.SparkApplicationSpec{
-				Type:                "Python",
-				SparkVersion:        config.SparkVersion,
-				Mode:                "cluster",
-				Image:               ConstStrToPointer(config.SparkImage),
-				ImagePullPolicy:     ConstStrToPointer(config.SparkImagePullPolicy),
-				MainApplicationFile: ConstStrToPointer(config.SparkAppFile),
-				Arguments:           recoJobArgs,
-				Driver: sparkv1.DriverSpec{
-					CoreRequest: &driverCoreRequest,
-					SparkPodSpec: sparkv1.SparkPodSpec{
-						Memory: &driverMemory,
-						Labels: map[string]string{
-							"version": config.SparkVersion,
-						},
-						EnvSecretKeyRefs: map[string]sparkv1.NameKey{
-							"CH_USERNAME": {
-								Name: "clickhouse-secret",
-								Key:  "username",
-							},
-							"CH_PASSWORD": {
-								Name: "clickhouse-secret",
-								Key:  "password",
-							},
-						},
-						ServiceAccount: ConstStrToPointer(config.SparkServiceAccount),
-					},
-				},
-				Executor: sparkv1.ExecutorSpec{
-					CoreRequest: &executorCoreRequest,
-					SparkPodSpec: sparkv1.SparkPodSpec{
-						Memory: &executorMemory,
-						Labels: map[string]string{
-							"version": config.SparkVersion,
-						},
-						EnvSecretKeyRefs: map[string]sparkv1.NameKey{
-							"CH_USERNAME": {
-								Name: "clickhouse-secret",
-								Key:  "username",
-							},
-							"CH_PASSWORD": {
-								Name: "clickhouse-secret",
-								Key:  "password",
-							},
-						},
-					},
-					Instances: &sparkResourceArgs.executorInstances,
-				},
-			},
-		}
-		response := &sparkv1.SparkApplication{}
-		err = clientset.CoreV1().RESTClient().
-			Post().
-			AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
-			Namespace(config.FlowVisibilityNS).
-			Resource("sparkapplications").
-			Body(recommendationApplication).
-			Do(context.TODO()).
-			Into(response)
-		if err != nil {
-			return err
-		}
-		if waitFlag {
-			err = wait.Poll(config.StatusCheckPollInterval, config.StatusCheckPollTimeout, func() (bool, error) {
-				state, err := getPolicyRecommendationStatus(clientset, recommendationID)
-				if err != nil {
-					return false, err
-				}
-				if state == "COMPLETED" {
-					return true, nil
-				}
-				if state == "FAILED" || state == "SUBMISSION_FAILED" || state == "FAILING" || state == "INVALIDATING" {
-					return false, fmt.Errorf("policy recommendation job failed, state: %s", state)
-				} else {
-					return false, nil
-				}
-			})
+		if len(allowNamespaces) > 0 || allowNamespaceSelector != "" || autoDetectSystem {
+			namespaces, err := resolveNamespaceAllowList(clientset, allowNamespaces, allowNamespaceSelector, autoDetectSystem)
+			if err != nil {
+				return err
+			}
+			namespacesJSON, err := json.Marshal(namespaces)
 			if err != nil {
-				if strings.Contains(err.Error(), "timed out") {
-					return fmt.Errorf(`Spark job with ID %s wait timeout of 60 minutes expired.
-Job is still running. Please check completion status for job via CLI later.`, recommendationID)
-				}
 				return err
 			}
+			recoJobArgs = append(recoJobArgs, "--ns_allow_list", string(namespacesJSON))
+		}
+		endpointServices, err := policyrecommendation.EndpointServices(context.TODO(), clientset)
+		if err != nil {
+			return err
+		}
+		endpointServicesArgs, err := policyrecommendation.EndpointServicesArgs(endpointServices)
+		if err != nil {
+			return err
+		}
+		recoJobArgs = append(recoJobArgs, endpointServicesArgs...)
+		windowsNodes, err := policyrecommendation.WindowsNodes(context.TODO(), clientset)
+		if err != nil {
+			return err
+		}
+		windowsCompatible := windowsCompatibility.Enabled(windowsNodes)
+		if windowsCompatible && len(windowsNodes) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: the cluster has Windows Nodes (%s), recommending policies which they can enforce: %s\n", strings.Join(windowsNodes, ", "), policyrecommendation.WindowsLimitations)
+		} else if len(windowsNodes) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: the cluster has Windows Nodes (%s), the recommended policies may not be enforced on them without --windows-compatibility\n", strings.Join(windowsNodes, ", "))
+		}
+		recoJobArgs = append(recoJobArgs, policyrecommendation.WindowsCompatibilityArgs(windowsCompatible)...)
 
-			endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+		endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
 			if err != nil {
 				return err
 			}
-			if endpoint != "" {
-				err = ParseEndpoint(endpoint)
-				if err != nil {
-					return err
+		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		skipTimeRangeCheck, err := cmd.Flags().GetBool("skip-time-range-check")
+		if err != nil {
+			return err
+		}
+		if !skipTimeRangeCheck && (!startTimeObj.IsZero() || !endTimeObj.IsZero()) {
+			// the connection is not kept open while the job is running
+			connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+			if err == nil {
+				var warning string
+				checkedEndTime := endTimeObj
+				if last != "" {
+					// new flow records keep being exported until the job starts
+					checkedEndTime = time.Time{}
+				}
+				warning, err = checkFlowTimeRange(connect, startTimeObj, checkedEndTime)
+				connect.Close()
+				if warning != "" {
+					fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
 				}
 			}
-			useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
-			if err != nil {
-				return err
+			if portForward != nil {
+				portForward.Stop()
 			}
-			filePath, err := cmd.Flags().GetString("file")
 			if err != nil {
 				return err
 			}
-			if err := CheckClickHousePod(clientset); err != nil {
-				return err
+		}
+
+		recommendationID := uuid.New().String()
+		recoJobArgs = append(recoJobArgs, "--id", recommendationID)
+		parameters := manifestParameters(cmd.Flags())
+		request := &executor.Request{
+			ID:              recommendationID,
+			Args:            recoJobArgs,
+			Resources:       sparkResourceArgs.resources(),
+			ArtifactsSecret: artifactsSecret,
+			Parameters:      retryParameters(parameters, startTimeObj, endTimeObj),
+		}
+		return submitPolicyRecommendationJob(cmd, clientset, kubeconfig, jobExecutor, request, parameters, nil)
+	},
+}
+
+// runFromManifest reruns a policy recommendation job from its manifest, with
+// the same arguments, resources and image.
+func runFromManifest(cmd *cobra.Command, manifestPath string) error {
+	var changed []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if !manifestExcludedFlags[flag.Name] && flag.Name != "from-manifest" {
+			changed = append(changed, flag.Name)
+		}
+	})
+	if len(changed) > 0 {
+		return fmt.Errorf("from-manifest cannot be used together with %s", strings.Join(changed, ", "))
+	}
+	manifest, err := policyrecommendation.LoadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	jobExecutor, err := executor.New(manifest.Backend, executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS, FaultInjector: faultInjector})
+	if err != nil {
+		return err
+	}
+	// The Namespaces allowed by the job are already resolved in its
+	// arguments.
+	if err := checkRunPermissions(cmd, clientset, jobExecutor, false, false); err != nil {
+		return err
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return err
+	}
+	if err := jobExecutor.PreCheck(context.TODO()); err != nil {
+		return err
+	}
+	return submitPolicyRecommendationJob(cmd, clientset, kubeconfig, jobExecutor, manifest.Request(uuid.New().String()), manifest.Parameters, manifest)
+}
+
+// checkRunPermissions checks that the user has the permissions required to run
+// a policy recommendation job with the executor. Taking a snapshot of the
+// cluster is not considered, as failing to take it only prints a warning.
+func checkRunPermissions(cmd *cobra.Command, clientset kubernetes.Interface, jobExecutor executor.Executor, namespaceSelector bool, autoDetectSystem bool) error {
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return err
+	}
+	permissions := clickHousePermissions(endpoint, useClusterIP)
+	// The Services are listed to recommend the toServices policies, and the
+	// Nodes to select the Spark image and to detect the Windows Nodes.
+	permissions = append(permissions, clusterPermission("list", "", "services"), clusterPermission("list", "", "nodes"))
+	if namespaceSelector || autoDetectSystem {
+		permissions = append(permissions, clusterPermission("list", "", "namespaces"))
+	}
+	if autoDetectSystem {
+		permissions = append(permissions, clusterPermission("list", "", "pods"))
+	}
+	permissions = append(permissions, jobExecutor.Permissions()...)
+	return checkPermissions(context.TODO(), clientset, permissions)
+}
+
+// submitPolicyRecommendationJob submits the job and records its manifest. As
+// the job runs anyway, failing to record the manifest only prints a warning.
+// When the job is rerun from a manifest, previous is that manifest.
+func submitPolicyRecommendationJob(cmd *cobra.Command, clientset kubernetes.Interface, kubeconfig string, jobExecutor executor.Executor, request *executor.Request, parameters map[string]string, previous *policyrecommendation.Manifest) error {
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return err
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return err
+	}
+	waitFlag, err := cmd.Flags().GetBool("wait")
+	if err != nil {
+		return err
+	}
+	waitOptions, err := getJobWaitOptions(cmd)
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		for _, flag := range []string{"wait", "snapshot"} {
+			if cmd.Flags().Changed(flag) {
+				return fmt.Errorf("dry-run cannot be used together with %s", flag)
 			}
-			recoResult, err := getPolicyRecommendationResult(clientset, kubeconfig, endpoint, useClusterIP, filePath, recommendationID)
-			if err != nil {
-				return err
-			} else {
-				if recoResult != "" {
-					fmt.Print(recoResult)
-				}
+		}
+		return printJobObject(cmd.OutOrStdout(), jobExecutor, request)
+	}
+	recommendationID := request.ID
+
+	var manifest *policyrecommendation.Manifest
+	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if portForward != nil {
+		defer portForward.Stop()
+	}
+	if err == nil {
+		defer connect.Close()
+		// Jobs rerun from a manifest keep the exclusions of the original job,
+		// which are part of its arguments.
+		if previous == nil {
+			if err := appendExclusionArgs(connect, request); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not read the excluded policies, they may be recommended again: %v\n", err)
 			}
+		}
+		manifest, err = newJobManifest(connect, jobExecutor.Backend(), request, parameters, time.Now())
+	} else if previous == nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read the excluded policies, they may be recommended again: %v\n", err)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record the manifest of the job: %v\n", err)
+	} else if previous != nil && manifest.FlowSchemaVersion != previous.FlowSchemaVersion {
+		fmt.Fprintf(os.Stderr, "Warning: the flow schema version is %d, the job was run with version %d\n", manifest.FlowSchemaVersion, previous.FlowSchemaVersion)
+	}
+
+	var snapshot *clusterSnapshot
+	if parameters["snapshot"] == "true" {
+		if connect == nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record the cluster snapshot of the job, ClickHouse is not reachable\n")
+		} else if snapshot, err = takeClusterSnapshot(clientset, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not take the cluster snapshot of the job: %v\n", err)
+		}
+	}
+
+	if err := jobExecutor.Submit(context.TODO(), request); err != nil {
+		return err
+	}
+	if snapshot != nil {
+		if err := saveClusterSnapshot(connect, recommendationID, snapshot); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v, upgrade ClickHouse with the Theia Helm chart to record cluster snapshots\n", err)
+		}
+	}
+	if manifest != nil {
+		if err := saveJobManifest(connect, manifest); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v, upgrade ClickHouse with the Theia Helm chart to record manifests\n", err)
+			manifest = nil
+		}
+	}
+	if !waitFlag {
+		// With --quiet, the ID is printed alone, for scripts to use it.
+		if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+			fmt.Fprintln(cmd.OutOrStdout(), recommendationID)
 			return nil
-		} else {
-			fmt.Printf("Successfully created policy recommendation job with ID %s\n", recommendationID)
 		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Successfully created policy recommendation job with ID %s\n", recommendationID)
 		return nil
-	},
+	}
+	ctx, cancel := newInterruptContext()
+	defer cancel()
+	if err := waitForPolicyRecommendationJob(ctx, clientset, recommendationID, waitOptions); err != nil {
+		return err
+	}
+	if manifest != nil {
+		if err := recordImageDigest(clientset, connect, manifest); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record the image digest of the job: %v\n", err)
+		}
+	}
+
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return err
+	}
+	recoResult, err := getPolicyRecommendationResult(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, filePath, "", "", nil, recommendationID)
+	if err != nil {
+		return err
+	}
+	if recoResult != "" {
+		fmt.Print(recoResult)
+	}
+	return nil
+}
+
+// printJobObject prints the object which would be created to run the job, as a
+// YAML manifest which can be applied later, e.g. from a GitOps repository.
+func printJobObject(out io.Writer, jobExecutor executor.Executor, request *executor.Request) error {
+	object, err := jobExecutor.Render(request)
+	if err != nil {
+		return err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return fmt.Errorf("error when encoding the policy recommendation job: %v", err)
+	}
+	return printManifests(out, []*unstructured.Unstructured{{Object: content}})
+}
+
+// jobWaitOptions configures how the CLI waits for a policy recommendation job
+// to terminate.
+type jobWaitOptions struct {
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+var defaultJobWaitOptions = jobWaitOptions{
+	pollInterval: config.StatusCheckPollInterval,
+	timeout:      config.StatusCheckPollTimeout,
+}
+
+// addJobWaitFlags adds the flags read by getJobWaitOptions to a command with a
+// wait flag.
+func addJobWaitFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration(
+		"poll-interval",
+		config.StatusCheckPollInterval,
+		"How often to check the status of the job when waiting for it.",
+	)
+	cmd.Flags().Duration(
+		"timeout",
+		config.StatusCheckPollTimeout,
+		"How long to wait for the job to terminate. The job keeps running after the timeout.",
+	)
+}
+
+func getJobWaitOptions(cmd *cobra.Command) (jobWaitOptions, error) {
+	var options jobWaitOptions
+	var err error
+	options.pollInterval, err = cmd.Flags().GetDuration("poll-interval")
+	if err != nil {
+		return options, err
+	}
+	if options.pollInterval <= 0 {
+		return options, fmt.Errorf("poll-interval should be a duration > 0")
+	}
+	options.timeout, err = cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return options, err
+	}
+	if options.timeout <= 0 {
+		return options, fmt.Errorf("timeout should be a duration > 0")
+	}
+	return options, nil
+}
+
+// newInterruptContext returns a context which is cancelled when the CLI is
+// interrupted, e.g. with Ctrl-C, so that it stops waiting and exits after
+// running the deferred cleanups, like stopping the port forwarding.
+func newInterruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// isJobTerminated returns whether a job in this state will not change state
+// anymore, or is about to fail.
+func isJobTerminated(state string) bool {
+	switch state {
+	case "COMPLETED", "FAILED", "SUBMISSION_FAILED", "FAILING", "INVALIDATING", executor.CancelledState:
+		return true
+	}
+	return false
+}
+
+// waitForJobTermination waits for the policy recommendation job to terminate
+// and returns its last state. It returns an error if the job is still running
+// after the timeout of the options, or if ctx is cancelled first.
+func waitForJobTermination(ctx context.Context, clientset kubernetes.Interface, recommendationID string, options jobWaitOptions) (string, error) {
+	var state string
+	err := wait.PollWithContext(ctx, options.pollInterval, options.timeout, func(ctx context.Context) (bool, error) {
+		var err error
+		state, err = getPolicyRecommendationStatus(ctx, clientset, recommendationID)
+		if err != nil {
+			return false, err
+		}
+		return isJobTerminated(state), nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return state, fmt.Errorf("interrupted while waiting for policy recommendation job %s, the job is still running", recommendationID)
+		}
+		if errors.Is(err, wait.ErrWaitTimeout) {
+			return state, fmt.Errorf(`Spark job with ID %s wait timeout of %v expired.
+Job is still running. Please check completion status for job via CLI later.`, recommendationID, options.timeout)
+		}
+		return state, err
+	}
+	return state, nil
+}
+
+// waitForPolicyRecommendationJob waits for the policy recommendation job to
+// complete, and returns an error if it fails or does not complete before the
+// timeout of the options.
+func waitForPolicyRecommendationJob(ctx context.Context, clientset kubernetes.Interface, recommendationID string, options jobWaitOptions) error {
+	state, err := waitForJobTermination(ctx, clientset, recommendationID, options)
+	if err != nil {
+		return err
+	}
+	if state == executor.CancelledState {
+		return fmt.Errorf("policy recommendation job was cancelled")
+	}
+	if state != "COMPLETED" {
+		return fmt.Errorf("policy recommendation job failed, state: %s", state)
+	}
+	return nil
+}
+
+// systemPodSelector selects the Pods of Antrea and of the Flow Aggregator,
+// whose Namespaces are detected as system Namespaces.
+const systemPodSelector = "app in (antrea, flow-aggregator)"
+
+// resolveNamespaceAllowList returns the sorted list of Namespaces in which all
+// traffic is allowed: the given Namespaces, the Namespaces matching the label
+// selector and, if autoDetectSystem is true, the system Namespaces, i.e. the
+// Namespaces prefixed with "kube-", the flow visibility Namespace and the
+// Namespaces running Antrea or the Flow Aggregator.
+func resolveNamespaceAllowList(clientset kubernetes.Interface, namespaces []string, selector string, autoDetectSystem bool) ([]string, error) {
+	allowList := sets.NewString(namespaces...)
+	if selector != "" {
+		nsList, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("error when listing Namespaces matching allow-namespace-selector: %v", err)
+		}
+		if len(nsList.Items) == 0 {
+			return nil, fmt.Errorf("no Namespaces match allow-namespace-selector %s", selector)
+		}
+		for _, ns := range nsList.Items {
+			allowList.Insert(ns.Name)
+		}
+	}
+	if autoDetectSystem {
+		nsList, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error when listing Namespaces: %v", err)
+		}
+		for _, ns := range nsList.Items {
+			if strings.HasPrefix(ns.Name, "kube-") || ns.Name == config.FlowVisibilityNS {
+				allowList.Insert(ns.Name)
+			}
+		}
+		pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{LabelSelector: systemPodSelector})
+		if err != nil {
+			return nil, fmt.Errorf("error when listing Antrea and Flow Aggregator Pods: %v", err)
+		}
+		for _, pod := range pods.Items {
+			allowList.Insert(pod.Namespace)
+		}
+	}
+	return allowList.List(), nil
+}
+
+// selectSparkImage selects the Spark image for the architectures of the
+// schedulable Nodes of the cluster. If the image does not support all of them,
+// the driver and executors are scheduled on the supported architecture with the
+// most Nodes. It fails if no Node has a supported architecture, rather than
+// letting the Spark Pods fail to start.
+// overrideSparkImages returns the Spark images of the architectures after the
+// image overrides are applied.
+func overrideSparkImages(images map[string]string, overrides install.ImageOverrides) map[string]string {
+	overridden := make(map[string]string, len(images))
+	for arch, image := range images {
+		overridden[arch] = overrides.Image(image)
+	}
+	return overridden
+}
+
+func (a *SparkResourceArgs) selectSparkImage(clientset kubernetes.Interface, images map[string]string) error {
+	nodes, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error when listing Nodes to select the Spark image: %v", err)
+	}
+	archNodes := map[string]int{}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		arch := node.Status.NodeInfo.Architecture
+		if arch == "" {
+			arch = node.Labels[v1.LabelArchStable]
+		}
+		archNodes[arch]++
+	}
+	if len(archNodes) == 0 {
+		return nil
+	}
+	var clusterArchs, supportedArchs []string
+	for arch := range archNodes {
+		clusterArchs = append(clusterArchs, arch)
+		if _, ok := images[arch]; ok {
+			supportedArchs = append(supportedArchs, arch)
+		}
+	}
+	sort.Strings(clusterArchs)
+	sort.Strings(supportedArchs)
+	if len(supportedArchs) == 0 {
+		var imageArchs []string
+		for arch := range images {
+			imageArchs = append(imageArchs, arch)
+		}
+		sort.Strings(imageArchs)
+		return fmt.Errorf("the Spark image supports Nodes of architecture %s, but the cluster Nodes are %s, please provide an image for these Nodes with spark-image",
+			strings.Join(imageArchs, ", "), strings.Join(clusterArchs, ", "))
+	}
+	selected := supportedArchs[0]
+	sameImage := len(supportedArchs) == len(clusterArchs)
+	for _, arch := range supportedArchs[1:] {
+		if images[arch] != images[selected] {
+			sameImage = false
+		}
+		if archNodes[arch] > archNodes[selected] {
+			selected = arch
+		}
+	}
+	a.sparkImage = images[selected]
+	if !sameImage {
+		a.nodeArchitecture = selected
+	}
+	return nil
+}
+
+// parseFlowTimeRange returns the time range of the flow records considered
+// for the policy recommendation, in UTC. A zero start or end time means no
+// limit. When last is provided, the time range ends at now, so that it is
+// recorded in the arguments of the Spark application.
+func parseFlowTimeRange(startTime, endTime, last string, location *time.Location, now time.Time) (time.Time, time.Time, error) {
+	var startTimeObj, endTimeObj time.Time
+	if last != "" {
+		if startTime != "" || endTime != "" {
+			return startTimeObj, endTimeObj, fmt.Errorf("last cannot be used together with start-time or end-time")
+		}
+		lastDuration, err := ParseDuration(last)
+		if err != nil {
+			return startTimeObj, endTimeObj, fmt.Errorf("parsing last: %v", err)
+		}
+		if lastDuration <= 0 {
+			return startTimeObj, endTimeObj, fmt.Errorf("last should be a positive duration")
+		}
+		endTimeObj = now.UTC().Truncate(time.Second)
+		return endTimeObj.Add(-lastDuration), endTimeObj, nil
+	}
+	var err error
+	if startTime != "" {
+		startTimeObj, err = ParseTimestamp(startTime, location)
+		if err != nil {
+			return startTimeObj, endTimeObj, fmt.Errorf("parsing start-time: %v", err)
+		}
+	}
+	if endTime != "" {
+		endTimeObj, err = ParseTimestamp(endTime, location)
+		if err != nil {
+			return startTimeObj, endTimeObj, fmt.Errorf("parsing end-time: %v", err)
+		}
+		if !endTimeObj.After(startTimeObj) {
+			return startTimeObj, endTimeObj, fmt.Errorf("end-time should be after start-time")
+		}
+	}
+	return startTimeObj, endTimeObj, nil
+}
+
+// checkFlowTimeRange compares the time range of the flow records considered
+// for the policy recommendation with the time range of the flow records stored
+// in ClickHouse. A zero start or end time means no limit. It returns an error
+// if no flow records are in the requested range, and a warning if the range is
+// only partially covered by flow records.
+func checkFlowTimeRange(connect *sql.DB, startTime, endTime time.Time) (string, error) {
+	var count uint64
+	var minTime, maxTime time.Time
+	query := "SELECT count(), min(flowStartSeconds), max(flowEndSeconds) FROM flows"
+	if err := connect.QueryRow(query).Scan(&count, &minTime, &maxTime); err != nil {
+		return "", fmt.Errorf("error when getting the time range of flow records from ClickHouse: %v", err)
+	}
+	if count == 0 {
+		return "", fmt.Errorf("no flow records in ClickHouse, the policy recommendation job would have no input")
+	}
+	available := fmt.Sprintf("flow records are available from %s to %s", FormatTimestamp(minTime), FormatTimestamp(maxTime))
+	if !startTime.IsZero() && startTime.After(maxTime) || !endTime.IsZero() && !endTime.After(minTime) {
+		return "", fmt.Errorf("no flow records between start-time and end-time, %s", available)
+	}
+	if !startTime.IsZero() && startTime.Before(minTime) || !endTime.IsZero() && endTime.After(maxTime) {
+		return fmt.Sprintf("the time range between start-time and end-time is only partially covered, %s", available), nil
+	}
+	return "", nil
+}
+
+// resources returns the resources of the policy recommendation job.
+func (a *SparkResourceArgs) resources() executor.Resources {
+	return executor.Resources{
+		ExecutorInstances:    a.executorInstances,
+		DriverCoreRequest:    a.driverCoreRequest,
+		DriverMemory:         a.driverMemory,
+		ExecutorCoreRequest:  a.executorCoreRequest,
+		ExecutorMemory:       a.executorMemory,
+		ExecutorNodeSelector: a.executorNodeSelector,
+		ExecutorTolerations:  a.executorTolerations,
+		SparkConf:            a.sparkConf,
+		Image:                a.sparkImage,
+		ImagePullPolicy:      a.imagePullPolicy,
+		SparkVersion:         a.sparkVersion,
+		NodeArchitecture:     a.nodeArchitecture,
+	}
+}
+
+// newPolicyRecommendationApplication returns the Spark application running the
+// policy recommendation job with the given ID and arguments.
+func newPolicyRecommendationApplication(recommendationID string, recoJobArgs []string, sparkResourceArgs *SparkResourceArgs) *sparkv1.SparkApplication {
+	return executor.NewSparkApplication(config.FlowVisibilityNS, &executor.Request{
+		ID:        recommendationID,
+		Args:      recoJobArgs,
+		Resources: sparkResourceArgs.resources(),
+	})
+}
+
+func createSparkApplication(clientset kubernetes.Interface, sparkApplication *sparkv1.SparkApplication) error {
+	return executor.CreateSparkApplication(context.TODO(), clientset, sparkApplication, faultInjector)
 }
 
 func init() {
@@ -358,8 +1149,8 @@ func init() {
 	policyRecommendationRunCmd.Flags().StringP(
 		"type",
 		"t",
-		"initial",
-		"{initial|subsequent} Indicates this recommendation is an initial recommendion or a subsequent recommendation job.",
+		string(policyrecommendation.DefaultRecommendationType),
+		policyrecommendation.RecommendationTypeHelp(),
 	)
 	policyRecommendationRunCmd.Flags().IntP(
 		"limit",
@@ -370,35 +1161,76 @@ func init() {
 	policyRecommendationRunCmd.Flags().StringP(
 		"policy-type",
 		"p",
-		"anp-deny-applied",
-		`Types of generated NetworkPolicy.
-Currently we have 3 generated NetworkPolicy types:
-anp-deny-applied: Recommending allow ANP/ACNP policies, with default deny rules only on Pods which have an allow rule applied.
-anp-deny-all: Recommending allow ANP/ACNP policies, with default deny rules for whole cluster.
-k8s-np: Recommending allow K8s NetworkPolicies.`,
+		string(policyrecommendation.DefaultPolicyType),
+		policyrecommendation.PolicyTypeHelp(),
 	)
 	policyRecommendationRunCmd.Flags().StringP(
 		"start-time",
 		"s",
 		"",
 		`The start time of the flow records considered for the policy recommendation.
-Format is YYYY-MM-DD hh:mm:ss in UTC timezone. No limit of the start time of flow records by default.`,
+Format is RFC3339 (e.g. 2022-01-01T00:00:00-08:00), or YYYY-MM-DD hh:mm:ss in the time zone given by --timezone.
+No limit of the start time of flow records by default.`,
 	)
 	policyRecommendationRunCmd.Flags().StringP(
 		"end-time",
 		"e",
 		"",
 		`The end time of the flow records considered for the policy recommendation.
-Format is YYYY-MM-DD hh:mm:ss in UTC timezone. No limit of the end time of flow records by default.`,
+Format is RFC3339 (e.g. 2022-01-31T23:59:59-08:00), or YYYY-MM-DD hh:mm:ss in the time zone given by --timezone.
+No limit of the end time of flow records by default.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"last",
+		"",
+		`Only consider the flow records of the given duration before the job submission, e.g. 24h or 7d.
+Cannot be used together with start-time and end-time.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"timezone",
+		"UTC",
+		`The time zone of start-time and end-time when they are in YYYY-MM-DD hh:mm:ss format.
+Can be UTC, Local or an IANA time zone name, e.g. America/Los_Angeles.`,
+	)
+	policyRecommendationRunCmd.Flags().StringSlice(
+		"allow-namespaces",
+		nil,
+		`Comma-separated list of Namespaces in which all traffic is allowed by default.
+If no Namespaces are provided with allow-namespaces, allow-namespace-selector or auto-detect-system,
+Traffic inside Antrea CNI related Namespaces: ['kube-system', 'flow-aggregator', 'flow-visibility'] will be
+allowed by default.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"allow-namespace-selector",
+		"",
+		"Label selector of Namespaces in which all traffic is allowed by default, e.g. env=infra.",
+	)
+	policyRecommendationRunCmd.Flags().Bool(
+		"auto-detect-system",
+		false,
+		`Allow all traffic by default in the system Namespaces discovered from the cluster: the Namespaces
+prefixed with 'kube-', the flow visibility Namespace and the Namespaces running Antrea or the Flow Aggregator.`,
+	)
+	policyRecommendationRunCmd.Flags().StringSlice(
+		"target-namespaces",
+		nil,
+		`Comma-separated list of Namespaces to which the recommendation is restricted. Only the flows from or to
+the Pods of these Namespaces are considered, and only the policies applying to them are recommended.
+Namespaces prefixed with '!' are excluded instead, e.g. '!kube-system,!monitoring' targets all the other Namespaces.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"pod-label-selector",
+		"",
+		`Label selector, e.g. 'app=web' or 'tier in (frontend,backend)', to which the recommendation is restricted.
+Only the flows from or to the Pods matching it are considered, and only the policies applying to them are recommended.`,
 	)
 	policyRecommendationRunCmd.Flags().StringP(
 		"ns-allow-list",
 		"n",
 		"",
-		`List of default allow Namespaces.
-If no Namespaces provided, Traffic inside Antrea CNI related Namespaces: ['kube-system', 'flow-aggregator',
-'flow-visibility'] will be allowed by default.`,
+		`List of default allow Namespaces in JSON format.`,
 	)
+	policyRecommendationRunCmd.Flags().MarkDeprecated("ns-allow-list", "use --allow-namespaces instead")
 	policyRecommendationRunCmd.Flags().Bool(
 		"exclude-labels",
 		true,
@@ -408,8 +1240,35 @@ If no Namespaces provided, Traffic inside Antrea CNI related Namespaces: ['kube-
 	policyRecommendationRunCmd.Flags().Bool(
 		"to-services",
 		true,
-		`Use the toServices feature in ANP and recommendation toServices rules for Pod-to-Service flows,
-only works when option is anp-deny-applied or anp-deny-all.`,
+		fmt.Sprintf(`Use the toServices feature in ANP and recommendation toServices rules for Pod-to-Service flows,
+only works when option is %s.`, policyrecommendation.ToServicesPolicyTypesHelp()),
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"address-family",
+		"dual",
+		`{ipv4|ipv6|dual} Address family of the flow records considered for the recommendation. dual considers the
+flow records of both families, and recommends ipBlock rules in both families when there are flow records in both.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"rule-ordering",
+		"volume",
+		`{volume|lexical} Order of the rules within each recommended policy. volume orders them by decreasing
+volume of the flows they match, so that the hottest flows match the first rules in the datapath. lexical
+orders them by peer, which keeps the result stable across jobs.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"windows-compatibility",
+		string(policyrecommendation.WindowsCompatibilityAuto),
+		fmt.Sprintf(`{auto|enabled|disabled} Recommend policies which the Antrea Agents running on Windows Nodes can enforce:
+%s. auto enables it when the cluster has Windows Nodes.`, policyrecommendation.WindowsLimitations),
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"backend",
+		executor.DefaultBackend,
+		`{spark|k8s-job} Backend running the policy recommendation job. spark runs the job as a Spark application
+managed by the Spark Operator. k8s-job runs the job in a single Kubernetes Job Pod, with Spark in local mode,
+and does not require the Spark Operator. It is suited to small recommendation jobs: the Pod gets the
+driver-core-request and driver-memory resources, and the executor options are ignored.`,
 	)
 	policyRecommendationRunCmd.Flags().Int32(
 		"executor-instances",
@@ -439,16 +1298,123 @@ Example values include 0.1, 500m, 1.5, 5, etc.`,
 		"512M",
 		`Specify the memory request for the executor Pod. Values conform to the Kubernetes resource quantity convention.
 Example values include 512M, 1G, 8G, etc.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"executor-spot-preset",
+		"",
+		`Run the executors on the spot (or preemptible) nodes of a managed Kubernetes service: eks, gke or aks.
+Graceful decommissioning of executors is enabled. The placement of the driver is unchanged.`,
+	)
+	policyRecommendationRunCmd.Flags().StringToString(
+		"executor-node-selector",
+		nil,
+		"Node selector of the executor Pods, e.g. node.kubernetes.io/lifecycle=spot.",
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"spark-image",
+		"",
+		`Spark image of the policy recommendation job. By default, the image is selected based on the architecture
+of the cluster Nodes.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"images-file",
+		"",
+		`Image overrides file rendered by "theia images import". The Spark image is replaced by its override, unless
+spark-image is provided.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"image-pull-policy",
+		config.SparkImagePullPolicy,
+		"Pull policy of the Spark image of the policy recommendation job: Always, IfNotPresent or Never.",
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"spark-version",
+		config.SparkVersion,
+		`Version of Spark in the Spark image of the policy recommendation job. It should be set when spark-image is an
+image built with another version of Spark.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"checkpoint-dir",
+		"",
+		`Directory used by Spark to checkpoint the flow records read from the database, so that they are not read
+again when executors are lost. It must be accessible from all Spark Pods, e.g. an hdfs:// or s3a:// URL supported
+by the Spark image.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"artifacts-uri",
+		"",
+		`s3://<bucket>/<prefix> or gs://<bucket>/<prefix> URI the logs, the metrics and the result of the job
+are uploaded to, under <prefix>/<job ID>/. They can be retrieved with "theia policy-recommendation artifacts".`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"artifacts-endpoint",
+		"",
+		"Endpoint of the S3-compatible object storage service, e.g. a MinIO server, the artifacts are uploaded to.",
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"artifacts-secret",
+		"",
+		`Secret in the flow-visibility Namespace with the access-key-id and secret-access-key used to upload
+the artifacts. By default, the credentials of the Spark driver Pod (e.g. its IAM role) are used.`,
+	)
+	policyRecommendationRunCmd.Flags().Int(
+		"write-batch-size",
+		0,
+		`Maximum number of recommended policies written to ClickHouse in a single insert. Large results are
+split into parts written by separate inserts, so that they do not time out. 0 means no limit.`,
+	)
+	policyRecommendationRunCmd.Flags().Float64(
+		"write-rate",
+		0,
+		`Maximum number of inserts per second when the result is written in several parts, e.g. 0.5 for at
+most one insert every 2 seconds. 0 means no limit. Can only be used with write-batch-size.`,
 	)
 	policyRecommendationRunCmd.Flags().Bool(
 		"wait",
 		false,
 		"Enable this option will hold and wait the whole policy recommendation job finishes.",
 	)
+	addJobWaitFlags(policyRecommendationRunCmd)
+	policyRecommendationRunCmd.Flags().Bool(
+		"dry-run",
+		false,
+		`Validate the job and print the manifest of the SparkApplication (or of the Job with the k8s-job backend)
+which would be created to run it, instead of creating it. The job can be run later by applying the manifest.`,
+	)
+	policyRecommendationRunCmd.Flags().Bool(
+		"snapshot",
+		false,
+		`Snapshot the Namespaces, Services and Pod labels of the cluster when submitting the job, so that the
+label selectors of the recommended policies can later be resolved as they were when the flows were observed,
+with "retrieve --resolve-selectors".`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"from-manifest",
+		"",
+		`Rerun a policy recommendation job identically from its manifest, as written by "retrieve --manifest".
+The job is run with the same arguments, resources and image, pinned to its digest if known. Cannot be used
+together with the flags configuring the job.`,
+	)
+	policyRecommendationRunCmd.Flags().String(
+		"job-spec",
+		"",
+		`Read the flags configuring the job from this YAML file, which maps flag names to their values, so that the job
+can be version-controlled and repeated. The flags set on the command line override the values of the file, which
+override the values set from the environment variables and the config file.`,
+	)
+	policyRecommendationRunCmd.Flags().Bool(
+		"skip-time-range-check",
+		false,
+		`Skip checking that ClickHouse has flow records between start-time and end-time before
+running the policy recommendation job.`,
+	)
 	policyRecommendationRunCmd.Flags().StringP(
 		"file",
 		"f",
 		"",
 		"The file path where you want to save the result. It can only be used when wait is enabled.",
 	)
+	// The retry command accepts the flags of the run command, to override the
+	// parameters of the retried job.
+	policyRecommendationRetryCmd.Flags().AddFlagSet(policyRecommendationRunCmd.Flags())
 }
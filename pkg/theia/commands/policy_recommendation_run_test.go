@@ -0,0 +1,492 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+func TestParseFlowTimeRange(t *testing.T) {
+	now := time.Date(2022, 8, 10, 12, 30, 15, 500, time.UTC)
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	testCases := []struct {
+		name          string
+		startTime     string
+		endTime       string
+		last          string
+		expectedStart time.Time
+		expectedEnd   time.Time
+		expectedErr   string
+	}{
+		{
+			name: "no limit",
+		},
+		{
+			name:          "start and end time",
+			startTime:     "2022-08-01 00:00:00",
+			endTime:       "2022-08-02T00:00:00Z",
+			expectedStart: time.Date(2022, 8, 1, 7, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "last 7 days",
+			last:          "7d",
+			expectedStart: time.Date(2022, 8, 3, 12, 30, 15, 0, time.UTC),
+			expectedEnd:   time.Date(2022, 8, 10, 12, 30, 15, 0, time.UTC),
+		},
+		{
+			name:        "last with start time",
+			startTime:   "2022-08-01 00:00:00",
+			last:        "24h",
+			expectedErr: "last cannot be used together with start-time or end-time",
+		},
+		{
+			name:        "invalid last",
+			last:        "0h",
+			expectedErr: "last should be a positive duration",
+		},
+		{
+			name:        "end time before start time",
+			startTime:   "2022-08-02 00:00:00",
+			endTime:     "2022-08-01 00:00:00",
+			expectedErr: "end-time should be after start-time",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parseFlowTimeRange(tt.startTime, tt.endTime, tt.last, losAngeles, now)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStart, start)
+			assert.Equal(t, tt.expectedEnd, end)
+		})
+	}
+}
+
+func TestCheckFlowTimeRange(t *testing.T) {
+	minTime := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	maxTime := time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC)
+	available := "flow records are available from 2022-08-01 00:00:00 to 2022-08-02 00:00:00"
+	testCases := []struct {
+		name            string
+		count           uint64
+		startTime       time.Time
+		endTime         time.Time
+		expectedWarning string
+		expectedErr     string
+	}{
+		{
+			name:      "covered range",
+			count:     100,
+			startTime: minTime.Add(time.Hour),
+			endTime:   maxTime.Add(-time.Hour),
+		},
+		{
+			name:      "no end time",
+			count:     100,
+			startTime: minTime,
+		},
+		{
+			name:        "no flow records",
+			startTime:   minTime,
+			expectedErr: "no flow records in ClickHouse",
+		},
+		{
+			name:        "start time after last flow",
+			count:       100,
+			startTime:   maxTime.Add(time.Hour),
+			expectedErr: "no flow records between start-time and end-time, " + available,
+		},
+		{
+			name:        "end time before first flow",
+			count:       100,
+			startTime:   minTime.Add(-2 * time.Hour),
+			endTime:     minTime,
+			expectedErr: "no flow records between start-time and end-time, " + available,
+		},
+		{
+			name:            "partially covered range",
+			count:           100,
+			startTime:       minTime.Add(-time.Hour),
+			endTime:         maxTime.Add(-time.Hour),
+			expectedWarning: "the time range between start-time and end-time is only partially covered, " + available,
+		},
+		{
+			name:            "end time after last flow",
+			count:           100,
+			endTime:         maxTime.Add(time.Hour),
+			expectedWarning: "the time range between start-time and end-time is only partially covered, " + available,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT count(), min(flowStartSeconds), max(flowEndSeconds) FROM flows")).
+				WillReturnRows(sqlmock.NewRows([]string{"count()", "min(flowStartSeconds)", "max(flowEndSeconds)"}).AddRow(tt.count, minTime, maxTime))
+			warning, err := checkFlowTimeRange(db, tt.startTime, tt.endTime)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedWarning, warning)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestResolveNamespaceAllowList(t *testing.T) {
+	newNamespace := func(name string, labels map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	newPod := func(namespace, name, app string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": app}}}
+	}
+	clientset := fake.NewSimpleClientset(
+		newNamespace("kube-system", nil),
+		newNamespace("kube-public", nil),
+		newNamespace("flow-visibility", nil),
+		newNamespace("antrea-system", nil),
+		newNamespace("monitoring", map[string]string{"env": "infra"}),
+		newNamespace("logging", map[string]string{"env": "infra"}),
+		newNamespace("app", map[string]string{"env": "prod"}),
+		newPod("antrea-system", "antrea-agent-x8kd2", "antrea"),
+		newPod("antrea-system", "antrea-controller-6f7c9", "antrea"),
+		newPod("app", "frontend", "frontend"),
+	)
+	testCases := []struct {
+		name             string
+		namespaces       []string
+		selector         string
+		autoDetectSystem bool
+		expected         []string
+		expectedErr      string
+	}{
+		{
+			name:       "namespaces only",
+			namespaces: []string{"ns-b", "ns-a", "ns-b"},
+			expected:   []string{"ns-a", "ns-b"},
+		},
+		{
+			name:       "namespaces and selector",
+			namespaces: []string{"app"},
+			selector:   "env=infra",
+			expected:   []string{"app", "logging", "monitoring"},
+		},
+		{
+			name:        "selector without match",
+			selector:    "env=dev",
+			expectedErr: "no Namespaces match allow-namespace-selector env=dev",
+		},
+		{
+			name:             "auto-detect system namespaces",
+			autoDetectSystem: true,
+			expected:         []string{"antrea-system", "flow-visibility", "kube-public", "kube-system"},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			namespaces, err := resolveNamespaceAllowList(clientset, tt.namespaces, tt.selector, tt.autoDetectSystem)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, namespaces)
+		})
+	}
+}
+
+func TestSetExecutorPlacement(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		preset               string
+		nodeSelector         map[string]string
+		expectedNodeSelector map[string]string
+		expectedTolerations  []v1.Toleration
+		expectedSparkConf    map[string]string
+		expectedErr          string
+	}{
+		{
+			name: "default placement",
+		},
+		{
+			name:                 "node selector",
+			nodeSelector:         map[string]string{"node.kubernetes.io/lifecycle": "spot"},
+			expectedNodeSelector: map[string]string{"node.kubernetes.io/lifecycle": "spot"},
+		},
+		{
+			name:                 "gke preset with node selector",
+			preset:               "gke",
+			nodeSelector:         map[string]string{"pool": "spark"},
+			expectedNodeSelector: map[string]string{"cloud.google.com/gke-spot": "true", "pool": "spark"},
+			expectedTolerations: []v1.Toleration{{
+				Key:      "cloud.google.com/gke-spot",
+				Operator: v1.TolerationOpEqual,
+				Value:    "true",
+				Effect:   v1.TaintEffectNoSchedule,
+			}},
+			expectedSparkConf: decommissionSparkConf,
+		},
+		{
+			name:                 "eks preset",
+			preset:               "eks",
+			expectedNodeSelector: map[string]string{"eks.amazonaws.com/capacityType": "SPOT"},
+			expectedSparkConf:    decommissionSparkConf,
+		},
+		{
+			name:        "unknown preset",
+			preset:      "gce",
+			expectedErr: `executor-spot-preset should be eks, gke or aks, did you mean "gke"?`,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			args := &SparkResourceArgs{}
+			err := args.setExecutorPlacement(tt.preset, tt.nodeSelector)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			app := newPolicyRecommendationApplication("e998433e-accb-4888-9fc8-06563f073e86", nil, args)
+			assert.Equal(t, tt.expectedNodeSelector, app.Spec.Executor.NodeSelector)
+			assert.Equal(t, tt.expectedTolerations, app.Spec.Executor.Tolerations)
+			assert.Equal(t, tt.expectedSparkConf, app.Spec.SparkConf)
+			assert.Nil(t, app.Spec.Driver.NodeSelector)
+			assert.Nil(t, app.Spec.Driver.Tolerations)
+		})
+	}
+}
+
+func TestSelectSparkImage(t *testing.T) {
+	newNode := func(name string, arch string, unschedulable bool) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.NodeSpec{Unschedulable: unschedulable},
+			Status:     v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{Architecture: arch}},
+		}
+	}
+	amd64Images := map[string]string{"amd64": "theia-policy-recommendation:latest"}
+	multiArchImages := map[string]string{"amd64": "theia-policy-recommendation:latest", "arm64": "theia-policy-recommendation:latest"}
+	testCases := []struct {
+		name                     string
+		nodes                    []*v1.Node
+		images                   map[string]string
+		expectedImage            string
+		expectedNodeArchitecture string
+		expectedErr              string
+	}{
+		{
+			name:          "amd64 cluster",
+			nodes:         []*v1.Node{newNode("node-1", "amd64", false), newNode("node-2", "arm64", true)},
+			images:        amd64Images,
+			expectedImage: "theia-policy-recommendation:latest",
+		},
+		{
+			name:                     "mixed cluster",
+			nodes:                    []*v1.Node{newNode("node-1", "amd64", false), newNode("node-2", "arm64", false), newNode("node-3", "arm64", false)},
+			images:                   amd64Images,
+			expectedImage:            "theia-policy-recommendation:latest",
+			expectedNodeArchitecture: "amd64",
+		},
+		{
+			name:          "mixed cluster with multi-arch image",
+			nodes:         []*v1.Node{newNode("node-1", "amd64", false), newNode("node-2", "arm64", false)},
+			images:        multiArchImages,
+			expectedImage: "theia-policy-recommendation:latest",
+		},
+		{
+			name:  "mixed cluster with per-arch images",
+			nodes: []*v1.Node{newNode("node-1", "amd64", false), newNode("node-2", "arm64", false), newNode("node-3", "arm64", false)},
+			images: map[string]string{
+				"amd64": "theia-policy-recommendation:latest",
+				"arm64": "theia-policy-recommendation-arm64:latest",
+			},
+			expectedImage:            "theia-policy-recommendation-arm64:latest",
+			expectedNodeArchitecture: "arm64",
+		},
+		{
+			name:        "arm64 cluster",
+			nodes:       []*v1.Node{newNode("node-1", "arm64", false), newNode("node-2", "arm64", false)},
+			images:      amd64Images,
+			expectedErr: "the Spark image supports Nodes of architecture amd64, but the cluster Nodes are arm64, please provide an image for these Nodes with spark-image",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			for _, node := range tt.nodes {
+				_, err := clientset.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			args := &SparkResourceArgs{executorNodeSelector: map[string]string{"pool": "spark"}}
+			err := args.selectSparkImage(clientset, tt.images)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			app := newPolicyRecommendationApplication("e998433e-accb-4888-9fc8-06563f073e86", nil, args)
+			assert.Equal(t, tt.expectedImage, *app.Spec.Image)
+			if tt.expectedNodeArchitecture != "" {
+				assert.Equal(t, map[string]string{v1.LabelArchStable: tt.expectedNodeArchitecture}, app.Spec.Driver.NodeSelector)
+				assert.Equal(t, map[string]string{v1.LabelArchStable: tt.expectedNodeArchitecture, "pool": "spark"}, app.Spec.Executor.NodeSelector)
+			} else {
+				assert.Nil(t, app.Spec.Driver.NodeSelector)
+				assert.Equal(t, map[string]string{"pool": "spark"}, app.Spec.Executor.NodeSelector)
+			}
+		})
+	}
+}
+
+func TestWaitForPolicyRecommendationJob(t *testing.T) {
+	const id = "e998433e-accb-4888-9fc8-06563f073e86"
+	newJob := func(conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pr-" + id,
+				Namespace: config.FlowVisibilityNS,
+				Labels:    map[string]string{executor.RecommendationIDLabel: id},
+			},
+			Status: batchv1.JobStatus{Active: 1, Conditions: conditions},
+		}
+	}
+	options := jobWaitOptions{pollInterval: 10 * time.Millisecond, timeout: 50 * time.Millisecond}
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testCases := []struct {
+		name          string
+		ctx           context.Context
+		job           *batchv1.Job
+		expectedError string
+	}{
+		{
+			name: "completed job",
+			ctx:  context.Background(),
+			job:  newJob(batchv1.JobCondition{Type: batchv1.JobComplete, Status: v1.ConditionTrue}),
+		},
+		{
+			name:          "failed job",
+			ctx:           context.Background(),
+			job:           newJob(batchv1.JobCondition{Type: batchv1.JobFailed, Status: v1.ConditionTrue}),
+			expectedError: "policy recommendation job failed, state: FAILED",
+		},
+		{
+			name:          "timeout",
+			ctx:           context.Background(),
+			job:           newJob(),
+			expectedError: "Spark job with ID e998433e-accb-4888-9fc8-06563f073e86 wait timeout of 50ms expired",
+		},
+		{
+			name:          "interrupted",
+			ctx:           cancelledCtx,
+			job:           newJob(),
+			expectedError: "interrupted while waiting for policy recommendation job e998433e-accb-4888-9fc8-06563f073e86, the job is still running",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.job)
+			err := waitForPolicyRecommendationJob(tt.ctx, clientset, id, options)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetJobWaitOptions(t *testing.T) {
+	cmd := &cobra.Command{}
+	addJobWaitFlags(cmd)
+	options, err := getJobWaitOptions(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, defaultJobWaitOptions, options)
+
+	require.NoError(t, cmd.Flags().Parse([]string{"--poll-interval", "30s", "--timeout", "2h"}))
+	options, err = getJobWaitOptions(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, jobWaitOptions{pollInterval: 30 * time.Second, timeout: 2 * time.Hour}, options)
+
+	require.NoError(t, cmd.Flags().Parse([]string{"--timeout", "0s"}))
+	_, err = getJobWaitOptions(cmd)
+	assert.EqualError(t, err, "timeout should be a duration > 0")
+}
+
+func TestPrintJobObject(t *testing.T) {
+	const id = "e998433e-accb-4888-9fc8-06563f073e86"
+	request := &executor.Request{
+		ID:   id,
+		Args: []string{"--type", "initial", "--id", id},
+		Resources: executor.Resources{
+			ExecutorInstances:   1,
+			DriverCoreRequest:   "200m",
+			DriverMemory:        "512M",
+			ExecutorCoreRequest: "200m",
+			ExecutorMemory:      "512M",
+		},
+	}
+	testCases := []struct {
+		backend    string
+		apiVersion string
+		kind       string
+	}{
+		{backend: executor.SparkOperatorBackend, apiVersion: "sparkoperator.k8s.io/v1beta2", kind: "SparkApplication"},
+		{backend: executor.K8sJobBackend, apiVersion: "batch/v1", kind: "Job"},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.backend, func(t *testing.T) {
+			jobExecutor, err := executor.New(tt.backend, executor.Options{Clientset: fake.NewSimpleClientset(), Namespace: config.FlowVisibilityNS})
+			require.NoError(t, err)
+			var out bytes.Buffer
+			require.NoError(t, printJobObject(&out, jobExecutor, request))
+			require.True(t, strings.HasPrefix(out.String(), "---\n"))
+			object := &unstructured.Unstructured{}
+			require.NoError(t, k8syaml.Unmarshal(out.Bytes()[4:], &object.Object))
+			assert.Equal(t, tt.apiVersion, object.GetAPIVersion())
+			assert.Equal(t, tt.kind, object.GetKind())
+			assert.Equal(t, "pr-"+id, object.GetName())
+			assert.Equal(t, config.FlowVisibilityNS, object.GetNamespace())
+			assert.Contains(t, out.String(), "- --id\n")
+		})
+	}
+}
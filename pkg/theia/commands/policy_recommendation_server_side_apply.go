@@ -0,0 +1,183 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	"antrea.io/theia/pkg/util/sink"
+)
+
+// fieldManager is the field manager of the recommended policies applied by
+// the CLI with server-side apply.
+const fieldManager = "theia"
+
+// applyOptions are the options of the server-side apply of the recommended
+// policies.
+type applyOptions struct {
+	// recoID, if not empty, is the ID of the job which recommended the
+	// policies.
+	recoID string
+	// scope, if not empty, is set as the scope label of the policies.
+	scope          string
+	forceConflicts bool
+	// dryRun submits the requests to the apiserver without persisting the
+	// policies.
+	dryRun bool
+}
+
+// labelAppliedPolicies sets the labels and annotations of the applied
+// policies, which are used to select the policies to prune.
+func labelAppliedPolicies(policies []map[string]interface{}, options applyOptions) {
+	namespaces := strings.Join(policyNamespaces(policies).List(), ",")
+	for _, policy := range policies {
+		obj := &unstructured.Unstructured{Object: policy}
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[appliedByLabel] = appliedBy
+		if options.recoID != "" {
+			labels[sink.RecommendationIDLabel] = options.recoID
+		}
+		if options.scope != "" {
+			labels[appliedScopeLabel] = options.scope
+		}
+		obj.SetLabels(labels)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[appliedNamespacesAnnotation] = namespaces
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// serverSideApplyPolicies labels the recommended policies as applied by theia,
+// applies them with server-side apply, and writes the result of each policy to
+// out. Policies which cannot be applied, e.g. because of conflicts with fields
+// owned by another field manager, are reported and skipped, and an error is
+// returned once all the policies have been applied.
+func serverSideApplyPolicies(out io.Writer, client rest.Interface, policies []map[string]interface{}, options applyOptions) error {
+	labelAppliedPolicies(policies, options)
+	suffix := ""
+	if options.dryRun {
+		suffix = " (server dry run)"
+	}
+	failed := 0
+	for _, policy := range policies {
+		result, err := serverSideApplyPolicy(client, policy, options.forceConflicts, options.dryRun)
+		if err == nil {
+			fmt.Fprintf(out, "%s %s %s%s\n", policy["kind"], policyObjectName(policy), result, suffix)
+			continue
+		}
+		failed++
+		if status, ok := err.(errors.APIStatus); ok && errors.IsConflict(err) && status.Status().Details != nil {
+			fmt.Fprintf(out, "%s %s conflicts with other field managers:\n", policy["kind"], policyObjectName(policy))
+			for _, cause := range status.Status().Details.Causes {
+				fmt.Fprintf(out, "  %s: %s\n", cause.Field, cause.Message)
+			}
+			continue
+		}
+		fmt.Fprintf(out, "%s %s failed: %v\n", policy["kind"], policyObjectName(policy), err)
+	}
+	if failed > 0 {
+		if options.forceConflicts {
+			return fmt.Errorf("%d out of %d recommended policies could not be applied", failed, len(policies))
+		}
+		return fmt.Errorf("%d out of %d recommended policies could not be applied, use force-conflicts to take ownership of the conflicting fields", failed, len(policies))
+	}
+	return nil
+}
+
+// serverSideApplyPolicy applies the Antrea-native policy, ClusterGroup or
+// Kubernetes NetworkPolicy with server-side apply, and returns whether it was
+// created, configured or left unchanged. With dryRun, the policy is not
+// persisted.
+func serverSideApplyPolicy(client rest.Interface, policy map[string]interface{}, forceConflicts bool, dryRun bool) (string, error) {
+	apiVersion, _ := policy["apiVersion"].(string)
+	kind, _ := policy["kind"].(string)
+	resource, err := policyResource(apiVersion, kind)
+	if err != nil {
+		return "", err
+	}
+	metadata, _ := policy["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	newRequest := func(request *rest.Request) *rest.Request {
+		request = request.AbsPath("/apis/" + apiVersion)
+		if namespace != "" {
+			request = request.Namespace(namespace)
+		}
+		return request.Resource(resource).Name(name)
+	}
+	var existing, applied struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+		Spec     interface{}       `json:"spec"`
+	}
+	// Result.Error decodes the Status returned by the apiserver, which
+	// includes the conflicting fields.
+	result := newRequest(client.Get()).Do(context.TODO())
+	if err := result.Error(); err != nil && !errors.IsNotFound(err) {
+		return "", err
+	} else if err == nil {
+		data, _ := result.Raw()
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return "", fmt.Errorf("error when decoding %s %s: %v", policy["kind"], name, err)
+		}
+	}
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	request := newRequest(client.Patch(types.ApplyPatchType)).Param("fieldManager", fieldManager)
+	if forceConflicts {
+		request = request.Param("force", "true")
+	}
+	if dryRun {
+		request = request.Param("dryRun", metav1.DryRunAll)
+	}
+	result = request.Body(body).Do(context.TODO())
+	if err := result.Error(); err != nil {
+		return "", err
+	}
+	data, _ := result.Raw()
+	if err := json.Unmarshal(data, &applied); err != nil {
+		return "", fmt.Errorf("error when decoding %s %s: %v", policy["kind"], name, err)
+	}
+	switch {
+	case existing.Metadata.ResourceVersion == "":
+		return "created", nil
+	// the resource version is not updated by dry runs
+	case existing.Metadata.ResourceVersion == applied.Metadata.ResourceVersion &&
+		equality.Semantic.DeepEqual(existing.Spec, applied.Spec) &&
+		equality.Semantic.DeepEqual(existing.Metadata.Labels, applied.Metadata.Labels) &&
+		equality.Semantic.DeepEqual(existing.Metadata.Annotations, applied.Metadata.Annotations):
+		return "unchanged", nil
+	default:
+		return "configured", nil
+	}
+}
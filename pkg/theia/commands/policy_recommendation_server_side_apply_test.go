@@ -0,0 +1,113 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestServerSideApplyPolicies(t *testing.T) {
+	const conflictingPolicy = "/apis/crd.antrea.io/v1alpha1/namespaces/ns1/networkpolicies/manual"
+	objects := map[string][]byte{}
+	appliedBodies := map[string]string{}
+	resourceVersion := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			object, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound})
+				return
+			}
+			w.Write(object)
+		case http.MethodPatch:
+			assert.Equal(t, "application/apply-patch+yaml", r.Header.Get("Content-Type"))
+			assert.Equal(t, "theia", r.URL.Query().Get("fieldManager"))
+			if r.URL.Path == conflictingPolicy && r.URL.Query().Get("force") != "true" {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(metav1.Status{
+					TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+					Status:   metav1.StatusFailure,
+					Reason:   metav1.StatusReasonConflict,
+					Code:     http.StatusConflict,
+					Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{{
+						Type:    metav1.CauseTypeFieldManagerConflict,
+						Message: `conflict with "kubectl-edit" using crd.antrea.io/v1alpha1`,
+						Field:   ".spec.ingress",
+					}}},
+				})
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			var policy map[string]interface{}
+			require.NoError(t, json.Unmarshal(body, &policy))
+			if appliedBodies[r.URL.Path] != string(body) {
+				appliedBodies[r.URL.Path] = string(body)
+				resourceVersion++
+				policy["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(resourceVersion)
+				objects[r.URL.Path], _ = json.Marshal(policy)
+			}
+			w.Write(objects[r.URL.Path])
+		}
+	}))
+	defer server.Close()
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	client := clientset.CoreV1().RESTClient()
+
+	policies, err := decodePolicyBundle([]byte(`apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-1
+  namespace: ns1
+spec:
+  priority: 5
+---
+apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: manual
+  namespace: ns1
+spec:
+  priority: 5
+`))
+	require.NoError(t, err)
+	objects[conflictingPolicy] = []byte(`{"metadata":{"name":"manual","namespace":"ns1","resourceVersion":"100"}}`)
+
+	var out bytes.Buffer
+	err = serverSideApplyPolicies(&out, client, policies, applyOptions{})
+	assert.EqualError(t, err, "1 out of 2 recommended policies could not be applied, use force-conflicts to take ownership of the conflicting fields")
+	assert.Equal(t, "NetworkPolicy ns1/recommend-allow-anp-1 created\n"+
+		"NetworkPolicy ns1/manual conflicts with other field managers:\n"+
+		"  .spec.ingress: conflict with \"kubectl-edit\" using crd.antrea.io/v1alpha1\n", out.String())
+
+	out.Reset()
+	require.NoError(t, serverSideApplyPolicies(&out, client, policies, applyOptions{forceConflicts: true}))
+	assert.Equal(t, "NetworkPolicy ns1/recommend-allow-anp-1 unchanged\nNetworkPolicy ns1/manual configured\n", out.String())
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/util/sink"
+)
+
+// kubernetesSinkScheme is the scheme of the sink applying the recommended
+// policies to the cluster with server-side apply: k8s: or
+// k8s:?force-conflicts=true. It is only available in the CLI.
+const kubernetesSinkScheme = "k8s"
+
+type kubernetesSink struct {
+	out            io.Writer
+	client         kubernetes.Interface
+	forceConflicts bool
+}
+
+func (s *kubernetesSink) Write(ctx context.Context, result *sink.Result) error {
+	policies, err := decodePolicyBundle(result.Policies)
+	if err != nil {
+		return err
+	}
+	return serverSideApplyPolicies(s.out, s.client.CoreV1().RESTClient(), policies, applyOptions{recoID: result.ID, forceConflicts: s.forceConflicts})
+}
+
+func (s *kubernetesSink) String() string {
+	return kubernetesSinkScheme + ":"
+}
+
+// newSinks returns the sinks of the given URIs. The output of the stdout sink
+// is written to out.
+func newSinks(uris []string, out io.Writer, clientset kubernetes.Interface) ([]sink.Sink, error) {
+	var sinks []sink.Sink
+	for _, uri := range uris {
+		s, err := sink.New(uri, sink.Options{Out: out, KubeClient: clientset})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// writeToSinks delivers a result to all the sinks, and reports each delivery
+// to stderr. It stops at the first sink which fails.
+func writeToSinks(ctx context.Context, sinks []sink.Sink, result *sink.Result) error {
+	for _, s := range sinks {
+		if err := s.Write(ctx, result); err != nil {
+			return fmt.Errorf("error when writing recommendation result to sink %s: %v", s, err)
+		}
+		if s.String() != sink.StdoutScheme {
+			fmt.Fprintf(os.Stderr, "Recommendation result written to %s\n", s)
+		}
+	}
+	return nil
+}
+
+func init() {
+	sink.Register(kubernetesSinkScheme, func(u *url.URL, options sink.Options) (sink.Sink, error) {
+		forceConflicts := false
+		if value := u.Query().Get("force-conflicts"); value != "" {
+			var err error
+			if forceConflicts, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("force-conflicts of the k8s sink should be a boolean")
+			}
+		}
+		return &kubernetesSink{out: os.Stderr, client: options.KubeClient, forceConflicts: forceConflicts}, nil
+	})
+}
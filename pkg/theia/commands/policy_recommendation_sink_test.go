@@ -0,0 +1,61 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/util/sink"
+)
+
+func TestNewSinks(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	sinks, err := newSinks([]string{"k8s:", "k8s:?force-conflicts=true"}, &bytes.Buffer{}, clientset)
+	require.NoError(t, err)
+	require.Len(t, sinks, 2)
+	assert.False(t, sinks[0].(*kubernetesSink).forceConflicts)
+	assert.True(t, sinks[1].(*kubernetesSink).forceConflicts)
+	assert.Equal(t, "k8s:", sinks[1].String())
+
+	_, err = newSinks([]string{"k8s:?force-conflicts=maybe"}, &bytes.Buffer{}, clientset)
+	assert.EqualError(t, err, "force-conflicts of the k8s sink should be a boolean")
+	_, err = newSinks([]string{"stdout", "ftp://example.com"}, &bytes.Buffer{}, clientset)
+	assert.ErrorContains(t, err, "its scheme should be one of: configmap, file, gs, http, https, k8s, s3, secret, stdout")
+}
+
+func TestWriteToSinks(t *testing.T) {
+	var out bytes.Buffer
+	filePath := filepath.Join(t.TempDir(), "result.yaml")
+	sinks, err := newSinks([]string{"-", filePath}, &out, fake.NewSimpleClientset())
+	require.NoError(t, err)
+	result := &sink.Result{ID: "e998433e-accb-4888-9fc8-06563f073e86", Policies: []byte(testRecommendedPolicies)}
+	require.NoError(t, writeToSinks(context.TODO(), sinks, result))
+	assert.Equal(t, testRecommendedPolicies, out.String())
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, testRecommendedPolicies, string(content))
+
+	sinks, err = newSinks([]string{filepath.Join(t.TempDir(), "missing", "result.yaml")}, &out, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, writeToSinks(context.TODO(), sinks, result), "error when writing recommendation result to sink")
+}
@@ -0,0 +1,316 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// snapshotTable stores the Namespaces, Services and Pod label sets of the
+// cluster at the time policy recommendation jobs were submitted, keyed by job
+// ID.
+const snapshotTable = "cluster_snapshots"
+
+const (
+	snapshotKindNamespace = "Namespace"
+	snapshotKindService   = "Service"
+	snapshotKindPod       = "Pod"
+)
+
+// snapshotObject is a Namespace, Service or Pod of a cluster snapshot. Labels
+// holds the selector of Services, and the labels of Namespaces and Pods.
+type snapshotObject struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Labels    map[string]string
+}
+
+type clusterSnapshot struct {
+	TimeCreated time.Time
+	Objects     []snapshotObject
+}
+
+// takeClusterSnapshot lists the Namespaces, Services and Pods of the cluster.
+// Services without selector are recorded with an empty selector.
+func takeClusterSnapshot(clientset kubernetes.Interface, now time.Time) (*clusterSnapshot, error) {
+	snapshot := &clusterSnapshot{TimeCreated: now.UTC().Truncate(time.Second)}
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Namespaces: %v", err)
+	}
+	for _, ns := range namespaces.Items {
+		snapshot.Objects = append(snapshot.Objects, snapshotObject{Kind: snapshotKindNamespace, Name: ns.Name, Labels: ns.Labels})
+	}
+	services, err := clientset.CoreV1().Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Services: %v", err)
+	}
+	for _, svc := range services.Items {
+		snapshot.Objects = append(snapshot.Objects, snapshotObject{Kind: snapshotKindService, Namespace: svc.Namespace, Name: svc.Name, Labels: svc.Spec.Selector})
+	}
+	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		snapshot.Objects = append(snapshot.Objects, snapshotObject{Kind: snapshotKindPod, Namespace: pod.Namespace, Name: pod.Name, Labels: pod.Labels})
+	}
+	return snapshot, nil
+}
+
+// saveClusterSnapshot records the snapshot taken when the job with the given
+// ID was submitted.
+func saveClusterSnapshot(connect *sql.DB, id string, snapshot *clusterSnapshot) error {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return fmt.Errorf("failed to record the cluster snapshot of job %s: %v", id, err)
+	}
+	tx, err := connect.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record the cluster snapshot of job %s: %v", id, err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO " + snapshotTable + " (id, timeCreated, kind, namespace, name, labels) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record the cluster snapshot of job %s: %v", id, err)
+	}
+	defer stmt.Close()
+	for _, object := range snapshot.Objects {
+		objectLabels := object.Labels
+		if objectLabels == nil {
+			objectLabels = map[string]string{}
+		}
+		data, err := json.Marshal(objectLabels)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(id, snapshot.TimeCreated, object.Kind, object.Namespace, object.Name, string(data)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record the cluster snapshot of job %s: %v", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record the cluster snapshot of job %s: %v", id, err)
+	}
+	return nil
+}
+
+// getClusterSnapshot returns the snapshot taken when the job with the given ID
+// was submitted.
+func getClusterSnapshot(connect *sql.DB, id string) (*clusterSnapshot, error) {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get the cluster snapshot of job %s: %v", id, err)
+	}
+	rows, err := connect.Query("SELECT timeCreated, kind, namespace, name, labels FROM "+snapshotTable+" WHERE id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the cluster snapshot of job %s: %v", id, err)
+	}
+	defer rows.Close()
+	snapshot := &clusterSnapshot{}
+	for rows.Next() {
+		var object snapshotObject
+		var data string
+		if err := rows.Scan(&snapshot.TimeCreated, &object.Kind, &object.Namespace, &object.Name, &data); err != nil {
+			return nil, fmt.Errorf("failed to get the cluster snapshot of job %s: %v", id, err)
+		}
+		if err := json.Unmarshal([]byte(data), &object.Labels); err != nil {
+			return nil, fmt.Errorf("failed to parse the cluster snapshot of job %s: %v", id, err)
+		}
+		snapshot.Objects = append(snapshot.Objects, object)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get the cluster snapshot of job %s: %v", id, err)
+	}
+	if len(snapshot.Objects) == 0 {
+		return nil, fmt.Errorf("could not find the cluster snapshot of job %s, it is only taken when the job is run with --snapshot", id)
+	}
+	return snapshot, nil
+}
+
+// resolvedPeers are the Pods and Services selected by the peers of a rule, or
+// by the appliedTo of a policy, in the cluster snapshot. Pods and Services
+// are given as <namespace>/<name>.
+type resolvedPeers struct {
+	// All is set when the rule applies to all peers.
+	All      bool     `json:"all,omitempty"`
+	Pods     []string `json:"pods,omitempty"`
+	Services []string `json:"services,omitempty"`
+	IPBlocks []string `json:"ipBlocks,omitempty"`
+}
+
+type resolvedRule struct {
+	Direction string `json:"direction"`
+	// Index of the rule in the ingress or egress rules of the policy.
+	Rule   int           `json:"rule"`
+	Action string        `json:"action,omitempty"`
+	Peers  resolvedPeers `json:"peers"`
+}
+
+type resolvedPolicy struct {
+	Policy    string         `json:"policy"`
+	AppliedTo resolvedPeers  `json:"appliedTo"`
+	Rules     []resolvedRule `json:"rules"`
+}
+
+type selectorReport struct {
+	ID           string           `json:"id"`
+	SnapshotTime string           `json:"snapshotTime"`
+	Policies     []resolvedPolicy `json:"policies"`
+}
+
+func (s *clusterSnapshot) objects(kind string) []snapshotObject {
+	var objects []snapshotObject
+	for _, object := range s.Objects {
+		if object.Kind == kind {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// selectNamespaces returns the Namespaces matching a Namespace selector, or
+// defaultNamespace if the selector is nil.
+func (s *clusterSnapshot) selectNamespaces(selector *labelSelector, defaultNamespace string) map[string]bool {
+	namespaces := make(map[string]bool)
+	if selector == nil {
+		if defaultNamespace != "" {
+			namespaces[defaultNamespace] = true
+			return namespaces
+		}
+		// Cluster-scoped policies without Namespace selector select Pods in
+		// all Namespaces.
+		selector = &labelSelector{}
+	}
+	for _, ns := range s.objects(snapshotKindNamespace) {
+		if labels.SelectorFromSet(selector.MatchLabels).Matches(labels.Set(ns.Labels)) {
+			namespaces[ns.Name] = true
+		}
+	}
+	return namespaces
+}
+
+// selectPods returns the Pods in the given Namespaces matching a Pod selector.
+// A nil selector selects all the Pods.
+func (s *clusterSnapshot) selectPods(namespaces map[string]bool, podLabels map[string]string) []string {
+	pods := []string{}
+	selector := labels.SelectorFromSet(podLabels)
+	for _, pod := range s.objects(snapshotKindPod) {
+		if namespaces[pod.Namespace] && selector.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	sort.Strings(pods)
+	return pods
+}
+
+// selectServicePods returns the Pods selected by a Service.
+func (s *clusterSnapshot) selectServicePods(namespace string, name string) []string {
+	for _, svc := range s.objects(snapshotKindService) {
+		if svc.Namespace == namespace && svc.Name == name && len(svc.Labels) > 0 {
+			return s.selectPods(map[string]bool{namespace: true}, svc.Labels)
+		}
+	}
+	return nil
+}
+
+func (s *clusterSnapshot) resolvePeers(peers []policyPeer, defaultNamespace string, groups map[string]recommendedPolicy) resolvedPeers {
+	var resolved resolvedPeers
+	pods := make(map[string]bool)
+	for _, peer := range peers {
+		switch {
+		case peer.IPBlock != nil:
+			resolved.IPBlocks = append(resolved.IPBlocks, peer.IPBlock.CIDR)
+		case peer.Group != "":
+			group, ok := groups[peer.Group]
+			if !ok || group.Spec.ServiceReference == nil {
+				continue
+			}
+			ref := group.Spec.ServiceReference
+			resolved.Services = append(resolved.Services, ref.Namespace+"/"+ref.Name)
+			for _, pod := range s.selectServicePods(ref.Namespace, ref.Name) {
+				pods[pod] = true
+			}
+		default:
+			for _, pod := range s.selectPods(s.selectNamespaces(peer.NamespaceSelector, defaultNamespace), selectorLabels(peer.PodSelector)) {
+				pods[pod] = true
+			}
+		}
+	}
+	for pod := range pods {
+		resolved.Pods = append(resolved.Pods, pod)
+	}
+	sort.Strings(resolved.Pods)
+	return resolved
+}
+
+// resolveSelectors resolves the selectors of the recommended policies against
+// the cluster snapshot, i.e. as they were when the flows were observed.
+func resolveSelectors(snapshot *clusterSnapshot, id string, yamls string) (*selectorReport, error) {
+	policies, err := parseRecommendedPolicies(yamls)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]recommendedPolicy)
+	for _, policy := range policies {
+		if policy.Kind == "ClusterGroup" {
+			groups[policy.Metadata.Name] = policy
+		}
+	}
+	report := &selectorReport{ID: id, SnapshotTime: FormatTimestamp(snapshot.TimeCreated), Policies: []resolvedPolicy{}}
+	for _, policy := range policies {
+		if policy.Kind == "ClusterGroup" {
+			continue
+		}
+		namespace := policy.Metadata.Namespace
+		resolved := resolvedPolicy{Policy: policyName(policy), Rules: []resolvedRule{}}
+		if policy.Kind == "NetworkPolicy" && policy.Spec.PodSelector != nil {
+			resolved.AppliedTo = resolvedPeers{Pods: snapshot.selectPods(map[string]bool{namespace: true}, policy.Spec.PodSelector.MatchLabels)}
+		} else {
+			resolved.AppliedTo = snapshot.resolvePeers(policy.Spec.AppliedTo, namespace, groups)
+		}
+		for _, direction := range []string{"ingress", "egress"} {
+			policyRules := policy.Spec.Ingress
+			if direction == "egress" {
+				policyRules = policy.Spec.Egress
+			}
+			for i, rule := range policyRules {
+				peers := rule.From
+				if direction == "egress" {
+					peers = rule.To
+				}
+				r := resolvedRule{Direction: direction, Rule: i, Action: rule.Action, Peers: snapshot.resolvePeers(peers, namespace, groups)}
+				for _, svc := range rule.ToServices {
+					r.Peers.Services = append(r.Peers.Services, svc.Namespace+"/"+svc.Name)
+					r.Peers.Pods = append(r.Peers.Pods, snapshot.selectServicePods(svc.Namespace, svc.Name)...)
+				}
+				sort.Strings(r.Peers.Pods)
+				r.Peers.All = len(peers) == 0 && len(rule.ToServices) == 0
+				resolved.Rules = append(resolved.Rules, r)
+			}
+		}
+		report.Policies = append(report.Policies, resolved)
+	}
+	return report, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testSnapshotID = "db7a0ac8-54a6-4e0c-b2e2-ca0a6e2b0c29"
+
+func testSnapshotObjects() []snapshotObject {
+	return []snapshotObject{
+		{Kind: snapshotKindNamespace, Name: "antrea-e2e", Labels: map[string]string{namespaceNameLabel: "antrea-e2e"}},
+		{Kind: snapshotKindNamespace, Name: "antrea-test", Labels: map[string]string{namespaceNameLabel: "antrea-test"}},
+		{Kind: snapshotKindService, Namespace: "antrea-e2e", Name: "perftestsvc", Labels: map[string]string{"app": "perftest-svc"}},
+		{Kind: snapshotKindPod, Namespace: "antrea-e2e", Name: "perftest-svc-1", Labels: map[string]string{"app": "perftest-svc"}},
+		{Kind: snapshotKindPod, Namespace: "antrea-test", Name: "perftest-a", Labels: map[string]string{"podname": "perftest-a"}},
+		{Kind: snapshotKindPod, Namespace: "antrea-test", Name: "perftest-b", Labels: map[string]string{"podname": "perftest-b"}},
+	}
+}
+
+func TestTakeClusterSnapshot(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "antrea-test", Labels: map[string]string{namespaceNameLabel: "antrea-test"}}},
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "perftestsvc", Namespace: "antrea-e2e"}, Spec: v1.ServiceSpec{Selector: map[string]string{"app": "perftest-svc"}}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "perftest-a", Namespace: "antrea-test", Labels: map[string]string{"podname": "perftest-a"}}},
+	)
+	now := time.Date(2022, 8, 1, 10, 0, 0, 500, time.UTC)
+	snapshot, err := takeClusterSnapshot(clientset, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC), snapshot.TimeCreated)
+	assert.Equal(t, []snapshotObject{
+		{Kind: snapshotKindNamespace, Name: "antrea-test", Labels: map[string]string{namespaceNameLabel: "antrea-test"}},
+		{Kind: snapshotKindService, Namespace: "antrea-e2e", Name: "perftestsvc", Labels: map[string]string{"app": "perftest-svc"}},
+		{Kind: snapshotKindPod, Namespace: "antrea-test", Name: "perftest-a", Labels: map[string]string{"podname": "perftest-a"}},
+	}, snapshot.Objects)
+}
+
+func TestSaveAndGetClusterSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	timeCreated := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	snapshot := &clusterSnapshot{TimeCreated: timeCreated, Objects: []snapshotObject{
+		{Kind: snapshotKindNamespace, Name: "antrea-test"},
+		{Kind: snapshotKindPod, Namespace: "antrea-test", Name: "perftest-a", Labels: map[string]string{"podname": "perftest-a"}},
+	}}
+	mock.ExpectBegin()
+	prepare := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO cluster_snapshots (id, timeCreated, kind, namespace, name, labels) VALUES (?, ?, ?, ?, ?, ?)"))
+	prepare.ExpectExec().WithArgs(testSnapshotID, timeCreated, snapshotKindNamespace, "", "antrea-test", "{}").WillReturnResult(sqlmock.NewResult(0, 1))
+	prepare.ExpectExec().WithArgs(testSnapshotID, timeCreated, snapshotKindPod, "antrea-test", "perftest-a", `{"podname":"perftest-a"}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, saveClusterSnapshot(db, testSnapshotID, snapshot))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT timeCreated, kind, namespace, name, labels FROM cluster_snapshots WHERE id = ?")).
+		WithArgs(testSnapshotID).
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated", "kind", "namespace", "name", "labels"}).
+			AddRow(timeCreated, snapshotKindNamespace, "", "antrea-test", "{}").
+			AddRow(timeCreated, snapshotKindPod, "antrea-test", "perftest-a", `{"podname":"perftest-a"}`))
+	retrieved, err := getClusterSnapshot(db, testSnapshotID)
+	require.NoError(t, err)
+	assert.Equal(t, timeCreated, retrieved.TimeCreated)
+	assert.Equal(t, []snapshotObject{
+		{Kind: snapshotKindNamespace, Name: "antrea-test", Labels: map[string]string{}},
+		{Kind: snapshotKindPod, Namespace: "antrea-test", Name: "perftest-a", Labels: map[string]string{"podname": "perftest-a"}},
+	}, retrieved.Objects)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT timeCreated, kind, namespace, name, labels FROM cluster_snapshots WHERE id = ?")).
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated", "kind", "namespace", "name", "labels"}))
+	_, err = getClusterSnapshot(db, "unknown")
+	assert.ErrorContains(t, err, "could not find the cluster snapshot of job unknown")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveSelectors(t *testing.T) {
+	snapshot := &clusterSnapshot{
+		TimeCreated: time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC),
+		Objects:     testSnapshotObjects(),
+	}
+	report, err := resolveSelectors(snapshot, testSnapshotID, testRecommendedPolicies)
+	require.NoError(t, err)
+	assert.Equal(t, testSnapshotID, report.ID)
+	assert.Equal(t, "2022-08-01 10:00:00", report.SnapshotTime)
+	require.Len(t, report.Policies, 2)
+
+	anp := report.Policies[0]
+	assert.Equal(t, "NetworkPolicy/antrea-test/recommend-allow-anp-ab7fd", anp.Policy)
+	assert.Equal(t, resolvedPeers{Pods: []string{"antrea-test/perftest-a"}}, anp.AppliedTo)
+	require.Len(t, anp.Rules, 3)
+	assert.Equal(t, resolvedPeers{Pods: []string{"antrea-test/perftest-b"}}, anp.Rules[0].Peers)
+	assert.Equal(t, resolvedPeers{IPBlocks: []string{"192.168.0.1/32"}}, anp.Rules[1].Peers)
+	assert.Equal(t, resolvedPeers{Pods: []string{"antrea-e2e/perftest-svc-1"}, Services: []string{"antrea-e2e/perftestsvc"}}, anp.Rules[2].Peers)
+
+	// Pod selectors without Namespace selector select Pods in all Namespaces
+	// in cluster-scoped policies.
+	acnp := report.Policies[1]
+	assert.Equal(t, "ClusterNetworkPolicy/recommend-reject-acnp-9juz4", acnp.Policy)
+	assert.Equal(t, resolvedPeers{Pods: []string{"antrea-test/perftest-a"}}, acnp.AppliedTo)
+	require.Len(t, acnp.Rules, 1)
+	assert.Equal(t, resolvedRule{Direction: "egress", Action: "Reject", Peers: resolvedPeers{
+		Pods: []string{"antrea-e2e/perftest-svc-1", "antrea-test/perftest-a", "antrea-test/perftest-b"},
+	}}, acnp.Rules[0])
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// staleRule is a recommended rule which no flow has hit since the rollout of
+// the recommended policies.
+type staleRule struct {
+	policy    string
+	direction string
+	index     int
+	// policyHits is the number of flows which hit any rule of the policy in
+	// the same direction. If it is 0, the policy may not have been applied.
+	policyHits uint64
+}
+
+// policyRecommendationStaleCmd represents the policy-recommendation stale command
+var policyRecommendationStaleCmd = &cobra.Command{
+	Use:   "stale",
+	Short: "List the recommended rules which have not been hit since they were applied",
+	Long: `List the rules of the policies recommended by a policy recommendation
+Spark job which have not been hit by any flow since a given time, to prune the
+recommended policies after they were applied. A rule is hit by a flow if the
+flow matches the rule and the flow was allowed by the policy, as reported in
+the NetworkPolicy fields of the flow records. Only flows recorded after the
+recommendation was created are considered. Default deny rules are not listed.`,
+	Args: cobra.RangeArgs(0, 1),
+	Example: `
+List the rules recommended by job e998433e-accb-4888-9fc8-06563f073e86 which have not been hit in the last 30 days
+$ theia policy-recommendation stale --id e998433e-accb-4888-9fc8-06563f073e86 --since 30d
+Or
+$ theia policy-recommendation stale e998433e-accb-4888-9fc8-06563f073e86 --since 30d
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoID, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		if recoID == "" && len(args) == 1 {
+			recoID = args[0]
+		}
+		err = ParseRecommendationID(recoID)
+		if err != nil {
+			return err
+		}
+		sinceStr, err := cmd.Flags().GetString("since")
+		if err != nil {
+			return err
+		}
+		since, err := ParseDuration(sinceStr)
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig: %v", err)
+		}
+		if err := CheckClickHousePod(clientset); err != nil {
+			return err
+		}
+		connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+		if portForward != nil {
+			defer portForward.Stop()
+		}
+		if err != nil {
+			return err
+		}
+		defer connect.Close()
+
+		recoResult, err := getResultFromClickHouse(connect, recoID, nil)
+		if err != nil {
+			return fmt.Errorf("error when getting result from ClickHouse, %v", err)
+		}
+		timeCreated, err := getRecommendationTime(connect, recoID)
+		if err != nil {
+			return err
+		}
+		start := time.Now().Add(-since)
+		if timeCreated.After(start) {
+			start = timeCreated
+		}
+		rules, err := listRecommendedRules(recoResult)
+		if err != nil {
+			return err
+		}
+		staleRules, checked, err := findStaleRules(connect, rules, start)
+		if err != nil {
+			return fmt.Errorf("error when getting rule hits from ClickHouse, %v", err)
+		}
+		if len(staleRules) == 0 {
+			fmt.Printf("All %d recommended rules have been hit since %s\n", checked, FormatTimestamp(start))
+			return nil
+		}
+		fmt.Printf("%d out of %d recommended rules have not been hit since %s\n", len(staleRules), checked, FormatTimestamp(start))
+		table := [][]string{{"Policy", "Direction", "Rule", "Policy Hits"}}
+		for _, rule := range staleRules {
+			table = append(table, []string{rule.policy, rule.direction, fmt.Sprint(rule.index), fmt.Sprint(rule.policyHits)})
+		}
+		TableOutput(table)
+		return nil
+	},
+}
+
+// policyHitFilter matches the flows allowed by the policy in the given
+// direction.
+func policyHitFilter(policy recommendedPolicy, direction string) flowFilter {
+	return flowFilter{
+		condition: fmt.Sprintf("%sNetworkPolicyName = ? AND %sNetworkPolicyNamespace = ?", direction, direction),
+		args:      []interface{}{policy.Metadata.Name, policy.Metadata.Namespace},
+	}
+}
+
+// findStaleRules returns the allow rules which have not been hit by any flow
+// since start, and the number of rules which were checked. Rules which cannot
+// be translated into a flow filter are skipped.
+func findStaleRules(connect *sql.DB, rules []recommendedRule, start time.Time) ([]staleRule, int, error) {
+	timeFilter := flowFilter{condition: "flowEndSeconds >= ?", args: []interface{}{start}}
+	policyHits := make(map[string]uint64)
+	var staleRules []staleRule
+	checked := 0
+	for _, rule := range rules {
+		if rule.isDeny() || rule.err != nil {
+			continue
+		}
+		checked++
+		hitFilter := policyHitFilter(rule.policy, rule.direction)
+		var hits uint64
+		filter := andFilters(timeFilter, hitFilter, rule.filter)
+		if err := connect.QueryRow("SELECT count() FROM flows WHERE "+filter.condition, filter.args...).Scan(&hits); err != nil {
+			return nil, 0, err
+		}
+		if hits > 0 {
+			continue
+		}
+		name := policyName(rule.policy)
+		key := name + "/" + rule.direction
+		total, ok := policyHits[key]
+		if !ok {
+			filter := andFilters(timeFilter, hitFilter)
+			if err := connect.QueryRow("SELECT count() FROM flows WHERE "+filter.condition, filter.args...).Scan(&total); err != nil {
+				return nil, 0, err
+			}
+			policyHits[key] = total
+		}
+		staleRules = append(staleRules, staleRule{policy: name, direction: rule.direction, index: rule.index, policyHits: total})
+	}
+	return staleRules, checked, nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationStaleCmd)
+	policyRecommendationStaleCmd.Flags().StringP(
+		"id",
+		"i",
+		"",
+		"ID of the policy recommendation Spark job.",
+	)
+	policyRecommendationStaleCmd.Flags().String(
+		"since",
+		"30d",
+		"Only consider the flows recorded in this period, e.g. 12h or 30d.",
+	)
+}
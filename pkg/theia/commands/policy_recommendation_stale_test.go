@@ -0,0 +1,57 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindStaleRules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	rules, err := listRecommendedRules(testRecommendedPolicies)
+	require.NoError(t, err)
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	countQuery := regexp.QuoteMeta("SELECT count() FROM flows WHERE ")
+	countRows := func(count uint64) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"count()"}).AddRow(count)
+	}
+	// Pod peer rule, hit
+	mock.ExpectQuery(countQuery).WillReturnRows(countRows(10))
+	// IPBlock peer rule, not hit
+	mock.ExpectQuery(countQuery).WillReturnRows(countRows(0))
+	mock.ExpectQuery(countQuery+regexp.QuoteMeta("(flowEndSeconds >= ?) AND (egressNetworkPolicyName = ? AND egressNetworkPolicyNamespace = ?)")+"$").
+		WithArgs(start, "recommend-allow-anp-ab7fd", "antrea-test").WillReturnRows(countRows(10))
+	// Service peer rule, not hit, the policy hits are only queried once
+	mock.ExpectQuery(countQuery).WillReturnRows(countRows(0))
+
+	staleRules, checked, err := findStaleRules(db, rules, start)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	// the reject rule of the ClusterNetworkPolicy is not checked
+	assert.Equal(t, 3, checked)
+	assert.Equal(t, []staleRule{
+		{policy: "NetworkPolicy/antrea-test/recommend-allow-anp-ab7fd", direction: "egress", index: 1, policyHits: 10},
+		{policy: "NetworkPolicy/antrea-test/recommend-allow-anp-ab7fd", direction: "egress", index: 2, policyHits: 10},
+	}, staleRules)
+}
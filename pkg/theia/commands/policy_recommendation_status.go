@@ -16,11 +16,12 @@ package commands
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -29,15 +30,26 @@ import (
 	"k8s.io/klog/v2"
 
 	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/validation"
 	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
 )
 
+// jobEventsTable stores the timeline of the policy recommendation jobs,
+// recorded by theia-manager, keyed by job ID.
+const jobEventsTable = "recommendation_events"
+
 // policyRecommendationStatusCmd represents the policy-recommendation status command
 var policyRecommendationStatusCmd = &cobra.Command{
 	Use:   "status",
-	Short: "Check the status of a policy recommendation Spark job",
-	Long: `Check the current status of a policy recommendation Spark job by ID.
-It will return the status of this Spark application like SUBMITTED, RUNNING, COMPLETED, or FAILED.`,
+	Short: "Check the status of a policy recommendation job",
+	Long: `Check the current status of a policy recommendation job by ID.
+It will return the status of this job like SUBMITTED, RUNNING, COMPLETED, or FAILED.
+With --wait, the status is returned once the job has terminated.
+With a verbose level of 1 or more, the timeline of the job recorded by theia-manager
+is also shown: its state transitions and the Kubernetes events of its objects, e.g.
+the reason why its Pods failed.
+With --output json, the status is printed as a JSON object for automation.`,
 	Args: cobra.RangeArgs(0, 1),
 	Example: `
 Check the current status of job with ID e998433e-accb-4888-9fc8-06563f073e86
@@ -46,6 +58,12 @@ Or
 $ theia policy-recommendation status e998433e-accb-4888-9fc8-06563f073e86
 Use Service ClusterIP when checking the current status of job with ID e998433e-accb-4888-9fc8-06563f073e86
 $ theia policy-recommendation status e998433e-accb-4888-9fc8-06563f073e86 --use-cluster-ip
+Check the current status and the timeline of job with ID e998433e-accb-4888-9fc8-06563f073e86
+$ theia policy-recommendation status e998433e-accb-4888-9fc8-06563f073e86 --verbose 1
+Wait for job with ID e998433e-accb-4888-9fc8-06563f073e86 to terminate, checking its status every 30 seconds for at most 2 hours
+$ theia policy-recommendation status e998433e-accb-4888-9fc8-06563f073e86 --wait --poll-interval 30s --timeout 2h
+Print the status of job with ID e998433e-accb-4888-9fc8-06563f073e86 as JSON
+$ theia policy-recommendation status e998433e-accb-4888-9fc8-06563f073e86 -o json
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		recoID, err := cmd.Flags().GetString("id")
@@ -77,27 +95,66 @@ $ theia policy-recommendation status e998433e-accb-4888-9fc8-06563f073e86 --use-
 				return err
 			}
 		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
 		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
 		if err != nil {
 			return err
 		}
+		waitFlag, err := cmd.Flags().GetBool("wait")
+		if err != nil {
+			return err
+		}
+		waitOptions, err := getJobWaitOptions(cmd)
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		if err := validation.OneOf("output", output, "text", "json"); err != nil {
+			return err
+		}
+		verboseLevel, err := cmd.Flags().GetInt("verbose")
+		if err != nil {
+			return err
+		}
+		if output == "json" && verboseLevel > 0 {
+			return fmt.Errorf("the timeline of the job cannot be printed with --output json")
+		}
 
-		err = PolicyRecoPreCheck(clientset)
+		// The Spark Operator is not checked, as jobs may run on the k8s-job
+		// backend.
+		err = CheckClickHousePod(clientset)
 		if err != nil {
 			return err
 		}
 		var state, errorMessage string
+		var job *executor.Job
 		// Check the ClickHouse first because completed jobs will store results in ClickHouse
-		_, err = getPolicyRecommendationResult(clientset, kubeconfig, endpoint, useClusterIP, "", recoID)
+		_, err = getPolicyRecommendationResult(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, "", "", "", nil, recoID)
 		if err != nil {
-			state, err = getPolicyRecommendationStatus(clientset, recoID)
+			if waitFlag {
+				ctx, cancel := newInterruptContext()
+				defer cancel()
+				if _, err := waitForJobTermination(ctx, clientset, recoID, waitOptions); err != nil {
+					return err
+				}
+			}
+			job, err = executor.GetJob(context.TODO(), executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}), recoID)
 			if err != nil {
 				return err
 			}
+			state = job.State
 			if state == "" {
 				state = "NEW"
 			}
-			if state == "RUNNING" {
+			// Only the Spark applications have a Spark Monitoring Service.
+			// The progress is not part of the state in the JSON output.
+			if state == "RUNNING" && job.Backend == executor.SparkOperatorBackend && output == "text" {
 				var endpoint string
 				service := fmt.Sprintf("pr-%s-ui-svc", recoID)
 				if useClusterIP {
@@ -128,55 +185,137 @@ $ theia policy-recommendation status e998433e-accb-4888-9fc8-06563f073e86 --use-
 					state += stateProgress
 				}
 			}
-			errorMessage, err = getPolicyRecommendationErrorMsg(clientset, recoID)
-			if err != nil {
-				return err
-			}
+			errorMessage = job.ErrorMessage
 		} else {
 			state = "COMPLETED"
+			if output == "json" {
+				// The SparkApplication or Job of completed jobs may have
+				// been deleted, their times are then left empty.
+				job, err = executor.GetJob(context.TODO(), executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}), recoID)
+				if err != nil {
+					klog.V(2).ErrorS(err, "failed to get the policy recommendation job", "id", recoID)
+				}
+			}
+		}
+		if output == "json" {
+			return printJobStatusJSON(os.Stdout, newJobStatus(recoID, state, errorMessage, job))
 		}
 		fmt.Printf("Status of this policy recommendation job is %s\n", state)
 		if errorMessage != "" {
 			fmt.Printf("Error message: %s\n", errorMessage)
 		}
+		if verboseLevel > 0 {
+			return printJobTimeline(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, recoID)
+		}
 		return nil
 	},
 }
 
-func getSparkAppByRecommendationID(clientset kubernetes.Interface, id string) (sparkApp sparkv1.SparkApplication, err error) {
-	err = clientset.CoreV1().RESTClient().
-		Get().
-		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
-		Namespace(config.FlowVisibilityNS).
-		Resource("sparkapplications").
-		Name("pr-" + id).
-		Do(context.TODO()).
-		Into(&sparkApp)
+// jobStatus is the status of a policy recommendation job printed with
+// --output json.
+type jobStatus struct {
+	ID                   string `json:"id"`
+	State                string `json:"state"`
+	SubmissionTime       string `json:"submissionTime"`
+	CompletionTime       string `json:"completionTime"`
+	ErrorMessage         string `json:"errorMessage"`
+	SparkApplicationName string `json:"sparkApplicationName"`
+}
+
+// newJobStatus returns the status of the job with the given ID. job may be
+// nil when its SparkApplication or Job no longer exists, in which case the
+// times and the SparkApplication name are left empty.
+func newJobStatus(recoID string, state string, errorMessage string, job *executor.Job) *jobStatus {
+	status := &jobStatus{
+		ID:           recoID,
+		State:        state,
+		ErrorMessage: errorMessage,
+	}
+	if job == nil {
+		return status
+	}
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	status.SubmissionTime = formatTime(job.CreationTime)
+	status.CompletionTime = formatTime(job.CompletionTime)
+	if job.Backend == executor.SparkOperatorBackend {
+		status.SparkApplicationName = "pr-" + recoID
+	}
+	return status
+}
+
+func printJobStatusJSON(out io.Writer, status *jobStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
 	if err != nil {
-		return sparkApp, err
+		return err
 	}
-	return sparkApp, nil
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
 }
 
-func getPolicyRecommendationStatus(clientset kubernetes.Interface, id string) (string, error) {
-	sparkApplication, err := getSparkAppByRecommendationID(clientset, id)
+// printJobTimeline prints the events of the job recorded by theia-manager.
+func printJobTimeline(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool, recoID string) error {
+	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if portForward != nil {
+		defer portForward.Stop()
+	}
 	if err != nil {
-		return "", err
+		return err
+	}
+	table, err := getJobTimeline(connect, recoID)
+	if err != nil {
+		// The table does not exist with older versions of the Theia Helm
+		// chart.
+		fmt.Fprintf(os.Stderr, "Warning: failed to get the timeline of job %s: %v\n", recoID, err)
+		return nil
 	}
-	state := strings.TrimSpace(string(sparkApplication.Status.AppState.State))
-	if state == "" {
-		state = "NEW"
+	if len(table) == 1 {
+		fmt.Println("No events were recorded for this policy recommendation job")
+		return nil
+	}
+	fmt.Println("Timeline of this policy recommendation job:")
+	TableOutput(table)
+	return nil
+}
+
+// getJobTimeline returns the events of the job, as a table starting with its
+// header.
+func getJobTimeline(connect *sql.DB, recoID string) ([][]string, error) {
+	query := "SELECT timeCreated, type, reason, message FROM " + jobEventsTable + " WHERE id = (?) ORDER BY timeCreated"
+	rows, err := connect.Query(query, recoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	table := [][]string{{"Time", "Type", "Reason", "Message"}}
+	for rows.Next() {
+		var timeCreated time.Time
+		var eventType, reason, message string
+		if err := rows.Scan(&timeCreated, &eventType, &reason, &message); err != nil {
+			return nil, err
+		}
+		table = append(table, []string{FormatTimestamp(timeCreated), eventType, reason, message})
 	}
-	return state, nil
+	return table, rows.Err()
+}
+
+func getSparkAppByRecommendationID(clientset kubernetes.Interface, id string) (sparkv1.SparkApplication, error) {
+	return executor.GetSparkApplication(context.TODO(), clientset, config.FlowVisibilityNS, id)
 }
 
-func getPolicyRecommendationErrorMsg(clientset kubernetes.Interface, id string) (string, error) {
-	sparkApplication, err := getSparkAppByRecommendationID(clientset, id)
+func getPolicyRecommendationStatus(ctx context.Context, clientset kubernetes.Interface, id string) (string, error) {
+	job, err := executor.GetJob(ctx, executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}), id)
 	if err != nil {
 		return "", err
 	}
-	errorMessage := strings.TrimSpace(string(sparkApplication.Status.AppState.ErrorMessage))
-	return errorMessage, nil
+	if job.State == "" {
+		return "NEW", nil
+	}
+	return job.State, nil
 }
 
 func getPolicyRecommendationProgress(baseUrl string) (string, error) {
@@ -254,4 +393,16 @@ func init() {
 		"",
 		"ID of the policy recommendation Spark job.",
 	)
+	policyRecommendationStatusCmd.Flags().Bool(
+		"wait",
+		false,
+		"Wait for the job to terminate before checking its status.",
+	)
+	policyRecommendationStatusCmd.Flags().StringP(
+		"output",
+		"o",
+		"text",
+		"Output format of the status of the job. Supported formats: text, json.",
+	)
+	addJobWaitFlags(policyRecommendationStatusCmd)
 }
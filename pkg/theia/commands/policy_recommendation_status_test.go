@@ -15,14 +15,21 @@
 package commands
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/util/executor"
 )
 
 func TestGetPolicyRecommendationProgress(t *testing.T) {
@@ -112,3 +119,92 @@ func TestGetPolicyRecommendationProgress(t *testing.T) {
 		})
 	}
 }
+
+func TestGetJobTimeline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	recoID := "e998433e-accb-4888-9fc8-06563f073e86"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT timeCreated, type, reason, message FROM recommendation_events WHERE id = (?) ORDER BY timeCreated")).
+		WithArgs(recoID).
+		WillReturnRows(sqlmock.NewRows([]string{"timeCreated", "type", "reason", "message"}).
+			AddRow(time.Date(2022, 8, 1, 11, 0, 0, 0, time.UTC), "State", "SUBMITTED", "").
+			AddRow(time.Date(2022, 8, 1, 11, 0, 20, 0, time.UTC), "Warning", "Failed", "Error: ErrImagePull").
+			AddRow(time.Date(2022, 8, 1, 11, 5, 0, 0, time.UTC), "State", "FAILED", "driver container failed with ExitCode: 1"))
+	table, err := getJobTimeline(db, recoID)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Time", "Type", "Reason", "Message"},
+		{"2022-08-01 11:00:00", "State", "SUBMITTED", ""},
+		{"2022-08-01 11:00:20", "Warning", "Failed", "Error: ErrImagePull"},
+		{"2022-08-01 11:05:00", "State", "FAILED", "driver container failed with ExitCode: 1"},
+	}, table)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPrintJobStatusJSON(t *testing.T) {
+	recoID := "e998433e-accb-4888-9fc8-06563f073e86"
+	testCases := []struct {
+		name         string
+		state        string
+		errorMessage string
+		job          *executor.Job
+		expected     jobStatus
+	}{
+		{
+			name:  "completed Spark application",
+			state: "COMPLETED",
+			job: &executor.Job{
+				ID:             recoID,
+				Backend:        executor.SparkOperatorBackend,
+				State:          "COMPLETED",
+				CreationTime:   time.Date(2022, 8, 1, 11, 0, 0, 0, time.UTC),
+				CompletionTime: time.Date(2022, 8, 1, 11, 5, 0, 0, time.UTC),
+			},
+			expected: jobStatus{
+				ID:                   recoID,
+				State:                "COMPLETED",
+				SubmissionTime:       "2022-08-01T11:00:00Z",
+				CompletionTime:       "2022-08-01T11:05:00Z",
+				SparkApplicationName: "pr-" + recoID,
+			},
+		},
+		{
+			name:         "failed Kubernetes Job",
+			state:        "FAILED",
+			errorMessage: "driver container failed",
+			job: &executor.Job{
+				ID:           recoID,
+				Backend:      executor.K8sJobBackend,
+				State:        "FAILED",
+				CreationTime: time.Date(2022, 8, 1, 11, 0, 0, 0, time.UTC),
+			},
+			expected: jobStatus{
+				ID:             recoID,
+				State:          "FAILED",
+				SubmissionTime: "2022-08-01T11:00:00Z",
+				ErrorMessage:   "driver container failed",
+			},
+		},
+		{
+			name:  "completed job without SparkApplication",
+			state: "COMPLETED",
+			expected: jobStatus{
+				ID:    recoID,
+				State: "COMPLETED",
+			},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, printJobStatusJSON(&buf, newJobStatus(recoID, tt.state, tt.errorMessage, tt.job)))
+			var status jobStatus
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &status))
+			assert.Equal(t, tt.expected, status)
+			for _, key := range []string{"id", "state", "submissionTime", "completionTime", "errorMessage", "sparkApplicationName"} {
+				assert.Contains(t, buf.String(), fmt.Sprintf("%q", key))
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+// policyRecommendationStopCmd represents the policy-recommendation stop command
+var policyRecommendationStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop a policy recommendation job",
+	Long: `Stop an in-progress policy recommendation job by ID.
+The job is terminated on its backend and reported in the CANCELLED state. It
+can still be deleted with the delete command.`,
+	Args: cobra.RangeArgs(0, 1),
+	Example: `
+Stop the policy recommendation job with ID e998433e-accb-4888-9fc8-06563f073e86
+$ theia policy-recommendation stop e998433e-accb-4888-9fc8-06563f073e86
+Or
+$ theia policy-recommendation stop --id e998433e-accb-4888-9fc8-06563f073e86
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoID, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		if recoID == "" && len(args) == 1 {
+			recoID = args[0]
+		}
+		err = ParseRecommendationID(recoID)
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		if err := stopPolicyRecommendationJob(clientset, recoID); err != nil {
+			return err
+		}
+		fmt.Fprintf(infoOut(cmd), "Successfully stopped policy recommendation job with ID %s\n", recoID)
+		return nil
+	},
+}
+
+// stopPolicyRecommendationJob stops the job with the given ID on its backend.
+// Jobs which already reached a final state cannot be stopped.
+func stopPolicyRecommendationJob(clientset kubernetes.Interface, recoID string) error {
+	job, err := executor.GetJob(context.TODO(), executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}), recoID)
+	if err != nil {
+		return err
+	}
+	switch job.State {
+	case "COMPLETED", "FAILED", "SUBMISSION_FAILED", executor.CancelledState:
+		return fmt.Errorf("policy recommendation job %s is already in state %s", recoID, job.State)
+	}
+	jobExecutor, err := executor.New(job.Backend, executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS})
+	if err != nil {
+		return err
+	}
+	if err := jobExecutor.Stop(context.TODO(), recoID); err != nil {
+		return fmt.Errorf("error when stopping policy recommendation job on the %s backend: %v", job.Backend, err)
+	}
+	return nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationStopCmd)
+	policyRecommendationStopCmd.Flags().StringP(
+		"id",
+		"i",
+		"",
+		"ID of the policy recommendation job.",
+	)
+}
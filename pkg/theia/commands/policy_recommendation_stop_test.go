@@ -0,0 +1,75 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/executor"
+)
+
+func TestStopPolicyRecommendationJob(t *testing.T) {
+	const id = "e998433e-accb-4888-9fc8-06563f073e86"
+	newJob := func(conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pr-" + id,
+				Namespace: config.FlowVisibilityNS,
+				Labels:    map[string]string{executor.RecommendationIDLabel: id},
+			},
+			Status: batchv1.JobStatus{Active: 1, Conditions: conditions},
+		}
+	}
+	testCases := []struct {
+		name          string
+		job           *batchv1.Job
+		expectedError string
+	}{
+		{
+			name: "running job",
+			job:  newJob(),
+		},
+		{
+			name:          "completed job",
+			job:           newJob(batchv1.JobCondition{Type: batchv1.JobComplete, Status: v1.ConditionTrue}),
+			expectedError: "policy recommendation job e998433e-accb-4888-9fc8-06563f073e86 is already in state COMPLETED",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.job)
+			err := stopPolicyRecommendationJob(clientset, id)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			job, err := executor.GetJob(context.TODO(), executor.All(executor.Options{Clientset: clientset, Namespace: config.FlowVisibilityNS}), id)
+			require.NoError(t, err)
+			assert.Equal(t, executor.CancelledState, job.State)
+			err = stopPolicyRecommendationJob(clientset, id)
+			assert.EqualError(t, err, "policy recommendation job e998433e-accb-4888-9fc8-06563f073e86 is already in state CANCELLED")
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/util/signing"
+)
+
+// signingPasswordEnv is the environment variable with the password of
+// encrypted signing keys, as for cosign.
+const signingPasswordEnv = "COSIGN_PASSWORD"
+
+// policyRecommendationVerifyCmd represents the policy-recommendation verify command
+var policyRecommendationVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the signature of a policy recommendation result",
+	Long: `Verify the signature of a policy recommendation result saved and signed
+with "theia policy-recommendation retrieve --file --sign-key", before applying
+the recommended policies. Signatures made with "cosign sign-blob" are supported
+as well.`,
+	Args: cobra.NoArgs,
+	Example: `
+Verify the recommendation result saved to output.yaml, with the signature saved to output.yaml.sig
+$ theia policy-recommendation verify --file output.yaml --key cosign.pub
+Verify the recommendation result with a signature saved to another file
+$ theia policy-recommendation verify --file output.yaml --key cosign.pub --signature signatures/output.yaml.sig
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filePath, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return err
+		}
+		if filePath == "" {
+			return fmt.Errorf("file should be provided")
+		}
+		keyPath, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		if keyPath == "" {
+			return fmt.Errorf("key should be provided")
+		}
+		signatureFilePath, err := cmd.Flags().GetString("signature")
+		if err != nil {
+			return err
+		}
+		if signatureFilePath == "" {
+			signatureFilePath = filePath + ".sig"
+		}
+		if err := verifyPolicyBundle(filePath, keyPath, signatureFilePath); err != nil {
+			return err
+		}
+		fmt.Println("Verified OK")
+		return nil
+	},
+}
+
+func verifyPolicyBundle(filePath string, keyPath string, signatureFilePath string) error {
+	publicKey, err := signing.LoadPublicKey(keyPath)
+	if err != nil {
+		return err
+	}
+	bundle, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("error when reading recommendation result: %v", err)
+	}
+	signature, err := os.ReadFile(signatureFilePath)
+	if err != nil {
+		return fmt.Errorf("error when reading signature: %v", err)
+	}
+	if err := signing.Verify(publicKey, bundle, string(signature)); err != nil {
+		return fmt.Errorf("error when verifying %s: %v", filePath, err)
+	}
+	return nil
+}
+
+func init() {
+	policyRecommendationCmd.AddCommand(policyRecommendationVerifyCmd)
+	policyRecommendationVerifyCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file path of the recommendation result to verify.",
+	)
+	policyRecommendationVerifyCmd.Flags().String(
+		"key",
+		"",
+		"PEM-encoded Ed25519 or ECDSA P-256 public key of the signer, e.g. cosign.pub.",
+	)
+	policyRecommendationVerifyCmd.Flags().String(
+		"signature",
+		"",
+		"The file path of the signature. Defaults to the result file path with the .sig suffix.",
+	)
+}
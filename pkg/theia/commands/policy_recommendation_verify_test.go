@@ -0,0 +1,60 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+}
+
+func TestSignAndVerifyPolicyBundle(t *testing.T) {
+	dir := t.TempDir()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	privateKeyPath := filepath.Join(dir, "theia.key")
+	publicKeyPath := filepath.Join(dir, "theia.pub")
+	writePEM(t, privateKeyPath, "PRIVATE KEY", privateKeyDER)
+	writePEM(t, publicKeyPath, "PUBLIC KEY", publicKeyDER)
+
+	bundlePath := filepath.Join(dir, "output.yaml")
+	signaturePath := bundlePath + ".sig"
+	require.NoError(t, os.WriteFile(bundlePath, []byte(testRecommendedPolicies), 0600))
+	signature, err := signPolicyBundle([]byte(testRecommendedPolicies), privateKeyPath, nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(signaturePath, []byte(signature), 0600))
+	assert.NoError(t, verifyPolicyBundle(bundlePath, publicKeyPath, signaturePath))
+
+	require.NoError(t, os.WriteFile(bundlePath, []byte(testRecommendedPolicies+"---\n"), 0600))
+	assert.EqualError(t, verifyPolicyBundle(bundlePath, publicKeyPath, signaturePath), "error when verifying "+bundlePath+": invalid signature")
+	assert.ErrorContains(t, verifyPolicyBundle(bundlePath, publicKeyPath, filepath.Join(dir, "missing.sig")), "error when reading signature")
+	_, err = signPolicyBundle([]byte(testRecommendedPolicies), publicKeyPath, nil)
+	assert.ErrorContains(t, err, "unsupported private key PEM type")
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/postgres"
+)
+
+var postgresCmd = &cobra.Command{
+	Use:     "postgres",
+	Aliases: []string{"pg"},
+	Short:   "Commands of Theia PostgreSQL feature",
+	Long: `Commands of Theia PostgreSQL feature, which stores the flow records and the
+policy recommendation results in a PostgreSQL database, with or without the
+TimescaleDB extension, for small clusters which do not need ClickHouse. The
+flow commands query the database with "--flows-backend postgres".
+
+The database is set with --postgres-url, or with the THEIA_POSTGRES_URL
+environment variable as the URL may include the password.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand to run like migrate")
+	},
+}
+
+// openPostgres opens a connection to the database of the postgres-url flag.
+func openPostgres(cmd *cobra.Command) (*sql.DB, error) {
+	url, err := getPostgresURL(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return postgres.Open(url)
+}
+
+func getPostgresURL(cmd *cobra.Command) (string, error) {
+	url, err := cmd.Flags().GetString("postgres-url")
+	if err != nil {
+		return "", err
+	}
+	if url == "" {
+		return "", fmt.Errorf("the PostgreSQL database must be set with --postgres-url or the %s environment variable", flagEnvVar("postgres-url"))
+	}
+	return url, nil
+}
+
+func init() {
+	rootCmd.AddCommand(postgresCmd)
+	postgresCmd.PersistentFlags().String(
+		"postgres-url",
+		"",
+		"The URL of the PostgreSQL database, e.g. postgres://theia@localhost:5432/theia?sslmode=disable.",
+	)
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/postgres"
+)
+
+var postgresLoadFlowsCmd = &cobra.Command{
+	Use:   "load-flows FILE...",
+	Short: "Load flow records into PostgreSQL",
+	Long: `Load flow records into the flows table of PostgreSQL. The files are in the
+CSV format of the files uploaded by the S3 uploader of the Flow Aggregator, and
+files with the .gz extension are decompressed. Each file is loaded in a single
+transaction, so that a file which fails to load can be loaded again.`,
+	Example: `Load the files downloaded from the S3 bucket of the Flow Aggregator
+$ theia postgres load-flows records-*.csv.gz`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: postgresLoadFlows,
+}
+
+func postgresLoadFlows(cmd *cobra.Command, args []string) error {
+	db, err := openPostgres(cmd)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	total := 0
+	for _, path := range args {
+		count, err := func() (int, error) {
+			r, err := openFlowRecords(path)
+			if err != nil {
+				return 0, err
+			}
+			defer r.Close()
+			return postgres.LoadFlows(context.TODO(), db, r)
+		}()
+		if err != nil {
+			return fmt.Errorf("error when loading %s: %v", path, err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Loaded %d flow records from %s\n", count, path)
+		total += count
+	}
+	if len(args) > 1 {
+		fmt.Fprintf(cmd.OutOrStdout(), "Loaded %d flow records from %d files\n", total, len(args))
+	}
+	return nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f *gzipFile) Close() error {
+	f.Reader.Close()
+	return f.file.Close()
+}
+
+// openFlowRecords opens a file of flow records, decompressing it if its name
+// has the .gz extension.
+func openFlowRecords(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+func init() {
+	postgresCmd.AddCommand(postgresLoadFlowsCmd)
+}
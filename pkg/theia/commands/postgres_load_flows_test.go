@@ -0,0 +1,60 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFlowRecords(t *testing.T) {
+	const records = "2022-08-01 12:00:00,2022-08-01 12:01:00\n"
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "records.csv")
+	require.NoError(t, os.WriteFile(plainPath, []byte(records), 0600))
+	gzipPath := filepath.Join(dir, "records.csv.gz")
+	file, err := os.Create(gzipPath)
+	require.NoError(t, err)
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte(records))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+
+	for _, path := range []string{plainPath, gzipPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			r, err := openFlowRecords(path)
+			require.NoError(t, err)
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, records, string(data))
+		})
+	}
+
+	_, err = openFlowRecords(filepath.Join(dir, "missing.csv"))
+	assert.Error(t, err)
+	// A file which is not compressed, despite its name.
+	notGzipPath := filepath.Join(dir, "plain.csv.gz")
+	require.NoError(t, os.WriteFile(notGzipPath, []byte(records), 0600))
+	_, err = openFlowRecords(notGzipPath)
+	assert.Error(t, err)
+}
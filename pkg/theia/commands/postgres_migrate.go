@@ -0,0 +1,63 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/postgres"
+)
+
+var postgresMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Create or update the tables of Theia in PostgreSQL",
+	Long: `Create or update the tables storing the flow records and the policy
+recommendation results in PostgreSQL, by applying the schema migrations which
+have not been applied to the database yet. When the TimescaleDB extension is
+installed in the database, the flows table is created as a hypertable
+partitioned by flow end time.
+
+You can run the "migrate" command multiple times as it is idempotent. It needs
+to be run again after upgrading the CLI, to apply the new migrations.`,
+	Example: `Migrate the database of the THEIA_POSTGRES_URL environment variable
+$ theia postgres migrate
+Migrate a local database
+$ theia postgres migrate --postgres-url "postgres://theia@localhost:5432/theia?sslmode=disable"`,
+	Args: cobra.NoArgs,
+	RunE: postgresMigrate,
+}
+
+func postgresMigrate(cmd *cobra.Command, args []string) error {
+	url, err := getPostgresURL(cmd)
+	if err != nil {
+		return err
+	}
+	from, to, err := postgres.Migrate(url)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		fmt.Fprintf(cmd.OutOrStdout(), "The database schema is up to date (version %d)\n", to)
+		return nil
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Migrated the database schema from version %d to version %d\n", from, to)
+	return nil
+}
+
+func init() {
+	postgresCmd.AddCommand(postgresMigrateCmd)
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/pkg/postgres"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+)
+
+var postgresLoadResultCmd = &cobra.Command{
+	Use:   "load-result ID",
+	Short: "Save a policy recommendation result in PostgreSQL",
+	Long: `Save the result of a policy recommendation job in the recommendations table
+of PostgreSQL, replacing any result previously saved with the same ID. The
+policy recommendation job reads the flows from ClickHouse and writes its result
+to ClickHouse, so the result is read from a file, e.g. saved by "theia
+policy-recommendation retrieve --file", or from stdin.`,
+	Example: `Save the result of a job retrieved to a file
+$ theia postgres load-result e998433e-accb-4888-9fc8-06563f073e86 -f result.yaml
+Save the result of a job from stdin
+$ theia policy-recommendation retrieve e998433e-accb-4888-9fc8-06563f073e86 | theia postgres load-result e998433e-accb-4888-9fc8-06563f073e86 -f -`,
+	Args: cobra.ExactArgs(1),
+	RunE: postgresLoadResult,
+}
+
+var postgresRetrieveCmd = &cobra.Command{
+	Use:   "retrieve ID",
+	Short: "Get a policy recommendation result saved in PostgreSQL",
+	Long: `Get the result of a policy recommendation job saved in PostgreSQL by
+"theia postgres load-result".`,
+	Example: `Print the result of a job
+$ theia postgres retrieve e998433e-accb-4888-9fc8-06563f073e86
+Save the policies of the app Namespace, and the cluster-scoped ones, to a file
+$ theia postgres retrieve e998433e-accb-4888-9fc8-06563f073e86 --filter-namespace app -f app.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: postgresRetrieve,
+}
+
+func postgresLoadResult(cmd *cobra.Command, args []string) error {
+	id := args[0]
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	if filePath == "" {
+		return fmt.Errorf("the file of the result must be set with --file")
+	}
+	recoTypeStr, err := cmd.Flags().GetString("type")
+	if err != nil {
+		return err
+	}
+	recoType, err := policyrecommendation.ParseRecommendationType("type", recoTypeStr)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if filePath == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("error when reading recommendation result: %v", err)
+	}
+	db, err := openPostgres(cmd)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := postgres.SaveResult(context.TODO(), db, id, string(recoType), string(data)); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Saved the result of job %s\n", id)
+	return nil
+}
+
+func postgresRetrieve(cmd *cobra.Command, args []string) error {
+	id := args[0]
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+	namespaces, err := cmd.Flags().GetStringSlice("filter-namespace")
+	if err != nil {
+		return err
+	}
+	db, err := openPostgres(cmd)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	result, err := policyrecommendation.QueryResult(context.TODO(), db, id, namespaces)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("could not find the result of job %s", id)
+	} else if err != nil {
+		return fmt.Errorf("failed to get the result of job %s: %v", id, err)
+	}
+	if filePath != "" {
+		if err := os.WriteFile(filePath, []byte(result), 0600); err != nil {
+			return fmt.Errorf("error when writing recommendation result to file: %v", err)
+		}
+		return nil
+	}
+	fmt.Fprint(cmd.OutOrStdout(), result)
+	return nil
+}
+
+func init() {
+	postgresCmd.AddCommand(postgresLoadResultCmd)
+	postgresLoadResultCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file with the result, or - to read it from stdin.",
+	)
+	postgresLoadResultCmd.Flags().StringP(
+		"type",
+		"t",
+		string(policyrecommendation.DefaultRecommendationType),
+		policyrecommendation.RecommendationTypeHelp(),
+	)
+	postgresCmd.AddCommand(postgresRetrieveCmd)
+	postgresRetrieveCmd.Flags().StringP(
+		"file",
+		"f",
+		"",
+		"The file path where you want to save the result.",
+	)
+	postgresRetrieveCmd.Flags().StringSlice(
+		"filter-namespace",
+		nil,
+		"Only get the policies in these Namespaces, and the cluster-scoped ones.",
+	)
+}
@@ -17,9 +17,17 @@ package commands
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
 	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/faultinjection"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -29,19 +37,199 @@ var (
 		Use:   "theia",
 		Short: "theia is the command line tool for Theia",
 		Long: `theia is the command line tool for Theia which provides access 
-to Theia network flow visibility capabilities`,
+to Theia network flow visibility capabilities.
+
+Flags which are not set on the command line are read from the THEIA_<FLAG>
+environment variables, e.g. THEIA_CLICKHOUSE_ENDPOINT for --clickhouse-endpoint,
+and then from the config file, which maps flag names to their values, either
+for all commands or for a single command, e.g.:
+
+  theia-namespace: theia
+  use-cluster-ip: true
+  policy-recommendation:
+    run:
+      driver-memory: 2g
+      executor-instances: 4`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := setFlagsFromJobSpec(cmd.Flags()); err != nil {
+				return err
+			}
+			if err := setFlagsFromEnv(cmd.Flags()); err != nil {
+				return err
+			}
+			configFile, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+			commandPath := strings.Fields(cmd.CommandPath())[1:]
+			if err := setFlagsFromConfigFile(cmd.Flags(), configFile, commandPath); err != nil {
+				return err
+			}
+			config.FlowVisibilityNS, err = cmd.Flags().GetString("theia-namespace")
+			if err != nil {
+				return err
+			}
 			verboseLevel, err := cmd.Flags().GetInt("verbose")
 			if err != nil {
 				return err
 			}
 			var l klog.Level
 			l.Set(fmt.Sprint(verboseLevel))
+			if faultinjection.Enabled {
+				spec, err := cmd.Flags().GetString("fault-injection")
+				if err != nil {
+					return err
+				}
+				faultInjector, err = faultinjection.NewInjectorFromSpec(spec)
+				if err != nil {
+					return fmt.Errorf("invalid fault injection configuration: %v", err)
+				}
+			}
 			return nil
 		},
 	}
+
+	// faultInjector is only set in binaries built with the "faultinjection"
+	// tag, and when fault injection is configured.
+	faultInjector *faultinjection.Injector
 )
 
+// envPrefix is the prefix of the environment variables from which flags are
+// read.
+const envPrefix = "THEIA_"
+
+// flagEnvVar returns the environment variable of a flag, e.g.
+// THEIA_CLICKHOUSE_ENDPOINT for clickhouse-endpoint.
+func flagEnvVar(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// setFlagsFromEnv sets the flags which are not set on the command line from
+// their environment variables, so that the CLI can be configured without long
+// argument lists, e.g. in Kubernetes CronJobs.
+func setFlagsFromEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed || flag.Name == "help" {
+			return
+		}
+		envVar := flagEnvVar(flag.Name)
+		value, ok := os.LookupEnv(envVar)
+		if !ok {
+			return
+		}
+		if setErr := flags.Set(flag.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for environment variable %s: %v", value, envVar, setErr)
+		}
+	})
+	return err
+}
+
+// defaultConfigFile returns the path of the config file used when config is
+// not set, ~/.theia/config.yaml.
+func defaultConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".theia", "config.yaml")
+}
+
+// defaultCacheDir returns the directory of the discovery cache, ~/.theia/cache.
+func defaultCacheDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".theia", "cache")
+}
+
+// setFlagsFromConfigFile sets the flags which are not set on the command line,
+// nor from their environment variables, from the config file. The config file
+// is shared by all commands, and the flags which the command does not have are
+// ignored. Values can also be set for a single command in a section named
+// after the command, e.g. "policy-recommendation: {run: {driver-memory: 2g}}",
+// and they override the values set at the top level. commandPath is the path
+// of the command without the root command, e.g. ["policy-recommendation",
+// "run"]. When path is empty, the default config file is used if it exists.
+func setFlagsFromConfigFile(flags *pflag.FlagSet, path string, commandPath []string) error {
+	if path == "" {
+		path = defaultConfigFile()
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error when reading config file: %v", err)
+	}
+	var section map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &section); err != nil {
+		return fmt.Errorf("error when parsing config file %s: %v", path, err)
+	}
+	values := make(map[string]string)
+	for depth := 0; section != nil; depth++ {
+		var next map[interface{}]interface{}
+		for key, value := range section {
+			name := fmt.Sprint(key)
+			if subSection, ok := value.(map[interface{}]interface{}); ok && depth < len(commandPath) && name == commandPath[depth] {
+				next = subSection
+				continue
+			}
+			flag := flags.Lookup(name)
+			if flag == nil || value == nil || name == "help" || name == "config" {
+				continue
+			}
+			flagValue, err := configFileFlagValue(flag, value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s in config file %s: %v", name, path, err)
+			}
+			values[name] = flagValue
+		}
+		section = next
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if flags.Lookup(name).Changed {
+			continue
+		}
+		if err := flags.Set(name, values[name]); err != nil {
+			return fmt.Errorf("invalid value %q for %s in config file %s: %v", values[name], name, path, err)
+		}
+	}
+	return nil
+}
+
+// configFileFlagValue converts a value of the config file to the string value
+// of the flag. Lists are joined with commas, and maps are only supported for
+// flags of key=value pairs, e.g. executor-node-selector.
+func configFileFlagValue(flag *pflag.Flag, value interface{}) (string, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ","), nil
+	case map[interface{}]interface{}:
+		if flag.Value.Type() != "stringToString" {
+			return "", fmt.Errorf("should be a scalar or a list")
+		}
+		pairs := make([]string, 0, len(value))
+		for k, v := range value {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", k, v))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -53,10 +241,44 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().IntVarP(&verbose, "verbose", "v", 0, "set verbose level")
+	rootCmd.PersistentFlags().String(
+		"config",
+		"",
+		"path to the config file of the CLI, will use ~/.theia/config.yaml if not specified and the file exists",
+	)
 	rootCmd.PersistentFlags().StringP(
 		"kubeconfig",
 		"k",
 		"",
 		"absolute path to the k8s config file, will use $KUBECONFIG if not specified",
 	)
+	rootCmd.PersistentFlags().BoolP(
+		"yes",
+		"y",
+		false,
+		"answer yes to the confirmation prompts, e.g. before deleting data, for unattended runs",
+	)
+	rootCmd.PersistentFlags().BoolP(
+		"quiet",
+		"q",
+		false,
+		"only print the results of the commands and the prompts, not the progress and success messages",
+	)
+	rootCmd.PersistentFlags().String(
+		"discovery-cache-ttl",
+		"1m",
+		"how long the API resources and the CRDs discovered from the K8s apiserver are cached in ~/.theia/cache, so that commands run back-to-back do not discover them again, 0 disables the cache",
+	)
+	rootCmd.PersistentFlags().String(
+		"theia-namespace",
+		config.FlowVisibilityNS,
+		"Namespace in which Theia is installed",
+	)
+	if faultinjection.Enabled {
+		rootCmd.PersistentFlags().String(
+			"fault-injection",
+			"",
+			fmt.Sprintf(`[test only] faults to inject, e.g. "spark-submission-failure=0.1,clickhouse-timeout=0.05,slow-query=0.2,slow-query-delay=5s", will use $%s if not specified`, faultinjection.EnvVar),
+		)
+	}
 }
@@ -0,0 +1,202 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetFlagsFromEnv(t *testing.T) {
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("clickhouse-endpoint", "", "")
+		flags.Bool("use-cluster-ip", false, "")
+		flags.StringSlice("selector", nil, "")
+		flags.Int("limit", 0, "")
+		return flags
+	}
+
+	t.Run("flags from env", func(t *testing.T) {
+		t.Setenv("THEIA_CLICKHOUSE_ENDPOINT", "http://clickhouse:8123")
+		t.Setenv("THEIA_USE_CLUSTER_IP", "true")
+		t.Setenv("THEIA_SELECTOR", "podNamespace=a,podName=b")
+		flags := newFlags()
+		require.NoError(t, setFlagsFromEnv(flags))
+		endpoint, _ := flags.GetString("clickhouse-endpoint")
+		assert.Equal(t, "http://clickhouse:8123", endpoint)
+		useClusterIP, _ := flags.GetBool("use-cluster-ip")
+		assert.True(t, useClusterIP)
+		selectors, _ := flags.GetStringSlice("selector")
+		assert.Equal(t, []string{"podNamespace=a", "podName=b"}, selectors)
+		limit, _ := flags.GetInt("limit")
+		assert.Equal(t, 0, limit)
+	})
+
+	t.Run("command line takes precedence", func(t *testing.T) {
+		t.Setenv("THEIA_CLICKHOUSE_ENDPOINT", "http://clickhouse:8123")
+		flags := newFlags()
+		require.NoError(t, flags.Parse([]string{"--clickhouse-endpoint", "http://localhost:8123"}))
+		require.NoError(t, setFlagsFromEnv(flags))
+		endpoint, _ := flags.GetString("clickhouse-endpoint")
+		assert.Equal(t, "http://localhost:8123", endpoint)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		t.Setenv("THEIA_LIMIT", "ten")
+		err := setFlagsFromEnv(newFlags())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid value "ten" for environment variable THEIA_LIMIT`)
+	})
+}
+
+func TestSetFlagsFromConfigFile(t *testing.T) {
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("spark-image", "", "")
+		flags.String("image-pull-policy", "IfNotPresent", "")
+		flags.StringSlice("selector", nil, "")
+		flags.Int("limit", 0, "")
+		flags.StringToString("executor-node-selector", nil, "")
+		return flags
+	}
+	writeConfig := func(t *testing.T, config string) string {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+		return path
+	}
+
+	t.Run("flags from config file", func(t *testing.T) {
+		path := writeConfig(t, `spark-image: registry.example.com/theia/theia-policy-recommendation:v0.5.0
+selector: [podNamespace=a, podName=b]
+limit: 10
+clickhouse-endpoint: http://clickhouse:8123
+`)
+		flags := newFlags()
+		require.NoError(t, setFlagsFromConfigFile(flags, path, nil))
+		image, _ := flags.GetString("spark-image")
+		assert.Equal(t, "registry.example.com/theia/theia-policy-recommendation:v0.5.0", image)
+		policy, _ := flags.GetString("image-pull-policy")
+		assert.Equal(t, "IfNotPresent", policy)
+		selectors, _ := flags.GetStringSlice("selector")
+		assert.Equal(t, []string{"podNamespace=a", "podName=b"}, selectors)
+		limit, _ := flags.GetInt("limit")
+		assert.Equal(t, 10, limit)
+	})
+
+	t.Run("command line and env take precedence", func(t *testing.T) {
+		t.Setenv("THEIA_IMAGE_PULL_POLICY", "Never")
+		path := writeConfig(t, "spark-image: registry.example.com/theia-policy-recommendation\nimage-pull-policy: Always\n")
+		flags := newFlags()
+		require.NoError(t, flags.Parse([]string{"--spark-image", "localhost:5000/theia-policy-recommendation"}))
+		require.NoError(t, setFlagsFromEnv(flags))
+		require.NoError(t, setFlagsFromConfigFile(flags, path, nil))
+		image, _ := flags.GetString("spark-image")
+		assert.Equal(t, "localhost:5000/theia-policy-recommendation", image)
+		policy, _ := flags.GetString("image-pull-policy")
+		assert.Equal(t, "Never", policy)
+	})
+
+	t.Run("default config file", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		flags := newFlags()
+		require.NoError(t, setFlagsFromConfigFile(flags, "", nil))
+		require.NoError(t, os.Mkdir(filepath.Join(home, ".theia"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".theia", "config.yaml"), []byte("image-pull-policy: Always\n"), 0600))
+		require.NoError(t, setFlagsFromConfigFile(flags, "", nil))
+		policy, _ := flags.GetString("image-pull-policy")
+		assert.Equal(t, "Always", policy)
+	})
+
+	t.Run("command sections", func(t *testing.T) {
+		path := writeConfig(t, `limit: 10
+image-pull-policy: Always
+policy-recommendation:
+  run:
+    limit: 20
+    executor-node-selector:
+      kubernetes.io/os: linux
+      node-role: spark
+  status:
+    limit: 30
+clickhouse:
+  spark-image: registry.example.com/theia-policy-recommendation
+`)
+		flags := newFlags()
+		require.NoError(t, setFlagsFromConfigFile(flags, path, []string{"policy-recommendation", "run"}))
+		limit, _ := flags.GetInt("limit")
+		assert.Equal(t, 20, limit)
+		policy, _ := flags.GetString("image-pull-policy")
+		assert.Equal(t, "Always", policy)
+		nodeSelector, _ := flags.GetStringToString("executor-node-selector")
+		assert.Equal(t, map[string]string{"kubernetes.io/os": "linux", "node-role": "spark"}, nodeSelector)
+		image, _ := flags.GetString("spark-image")
+		assert.Equal(t, "", image)
+	})
+
+	t.Run("map value for scalar flag", func(t *testing.T) {
+		path := writeConfig(t, "limit:\n  max: 10\n")
+		err := setFlagsFromConfigFile(newFlags(), path, nil)
+		assert.EqualError(t, err, "invalid value for limit in config file "+path+": should be a scalar or a list")
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		path := writeConfig(t, "limit: ten\n")
+		err := setFlagsFromConfigFile(newFlags(), path, nil)
+		assert.EqualError(t, err, `invalid value "ten" for limit in config file `+path+`: invalid argument "ten" for "--limit" flag: strconv.ParseInt: parsing "ten": invalid syntax`)
+	})
+
+	t.Run("missing config file", func(t *testing.T) {
+		err := setFlagsFromConfigFile(newFlags(), filepath.Join(t.TempDir(), "config.yaml"), nil)
+		assert.ErrorContains(t, err, "error when reading config file")
+	})
+}
+
+func TestGlobalConfirmationFlags(t *testing.T) {
+	for _, path := range [][]string{
+		{"clickhouse", "purge"},
+		{"clickhouse", "trim"},
+		{"uninstall"},
+		{"onboard", "namespace"},
+	} {
+		cmd, _, err := rootCmd.Find(path)
+		require.NoError(t, err)
+		for _, name := range []string{"yes", "quiet"} {
+			assert.NotNil(t, cmd.InheritedFlags().Lookup(name), "%s should inherit --%s", cmd.CommandPath(), name)
+		}
+	}
+}
+
+func TestInfoOut(t *testing.T) {
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().Bool("quiet", false, "")
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	fmt.Fprint(infoOut(cmd), "Purged 3 flows")
+	assert.Equal(t, "Purged 3 flows", out.String())
+
+	require.NoError(t, cmd.Flags().Set("quiet", "true"))
+	fmt.Fprint(infoOut(cmd), "Purged 4 flows")
+	assert.Equal(t, "Purged 3 flows", out.String())
+}
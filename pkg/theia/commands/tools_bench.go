@@ -0,0 +1,680 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/theia/portforwarder"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// Number of Nodes the synthetic Pods are spread across.
+const syntheticNodes = 4
+
+// syntheticFlowColumns are the columns of the flows table filled in for
+// synthetic flow records.
+var syntheticFlowColumns = []string{
+	"flowStartSeconds",
+	"flowEndSeconds",
+	"flowEndSecondsFromSourceNode",
+	"flowEndSecondsFromDestinationNode",
+	"flowEndReason",
+	"sourceIP",
+	"destinationIP",
+	"sourceTransportPort",
+	"destinationTransportPort",
+	"protocolIdentifier",
+	"packetTotalCount",
+	"octetTotalCount",
+	"packetDeltaCount",
+	"octetDeltaCount",
+	"reversePacketTotalCount",
+	"reverseOctetTotalCount",
+	"reversePacketDeltaCount",
+	"reverseOctetDeltaCount",
+	"sourcePodName",
+	"sourcePodNamespace",
+	"sourceNodeName",
+	"destinationPodName",
+	"destinationPodNamespace",
+	"destinationNodeName",
+	"destinationClusterIP",
+	"destinationServicePort",
+	"destinationServicePortName",
+	"ingressNetworkPolicyName",
+	"ingressNetworkPolicyNamespace",
+	"ingressNetworkPolicyRuleName",
+	"ingressNetworkPolicyRuleAction",
+	"ingressNetworkPolicyType",
+	"egressNetworkPolicyName",
+	"egressNetworkPolicyNamespace",
+	"egressNetworkPolicyRuleName",
+	"egressNetworkPolicyRuleAction",
+	"egressNetworkPolicyType",
+	"tcpState",
+	"flowType",
+	"sourcePodLabels",
+	"destinationPodLabels",
+	"throughput",
+	"reverseThroughput",
+	"throughputFromSourceNode",
+	"throughputFromDestinationNode",
+	"reverseThroughputFromSourceNode",
+	"reverseThroughputFromDestinationNode",
+	"clusterUUID",
+}
+
+// syntheticDataset describes the cardinality of the synthetic flow records.
+type syntheticDataset struct {
+	Records          int   `json:"records"`
+	Namespaces       int   `json:"namespaces"`
+	PodsPerNamespace int   `json:"podsPerNamespace"`
+	Services         int   `json:"services"`
+	Seed             int64 `json:"seed"`
+}
+
+type benchIngestion struct {
+	Records          int     `json:"records"`
+	DurationSeconds  float64 `json:"durationSeconds"`
+	RecordsPerSecond float64 `json:"recordsPerSecond"`
+}
+
+type benchJob struct {
+	ID                      string  `json:"id"`
+	State                   string  `json:"state"`
+	DurationSeconds         float64 `json:"durationSeconds"`
+	PeakExecutorMemoryBytes int64   `json:"peakExecutorMemoryBytes"`
+}
+
+type benchResult struct {
+	SizeBytes int `json:"sizeBytes"`
+	Policies  int `json:"policies"`
+}
+
+// benchReport is the JSON report of a benchmark run, meant to be compared
+// across runs to track regressions.
+type benchReport struct {
+	StartTime time.Time        `json:"startTime"`
+	Dataset   syntheticDataset `json:"dataset"`
+	Ingestion benchIngestion   `json:"ingestion"`
+	Job       benchJob         `json:"job"`
+	Result    benchResult      `json:"result"`
+}
+
+// toolsBenchCmd represents the tools bench command
+var toolsBenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark the policy recommendation pipeline",
+	Long: `Benchmark the policy recommendation pipeline. Synthetic flow records
+with the given cardinality are loaded into ClickHouse, then a policy
+recommendation job is run on these records. The ingestion rate, the job
+duration, the peak executor memory and the size of the result are reported in
+JSON format.
+
+The job processes all the flow records inserted while the records are being
+loaded, so the benchmark should be run on a test cluster. The synthetic records
+and the result of the job are deleted at the end of the benchmark, unless
+--keep-data is provided.`,
+	Example: `Benchmark the pipeline with 1M flow records between 1000 Pods
+$ theia tools bench --records 1000000 --namespaces 20 --pods-per-namespace 50
+Save the report to a file
+$ theia tools bench --output bench.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var dataset syntheticDataset
+		var err error
+		dataset.Records, err = cmd.Flags().GetInt("records")
+		if err != nil {
+			return err
+		}
+		dataset.Namespaces, err = cmd.Flags().GetInt("namespaces")
+		if err != nil {
+			return err
+		}
+		dataset.PodsPerNamespace, err = cmd.Flags().GetInt("pods-per-namespace")
+		if err != nil {
+			return err
+		}
+		dataset.Services, err = cmd.Flags().GetInt("services")
+		if err != nil {
+			return err
+		}
+		dataset.Seed, err = cmd.Flags().GetInt64("seed")
+		if err != nil {
+			return err
+		}
+		if dataset.Records <= 0 || dataset.Namespaces <= 0 || dataset.PodsPerNamespace <= 0 {
+			return fmt.Errorf("records, namespaces and pods-per-namespace should be integers > 0")
+		}
+		if err := validation.NonNegative("services", int64(dataset.Services)); err != nil {
+			return err
+		}
+		batchSize, err := cmd.Flags().GetInt("batch-size")
+		if err != nil {
+			return err
+		}
+		if err := validation.Positive("batch-size", int64(batchSize)); err != nil {
+			return err
+		}
+		policyTypeStr, err := cmd.Flags().GetString("policy-type")
+		if err != nil {
+			return err
+		}
+		policyType, err := policyrecommendation.ParsePolicyType("policy-type", policyTypeStr)
+		if err != nil {
+			return err
+		}
+		executorInstances, err := cmd.Flags().GetInt32("executor-instances")
+		if err != nil {
+			return err
+		}
+		if err := validation.NonNegative("executor-instances", int64(executorInstances)); err != nil {
+			return err
+		}
+		executorMemory, err := cmd.Flags().GetString("executor-memory")
+		if err != nil {
+			return err
+		}
+		if err := validation.Quantity("executor-memory", executorMemory); err != nil {
+			return err
+		}
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+		keepData, err := cmd.Flags().GetBool("keep-data")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		if err := PolicyRecoPreCheck(clientset); err != nil {
+			return err
+		}
+		connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+		if portForward != nil {
+			defer portForward.Stop()
+		}
+		if err != nil {
+			return err
+		}
+
+		schema, err := clickhouse.GetFlowSchema(connect)
+		if err != nil {
+			return err
+		}
+		if err := schema.Check(syntheticFlowColumns); err != nil {
+			return err
+		}
+
+		report := benchReport{
+			StartTime: time.Now().UTC(),
+			Dataset:   dataset,
+		}
+		recommendationID := uuid.New().String()
+		report.Job.ID = recommendationID
+		if !keepData {
+			defer cleanupBenchData(connect, recommendationID)
+		}
+		// The flow records are tagged with the recommendation ID, so that they
+		// can be deleted at the end of the benchmark.
+		fmt.Fprintf(os.Stderr, "Loading %d synthetic flow records into ClickHouse\n", dataset.Records)
+		// ClickHouse stores flow timestamps with a second precision
+		startTime := time.Now().UTC().Truncate(time.Second)
+		report.Ingestion, err = insertSyntheticFlows(connect, dataset, batchSize, recommendationID)
+		if err != nil {
+			return err
+		}
+		endTime := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+
+		fmt.Fprintf(os.Stderr, "Running policy recommendation job %s\n", recommendationID)
+		recoJobArgs := []string{
+			"--type", string(policyrecommendation.RecommendationTypeInitial),
+			"--limit", "0",
+			"--option", policyType.OptionArg(),
+			"--start_time", startTime.Format("2006-01-02 15:04:05"),
+			"--end_time", endTime.Format("2006-01-02 15:04:05"),
+			"--rm_labels", "true",
+			"--to_services", "true",
+			"--id", recommendationID,
+		}
+		sparkResourceArgs := SparkResourceArgs{
+			executorInstances:   executorInstances,
+			driverCoreRequest:   "200m",
+			driverMemory:        "512M",
+			executorCoreRequest: "200m",
+			executorMemory:      executorMemory,
+		}
+		if err := createSparkApplication(clientset, newPolicyRecommendationApplication(recommendationID, recoJobArgs, &sparkResourceArgs)); err != nil {
+			return err
+		}
+		report.Job, err = waitForBenchJob(clientset, kubeconfig, recommendationID, useClusterIP, timeout)
+		if err != nil {
+			return err
+		}
+		if report.Job.State != "COMPLETED" {
+			return fmt.Errorf("policy recommendation job failed, state: %s", report.Job.State)
+		}
+
+		recoResult, err := getResultFromClickHouse(connect, recommendationID, nil)
+		if err != nil {
+			return err
+		}
+		report.Result = benchResult{
+			SizeBytes: len(recoResult),
+			Policies:  countPolicies(recoResult),
+		}
+		return writeJSONReport(&report, output)
+	},
+}
+
+func syntheticPodIP(index int) string {
+	return fmt.Sprintf("10.%d.%d.%d", (index>>16)&0xff, (index>>8)&0xff, index&0xff)
+}
+
+// generateSyntheticFlow returns the values of a flow record between two Pods
+// picked randomly among the synthetic Pods, in the order of
+// syntheticFlowColumns. Pods are spread across syntheticNodes Nodes, and a
+// fraction of the flows goes through one of the synthetic Services.
+func generateSyntheticFlow(rng *rand.Rand, dataset *syntheticDataset, timestamp time.Time, clusterUUID string) []interface{} {
+	numPods := dataset.Namespaces * dataset.PodsPerNamespace
+	src, dst := rng.Intn(numPods), rng.Intn(numPods)
+	podName := func(index int) string {
+		return fmt.Sprintf("bench-pod-%d", index)
+	}
+	podNamespace := func(index int) string {
+		return fmt.Sprintf("bench-ns-%d", index/dataset.PodsPerNamespace)
+	}
+	podLabels := func(index int) string {
+		return fmt.Sprintf(`{"app":"bench-app-%d"}`, index)
+	}
+	nodeName := func(index int) string {
+		return fmt.Sprintf("bench-node-%d", index%syntheticNodes)
+	}
+	// 1 for intra-Node flows and 2 for inter-Node flows
+	flowType := uint8(2)
+	if src%syntheticNodes == dst%syntheticNodes {
+		flowType = 1
+	}
+	destinationClusterIP := ""
+	destinationServicePort := uint16(0)
+	destinationServicePortName := ""
+	destinationTransportPort := uint16(8080)
+	if dataset.Services > 0 && rng.Intn(4) == 0 {
+		service := rng.Intn(dataset.Services)
+		destinationClusterIP = fmt.Sprintf("10.96.%d.%d", (service>>8)&0xff, service&0xff)
+		destinationServicePort = 80
+		destinationServicePortName = fmt.Sprintf("%s/bench-svc-%d:http", podNamespace(dst), service)
+	}
+	packets := uint64(rng.Intn(1000) + 1)
+	octets := packets * 1000
+	return []interface{}{
+		timestamp.Add(-time.Minute),
+		timestamp,
+		timestamp,
+		timestamp,
+		uint8(3),
+		syntheticPodIP(src),
+		syntheticPodIP(dst),
+		uint16(30000 + rng.Intn(30000)),
+		destinationTransportPort,
+		uint8(6),
+		packets,
+		octets,
+		packets,
+		octets,
+		packets,
+		octets,
+		packets,
+		octets,
+		podName(src),
+		podNamespace(src),
+		nodeName(src),
+		podName(dst),
+		podNamespace(dst),
+		nodeName(dst),
+		destinationClusterIP,
+		destinationServicePort,
+		destinationServicePortName,
+		"",
+		"",
+		"",
+		uint8(0),
+		uint8(0),
+		"",
+		"",
+		"",
+		uint8(0),
+		uint8(0),
+		"TIME_WAIT",
+		flowType,
+		podLabels(src),
+		podLabels(dst),
+		octets * 8 / 60,
+		octets * 8 / 60,
+		octets * 8 / 60,
+		octets * 8 / 60,
+		octets * 8 / 60,
+		octets * 8 / 60,
+		clusterUUID,
+	}
+}
+
+func insertSyntheticFlows(connect *sql.DB, dataset syntheticDataset, batchSize int, clusterUUID string) (benchIngestion, error) {
+	if err := faultInjector.ClickHouseQuery(context.TODO()); err != nil {
+		return benchIngestion{}, fmt.Errorf("failed to insert flow records: %v", err)
+	}
+	writer := clickhouse.NewBatchWriter(connect, clickhouse.BatchWriterConfig{
+		Query: fmt.Sprintf("INSERT INTO flows (%s) VALUES (%s)",
+			strings.Join(syntheticFlowColumns, ", "),
+			strings.TrimSuffix(strings.Repeat("?, ", len(syntheticFlowColumns)), ", ")),
+		BatchSize: batchSize,
+	})
+	rng := rand.New(rand.NewSource(dataset.Seed))
+	start := time.Now()
+	for i := 0; i < dataset.Records; i++ {
+		if err := writer.Write(context.TODO(), generateSyntheticFlow(rng, &dataset, time.Now(), clusterUUID)...); err != nil {
+			writer.Close()
+			return benchIngestion{}, fmt.Errorf("failed to insert flow records after queueing %d out of %d records: %v", i, dataset.Records, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return benchIngestion{}, fmt.Errorf("failed to insert flow records: %v", err)
+	}
+	inserted := int(writer.Stats().Rows)
+	duration := time.Since(start).Seconds()
+	return benchIngestion{
+		Records:          inserted,
+		DurationSeconds:  duration,
+		RecordsPerSecond: float64(inserted) / duration,
+	}, nil
+}
+
+// waitForBenchJob waits for the policy recommendation job to terminate, and
+// samples the memory used by its executors while it is running.
+func waitForBenchJob(clientset kubernetes.Interface, kubeconfig string, recommendationID string, useClusterIP bool, timeout time.Duration) (benchJob, error) {
+	job := benchJob{ID: recommendationID}
+	submitted := time.Now()
+	var monitoringEndpoint string
+	var pf *portforwarder.PortForwarder
+	defer func() {
+		if pf != nil {
+			pf.Stop()
+		}
+	}()
+	for {
+		sparkApp, err := getSparkAppByRecommendationID(clientset, recommendationID)
+		if err != nil {
+			return job, err
+		}
+		job.State = strings.TrimSpace(string(sparkApp.Status.AppState.State))
+		switch job.State {
+		case "COMPLETED", "FAILED", "SUBMISSION_FAILED":
+			status := sparkApp.Status
+			if !status.LastSubmissionAttemptTime.IsZero() && !status.TerminationTime.IsZero() {
+				job.DurationSeconds = status.TerminationTime.Sub(status.LastSubmissionAttemptTime.Time).Seconds()
+			} else {
+				job.DurationSeconds = time.Since(submitted).Seconds()
+			}
+			return job, nil
+		case "RUNNING":
+			if monitoringEndpoint == "" {
+				monitoringEndpoint, pf, err = getSparkMonitoringEndpoint(clientset, kubeconfig, recommendationID, useClusterIP)
+				if err != nil {
+					klog.V(2).ErrorS(err, "Cannot get the memory usage of the job")
+				}
+			}
+			if monitoringEndpoint != "" {
+				memory, err := getPeakExecutorMemory(monitoringEndpoint)
+				if err != nil {
+					klog.V(2).ErrorS(err, "Failed to get the memory usage of the job")
+				} else if memory > job.PeakExecutorMemoryBytes {
+					job.PeakExecutorMemoryBytes = memory
+				}
+			}
+		}
+		if time.Since(submitted) > timeout {
+			return job, fmt.Errorf("policy recommendation job %s did not terminate after %v, state: %s", recommendationID, timeout, job.State)
+		}
+		time.Sleep(config.StatusCheckPollInterval)
+	}
+}
+
+func getSparkMonitoringEndpoint(clientset kubernetes.Interface, kubeconfig string, recommendationID string, useClusterIP bool) (string, *portforwarder.PortForwarder, error) {
+	service := fmt.Sprintf("pr-%s-ui-svc", recommendationID)
+	if useClusterIP {
+		serviceIP, servicePort, err := GetServiceAddr(clientset, service)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("http://%s:%d", serviceIP, servicePort), nil, nil
+	}
+	listenAddress := "localhost"
+	listenPort := 4040
+	pf, err := StartPortForward(kubeconfig, service, 4040, listenAddress, listenPort)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("http://%s:%d", listenAddress, listenPort), pf, nil
+}
+
+// getPeakExecutorMemory returns the highest JVM memory (heap and off-heap)
+// used by an executor of the Spark application, as reported by the Spark
+// Monitoring Service.
+func getPeakExecutorMemory(baseUrl string) (int64, error) {
+	response, err := getResponseFromSparkMonitoringSvc(fmt.Sprintf("%s/api/v1/applications", baseUrl))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get response from the Spark Monitoring Service: %v", err)
+	}
+	var apps []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(response, &apps); err != nil {
+		return 0, fmt.Errorf("failed to decode Spark applications: %v", err)
+	}
+	if len(apps) != 1 {
+		return 0, fmt.Errorf("wrong Spark Application number, expected 1, got %d", len(apps))
+	}
+	response, err = getResponseFromSparkMonitoringSvc(fmt.Sprintf("%s/api/v1/applications/%s/allexecutors", baseUrl, apps[0].ID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get response from the Spark Monitoring Service: %v", err)
+	}
+	var executors []struct {
+		ID                string           `json:"id"`
+		MemoryUsed        int64            `json:"memoryUsed"`
+		PeakMemoryMetrics map[string]int64 `json:"peakMemoryMetrics"`
+	}
+	if err := json.Unmarshal(response, &executors); err != nil {
+		return 0, fmt.Errorf("failed to decode Spark executors: %v", err)
+	}
+	var peak int64
+	for _, executor := range executors {
+		if executor.ID == "driver" {
+			continue
+		}
+		memory := executor.MemoryUsed
+		if executor.PeakMemoryMetrics != nil {
+			memory = executor.PeakMemoryMetrics["JVMHeapMemory"] + executor.PeakMemoryMetrics["JVMOffHeapMemory"]
+		}
+		if memory > peak {
+			peak = memory
+		}
+	}
+	return peak, nil
+}
+
+func countPolicies(recoResult string) int {
+	// Reading the documents of a string cannot fail.
+	docs, _ := splitRecommendedPolicies(recoResult)
+	return len(docs)
+}
+
+func cleanupBenchData(connect *sql.DB, recommendationID string) {
+	for _, query := range []string{
+		"ALTER TABLE flows_local ON CLUSTER '{cluster}' DELETE WHERE clusterUUID = ?",
+		"ALTER TABLE recommendations_local ON CLUSTER '{cluster}' DELETE WHERE id = ?",
+	} {
+		if _, err := connect.Exec(query, recommendationID); err != nil {
+			klog.ErrorS(err, "Failed to delete benchmark data, it will be deleted when its TTL expires", "id", recommendationID)
+			return
+		}
+	}
+}
+
+// writeJSONReport writes the report to the output file, or to stdout if output
+// is empty.
+func writeJSONReport(report interface{}, output string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error when encoding report: %v", err)
+	}
+	data = append(data, '\n')
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("error when writing report to file: %v", err)
+	}
+	return nil
+}
+
+func init() {
+	toolsCmd.AddCommand(toolsBenchCmd)
+	toolsBenchCmd.Flags().Int(
+		"records",
+		100000,
+		"Number of synthetic flow records loaded into ClickHouse.",
+	)
+	toolsBenchCmd.Flags().Int(
+		"namespaces",
+		10,
+		"Number of Namespaces of the synthetic Pods.",
+	)
+	toolsBenchCmd.Flags().Int(
+		"pods-per-namespace",
+		10,
+		"Number of synthetic Pods in each Namespace, each Pod having distinct labels.",
+	)
+	toolsBenchCmd.Flags().Int(
+		"services",
+		10,
+		"Number of synthetic Services. A quarter of the flow records go through a Service if it is not 0.",
+	)
+	toolsBenchCmd.Flags().Int64(
+		"seed",
+		1,
+		"Seed used to generate the synthetic flow records, the same seed produces the same dataset.",
+	)
+	toolsBenchCmd.Flags().Int(
+		"batch-size",
+		10000,
+		"Number of flow records inserted in each batch.",
+	)
+	toolsBenchCmd.Flags().StringP(
+		"policy-type",
+		"p",
+		string(policyrecommendation.DefaultPolicyType),
+		policyrecommendation.PolicyTypeHelp(),
+	)
+	toolsBenchCmd.Flags().Int32(
+		"executor-instances",
+		1,
+		"Specify the number of executors for the Spark application.",
+	)
+	toolsBenchCmd.Flags().String(
+		"executor-memory",
+		"512M",
+		"Specify the memory request for the executor Pod.",
+	)
+	toolsBenchCmd.Flags().Duration(
+		"timeout",
+		config.StatusCheckPollTimeout,
+		"Maximum time to wait for the policy recommendation job to terminate.",
+	)
+	toolsBenchCmd.Flags().Bool(
+		"keep-data",
+		false,
+		"Keep the synthetic flow records and the recommendation result in ClickHouse after the benchmark.",
+	)
+	toolsBenchCmd.Flags().StringP(
+		"output",
+		"o",
+		"",
+		"Path of the file the JSON report is written to. The report is written to stdout by default.",
+	)
+	toolsBenchCmd.Flags().String(
+		"clickhouse-endpoint",
+		"",
+		"The ClickHouse Service endpoint.",
+	)
+	toolsBenchCmd.Flags().String(
+		"clickhouse-ca-cert",
+		"",
+		`Path to a PEM file with the CA certificate(s) used to verify the ClickHouse endpoint. Providing it enables TLS.
+It is only used together with clickhouse-endpoint. The proxy used to reach the endpoint is taken from the
+HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.`,
+	)
+	toolsBenchCmd.Flags().Bool(
+		"use-cluster-ip",
+		false,
+		`Enable this option will use ClusterIP instead of port forwarding when connecting to the ClickHouse Service
+and Spark Monitoring Service. It can only be used when running in cluster.`,
+	)
+}
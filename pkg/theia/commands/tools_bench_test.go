@@ -0,0 +1,91 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSyntheticFlow(t *testing.T) {
+	dataset := &syntheticDataset{Records: 1000, Namespaces: 3, PodsPerNamespace: 4, Services: 2}
+	rng := rand.New(rand.NewSource(1))
+	timestamp := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	columnIndex := make(map[string]int)
+	for i, column := range syntheticFlowColumns {
+		columnIndex[column] = i
+	}
+	pods := make(map[string]bool)
+	namespaces := make(map[string]bool)
+	services := make(map[string]bool)
+	for i := 0; i < dataset.Records; i++ {
+		flow := generateSyntheticFlow(rng, dataset, timestamp, "bench")
+		require.Len(t, flow, len(syntheticFlowColumns))
+		pods[flow[columnIndex["sourcePodName"]].(string)] = true
+		pods[flow[columnIndex["destinationPodName"]].(string)] = true
+		namespaces[flow[columnIndex["sourcePodNamespace"]].(string)] = true
+		if service := flow[columnIndex["destinationServicePortName"]].(string); service != "" {
+			services[service] = true
+		}
+		assert.Equal(t, "", flow[columnIndex["ingressNetworkPolicyName"]])
+		assert.Equal(t, "", flow[columnIndex["egressNetworkPolicyName"]])
+		assert.Equal(t, "bench", flow[columnIndex["clusterUUID"]])
+		assert.Equal(t, timestamp, flow[columnIndex["flowEndSeconds"]])
+	}
+	assert.Len(t, pods, 12)
+	assert.Len(t, namespaces, 3)
+	// Services are named after the Namespace of the destination Pod
+	assert.LessOrEqual(t, len(services), 6)
+	assert.NotEmpty(t, services)
+}
+
+func TestGetPeakExecutorMemory(t *testing.T) {
+	sparkAppID := "spark-0fa6cc19ae23439794747a306d5ad705"
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var responses []map[string]interface{}
+		switch r.URL.Path {
+		case "/api/v1/applications":
+			responses = []map[string]interface{}{{"id": sparkAppID}}
+		case "/api/v1/applications/" + sparkAppID + "/allexecutors":
+			responses = []map[string]interface{}{
+				{"id": "driver", "memoryUsed": 4096, "peakMemoryMetrics": map[string]int64{"JVMHeapMemory": 8000, "JVMOffHeapMemory": 2000}},
+				{"id": "1", "memoryUsed": 1024, "peakMemoryMetrics": map[string]int64{"JVMHeapMemory": 3000, "JVMOffHeapMemory": 1000}},
+				{"id": "2", "memoryUsed": 5000},
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer testServer.Close()
+	memory, err := getPeakExecutorMemory(testServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), memory)
+}
+
+func TestCountPolicies(t *testing.T) {
+	assert.Equal(t, 0, countPolicies(""))
+	assert.Equal(t, 1, countPolicies("kind: NetworkPolicy\n"))
+	assert.Equal(t, 2, countPolicies("kind: NetworkPolicy\n---\nkind: ClusterNetworkPolicy\n"))
+}
@@ -0,0 +1,486 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/policyrecommendation"
+	"antrea.io/theia/pkg/util/validation"
+	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
+)
+
+// Parameters cycled through by the scale test, so that the concurrent jobs
+// do not all issue the same ClickHouse query.
+var (
+	scaleTestPolicyTypes = policyrecommendation.PolicyTypes()
+	scaleTestLimits      = []int{0, 10000, 100000}
+)
+
+type scaleTestJobParams struct {
+	PolicyType policyrecommendation.PolicyType `json:"policyType"`
+	Limit      int                             `json:"limit"`
+	ToServices bool                            `json:"toServices"`
+}
+
+type scaleTestJob struct {
+	ID     string             `json:"id"`
+	Params scaleTestJobParams `json:"params"`
+	State  string             `json:"state"`
+	// Time between the creation of the SparkApplication and its submission
+	// by the Spark Operator.
+	QueueSeconds float64 `json:"queueSeconds"`
+	// Time between the submission of the SparkApplication by the Spark
+	// Operator and its termination.
+	RunSeconds float64 `json:"runSeconds"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// latencyStats summarizes a set of durations, in seconds.
+type latencyStats struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	Max   float64 `json:"max"`
+}
+
+type scaleTestAPIServer struct {
+	Requests          int          `json:"requests"`
+	RequestsPerSecond float64      `json:"requestsPerSecond"`
+	CreateLatency     latencyStats `json:"createLatency"`
+	GetLatency        latencyStats `json:"getLatency"`
+	Errors            int          `json:"errors"`
+}
+
+type scaleTestClickHouse struct {
+	Samples              int     `json:"samples"`
+	MaxConcurrentQueries int64   `json:"maxConcurrentQueries"`
+	AvgConcurrentQueries float64 `json:"avgConcurrentQueries"`
+	MaxDelayedInserts    int64   `json:"maxDelayedInserts"`
+}
+
+// scaleTestReport is the JSON report of a scale test run.
+type scaleTestReport struct {
+	StartTime       time.Time           `json:"startTime"`
+	DurationSeconds float64             `json:"durationSeconds"`
+	Jobs            []scaleTestJob      `json:"jobs"`
+	Completed       int                 `json:"completed"`
+	Failed          int                 `json:"failed"`
+	QueueLatency    latencyStats        `json:"queueLatency"`
+	RunDuration     latencyStats        `json:"runDuration"`
+	APIServer       scaleTestAPIServer  `json:"apiServer"`
+	ClickHouse      scaleTestClickHouse `json:"clickHouse"`
+}
+
+// apiServerRecorder records the latency of the requests sent to the K8s
+// apiserver, as a measure of the load induced by the scale test.
+type apiServerRecorder struct {
+	mutex          sync.Mutex
+	createLatency  []float64
+	getLatency     []float64
+	errors         int
+	firstRequestAt time.Time
+}
+
+func (r *apiServerRecorder) record(latencies *[]float64, start time.Time, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.firstRequestAt.IsZero() || start.Before(r.firstRequestAt) {
+		r.firstRequestAt = start
+	}
+	*latencies = append(*latencies, time.Since(start).Seconds())
+	if err != nil {
+		r.errors++
+	}
+}
+
+func (r *apiServerRecorder) create(clientset kubernetes.Interface, sparkApplication *sparkv1.SparkApplication) error {
+	start := time.Now()
+	err := createSparkApplication(clientset, sparkApplication)
+	r.record(&r.createLatency, start, err)
+	return err
+}
+
+func (r *apiServerRecorder) get(clientset kubernetes.Interface, id string) (sparkv1.SparkApplication, error) {
+	start := time.Now()
+	sparkApp, err := getSparkAppByRecommendationID(clientset, id)
+	r.record(&r.getLatency, start, err)
+	return sparkApp, err
+}
+
+func (r *apiServerRecorder) summary() scaleTestAPIServer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	requests := len(r.createLatency) + len(r.getLatency)
+	result := scaleTestAPIServer{
+		Requests:      requests,
+		CreateLatency: computeLatencyStats(r.createLatency),
+		GetLatency:    computeLatencyStats(r.getLatency),
+		Errors:        r.errors,
+	}
+	if elapsed := time.Since(r.firstRequestAt).Seconds(); requests > 0 && elapsed > 0 {
+		result.RequestsPerSecond = float64(requests) / elapsed
+	}
+	return result
+}
+
+// toolsScaleTestCmd represents the tools scale-test command
+var toolsScaleTestCmd = &cobra.Command{
+	Use:   "scale-test",
+	Short: "Submit concurrent policy recommendation jobs and measure their impact",
+	Long: `Submit concurrent policy recommendation jobs and measure their impact.
+The jobs cycle through different policy types, limits and toServices options.
+The latency of the requests sent to the K8s apiserver, the time spent by the
+jobs in the Spark Operator queue, their run duration and the number of
+concurrent ClickHouse queries are reported in JSON format.
+
+The recommendation jobs and their results are deleted at the end of the test,
+unless --keep-jobs is provided.`,
+	Example: `Submit 20 concurrent policy recommendation jobs
+$ theia tools scale-test --jobs 20
+Submit 50 jobs, 10 at a time, and save the report to a file
+$ theia tools scale-test --jobs 50 --concurrency 10 --output scale.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		numJobs, err := cmd.Flags().GetInt("jobs")
+		if err != nil {
+			return err
+		}
+		if err := validation.Positive("jobs", int64(numJobs)); err != nil {
+			return err
+		}
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			return err
+		}
+		if err := validation.NonNegative("concurrency", int64(concurrency)); err != nil {
+			return err
+		}
+		if concurrency == 0 || concurrency > numJobs {
+			concurrency = numJobs
+		}
+		executorInstances, err := cmd.Flags().GetInt32("executor-instances")
+		if err != nil {
+			return err
+		}
+		if err := validation.NonNegative("executor-instances", int64(executorInstances)); err != nil {
+			return err
+		}
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+		keepJobs, err := cmd.Flags().GetBool("keep-jobs")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+		if err != nil {
+			return err
+		}
+		if endpoint != "" {
+			err = ParseEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+		if err != nil {
+			return err
+		}
+		useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := ResolveKubeConfig(cmd)
+		if err != nil {
+			return err
+		}
+		clientset, err := CreateK8sClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+		}
+		if err := PolicyRecoPreCheck(clientset); err != nil {
+			return err
+		}
+		connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+		if portForward != nil {
+			defer portForward.Stop()
+		}
+		if err != nil {
+			return err
+		}
+
+		report := scaleTestReport{StartTime: time.Now().UTC()}
+		recorder := &apiServerRecorder{}
+		jobs := make([]scaleTestJob, numJobs)
+		for i := range jobs {
+			jobs[i] = scaleTestJob{
+				ID:     uuid.New().String(),
+				Params: scaleTestParams(i),
+			}
+		}
+		if !keepJobs {
+			defer cleanupScaleTestJobs(clientset, connect, jobs)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		samplesCh := make(chan scaleTestClickHouse, 1)
+		go func() {
+			samplesCh <- sampleClickHouseContention(ctx, connect)
+		}()
+
+		fmt.Fprintf(os.Stderr, "Submitting %d policy recommendation jobs, %d at a time\n", numJobs, concurrency)
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i := range jobs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(job *scaleTestJob) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				runScaleTestJob(clientset, recorder, job, executorInstances, timeout)
+			}(&jobs[i])
+		}
+		wg.Wait()
+		cancel()
+		report.ClickHouse = <-samplesCh
+
+		report.DurationSeconds = time.Since(report.StartTime).Seconds()
+		report.Jobs = jobs
+		var queueLatencies, runDurations []float64
+		for _, job := range jobs {
+			if job.State == "COMPLETED" {
+				report.Completed++
+				queueLatencies = append(queueLatencies, job.QueueSeconds)
+				runDurations = append(runDurations, job.RunSeconds)
+			} else {
+				report.Failed++
+			}
+		}
+		report.QueueLatency = computeLatencyStats(queueLatencies)
+		report.RunDuration = computeLatencyStats(runDurations)
+		report.APIServer = recorder.summary()
+		return writeJSONReport(&report, output)
+	},
+}
+
+// scaleTestParams returns the parameters of the i-th job of the scale test.
+func scaleTestParams(i int) scaleTestJobParams {
+	return scaleTestJobParams{
+		PolicyType: scaleTestPolicyTypes[i%len(scaleTestPolicyTypes)],
+		Limit:      scaleTestLimits[(i/len(scaleTestPolicyTypes))%len(scaleTestLimits)],
+		ToServices: i%2 == 0,
+	}
+}
+
+// runScaleTestJob submits the job and waits for it to terminate. Errors are
+// recorded in the job, so that the other jobs can proceed.
+func runScaleTestJob(clientset kubernetes.Interface, recorder *apiServerRecorder, job *scaleTestJob, executorInstances int32, timeout time.Duration) {
+	recoJobArgs := []string{
+		"--type", string(policyrecommendation.RecommendationTypeInitial),
+		"--limit", strconv.Itoa(job.Params.Limit),
+		"--option", job.Params.PolicyType.OptionArg(),
+		"--rm_labels", "true",
+		"--to_services", strconv.FormatBool(job.Params.ToServices),
+		"--id", job.ID,
+	}
+	sparkResourceArgs := SparkResourceArgs{
+		executorInstances:   executorInstances,
+		driverCoreRequest:   "200m",
+		driverMemory:        "512M",
+		executorCoreRequest: "200m",
+		executorMemory:      "512M",
+	}
+	if err := recorder.create(clientset, newPolicyRecommendationApplication(job.ID, recoJobArgs, &sparkResourceArgs)); err != nil {
+		job.State = "SUBMISSION_FAILED"
+		job.Error = err.Error()
+		return
+	}
+	start := time.Now()
+	for {
+		time.Sleep(config.StatusCheckPollInterval)
+		sparkApp, err := recorder.get(clientset, job.ID)
+		if err != nil {
+			klog.V(2).ErrorS(err, "Failed to get the status of the job", "id", job.ID)
+		} else {
+			job.State = strings.TrimSpace(string(sparkApp.Status.AppState.State))
+			switch job.State {
+			case "COMPLETED", "FAILED", "SUBMISSION_FAILED":
+				status := sparkApp.Status
+				if !status.LastSubmissionAttemptTime.IsZero() {
+					job.QueueSeconds = status.LastSubmissionAttemptTime.Sub(sparkApp.CreationTimestamp.Time).Seconds()
+					if !status.TerminationTime.IsZero() {
+						job.RunSeconds = status.TerminationTime.Sub(status.LastSubmissionAttemptTime.Time).Seconds()
+					}
+				}
+				job.Error = strings.TrimSpace(status.AppState.ErrorMessage)
+				return
+			}
+		}
+		if time.Since(start) > timeout {
+			job.Error = fmt.Sprintf("job did not terminate after %v", timeout)
+			return
+		}
+	}
+}
+
+// sampleClickHouseContention periodically samples the number of queries
+// running concurrently in ClickHouse and the number of delayed inserts,
+// until ctx is cancelled.
+func sampleClickHouseContention(ctx context.Context, connect *sql.DB) scaleTestClickHouse {
+	var result scaleTestClickHouse
+	var total int64
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if result.Samples > 0 {
+				result.AvgConcurrentQueries = float64(total) / float64(result.Samples)
+			}
+			return result
+		case <-ticker.C:
+		}
+		var queries, delayedInserts int64
+		err := connect.QueryRow("SELECT sumIf(value, metric = 'Query'), sumIf(value, metric = 'DelayedInserts') FROM system.metrics").Scan(&queries, &delayedInserts)
+		if err != nil {
+			klog.V(2).ErrorS(err, "Failed to sample ClickHouse metrics")
+			continue
+		}
+		// Do not count the sampling query itself
+		if queries > 0 {
+			queries--
+		}
+		result.Samples++
+		total += queries
+		if queries > result.MaxConcurrentQueries {
+			result.MaxConcurrentQueries = queries
+		}
+		if delayedInserts > result.MaxDelayedInserts {
+			result.MaxDelayedInserts = delayedInserts
+		}
+	}
+}
+
+// computeLatencyStats returns the percentiles of the given values, using the
+// nearest-rank method.
+func computeLatencyStats(values []float64) latencyStats {
+	if len(values) == 0 {
+		return latencyStats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	percentile := func(p int) float64 {
+		rank := (p*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+	return latencyStats{
+		Count: len(sorted),
+		P50:   percentile(50),
+		P95:   percentile(95),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+func cleanupScaleTestJobs(clientset kubernetes.Interface, connect *sql.DB, jobs []scaleTestJob) {
+	for _, job := range jobs {
+		clientset.CoreV1().RESTClient().Delete().
+			AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
+			Namespace(config.FlowVisibilityNS).
+			Resource("sparkapplications").
+			Name("pr-" + job.ID).
+			Do(context.TODO())
+		query := "ALTER TABLE recommendations_local ON CLUSTER '{cluster}' DELETE WHERE id = (?);"
+		if _, err := connect.Exec(query, job.ID); err != nil {
+			klog.ErrorS(err, "Failed to delete the policy recommendation result", "id", job.ID)
+		}
+	}
+}
+
+func init() {
+	toolsCmd.AddCommand(toolsScaleTestCmd)
+	toolsScaleTestCmd.Flags().Int(
+		"jobs",
+		10,
+		"Number of policy recommendation jobs to submit.",
+	)
+	toolsScaleTestCmd.Flags().Int(
+		"concurrency",
+		0,
+		"Maximum number of jobs submitted and waited for at the same time. 0 means all the jobs.",
+	)
+	toolsScaleTestCmd.Flags().Int32(
+		"executor-instances",
+		1,
+		"Specify the number of executors for each Spark application.",
+	)
+	toolsScaleTestCmd.Flags().Duration(
+		"timeout",
+		config.StatusCheckPollTimeout,
+		"Maximum time to wait for each policy recommendation job to terminate.",
+	)
+	toolsScaleTestCmd.Flags().Bool(
+		"keep-jobs",
+		false,
+		"Keep the policy recommendation jobs and their results after the test.",
+	)
+	toolsScaleTestCmd.Flags().StringP(
+		"output",
+		"o",
+		"",
+		"Path of the file the JSON report is written to. The report is written to stdout by default.",
+	)
+	toolsScaleTestCmd.Flags().String(
+		"clickhouse-endpoint",
+		"",
+		"The ClickHouse Service endpoint.",
+	)
+	toolsScaleTestCmd.Flags().String(
+		"clickhouse-ca-cert",
+		"",
+		`Path to a PEM file with the CA certificate(s) used to verify the ClickHouse endpoint. Providing it enables TLS.
+It is only used together with clickhouse-endpoint. The proxy used to reach the endpoint is taken from the
+HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.`,
+	)
+	toolsScaleTestCmd.Flags().Bool(
+		"use-cluster-ip",
+		false,
+		`Enable this option will use ClusterIP instead of port forwarding when connecting to the ClickHouse Service.
+It can only be used when running in cluster.`,
+	)
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"antrea.io/theia/pkg/util/policyrecommendation"
+)
+
+func TestScaleTestParams(t *testing.T) {
+	seen := make(map[scaleTestJobParams]bool)
+	for i := 0; i < len(scaleTestPolicyTypes)*len(scaleTestLimits)*2; i++ {
+		params := scaleTestParams(i)
+		_, err := policyrecommendation.ParsePolicyType("policy-type", string(params.PolicyType))
+		assert.NoError(t, err)
+		seen[params] = true
+	}
+	// all combinations of policy types and limits are used, with both
+	// toServices options
+	assert.Len(t, seen, len(scaleTestPolicyTypes)*len(scaleTestLimits)*2)
+}
+
+func TestComputeLatencyStats(t *testing.T) {
+	testCases := []struct {
+		name          string
+		values        []float64
+		expectedStats latencyStats
+	}{
+		{
+			name:          "no value",
+			values:        nil,
+			expectedStats: latencyStats{},
+		},
+		{
+			name:          "single value",
+			values:        []float64{2.5},
+			expectedStats: latencyStats{Count: 1, P50: 2.5, P95: 2.5, Max: 2.5},
+		},
+		{
+			name:          "unsorted values",
+			values:        []float64{10, 1, 9, 2, 8, 3, 7, 4, 6, 5},
+			expectedStats: latencyStats{Count: 10, P50: 5, P95: 10, Max: 10},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStats, computeLatencyStats(tt.values))
+		})
+	}
+}
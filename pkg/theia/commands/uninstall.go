@@ -0,0 +1,302 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/install"
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Uninstall the Theia flow visibility components",
+	Long: `Uninstall the components installed by "theia install flow-visibility": the
+Spark Operator, Grafana, ClickHouse and the ClickHouse Operator.
+
+By default, the data of ClickHouse is preserved: the PersistentVolumeClaims of
+ClickHouse and the flow-visibility Namespace are kept, so that the data is
+available again when Theia is installed again. Use --purge-data to delete them.
+When ClickHouse does not store its data in PersistentVolumes, e.g. with the
+default installation of "theia install flow-visibility", the data cannot be
+preserved and the uninstallation asks for confirmation, unless --yes is set.
+The recommendation history can be exported before the uninstallation with
+--export-recommendations.`,
+	Example: `
+Uninstall Theia, preserving the ClickHouse data
+$ theia uninstall
+Export the recommendation history, then uninstall Theia and delete all its data
+$ theia uninstall --export-recommendations recommendations.json --purge-data
+`,
+	RunE: uninstall,
+}
+
+// exportedRecommendation is a policy recommendation exported before the
+// uninstallation, written as one JSON object per line.
+type exportedRecommendation struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	TimeCreated time.Time `json:"timeCreated"`
+	Yamls       string    `json:"yamls"`
+}
+
+func uninstall(cmd *cobra.Command, args []string) error {
+	purgeData, err := cmd.Flags().GetBool("purge-data")
+	if err != nil {
+		return err
+	}
+	exportPath, err := cmd.Flags().GetString("export-recommendations")
+	if err != nil {
+		return err
+	}
+	assumeYes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+	if config.FlowVisibilityNS != install.Namespace {
+		return fmt.Errorf("only the components installed in the %s Namespace can be uninstalled, use the Theia Helm chart to uninstall Theia from the %s Namespace", install.Namespace, config.FlowVisibilityNS)
+	}
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return err
+	}
+	out := infoOut(cmd)
+	if exportPath != "" {
+		if err := exportRecommendationHistory(cmd, kubeconfig, exportPath); err != nil {
+			return fmt.Errorf("error when exporting the recommendation history, Theia was not uninstalled: %v", err)
+		}
+	}
+	if purgeData && !assumeYes {
+		confirmed, err := promptConfirmation(bufio.NewReader(cmd.InOrStdin()), cmd.OutOrStdout(), "All the flows and policy recommendations stored in ClickHouse will be deleted permanently. Continue?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Fprintln(out, "Uninstallation cancelled")
+			return nil
+		}
+	}
+
+	// All the components are deleted, whatever the options they were
+	// installed with.
+	objects, err := install.Manifests(install.Options{WithSparkOperator: true, WithGrafana: true})
+	if err != nil {
+		return err
+	}
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	cache, err := discoveryCache(cmd, kubeconfig)
+	if err != nil {
+		return err
+	}
+	installer, err := install.NewInstallerForConfig(restConfig, cache, out)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	// The uninstallation deletes CRDs, which must not be found in the cache
+	// by the next commands.
+	defer cache.Invalidate()
+	if !purgeData {
+		retained, err := installer.RetainClickHouseData(context.TODO())
+		if err != nil {
+			return err
+		}
+		if !retained {
+			if err := confirmDataLoss(cmd, assumeYes); err != nil {
+				return err
+			}
+		}
+		objects = preserveDataObjects(objects)
+	}
+	if err := installer.Delete(context.TODO(), objects); err != nil {
+		return err
+	}
+	if !purgeData {
+		fmt.Fprintf(out, "The PersistentVolumeClaims of ClickHouse are kept in the %s Namespace, use --purge-data to delete them\n", install.Namespace)
+	}
+	return nil
+}
+
+// confirmDataLoss asks for confirmation, unless --yes is set, before
+// uninstalling Theia when the ClickHouse data cannot be preserved, e.g. when
+// ClickHouse stores it in an emptyDir volume. It returns an error if the
+// uninstallation is not confirmed.
+func confirmDataLoss(cmd *cobra.Command, assumeYes bool) error {
+	fmt.Fprintf(cmd.ErrOrStderr(), "Warning: ClickHouse does not store its data in PersistentVolumes, the data cannot be preserved\n")
+	if assumeYes {
+		return nil
+	}
+	confirmed, err := promptConfirmation(bufio.NewReader(cmd.InOrStdin()), cmd.OutOrStdout(), "All the flows and policy recommendations stored in ClickHouse will be deleted permanently. Continue?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("the ClickHouse data cannot be preserved, Theia was not uninstalled: use --export-recommendations to export the recommendation history first, then --purge-data or --yes to delete the data")
+	}
+	return nil
+}
+
+// preserveDataObjects removes the Namespace from the objects to delete, as
+// deleting it would delete the PersistentVolumeClaims of ClickHouse.
+func preserveDataObjects(objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	var preserved []*unstructured.Unstructured
+	for _, object := range objects {
+		if object.GetKind() == "Namespace" {
+			continue
+		}
+		preserved = append(preserved, object)
+	}
+	return preserved
+}
+
+func exportRecommendationHistory(cmd *cobra.Command, kubeconfig string, exportPath string) error {
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return err
+	}
+	if endpoint != "" {
+		err = ParseEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	if err := CheckClickHousePod(clientset); err != nil {
+		return err
+	}
+	connect, pf, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if pf != nil {
+		defer pf.Stop()
+	}
+	if err != nil {
+		return err
+	}
+	defer connect.Close()
+	file, err := os.Create(exportPath)
+	if err != nil {
+		return fmt.Errorf("error when creating file %s: %v", exportPath, err)
+	}
+	defer file.Close()
+	count, err := writeRecommendations(connect, file)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(infoOut(cmd), "Exported %d policy recommendations to %s\n", count, exportPath)
+	return nil
+}
+
+// writeRecommendations writes all the policy recommendations, one JSON object
+// per line, in the order in which they were created. The parts of the results
+// written in several parts are joined.
+func writeRecommendations(connect *sql.DB, out io.Writer) (int, error) {
+	rows, err := connect.Query("SELECT id, type, timeCreated, yamls FROM recommendations ORDER BY timeCreated, id, namespace, part;")
+	if err != nil {
+		return 0, fmt.Errorf("error when querying the policy recommendations: %v", err)
+	}
+	defer rows.Close()
+	encoder := json.NewEncoder(out)
+	count := 0
+	var pending *exportedRecommendation
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		if err := encoder.Encode(pending); err != nil {
+			return fmt.Errorf("error when writing the policy recommendations: %v", err)
+		}
+		count++
+		return nil
+	}
+	for rows.Next() {
+		var recommendation exportedRecommendation
+		if err := rows.Scan(&recommendation.ID, &recommendation.Type, &recommendation.TimeCreated, &recommendation.Yamls); err != nil {
+			return count, fmt.Errorf("error when scanning the policy recommendations: %v", err)
+		}
+		if pending != nil && pending.ID == recommendation.ID {
+			if pending.Yamls == "" {
+				pending.Yamls = recommendation.Yamls
+			} else if recommendation.Yamls != "" {
+				pending.Yamls += "---\n" + recommendation.Yamls
+			}
+			continue
+		}
+		if err := flush(); err != nil {
+			return count, err
+		}
+		pending = &recommendation
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("error when querying the policy recommendations: %v", err)
+	}
+	if err := flush(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+	uninstallCmd.Flags().Bool(
+		"purge-data",
+		false,
+		"delete the data of ClickHouse and the flow-visibility Namespace, instead of preserving the PersistentVolumeClaims of ClickHouse",
+	)
+	uninstallCmd.Flags().String(
+		"export-recommendations",
+		"",
+		"file to which the recommendation history is exported before the uninstallation, one JSON object per line",
+	)
+	uninstallCmd.Flags().String(
+		"clickhouse-endpoint",
+		"",
+		"The ClickHouse service endpoint, used to export the recommendation history.")
+	uninstallCmd.Flags().Bool(
+		"use-cluster-ip",
+		false,
+		`Enable this option will use ClusterIP instead of port forwarding when connecting to the ClickHouse Service.
+It can only be used when running in cluster.`,
+	)
+	uninstallCmd.Flags().String(
+		"clickhouse-ca-cert",
+		"",
+		`Path to a PEM file with the CA certificate(s) used to verify the ClickHouse endpoint. Providing it enables TLS.
+It is only used together with clickhouse-endpoint.`,
+	)
+}
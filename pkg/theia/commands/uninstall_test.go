@@ -0,0 +1,100 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWriteRecommendations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	timeCreated := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, timeCreated, yamls FROM recommendations ORDER BY timeCreated, id, namespace, part;")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "timeCreated", "yamls"}).
+			AddRow("e998433e-accb-4888-9fc8-06563f073e86", "initial", timeCreated, "kind: NetworkPolicy\n").
+			AddRow("e998433e-accb-4888-9fc8-06563f073e86", "initial", timeCreated, "kind: ClusterGroup\n").
+			AddRow("f2e9b0a7-1e8c-4a4f-8d0e-3c1c6a0f5b21", "subsequent", timeCreated.Add(time.Hour), "kind: ClusterNetworkPolicy\n"))
+	var out bytes.Buffer
+	count, err := writeRecommendations(db, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, `{"id":"e998433e-accb-4888-9fc8-06563f073e86","type":"initial","timeCreated":"2023-01-01T00:00:00Z","yamls":"kind: NetworkPolicy\n---\nkind: ClusterGroup\n"}
+{"id":"f2e9b0a7-1e8c-4a4f-8d0e-3c1c6a0f5b21","type":"subsequent","timeCreated":"2023-01-01T01:00:00Z","yamls":"kind: ClusterNetworkPolicy\n"}
+`, out.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPreserveDataObjects(t *testing.T) {
+	newObject := func(kind, name string) *unstructured.Unstructured {
+		object := &unstructured.Unstructured{}
+		object.SetKind(kind)
+		object.SetName(name)
+		return object
+	}
+	objects := []*unstructured.Unstructured{
+		newObject("Namespace", "flow-visibility"),
+		newObject("ClickHouseInstallation", "clickhouse"),
+		newObject("Deployment", "grafana"),
+	}
+	assert.Equal(t, objects[1:], preserveDataObjects(objects))
+}
+
+func TestConfirmDataLoss(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		assumeYes     bool
+		input         string
+		expectedError string
+	}{
+		{name: "yes flag", assumeYes: true},
+		{name: "confirmed", input: "y\n"},
+		{name: "declined", input: "n\n", expectedError: "the ClickHouse data cannot be preserved, Theia was not uninstalled"},
+		{name: "no input", input: "", expectedError: "use --export-recommendations"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			var out, errOut bytes.Buffer
+			cmd.SetIn(strings.NewReader(tc.input))
+			cmd.SetOut(&out)
+			cmd.SetErr(&errOut)
+			err := confirmDataLoss(cmd, tc.assumeYes)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Contains(t, errOut.String(), "ClickHouse does not store its data in PersistentVolumes")
+			if tc.assumeYes {
+				assert.Empty(t, out.String())
+			} else {
+				assert.Contains(t, out.String(), "[y/N]")
+			}
+		})
+	}
+}
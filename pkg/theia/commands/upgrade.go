@@ -0,0 +1,485 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/theia/commands/config"
+	"antrea.io/theia/pkg/util/diskcache"
+	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
+)
+
+// upgradeCRDs are the CRDs installed by the Theia Helm chart, with the version
+// which must be served for this version of the CLI.
+var upgradeCRDs = []struct {
+	name    string
+	version string
+}{
+	{name: "networkpolicyrecommendations.crd.theia.antrea.io", version: "v1alpha1"},
+	{name: "sparkapplications.sparkoperator.k8s.io", version: "v1beta2"},
+}
+
+const (
+	upgradeStatusUpToDate = "UP-TO-DATE"
+	upgradeStatusOutdated = "OUTDATED"
+	upgradeStatusNewer    = "NEWER"
+	upgradeStatusMissing  = "MISSING"
+	upgradeStatusUnknown  = "UNKNOWN"
+)
+
+// upgradeComponent is the state of a deployed Theia component, compared with
+// the version bundled with the CLI.
+type upgradeComponent struct {
+	name     string
+	deployed string
+	expected string
+	status   string
+}
+
+// upgradeStep is a step of the upgrade plan.
+type upgradeStep struct {
+	component   string
+	description string
+	// apply is nil for the steps which must be performed manually, because
+	// they require the Theia Helm chart or may be destructive.
+	apply func() error
+}
+
+func (s upgradeStep) kind() string {
+	if s.apply == nil {
+		return "manual"
+	}
+	return "auto"
+}
+
+// upgradePlan lists the steps in the order in which they must be performed:
+// running jobs must terminate before the CRDs and the ClickHouse schema are
+// upgraded, and the Spark Operator and Grafana are upgraded last.
+type upgradePlan struct {
+	components []upgradeComponent
+	steps      []upgradeStep
+}
+
+func (p *upgradePlan) add(component upgradeComponent, steps []upgradeStep) {
+	p.components = append(p.components, component)
+	p.steps = append(p.steps, steps...)
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Commands to upgrade the Theia components",
+	Long: `Commands to inspect the versions of the deployed Theia components,
+compare them with the versions bundled with this CLI, and perform the upgrade
+steps which are not destructive.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: must also specify a subcommand to run like plan")
+	},
+}
+
+// buildUpgradePlan inspects the deployed components. Components which cannot
+// be inspected are reported with the UNKNOWN status, so that a plan is always
+// returned for the other components.
+func buildUpgradePlan(cmd *cobra.Command) (*upgradePlan, func(), error) {
+	kubeconfig, err := ResolveKubeConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint, err := cmd.Flags().GetString("clickhouse-endpoint")
+	if err != nil {
+		return nil, nil, err
+	}
+	if endpoint != "" {
+		err = ParseEndpoint(endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	caCertPath, err := cmd.Flags().GetString("clickhouse-ca-cert")
+	if err != nil {
+		return nil, nil, err
+	}
+	grafanaEndpoint, err := cmd.Flags().GetString("grafana-endpoint")
+	if err != nil {
+		return nil, nil, err
+	}
+	useClusterIP, err := cmd.Flags().GetBool("use-cluster-ip")
+	if err != nil {
+		return nil, nil, err
+	}
+	clientset, err := CreateK8sClient(kubeconfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	cache, err := discoveryCache(cmd, kubeconfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cleanups []func()
+	cleanup := func() {
+		for _, f := range cleanups {
+			f()
+		}
+	}
+	plan := &upgradePlan{}
+	plan.add(checkSparkJobs(clientset))
+	for _, crd := range upgradeCRDs {
+		served, err := getCRDServedVersions(clientset, cache, crd.name)
+		plan.add(checkCRD(crd.name, crd.version, served, err))
+	}
+	connect, portForward, err := SetupClickHouseConnection(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if portForward != nil {
+		cleanups = append(cleanups, portForward.Stop)
+	}
+	if err != nil {
+		plan.add(checkFlowSchema(nil, nil, err))
+	} else {
+		cleanups = append(cleanups, func() { connect.Close() })
+		schema, err := clickhouse.GetFlowSchema(connect)
+		plan.add(checkFlowSchema(connect, schema, err))
+	}
+	plan.add(checkSparkOperator(clientset))
+	grafana, grafanaPortForward, err := SetupGrafanaConnection(clientset, kubeconfig, grafanaEndpoint, useClusterIP)
+	if grafanaPortForward != nil {
+		cleanups = append(cleanups, grafanaPortForward.Stop)
+	}
+	if err != nil {
+		plan.add(upgradeComponent{name: "Grafana dashboards", deployed: "-", expected: fmt.Sprintf("%d dashboards", len(canonicalDashboardUIDs)), status: upgradeStatusUnknown}, nil)
+	} else {
+		plan.add(checkGrafanaDashboards(grafana))
+	}
+	return plan, cleanup, nil
+}
+
+// checkSparkJobs checks that no running policy recommendation job uses another
+// Spark image than the one bundled with the CLI, as such jobs would fail if
+// the components they depend on were upgraded under them.
+func checkSparkJobs(clientset kubernetes.Interface) (upgradeComponent, []upgradeStep) {
+	component := upgradeComponent{name: "Spark image", deployed: "-", expected: config.SparkImage, status: upgradeStatusUnknown}
+	sparkApplicationList := &sparkv1.SparkApplicationList{}
+	err := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
+		Namespace(config.FlowVisibilityNS).
+		Resource("sparkapplications").
+		Do(context.TODO()).Into(sparkApplicationList)
+	if err != nil {
+		return component, nil
+	}
+	return sparkJobsComponent(sparkApplicationList.Items)
+}
+
+func sparkJobsComponent(sparkApplications []sparkv1.SparkApplication) (upgradeComponent, []upgradeStep) {
+	component := upgradeComponent{name: "Spark image", deployed: "-", expected: config.SparkImage, status: upgradeStatusUpToDate}
+	images := make(map[string]bool)
+	var outdatedJobs []string
+	for _, sparkApplication := range sparkApplications {
+		image := ""
+		if sparkApplication.Spec.Image != nil {
+			image = *sparkApplication.Spec.Image
+		}
+		state := sparkApplication.Status.AppState.State
+		if state != sparkv1.RunningState && state != sparkv1.SubmittedState {
+			continue
+		}
+		images[image] = true
+		if image != config.SparkImage {
+			outdatedJobs = append(outdatedJobs, sparkApplication.Name)
+		}
+	}
+	if len(images) > 0 {
+		var deployed []string
+		for image := range images {
+			deployed = append(deployed, image)
+		}
+		sort.Strings(deployed)
+		component.deployed = strings.Join(deployed, ",")
+	}
+	if len(outdatedJobs) == 0 {
+		return component, nil
+	}
+	component.status = upgradeStatusOutdated
+	sort.Strings(outdatedJobs)
+	return component, []upgradeStep{{
+		component:   component.name,
+		description: fmt.Sprintf("Wait for the running policy recommendation jobs %s to terminate, or delete them with \"theia policy-recommendation delete\"", strings.Join(outdatedJobs, ", ")),
+	}}
+}
+
+// getCRDServedVersions returns the versions served for the CRD, or nil if the
+// CRD is not installed. Installed CRDs are stored in cache, which can be nil,
+// so that they are not checked again by the next commands.
+func getCRDServedVersions(clientset kubernetes.Interface, cache *diskcache.Cache, name string) ([]string, error) {
+	cacheKey := "crd-" + name
+	var served []string
+	if cache.Get(cacheKey, &served) {
+		return served, nil
+	}
+	data, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/apiextensions.k8s.io/v1/customresourcedefinitions", name).
+		DoRaw(context.TODO())
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error when getting the CRD %s: %v", name, err)
+	}
+	var crd struct {
+		Spec struct {
+			Versions []struct {
+				Name   string `json:"name"`
+				Served bool   `json:"served"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &crd); err != nil {
+		return nil, fmt.Errorf("error when decoding the CRD %s: %v", name, err)
+	}
+	for _, version := range crd.Spec.Versions {
+		if version.Served {
+			served = append(served, version.Name)
+		}
+	}
+	if len(served) > 0 {
+		if err := cache.Set(cacheKey, served); err != nil {
+			klog.V(2).ErrorS(err, "Failed to cache the CRD", "name", name)
+		}
+	}
+	return served, nil
+}
+
+func checkCRD(name string, version string, served []string, err error) (upgradeComponent, []upgradeStep) {
+	component := upgradeComponent{name: "CRD " + name, deployed: "-", expected: version, status: upgradeStatusUnknown}
+	if err != nil {
+		return component, nil
+	}
+	if len(served) > 0 {
+		component.deployed = strings.Join(served, ",")
+	}
+	for _, v := range served {
+		if v == version {
+			component.status = upgradeStatusUpToDate
+			return component, nil
+		}
+	}
+	component.status = upgradeStatusOutdated
+	if served == nil {
+		component.status = upgradeStatusMissing
+	}
+	return component, []upgradeStep{{
+		component:   component.name,
+		description: "Apply the CRDs of the Theia Helm chart (\"kubectl apply -f build/charts/theia/crds\"), as Helm does not upgrade CRDs",
+	}}
+}
+
+// checkFlowSchema compares the flow schema deployed in ClickHouse with
+// FlowSchemaVersion. Schema migrations are performed by the ClickHouse schema
+// management container when ClickHouse is upgraded with the Theia Helm chart,
+// so the only automatic step is to record the version of a flows table which
+// predates the versioning of the flow schema but has all the expected columns.
+func checkFlowSchema(connect *sql.DB, schema *clickhouse.FlowSchema, err error) (upgradeComponent, []upgradeStep) {
+	component := upgradeComponent{name: "ClickHouse flow schema", deployed: "-", expected: fmt.Sprint(clickhouse.FlowSchemaVersion), status: upgradeStatusUnknown}
+	if err != nil {
+		return component, nil
+	}
+	component.deployed = fmt.Sprint(schema.Version)
+	missing := schema.MissingColumns(clickhouse.FlowColumns)
+	switch {
+	case schema.Version > clickhouse.FlowSchemaVersion:
+		component.status = upgradeStatusNewer
+		return component, []upgradeStep{{
+			component:   component.name,
+			description: "Upgrade theia, this version of the CLI does not support the deployed flow schema",
+		}}
+	case schema.Version == clickhouse.FlowSchemaVersion && len(missing) == 0:
+		component.status = upgradeStatusUpToDate
+		return component, nil
+	case schema.Version == 0 && len(missing) == 0:
+		component.deployed = "unversioned"
+		component.status = upgradeStatusOutdated
+		return component, []upgradeStep{{
+			component:   component.name,
+			description: fmt.Sprintf("Record flow schema version %d in the %s table", clickhouse.FlowSchemaVersion, clickhouse.MetadataTable),
+			apply: func() error {
+				return recordFlowSchemaVersion(connect)
+			},
+		}}
+	}
+	if schema.Version == 0 {
+		component.deployed = "unversioned"
+	}
+	component.status = upgradeStatusOutdated
+	description := "Upgrade ClickHouse with the Theia Helm chart, to migrate the flows table"
+	if len(missing) > 0 {
+		description += fmt.Sprintf(" (missing columns: %s)", strings.Join(missing, ", "))
+	}
+	return component, []upgradeStep{{
+		component:   component.name,
+		description: description,
+	}}
+}
+
+// recordFlowSchemaVersion creates the metadata table if needed, with the same
+// definition as the ClickHouse init script, and records FlowSchemaVersion.
+func recordFlowSchemaVersion(connect *sql.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS theia_metadata_local ON CLUSTER '{cluster}' (
+			key String,
+			value String,
+			timeUpdated DateTime DEFAULT now()
+		) engine=ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}', timeUpdated)
+		ORDER BY (key);`,
+		`CREATE TABLE IF NOT EXISTS theia_metadata ON CLUSTER '{cluster}' AS theia_metadata_local
+		engine=Distributed('{cluster}', default, theia_metadata_local, rand());`,
+	}
+	for _, query := range queries {
+		if _, err := connect.Exec(query); err != nil {
+			return fmt.Errorf("failed to create the %s table: %v", clickhouse.MetadataTable, err)
+		}
+	}
+	tx, err := connect.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record the flow schema version: %v", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO " + clickhouse.MetadataTable + " (key, value) VALUES (?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record the flow schema version: %v", err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(clickhouse.FlowSchemaVersionKey, fmt.Sprint(clickhouse.FlowSchemaVersion)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record the flow schema version: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record the flow schema version: %v", err)
+	}
+	return nil
+}
+
+// sparkOperatorVersion returns the Spark version of a Spark Operator image,
+// whose tag has the form v1beta2-<operator version>-<Spark version>.
+func sparkOperatorVersion(image string) string {
+	tag := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(tag, ":")
+	if i < 0 {
+		return ""
+	}
+	tag = tag[i+1:]
+	return tag[strings.LastIndex(tag, "-")+1:]
+}
+
+func checkSparkOperator(clientset kubernetes.Interface) (upgradeComponent, []upgradeStep) {
+	component := upgradeComponent{name: "Spark Operator", deployed: "-", expected: "Spark " + config.SparkVersion, status: upgradeStatusUnknown}
+	pods, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/name=spark-operator",
+	})
+	if err != nil {
+		return component, nil
+	}
+	if len(pods.Items) == 0 || len(pods.Items[0].Spec.Containers) == 0 {
+		component.status = upgradeStatusMissing
+		return component, []upgradeStep{{
+			component:   component.name,
+			description: "Install the Spark Operator with the Theia Helm chart (\"--set sparkOperator.enable=true\")",
+		}}
+	}
+	version := sparkOperatorVersion(pods.Items[0].Spec.Containers[0].Image)
+	component.deployed = "Spark " + version
+	if version == config.SparkVersion {
+		component.status = upgradeStatusUpToDate
+		return component, nil
+	}
+	component.status = upgradeStatusOutdated
+	return component, []upgradeStep{{
+		component:   component.name,
+		description: fmt.Sprintf("Upgrade the Spark Operator with the Theia Helm chart, to run Spark %s", config.SparkVersion),
+	}}
+}
+
+func checkGrafanaDashboards(client *grafanaClient) (upgradeComponent, []upgradeStep) {
+	component := upgradeComponent{name: "Grafana dashboards", expected: fmt.Sprintf("%d dashboards", len(canonicalDashboardUIDs)), status: upgradeStatusUpToDate}
+	var missing []string
+	for _, uid := range canonicalDashboardUIDs {
+		var dashboard struct{}
+		if err := client.get("/api/dashboards/uid/"+uid, &dashboard); err != nil {
+			missing = append(missing, uid)
+		}
+	}
+	component.deployed = fmt.Sprintf("%d dashboards", len(canonicalDashboardUIDs)-len(missing))
+	if len(missing) == 0 {
+		return component, nil
+	}
+	component.status = upgradeStatusOutdated
+	return component, []upgradeStep{{
+		component:   component.name,
+		description: fmt.Sprintf("Upgrade Grafana with the Theia Helm chart, to provision the missing dashboards %s", strings.Join(missing, ", ")),
+	}}
+}
+
+func printUpgradePlan(plan *upgradePlan) {
+	table := [][]string{{"Component", "Deployed", "Expected", "Status"}}
+	for _, c := range plan.components {
+		table = append(table, []string{c.name, c.deployed, c.expected, c.status})
+	}
+	TableOutput(table)
+	fmt.Println()
+	if len(plan.steps) == 0 {
+		fmt.Println("All components are up to date")
+		return
+	}
+	fmt.Println("Upgrade plan:")
+	for i, step := range plan.steps {
+		fmt.Printf("%d. [%s] %s: %s\n", i+1, step.kind(), step.component, step.description)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+	upgradeCmd.PersistentFlags().String(
+		"clickhouse-endpoint",
+		"",
+		"The ClickHouse Service endpoint.",
+	)
+	upgradeCmd.PersistentFlags().String(
+		"clickhouse-ca-cert",
+		"",
+		`Path to a PEM file with the CA certificate(s) used to verify the ClickHouse endpoint. Providing it enables TLS.
+It is only used together with clickhouse-endpoint.`,
+	)
+	upgradeCmd.PersistentFlags().String(
+		"grafana-endpoint",
+		"",
+		"The Grafana Service endpoint.",
+	)
+	upgradeCmd.PersistentFlags().Bool(
+		"use-cluster-ip",
+		false,
+		`Enable this option will use ClusterIP instead of port forwarding when connecting to the ClickHouse Service
+and Grafana Service. It can only be used when running in cluster.`,
+	)
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var upgradePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Print the plan to upgrade the Theia components",
+	Long: `Inspect the versions of the deployed Theia components (CRDs, ClickHouse
+flow schema, Spark image and Spark Operator, Grafana dashboards), compare them
+with the versions bundled with this CLI, and print the ordered list of steps
+required to upgrade them. Steps marked as "auto" can be performed with
+"theia upgrade apply", the other ones must be performed manually.`,
+	Example: `
+Print the upgrade plan
+$ theia upgrade plan
+Print the upgrade plan, using ClusterIP to connect to ClickHouse and Grafana
+$ theia upgrade plan --use-cluster-ip
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, cleanup, err := buildUpgradePlan(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		printUpgradePlan(plan)
+		return nil
+	},
+}
+
+var upgradeApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Perform the non-destructive upgrade steps",
+	Long: `Build the upgrade plan as "theia upgrade plan" does, and perform the
+steps marked as "auto", which are not destructive. The remaining manual steps
+are printed afterwards.`,
+	Example: `
+Perform the non-destructive upgrade steps
+$ theia upgrade apply
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, cleanup, err := buildUpgradePlan(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		return applyUpgradePlan(plan)
+	},
+}
+
+// applyUpgradePlan performs the automatic steps of the plan in order and
+// prints the manual steps which remain.
+func applyUpgradePlan(plan *upgradePlan) error {
+	var manual []upgradeStep
+	for _, step := range plan.steps {
+		if step.apply == nil {
+			manual = append(manual, step)
+			continue
+		}
+		fmt.Printf("%s: %s\n", step.component, step.description)
+		if err := step.apply(); err != nil {
+			return fmt.Errorf("error when upgrading %s: %v", step.component, err)
+		}
+	}
+	if len(manual) == 0 {
+		fmt.Println("All components are up to date")
+		return nil
+	}
+	fmt.Println("Remaining manual steps:")
+	for i, step := range manual {
+		fmt.Printf("%d. %s: %s\n", i+1, step.component, step.description)
+	}
+	return nil
+}
+
+func init() {
+	upgradeCmd.AddCommand(upgradePlanCmd)
+	upgradeCmd.AddCommand(upgradeApplyCmd)
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"antrea.io/theia/pkg/clickhouse"
+	"antrea.io/theia/pkg/theia/commands/config"
+	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
+)
+
+func TestSparkOperatorVersion(t *testing.T) {
+	testCases := []struct {
+		image           string
+		expectedVersion string
+	}{
+		{"projects.registry.vmware.com/antrea/theia-spark-operator:v1beta2-1.3.3-3.1.1", "3.1.1"},
+		{"localhost:5000/spark-operator:v1beta2-1.1.0-3.0.0", "3.0.0"},
+		{"localhost:5000/spark-operator", ""},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expectedVersion, sparkOperatorVersion(tt.image), tt.image)
+	}
+}
+
+func TestCheckCRD(t *testing.T) {
+	testCases := []struct {
+		name           string
+		served         []string
+		err            error
+		expectedStatus string
+		expectedSteps  int
+	}{
+		{"up to date", []string{"v1alpha1"}, nil, upgradeStatusUpToDate, 0},
+		{"outdated", []string{"v1alpha0"}, nil, upgradeStatusOutdated, 1},
+		{"missing", nil, nil, upgradeStatusMissing, 1},
+		{"unknown", nil, fmt.Errorf("forbidden"), upgradeStatusUnknown, 0},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			component, steps := checkCRD("networkpolicyrecommendations.crd.theia.antrea.io", "v1alpha1", tt.served, tt.err)
+			assert.Equal(t, tt.expectedStatus, component.status)
+			assert.Len(t, steps, tt.expectedSteps)
+			for _, step := range steps {
+				assert.Nil(t, step.apply)
+			}
+		})
+	}
+}
+
+func TestCheckFlowSchema(t *testing.T) {
+	allColumns := make(map[string]bool)
+	for _, column := range clickhouse.FlowColumns {
+		allColumns[column] = true
+	}
+	testCases := []struct {
+		name             string
+		schema           *clickhouse.FlowSchema
+		expectedDeployed string
+		expectedStatus   string
+		expectedKinds    []string
+	}{
+		{"up to date", &clickhouse.FlowSchema{Version: clickhouse.FlowSchemaVersion, Columns: allColumns}, "1", upgradeStatusUpToDate, nil},
+		{"unversioned", &clickhouse.FlowSchema{Columns: allColumns}, "unversioned", upgradeStatusOutdated, []string{"auto"}},
+		{"missing columns", &clickhouse.FlowSchema{Columns: map[string]bool{"sourceIP": true}}, "unversioned", upgradeStatusOutdated, []string{"manual"}},
+		{"newer", &clickhouse.FlowSchema{Version: clickhouse.FlowSchemaVersion + 1, Columns: allColumns}, "2", upgradeStatusNewer, []string{"manual"}},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			component, steps := checkFlowSchema(nil, tt.schema, nil)
+			assert.Equal(t, tt.expectedDeployed, component.deployed)
+			assert.Equal(t, tt.expectedStatus, component.status)
+			var kinds []string
+			for _, step := range steps {
+				kinds = append(kinds, step.kind())
+			}
+			assert.Equal(t, tt.expectedKinds, kinds)
+		})
+	}
+}
+
+func TestSparkJobsComponent(t *testing.T) {
+	newApp := func(name string, image string, state sparkv1.ApplicationStateType) sparkv1.SparkApplication {
+		return sparkv1.SparkApplication{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       sparkv1.SparkApplicationSpec{Image: &image},
+			Status:     sparkv1.SparkApplicationStatus{AppState: sparkv1.ApplicationState{State: state}},
+		}
+	}
+	component, steps := sparkJobsComponent([]sparkv1.SparkApplication{
+		newApp("pr-1", "theia-policy-recommendation:v0.1.0", sparkv1.CompletedState),
+		newApp("pr-2", config.SparkImage, sparkv1.RunningState),
+	})
+	assert.Equal(t, upgradeStatusUpToDate, component.status)
+	assert.Equal(t, config.SparkImage, component.deployed)
+	assert.Empty(t, steps)
+
+	component, steps = sparkJobsComponent([]sparkv1.SparkApplication{
+		newApp("pr-3", "theia-policy-recommendation:v0.1.0", sparkv1.SubmittedState),
+		newApp("pr-2", config.SparkImage, sparkv1.RunningState),
+	})
+	assert.Equal(t, upgradeStatusOutdated, component.status)
+	require.Len(t, steps, 1)
+	assert.Contains(t, steps[0].description, "pr-3")
+	assert.NotContains(t, steps[0].description, "pr-2")
+}
+
+func TestCheckSparkOperator(t *testing.T) {
+	newPod := func(image string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "policy-recommendation-spark-operator",
+				Namespace: config.FlowVisibilityNS,
+				Labels:    map[string]string{"app.kubernetes.io/name": "spark-operator"},
+			},
+			Spec: v1.PodSpec{Containers: []v1.Container{{Name: "spark-operator", Image: image}}},
+		}
+	}
+	testCases := []struct {
+		name           string
+		clientset      *fake.Clientset
+		expectedStatus string
+		expectedSteps  int
+	}{
+		{"up to date", fake.NewSimpleClientset(newPod("spark-operator:v1beta2-1.3.3-" + config.SparkVersion)), upgradeStatusUpToDate, 0},
+		{"outdated", fake.NewSimpleClientset(newPod("spark-operator:v1beta2-1.1.0-3.0.0")), upgradeStatusOutdated, 1},
+		{"missing", fake.NewSimpleClientset(), upgradeStatusMissing, 1},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			component, steps := checkSparkOperator(tt.clientset)
+			assert.Equal(t, tt.expectedStatus, component.status)
+			assert.Len(t, steps, tt.expectedSteps)
+		})
+	}
+}
+
+func TestCheckGrafanaDashboards(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/dashboards/uid/"+canonicalDashboardUIDs[0] {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Dashboard not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"dashboard": {}}`)
+	}))
+	defer server.Close()
+
+	client := &grafanaClient{baseURL: server.URL, httpClient: server.Client()}
+	component, steps := checkGrafanaDashboards(client)
+	assert.Equal(t, upgradeStatusOutdated, component.status)
+	assert.Equal(t, fmt.Sprintf("%d dashboards", len(canonicalDashboardUIDs)-1), component.deployed)
+	require.Len(t, steps, 1)
+	assert.Contains(t, steps[0].description, canonicalDashboardUIDs[0])
+}
+
+func TestApplyUpgradePlan(t *testing.T) {
+	var applied []string
+	plan := &upgradePlan{}
+	plan.add(upgradeComponent{name: "a"}, []upgradeStep{{component: "a", description: "manual step"}})
+	plan.add(upgradeComponent{name: "b"}, []upgradeStep{{component: "b", description: "auto step", apply: func() error {
+		applied = append(applied, "b")
+		return nil
+	}}})
+	plan.add(upgradeComponent{name: "c"}, []upgradeStep{{component: "c", description: "auto step", apply: func() error {
+		applied = append(applied, "c")
+		return nil
+	}}})
+	require.NoError(t, applyUpgradePlan(plan))
+	assert.Equal(t, []string{"b", "c"}, applied)
+
+	plan = &upgradePlan{}
+	plan.add(upgradeComponent{name: "d"}, []upgradeStep{{component: "d", description: "auto step", apply: func() error {
+		return fmt.Errorf("failure")
+	}}})
+	assert.Error(t, applyUpgradePlan(plan))
+}
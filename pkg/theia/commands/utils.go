@@ -15,25 +15,35 @@
 package commands
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"time"
+	// embed the IANA time zone database, which may be missing on Windows
+	_ "time/tzdata"
 
-	"github.com/ClickHouse/clickhouse-go"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"antrea.io/theia/pkg/client/clientset/versioned"
+	"antrea.io/theia/pkg/theia/clickhouse"
 	"antrea.io/theia/pkg/theia/commands/config"
 	"antrea.io/theia/pkg/theia/portforwarder"
+	"antrea.io/theia/pkg/util/diskcache"
+	"antrea.io/theia/pkg/util/executor"
+	"antrea.io/theia/pkg/util/validation"
 )
 
 func CreateK8sClient(kubeconfig string) (kubernetes.Interface, error) {
@@ -49,45 +59,118 @@ func CreateK8sClient(kubeconfig string) (kubernetes.Interface, error) {
 	return clientset, nil
 }
 
-func PolicyRecoPreCheck(clientset kubernetes.Interface) error {
-	err := CheckSparkOperatorPod(clientset)
+// CreateCRDClient creates a clientset for the Theia CRDs.
+func CreateCRDClient(kubeconfig string) (versioned.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = CheckClickHousePod(clientset)
+	return versioned.NewForConfig(config)
+}
+
+// CreateDynamicClient creates a dynamic client, used to manage the recommended
+// policies.
+func CreateDynamicClient(kubeconfig string) (dynamic.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return dynamic.NewForConfig(config)
 }
 
-func CheckSparkOperatorPod(clientset kubernetes.Interface) error {
-	// Check the deployment of Spark Operator in flow-visibility ns
-	pods, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=spark-operator",
-	})
+// discoveryCache returns the cache of the data discovered from the apiserver
+// of the kubeconfig, or nil if it is disabled with --discovery-cache-ttl.
+func discoveryCache(cmd *cobra.Command, kubeconfig string) (*diskcache.Cache, error) {
+	ttlStr, err := cmd.Flags().GetString("discovery-cache-ttl")
 	if err != nil {
-		return fmt.Errorf("error %v when finding the policy-recommendation-spark-operator Pod, please check the deployment of the Spark Operator", err)
+		return nil, err
 	}
-	if len(pods.Items) < 1 {
-		return fmt.Errorf("can't find the policy-recommendation-spark-operator Pod, please check the deployment of the Spark Operator")
+	ttl, err := ParseDuration(ttlStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing discovery-cache-ttl: %v", err)
 	}
-	hasRunningPod := false
-	for _, pod := range pods.Items {
-		if pod.Status.Phase == "Running" {
-			hasRunningPod = true
-			break
-		}
+	cacheDir := defaultCacheDir()
+	if ttl == 0 || cacheDir == "" {
+		return nil, nil
 	}
-	if !hasRunningPod {
-		return fmt.Errorf("can't find a running Spark Operator Pod, please check the deployment of Spark")
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create k8s client using given kubeconfig, %v", err)
+	}
+	return diskcache.ForHost(cacheDir, restConfig.Host, ttl), nil
+}
+
+// preCheckTimeout is the timeout of each pre-check, so that a slow or
+// unreachable API server does not block the commands indefinitely.
+var preCheckTimeout = 30 * time.Second
+
+// preCheck checks that a component required by the commands is running.
+type preCheck struct {
+	name  string
+	check func(ctx context.Context, clientset kubernetes.Interface) error
+}
+
+var (
+	sparkOperatorPreCheck = preCheck{name: "Spark Operator", check: checkSparkOperatorPod}
+	clickHousePreCheck    = preCheck{name: "ClickHouse", check: checkClickHousePod}
+)
+
+// PolicyRecoPreCheck checks that the components required by policy
+// recommendation are running. The checks run concurrently, and the errors of
+// all the failed checks are returned.
+func PolicyRecoPreCheck(clientset kubernetes.Interface) error {
+	return runPreChecks(clientset, sparkOperatorPreCheck, clickHousePreCheck)
+}
+
+// runPreChecks runs the checks concurrently, each with preCheckTimeout, and
+// returns the aggregated errors of the failed checks, in the order of the
+// checks.
+func runPreChecks(clientset kubernetes.Interface, checks ...preCheck) error {
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = runPreCheck(clientset, checks[i])
+		}(i)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+func runPreCheck(clientset kubernetes.Interface, check preCheck) error {
+	ctx, cancel := context.WithTimeout(context.Background(), preCheckTimeout)
+	defer cancel()
+	// The result is buffered, so that the check does not block once it has
+	// timed out.
+	result := make(chan error, 1)
+	go func() {
+		result <- check.check(ctx, clientset)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("the %s check timed out after %v, please check the connectivity to the Kubernetes API server", check.name, preCheckTimeout)
 	}
-	return nil
+}
+
+func CheckSparkOperatorPod(clientset kubernetes.Interface) error {
+	return runPreChecks(clientset, sparkOperatorPreCheck)
 }
 
 func CheckClickHousePod(clientset kubernetes.Interface) error {
+	return runPreChecks(clientset, clickHousePreCheck)
+}
+
+func checkSparkOperatorPod(ctx context.Context, clientset kubernetes.Interface) error {
+	return executor.CheckSparkOperatorPod(ctx, clientset, config.FlowVisibilityNS)
+}
+
+func checkClickHousePod(ctx context.Context, clientset kubernetes.Interface) error {
 	// Check the ClickHouse deployment in flow-visibility namespace
-	pods, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).List(context.TODO(), metav1.ListOptions{
+	pods, err := clientset.CoreV1().Pods(config.FlowVisibilityNS).List(ctx, metav1.ListOptions{
 		LabelSelector: "app=clickhouse",
 	})
 	if err != nil {
@@ -165,55 +248,20 @@ func ResolveKubeConfig(cmd *cobra.Command) (string, error) {
 	return kubeconfigPath, nil
 }
 
-func getClickHouseSecret(clientset kubernetes.Interface) (username []byte, password []byte, err error) {
-	secret, err := clientset.CoreV1().Secrets(config.FlowVisibilityNS).Get(context.TODO(), "clickhouse-secret", metav1.GetOptions{})
-	if err != nil {
-		return username, password, fmt.Errorf("error %v when finding the ClickHouse secret, please check the deployment of ClickHouse", err)
-	}
-	username, ok := secret.Data["username"]
-	if !ok {
-		return username, password, fmt.Errorf("error when getting the ClickHouse username")
-	}
-	password, ok = secret.Data["password"]
-	if !ok {
-		return username, password, fmt.Errorf("error when getting the ClickHouse password")
-	}
-	return username, password, nil
+// setupClickHouseClient connects to the ClickHouse endpoint given by the user,
+// or else to the ClickHouse Service, through its ClusterIP or a port forward.
+// The port forward must be stopped by the caller when it is not nil, even if
+// an error is returned.
+func setupClickHouseClient(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool) (client *clickhouse.Client, portForward *portforwarder.PortForwarder, err error) {
+	return connectClickHouse(clientset, kubeconfig, endpoint, caCertPath, useClusterIP, false)
 }
 
-func connectClickHouse(clientset kubernetes.Interface, url string) (*sql.DB, error) {
-	var connect *sql.DB
-	var connErr error
-	connRetryInterval := 1 * time.Second
-	connTimeout := 10 * time.Second
-
-	// Connect to ClickHouse in a loop
-	if err := wait.PollImmediate(connRetryInterval, connTimeout, func() (bool, error) {
-		// Open the database and ping it
-		var err error
-		connect, err = sql.Open("clickhouse", url)
-		if err != nil {
-			connErr = fmt.Errorf("failed to open ClickHouse: %v", err)
-			return false, nil
-		}
-		if err := connect.Ping(); err != nil {
-			if exception, ok := err.(*clickhouse.Exception); ok {
-				connErr = fmt.Errorf("failed to ping ClickHouse: %v", exception.Message)
-			} else {
-				connErr = fmt.Errorf("failed to ping ClickHouse: %v", err)
-			}
-			return false, nil
-		} else {
-			return true, nil
-		}
-	}); err != nil {
-		return nil, fmt.Errorf("failed to connect to ClickHouse after %s: %v", connTimeout, connErr)
-	}
-	return connect, nil
-}
-
-func SetupClickHouseConnection(clientset kubernetes.Interface, kubeconfig string, endpoint string, useClusterIP bool) (connect *sql.DB, portForward *portforwarder.PortForwarder, err error) {
-	if endpoint == "" {
+// connectClickHouse is like setupClickHouseClient. When readOnly is true,
+// ClickHouse rejects the statements run on the connections which write data
+// or change settings.
+func connectClickHouse(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool, readOnly bool) (client *clickhouse.Client, portForward *portforwarder.PortForwarder, err error) {
+	external := endpoint != ""
+	if !external {
 		service := "clickhouse-clickhouse"
 		if useClusterIP {
 			serviceIP, servicePort, err := GetServiceAddr(clientset, service)
@@ -238,16 +286,34 @@ func SetupClickHouseConnection(clientset kubernetes.Interface, kubeconfig string
 	}
 
 	// Connect to ClickHouse and execute query
-	username, password, err := getClickHouseSecret(clientset)
+	username, password, err := clickhouse.GetCredentials(context.TODO(), clientset, config.FlowVisibilityNS)
 	if err != nil {
 		return nil, portForward, err
 	}
-	url := fmt.Sprintf("%s?debug=false&username=%s&password=%s", endpoint, username, password)
-	connect, err = connectClickHouse(clientset, url)
+	client, err = clickhouse.Connect(clickhouse.Config{
+		Endpoint: endpoint,
+		Username: string(username),
+		Password: string(password),
+		// The endpoint may be outside the cluster, e.g. behind an ingress or
+		// a corporate gateway, so honor proxy settings and custom CA trust.
+		External:   external,
+		CACertPath: caCertPath,
+		ReadOnly:   readOnly,
+	})
 	if err != nil {
 		return nil, portForward, fmt.Errorf("error when connecting to ClickHouse, %v", err)
 	}
-	return connect, portForward, nil
+	return client, portForward, nil
+}
+
+// SetupClickHouseConnection is like setupClickHouseClient, for the commands
+// running their own queries.
+func SetupClickHouseConnection(clientset kubernetes.Interface, kubeconfig string, endpoint string, caCertPath string, useClusterIP bool) (connect *sql.DB, portForward *portforwarder.PortForwarder, err error) {
+	client, portForward, err := setupClickHouseClient(clientset, kubeconfig, endpoint, caCertPath, useClusterIP)
+	if err != nil {
+		return nil, portForward, err
+	}
+	return client.DB(), portForward, nil
 }
 
 func TableOutput(table [][]string) {
@@ -272,6 +338,28 @@ func TableOutputVertical(table [][]string) {
 	}
 }
 
+// infoOut returns the writer of the informational messages of a command, e.g.
+// progress and success messages, which are discarded with --quiet. Results,
+// prompts and warnings are always written.
+func infoOut(cmd *cobra.Command) io.Writer {
+	if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+		return io.Discard
+	}
+	return cmd.OutOrStdout()
+}
+
+// promptConfirmation asks the question on out and returns true if the answer
+// read from in is "y" or "yes".
+func promptConfirmation(in *bufio.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, err := in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
 func FormatTimestamp(timestamp time.Time) string {
 	if timestamp.IsZero() {
 		return "N/A"
@@ -287,6 +375,18 @@ func ParseEndpoint(endpoint string) error {
 	return nil
 }
 
+// ParseDuration parses a duration like time.ParseDuration, and also accepts a
+// whole number of days with the "d" unit, e.g. "30d".
+func ParseDuration(duration string) (time.Duration, error) {
+	return validation.Duration(duration)
+}
+
+// ParseTimestamp parses a timestamp in RFC3339 format, or in the
+// "YYYY-MM-DD hh:mm:ss" format in the given location, and returns it in UTC.
+func ParseTimestamp(timestamp string, location *time.Location) (time.Time, error) {
+	return validation.Timestamp(timestamp, location)
+}
+
 func ParseRecommendationID(recommendationID string) error {
 	_, err := uuid.Parse(recommendationID)
 	if err != nil {
@@ -15,11 +15,16 @@
 package commands
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 
 	"antrea.io/theia/pkg/theia/commands/config"
@@ -109,10 +114,26 @@ func TestPolicyRecoPreCheck(t *testing.T) {
 			expectedErrorMsg: "",
 		},
 		{
-			name:             "spark operator pod not found",
-			fakeClientset:    fake.NewSimpleClientset(),
+			name: "spark operator pod not found",
+			fakeClientset: fake.NewSimpleClientset(
+				&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "clickhouse",
+						Namespace: config.FlowVisibilityNS,
+						Labels:    map[string]string{"app": "clickhouse"},
+					},
+					Status: v1.PodStatus{
+						Phase: v1.PodRunning,
+					},
+				},
+			),
 			expectedErrorMsg: "can't find the policy-recommendation-spark-operator Pod, please check the deployment of the Spark Operator",
 		},
+		{
+			name:             "spark operator and clickhouse pods not found",
+			fakeClientset:    fake.NewSimpleClientset(),
+			expectedErrorMsg: "[can't find the policy-recommendation-spark-operator Pod, please check the deployment of the Spark Operator, can't find the ClickHouse Pod, please check the deployment of ClickHouse]",
+		},
 		{
 			name: "clickhouse pod not found",
 			fakeClientset: fake.NewSimpleClientset(
@@ -143,3 +164,93 @@ func TestPolicyRecoPreCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestRunPreChecks(t *testing.T) {
+	defer func(timeout time.Duration) {
+		preCheckTimeout = timeout
+	}(preCheckTimeout)
+	preCheckTimeout = 100 * time.Millisecond
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+	checks := []preCheck{
+		{
+			name: "blocked",
+			check: func(ctx context.Context, clientset kubernetes.Interface) error {
+				<-unblock
+				return nil
+			},
+		},
+		{
+			name: "failed",
+			check: func(ctx context.Context, clientset kubernetes.Interface) error {
+				return fmt.Errorf("component not found")
+			},
+		},
+		{
+			name: "passed",
+			check: func(ctx context.Context, clientset kubernetes.Interface) error {
+				return nil
+			},
+		},
+	}
+	start := time.Now()
+	err := runPreChecks(fake.NewSimpleClientset(), checks...)
+	assert.EqualError(t, err, "[the blocked check timed out after 100ms, please check the connectivity to the Kubernetes API server, component not found]")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.NoError(t, runPreChecks(fake.NewSimpleClientset(), checks[2]))
+}
+
+func TestParseDuration(t *testing.T) {
+	testCases := []struct {
+		duration         string
+		expectedDuration time.Duration
+		expectedErrorMsg string
+	}{
+		{duration: "30d", expectedDuration: 30 * 24 * time.Hour},
+		{duration: "12h", expectedDuration: 12 * time.Hour},
+		{duration: "1h30m", expectedDuration: 90 * time.Minute},
+		{duration: "-1d", expectedErrorMsg: "input duration -1d does not seem valid, it should be like 12h or 30d"},
+		{duration: "d", expectedErrorMsg: "input duration d does not seem valid, it should be like 12h or 30d"},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.duration, func(t *testing.T) {
+			duration, err := ParseDuration(tt.duration)
+			if tt.expectedErrorMsg != "" {
+				assert.EqualError(t, err, tt.expectedErrorMsg)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedDuration, duration)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	testCases := []struct {
+		timestamp        string
+		location         *time.Location
+		expectedTime     time.Time
+		expectedErrorMsg string
+	}{
+		{timestamp: "2022-01-01 00:00:00", location: time.UTC, expectedTime: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{timestamp: "2022-01-01 00:00:00", location: losAngeles, expectedTime: time.Date(2022, 1, 1, 8, 0, 0, 0, time.UTC)},
+		{timestamp: "2022-07-01 00:00:00", location: losAngeles, expectedTime: time.Date(2022, 7, 1, 7, 0, 0, 0, time.UTC)},
+		{timestamp: "2022-01-01T00:00:00+02:00", location: losAngeles, expectedTime: time.Date(2021, 12, 31, 22, 0, 0, 0, time.UTC)},
+		{timestamp: "2022-01-01T00:00:00Z", location: losAngeles, expectedTime: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{timestamp: "2022/01/01", location: time.UTC, expectedErrorMsg: "input timestamp 2022/01/01 does not seem valid"},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.timestamp, func(t *testing.T) {
+			timestamp, err := ParseTimestamp(tt.timestamp, tt.location)
+			if tt.expectedErrorMsg != "" {
+				assert.ErrorContains(t, err, tt.expectedErrorMsg)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedTime, timestamp)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package anonymize replaces the identifiers found in flow records, i.e. IP
+// addresses and Kubernetes object names, with pseudonyms, so that flows can be
+// shared without disclosing the topology of the cluster. The same identifier is
+// always replaced with the same pseudonym, so that the flows can still be
+// correlated.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Mode selects how the identifiers are replaced.
+type Mode string
+
+const (
+	// ModeNone keeps the identifiers unchanged.
+	ModeNone Mode = "none"
+	// ModeHash replaces the identifiers with a keyed hash (HMAC-SHA256). The
+	// pseudonyms are consistent across exports using the same key, and cannot
+	// be reverted without brute-forcing the key.
+	ModeHash Mode = "hash"
+	// ModeMap replaces the identifiers with sequential pseudonyms, and records
+	// the mapping so that the pseudonyms can be translated back by whoever
+	// holds it.
+	ModeMap Mode = "map"
+)
+
+// Kind is the kind of an identifier. Pseudonyms are prefixed with the kind,
+// except for IP addresses which are replaced with IP addresses of the same
+// family.
+type Kind string
+
+const (
+	KindIP        Kind = "ip"
+	KindNamespace Kind = "ns"
+	KindPod       Kind = "pod"
+	KindNode      Kind = "node"
+	KindService   Kind = "svc"
+	KindPolicy    Kind = "policy"
+)
+
+// ParseMode returns the Mode with the given name.
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case ModeNone, ModeHash, ModeMap:
+		return Mode(mode), nil
+	}
+	return "", fmt.Errorf("unsupported anonymization mode %s, it should be one of none, hash or map", mode)
+}
+
+// Anonymizer replaces identifiers with pseudonyms. It is not safe for
+// concurrent use.
+type Anonymizer struct {
+	mode Mode
+	key  []byte
+	// mapping maps, for each kind, the identifiers to their pseudonym in
+	// ModeMap.
+	mapping map[Kind]map[string]string
+	// used records the pseudonyms in use in ModeMap, to skip them when
+	// allocating new ones after loading an existing mapping.
+	used map[Kind]map[string]bool
+	// next is the sequence number of the next pseudonym allocated for each
+	// kind in ModeMap.
+	next map[Kind]uint64
+}
+
+// New returns an Anonymizer for the given mode. The key is required with
+// ModeHash and ignored otherwise.
+func New(mode Mode, key []byte) (*Anonymizer, error) {
+	if mode == ModeHash && len(key) == 0 {
+		return nil, fmt.Errorf("a key is required with the %s anonymization mode", ModeHash)
+	}
+	return &Anonymizer{
+		mode:    mode,
+		key:     key,
+		mapping: map[Kind]map[string]string{},
+		used:    map[Kind]map[string]bool{},
+		next:    map[Kind]uint64{},
+	}, nil
+}
+
+// Mode returns the mode of the Anonymizer.
+func (a *Anonymizer) Mode() Mode {
+	return a.mode
+}
+
+// Anonymize returns the pseudonym of value. Empty values are kept unchanged,
+// so that missing fields remain distinguishable.
+func (a *Anonymizer) Anonymize(kind Kind, value string) string {
+	if value == "" || a.mode == ModeNone {
+		return value
+	}
+	if kind == KindIP {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			// Not an IP address, e.g. a masked value: hash it like a name
+			// to avoid leaking it.
+			return a.anonymizeName(kind, value)
+		}
+		if ip.IsUnspecified() {
+			return value
+		}
+		return a.anonymizeIP(ip)
+	}
+	return a.anonymizeName(kind, value)
+}
+
+// AnonymizeServicePortName anonymizes a Service port name in the
+// "namespace/name:port" format used by the flow records, keeping the port
+// name since it is usually a protocol name like "http".
+func (a *Anonymizer) AnonymizeServicePortName(value string) string {
+	if value == "" || a.mode == ModeNone {
+		return value
+	}
+	service, port, hasPort := strings.Cut(value, ":")
+	namespace, name, found := strings.Cut(service, "/")
+	if !found {
+		return a.Anonymize(KindService, value)
+	}
+	result := a.Anonymize(KindNamespace, namespace) + "/" + a.Anonymize(KindService, name)
+	if hasPort {
+		result += ":" + port
+	}
+	return result
+}
+
+func (a *Anonymizer) anonymizeName(kind Kind, value string) string {
+	if a.mode == ModeHash {
+		return fmt.Sprintf("%s-%s", kind, hex.EncodeToString(a.digest(kind, value)[:6]))
+	}
+	return a.lookup(kind, value, func(n uint64) string {
+		return fmt.Sprintf("%s-%d", kind, n)
+	})
+}
+
+func (a *Anonymizer) anonymizeIP(ip net.IP) string {
+	ipv4 := ip.To4()
+	if a.mode == ModeHash {
+		digest := a.digest(KindIP, ip.String())
+		if ipv4 != nil {
+			return net.IP(digest[:net.IPv4len]).String()
+		}
+		return net.IP(digest[:net.IPv6len]).String()
+	}
+	// Sequential addresses are allocated from 10.0.0.0/8 for IPv4 and from
+	// fd00::/8 for IPv6. 10.0.0.0/8 is large enough for the number of IPv4
+	// addresses seen in a cluster.
+	kind := Kind("ipv6")
+	if ipv4 != nil {
+		kind = Kind("ipv4")
+	}
+	return a.lookup(kind, ip.String(), func(n uint64) string {
+		if ipv4 != nil {
+			pseudonym := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(pseudonym, 10<<24|uint32(n&0xffffff))
+			return pseudonym.String()
+		}
+		pseudonym := make(net.IP, net.IPv6len)
+		pseudonym[0] = 0xfd
+		binary.BigEndian.PutUint64(pseudonym[8:], n)
+		return pseudonym.String()
+	})
+}
+
+func (a *Anonymizer) digest(kind Kind, value string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	// The kind is included so that e.g. a Pod and a Namespace with the same
+	// name are not linkable.
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+func (a *Anonymizer) lookup(kind Kind, value string, pseudonym func(n uint64) string) string {
+	if result, ok := a.mapping[kind][value]; ok {
+		return result
+	}
+	var result string
+	for {
+		a.next[kind]++
+		result = pseudonym(a.next[kind])
+		if !a.used[kind][result] {
+			break
+		}
+	}
+	a.add(kind, value, result)
+	return result
+}
+
+func (a *Anonymizer) add(kind Kind, value, pseudonym string) {
+	if a.mapping[kind] == nil {
+		a.mapping[kind] = map[string]string{}
+		a.used[kind] = map[string]bool{}
+	}
+	a.mapping[kind][value] = pseudonym
+	a.used[kind][pseudonym] = true
+}
+
+// Mapping returns, for each kind, the identifiers replaced so far in ModeMap
+// with their pseudonym. IPv4 and IPv6 addresses are under the "ipv4" and "ipv6"
+// kinds.
+func (a *Anonymizer) Mapping() map[Kind]map[string]string {
+	return a.mapping
+}
+
+// LoadMapping reads a mapping saved with SaveMapping, so that the pseudonyms
+// of a previous export are reused. A missing file is not an error.
+func (a *Anonymizer) LoadMapping(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error when reading anonymization mapping %s: %v", path, err)
+	}
+	var mapping map[Kind]map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return fmt.Errorf("error when decoding anonymization mapping %s: %v", path, err)
+	}
+	for kind, values := range mapping {
+		for value, pseudonym := range values {
+			a.add(kind, value, pseudonym)
+		}
+	}
+	return nil
+}
+
+// SaveMapping writes the mapping as JSON to path. The file is only readable
+// by its owner, since it allows reverting the anonymization.
+func (a *Anonymizer) SaveMapping(path string) error {
+	data, err := json.MarshalIndent(a.mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("error when writing anonymization mapping %s: %v", path, err)
+	}
+	return nil
+}
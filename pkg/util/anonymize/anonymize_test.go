@@ -0,0 +1,97 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package anonymize
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []string{"none", "hash", "map"} {
+		parsed, err := ParseMode(mode)
+		require.NoError(t, err)
+		assert.Equal(t, Mode(mode), parsed)
+	}
+	_, err := ParseMode("encrypt")
+	assert.ErrorContains(t, err, "unsupported anonymization mode")
+}
+
+func TestAnonymizeNone(t *testing.T) {
+	a, err := New(ModeNone, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "10.10.0.1", a.Anonymize(KindIP, "10.10.0.1"))
+	assert.Equal(t, "frontend", a.Anonymize(KindPod, "frontend"))
+	assert.Equal(t, "app/web:http", a.AnonymizeServicePortName("app/web:http"))
+}
+
+func TestAnonymizeHash(t *testing.T) {
+	_, err := New(ModeHash, nil)
+	assert.ErrorContains(t, err, "a key is required")
+
+	a, err := New(ModeHash, []byte("secret"))
+	require.NoError(t, err)
+	b, err := New(ModeHash, []byte("secret"))
+	require.NoError(t, err)
+	c, err := New(ModeHash, []byte("other"))
+	require.NoError(t, err)
+
+	pod := a.Anonymize(KindPod, "frontend")
+	assert.Regexp(t, `^pod-[0-9a-f]{12}$`, pod)
+	assert.Equal(t, pod, b.Anonymize(KindPod, "frontend"), "pseudonyms should be consistent for the same key")
+	assert.NotEqual(t, pod, c.Anonymize(KindPod, "frontend"), "pseudonyms should depend on the key")
+	assert.NotEqual(t, pod[len("pod-"):], a.Anonymize(KindNamespace, "frontend")[len("ns-"):], "pseudonyms should depend on the kind")
+
+	ipv4 := a.Anonymize(KindIP, "10.10.0.1")
+	assert.NotEqual(t, "10.10.0.1", ipv4)
+	assert.Regexp(t, `^\d+\.\d+\.\d+\.\d+$`, ipv4)
+	assert.Equal(t, ipv4, b.Anonymize(KindIP, "10.10.0.1"))
+	ipv6 := a.Anonymize(KindIP, "fd12::1")
+	assert.Contains(t, ipv6, ":")
+	assert.NotEqual(t, "fd12::1", ipv6)
+	assert.Equal(t, "", a.Anonymize(KindIP, ""))
+	assert.Equal(t, "0.0.0.0", a.Anonymize(KindIP, "0.0.0.0"))
+}
+
+func TestAnonymizeMap(t *testing.T) {
+	a, err := New(ModeMap, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pod-1", a.Anonymize(KindPod, "frontend"))
+	assert.Equal(t, "pod-2", a.Anonymize(KindPod, "backend"))
+	assert.Equal(t, "pod-1", a.Anonymize(KindPod, "frontend"))
+	assert.Equal(t, "ns-1", a.Anonymize(KindNamespace, "app"))
+	assert.Equal(t, "10.0.0.1", a.Anonymize(KindIP, "192.168.1.10"))
+	assert.Equal(t, "10.0.0.2", a.Anonymize(KindIP, "192.168.1.11"))
+	assert.Equal(t, "fd00::1", a.Anonymize(KindIP, "fd12::1"))
+	assert.Equal(t, "ns-1/svc-1:http", a.AnonymizeServicePortName("app/web:http"))
+
+	path := filepath.Join(t.TempDir(), "mapping.json")
+	require.NoError(t, a.SaveMapping(path))
+
+	b, err := New(ModeMap, nil)
+	require.NoError(t, err)
+	require.NoError(t, b.LoadMapping(path))
+	assert.Equal(t, "pod-2", b.Anonymize(KindPod, "backend"))
+	assert.Equal(t, "pod-3", b.Anonymize(KindPod, "database"), "pseudonyms of the loaded mapping should not be reused")
+	assert.Equal(t, "10.0.0.3", b.Anonymize(KindIP, "192.168.1.12"))
+	assert.Equal(t, a.Mapping()[KindNamespace], b.Mapping()[KindNamespace])
+
+	c, err := New(ModeMap, nil)
+	require.NoError(t, err)
+	assert.NoError(t, c.LoadMapping(filepath.Join(t.TempDir(), "missing.json")))
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskcache
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+)
+
+// discoveryKey is the key of the API groups and resources in the cache.
+const discoveryKey = "discovery"
+
+// groupsAndResources are the API groups and resources served by the
+// apiserver.
+type groupsAndResources struct {
+	Groups    []*metav1.APIGroup        `json:"groups"`
+	Resources []*metav1.APIResourceList `json:"resources"`
+}
+
+// discoveryClient caches the API groups and resources returned by
+// ServerGroupsAndResources, which is what the discovery REST mappers use, in
+// memory and in a Cache. The other methods are not cached.
+type discoveryClient struct {
+	discovery.DiscoveryInterface
+	cache *Cache
+
+	mutex  sync.Mutex
+	cached *groupsAndResources
+	// fresh is true when the cached groups and resources were discovered by
+	// this client, instead of being read from the Cache.
+	fresh bool
+}
+
+// NewDiscoveryClient returns a discovery client caching the API groups and
+// resources discovered by delegate in the cache.
+func NewDiscoveryClient(delegate discovery.DiscoveryInterface, cache *Cache) discovery.CachedDiscoveryInterface {
+	return &discoveryClient{
+		DiscoveryInterface: delegate,
+		cache:              cache,
+	}
+}
+
+func (d *discoveryClient) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.cached != nil {
+		return d.cached.Groups, d.cached.Resources, nil
+	}
+	var cached groupsAndResources
+	if d.cache.Get(discoveryKey, &cached) {
+		d.cached = &cached
+		return cached.Groups, cached.Resources, nil
+	}
+	groups, resources, err := d.DiscoveryInterface.ServerGroupsAndResources()
+	if err != nil {
+		// Partial results, e.g. when an aggregated API is unavailable,
+		// are not cached.
+		return groups, resources, err
+	}
+	d.cached = &groupsAndResources{Groups: groups, Resources: resources}
+	d.fresh = true
+	if err := d.cache.Set(discoveryKey, d.cached); err != nil {
+		klog.V(2).InfoS("Failed to cache the API resources", "error", err)
+	}
+	return groups, resources, nil
+}
+
+func (d *discoveryClient) Fresh() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.fresh
+}
+
+// Invalidate discards the cached groups and resources, so that they are
+// discovered again.
+func (d *discoveryClient) Invalidate() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.cached = nil
+	d.fresh = false
+	if err := d.cache.Delete(discoveryKey); err != nil {
+		klog.V(2).InfoS("Failed to invalidate the cached API resources", "error", err)
+	}
+}
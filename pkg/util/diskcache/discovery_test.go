@@ -0,0 +1,72 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeDiscovery() *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{
+		Fake: &k8stesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "crd.theia.antrea.io/v1alpha1",
+					APIResources: []metav1.APIResource{
+						{Name: "networkpolicyrecommendations", Kind: "NetworkPolicyRecommendation", Namespaced: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDiscoveryClient(t *testing.T) {
+	cache := New(t.TempDir(), time.Minute)
+	delegate := newFakeDiscovery()
+
+	client := NewDiscoveryClient(delegate, cache)
+	_, resources, err := client.ServerGroupsAndResources()
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.True(t, client.Fresh())
+	_, _, err = client.ServerGroupsAndResources()
+	require.NoError(t, err)
+	// The next command reads the resources from the cache.
+	otherDelegate := newFakeDiscovery()
+	otherClient := NewDiscoveryClient(otherDelegate, cache)
+	_, resources, err = otherClient.ServerGroupsAndResources()
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "networkpolicyrecommendations", resources[0].APIResources[0].Name)
+	assert.False(t, otherClient.Fresh())
+	// The fake records the discovery of the groups and of the resources.
+	assert.Len(t, delegate.Actions(), 2)
+	assert.Empty(t, otherDelegate.Actions())
+
+	// Once invalidated, the resources are discovered again.
+	otherClient.Invalidate()
+	_, _, err = otherClient.ServerGroupsAndResources()
+	require.NoError(t, err)
+	assert.True(t, otherClient.Fresh())
+	assert.Len(t, otherDelegate.Actions(), 2)
+}
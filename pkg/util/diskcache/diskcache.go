@@ -0,0 +1,137 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diskcache caches on disk, for a short time, the data which the CLI
+// discovers from the K8s apiserver, e.g. the API resources or the CRDs, so that
+// commands run back-to-back, e.g. in scripts, do not repeat the same discovery
+// requests.
+package diskcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Cache stores JSON values in the files of a directory, each value expiring
+// TTL after it was stored. A nil Cache, or a Cache with a zero TTL, caches
+// nothing.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// unsafeChars are the characters replaced in the names of the cache
+// directories and files.
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.-]`)
+
+// New returns a Cache storing its values in dir.
+func New(dir string, ttl time.Duration) *Cache {
+	return &Cache{
+		dir: dir,
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// ForHost returns a Cache storing the values of the apiserver at host in a
+// subdirectory of root, so that the values of different clusters are not
+// mixed.
+func ForHost(root string, host string, ttl time.Duration) *Cache {
+	return New(filepath.Join(root, unsafeChars.ReplaceAllString(host, "_")), ttl)
+}
+
+func (c *Cache) enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, unsafeChars.ReplaceAllString(key, "_")+".json")
+}
+
+// Get decodes the value stored for key into value, and returns false if there
+// is no such value or if it has expired.
+func (c *Cache) Get(key string, value interface{}) bool {
+	if !c.enabled() {
+		return false
+	}
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || c.now().Sub(info.ModTime()) >= c.ttl {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, value) == nil
+}
+
+// Set stores the value for key. The value is written to a temporary file
+// first, so that concurrent commands never read a partial value.
+func (c *Cache) Set(key string, value interface{}) error {
+	if !c.enabled() {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error when encoding the cached value of %s: %v", key, err)
+	}
+	if err := os.MkdirAll(c.dir, 0750); err != nil {
+		return fmt.Errorf("error when creating the cache directory %s: %v", c.dir, err)
+	}
+	file, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("error when caching %s: %v", key, err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("error when caching %s: %v", key, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error when caching %s: %v", key, err)
+	}
+	if err := os.Rename(file.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("error when caching %s: %v", key, err)
+	}
+	return nil
+}
+
+// Delete removes the value stored for key.
+func (c *Cache) Delete(key string) error {
+	if c == nil {
+		return nil
+	}
+	if err := os.Remove(c.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error when removing the cached value of %s: %v", key, err)
+	}
+	return nil
+}
+
+// Invalidate removes all the values of the cache, e.g. after installing or
+// uninstalling CRDs.
+func (c *Cache) Invalidate() error {
+	if c == nil {
+		return nil
+	}
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("error when removing the cache directory %s: %v", c.dir, err)
+	}
+	return nil
+}
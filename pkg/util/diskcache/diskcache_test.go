@@ -0,0 +1,65 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskcache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	root := t.TempDir()
+	cache := ForHost(root, "https://10.0.0.1:6443", time.Minute)
+
+	var served []string
+	assert.False(t, cache.Get("crd-networkpolicyrecommendations.crd.theia.antrea.io", &served))
+	require.NoError(t, cache.Set("crd-networkpolicyrecommendations.crd.theia.antrea.io", []string{"v1alpha1"}))
+	assert.FileExists(t, filepath.Join(root, "https___10.0.0.1_6443", "crd-networkpolicyrecommendations.crd.theia.antrea.io.json"))
+	assert.True(t, cache.Get("crd-networkpolicyrecommendations.crd.theia.antrea.io", &served))
+	assert.Equal(t, []string{"v1alpha1"}, served)
+
+	// The values of other clusters are not shared.
+	var other []string
+	assert.False(t, ForHost(root, "https://10.0.0.2:6443", time.Minute).Get("crd-networkpolicyrecommendations.crd.theia.antrea.io", &other))
+
+	cache.now = func() time.Time {
+		return time.Now().Add(time.Minute)
+	}
+	assert.False(t, cache.Get("crd-networkpolicyrecommendations.crd.theia.antrea.io", &served))
+
+	require.NoError(t, cache.Set("discovery", "data"))
+	require.NoError(t, cache.Invalidate())
+	_, err := os.Stat(filepath.Join(root, "https___10.0.0.1_6443"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDisabledCache(t *testing.T) {
+	root := t.TempDir()
+	var value string
+	for _, cache := range []*Cache{nil, New(filepath.Join(root, "cache"), 0)} {
+		require.NoError(t, cache.Set("discovery", "data"))
+		assert.False(t, cache.Get("discovery", &value))
+		assert.NoError(t, cache.Delete("discovery"))
+		assert.NoError(t, cache.Invalidate())
+	}
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,290 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package executor runs policy recommendation jobs on the available backends,
+// such as Spark applications managed by the Spark Operator or Kubernetes Jobs.
+// Backends register a Factory, so that the CLI and theia-manager can run each
+// job on the backend it requests.
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/util/faultinjection"
+)
+
+// DefaultBackend is the backend used when a job does not request one.
+const DefaultBackend = SparkOperatorBackend
+
+const (
+	DefaultSparkImage           = "projects.registry.vmware.com/antrea/theia-policy-recommendation:latest"
+	DefaultSparkImagePullPolicy = "IfNotPresent"
+	DefaultSparkVersion         = "3.1.1"
+	// SparkAppFile is the policy recommendation job in the Spark image.
+	SparkAppFile = "local:///opt/spark/work-dir/policy_recommendation_job.py"
+	// SparkServiceAccount is the ServiceAccount of the Spark driver.
+	SparkServiceAccount = "policy-recommendation-spark"
+)
+
+const (
+	// CancelledState is the state of the jobs stopped with Stop, whatever
+	// the backend.
+	CancelledState = "CANCELLED"
+	// CancelledAnnotation is the annotation set on the backend objects of
+	// the jobs stopped with Stop.
+	CancelledAnnotation = "theia.antrea.io/cancelled"
+	// ParametersAnnotation is the annotation set on the backend objects of
+	// the jobs with the JSON-encoded parameters of their request, so that
+	// they can be retried.
+	ParametersAnnotation = "theia.antrea.io/parameters"
+)
+
+// Job is a policy recommendation job as reported by the backend running it.
+type Job struct {
+	ID      string
+	Backend string
+	// State is one of the states of the Spark applications, e.g. NEW,
+	// RUNNING, COMPLETED or FAILED, whatever the backend.
+	State          string
+	ErrorMessage   string
+	CreationTime   time.Time
+	CompletionTime time.Time
+	// Parameters are the parameters of the request of the job, nil if they
+	// were not recorded.
+	Parameters map[string]string
+}
+
+// Resources are the resources of a policy recommendation job. Backends which
+// run the job in a single Pod use the driver resources.
+type Resources struct {
+	ExecutorInstances    int32             `json:"executorInstances,omitempty"`
+	DriverCoreRequest    string            `json:"driverCoreRequest,omitempty"`
+	DriverMemory         string            `json:"driverMemory,omitempty"`
+	ExecutorCoreRequest  string            `json:"executorCoreRequest,omitempty"`
+	ExecutorMemory       string            `json:"executorMemory,omitempty"`
+	ExecutorNodeSelector map[string]string `json:"executorNodeSelector,omitempty"`
+	ExecutorTolerations  []v1.Toleration   `json:"executorTolerations,omitempty"`
+	SparkConf            map[string]string `json:"sparkConf,omitempty"`
+	// Image defaults to DefaultSparkImage when empty.
+	Image string `json:"image,omitempty"`
+	// ImagePullPolicy defaults to DefaultSparkImagePullPolicy when empty.
+	ImagePullPolicy string `json:"imagePullPolicy,omitempty"`
+	// SparkVersion is the version of Spark in the image. It defaults to
+	// DefaultSparkVersion when empty.
+	SparkVersion string `json:"sparkVersion,omitempty"`
+	// NodeArchitecture, if not empty, is the architecture of the Nodes the
+	// job is scheduled on.
+	NodeArchitecture string `json:"nodeArchitecture,omitempty"`
+}
+
+// Request is a request to run a policy recommendation job.
+type Request struct {
+	ID string
+	// Args are the arguments of the policy recommendation job.
+	Args      []string
+	Resources Resources
+	// ArtifactsSecret, if not empty, is the name of the Secret with the
+	// credentials used by the job to upload its artifacts to object storage.
+	ArtifactsSecret string
+	// Parameters, if not empty, are the effective values of the flags of
+	// the CLI command which submitted the job. They are recorded in the
+	// ParametersAnnotation, so that the job can be retried with the same
+	// flags.
+	Parameters map[string]string
+}
+
+// jobAnnotations returns the annotations of the backend object running the
+// requested job.
+func jobAnnotations(request *Request) map[string]string {
+	if len(request.Parameters) == 0 {
+		return nil
+	}
+	// Marshalling a map of strings cannot fail.
+	data, _ := json.Marshal(request.Parameters)
+	return map[string]string{ParametersAnnotation: string(data)}
+}
+
+// jobParameters returns the parameters recorded in the annotations of the
+// backend object of a job, or nil if they are missing or invalid.
+func jobParameters(annotations map[string]string) map[string]string {
+	data, ok := annotations[ParametersAnnotation]
+	if !ok {
+		return nil
+	}
+	var parameters map[string]string
+	if err := json.Unmarshal([]byte(data), &parameters); err != nil {
+		return nil
+	}
+	return parameters
+}
+
+// artifactsSecretKeys are the environment variables set from the keys of the
+// artifacts Secret, as expected by the AWS SDK of the job.
+var artifactsSecretKeys = []struct {
+	envName string
+	key     string
+}{
+	{"AWS_ACCESS_KEY_ID", "access-key-id"},
+	{"AWS_SECRET_ACCESS_KEY", "secret-access-key"},
+}
+
+// Executor runs policy recommendation jobs on a backend.
+type Executor interface {
+	Backend() string
+	// PreCheck checks that the components required by the backend are
+	// running.
+	PreCheck(ctx context.Context) error
+	Submit(ctx context.Context, request *Request) error
+	// Permissions returns the permissions which the user of the CLI needs
+	// to submit and follow jobs with the backend.
+	Permissions() []authorizationv1.ResourceAttributes
+	// Render returns the object which Submit creates for the request, so
+	// that it can be reviewed without submitting the job.
+	Render(request *Request) (runtime.Object, error)
+	// Get returns nil, and no error, if the backend has no job with this ID.
+	Get(ctx context.Context, id string) (*Job, error)
+	List(ctx context.Context) ([]Job, error)
+	// Delete does not return an error if the backend has no job with this
+	// ID.
+	Delete(ctx context.Context, id string) error
+	// Stop terminates the Pods of a job gracefully, and keeps the job so
+	// that it is reported in the CANCELLED state. It returns a NotFound
+	// error if the backend has no job with this ID.
+	Stop(ctx context.Context, id string) error
+}
+
+// Options are the options shared by the executors of all backends.
+type Options struct {
+	Clientset kubernetes.Interface
+	// Namespace is the Namespace of the jobs, in which Theia is installed.
+	Namespace string
+	// FaultInjector injects failures when testing, it is nil otherwise.
+	FaultInjector *faultinjection.Injector
+}
+
+// Factory returns the Executor of a backend.
+type Factory func(options Options) Executor
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = map[string]Factory{}
+)
+
+// Register makes a backend available. It panics if the backend is already
+// registered.
+func Register(backend string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	if _, ok := factories[backend]; ok {
+		panic(fmt.Sprintf("executor backend %s is already registered", backend))
+	}
+	factories[backend] = factory
+}
+
+// Backends returns the sorted names of the registered backends.
+func Backends() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+	var backends []string
+	for backend := range factories {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+// New returns the Executor of the given backend.
+func New(backend string, options Options) (Executor, error) {
+	factoriesMutex.RLock()
+	factory, ok := factories[backend]
+	factoriesMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("backend should be one of: %s", strings.Join(Backends(), ", "))
+	}
+	return factory(options), nil
+}
+
+// All returns the Executors of all the registered backends, sorted by backend
+// name.
+func All(options Options) []Executor {
+	var executors []Executor
+	for _, backend := range Backends() {
+		executor, _ := New(backend, options)
+		executors = append(executors, executor)
+	}
+	return executors
+}
+
+// GetJob returns the job with the given ID, whatever the backend running it.
+func GetJob(ctx context.Context, executors []Executor, id string) (*Job, error) {
+	for _, executor := range executors {
+		job, err := executor.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find the policy recommendation job with ID %s", id)
+}
+
+// ListJobs returns the jobs of all the given executors, sorted by creation
+// time.
+func ListJobs(ctx context.Context, executors []Executor) ([]Job, error) {
+	var jobs []Job
+	for _, executor := range executors {
+		backendJobs, err := executor.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, backendJobs...)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreationTime.Before(jobs[j].CreationTime)
+	})
+	return jobs, nil
+}
+
+func image(resources *Resources) string {
+	if resources.Image == "" {
+		return DefaultSparkImage
+	}
+	return resources.Image
+}
+
+func imagePullPolicy(resources *Resources) string {
+	if resources.ImagePullPolicy == "" {
+		return DefaultSparkImagePullPolicy
+	}
+	return resources.ImagePullPolicy
+}
+
+func sparkVersion(resources *Resources) string {
+	if resources.SparkVersion == "" {
+		return DefaultSparkVersion
+	}
+	return resources.SparkVersion
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type fakeExecutor struct {
+	backend string
+	jobs    []Job
+	err     error
+}
+
+func (e *fakeExecutor) Backend() string {
+	return e.backend
+}
+
+func (e *fakeExecutor) PreCheck(ctx context.Context) error {
+	return nil
+}
+
+func (e *fakeExecutor) Submit(ctx context.Context, request *Request) error {
+	return nil
+}
+
+func (e *fakeExecutor) Permissions() []authorizationv1.ResourceAttributes {
+	return nil
+}
+
+func (e *fakeExecutor) Render(request *Request) (runtime.Object, error) {
+	return nil, nil
+}
+
+func (e *fakeExecutor) Get(ctx context.Context, id string) (*Job, error) {
+	for i := range e.jobs {
+		if e.jobs[i].ID == id {
+			return &e.jobs[i], nil
+		}
+	}
+	return nil, e.err
+}
+
+func (e *fakeExecutor) List(ctx context.Context) ([]Job, error) {
+	return e.jobs, e.err
+}
+
+func (e *fakeExecutor) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func (e *fakeExecutor) Stop(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	assert.Equal(t, []string{K8sJobBackend, SparkOperatorBackend}, Backends())
+	executor, err := New(K8sJobBackend, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, K8sJobBackend, executor.Backend())
+	_, err = New("flink", Options{})
+	assert.EqualError(t, err, "backend should be one of: k8s-job, spark")
+	assert.Panics(t, func() {
+		Register(SparkOperatorBackend, func(options Options) Executor { return nil })
+	})
+}
+
+func TestGetAndListJobs(t *testing.T) {
+	created := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	executors := []Executor{
+		&fakeExecutor{backend: "a", jobs: []Job{{ID: "1", Backend: "a", CreationTime: created.Add(time.Hour)}}},
+		&fakeExecutor{backend: "b", jobs: []Job{{ID: "2", Backend: "b", CreationTime: created}}},
+	}
+	ctx := context.Background()
+	job, err := GetJob(ctx, executors, "2")
+	require.NoError(t, err)
+	assert.Equal(t, "b", job.Backend)
+	_, err = GetJob(ctx, executors, "3")
+	assert.EqualError(t, err, "could not find the policy recommendation job with ID 3")
+
+	jobs, err := ListJobs(ctx, executors)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "2", jobs[0].ID)
+	assert.Equal(t, "1", jobs[1].ID)
+
+	executors = append(executors, &fakeExecutor{backend: "c", err: fmt.Errorf("connection refused")})
+	_, err = GetJob(ctx, executors, "3")
+	assert.EqualError(t, err, "connection refused")
+	_, err = ListJobs(ctx, executors)
+	assert.EqualError(t, err, "connection refused")
+}
@@ -0,0 +1,269 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// K8sJobBackend runs jobs as Kubernetes Jobs, without the Spark Operator.
+	K8sJobBackend = "k8s-job"
+	// RecommendationIDLabel is the label of the Kubernetes Jobs running
+	// policy recommendation jobs, set to the ID of the recommendation job.
+	RecommendationIDLabel = "theia.antrea.io/recommendation-id"
+)
+
+func init() {
+	Register(K8sJobBackend, func(options Options) Executor {
+		return &K8sJobExecutor{clientset: options.Clientset, namespace: options.Namespace}
+	})
+}
+
+// K8sJobExecutor runs policy recommendation jobs as Kubernetes Jobs, in which
+// Spark runs in local mode in a single Pod. It does not depend on the Spark
+// Operator and is suited to small recommendation jobs.
+type K8sJobExecutor struct {
+	clientset kubernetes.Interface
+	namespace string
+}
+
+func (e *K8sJobExecutor) Backend() string {
+	return K8sJobBackend
+}
+
+// PreCheck does not check anything, as Kubernetes Jobs do not depend on other
+// components.
+func (e *K8sJobExecutor) PreCheck(ctx context.Context) error {
+	return nil
+}
+
+func (e *K8sJobExecutor) Submit(ctx context.Context, request *Request) error {
+	job, err := NewK8sJob(e.namespace, request)
+	if err != nil {
+		return err
+	}
+	_, err = e.clientset.BatchV1().Jobs(e.namespace).Create(ctx, job, metav1.CreateOptions{})
+	return err
+}
+
+func (e *K8sJobExecutor) Permissions() []authorizationv1.ResourceAttributes {
+	return []authorizationv1.ResourceAttributes{
+		{Namespace: e.namespace, Verb: "create", Group: batchv1.GroupName, Resource: "jobs"},
+		{Namespace: e.namespace, Verb: "get", Group: batchv1.GroupName, Resource: "jobs"},
+	}
+}
+
+func (e *K8sJobExecutor) Render(request *Request) (runtime.Object, error) {
+	job, err := NewK8sJob(e.namespace, request)
+	if err != nil {
+		return nil, err
+	}
+	// The TypeMeta is only set by the client when the Job is created.
+	job.TypeMeta = metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "Job"}
+	return job, nil
+}
+
+func (e *K8sJobExecutor) Get(ctx context.Context, id string) (*Job, error) {
+	job, err := e.clientset.BatchV1().Jobs(e.namespace).Get(ctx, "pr-"+id, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, ok := job.Labels[RecommendationIDLabel]; !ok {
+		return nil, nil
+	}
+	recoJob := k8sJobToJob(job)
+	return &recoJob, nil
+}
+
+func (e *K8sJobExecutor) List(ctx context.Context) ([]Job, error) {
+	jobList, err := e.clientset.BatchV1().Jobs(e.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: RecommendationIDLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	for i := range jobList.Items {
+		jobs = append(jobs, k8sJobToJob(&jobList.Items[i]))
+	}
+	return jobs, nil
+}
+
+func (e *K8sJobExecutor) Delete(ctx context.Context, id string) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	err := e.clientset.BatchV1().Jobs(e.namespace).Delete(ctx, "pr-"+id, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Stop suspends the Kubernetes Job, which deletes its active Pods, and
+// annotates it as cancelled.
+func (e *K8sJobExecutor) Stop(ctx context.Context, id string) error {
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:"true"}},"spec":{"suspend":true}}`, CancelledAnnotation)
+	_, err := e.clientset.BatchV1().Jobs(e.namespace).Patch(ctx, "pr-"+id, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// k8sJobToJob maps the status of a Kubernetes Job to the states of the Spark
+// applications, so that both backends report the same states.
+func k8sJobToJob(job *batchv1.Job) Job {
+	recoJob := Job{
+		ID:           job.Labels[RecommendationIDLabel],
+		Backend:      K8sJobBackend,
+		State:        "NEW",
+		CreationTime: job.CreationTimestamp.Time,
+		Parameters:   jobParameters(job.Annotations),
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			recoJob.State = "COMPLETED"
+			recoJob.CompletionTime = condition.LastTransitionTime.Time
+			return recoJob
+		case batchv1.JobFailed:
+			recoJob.State = "FAILED"
+			recoJob.ErrorMessage = condition.Message
+			recoJob.CompletionTime = condition.LastTransitionTime.Time
+			return recoJob
+		}
+	}
+	if job.Annotations[CancelledAnnotation] == "true" {
+		recoJob.State = CancelledState
+	} else if job.Status.Active > 0 {
+		recoJob.State = "RUNNING"
+	}
+	return recoJob
+}
+
+// NewK8sJob returns the Kubernetes Job running the requested policy
+// recommendation job in the given Namespace. The Pod gets the resources of the
+// Spark driver, as the job runs in the driver in local mode.
+func NewK8sJob(namespace string, request *Request) (*batchv1.Job, error) {
+	resources := &request.Resources
+	cpu, err := resource.ParseQuantity(resources.DriverCoreRequest)
+	if err != nil {
+		return nil, fmt.Errorf("driver core request is invalid: %v", err)
+	}
+	memory, err := resource.ParseQuantity(resources.DriverMemory)
+	if err != nil {
+		return nil, fmt.Errorf("driver memory is invalid: %v", err)
+	}
+	var nodeSelector map[string]string
+	if resources.NodeArchitecture != "" {
+		nodeSelector = map[string]string{v1.LabelArchStable: resources.NodeArchitecture}
+	}
+	args := []string{
+		"--master", "local[*]",
+		"--driver-memory", resources.DriverMemory,
+		strings.TrimPrefix(SparkAppFile, "local://"),
+	}
+	args = append(args, request.Args...)
+	labels := map[string]string{
+		RecommendationIDLabel: request.ID,
+		"version":             sparkVersion(resources),
+	}
+	env := []v1.EnvVar{
+		{
+			Name: "CH_USERNAME",
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "clickhouse-secret"},
+					Key:                  "username",
+				},
+			},
+		},
+		{
+			Name: "CH_PASSWORD",
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "clickhouse-secret"},
+					Key:                  "password",
+				},
+			},
+		},
+	}
+	if request.ArtifactsSecret != "" {
+		for _, secretKey := range artifactsSecretKeys {
+			env = append(env, v1.EnvVar{
+				Name: secretKey.envName,
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: request.ArtifactsSecret},
+						Key:                  secretKey.key,
+					},
+				},
+			})
+		}
+	}
+	backoffLimit := int32(0)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pr-" + request.ID,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: jobAnnotations(request),
+		},
+		Spec: batchv1.JobSpec{
+			// like Spark applications, failed jobs are not retried
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					RestartPolicy: v1.RestartPolicyNever,
+					NodeSelector:  nodeSelector,
+					Containers: []v1.Container{{
+						Name:            "policy-recommendation",
+						Image:           image(resources),
+						ImagePullPolicy: v1.PullPolicy(imagePullPolicy(resources)),
+						Command:         []string{"/opt/spark/bin/spark-submit"},
+						Args:            args,
+						Env:             env,
+						Resources: v1.ResourceRequirements{
+							Requests: v1.ResourceList{
+								v1.ResourceCPU:    cpu,
+								v1.ResourceMemory: memory,
+							},
+						},
+					}},
+				},
+			},
+		},
+	}, nil
+}
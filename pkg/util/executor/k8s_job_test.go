@@ -0,0 +1,236 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestK8sJobToJob(t *testing.T) {
+	created := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	finished := created.Add(10 * time.Minute)
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		status      batchv1.JobStatus
+		expectedJob Job
+	}{
+		{
+			name:   "new job",
+			status: batchv1.JobStatus{},
+			expectedJob: Job{
+				State: "NEW",
+			},
+		},
+		{
+			name:   "running job",
+			status: batchv1.JobStatus{Active: 1},
+			expectedJob: Job{
+				State: "RUNNING",
+			},
+		},
+		{
+			name:        "cancelled job",
+			annotations: map[string]string{CancelledAnnotation: "true"},
+			status:      batchv1.JobStatus{Active: 1},
+			expectedJob: Job{
+				State: "CANCELLED",
+			},
+		},
+		{
+			name: "completed job",
+			status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobComplete, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(finished)},
+				},
+			},
+			expectedJob: Job{
+				State:          "COMPLETED",
+				CompletionTime: finished,
+			},
+		},
+		{
+			name: "failed job",
+			status: batchv1.JobStatus{
+				Failed: 1,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(finished), Message: "Job has reached the specified backoff limit"},
+				},
+			},
+			expectedJob: Job{
+				State:          "FAILED",
+				ErrorMessage:   "Job has reached the specified backoff limit",
+				CompletionTime: finished,
+			},
+		},
+		{
+			name:        "job with parameters",
+			annotations: map[string]string{ParametersAnnotation: `{"type":"initial"}`},
+			status:      batchv1.JobStatus{Active: 1},
+			expectedJob: Job{
+				State:      "RUNNING",
+				Parameters: map[string]string{"type": "initial"},
+			},
+		},
+		{
+			name:        "job with invalid parameters",
+			annotations: map[string]string{ParametersAnnotation: "type=initial"},
+			status:      batchv1.JobStatus{Active: 1},
+			expectedJob: Job{
+				State: "RUNNING",
+			},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "pr-e998433e-accb-4888-9fc8-06563f073e86",
+					Labels:            map[string]string{RecommendationIDLabel: "e998433e-accb-4888-9fc8-06563f073e86"},
+					Annotations:       tt.annotations,
+					CreationTimestamp: metav1.NewTime(created),
+				},
+				Status: tt.status,
+			}
+			tt.expectedJob.ID = "e998433e-accb-4888-9fc8-06563f073e86"
+			tt.expectedJob.Backend = K8sJobBackend
+			tt.expectedJob.CreationTime = created
+			assert.Equal(t, tt.expectedJob, k8sJobToJob(job))
+		})
+	}
+}
+
+// testNamespace is a custom Namespace in which Theia is installed.
+const testNamespace = "theia"
+
+func TestNewK8sJob(t *testing.T) {
+	request := &Request{
+		ID:   "e998433e-accb-4888-9fc8-06563f073e86",
+		Args: []string{"--type", "initial"},
+		Resources: Resources{
+			DriverCoreRequest: "500m",
+			DriverMemory:      "1G",
+			NodeArchitecture:  "amd64",
+		},
+	}
+	job, err := NewK8sJob(testNamespace, request)
+	require.NoError(t, err)
+	assert.Equal(t, "pr-e998433e-accb-4888-9fc8-06563f073e86", job.Name)
+	assert.Equal(t, testNamespace, job.Namespace)
+	assert.Equal(t, int32(0), *job.Spec.BackoffLimit)
+	podSpec := job.Spec.Template.Spec
+	assert.Equal(t, v1.RestartPolicyNever, podSpec.RestartPolicy)
+	assert.Equal(t, map[string]string{v1.LabelArchStable: "amd64"}, podSpec.NodeSelector)
+	require.Len(t, podSpec.Containers, 1)
+	container := podSpec.Containers[0]
+	assert.Equal(t, DefaultSparkImage, container.Image)
+	assert.Equal(t, v1.PullPolicy(DefaultSparkImagePullPolicy), container.ImagePullPolicy)
+	assert.Equal(t, DefaultSparkVersion, job.Spec.Template.Labels["version"])
+	assert.Equal(t, []string{"--master", "local[*]", "--driver-memory", "1G", "/opt/spark/work-dir/policy_recommendation_job.py", "--type", "initial"}, container.Args)
+	assert.Equal(t, resource.MustParse("500m"), container.Resources.Requests[v1.ResourceCPU])
+	assert.Equal(t, resource.MustParse("1G"), container.Resources.Requests[v1.ResourceMemory])
+	require.Len(t, container.Env, 2)
+	assert.Equal(t, "clickhouse-secret", container.Env[0].ValueFrom.SecretKeyRef.Name)
+
+	request.ArtifactsSecret = "artifacts-secret"
+	job, err = NewK8sJob(testNamespace, request)
+	require.NoError(t, err)
+	env := job.Spec.Template.Spec.Containers[0].Env
+	require.Len(t, env, 4)
+	assert.Equal(t, "AWS_ACCESS_KEY_ID", env[2].Name)
+	assert.Equal(t, v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "artifacts-secret"}, Key: "access-key-id"}, *env[2].ValueFrom.SecretKeyRef)
+	assert.Equal(t, "AWS_SECRET_ACCESS_KEY", env[3].Name)
+
+	request.Resources.Image = "registry.example.com/theia/theia-policy-recommendation:v0.5.0"
+	request.Resources.ImagePullPolicy = "Always"
+	request.Resources.SparkVersion = "3.3.1"
+	job, err = NewK8sJob(testNamespace, request)
+	require.NoError(t, err)
+	container = job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "registry.example.com/theia/theia-policy-recommendation:v0.5.0", container.Image)
+	assert.Equal(t, v1.PullAlways, container.ImagePullPolicy)
+	assert.Equal(t, "3.3.1", job.Spec.Template.Labels["version"])
+
+	request.Parameters = map[string]string{"type": "initial", "last": ""}
+	job, err = NewK8sJob(testNamespace, request)
+	require.NoError(t, err)
+	assert.Equal(t, request.Parameters, k8sJobToJob(job).Parameters)
+
+	request.Resources.DriverMemory = "1 G"
+	_, err = NewK8sJob(testNamespace, request)
+	assert.Error(t, err)
+}
+
+func TestK8sJobExecutor(t *testing.T) {
+	id := "e998433e-accb-4888-9fc8-06563f073e86"
+	clientset := fake.NewSimpleClientset(
+		// Jobs without the recommendation ID label are ignored
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "pr-other", Namespace: testNamespace}},
+	)
+	executor, err := New(K8sJobBackend, Options{Clientset: clientset, Namespace: testNamespace})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	job, err := executor.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	request := &Request{
+		ID:        id,
+		Args:      []string{"--id", id},
+		Resources: Resources{DriverCoreRequest: "200m", DriverMemory: "512M"},
+	}
+	require.NoError(t, executor.Submit(ctx, request))
+	job, err = executor.Get(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, id, job.ID)
+	assert.Equal(t, K8sJobBackend, job.Backend)
+	assert.Equal(t, "NEW", job.State)
+
+	jobs, err := executor.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, id, jobs[0].ID)
+
+	require.NoError(t, executor.Stop(ctx, id))
+	job, err = executor.Get(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, "CANCELLED", job.State)
+	k8sJob, err := clientset.BatchV1().Jobs(testNamespace).Get(ctx, "pr-"+id, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, *k8sJob.Spec.Suspend)
+
+	require.NoError(t, executor.Delete(ctx, id))
+	job, err = executor.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	// deleting a missing job is not an error
+	assert.NoError(t, executor.Delete(ctx, id))
+	// stopping a missing job is
+	assert.True(t, errors.IsNotFound(executor.Stop(ctx, id)))
+}
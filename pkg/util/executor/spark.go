@@ -0,0 +1,309 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/util/faultinjection"
+	sparkv1 "antrea.io/theia/third_party/sparkoperator/v1beta2"
+)
+
+const SparkOperatorBackend = "spark"
+
+func init() {
+	Register(SparkOperatorBackend, func(options Options) Executor {
+		return &SparkOperatorExecutor{clientset: options.Clientset, namespace: options.Namespace, faultInjector: options.FaultInjector}
+	})
+}
+
+// SparkOperatorExecutor runs jobs as Spark applications managed by the Spark
+// Operator.
+type SparkOperatorExecutor struct {
+	clientset     kubernetes.Interface
+	namespace     string
+	faultInjector *faultinjection.Injector
+}
+
+func (e *SparkOperatorExecutor) Backend() string {
+	return SparkOperatorBackend
+}
+
+func (e *SparkOperatorExecutor) PreCheck(ctx context.Context) error {
+	return CheckSparkOperatorPod(ctx, e.clientset, e.namespace)
+}
+
+func (e *SparkOperatorExecutor) Submit(ctx context.Context, request *Request) error {
+	return CreateSparkApplication(ctx, e.clientset, NewSparkApplication(e.namespace, request), e.faultInjector)
+}
+
+func (e *SparkOperatorExecutor) Permissions() []authorizationv1.ResourceAttributes {
+	return []authorizationv1.ResourceAttributes{
+		// PreCheck lists the Pods of the Spark Operator.
+		{Namespace: e.namespace, Verb: "list", Resource: "pods"},
+		{Namespace: e.namespace, Verb: "create", Group: "sparkoperator.k8s.io", Resource: "sparkapplications"},
+		{Namespace: e.namespace, Verb: "get", Group: "sparkoperator.k8s.io", Resource: "sparkapplications"},
+	}
+}
+
+func (e *SparkOperatorExecutor) Render(request *Request) (runtime.Object, error) {
+	return NewSparkApplication(e.namespace, request), nil
+}
+
+func (e *SparkOperatorExecutor) Get(ctx context.Context, id string) (*Job, error) {
+	sparkApp, err := GetSparkApplication(ctx, e.clientset, e.namespace, id)
+	if err != nil {
+		// NotFound is also returned when the SparkApplication CRD is not
+		// installed.
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	job := sparkApplicationToJob(&sparkApp)
+	return &job, nil
+}
+
+func (e *SparkOperatorExecutor) List(ctx context.Context) ([]Job, error) {
+	sparkApplicationList := &sparkv1.SparkApplicationList{}
+	err := e.clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
+		Namespace(e.namespace).
+		Resource("sparkapplications").
+		Do(ctx).Into(sparkApplicationList)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var jobs []Job
+	for i := range sparkApplicationList.Items {
+		jobs = append(jobs, sparkApplicationToJob(&sparkApplicationList.Items[i]))
+	}
+	return jobs, nil
+}
+
+func (e *SparkOperatorExecutor) Delete(ctx context.Context, id string) error {
+	err := e.clientset.CoreV1().RESTClient().Delete().
+		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
+		Namespace(e.namespace).
+		Resource("sparkapplications").
+		Name("pr-" + id).
+		Do(ctx).
+		Error()
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Stop deletes the driver Pod of the Spark application, after annotating the
+// application as cancelled. The Spark Operator then fails the application, and
+// the executors are deleted with the driver.
+func (e *SparkOperatorExecutor) Stop(ctx context.Context, id string) error {
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:"true"}}}`, CancelledAnnotation)
+	err := e.clientset.CoreV1().RESTClient().Patch(types.MergePatchType).
+		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
+		Namespace(e.namespace).
+		Resource("sparkapplications").
+		Name("pr-" + id).
+		Body([]byte(patch)).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return err
+	}
+	err = e.clientset.CoreV1().Pods(e.namespace).Delete(ctx, "pr-"+id+"-driver", metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func sparkApplicationToJob(sparkApp *sparkv1.SparkApplication) Job {
+	job := Job{
+		ID:             strings.TrimPrefix(sparkApp.Name, "pr-"),
+		Backend:        SparkOperatorBackend,
+		State:          strings.TrimSpace(string(sparkApp.Status.AppState.State)),
+		ErrorMessage:   strings.TrimSpace(sparkApp.Status.AppState.ErrorMessage),
+		CreationTime:   sparkApp.CreationTimestamp.Time,
+		CompletionTime: sparkApp.Status.TerminationTime.Time,
+		Parameters:     jobParameters(sparkApp.Annotations),
+	}
+	// The application fails once its driver is deleted, unless it completed
+	// in the meantime.
+	if sparkApp.Annotations[CancelledAnnotation] == "true" && job.State != "COMPLETED" {
+		job.State = CancelledState
+		job.ErrorMessage = ""
+	}
+	return job
+}
+
+// CheckSparkOperatorPod checks that the Spark Operator is running in the given
+// Namespace.
+func CheckSparkOperatorPod(ctx context.Context, clientset kubernetes.Interface, namespace string) error {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/name=spark-operator",
+	})
+	if err != nil {
+		return fmt.Errorf("error %v when finding the policy-recommendation-spark-operator Pod, please check the deployment of the Spark Operator", err)
+	}
+	if len(pods.Items) < 1 {
+		return fmt.Errorf("can't find the policy-recommendation-spark-operator Pod, please check the deployment of the Spark Operator")
+	}
+	hasRunningPod := false
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == "Running" {
+			hasRunningPod = true
+			break
+		}
+	}
+	if !hasRunningPod {
+		return fmt.Errorf("can't find a running Spark Operator Pod, please check the deployment of Spark")
+	}
+	return nil
+}
+
+// GetSparkApplication returns the Spark application running the job with the
+// given ID in the given Namespace.
+func GetSparkApplication(ctx context.Context, clientset kubernetes.Interface, namespace string, id string) (sparkApp sparkv1.SparkApplication, err error) {
+	err = clientset.CoreV1().RESTClient().
+		Get().
+		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
+		Namespace(namespace).
+		Resource("sparkapplications").
+		Name("pr-" + id).
+		Do(ctx).
+		Into(&sparkApp)
+	return sparkApp, err
+}
+
+// CreateSparkApplication submits the Spark application to the Spark Operator.
+func CreateSparkApplication(ctx context.Context, clientset kubernetes.Interface, sparkApplication *sparkv1.SparkApplication, faultInjector *faultinjection.Injector) error {
+	if err := faultInjector.SparkSubmission(); err != nil {
+		return err
+	}
+	response := &sparkv1.SparkApplication{}
+	return clientset.CoreV1().RESTClient().
+		Post().
+		AbsPath("/apis/sparkoperator.k8s.io/v1beta2").
+		Namespace(sparkApplication.Namespace).
+		Resource("sparkapplications").
+		Body(sparkApplication).
+		Do(ctx).
+		Into(response)
+}
+
+// NewSparkApplication returns the Spark application running the requested
+// policy recommendation job in the given Namespace.
+func NewSparkApplication(namespace string, request *Request) *sparkv1.SparkApplication {
+	resources := &request.Resources
+	sparkImage := image(resources)
+	version := sparkVersion(resources)
+	var driverNodeSelector map[string]string
+	executorNodeSelector := resources.ExecutorNodeSelector
+	if resources.NodeArchitecture != "" {
+		driverNodeSelector = map[string]string{v1.LabelArchStable: resources.NodeArchitecture}
+		executorNodeSelector = map[string]string{v1.LabelArchStable: resources.NodeArchitecture}
+		for k, v := range resources.ExecutorNodeSelector {
+			executorNodeSelector[k] = v
+		}
+	}
+	envSecretKeyRefs := map[string]sparkv1.NameKey{
+		"CH_USERNAME": {
+			Name: "clickhouse-secret",
+			Key:  "username",
+		},
+		"CH_PASSWORD": {
+			Name: "clickhouse-secret",
+			Key:  "password",
+		},
+	}
+	// artifacts are uploaded by the driver only
+	driverEnvSecretKeyRefs := envSecretKeyRefs
+	if request.ArtifactsSecret != "" {
+		driverEnvSecretKeyRefs = map[string]sparkv1.NameKey{}
+		for k, v := range envSecretKeyRefs {
+			driverEnvSecretKeyRefs[k] = v
+		}
+		for _, secretKey := range artifactsSecretKeys {
+			driverEnvSecretKeyRefs[secretKey.envName] = sparkv1.NameKey{
+				Name: request.ArtifactsSecret,
+				Key:  secretKey.key,
+			}
+		}
+	}
+	return &sparkv1.SparkApplication{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "sparkoperator.k8s.io/v1beta2",
+			Kind:       "SparkApplication",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pr-" + request.ID,
+			Namespace:   namespace,
+			Annotations: jobAnnotations(request),
+		},
+		Spec: sparkv1.SparkApplicationSpec{
+			Type:                "Python",
+			SparkVersion:        version,
+			Mode:                "cluster",
+			Image:               &sparkImage,
+			ImagePullPolicy:     stringPtr(imagePullPolicy(resources)),
+			MainApplicationFile: stringPtr(SparkAppFile),
+			Arguments:           request.Args,
+			SparkConf:           resources.SparkConf,
+			Driver: sparkv1.DriverSpec{
+				CoreRequest: &resources.DriverCoreRequest,
+				SparkPodSpec: sparkv1.SparkPodSpec{
+					Memory: &resources.DriverMemory,
+					Labels: map[string]string{
+						"version": version,
+					},
+					EnvSecretKeyRefs: driverEnvSecretKeyRefs,
+					ServiceAccount:   stringPtr(SparkServiceAccount),
+					NodeSelector:     driverNodeSelector,
+				},
+			},
+			Executor: sparkv1.ExecutorSpec{
+				CoreRequest: &resources.ExecutorCoreRequest,
+				SparkPodSpec: sparkv1.SparkPodSpec{
+					Memory: &resources.ExecutorMemory,
+					Labels: map[string]string{
+						"version": version,
+					},
+					EnvSecretKeyRefs: envSecretKeyRefs,
+					NodeSelector:     executorNodeSelector,
+					Tolerations:      resources.ExecutorTolerations,
+				},
+				Instances: &resources.ExecutorInstances,
+			},
+		},
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
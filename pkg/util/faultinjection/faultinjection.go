@@ -0,0 +1,178 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package faultinjection injects artificial failures and delays, to validate
+// retry and error-path behavior in e2e and scale tests. Faults are only
+// injected in binaries built with the "faultinjection" build tag.
+package faultinjection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvVar is the environment variable used to configure fault injection
+	// when no explicit configuration is provided.
+	EnvVar = "THEIA_FAULT_INJECTION"
+
+	defaultSlowQueryDelay = 5 * time.Second
+)
+
+// ErrInjected is wrapped by all the errors returned by an Injector.
+var ErrInjected = errors.New("injected fault")
+
+// Config specifies the rate (between 0 and 1) at which each fault is
+// injected.
+type Config struct {
+	SparkSubmissionFailureRate float64
+	ClickHouseTimeoutRate      float64
+	SlowQueryRate              float64
+	SlowQueryDelay             time.Duration
+	// Seed for the random number generator, for reproducible runs. If 0,
+	// the current time is used.
+	Seed int64
+}
+
+// ParseConfig parses a comma-separated list of key=value pairs, e.g.
+// "spark-submission-failure=0.1,clickhouse-timeout=0.05,slow-query=0.2,slow-query-delay=10s".
+func ParseConfig(spec string) (*Config, error) {
+	config := &Config{SlowQueryDelay: defaultSlowQueryDelay}
+	if strings.TrimSpace(spec) == "" {
+		return config, nil
+	}
+	parseRate := func(key, value string) (float64, error) {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return 0, fmt.Errorf("%s should be a number between 0 and 1", key)
+		}
+		return rate, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid fault injection setting %q, it should be in key=value format", pair)
+		}
+		key, value := kv[0], kv[1]
+		var err error
+		switch key {
+		case "spark-submission-failure":
+			config.SparkSubmissionFailureRate, err = parseRate(key, value)
+		case "clickhouse-timeout":
+			config.ClickHouseTimeoutRate, err = parseRate(key, value)
+		case "slow-query":
+			config.SlowQueryRate, err = parseRate(key, value)
+		case "slow-query-delay":
+			config.SlowQueryDelay, err = time.ParseDuration(value)
+			if err == nil && config.SlowQueryDelay < 0 {
+				err = fmt.Errorf("slow-query-delay should not be negative")
+			}
+		case "seed":
+			config.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			err = fmt.Errorf("unknown fault injection setting %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// Injector decides randomly, according to its Config, whether a fault should
+// be injected. A nil Injector never injects faults.
+type Injector struct {
+	config Config
+	mutex  sync.Mutex
+	rand   *rand.Rand
+}
+
+// NewInjector returns nil if fault injection is not enabled in this binary.
+func NewInjector(config Config) *Injector {
+	if !Enabled {
+		return nil
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{
+		config: config,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// NewInjectorFromSpec parses spec, or the value of EnvVar if spec is empty,
+// and returns the corresponding Injector. It returns nil if fault injection is
+// not enabled in this binary or not configured.
+func NewInjectorFromSpec(spec string) (*Injector, error) {
+	if !Enabled {
+		return nil, nil
+	}
+	if spec == "" {
+		spec = os.Getenv(EnvVar)
+	}
+	if spec == "" {
+		return nil, nil
+	}
+	config, err := ParseConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewInjector(*config), nil
+}
+
+func (i *Injector) shouldInject(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.rand.Float64() < rate
+}
+
+// SparkSubmission returns an error if the submission of a Spark application
+// should fail.
+func (i *Injector) SparkSubmission() error {
+	if i == nil || !i.shouldInject(i.config.SparkSubmissionFailureRate) {
+		return nil
+	}
+	return fmt.Errorf("%w: Spark application submission failed", ErrInjected)
+}
+
+// ClickHouseQuery delays the query if it should be slow, and returns an error
+// if it should time out. The delay is interrupted if ctx is done.
+func (i *Injector) ClickHouseQuery(ctx context.Context) error {
+	if i == nil {
+		return nil
+	}
+	if i.shouldInject(i.config.SlowQueryRate) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(i.config.SlowQueryDelay):
+		}
+	}
+	if i.shouldInject(i.config.ClickHouseTimeoutRate) {
+		return fmt.Errorf("%w: ClickHouse query timed out", ErrInjected)
+	}
+	return nil
+}
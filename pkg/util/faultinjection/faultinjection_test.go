@@ -0,0 +1,115 @@
+// Copyright 2022 Antrea Authors
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faultinjection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		name           string
+		spec           string
+		expectedConfig *Config
+		expectedErr    string
+	}{
+		{
+			name:           "empty",
+			spec:           "",
+			expectedConfig: &Config{SlowQueryDelay: defaultSlowQueryDelay},
+		},
+		{
+			name: "all settings",
+			spec: "spark-submission-failure=0.1, clickhouse-timeout=0.05,slow-query=1,slow-query-delay=2s,seed=42",
+			expectedConfig: &Config{
+				SparkSubmissionFailureRate: 0.1,
+				ClickHouseTimeoutRate:      0.05,
+				SlowQueryRate:              1,
+				SlowQueryDelay:             2 * time.Second,
+				Seed:                       42,
+			},
+		},
+		{
+			name:        "rate out of range",
+			spec:        "clickhouse-timeout=1.5",
+			expectedErr: "clickhouse-timeout should be a number between 0 and 1",
+		},
+		{
+			name:        "negative delay",
+			spec:        "slow-query-delay=-1s",
+			expectedErr: "slow-query-delay should not be negative",
+		},
+		{
+			name:        "unknown setting",
+			spec:        "spark-timeout=0.1",
+			expectedErr: "unknown fault injection setting \"spark-timeout\"",
+		},
+		{
+			name:        "missing value",
+			spec:        "slow-query",
+			expectedErr: "invalid fault injection setting \"slow-query\", it should be in key=value format",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig(tt.spec)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedConfig, config)
+			}
+		})
+	}
+}
+
+func TestNilInjector(t *testing.T) {
+	var injector *Injector
+	assert.NoError(t, injector.SparkSubmission())
+	assert.NoError(t, injector.ClickHouseQuery(context.Background()))
+}
+
+func TestInjector(t *testing.T) {
+	injector := NewInjector(Config{
+		SparkSubmissionFailureRate: 1,
+		ClickHouseTimeoutRate:      1,
+		SlowQueryRate:              1,
+		SlowQueryDelay:             time.Hour,
+	})
+	if !Enabled {
+		assert.Nil(t, injector)
+		return
+	}
+	err := injector.SparkSubmission()
+	assert.True(t, errors.Is(err, ErrInjected))
+
+	// the slow query delay is interrupted by the context deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = injector.ClickHouseQuery(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	injector = NewInjector(Config{ClickHouseTimeoutRate: 1})
+	err = injector.ClickHouseQuery(context.Background())
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.NoError(t, injector.SparkSubmission())
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Field is a column of a BigQuery table.
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Mode is NULLABLE, REQUIRED or REPEATED, NULLABLE if empty.
+	Mode string `json:"mode,omitempty"`
+}
+
+// Table is a BigQuery table.
+type Table struct {
+	Name   string
+	Fields []Field
+	// PartitionField, if not empty, is the TIMESTAMP column by which the
+	// table is partitioned, by day.
+	PartitionField string
+}
+
+type datasetReference struct {
+	ProjectID string `json:"projectId"`
+	DatasetID string `json:"datasetId"`
+}
+
+type tableReference struct {
+	ProjectID string `json:"projectId"`
+	DatasetID string `json:"datasetId"`
+	TableID   string `json:"tableId"`
+}
+
+type tableSchema struct {
+	Fields []Field `json:"fields"`
+}
+
+type timePartitioning struct {
+	Type  string `json:"type"`
+	Field string `json:"field,omitempty"`
+}
+
+func (c *Client) bigQueryURL(format string, a ...interface{}) string {
+	return c.url(bigQueryEndpoint, "/bigquery/v2"+fmt.Sprintf(format, a...))
+}
+
+// CreateDataset creates a BigQuery dataset in the given location, e.g. US.
+// It returns false if the dataset already exists.
+func (c *Client) CreateDataset(ctx context.Context, project string, dataset string, location string) (bool, error) {
+	body := struct {
+		DatasetReference datasetReference `json:"datasetReference"`
+		Location         string           `json:"location"`
+	}{
+		DatasetReference: datasetReference{ProjectID: project, DatasetID: dataset},
+		Location:         location,
+	}
+	err := c.do(ctx, http.MethodPost, c.bigQueryURL("/projects/%s/datasets", url.PathEscape(project)), body, nil)
+	if IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create BigQuery dataset %s: %w", dataset, err)
+	}
+	return true, nil
+}
+
+// CreateTable creates a BigQuery table. It returns false if the table
+// already exists, in which case its schema is not updated.
+func (c *Client) CreateTable(ctx context.Context, project string, dataset string, table *Table) (bool, error) {
+	body := struct {
+		TableReference   tableReference    `json:"tableReference"`
+		Schema           tableSchema       `json:"schema"`
+		TimePartitioning *timePartitioning `json:"timePartitioning,omitempty"`
+	}{
+		TableReference: tableReference{ProjectID: project, DatasetID: dataset, TableID: table.Name},
+		Schema:         tableSchema{Fields: table.Fields},
+	}
+	if table.PartitionField != "" {
+		body.TimePartitioning = &timePartitioning{Type: "DAY", Field: table.PartitionField}
+	}
+	err := c.do(ctx, http.MethodPost, c.bigQueryURL("/projects/%s/datasets/%s/tables", url.PathEscape(project), url.PathEscape(dataset)), body, nil)
+	if IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create BigQuery table %s.%s: %w", dataset, table.Name, err)
+	}
+	return true, nil
+}
+
+type queryParameter struct {
+	ParameterType  queryParameterType  `json:"parameterType"`
+	ParameterValue queryParameterValue `json:"parameterValue"`
+}
+
+type queryParameterType struct {
+	Type string `json:"type"`
+}
+
+type queryParameterValue struct {
+	Value string `json:"value"`
+}
+
+type queryRequest struct {
+	Query           string            `json:"query"`
+	UseLegacySQL    bool              `json:"useLegacySql"`
+	ParameterMode   string            `json:"parameterMode,omitempty"`
+	QueryParameters []queryParameter  `json:"queryParameters,omitempty"`
+	DefaultDataset  *datasetReference `json:"defaultDataset,omitempty"`
+	Location        string            `json:"location,omitempty"`
+	TimeoutMs       int64             `json:"timeoutMs,omitempty"`
+}
+
+type jobReference struct {
+	ProjectID string `json:"projectId"`
+	JobID     string `json:"jobId"`
+	Location  string `json:"location"`
+}
+
+type tableCell struct {
+	V interface{} `json:"v"`
+}
+
+type tableRow struct {
+	F []tableCell `json:"f"`
+}
+
+// queryResponse is the response of the query and getQueryResults methods.
+type queryResponse struct {
+	JobComplete  bool         `json:"jobComplete"`
+	JobReference jobReference `json:"jobReference"`
+	Schema       *tableSchema `json:"schema"`
+	Rows         []tableRow   `json:"rows"`
+	PageToken    string       `json:"pageToken"`
+}
+
+// queryTimeoutMs is how long the requests wait for queries to complete, the
+// queries which are not complete are polled.
+const queryTimeoutMs = 10000
+
+func (c *Client) query(ctx context.Context, project string, request *queryRequest) (*queryResponse, error) {
+	request.TimeoutMs = queryTimeoutMs
+	var resp queryResponse
+	if err := c.do(ctx, http.MethodPost, c.bigQueryURL("/projects/%s/queries", url.PathEscape(project)), request, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// getQueryResults returns a page of the results of a query, once it is
+// complete.
+func (c *Client) getQueryResults(ctx context.Context, job jobReference, pageToken string) (*queryResponse, error) {
+	params := url.Values{}
+	params.Set("timeoutMs", fmt.Sprint(queryTimeoutMs))
+	if job.Location != "" {
+		params.Set("location", job.Location)
+	}
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+	var resp queryResponse
+	if err := c.do(ctx, http.MethodGet, c.bigQueryURL("/projects/%s/queries/%s?%s", url.PathEscape(job.ProjectID), url.PathEscape(job.JobID), params.Encode()), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
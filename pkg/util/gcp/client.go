@@ -0,0 +1,223 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcp is a minimal client for the REST APIs of the Google Cloud
+// services used to store flow records in BigQuery: BigQuery, Cloud Storage,
+// Pub/Sub and the BigQuery Data Transfer Service.
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bigQueryEndpoint = "https://bigquery.googleapis.com"
+	storageEndpoint  = "https://storage.googleapis.com"
+	pubSubEndpoint   = "https://pubsub.googleapis.com"
+	transferEndpoint = "https://bigquerydatatransfer.googleapis.com"
+
+	// AccessTokenEnv is the environment variable with the OAuth 2.0 access
+	// token used to authenticate the requests, e.g. the output of
+	// "gcloud auth print-access-token".
+	AccessTokenEnv = "GOOGLE_OAUTH_ACCESS_TOKEN"
+)
+
+// TokenSource returns the OAuth 2.0 access token used to authenticate the
+// requests. refresh is set when the previous token was rejected, e.g. because
+// it was revoked, so that a cached token is not returned again.
+type TokenSource func(ctx context.Context, refresh bool) (string, error)
+
+const (
+	// accessTokenLifetime is the lifetime of the access tokens of gcloud.
+	accessTokenLifetime = time.Hour
+	// accessTokenExpiryMargin is how long before its expiry a cached access
+	// token is refreshed, so that it does not expire during a request.
+	accessTokenExpiryMargin = 5 * time.Minute
+)
+
+// DefaultTokenSource returns the token of the AccessTokenEnv environment
+// variable if it is set, or the token of the active gcloud account. The gcloud
+// token is cached until it expires, or is rejected.
+func DefaultTokenSource() TokenSource {
+	return newCachedTokenSource(gcloudAccessToken, time.Now)
+}
+
+// newCachedTokenSource returns a TokenSource caching the tokens returned by
+// getToken, which are valid for accessTokenLifetime.
+func newCachedTokenSource(getToken func(ctx context.Context) (string, error), now func() time.Time) TokenSource {
+	var mutex sync.Mutex
+	var token string
+	var expiry time.Time
+	return func(ctx context.Context, refresh bool) (string, error) {
+		// the token of the environment variable cannot be refreshed
+		if envToken := os.Getenv(AccessTokenEnv); envToken != "" {
+			return envToken, nil
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if token != "" && !refresh && now().Before(expiry) {
+			return token, nil
+		}
+		newToken, err := getToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		token = newToken
+		expiry = now().Add(accessTokenLifetime - accessTokenExpiryMargin)
+		return token, nil
+	}
+}
+
+// gcloudAccessToken returns a new access token of the active gcloud account.
+func gcloudAccessToken(ctx context.Context) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to get a Google Cloud access token, set the %s environment variable or log in with gcloud: %v: %s", AccessTokenEnv, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Client is a client for the Google Cloud REST APIs.
+type Client struct {
+	httpClient  *http.Client
+	tokenSource TokenSource
+	// baseURL replaces the endpoints of all the services when it is not
+	// empty, e.g. in tests.
+	baseURL string
+}
+
+// NewClient returns a client authenticated with the tokens of tokenSource.
+func NewClient(tokenSource TokenSource) *Client {
+	return &Client{
+		httpClient:  http.DefaultClient,
+		tokenSource: tokenSource,
+	}
+}
+
+// APIError is an error returned by a Google Cloud API.
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (e *APIError) Error() string {
+	if e.Status != "" {
+		return fmt.Sprintf("%s (%d %s)", e.Message, e.Code, e.Status)
+	}
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// IsNotFound returns true if err is an APIError for a missing resource.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// IsAlreadyExists returns true if err is an APIError for a resource which
+// already exists.
+func IsAlreadyExists(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict
+}
+
+func (c *Client) url(endpoint string, path string) string {
+	if c.baseURL != "" {
+		endpoint = c.baseURL
+	}
+	return endpoint + path
+}
+
+// do sends a request with the JSON encoding of in as body, if it is not nil,
+// and decodes the JSON response into out, if it is not nil. The request is
+// sent again with a refreshed token if the token is rejected.
+func (c *Client) do(ctx context.Context, method string, url string, in interface{}, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	resp, data, err := c.send(ctx, method, url, body, false)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		resp, data, err = c.send(ctx, method, url, body, true)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var errResp struct {
+			Error *APIError `json:"error"`
+		}
+		if err := json.Unmarshal(data, &errResp); err != nil || errResp.Error == nil {
+			return &APIError{Code: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		}
+		errResp.Error.Code = resp.StatusCode
+		return errResp.Error
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode the response of %s %s: %v", method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// send sends a request with body, if it is not nil, and returns the response
+// and its body.
+func (c *Client) send(ctx context.Context, method string, url string, body []byte, refreshToken bool) (*http.Response, []byte, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	token, err := c.tokenSource(ctx, refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedTokenSource(t *testing.T) {
+	t.Setenv(AccessTokenEnv, "")
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	tokenSource := newCachedTokenSource(func(context.Context) (string, error) {
+		calls++
+		return fmt.Sprintf("token-%d", calls), nil
+	}, func() time.Time {
+		return now
+	})
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name          string
+		elapsed       time.Duration
+		refresh       bool
+		expectedToken string
+	}{
+		{name: "first token", expectedToken: "token-1"},
+		{name: "cached token", elapsed: 50 * time.Minute, expectedToken: "token-1"},
+		{name: "expiring token", elapsed: 6 * time.Minute, expectedToken: "token-2"},
+		{name: "refreshed cached token", elapsed: time.Minute, refresh: true, expectedToken: "token-3"},
+		{name: "token cached after refresh", elapsed: time.Minute, expectedToken: "token-3"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.elapsed)
+			token, err := tokenSource(ctx, tc.refresh)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedToken, token)
+		})
+	}
+
+	t.Setenv(AccessTokenEnv, "env-token")
+	token, err := tokenSource(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, "env-token", token)
+	assert.Equal(t, 3, calls)
+}
+
+func TestClientRefreshesRejectedToken(t *testing.T) {
+	var authorizations []string
+	validToken := "token-2"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer "+validToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]interface{}{"error": map[string]interface{}{"message": "Invalid Credentials", "status": "UNAUTHENTICATED"}})
+			return
+		}
+		writeJSON(w, map[string]interface{}{"id": "dataset"})
+	}))
+	defer server.Close()
+	calls := 0
+	client := NewClient(func(ctx context.Context, refresh bool) (string, error) {
+		if refresh || calls == 0 {
+			calls++
+		}
+		return fmt.Sprintf("token-%d", calls), nil
+	})
+	client.baseURL = server.URL
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, client.do(context.Background(), http.MethodPost, client.url(bigQueryEndpoint, "/datasets"), map[string]string{"id": "dataset"}, &out))
+	assert.Equal(t, "dataset", out.ID)
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, authorizations)
+
+	// the request is only sent again once
+	validToken = "token-4"
+	err := client.do(context.Background(), http.MethodGet, client.url(bigQueryEndpoint, "/datasets"), nil, nil)
+	assert.EqualError(t, err, "Invalid Credentials (401 UNAUTHENTICATED)")
+	assert.Len(t, authorizations, 4)
+}
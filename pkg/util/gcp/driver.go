@@ -0,0 +1,250 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// OpenBigQuery returns a database handle running the queries in BigQuery,
+// with the tables of the given dataset accessible without qualification. Only
+// queries are supported, with positional parameters.
+func OpenBigQuery(client *Client, project string, dataset string, location string) *sql.DB {
+	return sql.OpenDB(&connector{
+		client:   client,
+		project:  project,
+		dataset:  dataset,
+		location: location,
+	})
+}
+
+type connector struct {
+	client   *Client
+	project  string
+	dataset  string
+	location string
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{connector: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return bigQueryDriver{}
+}
+
+// bigQueryDriver is only used through OpenBigQuery, there is no DSN.
+type bigQueryDriver struct{}
+
+func (bigQueryDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("the BigQuery driver can only be used with OpenBigQuery")
+}
+
+type conn struct {
+	*connector
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported by BigQuery")
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	request := &queryRequest{
+		Query:          query,
+		UseLegacySQL:   false,
+		DefaultDataset: &datasetReference{ProjectID: c.project, DatasetID: c.dataset},
+		Location:       c.location,
+	}
+	if len(args) > 0 {
+		request.ParameterMode = "POSITIONAL"
+		for _, arg := range args {
+			if arg.Name != "" {
+				return nil, fmt.Errorf("named parameters are not supported")
+			}
+			parameter, err := newQueryParameter(arg.Value)
+			if err != nil {
+				return nil, err
+			}
+			request.QueryParameters = append(request.QueryParameters, parameter)
+		}
+	}
+	resp, err := c.client.query(ctx, c.project, request)
+	if err != nil {
+		return nil, err
+	}
+	for !resp.JobComplete {
+		if resp, err = c.client.getQueryResults(ctx, resp.JobReference, ""); err != nil {
+			return nil, err
+		}
+	}
+	return &rows{ctx: ctx, client: c.client, resp: resp}, nil
+}
+
+// newQueryParameter returns the query parameter of an argument.
+func newQueryParameter(value driver.Value) (queryParameter, error) {
+	var parameterType, parameterValue string
+	switch v := value.(type) {
+	case int64:
+		parameterType, parameterValue = "INT64", strconv.FormatInt(v, 10)
+	case float64:
+		parameterType, parameterValue = "FLOAT64", strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		parameterType, parameterValue = "BOOL", strconv.FormatBool(v)
+	case string:
+		parameterType, parameterValue = "STRING", v
+	case []byte:
+		parameterType, parameterValue = "BYTES", base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		parameterType, parameterValue = "TIMESTAMP", v.UTC().Format("2006-01-02 15:04:05.999999-07:00")
+	default:
+		return queryParameter{}, fmt.Errorf("unsupported query parameter type %T", value)
+	}
+	return queryParameter{
+		ParameterType:  queryParameterType{Type: parameterType},
+		ParameterValue: queryParameterValue{Value: parameterValue},
+	}, nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1, the number of parameters is checked by BigQuery.
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("only queries are supported by the BigQuery driver")
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	namedArgs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedArgs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return s.QueryContext(context.Background(), namedArgs)
+}
+
+// QueryContext implements driver.StmtQueryContext, so that the queries of
+// prepared statements are canceled with their context.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// rows iterates over the pages of the results of a query.
+type rows struct {
+	ctx    context.Context
+	client *Client
+	resp   *queryResponse
+	// index is the index of the next row in the current page.
+	index int
+}
+
+func (r *rows) Columns() []string {
+	if r.resp.Schema == nil {
+		return nil
+	}
+	columns := make([]string, len(r.resp.Schema.Fields))
+	for i, field := range r.resp.Schema.Fields {
+		columns[i] = field.Name
+	}
+	return columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	for r.index >= len(r.resp.Rows) {
+		if r.resp.PageToken == "" {
+			return io.EOF
+		}
+		resp, err := r.client.getQueryResults(r.ctx, r.resp.JobReference, r.resp.PageToken)
+		if err != nil {
+			return err
+		}
+		// The schema is not repeated in the following pages.
+		if resp.Schema == nil {
+			resp.Schema = r.resp.Schema
+		}
+		r.resp = resp
+		r.index = 0
+	}
+	row := r.resp.Rows[r.index]
+	r.index++
+	for i := range dest {
+		if i >= len(row.F) {
+			return fmt.Errorf("row has %d values, expected %d", len(row.F), len(dest))
+		}
+		value, err := convertValue(r.resp.Schema.Fields[i].Type, row.F[i].V)
+		if err != nil {
+			return fmt.Errorf("invalid value of column %s: %v", r.resp.Schema.Fields[i].Name, err)
+		}
+		dest[i] = value
+	}
+	return nil
+}
+
+// convertValue converts a value of the REST API, which encodes all the scalar
+// values as strings, to a driver value.
+func convertValue(fieldType string, value interface{}) (driver.Value, error) {
+	if value == nil {
+		return nil, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value %v of type %s", value, fieldType)
+	}
+	switch fieldType {
+	case "INTEGER", "INT64":
+		return strconv.ParseInt(s, 10, 64)
+	case "FLOAT", "FLOAT64":
+		return strconv.ParseFloat(s, 64)
+	case "BOOLEAN", "BOOL":
+		return strconv.ParseBool(s)
+	case "TIMESTAMP":
+		// Timestamps are encoded as seconds since the epoch.
+		seconds, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		whole, fraction := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(math.Round(fraction*1e6))*1e3).UTC(), nil
+	}
+	return s, nil
+}
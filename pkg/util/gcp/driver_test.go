@@ -0,0 +1,192 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := NewClient(func(context.Context, bool) (string, error) {
+		return "token", nil
+	})
+	client.baseURL = server.URL
+	return client
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestBigQueryQuery(t *testing.T) {
+	schema := map[string]interface{}{
+		"fields": []map[string]string{
+			{"name": "sourcePodName", "type": "STRING"},
+			{"name": "octetDeltaCount", "type": "INTEGER"},
+			{"name": "flowEndSeconds", "type": "TIMESTAMP"},
+			{"name": "destinationPodName", "type": "STRING"},
+		},
+	}
+	job := map[string]string{"projectId": "project", "jobId": "job", "location": "US"}
+	var request queryRequest
+	var requests []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("pageToken"))
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/bigquery/v2/projects/project/queries":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			writeJSON(w, map[string]interface{}{"jobComplete": false, "jobReference": job})
+		case r.URL.Query().Get("pageToken") == "":
+			assert.Equal(t, "US", r.URL.Query().Get("location"))
+			writeJSON(w, map[string]interface{}{
+				"jobComplete":  true,
+				"jobReference": job,
+				"schema":       schema,
+				"rows": []map[string]interface{}{
+					{"f": []map[string]interface{}{{"v": "pod-a"}, {"v": "100"}, {"v": "1.6577e9"}, {"v": nil}}},
+				},
+				"pageToken": "page2",
+			})
+		default:
+			writeJSON(w, map[string]interface{}{
+				"jobComplete":  true,
+				"jobReference": job,
+				"rows": []map[string]interface{}{
+					{"f": []map[string]interface{}{{"v": "pod-b"}, {"v": "200"}, {"v": "1657700000.5"}, {"v": "pod-c"}}},
+				},
+			})
+		}
+	})
+	db := OpenBigQuery(client, "project", "antrea_flows", "US")
+	defer db.Close()
+
+	start := time.Date(2022, 7, 13, 8, 0, 0, 0, time.FixedZone("", 3600))
+	rows, err := db.Query("SELECT * FROM flows WHERE flowEndSeconds >= ? AND sourcePodNamespace = ? LIMIT ?", start, "default", 10)
+	require.NoError(t, err)
+	defer rows.Close()
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sourcePodName", "octetDeltaCount", "flowEndSeconds", "destinationPodName"}, columns)
+	type flow struct {
+		source      string
+		bytes       uint64
+		end         time.Time
+		destination *string
+	}
+	var flows []flow
+	for rows.Next() {
+		var f flow
+		require.NoError(t, rows.Scan(&f.source, &f.bytes, &f.end, &f.destination))
+		flows = append(flows, f)
+	}
+	require.NoError(t, rows.Err())
+	podC := "pod-c"
+	assert.Equal(t, []flow{
+		{source: "pod-a", bytes: 100, end: time.Unix(1657700000, 0).UTC()},
+		{source: "pod-b", bytes: 200, end: time.Unix(1657700000, 500000000).UTC(), destination: &podC},
+	}, flows)
+
+	assert.Equal(t, []string{
+		"POST /bigquery/v2/projects/project/queries ",
+		"GET /bigquery/v2/projects/project/queries/job ",
+		"GET /bigquery/v2/projects/project/queries/job page2",
+	}, requests)
+	assert.False(t, request.UseLegacySQL)
+	assert.Equal(t, &datasetReference{ProjectID: "project", DatasetID: "antrea_flows"}, request.DefaultDataset)
+	assert.Equal(t, "US", request.Location)
+	assert.Equal(t, "POSITIONAL", request.ParameterMode)
+	assert.Equal(t, []queryParameter{
+		{ParameterType: queryParameterType{Type: "TIMESTAMP"}, ParameterValue: queryParameterValue{Value: "2022-07-13 07:00:00+00:00"}},
+		{ParameterType: queryParameterType{Type: "STRING"}, ParameterValue: queryParameterValue{Value: "default"}},
+		{ParameterType: queryParameterType{Type: "INT64"}, ParameterValue: queryParameterValue{Value: "10"}},
+	}, request.QueryParameters)
+}
+
+func TestBigQueryQueryError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    404,
+				"message": "Not found: Table project:antrea_flows.flows",
+				"status":  "NOT_FOUND",
+			},
+		})
+	})
+	db := OpenBigQuery(client, "project", "antrea_flows", "US")
+	defer db.Close()
+	_, err := db.Query("SELECT * FROM flows")
+	assert.EqualError(t, err, "Not found: Table project:antrea_flows.flows (404 NOT_FOUND)")
+	assert.True(t, IsNotFound(err))
+
+	_, err = db.Exec("DELETE FROM flows WHERE TRUE")
+	assert.EqualError(t, err, "only queries are supported by the BigQuery driver")
+}
+
+func TestBigQueryPreparedQueryCanceled(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// the request is only canceled once its body is read
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	})
+	db := OpenBigQuery(client, "project", "antrea_flows", "US")
+	defer db.Close()
+	stmt, err := db.Prepare("SELECT * FROM flows")
+	require.NoError(t, err)
+	defer stmt.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = stmt.QueryContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestConvertValue(t *testing.T) {
+	for _, tc := range []struct {
+		fieldType string
+		value     interface{}
+		expected  interface{}
+		err       bool
+	}{
+		{fieldType: "INTEGER", value: "-3", expected: int64(-3)},
+		{fieldType: "INT64", value: "x", err: true},
+		{fieldType: "FLOAT", value: "1.5", expected: 1.5},
+		{fieldType: "BOOLEAN", value: "true", expected: true},
+		{fieldType: "TIMESTAMP", value: "1.657700000123456E9", expected: time.Unix(1657700000, 123456000).UTC()},
+		{fieldType: "STRING", value: "pod", expected: "pod"},
+		{fieldType: "STRING", value: nil, expected: nil},
+		{fieldType: "RECORD", value: map[string]interface{}{}, err: true},
+	} {
+		value, err := convertValue(tc.fieldType, tc.value)
+		if tc.err {
+			assert.Error(t, err, tc.fieldType)
+			continue
+		}
+		require.NoError(t, err, tc.fieldType)
+		assert.Equal(t, tc.expected, value, tc.fieldType)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// CreateBucket creates a Cloud Storage bucket in the given location. It
+// returns false if the bucket already exists.
+func (c *Client) CreateBucket(ctx context.Context, project string, bucket string, location string) (bool, error) {
+	body := struct {
+		Name     string `json:"name"`
+		Location string `json:"location"`
+	}{
+		Name:     bucket,
+		Location: location,
+	}
+	err := c.do(ctx, http.MethodPost, c.url(storageEndpoint, "/storage/v1/b?project="+url.QueryEscape(project)), body, nil)
+	if IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create Cloud Storage bucket %s: %w", bucket, err)
+	}
+	return true, nil
+}
+
+// TopicName returns the full name of a Pub/Sub topic.
+func TopicName(project string, topic string) string {
+	return fmt.Sprintf("projects/%s/topics/%s", project, topic)
+}
+
+// CreateTopic creates a Pub/Sub topic. It returns false if the topic already
+// exists.
+func (c *Client) CreateTopic(ctx context.Context, project string, topic string) (bool, error) {
+	err := c.do(ctx, http.MethodPut, c.url(pubSubEndpoint, "/v1/"+TopicName(url.PathEscape(project), url.PathEscape(topic))), struct{}{}, nil)
+	if IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create Pub/Sub topic %s: %w", topic, err)
+	}
+	return true, nil
+}
+
+// StorageTransfer is a BigQuery Data Transfer Service configuration loading
+// the CSV files of a Cloud Storage folder into a table on a schedule.
+type StorageTransfer struct {
+	// DisplayName identifies the configuration, it must be unique in the
+	// project and location.
+	DisplayName string
+	Dataset     string
+	Table       string
+	// DataPath is the URI of the loaded files, e.g. gs://bucket/flows/*.
+	DataPath string
+	// Schedule is the schedule of the loads, e.g. "every 15 minutes".
+	Schedule string
+	// Topic, if not empty, is the full name of the Pub/Sub topic to which
+	// the result of each load is published.
+	Topic string
+}
+
+type transferConfig struct {
+	Name                    string                 `json:"name,omitempty"`
+	DisplayName             string                 `json:"displayName"`
+	DataSourceID            string                 `json:"dataSourceId"`
+	DestinationDatasetID    string                 `json:"destinationDatasetId"`
+	Schedule                string                 `json:"schedule"`
+	NotificationPubsubTopic string                 `json:"notificationPubsubTopic,omitempty"`
+	Params                  map[string]interface{} `json:"params"`
+}
+
+// storageDataSource is the data source of the transfers from Cloud Storage.
+const storageDataSource = "google_cloud_storage"
+
+func (c *Client) transferURL(format string, a ...interface{}) string {
+	return c.url(transferEndpoint, "/v1/"+fmt.Sprintf(format, a...))
+}
+
+// EnsureStorageTransfer creates the transfer configuration, or updates the
+// existing configuration with the same display name. It returns the name of
+// the configuration, and true if it was created.
+func (c *Client) EnsureStorageTransfer(ctx context.Context, project string, location string, transfer *StorageTransfer) (string, bool, error) {
+	config := transferConfig{
+		DisplayName:             transfer.DisplayName,
+		DataSourceID:            storageDataSource,
+		DestinationDatasetID:    transfer.Dataset,
+		Schedule:                transfer.Schedule,
+		NotificationPubsubTopic: transfer.Topic,
+		Params: map[string]interface{}{
+			"data_path_template":              transfer.DataPath,
+			"destination_table_name_template": transfer.Table,
+			"file_format":                     "CSV",
+			"write_disposition":               "APPEND",
+			// The loaded files are deleted, so that they are not loaded
+			// again by the next run.
+			"delete_source_files": true,
+			"allow_jagged_rows":   true,
+		},
+	}
+	parent := fmt.Sprintf("projects/%s/locations/%s", url.PathEscape(project), url.PathEscape(location))
+	existing, err := c.findTransferConfig(ctx, parent, transfer.DisplayName)
+	if err != nil {
+		return "", false, err
+	}
+	if existing == "" {
+		var created transferConfig
+		if err := c.do(ctx, http.MethodPost, c.transferURL("%s/transferConfigs", parent), config, &created); err != nil {
+			return "", false, fmt.Errorf("failed to create transfer configuration %s: %w", transfer.DisplayName, err)
+		}
+		return created.Name, true, nil
+	}
+	params := url.Values{}
+	params.Set("updateMask", "destinationDatasetId,schedule,notificationPubsubTopic,params")
+	if err := c.do(ctx, http.MethodPatch, c.transferURL("%s?%s", existing, params.Encode()), config, nil); err != nil {
+		return "", false, fmt.Errorf("failed to update transfer configuration %s: %w", transfer.DisplayName, err)
+	}
+	return existing, false, nil
+}
+
+// findTransferConfig returns the name of the Cloud Storage transfer
+// configuration with the given display name, or an empty string if there is
+// none.
+func (c *Client) findTransferConfig(ctx context.Context, parent string, displayName string) (string, error) {
+	params := url.Values{}
+	params.Set("dataSourceIds", storageDataSource)
+	for {
+		var resp struct {
+			TransferConfigs []transferConfig `json:"transferConfigs"`
+			NextPageToken   string           `json:"nextPageToken"`
+		}
+		if err := c.do(ctx, http.MethodGet, c.transferURL("%s/transferConfigs?%s", parent, params.Encode()), nil, &resp); err != nil {
+			return "", fmt.Errorf("failed to list transfer configurations: %w", err)
+		}
+		for _, config := range resp.TransferConfigs {
+			if config.DisplayName == displayName {
+				return config.Name, nil
+			}
+		}
+		if resp.NextPageToken == "" {
+			return "", nil
+		}
+		params.Set("pageToken", resp.NextPageToken)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateResources(t *testing.T) {
+	existing := map[string]bool{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.RequestURI()
+		if existing[key] {
+			w.WriteHeader(http.StatusConflict)
+			writeJSON(w, map[string]interface{}{"error": map[string]interface{}{"message": "Already Exists", "status": "ALREADY_EXISTS"}})
+			return
+		}
+		existing[key] = true
+		writeJSON(w, map[string]interface{}{})
+	})
+	ctx := context.Background()
+	for i, expected := range []bool{true, false} {
+		created, err := client.CreateDataset(ctx, "project", "antrea_flows", "US")
+		require.NoError(t, err)
+		assert.Equal(t, expected, created, "dataset, attempt %d", i)
+		created, err = client.CreateTable(ctx, "project", "antrea_flows", &Table{Name: "flows", Fields: []Field{{Name: "flowEndSeconds", Type: "TIMESTAMP"}}, PartitionField: "flowEndSeconds"})
+		require.NoError(t, err)
+		assert.Equal(t, expected, created, "table, attempt %d", i)
+		created, err = client.CreateBucket(ctx, "project", "antrea-flows", "US")
+		require.NoError(t, err)
+		assert.Equal(t, expected, created, "bucket, attempt %d", i)
+		created, err = client.CreateTopic(ctx, "project", "antrea-flows")
+		require.NoError(t, err)
+		assert.Equal(t, expected, created, "topic, attempt %d", i)
+	}
+	assert.Equal(t, map[string]bool{
+		"POST /bigquery/v2/projects/project/datasets":                     true,
+		"POST /bigquery/v2/projects/project/datasets/antrea_flows/tables": true,
+		"POST /storage/v1/b?project=project":                              true,
+		"PUT /v1/projects/project/topics/antrea-flows":                    true,
+	}, existing)
+}
+
+func TestEnsureStorageTransfer(t *testing.T) {
+	var configs []transferConfig
+	var updateMask string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		const parent = "/v1/projects/project/locations/us/transferConfigs"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == parent:
+			assert.Equal(t, storageDataSource, r.URL.Query().Get("dataSourceIds"))
+			writeJSON(w, map[string]interface{}{"transferConfigs": configs})
+		case r.Method == http.MethodPost && r.URL.Path == parent:
+			var config transferConfig
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&config))
+			config.Name = "projects/1234/locations/us/transferConfigs/abcd"
+			configs = append(configs, config)
+			writeJSON(w, config)
+		case r.Method == http.MethodPatch && r.URL.Path == "/v1/projects/1234/locations/us/transferConfigs/abcd":
+			updateMask = r.URL.Query().Get("updateMask")
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&configs[0]))
+			writeJSON(w, configs[0])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	transfer := &StorageTransfer{
+		DisplayName: "theia-antrea_flows-flows",
+		Dataset:     "antrea_flows",
+		Table:       "flows",
+		DataPath:    "gs://antrea-flows/flows/*",
+		Schedule:    "every 15 minutes",
+		Topic:       TopicName("project", "antrea-flows"),
+	}
+	name, created, err := client.EnsureStorageTransfer(context.Background(), "project", "us", transfer)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "projects/1234/locations/us/transferConfigs/abcd", name)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "google_cloud_storage", configs[0].DataSourceID)
+	assert.Equal(t, "projects/project/topics/antrea-flows", configs[0].NotificationPubsubTopic)
+	assert.Equal(t, "gs://antrea-flows/flows/*", configs[0].Params["data_path_template"])
+	assert.Equal(t, "flows", configs[0].Params["destination_table_name_template"])
+
+	transfer.Schedule = "every 1 hours"
+	name, created, err = client.EnsureStorageTransfer(context.Background(), "project", "us", transfer)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "projects/1234/locations/us/transferConfigs/abcd", name)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "every 1 hours", configs[0].Schedule)
+	assert.Equal(t, "destinationDatasetId,schedule,notificationPubsubTopic,params", updateMask)
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ImageOverrides is the content of an image overrides file, which maps the
+// images used by Theia to the images to use instead, e.g. the copies of the
+// images in the registry of a disconnected environment.
+type ImageOverrides struct {
+	Images map[string]string `yaml:"images"`
+}
+
+// ContainerImages returns the sorted images of all the containers of the
+// objects, without duplicates.
+func ContainerImages(objects []*unstructured.Unstructured) []string {
+	found := make(map[string]bool)
+	for _, object := range objects {
+		visitContainers(object.Object, func(container map[string]interface{}) {
+			if image, ok := container["image"].(string); ok && image != "" {
+				found[image] = true
+			}
+		})
+	}
+	images := make([]string, 0, len(found))
+	for image := range found {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images
+}
+
+// RenderImageOverrides maps each image to an image with the same name and tag
+// in the registry, e.g. projects.registry.vmware.com/antrea/theia-grafana:8.3.3
+// to registry.example.com/theia/theia-grafana:8.3.3.
+func RenderImageOverrides(images []string, registry string) ImageOverrides {
+	registry = strings.TrimSuffix(registry, "/")
+	overrides := ImageOverrides{Images: make(map[string]string, len(images))}
+	for _, image := range images {
+		// The digest of an image cannot contain a "/", so the last one
+		// always separates the name from the repository.
+		name := image[strings.LastIndex(image, "/")+1:]
+		overrides.Images[image] = registry + "/" + name
+	}
+	return overrides
+}
+
+// Image returns the image to use instead of the image, which is the image
+// itself if it is not overridden.
+func (o ImageOverrides) Image(image string) string {
+	if override, ok := o.Images[image]; ok {
+		return override
+	}
+	return image
+}
+
+// Write writes the image overrides as YAML, sorted by image.
+func (o ImageOverrides) Write(out io.Writer) error {
+	data, err := yaml.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("error when encoding the image overrides: %v", err)
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// LoadImageOverrides reads an image overrides file.
+func LoadImageOverrides(path string) (ImageOverrides, error) {
+	var overrides ImageOverrides
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return overrides, fmt.Errorf("error when reading image overrides file %s: %v", path, err)
+	}
+	if err := yaml.UnmarshalStrict(data, &overrides); err != nil {
+		return overrides, fmt.Errorf("error when decoding image overrides file %s: %v", path, err)
+	}
+	for image, override := range overrides.Images {
+		if image == "" || override == "" {
+			return overrides, fmt.Errorf("invalid image overrides file %s: images should not be empty", path)
+		}
+	}
+	return overrides, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerImages(t *testing.T) {
+	objects, err := Manifests(Options{WithGrafana: true, WithSparkOperator: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"projects.registry.vmware.com/antrea/busybox",
+		"projects.registry.vmware.com/antrea/theia-clickhouse-monitor:latest",
+		"projects.registry.vmware.com/antrea/theia-clickhouse-operator:0.18.2",
+		"projects.registry.vmware.com/antrea/theia-clickhouse-server:latest",
+		"projects.registry.vmware.com/antrea/theia-grafana:8.3.3",
+		"projects.registry.vmware.com/antrea/theia-metrics-exporter:0.18.2",
+		"projects.registry.vmware.com/antrea/theia-spark-operator:v1beta2-1.3.3-3.1.1",
+		"projects.registry.vmware.com/antrea/theia-zookeeper:3.8.0",
+	}, ContainerImages(objects))
+}
+
+func TestImageOverrides(t *testing.T) {
+	images := []string{
+		"projects.registry.vmware.com/antrea/theia-grafana:8.3.3",
+		"projects.registry.vmware.com/antrea/busybox",
+		"docker.io/library/busybox@sha256:3fbc632167424a6d997e74f52b878d7cc478225cffac6bc977eedfe51c7f4e79",
+	}
+	overrides := RenderImageOverrides(images, "registry.example.com/theia/")
+	assert.Equal(t, map[string]string{
+		"projects.registry.vmware.com/antrea/theia-grafana:8.3.3":                                           "registry.example.com/theia/theia-grafana:8.3.3",
+		"projects.registry.vmware.com/antrea/busybox":                                                       "registry.example.com/theia/busybox",
+		"docker.io/library/busybox@sha256:3fbc632167424a6d997e74f52b878d7cc478225cffac6bc977eedfe51c7f4e79": "registry.example.com/theia/busybox@sha256:3fbc632167424a6d997e74f52b878d7cc478225cffac6bc977eedfe51c7f4e79",
+	}, overrides.Images)
+	assert.Equal(t, "registry.example.com/theia/busybox", overrides.Image("projects.registry.vmware.com/antrea/busybox"))
+	assert.Equal(t, "nginx", overrides.Image("nginx"))
+
+	var out bytes.Buffer
+	require.NoError(t, overrides.Write(&out))
+	path := filepath.Join(t.TempDir(), "images.yaml")
+	require.NoError(t, os.WriteFile(path, out.Bytes(), 0644))
+	loaded, err := LoadImageOverrides(path)
+	require.NoError(t, err)
+	assert.Equal(t, overrides, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte("image:\n  nginx: registry.example.com/theia/nginx\n"), 0644))
+	_, err = LoadImageOverrides(path)
+	assert.ErrorContains(t, err, "error when decoding image overrides file")
+	require.NoError(t, os.WriteFile(path, []byte("images:\n  nginx: \"\"\n"), 0644))
+	_, err = LoadImageOverrides(path)
+	assert.ErrorContains(t, err, "images should not be empty")
+}
@@ -0,0 +1,438 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package install applies the manifests of the Theia components embedded in
+// the binary with server-side apply. It is used by "theia install
+// flow-visibility" and by the e2e tests, so that Theia is installed the same
+// way in both cases.
+package install
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+
+	"antrea.io/theia/build"
+	"antrea.io/theia/pkg/util/diskcache"
+)
+
+const (
+	// Namespace is the Namespace of the embedded manifests. The namespaced
+	// objects which do not set their Namespace are installed in it.
+	Namespace = "flow-visibility"
+	// DefaultImageRegistry is the registry of the images of the embedded
+	// manifests.
+	DefaultImageRegistry = "projects.registry.vmware.com/antrea"
+
+	fieldManager = "theia"
+)
+
+var (
+	// crdPollInterval and crdPollTimeout bound the wait for the CRDs to be
+	// served before the custom resources are applied.
+	crdPollInterval = 2 * time.Second
+	crdPollTimeout  = 2 * time.Minute
+	// deletionPollTimeout bounds the wait for the ClickHouseInstallation to
+	// be deleted by the ClickHouse Operator.
+	deletionPollTimeout = 5 * time.Minute
+)
+
+var clickHouseInstallationResource = schema.GroupVersionResource{
+	Group:    "clickhouse.altinity.com",
+	Version:  "v1",
+	Resource: "clickhouseinstallations",
+}
+
+// Options selects the components to install and overrides some values of the
+// embedded manifests.
+type Options struct {
+	// WithSparkOperator installs the Spark Operator, which is required to
+	// run policy recommendation jobs with the Spark Operator backend.
+	WithSparkOperator bool
+	// WithGrafana installs Grafana and the Theia dashboards.
+	WithGrafana bool
+	// ImageRegistry replaces DefaultImageRegistry in the images of all
+	// containers when not empty, e.g. to pull the images from a private
+	// registry.
+	ImageRegistry string
+	// Images maps the images of the embedded manifests to the images to use
+	// instead, e.g. the images of an image overrides file.
+	Images map[string]string
+	// ImagePullPolicy overrides the pull policy of all containers when not
+	// empty.
+	ImagePullPolicy string
+	// ClickHouseStorageSize overrides the size of the ClickHouse storage,
+	// e.g. "100Mi", when not empty.
+	ClickHouseStorageSize string
+	// ClickHouseMonitorThreshold overrides the ratio of the ClickHouse
+	// storage above which the ClickHouse monitor deletes records, e.g.
+	// "0.1", when not empty.
+	ClickHouseMonitorThreshold string
+}
+
+type manifest struct {
+	name string
+	data []byte
+}
+
+// Manifests returns the objects to install for the options, in the order in
+// which they must be applied: the ClickHouse Operator first, then ClickHouse
+// and Grafana, and the Spark Operator last.
+func Manifests(options Options) ([]*unstructured.Unstructured, error) {
+	manifests := []manifest{
+		{name: "ClickHouse Operator", data: build.ClickHouseOperator},
+		{name: "flow visibility", data: build.FlowVisibility},
+	}
+	if options.WithSparkOperator {
+		manifests = append(manifests, manifest{name: "Spark Operator", data: build.SparkOperator})
+	}
+	var objects []*unstructured.Unstructured
+	for _, manifest := range manifests {
+		decoded, err := decodeManifest(manifest.data)
+		if err != nil {
+			return nil, fmt.Errorf("error when decoding the %s manifest: %v", manifest.name, err)
+		}
+		for _, object := range decoded {
+			// The objects of Grafana are all named after it in the
+			// Theia Helm chart.
+			if !options.WithGrafana && strings.HasPrefix(object.GetName(), "grafana") {
+				continue
+			}
+			visitContainers(object.Object, func(container map[string]interface{}) {
+				overrideContainer(container, options)
+			})
+			if object.GetKind() == "ClickHouseInstallation" {
+				if err := overrideClickHouse(object, options); err != nil {
+					return nil, fmt.Errorf("error when overriding the ClickHouse options: %v", err)
+				}
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+func decodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(manifest)))
+	for {
+		document, err := reader.Read()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		object := &unstructured.Unstructured{}
+		if err := k8syaml.Unmarshal(document, &object.Object); err != nil {
+			return nil, err
+		}
+		// Skip the documents which only contain comments.
+		if len(object.Object) == 0 {
+			continue
+		}
+		objects = append(objects, object)
+	}
+}
+
+// visitContainers calls visit for all the containers found in the value,
+// whatever the kind of the object, e.g. the containers of the Pod templates of
+// Deployments and ClickHouseInstallations. Only the lists of containers are
+// considered, so that the "image" properties of the CRD schemas are skipped.
+func visitContainers(value interface{}, visit func(container map[string]interface{})) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if containers, ok := field.([]interface{}); ok && (key == "containers" || key == "initContainers") {
+				for _, container := range containers {
+					if container, ok := container.(map[string]interface{}); ok {
+						visit(container)
+					}
+				}
+				continue
+			}
+			visitContainers(field, visit)
+		}
+	case []interface{}:
+		for _, item := range value {
+			visitContainers(item, visit)
+		}
+	}
+}
+
+// overrideContainer applies the image overrides of the options to a
+// container. The images of the Images overrides take precedence over
+// ImageRegistry.
+func overrideContainer(container map[string]interface{}, options Options) {
+	if image, ok := container["image"].(string); ok {
+		if override, ok := options.Images[image]; ok {
+			container["image"] = override
+		} else if options.ImageRegistry != "" && strings.HasPrefix(image, DefaultImageRegistry+"/") {
+			container["image"] = strings.TrimSuffix(options.ImageRegistry, "/") + strings.TrimPrefix(image, DefaultImageRegistry)
+		}
+	}
+	if options.ImagePullPolicy != "" {
+		container["imagePullPolicy"] = options.ImagePullPolicy
+	}
+}
+
+// overrideClickHouse applies the ClickHouse overrides of the options to the
+// Pod templates of the ClickHouseInstallation: the storage size is both the
+// size limit of the storage volume and the STORAGE_SIZE of the ClickHouse
+// monitor.
+func overrideClickHouse(object *unstructured.Unstructured, options Options) error {
+	podTemplates, _, err := unstructured.NestedSlice(object.Object, "spec", "templates", "podTemplates")
+	if err != nil {
+		return err
+	}
+	for _, podTemplate := range podTemplates {
+		podTemplate, ok := podTemplate.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if options.ClickHouseStorageSize != "" {
+			volumes, _, err := unstructured.NestedSlice(podTemplate, "spec", "volumes")
+			if err != nil {
+				return err
+			}
+			for _, volume := range volumes {
+				volume, ok := volume.(map[string]interface{})
+				if !ok || volume["name"] != "clickhouse-storage-volume" {
+					continue
+				}
+				if err := unstructured.SetNestedField(volume, options.ClickHouseStorageSize, "emptyDir", "sizeLimit"); err != nil {
+					return err
+				}
+			}
+			if err := unstructured.SetNestedSlice(podTemplate, volumes, "spec", "volumes"); err != nil {
+				return err
+			}
+		}
+		containers, _, err := unstructured.NestedSlice(podTemplate, "spec", "containers")
+		if err != nil {
+			return err
+		}
+		for _, container := range containers {
+			container, ok := container.(map[string]interface{})
+			if !ok || container["name"] != "clickhouse-monitor" {
+				continue
+			}
+			env, _ := container["env"].([]interface{})
+			for _, envVar := range env {
+				envVar, ok := envVar.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if envVar["name"] == "STORAGE_SIZE" && options.ClickHouseStorageSize != "" {
+					envVar["value"] = options.ClickHouseStorageSize
+				}
+				if envVar["name"] == "THRESHOLD" && options.ClickHouseMonitorThreshold != "" {
+					envVar["value"] = options.ClickHouseMonitorThreshold
+				}
+			}
+		}
+		if err := unstructured.SetNestedSlice(podTemplate, containers, "spec", "containers"); err != nil {
+			return err
+		}
+	}
+	return unstructured.SetNestedSlice(object.Object, podTemplates, "spec", "templates", "podTemplates")
+}
+
+// Installer applies and deletes the objects of the embedded manifests.
+type Installer struct {
+	client dynamic.Interface
+	mapper meta.ResettableRESTMapper
+	out    io.Writer
+}
+
+// NewInstaller creates an Installer which prints the applied and deleted
+// objects to out.
+func NewInstaller(client dynamic.Interface, mapper meta.ResettableRESTMapper, out io.Writer) *Installer {
+	return &Installer{
+		client: client,
+		mapper: mapper,
+		out:    out,
+	}
+}
+
+// NewInstallerForConfig creates an Installer which discovers the resources of
+// the objects from the K8s apiserver. The discovered resources are stored in
+// cache, which can be nil.
+func NewInstallerForConfig(config *rest.Config, cache *diskcache.Cache, out io.Writer) (*Installer, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(diskcache.NewDiscoveryClient(discoveryClient, cache))
+	return NewInstaller(client, mapper, out), nil
+}
+
+// Apply applies the objects in order with server-side apply, taking the
+// ownership of the fields managed by other field managers, and prints each
+// applied object.
+func (i *Installer) Apply(ctx context.Context, objects []*unstructured.Unstructured) error {
+	force := true
+	for _, object := range objects {
+		resource, err := i.resourceFor(ctx, object, true)
+		if err != nil {
+			return err
+		}
+		data, err := object.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("error when encoding %s %s: %v", object.GetKind(), object.GetName(), err)
+		}
+		if _, err := resource.Patch(ctx, object.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+			FieldManager: fieldManager,
+			Force:        &force,
+		}); err != nil {
+			return fmt.Errorf("error when applying %s %s: %v", object.GetKind(), object.GetName(), err)
+		}
+		fmt.Fprintf(i.out, "%s %s applied\n", object.GetKind(), object.GetName())
+	}
+	return nil
+}
+
+// Delete deletes the objects in the reverse order. The objects which do not
+// exist, or whose CRD has already been deleted, are ignored. Delete waits for
+// the ClickHouseInstallation to be deleted, as the ClickHouse Operator, which
+// is deleted after it, must first remove its finalizer.
+func (i *Installer) Delete(ctx context.Context, objects []*unstructured.Unstructured) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	for idx := len(objects) - 1; idx >= 0; idx-- {
+		object := objects[idx]
+		resource, err := i.resourceFor(ctx, object, false)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = resource.Delete(ctx, object.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagationPolicy})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error when deleting %s %s: %v", object.GetKind(), object.GetName(), err)
+		}
+		if object.GetKind() == "ClickHouseInstallation" {
+			if err := wait.PollImmediateWithContext(ctx, crdPollInterval, deletionPollTimeout, func(ctx context.Context) (bool, error) {
+				_, err := resource.Get(ctx, object.GetName(), metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					return true, nil
+				}
+				return false, err
+			}); err != nil {
+				return fmt.Errorf("error when waiting for %s %s to be deleted: %v", object.GetKind(), object.GetName(), err)
+			}
+		}
+		fmt.Fprintf(i.out, "%s %s deleted\n", object.GetKind(), object.GetName())
+	}
+	return nil
+}
+
+// RetainClickHouseData sets the reclaim policy of the volume claim templates
+// of the ClickHouseInstallation to Retain, so that the ClickHouse Operator
+// keeps the PersistentVolumeClaims of ClickHouse when it is deleted. It
+// returns false if ClickHouse is not installed, or does not store its data in
+// PersistentVolumes.
+func (i *Installer) RetainClickHouseData(ctx context.Context) (bool, error) {
+	resource := i.client.Resource(clickHouseInstallationResource).Namespace(Namespace)
+	chi, err := resource.Get(ctx, "clickhouse", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error when getting the ClickHouseInstallation: %v", err)
+	}
+	templates, _, err := unstructured.NestedSlice(chi.Object, "spec", "templates", "volumeClaimTemplates")
+	if err != nil {
+		return false, err
+	}
+	if len(templates) == 0 {
+		return false, nil
+	}
+	for _, template := range templates {
+		if template, ok := template.(map[string]interface{}); ok {
+			template["reclaimPolicy"] = "Retain"
+		}
+	}
+	if err := unstructured.SetNestedSlice(chi.Object, templates, "spec", "templates", "volumeClaimTemplates"); err != nil {
+		return false, err
+	}
+	if _, err := resource.Update(ctx, chi, metav1.UpdateOptions{FieldManager: fieldManager}); err != nil {
+		return false, fmt.Errorf("error when updating the ClickHouseInstallation: %v", err)
+	}
+	return true, nil
+}
+
+// resourceFor returns the client of the resource of the object. When
+// waitForCRD is true, it waits for the CRDs applied before the object to be
+// served.
+func (i *Installer) resourceFor(ctx context.Context, object *unstructured.Unstructured, waitForCRD bool) (dynamic.ResourceInterface, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := i.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// Older K8s versions may serve the kind at another version, e.g.
+		// policy/v1beta1 PodDisruptionBudgets before K8s v1.21, in which
+		// case the object is applied at this version.
+		if otherMapping, otherErr := i.mapper.RESTMapping(gvk.GroupKind()); otherErr == nil {
+			mapping, err = otherMapping, nil
+			object.SetAPIVersion(mapping.GroupVersionKind.GroupVersion().String())
+		}
+	}
+	if meta.IsNoMatchError(err) && waitForCRD {
+		err = wait.PollImmediateWithContext(ctx, crdPollInterval, crdPollTimeout, func(ctx context.Context) (bool, error) {
+			i.mapper.Reset()
+			mapping, err = i.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+			if meta.IsNoMatchError(err) {
+				return false, nil
+			}
+			return err == nil, err
+		})
+		if err == wait.ErrWaitTimeout {
+			return nil, fmt.Errorf("kind %s is not served by the K8s apiserver", gvk)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return i.client.Resource(mapping.Resource), nil
+	}
+	if object.GetNamespace() == "" {
+		object.SetNamespace(Namespace)
+	}
+	return i.client.Resource(mapping.Resource).Namespace(object.GetNamespace()), nil
+}
@@ -0,0 +1,300 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func findObject(objects []*unstructured.Unstructured, kind, name string) *unstructured.Unstructured {
+	for _, object := range objects {
+		if object.GetKind() == kind && object.GetName() == name {
+			return object
+		}
+	}
+	return nil
+}
+
+func containerImages(t *testing.T, object *unstructured.Unstructured) []string {
+	containers, _, err := unstructured.NestedSlice(object.Object, "spec", "template", "spec", "containers")
+	require.NoError(t, err)
+	var images []string
+	for _, container := range containers {
+		images = append(images, container.(map[string]interface{})["image"].(string))
+	}
+	return images
+}
+
+func TestManifests(t *testing.T) {
+	t.Run("default components", func(t *testing.T) {
+		objects, err := Manifests(Options{WithGrafana: true})
+		require.NoError(t, err)
+		assert.NotNil(t, findObject(objects, "Deployment", "clickhouse-operator"))
+		assert.NotNil(t, findObject(objects, "ClickHouseInstallation", "clickhouse"))
+		assert.NotNil(t, findObject(objects, "Deployment", "grafana"))
+		assert.Nil(t, findObject(objects, "Deployment", "policy-recommendation-spark-operator"))
+		assert.Equal(t, "CustomResourceDefinition", objects[0].GetKind())
+	})
+
+	t.Run("with Spark Operator without Grafana", func(t *testing.T) {
+		objects, err := Manifests(Options{WithSparkOperator: true})
+		require.NoError(t, err)
+		for _, object := range objects {
+			assert.NotContains(t, object.GetName(), "grafana")
+		}
+		assert.NotNil(t, findObject(objects, "CustomResourceDefinition", "sparkapplications.sparkoperator.k8s.io"))
+		assert.NotNil(t, findObject(objects, "Deployment", "policy-recommendation-spark-operator"))
+		assert.Equal(t, "ClusterRoleBinding", objects[len(objects)-1].GetKind())
+	})
+
+	t.Run("ClickHouse overrides", func(t *testing.T) {
+		objects, err := Manifests(Options{ClickHouseStorageSize: "100Mi", ClickHouseMonitorThreshold: "0.1"})
+		require.NoError(t, err)
+		podTemplates, _, err := unstructured.NestedSlice(findObject(objects, "ClickHouseInstallation", "clickhouse").Object, "spec", "templates", "podTemplates")
+		require.NoError(t, err)
+		podSpec := podTemplates[0].(map[string]interface{})["spec"].(map[string]interface{})
+		sizeLimits := make(map[string]interface{})
+		for _, volume := range podSpec["volumes"].([]interface{}) {
+			volume := volume.(map[string]interface{})
+			if emptyDir, ok := volume["emptyDir"].(map[string]interface{}); ok {
+				sizeLimits[volume["name"].(string)] = emptyDir["sizeLimit"]
+			}
+		}
+		assert.Equal(t, map[string]interface{}{"clickhouse-storage-volume": "100Mi"}, sizeLimits)
+		env := make(map[string]interface{})
+		for _, container := range podSpec["containers"].([]interface{}) {
+			container := container.(map[string]interface{})
+			if container["name"] != "clickhouse-monitor" {
+				continue
+			}
+			for _, envVar := range container["env"].([]interface{}) {
+				envVar := envVar.(map[string]interface{})
+				env[envVar["name"].(string)] = envVar["value"]
+			}
+		}
+		assert.Equal(t, "100Mi", env["STORAGE_SIZE"])
+		assert.Equal(t, "0.1", env["THRESHOLD"])
+		assert.Equal(t, "0.5", env["DELETE_PERCENTAGE"])
+	})
+
+	t.Run("image overrides", func(t *testing.T) {
+		objects, err := Manifests(Options{WithGrafana: true, WithSparkOperator: true, ImageRegistry: "registry.example.com/theia/", ImagePullPolicy: "Always"})
+		require.NoError(t, err)
+		grafana := findObject(objects, "Deployment", "grafana")
+		assert.Equal(t, []string{"registry.example.com/theia/theia-grafana:8.3.3"}, containerImages(t, grafana))
+		sparkOperator := findObject(objects, "Deployment", "policy-recommendation-spark-operator")
+		assert.Equal(t, []string{"registry.example.com/theia/theia-spark-operator:v1beta2-1.3.3-3.1.1"}, containerImages(t, sparkOperator))
+		containers, _, _ := unstructured.NestedSlice(grafana.Object, "spec", "template", "spec", "containers")
+		assert.Equal(t, "Always", containers[0].(map[string]interface{})["imagePullPolicy"])
+
+		podTemplates, _, err := unstructured.NestedSlice(findObject(objects, "ClickHouseInstallation", "clickhouse").Object, "spec", "templates", "podTemplates")
+		require.NoError(t, err)
+		containers, _, _ = unstructured.NestedSlice(podTemplates[0].(map[string]interface{}), "spec", "containers")
+		assert.Equal(t, "registry.example.com/theia/theia-clickhouse-server:latest", containers[0].(map[string]interface{})["image"])
+
+		// The CRDs are left unchanged.
+		defaultObjects, err := Manifests(Options{WithSparkOperator: true})
+		require.NoError(t, err)
+		name := "sparkapplications.sparkoperator.k8s.io"
+		assert.Equal(t, findObject(defaultObjects, "CustomResourceDefinition", name), findObject(objects, "CustomResourceDefinition", name))
+	})
+
+	t.Run("images", func(t *testing.T) {
+		images := map[string]string{"projects.registry.vmware.com/antrea/theia-grafana:8.3.3": "mirror.example.com/grafana:8.3.3"}
+		objects, err := Manifests(Options{WithGrafana: true, ImageRegistry: "registry.example.com/theia", Images: images})
+		require.NoError(t, err)
+		// The images take precedence over the registry.
+		assert.Equal(t, []string{"mirror.example.com/grafana:8.3.3"}, containerImages(t, findObject(objects, "Deployment", "grafana")))
+		assert.Equal(t, []string{"registry.example.com/theia/theia-zookeeper:3.8.0"}, containerImages(t, findObject(objects, "StatefulSet", "zookeeper")))
+	})
+}
+
+// crdRESTMapper is a RESTMapper which only maps the kinds of the CRDs after it
+// has been reset, like a discovery RESTMapper when the CRDs are not served yet.
+type crdRESTMapper struct {
+	*meta.DefaultRESTMapper
+	crdKinds []schema.GroupVersionKind
+	resets   int
+}
+
+func (m *crdRESTMapper) Reset() {
+	m.resets++
+	if m.resets == 2 {
+		for _, gvk := range m.crdKinds {
+			m.Add(gvk, meta.RESTScopeNamespace)
+		}
+	}
+}
+
+func TestInstallerApply(t *testing.T) {
+	defer func(interval, timeout time.Duration) {
+		crdPollInterval, crdPollTimeout = interval, timeout
+	}(crdPollInterval, crdPollTimeout)
+	crdPollInterval, crdPollTimeout = time.Millisecond, 5*time.Second
+	mapper := &crdRESTMapper{
+		// The preferred versions of the K8s apiserver, when the version
+		// of the kind is not given.
+		DefaultRESTMapper: meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "policy", Version: "v1beta1"}}),
+		crdKinds:          []schema.GroupVersionKind{{Group: "clickhouse.altinity.com", Version: "v1", Kind: "ClickHouseInstallation"}},
+	}
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"}, meta.RESTScopeNamespace)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &unstructured.Unstructured{}, nil
+	})
+	objects, err := decodeManifest([]byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: flow-visibility
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: clickhouse-mounted-configmap
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: zookeeper-pod-disruption-budget
+---
+apiVersion: clickhouse.altinity.com/v1
+kind: ClickHouseInstallation
+metadata:
+  name: clickhouse
+  namespace: flow-visibility
+`))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, NewInstaller(client, mapper, &out).Apply(context.TODO(), objects))
+	assert.Equal(t, `Namespace flow-visibility applied
+ConfigMap clickhouse-mounted-configmap applied
+PodDisruptionBudget zookeeper-pod-disruption-budget applied
+ClickHouseInstallation clickhouse applied
+`, out.String())
+	assert.Equal(t, 2, mapper.resets)
+	assert.Equal(t, "policy/v1beta1", objects[2].GetAPIVersion())
+	actions := client.Actions()
+	require.Len(t, actions, 4)
+	for _, action := range actions {
+		patch := action.(k8stesting.PatchAction)
+		assert.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+	}
+	assert.Equal(t, "", actions[0].GetNamespace())
+	assert.Equal(t, "flow-visibility", actions[1].GetNamespace())
+	assert.Equal(t, "clickhouseinstallations", actions[3].GetResource().Resource)
+}
+
+func TestInstallerDelete(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "clickhouse.altinity.com", Version: "v1", Kind: "ClickHouseInstallation"}, meta.RESTScopeNamespace)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "clickhouse-mounted-configmap", "namespace": "flow-visibility"},
+	}}, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "clickhouse.altinity.com/v1",
+		"kind":       "ClickHouseInstallation",
+		"metadata":   map[string]interface{}{"name": "clickhouse", "namespace": "flow-visibility"},
+	}})
+	objects, err := decodeManifest([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: clickhouse-mounted-configmap
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: clickhouse-secret
+---
+apiVersion: clickhouse.altinity.com/v1
+kind: ClickHouseInstallation
+metadata:
+  name: clickhouse
+`))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, NewInstaller(client, meta.MultiRESTMapper{mapper}, &out).Delete(context.TODO(), objects))
+	assert.Equal(t, "ClickHouseInstallation clickhouse deleted\nConfigMap clickhouse-mounted-configmap deleted\n", out.String())
+	var resources []string
+	for _, action := range client.Actions() {
+		resources = append(resources, action.GetVerb()+" "+action.GetResource().Resource)
+	}
+	// The ClickHouseInstallation is deleted first, and Delete waits for it to
+	// be deleted.
+	assert.Equal(t, []string{"delete clickhouseinstallations", "get clickhouseinstallations", "delete secrets", "delete configmaps"}, resources)
+}
+
+func TestRetainClickHouseData(t *testing.T) {
+	newCHI := func(volumeClaimTemplates ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "clickhouse.altinity.com/v1",
+			"kind":       "ClickHouseInstallation",
+			"metadata":   map[string]interface{}{"name": "clickhouse", "namespace": "flow-visibility"},
+			"spec": map[string]interface{}{
+				"templates": map[string]interface{}{
+					"volumeClaimTemplates": volumeClaimTemplates,
+				},
+			},
+		}}
+	}
+	newClient := func(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			clickHouseInstallationResource: "ClickHouseInstallationList",
+		}, objects...)
+	}
+
+	t.Run("PersistentVolumes", func(t *testing.T) {
+		client := newClient(newCHI(map[string]interface{}{"name": "clickhouse-storage-template"}))
+		retained, err := NewInstaller(client, nil, &bytes.Buffer{}).RetainClickHouseData(context.TODO())
+		require.NoError(t, err)
+		assert.True(t, retained)
+		chi, err := client.Resource(clickHouseInstallationResource).Namespace("flow-visibility").Get(context.TODO(), "clickhouse", metav1.GetOptions{})
+		require.NoError(t, err)
+		templates, _, _ := unstructured.NestedSlice(chi.Object, "spec", "templates", "volumeClaimTemplates")
+		assert.Equal(t, []interface{}{map[string]interface{}{"name": "clickhouse-storage-template", "reclaimPolicy": "Retain"}}, templates)
+	})
+
+	t.Run("no PersistentVolumes", func(t *testing.T) {
+		retained, err := NewInstaller(newClient(newCHI()), nil, &bytes.Buffer{}).RetainClickHouseData(context.TODO())
+		require.NoError(t, err)
+		assert.False(t, retained)
+	})
+
+	t.Run("not installed", func(t *testing.T) {
+		retained, err := NewInstaller(newClient(), nil, &bytes.Buffer{}).RetainClickHouseData(context.TODO())
+		require.NoError(t, err)
+		assert.False(t, retained)
+	})
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka publishes messages to Kafka topics, over TLS and with SASL
+// authentication when they are configured.
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	SASLMechanismSCRAMSHA512 = "SCRAM-SHA-512"
+
+	// UsernameEnv and PasswordEnv are the environment variables with the
+	// SASL credentials.
+	UsernameEnv = "KAFKA_USERNAME"
+	PasswordEnv = "KAFKA_PASSWORD"
+
+	dialTimeout = 10 * time.Second
+	// batchTimeout is how long messages are buffered before being sent.
+	// The messages are few, so they are sent right away.
+	batchTimeout = 10 * time.Millisecond
+)
+
+// Config is the configuration of the connection to the Kafka brokers.
+type Config struct {
+	Brokers  []string
+	ClientID string
+	// TLS is nil when the brokers are reached without TLS.
+	TLS *TLSConfig
+	// SASLMechanism is one of PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512, or
+	// empty without SASL authentication. The credentials are read from the
+	// KAFKA_USERNAME and KAFKA_PASSWORD environment variables.
+	SASLMechanism string
+}
+
+// TLSConfig is the TLS configuration of the connection to the brokers. The
+// system CAs are used when CAFile is empty, and the client certificate is only
+// presented when CertFile and KeyFile are set.
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Message is a message published to a topic. Messages with the same key are
+// published to the same partition, so that their order is preserved.
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// Publisher publishes messages to Kafka topics.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, messages ...Message) error
+}
+
+// Producer is a Publisher writing to the brokers of a Config. It is safe for
+// concurrent use.
+type Producer struct {
+	dialer  *kafkago.Dialer
+	brokers []string
+	mutex   sync.Mutex
+	// writers are the writers of each topic, created on first use.
+	writers map[string]*kafkago.Writer
+}
+
+// NewProducer returns a Producer publishing to the brokers of config. The
+// connections to the brokers are established when the first messages are
+// published.
+func NewProducer(config Config) (*Producer, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers are configured")
+	}
+	dialer := &kafkago.Dialer{
+		ClientID:  config.ClientID,
+		Timeout:   dialTimeout,
+		DualStack: true,
+	}
+	if config.TLS != nil {
+		tlsConfig, err := newTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		dialer.TLS = tlsConfig
+	}
+	if config.SASLMechanism != "" {
+		mechanism, err := newSASLMechanism(config.SASLMechanism, os.Getenv(UsernameEnv), os.Getenv(PasswordEnv))
+		if err != nil {
+			return nil, err
+		}
+		dialer.SASLMechanism = mechanism
+	}
+	return &Producer{
+		dialer:  dialer,
+		brokers: config.Brokers,
+		writers: make(map[string]*kafkago.Writer),
+	}, nil
+}
+
+func newTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		caCert, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading the Kafka CA certificate: %v", err)
+		}
+		certPool := x509.NewCertPool()
+		if ok := certPool.AppendCertsFromPEM(caCert); !ok {
+			return nil, fmt.Errorf("no valid certificate in the Kafka CA certificate file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error when loading the Kafka client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func newSASLMechanism(name, username, password string) (sasl.Mechanism, error) {
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("%s and %s must be defined with the %s SASL mechanism", UsernameEnv, PasswordEnv, name)
+	}
+	switch strings.ToUpper(name) {
+	case SASLMechanismPlain:
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case SASLMechanismSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, username, password)
+	case SASLMechanismSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, username, password)
+	}
+	return nil, fmt.Errorf("unsupported SASL mechanism %q, supported mechanisms: %s, %s, %s", name, SASLMechanismPlain, SASLMechanismSCRAMSHA256, SASLMechanismSCRAMSHA512)
+}
+
+func (p *Producer) writer(topic string) *kafkago.Writer {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	writer, ok := p.writers[topic]
+	if !ok {
+		writer = kafkago.NewWriter(kafkago.WriterConfig{
+			Brokers:      p.brokers,
+			Topic:        topic,
+			Dialer:       p.dialer,
+			Balancer:     &kafkago.Hash{},
+			BatchTimeout: batchTimeout,
+		})
+		p.writers[topic] = writer
+	}
+	return writer
+}
+
+// Publish publishes the messages to topic, and returns when they are
+// acknowledged by the brokers.
+func (p *Producer) Publish(ctx context.Context, topic string, messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	kafkaMessages := make([]kafkago.Message, len(messages))
+	for i, message := range messages {
+		kafkaMessages[i] = kafkago.Message{Key: message.Key, Value: message.Value}
+	}
+	if err := p.writer(topic).WriteMessages(ctx, kafkaMessages...); err != nil {
+		return fmt.Errorf("error when publishing %d messages to Kafka topic %s: %v", len(messages), topic, err)
+	}
+	return nil
+}
+
+// Close flushes the pending messages and closes the connections.
+func (p *Producer) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var errs []string
+	for topic, writer := range p.writers {
+		if err := writer.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", topic, err))
+		}
+	}
+	p.writers = make(map[string]*kafkago.Writer)
+	if len(errs) > 0 {
+		return fmt.Errorf("error when closing the Kafka writers: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSASLMechanism(t *testing.T) {
+	for _, name := range []string{"PLAIN", "scram-sha-256", "SCRAM-SHA-512"} {
+		mechanism, err := newSASLMechanism(name, "theia", "secret")
+		require.NoError(t, err, name)
+		assert.Contains(t, []string{SASLMechanismPlain, SASLMechanismSCRAMSHA256, SASLMechanismSCRAMSHA512}, mechanism.Name())
+	}
+	_, err := newSASLMechanism("GSSAPI", "theia", "secret")
+	assert.EqualError(t, err, `unsupported SASL mechanism "GSSAPI", supported mechanisms: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512`)
+	_, err = newSASLMechanism("PLAIN", "", "")
+	assert.EqualError(t, err, "KAFKA_USERNAME and KAFKA_PASSWORD must be defined with the PLAIN SASL mechanism")
+}
+
+func TestNewProducer(t *testing.T) {
+	_, err := NewProducer(Config{})
+	assert.EqualError(t, err, "no Kafka brokers are configured")
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0600))
+	_, err = NewProducer(Config{Brokers: []string{"kafka:9093"}, TLS: &TLSConfig{CAFile: caFile}})
+	assert.EqualError(t, err, "no valid certificate in the Kafka CA certificate file "+caFile)
+
+	producer, err := NewProducer(Config{Brokers: []string{"kafka:9093"}, TLS: &TLSConfig{}})
+	require.NoError(t, err)
+	assert.NotNil(t, producer.dialer.TLS)
+	assert.NoError(t, producer.Close())
+}
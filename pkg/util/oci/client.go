@@ -0,0 +1,394 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oci pushes and pulls artifacts to and from OCI registries, with the
+// layout of the artifacts pushed by ORAS: an image manifest whose config media
+// type is the type of the artifact, and whose layers are annotated with their
+// file names.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// TitleAnnotation is the annotation with the file name of a layer.
+	TitleAnnotation = "org.opencontainers.image.title"
+
+	// maxManifestSize is the maximum size of the manifests pulled, as
+	// recommended by the OCI distribution specification.
+	maxManifestSize = 4 * 1024 * 1024
+)
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Layer is a file of an artifact.
+type Layer struct {
+	MediaType string
+	Title     string
+	Data      []byte
+}
+
+// Artifact is the content of an artifact. Its type is the media type of the
+// config of its manifest, which is an empty JSON object.
+type Artifact struct {
+	ArtifactType string
+	Layers       []Layer
+	Annotations  map[string]string
+}
+
+// Layer returns the first layer with the given media type, or nil.
+func (a *Artifact) Layer(mediaType string) *Layer {
+	for i := range a.Layers {
+		if a.Layers[i].MediaType == mediaType {
+			return &a.Layers[i]
+		}
+	}
+	return nil
+}
+
+// Client is a client for the OCI distribution API. It supports anonymous
+// access, and basic and token authentication with a username and password.
+type Client struct {
+	httpClient *http.Client
+	username   string
+	password   string
+	plainHTTP  bool
+
+	mutex sync.Mutex
+	// tokens are the bearer tokens of each scope.
+	tokens map[string]string
+}
+
+// NewClient returns a client for OCI registries. If plainHTTP is true,
+// registries are accessed with HTTP instead of HTTPS.
+func NewClient(username string, password string, plainHTTP bool) *Client {
+	return &Client{
+		httpClient: http.DefaultClient,
+		username:   username,
+		password:   password,
+		plainHTTP:  plainHTTP,
+		tokens:     make(map[string]string),
+	}
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func (c *Client) baseURL(registry string) string {
+	if c.plainHTTP {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
+
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate handles the authentication challenge of a 401 response, and
+// returns the Authorization header to retry the request with.
+func (c *Client) authenticate(ctx context.Context, resp *http.Response, scope string) (string, error) {
+	challenge := resp.Header.Get("WWW-Authenticate")
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.username == "" {
+			return "", fmt.Errorf("registry requires authentication, but no credentials were provided")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.username+":"+c.password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+	values := map[string]string{}
+	for _, match := range challengeParamRegexp.FindAllStringSubmatch(params, -1) {
+		values[match[1]] = match[2]
+	}
+	if values["realm"] == "" {
+		return "", fmt.Errorf("authentication challenge %q has no realm", challenge)
+	}
+	tokenURL, err := url.Parse(values["realm"])
+	if err != nil {
+		return "", fmt.Errorf("realm of authentication challenge is invalid: %v", err)
+	}
+	query := tokenURL.Query()
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	tokenResp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error when getting registry token: %v", err)
+	}
+	defer tokenResp.Body.Close()
+	if tokenResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error when getting registry token: %s", tokenResp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(tokenResp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error when decoding registry token: %v", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("registry returned an empty token")
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// do sends the request built by newRequest, authenticating with the registry
+// if needed. newRequest is called again to retry the request after
+// authentication, as the body of the first request has been consumed.
+func (c *Client) do(ctx context.Context, scope string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	authorization := c.tokens[scope]
+	c.mutex.Unlock()
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	resp.Body.Close()
+	authorization, err = c.authenticate(ctx, resp, scope)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.tokens[scope] = authorization
+	c.mutex.Unlock()
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+	return c.httpClient.Do(req)
+}
+
+func responseError(resp *http.Response, action string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("error when %s: %s %s", action, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (c *Client) pushBlob(ctx context.Context, ref Reference, scope string, mediaType string, data []byte) (Descriptor, error) {
+	descriptor := Descriptor{MediaType: mediaType, Digest: digestOf(data), Size: int64(len(data))}
+	blobURL := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL(ref.Registry), ref.Repository, descriptor.Digest)
+	resp, err := c.do(ctx, scope, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodHead, blobURL, nil)
+	})
+	if err != nil {
+		return descriptor, fmt.Errorf("error when checking blob %s: %v", descriptor.Digest, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return descriptor, nil
+	}
+
+	uploadsURL := fmt.Sprintf("%s/v2/%s/blobs/uploads/", c.baseURL(ref.Registry), ref.Repository)
+	resp, err = c.do(ctx, scope, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, uploadsURL, nil)
+	})
+	if err != nil {
+		return descriptor, fmt.Errorf("error when starting upload of blob %s: %v", descriptor.Digest, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return descriptor, responseError(resp, "starting upload of blob "+descriptor.Digest)
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return descriptor, fmt.Errorf("upload location of blob %s is invalid: %v", descriptor.Digest, err)
+	}
+	query := location.Query()
+	query.Set("digest", descriptor.Digest)
+	location.RawQuery = query.Encode()
+	resp, err = c.do(ctx, scope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return descriptor, fmt.Errorf("error when uploading blob %s: %v", descriptor.Digest, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return descriptor, responseError(resp, "uploading blob "+descriptor.Digest)
+	}
+	return descriptor, nil
+}
+
+// Push pushes an artifact, and returns the digest of its manifest.
+func (c *Client) Push(ctx context.Context, ref Reference, artifact *Artifact) (string, error) {
+	scope := fmt.Sprintf("repository:%s:pull,push", ref.Repository)
+	config, err := c.pushBlob(ctx, ref, scope, artifact.ArtifactType, []byte("{}"))
+	if err != nil {
+		return "", err
+	}
+	manifest := Manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		Config:        config,
+		Layers:        []Descriptor{},
+		Annotations:   artifact.Annotations,
+	}
+	for _, layer := range artifact.Layers {
+		descriptor, err := c.pushBlob(ctx, ref, scope, layer.MediaType, layer.Data)
+		if err != nil {
+			return "", err
+		}
+		descriptor.Annotations = map[string]string{TitleAnnotation: layer.Title}
+		manifest.Layers = append(manifest.Layers, descriptor)
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(ref.Registry), ref.Repository, ref.Reference)
+	resp, err := c.do(ctx, scope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, manifestURL, bytes.NewReader(manifestData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", ManifestMediaType)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error when pushing manifest: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", responseError(resp, "pushing manifest")
+	}
+	return digestOf(manifestData), nil
+}
+
+func (c *Client) get(ctx context.Context, scope string, url string, accept string, expectedDigest string, maxSize int64) ([]byte, error) {
+	resp, err := c.do(ctx, scope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp, "getting "+url)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, maxSize)
+	}
+	if expectedDigest != "" && digestOf(data) != expectedDigest {
+		return nil, fmt.Errorf("digest of %s does not match %s", url, expectedDigest)
+	}
+	return data, nil
+}
+
+// Pull pulls an artifact. The digests of its manifest, when the reference is
+// a digest, and of its layers are verified.
+func (c *Client) Pull(ctx context.Context, ref Reference) (*Artifact, error) {
+	scope := fmt.Sprintf("repository:%s:pull", ref.Repository)
+	var expectedDigest string
+	if digestRegexp.MatchString(ref.Reference) {
+		expectedDigest = ref.Reference
+	}
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(ref.Registry), ref.Repository, ref.Reference)
+	manifestData, err := c.get(ctx, scope, manifestURL, ManifestMediaType, expectedDigest, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("error when pulling manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("error when decoding manifest: %v", err)
+	}
+	if manifest.MediaType != ManifestMediaType {
+		return nil, fmt.Errorf("unsupported manifest media type %q", manifest.MediaType)
+	}
+	artifact := &Artifact{
+		ArtifactType: manifest.Config.MediaType,
+		Annotations:  manifest.Annotations,
+	}
+	for _, descriptor := range manifest.Layers {
+		if !digestRegexp.MatchString(descriptor.Digest) {
+			return nil, fmt.Errorf("digest %q of layer is invalid", descriptor.Digest)
+		}
+		blobURL := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL(ref.Registry), ref.Repository, descriptor.Digest)
+		data, err := c.get(ctx, scope, blobURL, "", descriptor.Digest, descriptor.Size)
+		if err != nil {
+			return nil, fmt.Errorf("error when pulling layer: %v", err)
+		}
+		artifact.Layers = append(artifact.Layers, Layer{
+			MediaType: descriptor.MediaType,
+			Title:     descriptor.Annotations[TitleAnnotation],
+			Data:      data,
+		})
+	}
+	return artifact, nil
+}
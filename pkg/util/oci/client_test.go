@@ -0,0 +1,156 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistry is an in-memory registry for a single repository, which
+// requires a bearer token obtained with the credentials user/password.
+type fakeRegistry struct {
+	mutex     sync.Mutex
+	server    *httptest.Server
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	r := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	r.server = httptest.NewServer(r)
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if req.URL.Path == "/token" {
+		if username, password, ok := req.BasicAuth(); !ok || username != "user" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "token-" + req.URL.Query().Get("scope")})
+		return
+	}
+	scope := "repository:theia/policies:pull"
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		scope = "repository:theia/policies:pull,push"
+	}
+	if auth := req.Header.Get("Authorization"); auth != "Bearer token-"+scope && auth != "Bearer token-repository:theia/policies:pull,push" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="%s"`, r.server.URL, scope))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	const prefix = "/v2/theia/policies/"
+	path := strings.TrimPrefix(req.URL.Path, prefix)
+	switch {
+	case req.Method == http.MethodHead && strings.HasPrefix(path, "blobs/"):
+		if _, ok := r.blobs[strings.TrimPrefix(path, "blobs/")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case req.Method == http.MethodGet && strings.HasPrefix(path, "blobs/"):
+		data, ok := r.blobs[strings.TrimPrefix(path, "blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case req.Method == http.MethodPost && path == "blobs/uploads/":
+		r.uploads++
+		w.Header().Set("Location", fmt.Sprintf("%sblobs/uploads/%d?state=abc", prefix, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "blobs/uploads/"):
+		data, _ := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		if req.URL.Query().Get("state") != "abc" || digestOf(data) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest] = data
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "manifests/"):
+		data, _ := io.ReadAll(req.Body)
+		r.manifests[strings.TrimPrefix(path, "manifests/")] = data
+		r.manifests[digestOf(data)] = data
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodGet && strings.HasPrefix(path, "manifests/"):
+		data, ok := r.manifests[strings.TrimPrefix(path, "manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ManifestMediaType)
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPushAndPull(t *testing.T) {
+	registry := newFakeRegistry(t)
+	ref, err := ParseReference("oci://" + strings.TrimPrefix(registry.server.URL, "http://") + "/theia/policies:v1")
+	require.NoError(t, err)
+	artifact := &Artifact{
+		ArtifactType: "application/vnd.example.config.v1+json",
+		Layers: []Layer{
+			{MediaType: "application/vnd.example.policies.v1+yaml", Title: "policies.yaml", Data: []byte("kind: NetworkPolicy\n")},
+			{MediaType: "application/vnd.example.signature.v1", Title: "policies.yaml.sig", Data: []byte("c2lnbmF0dXJl")},
+		},
+		Annotations: map[string]string{"io.antrea.theia.recommendation-id": "e998433e-accb-4888-9fc8-06563f073e86"},
+	}
+
+	client := NewClient("user", "password", true)
+	digest, err := client.Push(context.TODO(), ref, artifact)
+	require.NoError(t, err)
+	assert.Len(t, registry.blobs, 3)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests["v1"], &manifest))
+	assert.Equal(t, "application/vnd.example.config.v1+json", manifest.Config.MediaType)
+	assert.Equal(t, "policies.yaml", manifest.Layers[0].Annotations[TitleAnnotation])
+
+	// pushing again only pushes the manifest, the blobs already exist
+	_, err = client.Push(context.TODO(), ref, artifact)
+	require.NoError(t, err)
+	assert.Equal(t, 3, registry.uploads)
+
+	for _, pullRef := range []Reference{ref, {Registry: ref.Registry, Repository: ref.Repository, Reference: digest}} {
+		pulled, err := NewClient("user", "password", true).Pull(context.TODO(), pullRef)
+		require.NoError(t, err)
+		assert.Equal(t, artifact, pulled)
+		assert.Equal(t, []byte("c2lnbmF0dXJl"), pulled.Layer("application/vnd.example.signature.v1").Data)
+		assert.Nil(t, pulled.Layer("application/octet-stream"))
+	}
+
+	_, err = NewClient("user", "wrong", true).Pull(context.TODO(), ref)
+	assert.ErrorContains(t, err, "error when getting registry token: 401 Unauthorized")
+
+	// a layer modified in the registry is detected
+	registry.blobs[manifest.Layers[0].Digest] = []byte("kind: NetworkPolicx\n")
+	_, err = client.Pull(context.TODO(), ref)
+	assert.ErrorContains(t, err, "does not match "+manifest.Layers[0].Digest)
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Scheme is the scheme of the OCI references accepted by the CLI, e.g.
+// oci://registry.example.com/policies:v1.
+const Scheme = "oci://"
+
+var (
+	repositoryRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tagRegexp        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digestRegexp     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// Reference is a reference to an artifact in an OCI registry.
+type Reference struct {
+	Registry   string
+	Repository string
+	// Reference is either a tag or a digest.
+	Reference string
+}
+
+func (r Reference) String() string {
+	separator := ":"
+	if strings.HasPrefix(r.Reference, "sha256:") {
+		separator = "@"
+	}
+	return Scheme + r.Registry + "/" + r.Repository + separator + r.Reference
+}
+
+// ParseReference parses oci://<registry>/<repository>[:<tag>|@<digest>]. The
+// tag defaults to latest.
+func ParseReference(ref string) (Reference, error) {
+	if !strings.HasPrefix(ref, Scheme) {
+		return Reference{}, fmt.Errorf("OCI reference %q should start with %s", ref, Scheme)
+	}
+	name := strings.TrimPrefix(ref, Scheme)
+	registry, path, ok := strings.Cut(name, "/")
+	if !ok || registry == "" {
+		return Reference{}, fmt.Errorf("OCI reference %q should be %s<registry>/<repository>[:<tag>|@<digest>]", ref, Scheme)
+	}
+	result := Reference{Registry: registry, Reference: "latest"}
+	if repository, digest, ok := strings.Cut(path, "@"); ok {
+		if !digestRegexp.MatchString(digest) {
+			return Reference{}, fmt.Errorf("digest %q of OCI reference is invalid", digest)
+		}
+		path, result.Reference = repository, digest
+	} else if i := strings.LastIndex(path, ":"); i >= 0 {
+		tag := path[i+1:]
+		if !tagRegexp.MatchString(tag) {
+			return Reference{}, fmt.Errorf("tag %q of OCI reference is invalid", tag)
+		}
+		path, result.Reference = path[:i], tag
+	}
+	if !repositoryRegexp.MatchString(path) {
+		return Reference{}, fmt.Errorf("repository %q of OCI reference is invalid", path)
+	}
+	result.Repository = path
+	return result, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testCases := []struct {
+		ref         string
+		expectedRef Reference
+		expectedErr string
+	}{
+		{
+			ref:         "oci://registry.example.com/theia/policies:v1",
+			expectedRef: Reference{Registry: "registry.example.com", Repository: "theia/policies", Reference: "v1"},
+		},
+		{
+			ref:         "oci://localhost:5000/policies",
+			expectedRef: Reference{Registry: "localhost:5000", Repository: "policies", Reference: "latest"},
+		},
+		{
+			ref:         "oci://localhost:5000/policies@" + digest,
+			expectedRef: Reference{Registry: "localhost:5000", Repository: "policies", Reference: digest},
+		},
+		{
+			ref:         "registry.example.com/policies:v1",
+			expectedErr: "should start with oci://",
+		},
+		{
+			ref:         "oci://registry.example.com",
+			expectedErr: "should be oci://<registry>/<repository>[:<tag>|@<digest>]",
+		},
+		{
+			ref:         "oci://registry.example.com/Policies:v1",
+			expectedErr: "repository \"Policies\" of OCI reference is invalid",
+		},
+		{
+			ref:         "oci://registry.example.com/policies:v1?",
+			expectedErr: "tag \"v1?\" of OCI reference is invalid",
+		},
+		{
+			ref:         "oci://registry.example.com/policies@sha256:1234",
+			expectedErr: "digest \"sha256:1234\" of OCI reference is invalid",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, err := ParseReference(tt.ref)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRef, ref)
+			if tt.expectedRef.Reference != "latest" {
+				assert.Equal(t, tt.ref, ref.String())
+			}
+		})
+	}
+}
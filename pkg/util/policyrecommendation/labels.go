@@ -0,0 +1,45 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PodLabelSelectorArgs returns the --pod_label_selector argument of the Spark
+// job restricting the recommendation to the Pods matching the label selector
+// given by the flag name, or no argument if the selector is empty. The
+// selector is passed to the job as a JSON LabelSelector.
+func PodLabelSelectorArgs(name, selector string) ([]string, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return nil, fmt.Errorf("%s should be a label selector, e.g. app=web: %v", name, err)
+	}
+	labelSelector, err := metav1.ParseToLabelSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("%s should be a label selector, e.g. app=web: %v", name, err)
+	}
+	data, err := json.Marshal(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"--pod_label_selector", string(data)}, nil
+}
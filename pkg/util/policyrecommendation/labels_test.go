@@ -0,0 +1,38 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodLabelSelectorArgs(t *testing.T) {
+	args, err := PodLabelSelectorArgs("pod-label-selector", "")
+	require.NoError(t, err)
+	assert.Empty(t, args)
+
+	args, err = PodLabelSelectorArgs("pod-label-selector", "app=web,tier in (frontend,backend),!canary")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"--pod_label_selector",
+		`{"matchLabels":{"app":"web"},"matchExpressions":[{"key":"canary","operator":"DoesNotExist"},{"key":"tier","operator":"In","values":["backend","frontend"]}]}`,
+	}, args)
+
+	_, err = PodLabelSelectorArgs("pod-label-selector", "app==web==x")
+	assert.ErrorContains(t, err, "pod-label-selector should be a label selector, e.g. app=web")
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"antrea.io/theia/pkg/util/executor"
+)
+
+// Manifest records the effective parameters of a policy recommendation job,
+// and the versions of its code and of its input data, so that the job can be
+// rerun identically later.
+type Manifest struct {
+	ID          string    `json:"id"`
+	TimeCreated time.Time `json:"timeCreated"`
+	Backend     string    `json:"backend"`
+	// Parameters are the effective values of the flags of the command which
+	// submitted the job.
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// JobArgs are the arguments of the job, with relative time ranges
+	// resolved to absolute ones.
+	JobArgs         []string           `json:"jobArgs"`
+	Resources       executor.Resources `json:"resources"`
+	ArtifactsSecret string             `json:"artifactsSecret,omitempty"`
+	// Image is the image of the job, as given to the backend.
+	Image string `json:"image"`
+	// ImageDigest is the digest of the image which ran the job, e.g.
+	// sha256:..., once known.
+	ImageDigest       string `json:"imageDigest,omitempty"`
+	FlowSchemaVersion int    `json:"flowSchemaVersion"`
+	// DataWatermark is the insertion time of the latest flow record when the
+	// job was submitted.
+	DataWatermark time.Time `json:"dataWatermark,omitempty"`
+}
+
+// LoadManifest reads a manifest from a JSON file.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error when parsing manifest %s: %v", path, err)
+	}
+	if len(manifest.JobArgs) == 0 {
+		return nil, fmt.Errorf("manifest %s has no job arguments", path)
+	}
+	return &manifest, nil
+}
+
+// PinnedImage returns the image of the job, pinned to its digest if it is
+// known.
+func (m *Manifest) PinnedImage() string {
+	if m.ImageDigest == "" || m.Image == "" {
+		return m.Image
+	}
+	repository := m.Image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository = repository[:i]
+	} else if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	return repository + "@" + m.ImageDigest
+}
+
+// Request returns the request to rerun the job with a new ID, with the same
+// arguments, resources and image.
+func (m *Manifest) Request(id string) *executor.Request {
+	args := make([]string, 0, len(m.JobArgs))
+	for i := 0; i < len(m.JobArgs); i++ {
+		if m.JobArgs[i] == "--id" && i+1 < len(m.JobArgs) {
+			i++
+			continue
+		}
+		args = append(args, m.JobArgs[i])
+	}
+	resources := m.Resources
+	resources.Image = m.PinnedImage()
+	return &executor.Request{
+		ID:              id,
+		Args:            append(args, "--id", id),
+		Resources:       resources,
+		ArtifactsSecret: m.ArtifactsSecret,
+	}
+}
+
+// ImageDigest returns the digest of the image ID reported in the status of a
+// container, or an empty string if it has no digest, e.g. for images which
+// were built locally.
+func ImageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	return ""
+}
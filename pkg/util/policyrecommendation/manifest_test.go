@@ -0,0 +1,82 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/pkg/util/executor"
+)
+
+func TestPinnedImage(t *testing.T) {
+	digest := "sha256:0123456789abcdef"
+	testCases := []struct {
+		image         string
+		digest        string
+		expectedImage string
+	}{
+		{"projects.registry.vmware.com/antrea/theia-policy-recommendation:latest", "", "projects.registry.vmware.com/antrea/theia-policy-recommendation:latest"},
+		{"projects.registry.vmware.com/antrea/theia-policy-recommendation:latest", digest, "projects.registry.vmware.com/antrea/theia-policy-recommendation@" + digest},
+		{"localhost:5000/theia-policy-recommendation", digest, "localhost:5000/theia-policy-recommendation@" + digest},
+		{"theia-policy-recommendation@sha256:fedcba", digest, "theia-policy-recommendation@" + digest},
+	}
+	for _, tt := range testCases {
+		manifest := &Manifest{Image: tt.image, ImageDigest: tt.digest}
+		assert.Equal(t, tt.expectedImage, manifest.PinnedImage())
+	}
+}
+
+func TestManifestRequest(t *testing.T) {
+	manifest := &Manifest{
+		ID:              "e998433e-accb-4888-9fc8-06563f073e86",
+		JobArgs:         []string{"--type", "initial", "--id", "e998433e-accb-4888-9fc8-06563f073e86", "--to_services", "true"},
+		Resources:       executor.Resources{ExecutorInstances: 2, DriverMemory: "1G"},
+		ArtifactsSecret: "theia-artifacts",
+		Image:           "theia-policy-recommendation:latest",
+		ImageDigest:     "sha256:0123",
+	}
+	request := manifest.Request("f998433e-accb-4888-9fc8-06563f073e86")
+	assert.Equal(t, &executor.Request{
+		ID:              "f998433e-accb-4888-9fc8-06563f073e86",
+		Args:            []string{"--type", "initial", "--to_services", "true", "--id", "f998433e-accb-4888-9fc8-06563f073e86"},
+		Resources:       executor.Resources{ExecutorInstances: 2, DriverMemory: "1G", Image: "theia-policy-recommendation@sha256:0123"},
+		ArtifactsSecret: "theia-artifacts",
+	}, request)
+}
+
+func TestImageDigest(t *testing.T) {
+	assert.Equal(t, "sha256:0123", ImageDigest("docker-pullable://projects.registry.vmware.com/antrea/theia-policy-recommendation@sha256:0123"))
+	assert.Equal(t, "sha256:0123", ImageDigest("docker.io/library/theia@sha256:0123"))
+	assert.Equal(t, "", ImageDigest("sha256:0123"))
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id": "e998433e", "backend": "k8s-job", "jobArgs": ["--type", "initial"], "resources": {"driverMemory": "1G"}}`), 0600))
+	manifest, err := LoadManifest(path)
+	require.NoError(t, err)
+	assert.Equal(t, "k8s-job", manifest.Backend)
+	assert.Equal(t, "1G", manifest.Resources.DriverMemory)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"id": "e998433e"}`), 0600))
+	_, err = LoadManifest(path)
+	assert.ErrorContains(t, err, "has no job arguments")
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+// TargetNamespaces restricts a policy recommendation job to the workloads of
+// some Namespaces: only the flows from or to these workloads are considered,
+// and only the policies applying to them are recommended. Denied Namespaces
+// take precedence over allowed ones.
+type TargetNamespaces struct {
+	// Allow, if not empty, is the list of targeted Namespaces.
+	Allow []string `json:"allow,omitempty"`
+	// Deny is the list of Namespaces which are not targeted.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// ParseTargetNamespaces returns the target Namespaces given by the values of
+// the flag name. Namespaces prefixed with "!" are denied, the others are
+// allowed.
+func ParseTargetNamespaces(name string, values []string) (*TargetNamespaces, error) {
+	allow := sets.NewString()
+	deny := sets.NewString()
+	for _, value := range values {
+		namespace := strings.TrimPrefix(value, "!")
+		if errs := k8svalidation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return nil, fmt.Errorf("%s should be a list of Namespaces, optionally prefixed with \"!\", invalid Namespace %q: %s", name, namespace, strings.Join(errs, ", "))
+		}
+		if namespace != value {
+			deny.Insert(namespace)
+		} else {
+			allow.Insert(namespace)
+		}
+	}
+	if both := allow.Intersection(deny); both.Len() > 0 {
+		return nil, fmt.Errorf("%s both allows and denies the Namespaces %v", name, both.List())
+	}
+	return &TargetNamespaces{Allow: allow.List(), Deny: deny.List()}, nil
+}
+
+// IsEmpty returns true if all Namespaces are targeted.
+func (t *TargetNamespaces) IsEmpty() bool {
+	return t == nil || (len(t.Allow) == 0 && len(t.Deny) == 0)
+}
+
+// Args returns the --target_namespaces argument of the Spark job, or no
+// argument if all Namespaces are targeted.
+func (t *TargetNamespaces) Args() ([]string, error) {
+	if t.IsEmpty() {
+		return nil, nil
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"--target_namespaces", string(data)}, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetNamespaces(t *testing.T) {
+	targets, err := ParseTargetNamespaces("target-namespaces", nil)
+	require.NoError(t, err)
+	assert.True(t, targets.IsEmpty())
+	args, err := targets.Args()
+	require.NoError(t, err)
+	assert.Empty(t, args)
+
+	targets, err = ParseTargetNamespaces("target-namespaces", []string{"app-b", "!kube-system", "app-a", "app-b"})
+	require.NoError(t, err)
+	assert.Equal(t, &TargetNamespaces{Allow: []string{"app-a", "app-b"}, Deny: []string{"kube-system"}}, targets)
+	args, err = targets.Args()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--target_namespaces", `{"allow":["app-a","app-b"],"deny":["kube-system"]}`}, args)
+
+	targets, err = ParseTargetNamespaces("target-namespaces", []string{"!monitoring"})
+	require.NoError(t, err)
+	args, err = targets.Args()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--target_namespaces", `{"deny":["monitoring"]}`}, args)
+
+	_, err = ParseTargetNamespaces("target-namespaces", []string{"App"})
+	assert.ErrorContains(t, err, `target-namespaces should be a list of Namespaces, optionally prefixed with "!", invalid Namespace "App"`)
+	_, err = ParseTargetNamespaces("target-namespaces", []string{"app-a", "!app-a"})
+	assert.EqualError(t, err, "target-namespaces both allows and denies the Namespaces [app-a]")
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// ResultQuery selects the parts of the result of a policy recommendation job.
+// The job writes the recommended policies of each Namespace in separate parts,
+// the cluster-scoped ones having an empty Namespace, and splits them further
+// when its write batch size is set. The recommendations table is sorted by job
+// ID and Namespace, so that reading the result of a job, or its policies in
+// some Namespaces, does not scan the whole table.
+const ResultQuery = "SELECT yamls FROM recommendations WHERE id = (?) ORDER BY namespace, part;"
+
+// namespacedResultQuery returns the query selecting the parts of the result
+// of a policy recommendation job in the given number of Namespaces, and the
+// cluster-scoped ones.
+func namespacedResultQuery(namespaces int) string {
+	return "SELECT yamls FROM recommendations WHERE id = (?) AND namespace IN (''" +
+		strings.Repeat(", ?", namespaces) + ") ORDER BY namespace, part;"
+}
+
+// QueryResult returns the recommended policies of a policy recommendation job,
+// joining the parts of its result. When namespaces are provided, only the
+// policies in these Namespaces and the cluster-scoped ones are returned. It
+// returns sql.ErrNoRows if the result of the job is not found.
+func QueryResult(ctx context.Context, db *sql.DB, id string, namespaces []string) (string, error) {
+	query := ResultQuery
+	args := []interface{}{id}
+	if len(namespaces) > 0 {
+		query = namespacedResultQuery(len(namespaces))
+		for _, namespace := range namespaces {
+			args = append(args, namespace)
+		}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var parts []string
+	found := false
+	for rows.Next() {
+		var part string
+		if err := rows.Scan(&part); err != nil {
+			return "", err
+		}
+		found = true
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if !found {
+		return "", sql.ErrNoRows
+	}
+	return strings.Join(parts, "---\n"), nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryResult(t *testing.T) {
+	testCases := []struct {
+		name           string
+		namespaces     []string
+		parts          []string
+		expectedResult string
+		expectedErr    error
+	}{
+		{
+			name:           "single part",
+			parts:          []string{"kind: A\n---\nkind: B\n"},
+			expectedResult: "kind: A\n---\nkind: B\n",
+		},
+		{
+			name:           "several parts",
+			parts:          []string{"kind: A\n---\nkind: B\n", "kind: C\n"},
+			expectedResult: "kind: A\n---\nkind: B\n---\nkind: C\n",
+		},
+		{
+			name:           "empty result",
+			parts:          []string{""},
+			expectedResult: "",
+		},
+		{
+			name:           "namespaces",
+			namespaces:     []string{"ns-a", "ns-b"},
+			parts:          []string{"kind: ClusterNetworkPolicy\n", "kind: NetworkPolicy\n"},
+			expectedResult: "kind: ClusterNetworkPolicy\n---\nkind: NetworkPolicy\n",
+		},
+		{
+			name:        "no result",
+			expectedErr: sql.ErrNoRows,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+			rows := sqlmock.NewRows([]string{"yamls"})
+			for _, part := range tc.parts {
+				rows.AddRow(part)
+			}
+			if len(tc.namespaces) > 0 {
+				mock.ExpectQuery("SELECT yamls FROM recommendations WHERE id = (?) AND namespace IN ('', ?, ?) ORDER BY namespace, part;").
+					WithArgs("id-1", "ns-a", "ns-b").WillReturnRows(rows)
+			} else {
+				mock.ExpectQuery(ResultQuery).WithArgs("id-1").WillReturnRows(rows)
+			}
+			result, err := QueryResult(context.TODO(), db, "id-1", tc.namespaces)
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedResult, result)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
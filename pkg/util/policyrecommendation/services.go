@@ -0,0 +1,75 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IsEndpointService returns whether the traffic of a Service may reach its
+// endpoints without going through its ClusterIP, in which case toServices
+// rules, which only match the traffic to the ClusterIP, would not allow it:
+//   - the clients of headless Services connect to the endpoints directly.
+//   - external traffic to NodePort and LoadBalancer Services with the Local
+//     externalTrafficPolicy is delivered to the endpoints on the Node which
+//     received it, without being load-balanced to the ClusterIP.
+func IsEndpointService(svc *v1.Service) bool {
+	if svc.Spec.ClusterIP == v1.ClusterIPNone {
+		return true
+	}
+	switch svc.Spec.Type {
+	case v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer:
+		return svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal
+	}
+	return false
+}
+
+// EndpointServices returns the sorted <namespace>/<name> of the Services of the
+// cluster for which endpoint rules are recommended instead of toServices
+// rules.
+func EndpointServices(ctx context.Context, clientset kubernetes.Interface) ([]string, error) {
+	services, err := clientset.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Services: %v", err)
+	}
+	var endpointServices []string
+	for i := range services.Items {
+		if IsEndpointService(&services.Items[i]) {
+			endpointServices = append(endpointServices, services.Items[i].Namespace+"/"+services.Items[i].Name)
+		}
+	}
+	sort.Strings(endpointServices)
+	return endpointServices, nil
+}
+
+// EndpointServicesArgs returns the --endpoint_svcs argument of the Spark job
+// for the given Services, or no argument if there are none.
+func EndpointServicesArgs(endpointServices []string) ([]string, error) {
+	if len(endpointServices) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(endpointServices)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"--endpoint_svcs", string(data)}, nil
+}
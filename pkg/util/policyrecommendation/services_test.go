@@ -0,0 +1,50 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEndpointServices(t *testing.T) {
+	service := func(name string, spec v1.ServiceSpec) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "antrea-test"}, Spec: spec}
+	}
+	clientset := fake.NewSimpleClientset(
+		service("clusterip", v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, ClusterIP: "10.96.0.10"}),
+		service("headless", v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, ClusterIP: v1.ClusterIPNone}),
+		service("nodeport-cluster", v1.ServiceSpec{Type: v1.ServiceTypeNodePort, ClusterIP: "10.96.0.11", ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster}),
+		service("nodeport-local", v1.ServiceSpec{Type: v1.ServiceTypeNodePort, ClusterIP: "10.96.0.12", ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal}),
+		service("lb-local", v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, ClusterIP: "10.96.0.13", ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal}),
+	)
+	services, err := EndpointServices(context.TODO(), clientset)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"antrea-test/headless", "antrea-test/lb-local", "antrea-test/nodeport-local"}, services)
+
+	args, err := EndpointServicesArgs(services)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--endpoint_svcs", `["antrea-test/headless","antrea-test/lb-local","antrea-test/nodeport-local"]`}, args)
+
+	args, err = EndpointServicesArgs(nil)
+	require.NoError(t, err)
+	assert.Empty(t, args)
+}
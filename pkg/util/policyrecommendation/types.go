@@ -0,0 +1,190 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policyrecommendation defines the types of policy recommendation
+// jobs and of generated NetworkPolicies, shared by the CLI, theia-manager and
+// the builders of the arguments of the policy recommendation Spark job.
+package policyrecommendation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// RecommendationType is the type of a policy recommendation job, given by the
+// --type argument of the Spark job.
+type RecommendationType string
+
+const (
+	// RecommendationTypeInitial recommends policies from scratch.
+	RecommendationTypeInitial RecommendationType = "initial"
+	// RecommendationTypeSubsequent recommends policies on top of the
+	// policies which are already applied.
+	RecommendationTypeSubsequent RecommendationType = "subsequent"
+
+	DefaultRecommendationType = RecommendationTypeInitial
+)
+
+// RecommendationTypes are the supported recommendation types.
+var RecommendationTypes = []RecommendationType{RecommendationTypeInitial, RecommendationTypeSubsequent}
+
+// ParseRecommendationType parses a recommendation type, case-insensitively.
+// An empty string is the default recommendation type.
+func ParseRecommendationType(name string, value string) (RecommendationType, error) {
+	if value == "" {
+		return DefaultRecommendationType, nil
+	}
+	options := make([]string, len(RecommendationTypes))
+	for i, t := range RecommendationTypes {
+		if strings.EqualFold(value, string(t)) {
+			return t, nil
+		}
+		options[i] = string(t)
+	}
+	return "", validation.OneOf(name, value, options...)
+}
+
+// PolicyType is the type of the NetworkPolicies generated by a policy
+// recommendation job. It is given to the Spark job as a numeric --option
+// argument.
+type PolicyType string
+
+const (
+	PolicyTypeANPDenyApplied PolicyType = "anp-deny-applied"
+	PolicyTypeANPDenyAll     PolicyType = "anp-deny-all"
+	PolicyTypeK8sNP          PolicyType = "k8s-np"
+
+	DefaultPolicyType = PolicyTypeANPDenyApplied
+)
+
+// policyTypeInfo describes a policy type supported by the Spark job.
+type policyTypeInfo struct {
+	policyType  PolicyType
+	option      int
+	description string
+	toServices  bool
+}
+
+// policyTypes is the registry of the supported policy types, in the order in
+// which they are documented. A new isolation option of the Spark job is
+// supported by all the commands once it is added here.
+var policyTypes = []policyTypeInfo{
+	{
+		policyType:  PolicyTypeANPDenyApplied,
+		option:      1,
+		description: "Recommending allow ANP/ACNP policies, with default deny rules only on Pods which have an allow rule applied.",
+		toServices:  true,
+	},
+	{
+		policyType:  PolicyTypeANPDenyAll,
+		option:      2,
+		description: "Recommending allow ANP/ACNP policies, with default deny rules for whole cluster.",
+		toServices:  true,
+	},
+	{
+		policyType:  PolicyTypeK8sNP,
+		option:      3,
+		description: "Recommending allow K8s NetworkPolicies.",
+	},
+}
+
+func (t PolicyType) info() *policyTypeInfo {
+	for i := range policyTypes {
+		if policyTypes[i].policyType == t {
+			return &policyTypes[i]
+		}
+	}
+	return nil
+}
+
+// PolicyTypes returns the supported policy types.
+func PolicyTypes() []PolicyType {
+	types := make([]PolicyType, len(policyTypes))
+	for i, info := range policyTypes {
+		types[i] = info.policyType
+	}
+	return types
+}
+
+// ParsePolicyType parses a policy type. An empty string is the default policy
+// type.
+func ParsePolicyType(name string, value string) (PolicyType, error) {
+	if value == "" {
+		return DefaultPolicyType, nil
+	}
+	if info := PolicyType(value).info(); info != nil {
+		return info.policyType, nil
+	}
+	options := make([]string, len(policyTypes))
+	for i, info := range policyTypes {
+		options[i] = string(info.policyType)
+	}
+	return "", validation.OneOf(name, value, options...)
+}
+
+// Option returns the value of the --option argument of the Spark job for the
+// policy type, or 0 if the policy type is not supported.
+func (t PolicyType) Option() int {
+	if info := t.info(); info != nil {
+		return info.option
+	}
+	return 0
+}
+
+// OptionArg returns the value of the --option argument of the Spark job.
+func (t PolicyType) OptionArg() string {
+	return strconv.Itoa(t.Option())
+}
+
+// SupportsToServices returns whether the generated policies can have
+// toServices rules.
+func (t PolicyType) SupportsToServices() bool {
+	info := t.info()
+	return info != nil && info.toServices
+}
+
+// ToServicesPolicyTypesHelp returns the policy types supporting toServices
+// rules, as "a or b".
+func ToServicesPolicyTypesHelp() string {
+	var types []string
+	for _, info := range policyTypes {
+		if info.toServices {
+			types = append(types, string(info.policyType))
+		}
+	}
+	return strings.Join(types, " or ")
+}
+
+// PolicyTypeHelp returns the help text of the flags selecting a policy type.
+func PolicyTypeHelp() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Types of generated NetworkPolicy.\nCurrently we have %d generated NetworkPolicy types:", len(policyTypes))
+	for _, info := range policyTypes {
+		fmt.Fprintf(&b, "\n%s: %s", info.policyType, info.description)
+	}
+	return b.String()
+}
+
+// RecommendationTypeHelp returns the help text of the flags selecting a
+// recommendation type.
+func RecommendationTypeHelp() string {
+	options := make([]string, len(RecommendationTypes))
+	for i, t := range RecommendationTypes {
+		options[i] = string(t)
+	}
+	return fmt.Sprintf("{%s} Indicates this recommendation is an initial recommendion or a subsequent recommendation job.", strings.Join(options, "|"))
+}
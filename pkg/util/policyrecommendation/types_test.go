@@ -0,0 +1,69 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRecommendationType(t *testing.T) {
+	for value, expected := range map[string]RecommendationType{
+		"":           RecommendationTypeInitial,
+		"initial":    RecommendationTypeInitial,
+		"Subsequent": RecommendationTypeSubsequent,
+	} {
+		recoType, err := ParseRecommendationType("type", value)
+		require.NoError(t, err)
+		assert.Equal(t, expected, recoType)
+	}
+	_, err := ParseRecommendationType("type", "periodic")
+	assert.EqualError(t, err, "type should be initial or subsequent")
+}
+
+func TestParsePolicyType(t *testing.T) {
+	testCases := []struct {
+		value              string
+		expectedPolicyType PolicyType
+		expectedOption     string
+		expectedToServices bool
+	}{
+		{value: "", expectedPolicyType: PolicyTypeANPDenyApplied, expectedOption: "1", expectedToServices: true},
+		{value: "anp-deny-all", expectedPolicyType: PolicyTypeANPDenyAll, expectedOption: "2", expectedToServices: true},
+		{value: "k8s-np", expectedPolicyType: PolicyTypeK8sNP, expectedOption: "3"},
+	}
+	for _, tt := range testCases {
+		policyType, err := ParsePolicyType("policy-type", tt.value)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expectedPolicyType, policyType)
+		assert.Equal(t, tt.expectedOption, policyType.OptionArg())
+		assert.Equal(t, tt.expectedToServices, policyType.SupportsToServices())
+	}
+	_, err := ParsePolicyType("policy-type", "k8s-nps")
+	assert.EqualError(t, err, `policy-type should be anp-deny-applied, anp-deny-all or k8s-np, did you mean "k8s-np"?`)
+	assert.Equal(t, 0, PolicyType("acnp").Option())
+}
+
+func TestHelp(t *testing.T) {
+	assert.Equal(t, `Types of generated NetworkPolicy.
+Currently we have 3 generated NetworkPolicy types:
+anp-deny-applied: Recommending allow ANP/ACNP policies, with default deny rules only on Pods which have an allow rule applied.
+anp-deny-all: Recommending allow ANP/ACNP policies, with default deny rules for whole cluster.
+k8s-np: Recommending allow K8s NetworkPolicies.`, PolicyTypeHelp())
+	assert.Equal(t, "anp-deny-applied or anp-deny-all", ToServicesPolicyTypesHelp())
+	assert.Equal(t, "{initial|subsequent} Indicates this recommendation is an initial recommendion or a subsequent recommendation job.", RecommendationTypeHelp())
+}
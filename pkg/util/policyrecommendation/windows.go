@@ -0,0 +1,83 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"antrea.io/theia/pkg/util/validation"
+)
+
+// WindowsCompatibility is the Windows compatibility mode of a policy
+// recommendation job. In compatibility mode, the job skips the rule features
+// which the Antrea Agents running on Windows Nodes cannot enforce.
+type WindowsCompatibility string
+
+const (
+	// WindowsCompatibilityAuto enables the compatibility mode if the cluster
+	// has Windows Nodes.
+	WindowsCompatibilityAuto     WindowsCompatibility = "auto"
+	WindowsCompatibilityEnabled  WindowsCompatibility = "enabled"
+	WindowsCompatibilityDisabled WindowsCompatibility = "disabled"
+)
+
+// WindowsLimitations describes the changes made to the recommended policies
+// in compatibility mode.
+const WindowsLimitations = "default deny rules drop traffic instead of rejecting it, and Pod-to-Service traffic is " +
+	"allowed with ClusterGroups instead of toServices rules"
+
+// ParseWindowsCompatibility returns the Windows compatibility mode given by
+// the value of the flag or field name.
+func ParseWindowsCompatibility(name string, value string) (WindowsCompatibility, error) {
+	if err := validation.OneOf(name, value, string(WindowsCompatibilityAuto), string(WindowsCompatibilityEnabled), string(WindowsCompatibilityDisabled)); err != nil {
+		return "", err
+	}
+	return WindowsCompatibility(value), nil
+}
+
+// Enabled returns whether the compatibility mode is enabled in a cluster with
+// the given Windows Nodes.
+func (c WindowsCompatibility) Enabled(windowsNodes []string) bool {
+	return c == WindowsCompatibilityEnabled || (c == WindowsCompatibilityAuto && len(windowsNodes) > 0)
+}
+
+// WindowsNodes returns the sorted names of the Windows Nodes of the cluster.
+func WindowsNodes(ctx context.Context, clientset kubernetes.Interface) ([]string, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: v1.LabelOSStable + "=windows"})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Windows Nodes: %v", err)
+	}
+	var names []string
+	for _, node := range nodes.Items {
+		names = append(names, node.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// WindowsCompatibilityArgs returns the --windows_compat argument of the Spark
+// job, or no argument if the compatibility mode is disabled.
+func WindowsCompatibilityArgs(enabled bool) []string {
+	if !enabled {
+		return nil
+	}
+	return []string{"--windows_compat", "true"}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyrecommendation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWindowsCompatibility(t *testing.T) {
+	node := func(name string, os string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{v1.LabelOSStable: os}}}
+	}
+	clientset := fake.NewSimpleClientset(node("worker-1", "linux"), node("win-2", "windows"), node("win-1", "windows"))
+	windowsNodes, err := WindowsNodes(context.TODO(), clientset)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"win-1", "win-2"}, windowsNodes)
+
+	testCases := []struct {
+		compatibility WindowsCompatibility
+		windowsNodes  []string
+		expected      bool
+	}{
+		{WindowsCompatibilityAuto, windowsNodes, true},
+		{WindowsCompatibilityAuto, nil, false},
+		{WindowsCompatibilityEnabled, nil, true},
+		{WindowsCompatibilityDisabled, windowsNodes, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.compatibility.Enabled(tc.windowsNodes), "%s with %d Windows Nodes", tc.compatibility, len(tc.windowsNodes))
+	}
+
+	_, err = ParseWindowsCompatibility("windows-compatibility", "enable")
+	assert.EqualError(t, err, `windows-compatibility should be auto, enabled or disabled, did you mean "enabled"?`)
+	assert.Equal(t, []string{"--windows_compat", "true"}, WindowsCompatibilityArgs(true))
+	assert.Empty(t, WindowsCompatibilityArgs(false))
+}
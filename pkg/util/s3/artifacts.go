@@ -0,0 +1,117 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// GCSEndpoint is the endpoint of the S3-compatible XML API of Google Cloud
+// Storage, used for gs:// URIs.
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// ArtifactsLocation is where the artifacts of the policy recommendation jobs
+// are uploaded: the artifacts of a job are stored under <Prefix>/<job ID>/.
+type ArtifactsLocation struct {
+	Bucket   string
+	Prefix   string
+	Endpoint string
+}
+
+// ParseArtifactsURI parses an s3://<bucket>/<prefix> or gs://<bucket>/<prefix>
+// URI. Google Cloud Storage is accessed through its S3-compatible API, unless
+// another endpoint is provided.
+func ParseArtifactsURI(uri string, endpoint string) (*ArtifactsLocation, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("artifacts URI is invalid: %v", err)
+	}
+	if (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return nil, fmt.Errorf("artifacts URI should be s3://<bucket>/<prefix> or gs://<bucket>/<prefix>")
+	}
+	if endpoint == "" && u.Scheme == "gs" {
+		endpoint = GCSEndpoint
+	}
+	return &ArtifactsLocation{
+		Bucket:   u.Host,
+		Prefix:   strings.Trim(u.Path, "/"),
+		Endpoint: endpoint,
+	}, nil
+}
+
+// JobPrefix returns the key prefix of the artifacts of a job.
+func (l *ArtifactsLocation) JobPrefix(id string) string {
+	if l.Prefix == "" {
+		return id + "/"
+	}
+	return l.Prefix + "/" + id + "/"
+}
+
+// ListJobArtifacts returns the artifacts of a job, sorted by key.
+func ListJobArtifacts(ctx context.Context, client Interface, location *ArtifactsLocation, id string) ([]s3types.Object, error) {
+	prefix := location.JobPrefix(id)
+	var objects []s3types.Object
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: &location.Bucket,
+		Prefix: &prefix,
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error when listing objects in bucket %s: %v", location.Bucket, err)
+		}
+		objects = append(objects, output.Contents...)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return *objects[i].Key < *objects[j].Key
+	})
+	return objects, nil
+}
+
+// DownloadObject downloads an object to a local file, and returns the number
+// of bytes written.
+func DownloadObject(ctx context.Context, client Interface, bucket string, key string, path string) (int64, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error when getting object %s from bucket %s: %v", key, bucket, err)
+	}
+	defer output.Body.Close()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	n, err := io.Copy(file, output.Body)
+	if err != nil {
+		return n, fmt.Errorf("error when downloading object %s: %v", key, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient serves the objects of a single bucket, with a page size of 1 to
+// exercise pagination.
+type fakeClient struct {
+	objects map[string]string
+}
+
+func (c *fakeClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	content, ok := c.objects[*params.Key]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+}
+
+func (c *fakeClient) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range c.objects {
+		if strings.HasPrefix(key, *params.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	// return the keys in reverse order, they should be sorted by the caller
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	start := 0
+	if params.ContinuationToken != nil {
+		fmt.Sscanf(*params.ContinuationToken, "%d", &start)
+	}
+	output := &s3.ListObjectsV2Output{}
+	if start < len(keys) {
+		key := keys[start]
+		output.Contents = []s3types.Object{{Key: &key, Size: int64(len(c.objects[key]))}}
+	}
+	if start+1 < len(keys) {
+		token := fmt.Sprintf("%d", start+1)
+		output.IsTruncated = true
+		output.NextContinuationToken = &token
+	}
+	return output, nil
+}
+
+func (c *fakeClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	content, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.objects[*params.Key] = string(content)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestParseArtifactsURI(t *testing.T) {
+	testCases := []struct {
+		name             string
+		uri              string
+		endpoint         string
+		expectedLocation *ArtifactsLocation
+		expectedErr      string
+	}{
+		{
+			name:             "s3 URI",
+			uri:              "s3://my-bucket/theia/artifacts/",
+			expectedLocation: &ArtifactsLocation{Bucket: "my-bucket", Prefix: "theia/artifacts"},
+		},
+		{
+			name:             "s3 URI with endpoint",
+			uri:              "s3://my-bucket",
+			endpoint:         "http://minio.minio.svc:9000",
+			expectedLocation: &ArtifactsLocation{Bucket: "my-bucket", Endpoint: "http://minio.minio.svc:9000"},
+		},
+		{
+			name:             "gs URI",
+			uri:              "gs://my-bucket/artifacts",
+			expectedLocation: &ArtifactsLocation{Bucket: "my-bucket", Prefix: "artifacts", Endpoint: GCSEndpoint},
+		},
+		{
+			name:        "unsupported scheme",
+			uri:         "hdfs://my-bucket/artifacts",
+			expectedErr: "artifacts URI should be",
+		},
+		{
+			name:        "no bucket",
+			uri:         "s3:///artifacts",
+			expectedErr: "artifacts URI should be",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			location, err := ParseArtifactsURI(tt.uri, tt.endpoint)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestListAndDownloadJobArtifacts(t *testing.T) {
+	client := &fakeClient{objects: map[string]string{
+		"theia/id-1/job.log":      "log",
+		"theia/id-1/metrics.json": "{}",
+		"theia/id-1/result.yaml":  "apiVersion: v1",
+		"theia/id-2/job.log":      "other log",
+	}}
+	location := &ArtifactsLocation{Bucket: "my-bucket", Prefix: "theia"}
+	objects, err := ListJobArtifacts(context.TODO(), client, location, "id-1")
+	require.NoError(t, err)
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, *object.Key)
+	}
+	assert.Equal(t, []string{"theia/id-1/job.log", "theia/id-1/metrics.json", "theia/id-1/result.yaml"}, keys)
+
+	path := filepath.Join(t.TempDir(), "id-1", "result.yaml")
+	n, err := DownloadObject(context.TODO(), client, "my-bucket", "theia/id-1/result.yaml", path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), n)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: v1", string(content))
+
+	_, err = DownloadObject(context.TODO(), client, "my-bucket", "theia/id-3/job.log", path)
+	assert.ErrorContains(t, err, "error when getting object theia/id-3/job.log")
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signing signs and verifies the policy bundles exported from
+// policy recommendation jobs, with Ed25519 or ECDSA P-256 keys. Signatures
+// are base64-encoded, like the ones of "cosign sign-blob": ECDSA signatures
+// are computed on the SHA-256 digest of the bundle, so that bundles signed
+// with a cosign key can be verified with "cosign verify-blob", and conversely.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	privateKeyPEMType = "PRIVATE KEY"
+	publicKeyPEMType  = "PUBLIC KEY"
+	// cosign encrypts its private keys with a password, older versions use
+	// the COSIGN type and newer ones the SIGSTORE type.
+	cosignPrivateKeyPEMType   = "ENCRYPTED COSIGN PRIVATE KEY"
+	sigstorePrivateKeyPEMType = "ENCRYPTED SIGSTORE PRIVATE KEY"
+)
+
+// encryptedKey is the format of the encrypted cosign private keys.
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func decryptCosignKey(data []byte, password []byte) ([]byte, error) {
+	var key encryptedKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("encrypted private key is invalid: %v", err)
+	}
+	if key.KDF.Name != "scrypt" || key.Cipher.Name != "nacl/secretbox" || len(key.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("encrypted private key uses an unsupported KDF or cipher")
+	}
+	secretKey, err := scrypt.Key(password, key.KDF.Salt, key.KDF.Params.N, key.KDF.Params.R, key.KDF.Params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error when deriving the key from the password: %v", err)
+	}
+	var nonce [24]byte
+	var secretKeyArray [32]byte
+	copy(nonce[:], key.Cipher.Nonce)
+	copy(secretKeyArray[:], secretKey)
+	der, ok := secretbox.Open(nil, key.Ciphertext, &nonce, &secretKeyArray)
+	if !ok {
+		return nil, fmt.Errorf("error when decrypting the private key, the password may be wrong")
+	}
+	return der, nil
+}
+
+// ParsePrivateKey parses a PEM-encoded PKCS #8 private key, or an encrypted
+// cosign private key, which is decrypted with password.
+func ParsePrivateKey(data []byte, password []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM-encoded")
+	}
+	der := block.Bytes
+	switch block.Type {
+	case privateKeyPEMType:
+	case cosignPrivateKeyPEMType, sigstorePrivateKeyPEMType:
+		var err error
+		if der, err = decryptCosignKey(block.Bytes, password); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported private key PEM type %q", block.Type)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("error when parsing the private key: %v", err)
+	}
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("only ECDSA keys on the P-256 curve are supported")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T, it should be Ed25519 or ECDSA", key)
+	}
+}
+
+// ParsePublicKey parses a PEM-encoded PKIX public key, e.g. cosign.pub.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != publicKeyPEMType {
+		return nil, fmt.Errorf("public key should be a PEM-encoded %s block", publicKeyPEMType)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error when parsing the public key: %v", err)
+	}
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return key, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("only ECDSA keys on the P-256 curve are supported")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T, it should be Ed25519 or ECDSA", key)
+	}
+}
+
+// LoadPrivateKey reads a private key from a file, see ParsePrivateKey.
+func LoadPrivateKey(path string, password []byte) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading private key: %v", err)
+	}
+	return ParsePrivateKey(data, password)
+}
+
+// LoadPublicKey reads a public key from a file, see ParsePublicKey.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading public key: %v", err)
+	}
+	return ParsePublicKey(data)
+}
+
+// Sign returns the base64-encoded signature of data.
+func Sign(signer crypto.Signer, data []byte) (string, error) {
+	var signature []byte
+	var err error
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		signature, err = signer.Sign(rand.Reader, data, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(data)
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error when signing: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the base64-encoded signature of data. Surrounding whitespace
+// in the signature is ignored.
+func Verify(publicKey crypto.PublicKey, data []byte, signature string) error {
+	rawSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("signature is not base64-encoded: %v", err)
+	}
+	var ok bool
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, data, rawSignature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = ecdsa.VerifyASN1(key, digest[:], rawSignature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	if !ok {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const testBundle = `apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-ab7fd
+  namespace: antrea-test
+`
+
+func encodePrivateKey(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: privateKeyPEMType, Bytes: der})
+}
+
+func encodePublicKey(t *testing.T, key crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: publicKeyPEMType, Bytes: der})
+}
+
+// encryptCosignKey encrypts a private key like "cosign generate-key-pair",
+// with cheaper scrypt parameters.
+func encryptCosignKey(t *testing.T, key crypto.Signer, password []byte) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	var encrypted encryptedKey
+	encrypted.KDF.Name = "scrypt"
+	encrypted.KDF.Params.N = 1024
+	encrypted.KDF.Params.R = 8
+	encrypted.KDF.Params.P = 1
+	encrypted.KDF.Salt = []byte("0123456789abcdef0123456789abcdef")
+	encrypted.Cipher.Name = "nacl/secretbox"
+	encrypted.Cipher.Nonce = []byte("0123456789abcdef01234567")
+	secretKey, err := scrypt.Key(password, encrypted.KDF.Salt, 1024, 8, 1, 32)
+	require.NoError(t, err)
+	var nonce [24]byte
+	var secretKeyArray [32]byte
+	copy(nonce[:], encrypted.Cipher.Nonce)
+	copy(secretKeyArray[:], secretKey)
+	encrypted.Ciphertext = secretbox.Seal(nil, der, &nonce, &secretKeyArray)
+	data, err := json.Marshal(encrypted)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: cosignPrivateKeyPEMType, Bytes: data})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		privateKeyPEM []byte
+		publicKey     crypto.PublicKey
+	}{
+		{
+			name:          "Ed25519",
+			privateKeyPEM: encodePrivateKey(t, ed25519Key),
+			publicKey:     ed25519Key.Public(),
+		},
+		{
+			name:          "ECDSA",
+			privateKeyPEM: encodePrivateKey(t, ecdsaKey),
+			publicKey:     ecdsaKey.Public(),
+		},
+		{
+			name:          "encrypted cosign",
+			privateKeyPEM: encryptCosignKey(t, ecdsaKey, []byte("password")),
+			publicKey:     ecdsaKey.Public(),
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := ParsePrivateKey(tt.privateKeyPEM, []byte("password"))
+			require.NoError(t, err)
+			publicKey, err := ParsePublicKey(encodePublicKey(t, tt.publicKey))
+			require.NoError(t, err)
+			signature, err := Sign(signer, []byte(testBundle))
+			require.NoError(t, err)
+
+			assert.NoError(t, Verify(publicKey, []byte(testBundle), signature+"\n"))
+			assert.EqualError(t, Verify(publicKey, []byte(testBundle+"---\n"), signature), "invalid signature")
+			assert.EqualError(t, Verify(otherKey.Public(), []byte(testBundle), signature), "invalid signature")
+			assert.ErrorContains(t, Verify(publicKey, []byte(testBundle), "not base64"), "signature is not base64-encoded")
+		})
+	}
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = ParsePrivateKey(encryptCosignKey(t, ecdsaKey, []byte("password")), []byte("wrong"))
+	assert.ErrorContains(t, err, "the password may be wrong")
+	_, err = ParsePrivateKey(encodePrivateKey(t, p384Key), nil)
+	assert.EqualError(t, err, "only ECDSA keys on the P-256 curve are supported")
+	_, err = ParsePrivateKey([]byte("not a key"), nil)
+	assert.EqualError(t, err, "private key is not PEM-encoded")
+	_, err = ParsePublicKey(encodePublicKey(t, p384Key.Public()))
+	assert.EqualError(t, err, "only ECDSA keys on the P-256 curve are supported")
+	_, err = ParsePublicKey(encodePrivateKey(t, ecdsaKey))
+	assert.EqualError(t, err, "public key should be a PEM-encoded PUBLIC KEY block")
+}
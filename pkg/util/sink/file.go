@@ -0,0 +1,64 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileScheme is the scheme of the sink writing the results to a local file:
+// file:<path> or file:///<absolute path>. The results are written to
+// <path>/<ID>.yaml when the path is a directory or ends with a slash.
+const FileScheme = "file"
+
+type fileSink struct {
+	path string
+}
+
+func (s *fileSink) Write(ctx context.Context, result *Result) error {
+	path := s.path
+	if info, err := os.Stat(path); strings.HasSuffix(path, "/") || (err == nil && info.IsDir()) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+		path = filepath.Join(path, resultFileName(result))
+	}
+	if err := os.WriteFile(path, result.Policies, 0600); err != nil {
+		return fmt.Errorf("error when writing recommendation result to file: %v", err)
+	}
+	return nil
+}
+
+func (s *fileSink) String() string {
+	return s.path
+}
+
+func init() {
+	Register(FileScheme, func(u *url.URL, options Options) (Sink, error) {
+		path := u.Path
+		if u.Opaque != "" {
+			path = u.Opaque
+		}
+		if (u.Host != "" && u.Host != "localhost") || path == "" {
+			return nil, fmt.Errorf("file sink should be file:<path> or file:///<absolute path>")
+		}
+		return &fileSink{path: path}, nil
+	})
+}
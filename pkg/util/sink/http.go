@@ -0,0 +1,75 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// RecommendationIDHeader is the header carrying the ID of the job whose
+// result is posted by the http and https sinks.
+const RecommendationIDHeader = "X-Theia-Recommendation-Id"
+
+// httpSink posts the results to an HTTP endpoint, as application/yaml unless
+// the result has another content type. The
+// credentials in the URI, if any, are sent with basic authentication.
+type httpSink struct {
+	u      *url.URL
+	client *http.Client
+}
+
+func (s *httpSink) Write(ctx context.Context, result *Result) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.u.String(), bytes.NewReader(result.Policies))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", resultContentType(result))
+	req.Header.Set(RecommendationIDHeader, result.ID)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error when posting recommendation result to %s: %v", s, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("error when posting recommendation result to %s: %s", s, resp.Status)
+	}
+	return nil
+}
+
+func (s *httpSink) String() string {
+	return s.u.Redacted()
+}
+
+func newHTTPSink(u *url.URL, options Options) (Sink, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("%s sink should be %s://<host>/<path>", u.Scheme, u.Scheme)
+	}
+	client := options.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpSink{u: u, client: client}, nil
+}
+
+func init() {
+	Register("http", newHTTPSink)
+	Register("https", newHTTPSink)
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// PoliciesKey is the key of the recommended policies in the ConfigMaps
+	// and Secrets written by the configmap and secret sinks.
+	PoliciesKey = "policies.yaml"
+	// RecommendationIDLabel is the label set to the ID of the job on the
+	// objects written by the configmap and secret sinks.
+	RecommendationIDLabel = "theia.antrea.io/recommendation-id"
+	// SinkLabel is the label set to the name of the sink on all the chunks
+	// of a result written by the configmap and secret sinks.
+	SinkLabel = "theia.antrea.io/sink"
+	// ChunkAnnotation is the index of the chunk stored in an object.
+	ChunkAnnotation = "theia.antrea.io/chunk"
+	// ChunksAnnotation is the number of chunks of a result.
+	ChunksAnnotation = "theia.antrea.io/chunks"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "theia"
+
+	// maxChunkSize leaves room for the metadata of the objects, which are
+	// limited to 1MiB.
+	maxChunkSize = 1<<20 - 64<<10
+)
+
+// objectSink writes the results to configmap://<namespace>/<name> or
+// secret://<namespace>/<name>, under the PoliciesKey key, or the name of the
+// result when it is set. Each result replaces
+// the previous one. Results larger than maxChunkSize are split into chunks,
+// cut at document boundaries when possible: the first chunk is stored in
+// <name>, and the next ones in <name>-1, <name>-2, etc. Concatenating the
+// chunks in order restores the result.
+type objectSink struct {
+	scheme    string
+	namespace string
+	name      string
+	client    kubernetes.Interface
+}
+
+// chunkName returns the name of the object storing a chunk.
+func (s *objectSink) chunkName(index int) string {
+	if index == 0 {
+		return s.name
+	}
+	return fmt.Sprintf("%s-%d", s.name, index)
+}
+
+func (s *objectSink) Write(ctx context.Context, result *Result) error {
+	if s.client == nil {
+		return fmt.Errorf("%s sink requires a Kubernetes client", s.scheme)
+	}
+	chunks := splitChunks(result.Policies, maxChunkSize)
+	written := make(map[string]bool, len(chunks))
+	for i, chunk := range chunks {
+		meta := metav1.ObjectMeta{
+			Name:      s.chunkName(i),
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				managedByLabel:        managedBy,
+				RecommendationIDLabel: result.ID,
+				SinkLabel:             s.name,
+			},
+			Annotations: map[string]string{
+				ChunkAnnotation:  strconv.Itoa(i),
+				ChunksAnnotation: strconv.Itoa(len(chunks)),
+			},
+		}
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			return s.writeChunk(ctx, meta, resultKey(result), chunk)
+		}); err != nil {
+			return fmt.Errorf("error when writing recommendation result to %s %s/%s: %v", s.kind(), s.namespace, meta.Name, err)
+		}
+		written[meta.Name] = true
+	}
+	// Delete the chunks left by a previous, larger result.
+	stale, err := s.listChunks(ctx)
+	if err != nil {
+		return fmt.Errorf("error when listing the chunks of %s %s/%s: %v", s.kind(), s.namespace, s.name, err)
+	}
+	for _, name := range stale {
+		if written[name] {
+			continue
+		}
+		if err := s.deleteChunk(ctx, name); err != nil && !apimachineryerrors.IsNotFound(err) {
+			return fmt.Errorf("error when deleting stale chunk %s/%s: %v", s.namespace, name, err)
+		}
+	}
+	return nil
+}
+
+func (s *objectSink) kind() string {
+	if s.scheme == "secret" {
+		return "Secret"
+	}
+	return "ConfigMap"
+}
+
+// resultKey returns the key of a result in the objects.
+func resultKey(result *Result) string {
+	if result.Name != "" {
+		return result.Name
+	}
+	return PoliciesKey
+}
+
+// writeChunk creates or replaces the object storing a chunk.
+func (s *objectSink) writeChunk(ctx context.Context, meta metav1.ObjectMeta, key string, chunk []byte) error {
+	if s.scheme == "secret" {
+		secrets := s.client.CoreV1().Secrets(s.namespace)
+		secret, err := secrets.Get(ctx, meta.Name, metav1.GetOptions{})
+		if apimachineryerrors.IsNotFound(err) {
+			_, err = secrets.Create(ctx, &corev1.Secret{
+				ObjectMeta: meta,
+				Type:       corev1.SecretTypeOpaque,
+				Data:       map[string][]byte{key: chunk},
+			}, metav1.CreateOptions{})
+			return err
+		} else if err != nil {
+			return err
+		}
+		meta.ResourceVersion = secret.ResourceVersion
+		secret.ObjectMeta = meta
+		secret.Data = map[string][]byte{key: chunk}
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	}
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	configMap, err := configMaps.Get(ctx, meta.Name, metav1.GetOptions{})
+	if apimachineryerrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: meta,
+			Data:       map[string]string{key: string(chunk)},
+		}, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	meta.ResourceVersion = configMap.ResourceVersion
+	configMap.ObjectMeta = meta
+	configMap.Data = map[string]string{key: string(chunk)}
+	configMap.BinaryData = nil
+	_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	return err
+}
+
+// listChunks returns the names of the objects storing the chunks of the sink.
+func (s *objectSink) listChunks(ctx context.Context) ([]string, error) {
+	options := metav1.ListOptions{
+		LabelSelector: labels.Set{managedByLabel: managedBy, SinkLabel: s.name}.String(),
+	}
+	var names []string
+	if s.scheme == "secret" {
+		secrets, err := s.client.CoreV1().Secrets(s.namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets.Items {
+			names = append(names, secret.Name)
+		}
+	} else {
+		configMaps, err := s.client.CoreV1().ConfigMaps(s.namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, configMap := range configMaps.Items {
+			names = append(names, configMap.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *objectSink) deleteChunk(ctx context.Context, name string) error {
+	if s.scheme == "secret" {
+		return s.client.CoreV1().Secrets(s.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	}
+	return s.client.CoreV1().ConfigMaps(s.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (s *objectSink) String() string {
+	return fmt.Sprintf("%s://%s/%s", s.scheme, s.namespace, s.name)
+}
+
+// splitChunks splits a multi-document YAML manifest into chunks of at most
+// size bytes, cut before a document separator when possible.
+func splitChunks(data []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(data) > size {
+		cut := strings.LastIndex(string(data[:size]), "\n---")
+		if cut <= 0 {
+			cut = size
+		} else {
+			cut++
+		}
+		chunks = append(chunks, data[:cut])
+		data = data[cut:]
+	}
+	return append(chunks, data)
+}
+
+func newObjectSink(u *url.URL, options Options) (Sink, error) {
+	name := strings.Trim(u.Path, "/")
+	if u.Host == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("%s sink should be %s://<namespace>/<name>", u.Scheme, u.Scheme)
+	}
+	return &objectSink{
+		scheme:    u.Scheme,
+		namespace: u.Host,
+		name:      name,
+		client:    options.KubeClient,
+	}, nil
+}
+
+func init() {
+	Register("configmap", newObjectSink)
+	Register("secret", newObjectSink)
+}
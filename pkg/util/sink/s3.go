@@ -0,0 +1,104 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	s3client "antrea.io/theia/pkg/util/s3"
+)
+
+// s3Sink uploads the results to s3://<bucket>/<prefix>/<ID>.yaml or
+// gs://<bucket>/<prefix>/<ID>.yaml. The region and the endpoint of the
+// bucket can be provided with the region and endpoint query parameters.
+type s3Sink struct {
+	uri         string
+	location    *s3client.ArtifactsLocation
+	region      string
+	newS3Client func(ctx context.Context, region string, endpoint string) (s3client.Interface, error)
+}
+
+func (s *s3Sink) Write(ctx context.Context, result *Result) error {
+	client, err := s.newS3Client(ctx, s.region, s.location.Endpoint)
+	if err != nil {
+		return err
+	}
+	key := resultFileName(result)
+	if s.location.Prefix != "" {
+		key = s.location.Prefix + "/" + key
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &s.location.Bucket,
+		Key:         &key,
+		Body:        bytes.NewReader(result.Policies),
+		ContentType: aws.String(resultContentType(result)),
+	}); err != nil {
+		return fmt.Errorf("error when uploading object %s to bucket %s: %v", key, s.location.Bucket, err)
+	}
+	return nil
+}
+
+func (s *s3Sink) String() string {
+	return s.uri
+}
+
+// newS3Client returns a client created from the default AWS SDK config.
+func newS3Client(ctx context.Context, region string, endpoint string) (s3client.Interface, error) {
+	var optFns []func(*awsconfig.LoadOptions) error
+	if region == "" && endpoint == s3client.GCSEndpoint {
+		region = "auto"
+	}
+	if region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS SDK config: %v", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("the region of the bucket is unknown, please provide it with the region query parameter")
+	}
+	return s3client.GetClient(awsCfg, endpoint), nil
+}
+
+func newS3Sink(u *url.URL, options Options) (Sink, error) {
+	query := u.Query()
+	location, err := s3client.ParseArtifactsURI(u.String(), query.Get("endpoint"))
+	if err != nil {
+		return nil, fmt.Errorf("s3 sink is invalid: %v", err)
+	}
+	s := &s3Sink{
+		uri:         u.Redacted(),
+		location:    location,
+		region:      query.Get("region"),
+		newS3Client: options.NewS3Client,
+	}
+	if s.newS3Client == nil {
+		s.newS3Client = newS3Client
+	}
+	return s, nil
+}
+
+func init() {
+	Register("s3", newS3Sink)
+	Register("gs", newS3Sink)
+}
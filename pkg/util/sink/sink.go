@@ -0,0 +1,173 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sink delivers the results of policy recommendation jobs where the
+// automation consuming them expects them, e.g. a file, an S3 bucket, a
+// ConfigMap or an HTTP endpoint. Sinks are selected with URIs, and each URI
+// scheme registers a Factory.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+
+	s3client "antrea.io/theia/pkg/util/s3"
+)
+
+// StdoutScheme is the scheme of the sink writing the results to Options.Out.
+// The "stdout" and "-" URIs are shorthands for "stdout:".
+const StdoutScheme = "stdout"
+
+// Result is the result of a policy recommendation job, or another document
+// delivered by Theia, e.g. a report.
+type Result struct {
+	// ID is the ID of the job.
+	ID string
+	// Policies are the recommended policies, as a multi-document YAML
+	// manifest, or the content of the document.
+	Policies []byte
+	// Name is the name of the file, key or object storing the result.
+	// Defaults to <ID>.yaml.
+	Name string
+	// ContentType is the media type of the result. Defaults to
+	// application/yaml.
+	ContentType string
+}
+
+// Sink delivers the results of policy recommendation jobs.
+type Sink interface {
+	Write(ctx context.Context, result *Result) error
+	// String returns the URI of the sink, without credentials.
+	String() string
+}
+
+// Options are the options shared by the sinks of all schemes. The options used
+// by a sink are only required when it writes a result.
+type Options struct {
+	// Out is where the stdout sink writes the results.
+	Out io.Writer
+	// KubeClient is used by the sinks writing Kubernetes objects.
+	KubeClient kubernetes.Interface
+	// HTTPClient is used by the http and https sinks. http.DefaultClient is
+	// used if it is nil.
+	HTTPClient *http.Client
+	// NewS3Client returns the client used by the s3 and gs sinks. By
+	// default, the client is created from the default AWS SDK config.
+	NewS3Client func(ctx context.Context, region string, endpoint string) (s3client.Interface, error)
+}
+
+// Factory returns the Sink of a URI.
+type Factory func(u *url.URL, options Options) (Sink, error)
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = map[string]Factory{}
+)
+
+// Register makes a URI scheme available. It panics if the scheme is already
+// registered.
+func Register(scheme string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	if _, ok := factories[scheme]; ok {
+		panic(fmt.Sprintf("sink scheme %s is already registered", scheme))
+	}
+	factories[scheme] = factory
+}
+
+// Schemes returns the sorted registered URI schemes.
+func Schemes() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+	var schemes []string
+	for scheme := range factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Parse parses a sink URI. URIs without a scheme are file paths.
+func Parse(uri string) (*url.URL, error) {
+	if uri == "-" || uri == StdoutScheme {
+		uri = StdoutScheme + ":"
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("sink %s is invalid: %v", uri, err)
+	}
+	if u.Scheme == "" {
+		u.Scheme = FileScheme
+		u.Opaque, u.Path = u.Path, ""
+	}
+	return u, nil
+}
+
+// New returns the Sink of a URI.
+func New(uri string, options Options) (Sink, error) {
+	u, err := Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	factoriesMutex.RLock()
+	factory, ok := factories[u.Scheme]
+	factoriesMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("sink %s is invalid, its scheme should be one of: %s", u.Redacted(), strings.Join(Schemes(), ", "))
+	}
+	return factory(u, options)
+}
+
+// resultFileName returns the name of the file, key or object storing a result.
+func resultFileName(result *Result) string {
+	if result.Name != "" {
+		return result.Name
+	}
+	return result.ID + ".yaml"
+}
+
+// resultContentType returns the media type of a result.
+func resultContentType(result *Result) string {
+	if result.ContentType != "" {
+		return result.ContentType
+	}
+	return "application/yaml"
+}
+
+type stdoutSink struct {
+	out io.Writer
+}
+
+func (s *stdoutSink) Write(ctx context.Context, result *Result) error {
+	_, err := s.out.Write(result.Policies)
+	return err
+}
+
+func (s *stdoutSink) String() string {
+	return StdoutScheme
+}
+
+func init() {
+	Register(StdoutScheme, func(u *url.URL, options Options) (Sink, error) {
+		return &stdoutSink{out: options.Out}, nil
+	})
+}
@@ -0,0 +1,206 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package syslog formats the security events found by Theia, e.g. the flows
+// denied by network policies, as RFC 5424 syslog messages or as CEF (ArcSight
+// Common Event Format) records, and sends them to syslog servers, so that SOC
+// tooling can ingest them without custom integration.
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatRFC5424 = "syslog"
+	FormatCEF     = "cef"
+
+	// enterpriseNumber is the IANA Private Enterprise Number of Antrea,
+	// which identifies the structured data of the RFC 5424 messages.
+	enterpriseNumber = 56506
+	// FacilityLocal0 is the default facility of the messages.
+	FacilityLocal0 = 16
+
+	cefVendor  = "Antrea"
+	cefProduct = "Theia"
+	// nilValue is the value of the empty RFC 5424 header fields.
+	nilValue = "-"
+)
+
+// Formats are the supported formats.
+var Formats = []string{FormatRFC5424, FormatCEF}
+
+// Field is a field of an event. Keys are CEF extension keys, e.g. src.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Event is a security event.
+type Event struct {
+	Time time.Time
+	// ID identifies the type of the event, e.g. denied-flow. It is the
+	// Signature ID of the CEF records, and the MSGID of the syslog messages.
+	ID string
+	// Name is a human-readable description of the event.
+	Name string
+	// Severity is the CEF severity, from 0 to 10.
+	Severity int
+	// Fields are the details of the event. Fields with empty values are
+	// left out.
+	Fields []Field
+}
+
+// Formatter formats events.
+type Formatter struct {
+	// EventFormat is FormatRFC5424 or FormatCEF.
+	EventFormat string
+	// Syslog is true when the events are sent to a syslog server, in which
+	// case the CEF records are the messages of RFC 5424 syslog messages.
+	Syslog bool
+	// Hostname and AppName are the HOSTNAME and APP-NAME of the syslog
+	// messages.
+	Hostname string
+	AppName  string
+	Facility int
+	// Version is the Device Version of the CEF records.
+	Version string
+}
+
+// NewFormatter returns a Formatter of events in the given format.
+func NewFormatter(format string, syslog bool, hostname string, appName string, version string) (*Formatter, error) {
+	if format != FormatRFC5424 && format != FormatCEF {
+		return nil, fmt.Errorf("unsupported event format %q, supported formats: %v", format, Formats)
+	}
+	return &Formatter{
+		EventFormat: format,
+		Syslog:      syslog,
+		Hostname:    hostname,
+		AppName:     appName,
+		Facility:    FacilityLocal0,
+		Version:     version,
+	}, nil
+}
+
+// Format returns an event formatted as a single line, without line ending.
+func (f *Formatter) Format(event *Event) string {
+	if f.EventFormat == FormatCEF {
+		record := f.cef(event)
+		if !f.Syslog {
+			return record
+		}
+		return f.header(event) + " " + nilValue + " " + record
+	}
+	message := f.header(event) + " " + structuredData(event)
+	if event.Name != "" {
+		message += " " + event.Name
+	}
+	return message
+}
+
+// header returns the header of the RFC 5424 message of an event:
+// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID.
+func (f *Formatter) header(event *Event) string {
+	priority := f.Facility*8 + syslogSeverity(event.Severity)
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s",
+		priority,
+		event.Time.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		headerField(f.Hostname, 255),
+		headerField(f.AppName, 48),
+		nilValue,
+		headerField(event.ID, 32),
+	)
+}
+
+// headerField returns the value of a header field, which is printable ASCII
+// without spaces, truncated to maxLength.
+func headerField(value string, maxLength int) string {
+	field := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+	if field == "" {
+		return nilValue
+	}
+	return field
+}
+
+// syslogSeverity maps a CEF severity to a syslog severity.
+func syslogSeverity(severity int) int {
+	switch {
+	case severity >= 9:
+		return 2 // Critical
+	case severity >= 7:
+		return 3 // Error
+	case severity >= 4:
+		return 4 // Warning
+	default:
+		return 6 // Informational
+	}
+}
+
+var sdParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// structuredData returns the SD-ELEMENT with the fields of an event.
+func structuredData(event *Event) string {
+	var sd strings.Builder
+	fmt.Fprintf(&sd, "[theia@%d", enterpriseNumber)
+	for _, field := range event.Fields {
+		if field.Value == "" {
+			continue
+		}
+		fmt.Fprintf(&sd, ` %s="%s"`, field.Key, sdParamEscaper.Replace(field.Value))
+	}
+	sd.WriteString("]")
+	return sd.String()
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// cef returns the CEF record of an event.
+func (f *Formatter) cef(event *Event) string {
+	var record strings.Builder
+	fmt.Fprintf(&record, "CEF:0|%s|%s|%s|%s|%s|%s|",
+		cefHeaderEscaper.Replace(cefVendor),
+		cefHeaderEscaper.Replace(cefProduct),
+		cefHeaderEscaper.Replace(f.Version),
+		cefHeaderEscaper.Replace(event.ID),
+		cefHeaderEscaper.Replace(event.Name),
+		strconv.Itoa(event.Severity),
+	)
+	fields := append([]Field{{Key: "rt", Value: strconv.FormatInt(event.Time.UnixMilli(), 10)}}, event.Fields...)
+	first := true
+	for _, field := range fields {
+		if field.Value == "" {
+			continue
+		}
+		if !first {
+			record.WriteString(" ")
+		}
+		first = false
+		fmt.Fprintf(&record, "%s=%s", field.Key, cefExtensionEscaper.Replace(field.Value))
+	}
+	return record.String()
+}
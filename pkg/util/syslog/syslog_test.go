@@ -0,0 +1,121 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvent = &Event{
+	Time:     time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC),
+	ID:       "denied-flow",
+	Name:     "Flow denied by network policy",
+	Severity: 5,
+	Fields: []Field{
+		{Key: "src", Value: "10.10.0.5"},
+		{Key: "dst", Value: "10.10.1.6"},
+		{Key: "act", Value: "Drop"},
+		{Key: "cs1Label", Value: "networkPolicy"},
+		{Key: "cs1", Value: `app-a/deny-all "db"=\ok]`},
+		{Key: "cs2", Value: ""},
+	},
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range []struct {
+		format   string
+		syslog   bool
+		expected string
+	}{
+		{
+			format:   FormatRFC5424,
+			expected: `<132>1 2022-08-01T12:00:00.000000Z node-1 theia - denied-flow [theia@56506 src="10.10.0.5" dst="10.10.1.6" act="Drop" cs1Label="networkPolicy" cs1="app-a/deny-all \"db\"=\\ok\]"] Flow denied by network policy`,
+		},
+		{
+			format:   FormatCEF,
+			expected: `CEF:0|Antrea|Theia|v0.3.0|denied-flow|Flow denied by network policy|5|rt=1659355200000 src=10.10.0.5 dst=10.10.1.6 act=Drop cs1Label=networkPolicy cs1=app-a/deny-all "db"\=\\ok]`,
+		},
+		{
+			format:   FormatCEF,
+			syslog:   true,
+			expected: `<132>1 2022-08-01T12:00:00.000000Z node-1 theia - denied-flow - CEF:0|Antrea|Theia|v0.3.0|denied-flow|Flow denied by network policy|5|rt=1659355200000 src=10.10.0.5 dst=10.10.1.6 act=Drop cs1Label=networkPolicy cs1=app-a/deny-all "db"\=\\ok]`,
+		},
+	} {
+		formatter, err := NewFormatter(tt.format, tt.syslog, "node-1", "theia", "v0.3.0")
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, formatter.Format(testEvent))
+	}
+
+	_, err := NewFormatter("leef", false, "", "theia", "")
+	assert.EqualError(t, err, `unsupported event format "leef", supported formats: [syslog cef]`)
+}
+
+func TestFormatHeader(t *testing.T) {
+	formatter, err := NewFormatter(FormatRFC5424, true, "", "the ia", "")
+	require.NoError(t, err)
+	event := &Event{Time: testEvent.Time, ID: "flow", Severity: 9}
+	assert.Equal(t, "<130>1 2022-08-01T12:00:00.000000Z - theia - flow [theia@56506]", formatter.Format(event))
+}
+
+func TestWriter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := bufio.NewReader(conn).ReadString('!')
+		received <- data
+	}()
+	writer, err := Dial("tcp://"+listener.Addr().String(), "")
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteMessage("<134>1 - - - - - hello"))
+	require.NoError(t, writer.WriteMessage("!"))
+	require.NoError(t, writer.Close())
+	assert.Equal(t, "22 <134>1 - - - - - hello1 !", <-received)
+
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udpConn.Close()
+	writer, err = Dial("udp://"+udpConn.LocalAddr().String(), "")
+	require.NoError(t, err)
+	defer writer.Close()
+	require.NoError(t, writer.WriteMessage("<134>1 - - - - - hello"))
+	buffer := make([]byte, 1024)
+	udpConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := udpConn.ReadFrom(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "<134>1 - - - - - hello", string(buffer[:n]))
+}
+
+func TestDialInvalidURI(t *testing.T) {
+	for _, uri := range []string{"siem:514", "http://siem", "udp://"} {
+		_, err := Dial(uri, "")
+		assert.True(t, err != nil && strings.Contains(err.Error(), "syslog server"), uri)
+	}
+	_, err := Dial("tls://127.0.0.1:6514", "/non-existent/ca.crt")
+	assert.ErrorContains(t, err, "error when reading the CA certificate of the syslog server")
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// defaultPorts are the default ports of the syslog transports.
+var defaultPorts = map[string]string{
+	"udp": "514",
+	"tcp": "514",
+	"tls": "6514",
+}
+
+// Writer sends messages to a syslog server: over UDP, one message per
+// datagram as defined by RFC 5426, or over TCP or TLS, with the octet-counting
+// framing defined by RFC 5425.
+type Writer struct {
+	conn net.Conn
+	// stream is true for TCP and TLS connections.
+	stream bool
+}
+
+// Dial connects to the syslog server of the URI, e.g. udp://siem:514,
+// tcp://siem:514 or tls://siem:6514. With TLS, the certificate of the server is
+// verified with the CA certificates of caCertPath if not empty, or else with
+// the system ones.
+func Dial(uri string, caCertPath string) (*Writer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("syslog server %s is invalid: %v", uri, err)
+	}
+	defaultPort, ok := defaultPorts[u.Scheme]
+	if !ok || u.Host == "" {
+		return nil, fmt.Errorf("syslog server %s is invalid, it should be udp://<host>[:<port>], tcp://<host>[:<port>] or tls://<host>[:<port>]", uri)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "tls":
+		tlsConfig := &tls.Config{
+			ServerName: u.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		}
+		if caCertPath != "" {
+			caCert, err := os.ReadFile(caCertPath)
+			if err != nil {
+				return nil, fmt.Errorf("error when reading the CA certificate of the syslog server: %v", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("no valid PEM certificate found in %s", caCertPath)
+			}
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", address, tlsConfig)
+	default:
+		conn, err = net.DialTimeout(u.Scheme, address, dialTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error when connecting to syslog server %s: %v", address, err)
+	}
+	return &Writer{conn: conn, stream: u.Scheme != "udp"}, nil
+}
+
+// WriteMessage sends a message.
+func (w *Writer) WriteMessage(message string) error {
+	if w.stream {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+	w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := w.conn.Write([]byte(message)); err != nil {
+		return fmt.Errorf("error when sending message to syslog server: %v", err)
+	}
+	return nil
+}
+
+// Close closes the connection to the syslog server.
+func (w *Writer) Close() error {
+	return w.conn.Close()
+}
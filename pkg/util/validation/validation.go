@@ -0,0 +1,232 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validation validates the values of command line flags. The errors
+// explain which values are expected and, when a close valid value exists,
+// suggest it, so that all the commands report invalid values the same way.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// newError returns an error with the given message, followed by the
+// suggestion if it is not empty.
+func newError(message string, suggestion string) error {
+	if suggestion != "" {
+		return fmt.Errorf("%s, did you mean %q?", message, suggestion)
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// joinOptions joins options as "a, b or c".
+func joinOptions(options []string) string {
+	if len(options) == 1 {
+		return options[0]
+	}
+	return strings.Join(options[:len(options)-1], ", ") + " or " + options[len(options)-1]
+}
+
+// OneOf checks that the value of a flag is one of the given options.
+func OneOf(name string, value string, options ...string) error {
+	for _, option := range options {
+		if value == option {
+			return nil
+		}
+	}
+	return newError(fmt.Sprintf("%s should be %s", name, joinOptions(options)), Closest(value, options))
+}
+
+// NonNegative checks that the value of an integer flag is >= 0.
+func NonNegative(name string, value int64) error {
+	if value < 0 {
+		return fmt.Errorf("%s should be an integer >= 0", name)
+	}
+	return nil
+}
+
+// Positive checks that the value of an integer flag is > 0.
+func Positive(name string, value int64) error {
+	if value <= 0 {
+		return fmt.Errorf("%s should be an integer > 0", name)
+	}
+	return nil
+}
+
+// Quantity checks that the value of a flag is a Kubernetes resource quantity,
+// e.g. 500m or 2G.
+func Quantity(name string, value string) error {
+	if _, err := resource.ParseQuantity(value); err == nil {
+		return nil
+	}
+	var suggestion string
+	// Common mistakes are byte units, e.g. "2GB" or "512mb", and units with
+	// the wrong case, e.g. "2g".
+	number := strings.TrimRight(value, "bB")
+	if i := strings.LastIndexAny(number, "0123456789."); i >= 0 && i < len(number)-1 {
+		unit := number[i+1:]
+		for _, candidate := range []string{number, number[:i+1] + strings.ToUpper(unit[:1]) + unit[1:]} {
+			if candidate == value || candidate[i+1:] == "m" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(candidate); err == nil {
+				suggestion = candidate
+				break
+			}
+		}
+	}
+	return newError(fmt.Sprintf("%s should conform to the Kubernetes resource quantity convention, for example: 500m or 2G", name), suggestion)
+}
+
+// Timezone returns the location of a time zone, which should be UTC, Local or
+// an IANA time zone name.
+func Timezone(name string, value string) (*time.Location, error) {
+	location, err := time.LoadLocation(value)
+	if err == nil {
+		return location, nil
+	}
+	var suggestion string
+	lower := strings.ToLower(value)
+	for _, candidate := range []string{
+		strings.ToUpper(value),
+		strings.ToUpper(lower[:1]) + lower[1:],
+		strings.ReplaceAll(strings.TrimSpace(value), " ", "_"),
+	} {
+		if candidate == value {
+			continue
+		}
+		if _, err := time.LoadLocation(candidate); err == nil {
+			suggestion = candidate
+			break
+		}
+	}
+	return nil, newError(fmt.Sprintf("parsing %s: %v, %s should be UTC, Local or an IANA time zone name, for example: America/Los_Angeles", name, err, name), suggestion)
+}
+
+var durationWordUnits = regexp.MustCompile(`^(\d+)\s*(days?|d|weeks?|w|hours?|hrs?|h|minutes?|mins?|m|seconds?|secs?|s)$`)
+
+// Duration parses a duration like time.ParseDuration, and also accepts a whole
+// number of days with the "d" unit, e.g. "30d".
+func Duration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil {
+		return d, nil
+	}
+	var suggestion string
+	if match := durationWordUnits.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value))); match != nil {
+		switch unit := match[2]; {
+		case strings.HasPrefix(unit, "w"):
+			n, _ := strconv.Atoi(match[1])
+			suggestion = fmt.Sprintf("%dd", 7*n)
+		case strings.HasPrefix(unit, "mi") || unit == "m":
+			suggestion = match[1] + "m"
+		default:
+			suggestion = match[1] + unit[:1]
+		}
+		if suggestion == value {
+			suggestion = ""
+		}
+	}
+	return 0, newError(fmt.Sprintf("input duration %s does not seem valid, it should be like 12h or 30d", value), suggestion)
+}
+
+const timestampLayout = "2006-01-02 15:04:05"
+
+// Timestamp parses a timestamp in RFC3339 format, or in the
+// "YYYY-MM-DD hh:mm:ss" format in the given location, and returns it in UTC.
+func Timestamp(value string, location *time.Location) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.ParseInLocation(timestampLayout, value, location)
+	}
+	if err == nil {
+		return t.UTC(), nil
+	}
+	var suggestion string
+	// Common mistakes are dates without a time, other date separators and
+	// RFC3339 timestamps without a time zone.
+	candidate := strings.NewReplacer("/", "-", ".", "-", "T", " ").Replace(strings.TrimSpace(value))
+	for _, candidate := range []string{candidate, candidate + " 00:00:00"} {
+		if _, err := time.ParseInLocation(timestampLayout, candidate, location); err == nil {
+			suggestion = candidate
+			break
+		}
+	}
+	return time.Time{}, newError(fmt.Sprintf(`input timestamp %s does not seem valid, it should be in RFC3339 format,
+for example: 2006-01-02T15:04:05Z, or in 'YYYY-MM-DD hh:mm:ss' format, for example: 2006-01-02 15:04:05`, value), suggestion)
+}
+
+// Closest returns the option closest to value, if it is close enough to be a
+// likely typo, or an empty string.
+func Closest(value string, options []string) string {
+	var closest string
+	maxDistance := len(value) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	best := maxDistance + 1
+	for _, option := range options {
+		if strings.EqualFold(value, option) {
+			return option
+		}
+		if d := distance(strings.ToLower(value), strings.ToLower(option)); d < best {
+			best = d
+			closest = option
+		}
+	}
+	return closest
+}
+
+// distance returns the Levenshtein distance between two strings.
+func distance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
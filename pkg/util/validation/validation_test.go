@@ -0,0 +1,134 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneOf(t *testing.T) {
+	assert.NoError(t, OneOf("type", "initial", "initial", "subsequent"))
+	assert.EqualError(t, OneOf("type", "intial", "initial", "subsequent"), `type should be initial or subsequent, did you mean "initial"?`)
+	assert.EqualError(t, OneOf("type", "INITIAL", "initial", "subsequent"), `type should be initial or subsequent, did you mean "initial"?`)
+	assert.EqualError(t, OneOf("executor-spot-preset", "azure", "eks", "gke", "aks"), "executor-spot-preset should be eks, gke or aks")
+}
+
+func TestIntegers(t *testing.T) {
+	assert.NoError(t, NonNegative("limit", 0))
+	assert.EqualError(t, NonNegative("limit", -1), "limit should be an integer >= 0")
+	assert.NoError(t, Positive("jobs", 1))
+	assert.EqualError(t, Positive("jobs", 0), "jobs should be an integer > 0")
+}
+
+func TestQuantity(t *testing.T) {
+	testCases := []struct {
+		value              string
+		expectedSuggestion string
+		valid              bool
+	}{
+		{value: "200m", valid: true},
+		{value: "512M", valid: true},
+		{value: "2Gi", valid: true},
+		{value: "2GB", expectedSuggestion: "2G"},
+		{value: "2g", expectedSuggestion: "2G"},
+		{value: "512mb", expectedSuggestion: "512M"},
+		{value: "2GiB", expectedSuggestion: "2Gi"},
+		{value: "lots"},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.value, func(t *testing.T) {
+			err := Quantity("driver-memory", tt.value)
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			expected := "driver-memory should conform to the Kubernetes resource quantity convention, for example: 500m or 2G"
+			if tt.expectedSuggestion != "" {
+				expected += `, did you mean "` + tt.expectedSuggestion + `"?`
+			}
+			assert.EqualError(t, err, expected)
+		})
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	location, err := Timezone("timezone", "America/Los_Angeles")
+	require.NoError(t, err)
+	assert.Equal(t, "America/Los_Angeles", location.String())
+	for value, suggestion := range map[string]string{
+		"utc":                 "UTC",
+		"local":               "Local",
+		"America/Los Angeles": "America/Los_Angeles",
+	} {
+		_, err := Timezone("timezone", value)
+		assert.ErrorContains(t, err, "timezone should be UTC, Local or an IANA time zone name")
+		assert.ErrorContains(t, err, `did you mean "`+suggestion+`"?`)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	testCases := []struct {
+		value            string
+		expectedDuration time.Duration
+		expectedErrorMsg string
+	}{
+		{value: "30d", expectedDuration: 30 * 24 * time.Hour},
+		{value: "1h30m", expectedDuration: 90 * time.Minute},
+		{value: "-1d", expectedErrorMsg: "input duration -1d does not seem valid, it should be like 12h or 30d"},
+		{value: "7 days", expectedErrorMsg: `input duration 7 days does not seem valid, it should be like 12h or 30d, did you mean "7d"?`},
+		{value: "2w", expectedErrorMsg: `input duration 2w does not seem valid, it should be like 12h or 30d, did you mean "14d"?`},
+		{value: "12H", expectedErrorMsg: `input duration 12H does not seem valid, it should be like 12h or 30d, did you mean "12h"?`},
+		{value: "10mins", expectedErrorMsg: `input duration 10mins does not seem valid, it should be like 12h or 30d, did you mean "10m"?`},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.value, func(t *testing.T) {
+			duration, err := Duration(tt.value)
+			if tt.expectedErrorMsg != "" {
+				assert.EqualError(t, err, tt.expectedErrorMsg)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedDuration, duration)
+			}
+		})
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	timestamp, err := Timestamp("2022-01-01T00:00:00+02:00", time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 12, 31, 22, 0, 0, 0, time.UTC), timestamp)
+	for value, suggestion := range map[string]string{
+		"2022/01/01 10:00:00": "2022-01-01 10:00:00",
+		"2022-01-01":          "2022-01-01 00:00:00",
+		"2022-01-01T10:00:00": "2022-01-01 10:00:00",
+	} {
+		_, err := Timestamp(value, time.UTC)
+		assert.ErrorContains(t, err, "input timestamp "+value+" does not seem valid")
+		assert.ErrorContains(t, err, `did you mean "`+suggestion+`"?`)
+	}
+	_, err = Timestamp("yesterday", time.UTC)
+	assert.NotContains(t, err.Error(), "did you mean")
+}
+
+func TestClosest(t *testing.T) {
+	options := []string{"anp-deny-applied", "anp-deny-all", "k8s-np"}
+	assert.Equal(t, "anp-deny-all", Closest("anp-deny-al", options))
+	assert.Equal(t, "k8s-np", Closest("k8snp", options))
+	assert.Equal(t, "", Closest("acnp", options))
+}
@@ -28,6 +28,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
+
+	flowclickhouse "antrea.io/theia/pkg/clickhouse"
 )
 
 const (
@@ -279,10 +281,7 @@ func monitorMemory(connect *sql.DB) {
 		// Delete old data in the table storing records and related materialized views
 		tables := append([]string{tableName}, mvNames...)
 		for _, table := range tables {
-			// Delete all records inserted earlier than an upper boundary of timeInserted
-			query := fmt.Sprintf("ALTER TABLE %s DELETE WHERE timeInserted < toDateTime(?)", table)
-			// #nosec G201: table and view names were sanitized earlier
-			if _, err := connect.Exec(query, timeBoundary.Format(timeFormat)); err != nil {
+			if err := deleteRecords(connect, table, timeBoundary); err != nil {
 				klog.ErrorS(err, "Failed to delete records from ClickHouse", "table", table)
 				return
 			}
@@ -292,6 +291,32 @@ func monitorMemory(connect *sql.DB) {
 	}
 }
 
+// Deletes all records inserted earlier than an upper boundary of timeInserted.
+// The partitions older than the boundary are dropped, and the remaining records
+// are deleted with a mutation.
+func deleteRecords(connect *sql.DB, table string, timeBoundary time.Time) error {
+	condition := "timeInserted < toDateTime(?)"
+	plan, err := flowclickhouse.PlanDeletion(connect, table, false, condition, timeBoundary.Format(timeFormat))
+	if err != nil {
+		return err
+	}
+	for _, partition := range plan.Partitions {
+		if err := flowclickhouse.DropPartition(connect, table, false, partition); err != nil {
+			return err
+		}
+		klog.InfoS("Dropped partition", "table", table, "partition", partition)
+	}
+	if !plan.Mutate {
+		return nil
+	}
+	query := fmt.Sprintf("ALTER TABLE %s DELETE WHERE %s", table, condition)
+	// #nosec G201: table and view names were sanitized earlier
+	if _, err := connect.Exec(query, timeBoundary.Format(timeFormat)); err != nil {
+		return err
+	}
+	return nil
+}
+
 // Gets the timeInserted value of the latest row to be deleted.
 func getTimeBoundary(connect *sql.DB) (time.Time, error) {
 	var timeBoundary time.Time
@@ -56,9 +56,17 @@ func TestMonitorWithMockDB(t *testing.T) {
 				mock.ExpectQuery("SELECT SUM(bytes) FROM system.parts").WillReturnRows(partsRow)
 				mock.ExpectQuery("SELECT COUNT() FROM flows").WillReturnRows(countRow)
 				mock.ExpectQuery("SELECT timeInserted FROM flows LIMIT 1 OFFSET (?)").WithArgs(4).WillReturnRows(timeRow)
+				timeBoundary := baseTime.Add(5 * time.Second).Format(timeFormat)
 				for _, table := range []string{"flows", "flows_pod_view", "flows_node_view", "flows_policy_view"} {
-					query := fmt.Sprintf("ALTER TABLE %s DELETE WHERE timeInserted < toDateTime(?)", table)
-					mock.ExpectExec(query).WithArgs(baseTime.Add(5 * time.Second).Format(timeFormat)).WillReturnResult(sqlmock.NewResult(0, 5))
+					partitionsRow := sqlmock.NewRows([]string{"partition", "matched", "droppable"}).
+						AddRow("20230101", 3, true).
+						AddRow("20230102", 2, false)
+					query := fmt.Sprintf("SELECT _partition_id AS partition, countIf(timeInserted < toDateTime(?)) AS matched, partition != 'all' AND matched = count() AND max(timeInserted) < toStartOfDay(now()) AS droppable FROM %s GROUP BY partition ORDER BY partition", table)
+					mock.ExpectQuery(query).WithArgs(timeBoundary).WillReturnRows(partitionsRow)
+					query = fmt.Sprintf("ALTER TABLE %s DROP PARTITION ID ?", table)
+					mock.ExpectExec(query).WithArgs("20230101").WillReturnResult(sqlmock.NewResult(0, 0))
+					query = fmt.Sprintf("ALTER TABLE %s DELETE WHERE timeInserted < toDateTime(?)", table)
+					mock.ExpectExec(query).WithArgs(timeBoundary).WillReturnResult(sqlmock.NewResult(0, 2))
 				}
 			},
 		},
@@ -0,0 +1,477 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The IPFIX exporter re-exports the flows stored in ClickHouse, which are
+// aggregated and enriched with Kubernetes metadata by the Flow Aggregator, as
+// IPFIX to an external collector, e.g. a legacy network analytics system. The
+// flows inserted since the previous round are exported every EXPORT_INTERVAL,
+// with the columns given by TEMPLATE_COLUMNS.
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go"
+	"github.com/vmware/go-ipfix/pkg/entities"
+	"github.com/vmware/go-ipfix/pkg/exporter"
+	"github.com/vmware/go-ipfix/pkg/registry"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Connection to ClickHouse times out if it fails for 1 minute.
+	connTimeout = time.Minute
+	// Retry connection to ClickHouse every 10 seconds if it fails.
+	connRetryInterval = 10 * time.Second
+	// Time format for timeInserted and the other DateTime columns
+	timeFormat = "2006-01-02 15:04:05"
+	// Templates are resent every 10 minutes to the collectors listening
+	// over UDP.
+	templateRefreshTimeout = 600
+)
+
+// defaultTemplateColumns are the columns of the flows table exported when
+// TEMPLATE_COLUMNS is not set.
+const defaultTemplateColumns = "flowStartSeconds flowEndSeconds flowEndReason sourceIP destinationIP " +
+	"sourceTransportPort destinationTransportPort protocolIdentifier packetTotalCount octetTotalCount " +
+	"packetDeltaCount octetDeltaCount reversePacketTotalCount reverseOctetTotalCount reversePacketDeltaCount " +
+	"reverseOctetDeltaCount sourcePodName sourcePodNamespace sourceNodeName destinationPodName " +
+	"destinationPodNamespace destinationNodeName destinationClusterIP destinationServicePort " +
+	"destinationServicePortName ingressNetworkPolicyName ingressNetworkPolicyNamespace " +
+	"ingressNetworkPolicyRuleName ingressNetworkPolicyRuleAction egressNetworkPolicyName " +
+	"egressNetworkPolicyNamespace egressNetworkPolicyRuleName egressNetworkPolicyRuleAction tcpState flowType"
+
+// ipElements are the IPv4 and IPv6 Information Elements of the IP columns of
+// the flows table. The other columns have the name of their Information
+// Element.
+var ipElements = map[string][2]string{
+	"sourceIP":             {"sourceIPv4Address", "sourceIPv6Address"},
+	"destinationIP":        {"destinationIPv4Address", "destinationIPv6Address"},
+	"destinationClusterIP": {"destinationClusterIPv4", "destinationClusterIPv6"},
+}
+
+// ipfixExporter sends IPFIX sets to the collector. It is implemented by
+// exporter.ExportingProcess.
+type ipfixExporter interface {
+	NewTemplateID() uint16
+	SendSet(set entities.Set) (int, error)
+	CloseConnToCollector()
+}
+
+var (
+	getEnv           = os.Getenv
+	openSql          = sql.Open
+	foreverRun       = wait.Forever
+	now              = time.Now
+	newIPFIXExporter = func(input exporter.ExporterInput) (ipfixExporter, error) {
+		return exporter.InitExportingProcess(input)
+	}
+)
+
+var (
+	// identifierPartRegex is used to validate ClickHouse SQL identifiers coming from the environment.
+	identifierPartRegex = regexp.MustCompile("^[a-zA-Z_][0-9a-zA-Z_]*$")
+	// The name of the table storing the flow records
+	tableName string
+	// The columns of the flow records exported to the collector
+	templateColumns []string
+	// The time interval between two rounds of export.
+	exportInterval time.Duration
+	// The configuration of the exporting process
+	exporterInput exporter.ExporterInput
+	// The exporting process, nil until it is connected to the collector
+	exportingProcess ipfixExporter
+	// The IPv4 and IPv6 templates of the flow records, sent when the
+	// exporting process connects to the collector
+	templates [2]*template
+	// The flows inserted after this time are exported in the next round
+	lastExportTime time.Time
+)
+
+var errNotAValidIdentifier = errors.New("not a valid identifier")
+
+// template is the IPFIX template of the flow records of an IP family.
+type template struct {
+	id       uint16
+	elements []*entities.InfoElement
+}
+
+func sanitizeIdentifier(identifier string) (string, error) {
+	identifierParts := strings.Split(identifier, ".")
+	if len(identifierParts) > 2 {
+		return "", errNotAValidIdentifier
+	}
+	for _, part := range identifierParts {
+		// see https://clickhouse.com/docs/en/sql-reference/syntax/#identifiers
+		if !identifierPartRegex.MatchString(part) {
+			return "", errNotAValidIdentifier
+		}
+	}
+	return identifier, nil
+}
+
+func main() {
+	registry.LoadRegistry()
+	if err := loadEnvVariables(); err != nil {
+		klog.ErrorS(err, "Error when loading environment variables")
+		os.Exit(1)
+	}
+	connect, err := connectLoop()
+	if err != nil {
+		klog.ErrorS(err, "Error when connecting to ClickHouse")
+		os.Exit(1)
+	}
+	startExporter(connect)
+}
+
+func startExporter(connect *sql.DB) {
+	// Only the flows inserted after the exporter starts are exported.
+	lastExportTime = now().Truncate(time.Second)
+	foreverRun(func() {
+		exportRound(connect)
+	}, exportInterval)
+}
+
+func loadEnvVariables() error {
+	tableName = getEnv("TABLE_NAME")
+	collectorAddress := getEnv("COLLECTOR_ADDRESS")
+	collectorProtocol := getEnv("COLLECTOR_PROTOCOL")
+	exportIntervalStr := getEnv("EXPORT_INTERVAL")
+	if len(tableName) == 0 || len(collectorAddress) == 0 || len(collectorProtocol) == 0 || len(exportIntervalStr) == 0 {
+		return fmt.Errorf("unable to load environment variables, TABLE_NAME, COLLECTOR_ADDRESS, COLLECTOR_PROTOCOL and EXPORT_INTERVAL must be defined")
+	}
+
+	var err error
+	tableName, err = sanitizeIdentifier(tableName)
+	if err != nil {
+		return fmt.Errorf("invalid TABLE_NAME: %v", err)
+	}
+	if _, _, err := net.SplitHostPort(collectorAddress); err != nil {
+		return fmt.Errorf("invalid COLLECTOR_ADDRESS, it should be <host>:<port>: %v", err)
+	}
+	if collectorProtocol != "tcp" && collectorProtocol != "udp" {
+		return fmt.Errorf("invalid COLLECTOR_PROTOCOL %s, it should be tcp or udp", collectorProtocol)
+	}
+	exportInterval, err = time.ParseDuration(exportIntervalStr)
+	if err != nil {
+		return fmt.Errorf("error when parsing EXPORT_INTERVAL: %v", err)
+	}
+	exporterInput = exporter.ExporterInput{
+		CollectorAddress:  collectorAddress,
+		CollectorProtocol: collectorProtocol,
+		TempRefTimeout:    templateRefreshTimeout,
+	}
+	if observationDomainIDStr := getEnv("OBSERVATION_DOMAIN_ID"); observationDomainIDStr != "" {
+		observationDomainID, err := strconv.ParseUint(observationDomainIDStr, 10, 32)
+		if err != nil {
+			return fmt.Errorf("error when parsing OBSERVATION_DOMAIN_ID: %v", err)
+		}
+		exporterInput.ObservationDomainID = uint32(observationDomainID)
+	}
+	// The connection to a TCP collector is secured with TLS when a CA
+	// certificate is provided.
+	if caCertPath := getEnv("COLLECTOR_CA_CERT"); caCertPath != "" {
+		if collectorProtocol != "tcp" {
+			return fmt.Errorf("COLLECTOR_CA_CERT is only supported with the tcp COLLECTOR_PROTOCOL")
+		}
+		exporterInput.IsEncrypted = true
+		for _, file := range []struct {
+			path string
+			data *[]byte
+		}{
+			{caCertPath, &exporterInput.CACert},
+			{getEnv("COLLECTOR_CLIENT_CERT"), &exporterInput.ClientCert},
+			{getEnv("COLLECTOR_CLIENT_KEY"), &exporterInput.ClientKey},
+		} {
+			if file.path == "" {
+				continue
+			}
+			if *file.data, err = os.ReadFile(file.path); err != nil {
+				return fmt.Errorf("error when reading the TLS certificates of the collector: %v", err)
+			}
+		}
+	}
+
+	templateColumnsStr := getEnv("TEMPLATE_COLUMNS")
+	if templateColumnsStr == "" {
+		templateColumnsStr = defaultTemplateColumns
+	}
+	templateColumns = strings.Fields(templateColumnsStr)
+	for i, ipv6 := range []bool{false, true} {
+		templates[i] = &template{}
+		for _, column := range templateColumns {
+			if _, err := sanitizeIdentifier(column); err != nil {
+				return fmt.Errorf("invalid TEMPLATE_COLUMNS: %v", err)
+			}
+			element, err := getInfoElement(column, ipv6)
+			if err != nil {
+				return fmt.Errorf("invalid TEMPLATE_COLUMNS: %v", err)
+			}
+			templates[i].elements = append(templates[i].elements, element)
+		}
+	}
+	return nil
+}
+
+// getInfoElement returns the Information Element of a column of the flows
+// table, from the IANA registry, the Antrea registry or the IANA reverse
+// registry.
+func getInfoElement(column string, ipv6 bool) (*entities.InfoElement, error) {
+	name := column
+	if elements, ok := ipElements[column]; ok {
+		name = elements[0]
+		if ipv6 {
+			name = elements[1]
+		}
+	}
+	for _, enterpriseID := range []uint32{registry.IANAEnterpriseID, registry.AntreaEnterpriseID, registry.IANAReversedEnterpriseID} {
+		if element, err := registry.GetInfoElement(name, enterpriseID); err == nil {
+			return element, nil
+		}
+	}
+	return nil, fmt.Errorf("column %s has no IPFIX Information Element", column)
+}
+
+// Connects to ClickHouse in a loop
+func connectLoop() (*sql.DB, error) {
+	// ClickHouse configuration
+	userName := getEnv("CLICKHOUSE_USERNAME")
+	password := getEnv("CLICKHOUSE_PASSWORD")
+	databaseURL := getEnv("DB_URL")
+	if len(userName) == 0 || len(password) == 0 || len(databaseURL) == 0 {
+		return nil, fmt.Errorf("unable to load environment variables, CLICKHOUSE_USERNAME, CLICKHOUSE_PASSWORD and DB_URL must be defined")
+	}
+	var connect *sql.DB
+	if err := wait.PollImmediate(connRetryInterval, connTimeout, func() (bool, error) {
+		// Open the database and ping it
+		dataSourceName := fmt.Sprintf("%s?username=%s&password=%s", databaseURL, userName, password)
+		var err error
+		connect, err = openSql("clickhouse", dataSourceName)
+		if err != nil {
+			klog.ErrorS(err, "Failed to connect to ClickHouse")
+			return false, nil
+		}
+		if err := connect.Ping(); err != nil {
+			if exception, ok := err.(*clickhouse.Exception); ok {
+				klog.ErrorS(nil, "Failed to ping ClickHouse", "message", exception.Message)
+			} else {
+				klog.ErrorS(err, "Failed to ping ClickHouse")
+			}
+			return false, nil
+		} else {
+			return true, nil
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("failed to connect to ClickHouse after %s", connTimeout)
+	}
+	return connect, nil
+}
+
+// exportRound exports the flows inserted since the previous round. The flows
+// are exported again in the next round if the export fails, so a collector
+// may receive some flows twice.
+func exportRound(connect *sql.DB) {
+	if exportingProcess == nil {
+		if err := connectToCollector(); err != nil {
+			klog.ErrorS(err, "Failed to connect to the IPFIX collector", "address", exporterInput.CollectorAddress)
+			return
+		}
+	}
+	// timeInserted has a precision of one second, the flows inserted during
+	// the current second are exported in the next round.
+	exportTime := now().Truncate(time.Second).Add(-time.Second)
+	if !exportTime.After(lastExportTime) {
+		return
+	}
+	count, err := exportFlows(connect, lastExportTime, exportTime)
+	if err != nil {
+		klog.ErrorS(err, "Failed to export flows", "since", lastExportTime)
+		// The connection is reset in case the collector is the cause of the
+		// failure.
+		exportingProcess.CloseConnToCollector()
+		exportingProcess = nil
+		return
+	}
+	klog.V(2).InfoS("Exported flows", "count", count, "since", lastExportTime)
+	lastExportTime = exportTime
+}
+
+// connectToCollector starts the exporting process and sends the templates.
+func connectToCollector() error {
+	ep, err := newIPFIXExporter(exporterInput)
+	if err != nil {
+		return err
+	}
+	for _, template := range templates {
+		template.id = ep.NewTemplateID()
+		elements := make([]entities.InfoElementWithValue, len(template.elements))
+		for i, element := range template.elements {
+			if elements[i], err = entities.DecodeAndCreateInfoElementWithValue(element, nil); err != nil {
+				ep.CloseConnToCollector()
+				return err
+			}
+		}
+		if err := sendRecord(ep, entities.Template, template.id, elements); err != nil {
+			ep.CloseConnToCollector()
+			return fmt.Errorf("error when sending template: %v", err)
+		}
+	}
+	exportingProcess = ep
+	return nil
+}
+
+func sendRecord(ep ipfixExporter, setType entities.ContentType, templateID uint16, elements []entities.InfoElementWithValue) error {
+	set := entities.NewSet(false)
+	if err := set.PrepareSet(setType, templateID); err != nil {
+		return err
+	}
+	if err := set.AddRecord(elements, templateID); err != nil {
+		return err
+	}
+	_, err := ep.SendSet(set)
+	return err
+}
+
+// exportFlows sends the flows inserted in (since, until] to the collector, and
+// returns the number of flows sent.
+func exportFlows(connect *sql.DB, since, until time.Time) (int, error) {
+	selected := make([]string, len(templateColumns))
+	for i, column := range templateColumns {
+		selected[i] = fmt.Sprintf("toString(%s)", column)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE timeInserted > toDateTime(?, 'UTC') AND timeInserted <= toDateTime(?, 'UTC') ORDER BY timeInserted",
+		strings.Join(selected, ", "), tableName)
+	rows, err := connect.Query(query, since.UTC().Format(timeFormat), until.UTC().Format(timeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get flows from ClickHouse: %v", err)
+	}
+	defer rows.Close()
+	values := make([]string, len(templateColumns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, fmt.Errorf("failed to parse the data returned by database: %v", err)
+		}
+		template := templates[0]
+		if isIPv6Flow(values) {
+			template = templates[1]
+		}
+		elements := make([]entities.InfoElementWithValue, len(values))
+		for i, element := range template.elements {
+			if elements[i], err = newInfoElementWithValue(element, values[i]); err != nil {
+				return count, fmt.Errorf("invalid value %q of column %s: %v", values[i], templateColumns[i], err)
+			}
+		}
+		if err := sendRecord(exportingProcess, entities.Data, template.id, elements); err != nil {
+			return count, fmt.Errorf("error when sending flow: %v", err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to get flows from ClickHouse: %v", err)
+	}
+	return count, nil
+}
+
+// isIPv6Flow returns true if the IP columns of a flow hold IPv6 addresses.
+func isIPv6Flow(values []string) bool {
+	for i, column := range templateColumns {
+		if _, ok := ipElements[column]; ok && values[i] != "" {
+			return strings.Contains(values[i], ":")
+		}
+	}
+	return false
+}
+
+// newInfoElementWithValue returns an Information Element with a value of the
+// flows table, as returned by toString. Empty values, e.g. the destination
+// ClusterIP of the flows which are not to a Service, are exported as the zero
+// value.
+func newInfoElementWithValue(element *entities.InfoElement, value string) (entities.InfoElementWithValue, error) {
+	if value == "" {
+		return entities.DecodeAndCreateInfoElementWithValue(element, nil)
+	}
+	parseUint := func(bitSize int) (uint64, error) {
+		return strconv.ParseUint(value, 10, bitSize)
+	}
+	parseInt := func(bitSize int) (int64, error) {
+		return strconv.ParseInt(value, 10, bitSize)
+	}
+	switch element.DataType {
+	case entities.Unsigned8:
+		v, err := parseUint(8)
+		return entities.NewUnsigned8InfoElement(element, uint8(v)), err
+	case entities.Unsigned16:
+		v, err := parseUint(16)
+		return entities.NewUnsigned16InfoElement(element, uint16(v)), err
+	case entities.Unsigned32:
+		v, err := parseUint(32)
+		return entities.NewUnsigned32InfoElement(element, uint32(v)), err
+	case entities.Unsigned64:
+		v, err := parseUint(64)
+		return entities.NewUnsigned64InfoElement(element, v), err
+	case entities.Signed8:
+		v, err := parseInt(8)
+		return entities.NewSigned8InfoElement(element, int8(v)), err
+	case entities.Signed16:
+		v, err := parseInt(16)
+		return entities.NewSigned16InfoElement(element, int16(v)), err
+	case entities.Signed32:
+		v, err := parseInt(32)
+		return entities.NewSigned32InfoElement(element, int32(v)), err
+	case entities.Signed64:
+		v, err := parseInt(64)
+		return entities.NewSigned64InfoElement(element, v), err
+	case entities.Float32:
+		v, err := strconv.ParseFloat(value, 32)
+		return entities.NewFloat32InfoElement(element, float32(v)), err
+	case entities.Float64:
+		v, err := strconv.ParseFloat(value, 64)
+		return entities.NewFloat64InfoElement(element, v), err
+	case entities.Boolean:
+		v, err := strconv.ParseBool(value)
+		return entities.NewBoolInfoElement(element, v), err
+	case entities.String:
+		return entities.NewStringInfoElement(element, value), nil
+	case entities.DateTimeSeconds, entities.DateTimeMilliseconds:
+		t, err := time.Parse(timeFormat, value)
+		if err != nil {
+			return nil, err
+		}
+		if element.DataType == entities.DateTimeSeconds {
+			return entities.NewDateTimeSecondsInfoElement(element, uint32(t.Unix())), nil
+		}
+		return entities.NewDateTimeMillisecondsInfoElement(element, uint64(t.UnixMilli())), nil
+	case entities.Ipv4Address, entities.Ipv6Address:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address")
+		}
+		return entities.NewIPAddressInfoElement(element, ip), nil
+	}
+	return nil, fmt.Errorf("unsupported data type of Information Element %s", element.Name)
+}
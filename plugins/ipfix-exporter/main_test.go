@@ -0,0 +1,206 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/go-ipfix/pkg/entities"
+	"github.com/vmware/go-ipfix/pkg/exporter"
+	"github.com/vmware/go-ipfix/pkg/registry"
+)
+
+type fakeExporter struct {
+	templateID uint16
+	sets       []entities.Set
+	sendErr    error
+	closed     bool
+}
+
+func (e *fakeExporter) NewTemplateID() uint16 {
+	e.templateID++
+	return e.templateID + 255
+}
+
+func (e *fakeExporter) SendSet(set entities.Set) (int, error) {
+	if e.sendErr != nil {
+		return 0, e.sendErr
+	}
+	e.sets = append(e.sets, set)
+	return set.GetSetLength(), nil
+}
+
+func (e *fakeExporter) CloseConnToCollector() {
+	e.closed = true
+}
+
+func initEnv(env map[string]string) {
+	getEnv = func(key string) string {
+		if value, ok := env[key]; ok {
+			return value
+		}
+		return map[string]string{
+			"TABLE_NAME":         "default.flows",
+			"COLLECTOR_ADDRESS":  "collector:4739",
+			"COLLECTOR_PROTOCOL": "tcp",
+			"EXPORT_INTERVAL":    "1m",
+			"TEMPLATE_COLUMNS":   "flowEndSeconds sourceIP destinationIP destinationTransportPort octetTotalCount reverseOctetTotalCount sourcePodName ingressNetworkPolicyRuleAction throughput",
+		}[key]
+	}
+}
+
+func TestLoadEnvVariables(t *testing.T) {
+	registry.LoadRegistry()
+	for _, tt := range []struct {
+		name          string
+		env           map[string]string
+		expectedError string
+	}{
+		{
+			name: "Valid",
+		},
+		{
+			name:          "Missing collector",
+			env:           map[string]string{"COLLECTOR_ADDRESS": ""},
+			expectedError: "unable to load environment variables, TABLE_NAME, COLLECTOR_ADDRESS, COLLECTOR_PROTOCOL and EXPORT_INTERVAL must be defined",
+		},
+		{
+			name:          "Invalid protocol",
+			env:           map[string]string{"COLLECTOR_PROTOCOL": "sctp"},
+			expectedError: "invalid COLLECTOR_PROTOCOL sctp, it should be tcp or udp",
+		},
+		{
+			name:          "Invalid table",
+			env:           map[string]string{"TABLE_NAME": "flows; DROP TABLE flows"},
+			expectedError: "invalid TABLE_NAME: not a valid identifier",
+		},
+		{
+			name:          "Column without Information Element",
+			env:           map[string]string{"TEMPLATE_COLUMNS": "sourceIP clusterUUID"},
+			expectedError: "invalid TEMPLATE_COLUMNS: column clusterUUID has no IPFIX Information Element",
+		},
+		{
+			name:          "TLS with UDP",
+			env:           map[string]string{"COLLECTOR_PROTOCOL": "udp", "COLLECTOR_CA_CERT": "/etc/ipfix/ca.crt"},
+			expectedError: "COLLECTOR_CA_CERT is only supported with the tcp COLLECTOR_PROTOCOL",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			initEnv(tt.env)
+			err := loadEnvVariables()
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "default.flows", tableName)
+			assert.Equal(t, time.Minute, exportInterval)
+			assert.Equal(t, "collector:4739", exporterInput.CollectorAddress)
+			assert.Equal(t, "sourceIPv4Address", templates[0].elements[1].Name)
+			assert.Equal(t, "sourceIPv6Address", templates[1].elements[1].Name)
+			assert.Equal(t, registry.IANAReversedEnterpriseID, templates[0].elements[5].EnterpriseId)
+			assert.Equal(t, registry.AntreaEnterpriseID, templates[0].elements[6].EnterpriseId)
+		})
+	}
+}
+
+func TestExportRound(t *testing.T) {
+	registry.LoadRegistry()
+	initEnv(nil)
+	require.NoError(t, loadEnvVariables())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ep := &fakeExporter{}
+	newIPFIXExporter = func(input exporter.ExporterInput) (ipfixExporter, error) {
+		assert.Equal(t, "collector:4739", input.CollectorAddress)
+		return ep, nil
+	}
+	baseTime := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return baseTime.Add(90 * time.Second) }
+	lastExportTime = baseTime
+	exportingProcess = nil
+
+	query := regexp.QuoteMeta("SELECT toString(flowEndSeconds), toString(sourceIP), toString(destinationIP), toString(destinationTransportPort), toString(octetTotalCount), toString(reverseOctetTotalCount), toString(sourcePodName), toString(ingressNetworkPolicyRuleAction), toString(throughput) FROM default.flows WHERE timeInserted > toDateTime(?, 'UTC') AND timeInserted <= toDateTime(?, 'UTC') ORDER BY timeInserted")
+	columns := []string{"flowEndSeconds", "sourceIP", "destinationIP", "destinationTransportPort", "octetTotalCount", "reverseOctetTotalCount", "sourcePodName", "ingressNetworkPolicyRuleAction", "throughput"}
+	mock.ExpectQuery(query).
+		WithArgs("2022-08-01 12:00:00", "2022-08-01 12:01:29").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("2022-08-01 12:00:30", "10.10.0.4", "10.10.1.5", "80", "1024", "2048", "frontend", "2", "").
+			AddRow("2022-08-01 12:00:31", "fd00:10:10::4", "fd00:10:10::5", "443", "512", "0", "", "0", "4096"))
+	exportRound(db)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, baseTime.Add(89*time.Second), lastExportTime)
+
+	// The IPv4 and IPv6 templates are sent first, then a data set per flow.
+	require.Len(t, ep.sets, 4)
+	assert.Equal(t, entities.Template, ep.sets[0].GetSetType())
+	assert.Equal(t, entities.Template, ep.sets[1].GetSetType())
+	ipv4Record := ep.sets[2].GetRecords()[0]
+	assert.Equal(t, uint16(256), ipv4Record.GetTemplateID())
+	element, _, _ := ipv4Record.GetInfoElementWithValue("sourceIPv4Address")
+	assert.Equal(t, net.ParseIP("10.10.0.4").To4(), element.GetIPAddressValue().To4())
+	element, _, _ = ipv4Record.GetInfoElementWithValue("flowEndSeconds")
+	assert.Equal(t, uint32(baseTime.Add(30*time.Second).Unix()), element.GetUnsigned32Value())
+	element, _, _ = ipv4Record.GetInfoElementWithValue("reverseOctetTotalCount")
+	assert.Equal(t, uint64(2048), element.GetUnsigned64Value())
+	element, _, _ = ipv4Record.GetInfoElementWithValue("sourcePodName")
+	assert.Equal(t, "frontend", element.GetStringValue())
+	element, _, _ = ipv4Record.GetInfoElementWithValue("ingressNetworkPolicyRuleAction")
+	assert.Equal(t, registry.NetworkPolicyRuleActionDrop, element.GetUnsigned8Value())
+	ipv6Record := ep.sets[3].GetRecords()[0]
+	assert.Equal(t, uint16(257), ipv6Record.GetTemplateID())
+	element, _, _ = ipv6Record.GetInfoElementWithValue("destinationIPv6Address")
+	assert.Equal(t, net.ParseIP("fd00:10:10::5"), element.GetIPAddressValue())
+	element, _, _ = ipv6Record.GetInfoElementWithValue("throughput")
+	assert.Equal(t, uint64(4096), element.GetUnsigned64Value())
+
+	// The flows are exported again after a failure.
+	ep.sendErr = fmt.Errorf("connection reset")
+	now = func() time.Time { return baseTime.Add(150 * time.Second) }
+	mock.ExpectQuery(query).
+		WithArgs("2022-08-01 12:01:29", "2022-08-01 12:02:29").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("2022-08-01 12:01:40", "10.10.0.4", "10.10.1.5", "80", "1024", "2048", "frontend", "2", ""))
+	exportRound(db)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.True(t, ep.closed)
+	assert.Nil(t, exportingProcess)
+	assert.Equal(t, baseTime.Add(89*time.Second), lastExportTime)
+}
+
+func TestNewInfoElementWithValue(t *testing.T) {
+	registry.LoadRegistry()
+	element, err := registry.GetInfoElement("destinationTransportPort", registry.IANAEnterpriseID)
+	require.NoError(t, err)
+	_, err = newInfoElementWithValue(element, "65536")
+	assert.Error(t, err)
+	value, err := newInfoElementWithValue(element, "")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0), value.GetUnsigned16Value())
+
+	element, err = registry.GetInfoElement("destinationClusterIPv4", registry.AntreaEnterpriseID)
+	require.NoError(t, err)
+	_, err = newInfoElementWithValue(element, "not-an-ip")
+	assert.EqualError(t, err, "invalid IP address")
+}
@@ -14,17 +14,22 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import copy
 import datetime
 import getopt
+import io
 import json
 import logging
 import os
 import random
 import string
 import sys
+import time
 import uuid
 
+import boto3
 import kubernetes.client
+import yaml
 from pyspark.sql import SparkSession
 from pyspark.sql.functions import udf
 from pyspark.sql.types import StringType
@@ -33,7 +38,7 @@ from urllib.parse import urlparse
 import antrea_crd
 from policy_recommendation_utils import (
     is_intstring,
-    get_IP_version,
+    get_IP_block_cidr,
     dict_to_yaml,
 )
 
@@ -51,12 +56,27 @@ FLOW_TABLE_COLUMNS = [
     'flowType',
 ]
 
+# Volume in bytes of the flow records, used to order the recommended rules
+FLOW_VOLUME_COLUMN = "sum(octetDeltaCount) AS volume"
+
+# Conditions selecting the flow records of each address family. Dual-stack
+# recommendations consider the flow records of both families.
+ADDRESS_FAMILY_FILTERS = {
+    "ipv4": "isIPv4String(destinationIP)",
+    "ipv6": "isIPv6String(destinationIP)",
+}
+ADDRESS_FAMILIES = ["ipv4", "ipv6", "dual"]
+
 NAMESPACE_ALLOW_LIST = ["kube-system", "flow-aggregator", "flow-visibility"]
 
 ROW_DELIMITER = "#"
 PEER_DELIMITER = "|"
 DEFAULT_POLICY_PRIORITY = 5
 
+# Endpoint of the S3-compatible XML API of Google Cloud Storage, used for gs://
+# artifacts URIs
+GCS_ENDPOINT = "https://storage.googleapis.com"
+
 MEANINGLESS_LABELS = [
     "pod-template-hash",
     "controller-revision-hash",
@@ -74,10 +94,23 @@ ch.setFormatter(formatter)
 logger.addHandler(ch)
 
 
-def get_flow_type(flowType, destinationServicePortName, destinationPodLabels):
+def get_svc_namespaced_name(destinationServicePortName):
+    return destinationServicePortName.partition(":")[0]
+
+
+def get_flow_type(
+    flowType,
+    destinationServicePortName,
+    destinationPodLabels,
+    endpoint_svcs=(),
+):
     if flowType == 3:
         return "pod_to_external"
-    elif destinationServicePortName != "":
+    elif destinationServicePortName != "" and not (
+        destinationPodLabels != ""
+        and get_svc_namespaced_name(destinationServicePortName)
+        in endpoint_svcs
+    ):
         return "pod_to_svc"
     elif destinationPodLabels != "":
         return "pod_to_pod"
@@ -179,6 +212,47 @@ def combine_network_peers(a, b):
     return (new_src, new_dst)
 
 
+def ingress_rule_key(flow):
+    applied_to, (src, _) = map_flow_to_ingress(flow)
+    return (applied_to, "ingress", src)
+
+
+def egress_rule_key(flow, k8s=False):
+    applied_to, (_, dst) = map_flow_to_egress(flow, k8s)
+    return (applied_to, "egress", dst)
+
+
+def egress_svc_rule_key(flow):
+    applied_to, dst = map_flow_to_egress_svc(flow)
+    return (applied_to, "egress", dst)
+
+
+# Returns the volume in bytes of the flows matching each recommended rule,
+# keyed by the (appliedTo, direction, peer) tuple returned by rule_key
+def sum_rule_volumes(flows_df, rule_key):
+    if "volume" not in flows_df.columns:
+        return {}
+    return (
+        flows_df.rdd.map(lambda flow: (rule_key(flow), flow.volume))
+        .reduceByKey(lambda a, b: a + b)
+        .collectAsMap()
+    )
+
+
+# Returns the distinct peers of the rules of a policy, ordered by decreasing
+# volume of the matching flows, so that the hottest flows match the first
+# rules, then lexically
+def sort_rule_peers(applied_to, direction, peers, rule_volumes=None):
+    rule_volumes = rule_volumes or {}
+    return sorted(
+        set(peers),
+        key=lambda peer: (
+            -rule_volumes.get((applied_to, direction, peer), 0),
+            peer,
+        ),
+    )
+
+
 def generate_k8s_egress_rule(egress):
     if len(egress.split(ROW_DELIMITER)) == 4:
         ns, labels, port, protocolIdentifier = egress.split(ROW_DELIMITER)
@@ -192,10 +266,7 @@ def generate_k8s_egress_rule(egress):
         )
     elif len(egress.split(ROW_DELIMITER)) == 3:
         destinationIP, port, protocolIdentifier = egress.split(ROW_DELIMITER)
-        if get_IP_version(destinationIP) == "v4":
-            cidr = destinationIP + "/32"
-        else:
-            cidr = destinationIP + "/128"
+        cidr = get_IP_block_cidr(destinationIP)
         egress_peer = kubernetes.client.V1NetworkPolicyPeer(
             ip_block=kubernetes.client.V1IPBlock(
                 cidr=cidr,
@@ -244,13 +315,17 @@ def generate_policy_name(info):
     )
 
 
-def generate_k8s_np(x):
+def generate_k8s_np(x, rule_volumes=None):
     applied_to, (ingresses, egresses) = x
     ns, labels = applied_to.split(ROW_DELIMITER)
     if ns in NAMESPACE_ALLOW_LIST:
         return []
-    ingress_list = list(set(ingresses.split(PEER_DELIMITER)))
-    egress_list = list(set(egresses.split(PEER_DELIMITER)))
+    ingress_list = sort_rule_peers(
+        applied_to, "ingress", ingresses.split(PEER_DELIMITER), rule_volumes
+    )
+    egress_list = sort_rule_peers(
+        applied_to, "egress", egresses.split(PEER_DELIMITER), rule_volumes
+    )
     egressRules = []
     for egress in egress_list:
         if ROW_DELIMITER in egress:
@@ -317,10 +392,7 @@ def generate_anp_egress_rule(egress):
     elif len(egress.split(ROW_DELIMITER)) == 3:
         # Pod-to-External flow
         destinationIP, port, protocolIdentifier = egress.split(ROW_DELIMITER)
-        if get_IP_version(destinationIP) == "v4":
-            cidr = destinationIP + "/32"
-        else:
-            cidr = destinationIP + "/128"
+        cidr = get_IP_block_cidr(destinationIP)
         egress_peer = antrea_crd.NetworkPolicyPeer(
             ip_block=antrea_crd.IPBlock(
                 CIDR=cidr,
@@ -378,7 +450,7 @@ def generate_anp_ingress_rule(ingress):
     return ingress_rule
 
 
-def generate_anp(network_peers):
+def generate_anp(network_peers, rule_volumes=None):
     applied_to, (ingresses, egresses) = network_peers
     ns, labels = applied_to.split(ROW_DELIMITER)
     if ns in NAMESPACE_ALLOW_LIST:
@@ -393,8 +465,12 @@ def generate_anp(network_peers):
             )
         )
         return []
-    ingress_list = list(set(ingresses.split(PEER_DELIMITER)))
-    egress_list = list(set(egresses.split(PEER_DELIMITER)))
+    ingress_list = sort_rule_peers(
+        applied_to, "ingress", ingresses.split(PEER_DELIMITER), rule_volumes
+    )
+    egress_list = sort_rule_peers(
+        applied_to, "egress", egresses.split(PEER_DELIMITER), rule_volumes
+    )
     egressRules = []
     for egress in egress_list:
         if ROW_DELIMITER in egress:
@@ -479,7 +555,7 @@ def generate_acnp_svc_egress_rule(egress):
     return egress_rule
 
 
-def generate_svc_acnp(x):
+def generate_svc_acnp(x, rule_volumes=None):
     applied_to, egresses = x
     ns, labels = applied_to.split(ROW_DELIMITER)
     if ns in NAMESPACE_ALLOW_LIST:
@@ -494,7 +570,9 @@ def generate_svc_acnp(x):
             )
         )
         return []
-    egress_list = egresses.split(PEER_DELIMITER)
+    egress_list = sort_rule_peers(
+        applied_to, "egress", egresses.split(PEER_DELIMITER), rule_volumes
+    )
     egressRules = []
     for egress in egress_list:
         egressRules.append(generate_acnp_svc_egress_rule(egress))
@@ -527,7 +605,7 @@ def generate_svc_acnp(x):
         return []
 
 
-def generate_reject_acnp(applied_to):
+def generate_reject_acnp(applied_to, deny_action="Reject"):
     if not applied_to:
         np_name = "recommend-reject-all-acnp"
         applied_to = antrea_crd.NetworkPolicyPeer(
@@ -569,7 +647,7 @@ def generate_reject_acnp(applied_to):
             applied_to=[applied_to],
             egress=[
                 antrea_crd.Rule(
-                    action="Reject",
+                    action=deny_action,
                     to=[
                         antrea_crd.NetworkPolicyPeer(
                             pod_selector=kubernetes.client.V1LabelSelector()
@@ -579,7 +657,7 @@ def generate_reject_acnp(applied_to):
             ],
             ingress=[
                 antrea_crd.Rule(
-                    action="Reject",
+                    action=deny_action,
                     _from=[
                         antrea_crd.NetworkPolicyPeer(
                             pod_selector=kubernetes.client.V1LabelSelector()
@@ -592,7 +670,7 @@ def generate_reject_acnp(applied_to):
     return [dict_to_yaml(np.to_dict())]
 
 
-def recommend_k8s_policies(flows_df):
+def recommend_k8s_policies(flows_df, rule_ordering="volume"):
     egress_rdd = flows_df.rdd.map(
         lambda flow: map_flow_to_egress(flow, k8s=True)
     ).reduceByKey(lambda a, b: ("", a[1] + PEER_DELIMITER + b[1]))
@@ -604,13 +682,31 @@ def recommend_k8s_policies(flows_df):
     network_peers_rdd = ingress_rdd.union(egress_rdd).reduceByKey(
         combine_network_peers
     )
-    k8s_np_rdd = network_peers_rdd.flatMap(generate_k8s_np)
+    rule_volumes = {}
+    if rule_ordering == "volume":
+        rule_volumes = sum_rule_volumes(
+            flows_df.filter(flows_df.flowType != "pod_to_external"),
+            ingress_rule_key,
+        )
+        rule_volumes.update(
+            sum_rule_volumes(
+                flows_df, lambda flow: egress_rule_key(flow, k8s=True)
+            )
+        )
+    k8s_np_rdd = network_peers_rdd.flatMap(
+        lambda x: generate_k8s_np(x, rule_volumes)
+    )
     k8s_np_list = k8s_np_rdd.collect()
     return k8s_np_list
 
 
 def recommend_antrea_policies(
-    flows_df, option=1, deny_rules=True, to_services=True
+    flows_df,
+    option=1,
+    deny_rules=True,
+    to_services=True,
+    deny_action="Reject",
+    rule_ordering="volume",
 ):
     ingress_rdd = (
         flows_df.filter(flows_df.flowType != "pod_to_external")
@@ -631,7 +727,18 @@ def recommend_antrea_policies(
     network_peers_rdd = ingress_rdd.union(egress_rdd).reduceByKey(
         combine_network_peers
     )
-    anp_rdd = network_peers_rdd.flatMap(generate_anp)
+    rule_volumes = {}
+    if rule_ordering == "volume":
+        rule_volumes = sum_rule_volumes(
+            flows_df.filter(flows_df.flowType != "pod_to_external"),
+            ingress_rule_key,
+        )
+        rule_volumes.update(
+            sum_rule_volumes(unprotected_flows_df, egress_rule_key)
+        )
+    anp_rdd = network_peers_rdd.flatMap(
+        lambda x: generate_anp(x, rule_volumes)
+    )
     anp_list = anp_rdd.collect()
     # If toServices feature is not enabled, recommend clusterGroup for
     # Services and allow Antrea Cluster Network Policies for unprotected
@@ -649,7 +756,13 @@ def recommend_antrea_policies(
         egress_svc_rdd = unprotected_svc_flows_df.rdd.map(
             map_flow_to_egress_svc
         ).reduceByKey(lambda a, b: a + PEER_DELIMITER + b)
-        svc_acnp_rdd = egress_svc_rdd.flatMap(generate_svc_acnp)
+        if rule_ordering == "volume":
+            rule_volumes.update(
+                sum_rule_volumes(unprotected_svc_flows_df, egress_svc_rule_key)
+            )
+        svc_acnp_rdd = egress_svc_rdd.flatMap(
+            lambda x: generate_svc_acnp(x, rule_volumes)
+        )
         svc_acnp_list = svc_acnp_rdd.collect()
     if deny_rules:
         if option == 1:
@@ -662,37 +775,51 @@ def recommend_antrea_policies(
                 )
             else:
                 applied_groups_rdd = network_peers_rdd.map(lambda x: x[0])
-            deny_anp_rdd = applied_groups_rdd.flatMap(generate_reject_acnp)
+            deny_anp_rdd = applied_groups_rdd.flatMap(
+                lambda x: generate_reject_acnp(x, deny_action)
+            )
             deny_anp_list = deny_anp_rdd.collect()
             return anp_list + svc_cg_list + svc_acnp_list + deny_anp_list
         else:
             # Recommend deny ACNP for whole cluster
-            deny_all_policy = generate_reject_acnp("")
+            deny_all_policy = generate_reject_acnp("", deny_action)
             return anp_list + svc_cg_list + svc_acnp_list + [deny_all_policy]
     else:
         return anp_list + svc_cg_list + svc_acnp_list
 
 
 def recommend_policies_for_unprotected_flows(
-    unprotected_flows_df, option=1, to_services=True
+    unprotected_flows_df,
+    option=1,
+    to_services=True,
+    deny_action="Reject",
+    rule_ordering="volume",
 ):
     if option not in [1, 2, 3]:
         logger.error("Error: option {} is not valid".format(option))
         return []
     if option == 3:
         # Recommend K8s native NetworkPolicies for unprotected flows
-        return recommend_k8s_policies(unprotected_flows_df)
+        return recommend_k8s_policies(unprotected_flows_df, rule_ordering)
     else:
         return recommend_antrea_policies(
-            unprotected_flows_df, option, True, to_services
+            unprotected_flows_df,
+            option,
+            True,
+            to_services,
+            deny_action,
+            rule_ordering,
         )
 
 
 def recommend_policies_for_trusted_denied_flows(
-    trusted_denied_flows_df, to_services
+    trusted_denied_flows_df, to_services, rule_ordering="volume"
 ):
     return recommend_antrea_policies(
-        trusted_denied_flows_df, deny_rules=False, to_services=to_services
+        trusted_denied_flows_df,
+        deny_rules=False,
+        to_services=to_services,
+        rule_ordering=rule_ordering,
     )
 
 
@@ -744,9 +871,49 @@ def recommend_policies_for_ns_allow_list(ns_allow_list):
     return policies
 
 
-def generate_sql_query(table_name, limit, start_time, end_time, unprotected):
-    sql_query = "SELECT {} FROM {}".format(
-        ", ".join(FLOW_TABLE_COLUMNS), table_name
+def generate_label_selector_condition(labels_column, selector):
+    """Returns the SQL condition matching the Pods whose labels, stored as a
+    JSON object in labels_column, match the LabelSelector selector."""
+    expressions = [
+        {"key": key, "operator": "In", "values": [value]}
+        for key, value in sorted((selector.get("matchLabels") or {}).items())
+    ] + (selector.get("matchExpressions") or [])
+    conditions = []
+    for expression in expressions:
+        has_key = "JSONHas({}, '{}')".format(labels_column, expression["key"])
+        operator = expression["operator"]
+        if operator in ("In", "NotIn"):
+            condition = "({} AND JSONExtractString({}, '{}') IN ({}))".format(
+                has_key,
+                labels_column,
+                expression["key"],
+                ", ".join(
+                    "'{}'".format(value) for value in expression["values"]
+                ),
+            )
+            if operator == "NotIn":
+                condition = "NOT " + condition
+        elif operator == "Exists":
+            condition = has_key
+        else:
+            condition = "NOT " + has_key
+        conditions.append(condition)
+    return "({})".format(" AND ".join(conditions)) if conditions else "1"
+
+
+def generate_sql_query(
+    table_name,
+    limit,
+    start_time,
+    end_time,
+    unprotected,
+    ns_scope=None,
+    address_family="dual",
+    ns_exclude=None,
+    pod_selector=None,
+):
+    sql_query = "SELECT {}, {} FROM {}".format(
+        ", ".join(FLOW_TABLE_COLUMNS), FLOW_VOLUME_COLUMN, table_name
     )
     if unprotected:
         sql_query += " WHERE ingressNetworkPolicyName == '' \
@@ -758,13 +925,37 @@ AND egressNetworkPolicyName == ''"
         sql_query += " AND flowStartSeconds >= '{}'".format(start_time)
     if end_time:
         sql_query += " AND flowEndSeconds < '{}'".format(end_time)
+    if ns_scope:
+        ns_list = ", ".join("'{}'".format(ns) for ns in ns_scope)
+        sql_query += " AND (sourcePodNamespace IN ({0}) \
+OR destinationPodNamespace IN ({0}))".format(ns_list)
+    if ns_exclude:
+        # Keep the flows from or to the Pods of at least one Namespace which
+        # is not excluded.
+        ns_list = ", ".join("'{}'".format(ns) for ns in ns_exclude)
+        sql_query += " AND ((sourcePodNamespace != '' \
+AND sourcePodNamespace NOT IN ({0})) OR (destinationPodNamespace != '' \
+AND destinationPodNamespace NOT IN ({0})))".format(ns_list)
+    if pod_selector:
+        # Keep the flows from or to at least one Pod matching the selector.
+        sql_query += " AND ((sourcePodName != '' AND {}) \
+OR (destinationPodName != '' AND {}))".format(
+            generate_label_selector_condition("sourcePodLabels", pod_selector),
+            generate_label_selector_condition(
+                "destinationPodLabels", pod_selector
+            ),
+        )
+    if address_family in ADDRESS_FAMILY_FILTERS:
+        sql_query += " AND " + ADDRESS_FAMILY_FILTERS[address_family]
     sql_query += " GROUP BY {}".format(", ".join(FLOW_TABLE_COLUMNS))
     if limit:
         sql_query += " LIMIT {}".format(limit)
     return sql_query
 
 
-def read_flow_df(spark, db_jdbc_address, sql_query, rm_labels):
+def read_flow_df(
+    spark, db_jdbc_address, sql_query, rm_labels, endpoint_svcs=()
+):
     flow_df = (
         spark.read.format("jdbc")
         .option("driver", "ru.yandex.clickhouse.ClickHouseDriver")
@@ -790,15 +981,291 @@ def read_flow_df(spark, db_jdbc_address, sql_query, rm_labels):
             )
             .dropDuplicates(["sourcePodLabels", "destinationPodLabels"])
         )
+    # The flows to the endpoints of the Services in endpoint_svcs are
+    # recommended endpoint rules, as toServices rules would not match the
+    # traffic reaching the endpoints without going through the ClusterIP.
+    endpoint_svcs = frozenset(endpoint_svcs)
     flow_df = flow_df.withColumn(
         "flowType",
-        udf(get_flow_type, StringType())(
-            "flowType", "destinationServicePortName", "destinationPodLabels"
-        ),
+        udf(
+            lambda flowType, svc, labels: get_flow_type(
+                flowType, svc, labels, endpoint_svcs
+            ),
+            StringType(),
+        )("flowType", "destinationServicePortName", "destinationPodLabels"),
     )
+    if spark.sparkContext.getCheckpointDir():
+        # Save the flow records to the checkpoint directory, so that they are
+        # not read again from the database when executors are lost.
+        flow_df = flow_df.checkpoint()
     return flow_df
 
 
+def get_policy_namespace(policy):
+    """Returns the Namespace of a recommended policy, or an empty string for
+    the cluster-scoped policies and ClusterGroups."""
+    metadata = yaml.safe_load(policy).get("metadata") or {}
+    return metadata.get("namespace") or ""
+
+
+def split_result(result, batch_size):
+    """Splits the recommended policies by Namespace, and the policies of each
+    Namespace into batches of at most batch_size policies. Each batch is
+    written as a separate part of the result, so that the result can be
+    retrieved by Namespace. A batch_size of 0 keeps all the policies of a
+    Namespace in a single part. Returns (namespace, part, policies) tuples."""
+    policies_by_ns = {}
+    for policy in filter(None, result):
+        policies_by_ns.setdefault(get_policy_namespace(policy), []).append(
+            policy
+        )
+    if not policies_by_ns:
+        return [("", 0, [])]
+    parts = []
+    for namespace in sorted(policies_by_ns):
+        policies = policies_by_ns[namespace]
+        if batch_size <= 0:
+            batches = [policies]
+        else:
+            batches = [
+                policies[i:i + batch_size]
+                for i in range(0, len(policies), batch_size)
+            ]
+        for part, batch in enumerate(batches):
+            parts.append((namespace, part, batch))
+    return parts
+
+
+def get_exclusion_key(policy):
+    """Returns the key identifying the Pods a recommended policy applies to:
+    its kind, its Namespace and its spec without its rules. For ClusterGroups,
+    the key identifies the whole group."""
+    metadata = policy.get("metadata") or {}
+    spec = {
+        key: value
+        for key, value in (policy.get("spec") or {}).items()
+        if key not in ("ingress", "egress", "policyTypes")
+    }
+    return json.dumps(
+        [policy.get("kind"), metadata.get("namespace") or "", spec],
+        sort_keys=True,
+    )
+
+
+def get_rule_key(direction, rule):
+    return json.dumps([direction, rule], sort_keys=True)
+
+
+def apply_exclusions(result, exclusions):
+    """Removes the rules of the excluded policies, i.e. the policies rejected
+    by users in previous recommendations, from the recommended policies
+    applying to the same Pods. The policies left without rules, and the
+    excluded ClusterGroups, are not recommended. The directions left without
+    rules are removed from the policyTypes of K8s NetworkPolicies, so that
+    the Pods are not isolated in these directions."""
+    if not exclusions:
+        return result
+    excluded_rules = {}
+    for excluded in exclusions:
+        rules = excluded_rules.setdefault(get_exclusion_key(excluded), set())
+        spec = excluded.get("spec") or {}
+        for direction in ("ingress", "egress"):
+            for rule in spec.get(direction) or []:
+                rules.add(get_rule_key(direction, rule))
+    filtered = []
+    for policy_yaml in result:
+        if not policy_yaml:
+            filtered.append(policy_yaml)
+            continue
+        policy = yaml.safe_load(policy_yaml)
+        key = get_exclusion_key(policy)
+        if key not in excluded_rules:
+            filtered.append(policy_yaml)
+            continue
+        rules = excluded_rules[key]
+        if not rules:
+            # excluded ClusterGroup
+            continue
+        spec = policy.get("spec") or {}
+        changed = False
+        for direction in ("ingress", "egress"):
+            policy_rules = spec.get(direction) or []
+            kept = [
+                rule
+                for rule in policy_rules
+                if get_rule_key(direction, rule) not in rules
+            ]
+            if len(kept) == len(policy_rules):
+                continue
+            changed = True
+            spec[direction] = kept
+            policy_type = direction.capitalize()
+            if not kept and policy_type in (spec.get("policyTypes") or []):
+                spec["policyTypes"].remove(policy_type)
+        if not changed:
+            filtered.append(policy_yaml)
+        elif spec.get("ingress") or spec.get("egress"):
+            filtered.append(yaml.dump(policy))
+    return filtered
+
+
+def get_policy_target_namespaces(policy):
+    """Returns the Namespaces of the Pods a recommended policy applies to, or
+    None if it applies to the Pods of all Namespaces. ClusterGroups apply to
+    no Pods."""
+    metadata = policy.get("metadata") or {}
+    if metadata.get("namespace"):
+        return [metadata["namespace"]]
+    namespaces = []
+    for peer in (policy.get("spec") or {}).get("appliedTo") or []:
+        match_labels = (peer.get("namespaceSelector") or {}).get(
+            "matchLabels"
+        ) or {}
+        if "kubernetes.io/metadata.name" not in match_labels:
+            return None
+        namespaces.append(match_labels["kubernetes.io/metadata.name"])
+    return namespaces
+
+
+def get_policy_groups(policy):
+    """Returns the names of the ClusterGroups referenced by the rules of a
+    recommended policy."""
+    groups = set()
+    spec = policy.get("spec") or {}
+    for rule in (spec.get("ingress") or []) + (spec.get("egress") or []):
+        for peer in (rule.get("from") or []) + (rule.get("to") or []):
+            if peer.get("group"):
+                groups.add(peer["group"])
+    return groups
+
+
+def filter_target_namespaces(result, target_allow, target_deny):
+    """Only keeps the recommended policies applying to the Pods of the target
+    Namespaces, i.e. of the Namespaces in target_allow if it is not empty and
+    not in target_deny. The policies applying to the Pods of all Namespaces,
+    e.g. the cluster-wide default deny policy, are restricted to the target
+    Namespaces, and the ClusterGroups are only kept if they are referenced by
+    the kept policies."""
+    if not target_allow and not target_deny:
+        return result
+
+    def is_target(ns):
+        if ns in target_deny:
+            return False
+        return not target_allow or ns in target_allow
+
+    filtered = []
+    cluster_groups = []
+    groups = set()
+    for policy_yaml in result:
+        if not policy_yaml:
+            filtered.append(policy_yaml)
+            continue
+        policy = yaml.safe_load(policy_yaml)
+        if policy.get("kind") == "ClusterGroup":
+            cluster_groups.append((policy, policy_yaml))
+            continue
+        namespaces = get_policy_target_namespaces(policy)
+        if namespaces is None:
+            expressions = []
+            if target_allow:
+                expressions.append(
+                    {
+                        "key": "kubernetes.io/metadata.name",
+                        "operator": "In",
+                        "values": sorted(target_allow),
+                    }
+                )
+            if target_deny:
+                expressions.append(
+                    {
+                        "key": "kubernetes.io/metadata.name",
+                        "operator": "NotIn",
+                        "values": sorted(target_deny),
+                    }
+                )
+            for peer in policy["spec"]["appliedTo"]:
+                peer["namespaceSelector"] = {"matchExpressions": expressions}
+            policy_yaml = yaml.dump(policy)
+        elif not all(is_target(ns) for ns in namespaces):
+            continue
+        filtered.append(policy_yaml)
+        groups |= get_policy_groups(policy)
+    for policy, policy_yaml in cluster_groups:
+        if (policy.get("metadata") or {}).get("name") in groups:
+            filtered.append(policy_yaml)
+    return filtered
+
+
+def match_label_selector(selector, labels):
+    """Returns whether the labels of a Pod match the LabelSelector
+    selector."""
+    for key, value in (selector.get("matchLabels") or {}).items():
+        if labels.get(key) != value:
+            return False
+    for expression in selector.get("matchExpressions") or []:
+        key = expression["key"]
+        operator = expression["operator"]
+        if operator == "In" and labels.get(key) not in (
+            expression.get("values") or []
+        ):
+            return False
+        if operator == "NotIn" and key in labels and labels[key] in (
+            expression.get("values") or []
+        ):
+            return False
+        if operator == "Exists" and key not in labels:
+            return False
+        if operator == "DoesNotExist" and key in labels:
+            return False
+    return True
+
+
+def filter_pod_label_selector(result, pod_selector):
+    """Only keeps the recommended policies applying to the Pods matching the
+    LabelSelector pod_selector. The policies applying to all the Pods of
+    their Namespaces, e.g. the default deny policies, are restricted to the
+    matching Pods, and the ClusterGroups are only kept if they are referenced
+    by the kept policies."""
+    if not pod_selector:
+        return result
+    filtered = []
+    cluster_groups = []
+    groups = set()
+    for policy_yaml in result:
+        if not policy_yaml:
+            filtered.append(policy_yaml)
+            continue
+        policy = yaml.safe_load(policy_yaml)
+        if policy.get("kind") == "ClusterGroup":
+            cluster_groups.append((policy, policy_yaml))
+            continue
+        spec = policy.get("spec") or {}
+        # The podSelector of K8s NetworkPolicies is in their spec.
+        peers = spec["appliedTo"] if "appliedTo" in spec else [spec]
+        matched = False
+        restricted = False
+        for peer in peers:
+            pod_labels = (peer.get("podSelector") or {}).get("matchLabels")
+            if pod_labels:
+                matched = matched or match_label_selector(
+                    pod_selector, pod_labels
+                )
+            else:
+                peer["podSelector"] = copy.deepcopy(pod_selector)
+                restricted = True
+        if not matched and not restricted:
+            continue
+        if restricted:
+            policy_yaml = yaml.dump(policy)
+        filtered.append(policy_yaml)
+        groups |= get_policy_groups(policy)
+    for policy, policy_yaml in cluster_groups:
+        if (policy.get("metadata") or {}).get("name") in groups:
+            filtered.append(policy_yaml)
+    return filtered
+
+
 def write_recommendation_result(
     spark,
     result,
@@ -806,30 +1273,84 @@ def write_recommendation_result(
     db_jdbc_address,
     table_name,
     recommendation_id_input,
+    write_batch_size=0,
+    write_rate=0,
 ):
     if not recommendation_id_input:
         recommendation_id = str(uuid.uuid4())
     else:
         recommendation_id = recommendation_id_input
-    result_dict = {
-        "id": recommendation_id,
-        "type": recommendation_type,
-        "timeCreated": datetime.datetime.now().strftime("%Y-%m-%d %H:%M:%S"),
-        "yamls": "---\n".join(filter(None, result)),
-    }
-    result_df = spark.createDataFrame([result_dict])
-    result_df.write.mode("append").format("jdbc").option(
-        "driver", "ru.yandex.clickhouse.ClickHouseDriver"
-    ).option("url", db_jdbc_address).option(
-        "user", os.getenv("CH_USERNAME")
-    ).option(
-        "password", os.getenv("CH_PASSWORD")
-    ).option(
-        "dbtable", table_name
-    ).save()
+    time_created = datetime.datetime.now().strftime("%Y-%m-%d %H:%M:%S")
+    last_insert = None
+    for namespace, part, policies in split_result(result, write_batch_size):
+        if write_rate > 0 and last_insert is not None:
+            # throttle the inserts, so that huge results do not overwhelm
+            # ClickHouse
+            delay = last_insert + 1 / write_rate - time.time()
+            if delay > 0:
+                time.sleep(delay)
+        last_insert = time.time()
+        result_dict = {
+            "id": recommendation_id,
+            "type": recommendation_type,
+            "timeCreated": time_created,
+            "yamls": "---\n".join(policies),
+            "part": part,
+            "namespace": namespace,
+        }
+        result_df = spark.createDataFrame([result_dict])
+        result_df.write.mode("append").format("jdbc").option(
+            "driver", "ru.yandex.clickhouse.ClickHouseDriver"
+        ).option("url", db_jdbc_address).option(
+            "user", os.getenv("CH_USERNAME")
+        ).option(
+            "password", os.getenv("CH_PASSWORD")
+        ).option(
+            "dbtable", table_name
+        ).save()
     return recommendation_id
 
 
+def parse_artifacts_uri(artifacts_uri, artifacts_endpoint=""):
+    """Returns the bucket, the key prefix and the endpoint of an artifacts
+    URI, either s3://<bucket>/<prefix> or gs://<bucket>/<prefix>. Google Cloud
+    Storage is accessed through its S3-compatible API."""
+    parsed = urlparse(artifacts_uri)
+    if parsed.scheme not in ("s3", "gs") or not parsed.netloc:
+        raise ValueError(
+            "artifacts URI should be s3://<bucket>/<prefix> or "
+            "gs://<bucket>/<prefix>"
+        )
+    endpoint = artifacts_endpoint
+    if not endpoint and parsed.scheme == "gs":
+        endpoint = GCS_ENDPOINT
+    return parsed.netloc, parsed.path.strip("/"), endpoint
+
+
+def get_artifact_key(prefix, recommendation_id, name):
+    return "/".join(filter(None, [prefix, recommendation_id, name]))
+
+
+def upload_artifacts(artifacts_uri, artifacts_endpoint, recommendation_id,
+                     artifacts):
+    """Uploads the artifacts, a dict from file names to contents, under
+    <artifacts_uri>/<recommendation_id>/. Failures are logged but do not fail
+    the job, as the result is already written to the database."""
+    try:
+        bucket, prefix, endpoint = parse_artifacts_uri(
+            artifacts_uri, artifacts_endpoint
+        )
+        client = boto3.client("s3", endpoint_url=endpoint or None)
+        for name, content in artifacts.items():
+            key = get_artifact_key(prefix, recommendation_id, name)
+            client.put_object(
+                Bucket=bucket, Key=key, Body=content.encode("utf-8")
+            )
+            logger.info("Uploaded artifact s3://{}/{}".format(bucket, key))
+    except Exception as e:
+        logger.error("Failed to upload artifacts: {}".format(e))
+
+
 def initial_recommendation_job(
     spark,
     db_jdbc_address,
@@ -841,6 +1362,13 @@ def initial_recommendation_job(
     ns_allow_list=NAMESPACE_ALLOW_LIST,
     rm_labels=False,
     to_services=True,
+    ns_scope=None,
+    endpoint_svcs=(),
+    deny_action="Reject",
+    rule_ordering="volume",
+    address_family="dual",
+    ns_exclude=None,
+    pod_selector=None,
 ):
     """
     Start an initial policy recommendation Spark job on a cluster having no
@@ -875,21 +1403,53 @@ def initial_recommendation_job(
                    'pod-template-generation'.
         to_services: Use the toServices feature in ANP, only works when
                      option is 1 or 2.
+        ns_scope: List of namespaces the recommendation is scoped to. Only
+                  the flow records from or to these namespaces are considered.
+                  Default value is None, which means all namespaces.
+        endpoint_svcs: List of <namespace>/<name> of the Services whose
+                       endpoints are reached directly, e.g. headless Services.
+                       Endpoint rules are recommended for the flows to these
+                       Services instead of toServices rules.
+        deny_action: Action of the recommended default deny rules, Reject or
+                     Drop. Default value is Reject.
+        rule_ordering: Order of the rules of the recommended policies, volume
+                       to order them by decreasing volume of the matching
+                       flows, or lexical. Default value is volume.
+        address_family: Address family of the flow records considered for
+                        the recommendation, ipv4, ipv6 or dual. Default value
+                        is dual, which means both families.
+        ns_exclude: List of namespaces excluded from the recommendation. The
+                    flow records between the Pods of these namespaces, or
+                    from their Pods to external destinations, are not
+                    considered. Default value is None, which means no
+                    namespaces.
+        pod_selector: LabelSelector, as a dict, the recommendation is scoped
+                      to. Only the flow records from or to the Pods matching
+                      it are considered. Default value is None, which means
+                      all Pods.
 
     Returns:
         A list of recommended policies, each recommended policy is a string of
         YAML format.
     """
     sql_query = generate_sql_query(
-        table_name, limit, start_time, end_time, True
+        table_name,
+        limit,
+        start_time,
+        end_time,
+        True,
+        ns_scope,
+        address_family,
+        ns_exclude,
+        pod_selector,
     )
     unprotected_flows_df = read_flow_df(
-        spark, db_jdbc_address, sql_query, rm_labels
+        spark, db_jdbc_address, sql_query, rm_labels, endpoint_svcs
     )
     return recommend_policies_for_ns_allow_list(
         ns_allow_list
     ) + recommend_policies_for_unprotected_flows(
-        unprotected_flows_df, option, to_services
+        unprotected_flows_df, option, to_services, deny_action, rule_ordering
     )
 
 
@@ -903,6 +1463,13 @@ def subsequent_recommendation_job(
     end_time=None,
     rm_labels=False,
     to_services=True,
+    ns_scope=None,
+    endpoint_svcs=(),
+    deny_action="Reject",
+    rule_ordering="volume",
+    address_family="dual",
+    ns_exclude=None,
+    pod_selector=None,
 ):
     """
     Start a subsequent policy recommendation Spark job on a cluster having
@@ -935,6 +1502,30 @@ def subsequent_recommendation_job(
                    'pod-template-generation'.
         to_services: Use the toServices feature in ANP, only works when option
                      is 1 or 2.
+        ns_scope: List of namespaces the recommendation is scoped to. Only
+                  the flow records from or to these namespaces are considered.
+                  Default value is None, which means all namespaces.
+        endpoint_svcs: List of <namespace>/<name> of the Services whose
+                       endpoints are reached directly, e.g. headless Services.
+                       Endpoint rules are recommended for the flows to these
+                       Services instead of toServices rules.
+        deny_action: Action of the recommended default deny rules, Reject or
+                     Drop. Default value is Reject.
+        rule_ordering: Order of the rules of the recommended policies, volume
+                       to order them by decreasing volume of the matching
+                       flows, or lexical. Default value is volume.
+        address_family: Address family of the flow records considered for
+                        the recommendation, ipv4, ipv6 or dual. Default value
+                        is dual, which means both families.
+        ns_exclude: List of namespaces excluded from the recommendation. The
+                    flow records between the Pods of these namespaces, or
+                    from their Pods to external destinations, are not
+                    considered. Default value is None, which means no
+                    namespaces.
+        pod_selector: LabelSelector, as a dict, the recommendation is scoped
+                      to. Only the flow records from or to the Pods matching
+                      it are considered. Default value is None, which means
+                      all Pods.
 
     Returns:
         A list of recommended policies, each recommended policy is a string of
@@ -942,23 +1533,39 @@ def subsequent_recommendation_job(
     """
     recommend_policies = []
     sql_query = generate_sql_query(
-        table_name, limit, start_time, end_time, True
+        table_name,
+        limit,
+        start_time,
+        end_time,
+        True,
+        ns_scope,
+        address_family,
+        ns_exclude,
+        pod_selector,
     )
     unprotected_flows_df = read_flow_df(
-        spark, db_jdbc_address, sql_query, rm_labels
+        spark, db_jdbc_address, sql_query, rm_labels, endpoint_svcs
     )
     recommend_policies += recommend_policies_for_unprotected_flows(
-        unprotected_flows_df, option, to_services
+        unprotected_flows_df, option, to_services, deny_action, rule_ordering
     )
     if option in [1, 2]:
         sql_query = generate_sql_query(
-            table_name, limit, start_time, end_time, False
+            table_name,
+            limit,
+            start_time,
+            end_time,
+            False,
+            ns_scope,
+            address_family,
+            ns_exclude,
+            pod_selector,
         )
         trusted_denied_flows_df = read_flow_df(
-            spark, db_jdbc_address, sql_query, rm_labels
+            spark, db_jdbc_address, sql_query, rm_labels, endpoint_svcs
         )
         recommend_policies += recommend_policies_for_trusted_denied_flows(
-            trusted_denied_flows_df, to_services
+            trusted_denied_flows_df, to_services, rule_ordering
         )
     return recommend_policies
 
@@ -978,6 +1585,20 @@ def main(argv):
     recommendation_id_input = ""
     rm_labels = True
     to_services = True
+    ns_scope = None
+    endpoint_svcs = []
+    windows_compat = False
+    rule_ordering = "volume"
+    address_family = "dual"
+    checkpoint_dir = ""
+    artifacts_uri = ""
+    artifacts_endpoint = ""
+    write_batch_size = 0
+    write_rate = 0
+    exclusions = []
+    target_allow = []
+    target_deny = []
+    pod_selector = None
     help_message = """
     Start the policy recommendation spark job.
 
@@ -1019,6 +1640,57 @@ def main(argv):
         toServices rules for Pod-to-Service flows, only works when option is
         1 or 2. This feature is enabled by default, provide false to disable
         this feature.
+    --ns_scope=None: List of namespaces the recommendation is scoped to. Only
+        the flow records from or to these namespaces are considered. Default
+        value is None, which means all namespaces.
+    --endpoint_svcs=[]: List of <namespace>/<name> of the Services whose
+        endpoints are reached without going through their ClusterIP, i.e.
+        headless Services and NodePort or LoadBalancer Services with the Local
+        externalTrafficPolicy. Endpoint rules are recommended for the flows to
+        these Services instead of toServices rules.
+    --windows_compat=None: Recommend policies which the Antrea Agents running
+        on Windows Nodes can enforce: default deny rules drop traffic instead
+        of rejecting it, and the toServices feature is disabled. Provide true
+        to enable this feature.
+    --rule_ordering=volume: {volume|lexical} Order of the rules of the
+        recommended policies. volume orders them by decreasing volume of the
+        matching flows, so that the hottest flows match the first rules.
+        lexical orders them by peer.
+    --address_family=dual: {ipv4|ipv6|dual} Address family of the flow
+        records considered for the policy recommendation. dual considers the
+        flow records of both families, and recommends ipBlock rules in both
+        families when there are flow records in both.
+    --checkpoint_dir=None: Directory used to checkpoint the flow records read
+        from the database, so that they are not read again when executors are
+        lost, e.g. when running on spot nodes. It must be accessible from all
+        executors. Default value is None, which disables checkpointing.
+    --artifacts_uri=None: s3://<bucket>/<prefix> or gs://<bucket>/<prefix>
+        URI the logs, the metrics and the result of the job are uploaded to,
+        under <prefix>/<id>/. Default value is None, which disables uploads.
+    --artifacts_endpoint=None: Endpoint of the S3-compatible object storage
+        service the artifacts are uploaded to, e.g. a MinIO server. Default
+        value is None, which means AWS S3 for s3:// URIs and Google Cloud
+        Storage for gs:// URIs.
+    --write_batch_size=0: The maximum number of recommended policies written
+        to the database in a single insert. The result is written in one part
+        for each Namespace, and the parts are split further by separate
+        inserts. 0 means no limit, i.e. each part is written in a single
+        insert.
+    --write_rate=0: The maximum number of inserts per second when the result
+        is written in several parts. 0 means no limit.
+    --exclusions=[]: JSON list of the policies rejected by users in previous
+        recommendations. Their rules are not recommended again for the same
+        Pods, and the policies left without rules are not recommended.
+    --target_namespaces={}: JSON object restricting the recommendation to the
+        Pods of some namespaces, with the "allow" list of the target
+        namespaces, all namespaces if it is empty, and the "deny" list of the
+        namespaces which are not targeted. Only the flow records from or to
+        the Pods of the target namespaces are considered, and only the
+        policies applying to them are recommended.
+    --pod_label_selector={}: JSON LabelSelector restricting the
+        recommendation to the Pods matching it. Only the flow records from or
+        to these Pods are considered, and only the policies applying to them
+        are recommended.
 
     Usage Example:
     python3 policy_recommendation_job.py
@@ -1047,6 +1719,19 @@ def main(argv):
                 "id=",
                 "rm_labels=",
                 "to_services=",
+                "ns_scope=",
+                "endpoint_svcs=",
+                "windows_compat=",
+                "rule_ordering=",
+                "address_family=",
+                "checkpoint_dir=",
+                "artifacts_uri=",
+                "artifacts_endpoint=",
+                "write_batch_size=",
+                "write_rate=",
+                "exclusions=",
+                "target_namespaces=",
+                "pod_label_selector=",
             ],
         )
     except getopt.GetoptError as e:
@@ -1128,8 +1813,124 @@ def main(argv):
         elif opt in ("--to_services"):
             if arg == "false":
                 to_services = False
+        elif opt in ("--ns_scope"):
+            arg_list = json.loads(arg)
+            if not isinstance(arg_list, list):
+                logger.error("ns_scope should be a list.")
+                logger.info(help_message)
+                sys.exit(2)
+            ns_scope = arg_list
+        elif opt in ("--endpoint_svcs"):
+            arg_list = json.loads(arg)
+            if not isinstance(arg_list, list):
+                logger.error("endpoint_svcs should be a list.")
+                logger.info(help_message)
+                sys.exit(2)
+            endpoint_svcs = arg_list
+        elif opt in ("--windows_compat"):
+            if arg == "true":
+                windows_compat = True
+        elif opt in ("--rule_ordering"):
+            valid_orderings = ["volume", "lexical"]
+            if arg not in valid_orderings:
+                logger.error(
+                    "rule_ordering should be in {}".format(
+                        " or ".join(valid_orderings)
+                    )
+                )
+                logger.info(help_message)
+                sys.exit(2)
+            rule_ordering = arg
+        elif opt in ("--address_family"):
+            if arg not in ADDRESS_FAMILIES:
+                logger.error(
+                    "address_family should be in {}".format(
+                        " or ".join(ADDRESS_FAMILIES)
+                    )
+                )
+                logger.info(help_message)
+                sys.exit(2)
+            address_family = arg
+        elif opt in ("--checkpoint_dir"):
+            checkpoint_dir = arg
+        elif opt in ("--artifacts_uri"):
+            try:
+                parse_artifacts_uri(arg)
+            except ValueError as e:
+                logger.error(e)
+                logger.info(help_message)
+                sys.exit(2)
+            artifacts_uri = arg
+        elif opt in ("--artifacts_endpoint"):
+            artifacts_endpoint = arg
+        elif opt in ("--write_batch_size"):
+            if not is_intstring(arg) or int(arg) < 0:
+                logger.error("write_batch_size should be an integer >= 0.")
+                logger.info(help_message)
+                sys.exit(2)
+            write_batch_size = int(arg)
+        elif opt in ("--write_rate"):
+            try:
+                write_rate = float(arg)
+                if write_rate < 0:
+                    raise ValueError
+            except ValueError:
+                logger.error("write_rate should be a number >= 0.")
+                logger.info(help_message)
+                sys.exit(2)
+        elif opt in ("--exclusions"):
+            arg_list = json.loads(arg)
+            if not isinstance(arg_list, list):
+                logger.error("exclusions should be a list.")
+                logger.info(help_message)
+                sys.exit(2)
+            exclusions = arg_list
+        elif opt in ("--target_namespaces"):
+            targets = json.loads(arg)
+            if not isinstance(targets, dict) or not all(
+                isinstance(targets.get(key, []), list)
+                for key in ("allow", "deny")
+            ):
+                logger.error(
+                    "target_namespaces should be an object with allow and \
+deny lists."
+                )
+                logger.info(help_message)
+                sys.exit(2)
+            target_allow = targets.get("allow", [])
+            target_deny = targets.get("deny", [])
+        elif opt in ("--pod_label_selector"):
+            pod_selector = json.loads(arg)
+            if not isinstance(pod_selector, dict):
+                logger.error("pod_label_selector should be a LabelSelector.")
+                logger.info(help_message)
+                sys.exit(2)
+
+    if target_allow:
+        ns_scope = (
+            target_allow
+            if ns_scope is None
+            else [ns for ns in ns_scope if ns in target_allow]
+        )
 
+    deny_action = "Reject"
+    if windows_compat:
+        # Windows Agents cannot enforce Reject rules and toServices rules
+        deny_action = "Drop"
+        to_services = False
+
+    if artifacts_uri:
+        # capture the logs of the job, so that they can be uploaded with
+        # the other artifacts
+        log_stream = io.StringIO()
+        log_handler = logging.StreamHandler(log_stream)
+        log_handler.setLevel(logging.INFO)
+        log_handler.setFormatter(formatter)
+        logger.addHandler(log_handler)
+    start = time.time()
     spark = SparkSession.builder.getOrCreate()
+    if checkpoint_dir:
+        spark.sparkContext.setCheckpointDir(checkpoint_dir)
     if recommendation_type == "initial":
         result = initial_recommendation_job(
             spark,
@@ -1142,7 +1943,17 @@ def main(argv):
             ns_allow_list,
             rm_labels,
             to_services,
+            ns_scope,
+            endpoint_svcs,
+            deny_action,
+            rule_ordering,
+            address_family,
+            target_deny,
+            pod_selector,
         )
+        result = filter_target_namespaces(result, target_allow, target_deny)
+        result = filter_pod_label_selector(result, pod_selector)
+        result = apply_exclusions(result, exclusions)
         recommendation_id = write_recommendation_result(
             spark,
             result,
@@ -1150,6 +1961,8 @@ def main(argv):
             db_jdbc_address,
             result_table_name,
             recommendation_id_input,
+            write_batch_size,
+            write_rate,
         )
         logger.info(
             "Initial policy recommendation completed, id: {}, policy number: \
@@ -1168,7 +1981,17 @@ def main(argv):
             end_time,
             rm_labels,
             to_services,
+            ns_scope,
+            endpoint_svcs,
+            deny_action,
+            rule_ordering,
+            address_family,
+            target_deny,
+            pod_selector,
         )
+        result = filter_target_namespaces(result, target_allow, target_deny)
+        result = filter_pod_label_selector(result, pod_selector)
+        result = apply_exclusions(result, exclusions)
         recommendation_id = write_recommendation_result(
             spark,
             result,
@@ -1176,6 +1999,8 @@ def main(argv):
             db_jdbc_address,
             result_table_name,
             recommendation_id_input,
+            write_batch_size,
+            write_rate,
         )
         logger.info(
             "Subsequent policy recommendation completed, id: {}, policy \
@@ -1183,6 +2008,29 @@ def main(argv):
                 recommendation_id, len(result)
             )
         )
+    if artifacts_uri:
+        end = time.time()
+        metrics = {
+            "id": recommendation_id,
+            "type": recommendation_type,
+            "option": option,
+            "startTime": datetime.datetime.utcfromtimestamp(
+                start).strftime("%Y-%m-%d %H:%M:%S"),
+            "endTime": datetime.datetime.utcfromtimestamp(
+                end).strftime("%Y-%m-%d %H:%M:%S"),
+            "durationSeconds": round(end - start, 3),
+            "policyNumber": len(result),
+        }
+        upload_artifacts(
+            artifacts_uri,
+            artifacts_endpoint,
+            recommendation_id,
+            {
+                "result.yaml": "---\n".join(filter(None, result)),
+                "metrics.json": json.dumps(metrics, indent=2),
+                "job.log": log_stream.getvalue(),
+            },
+        )
     spark.stop()
 
 
@@ -52,6 +52,28 @@ def test_get_flow_type(test_input, expected_flow_type):
     assert flow_type == expected_flow_type
 
 
+@pytest.mark.parametrize(
+    "test_input, expected_flow_type",
+    [
+        (
+            (1, "antrea-e2e/perftestsvc:5201", '{"podname":"perftest-c"}'),
+            "pod_to_pod",
+        ),
+        ((1, "antrea-e2e/perftestsvc:5201", ""), "pod_to_svc"),
+        (
+            (1, "antrea-e2e/othersvc:5201", '{"podname":"perftest-c"}'),
+            "pod_to_svc",
+        ),
+    ],
+)
+def test_get_flow_type_endpoint_svcs(test_input, expected_flow_type):
+    flowType, destinationServicePortName, destinationPodLabels = test_input
+    flow_type = pr.get_flow_type(flowType, destinationServicePortName,
+                                 destinationPodLabels,
+                                 ["antrea-e2e/perftestsvc"])
+    assert flow_type == expected_flow_type
+
+
 @pytest.mark.parametrize(
     "test_input, expected_labels",
     [
@@ -89,56 +111,62 @@ table_name = "default.flows"
     [
         (
             (0, "", "", True),
-            "SELECT {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
+            "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
 egressNetworkPolicyName == '' GROUP BY {}".format(
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
+                pr.FLOW_VOLUME_COLUMN,
                 table_name,
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
             ),
         ),
         (
             (0, "", "", False),
-            "SELECT {} FROM {} WHERE trusted == 1 GROUP BY {}".format(
+            "SELECT {}, {} FROM {} WHERE trusted == 1 GROUP BY {}".format(
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
+                pr.FLOW_VOLUME_COLUMN,
                 table_name,
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
             ),
         ),
         (
             (100, "", "", True),
-            "SELECT {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
+            "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
 egressNetworkPolicyName == '' GROUP BY {} LIMIT 100".format(
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
+                pr.FLOW_VOLUME_COLUMN,
                 table_name,
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
             ),
         ),
         (
             (100, "2022-01-01 00:00:00", "", True),
-            "SELECT {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
+            "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
 egressNetworkPolicyName == '' AND flowStartSeconds >= '2022-01-01 00:00:00' \
 GROUP BY {} LIMIT 100".format(
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
+                pr.FLOW_VOLUME_COLUMN,
                 table_name,
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
             ),
         ),
         (
             (100, "", "2022-01-01 23:59:59", True),
-            "SELECT {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
+            "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
 egressNetworkPolicyName == '' AND flowEndSeconds < '2022-01-01 23:59:59' \
 GROUP BY {} LIMIT 100".format(
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
+                pr.FLOW_VOLUME_COLUMN,
                 table_name,
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
             ),
         ),
         (
             (100, "2022-01-01 00:00:00", "2022-01-01 23:59:59", True),
-            "SELECT {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
+            "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName == '' AND \
 egressNetworkPolicyName == '' AND flowStartSeconds >= '2022-01-01 00:00:00' \
 AND flowEndSeconds < '2022-01-01 23:59:59' GROUP BY {} LIMIT 100".format(
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
+                pr.FLOW_VOLUME_COLUMN,
                 table_name,
                 ", ".join(pr.FLOW_TABLE_COLUMNS),
             ),
@@ -153,6 +181,110 @@ def test_generate_sql_query(test_input, expected_sql_query):
     assert sql_query == expected_sql_query
 
 
+def test_generate_sql_query_ns_exclude():
+    sql_query = pr.generate_sql_query(
+        table_name,
+        0,
+        "",
+        "",
+        True,
+        None,
+        "dual",
+        ["kube-system", "monitoring"],
+    )
+    assert sql_query == "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName \
+== '' AND egressNetworkPolicyName == '' AND ((sourcePodNamespace != '' AND \
+sourcePodNamespace NOT IN ('kube-system', 'monitoring')) OR \
+(destinationPodNamespace != '' AND destinationPodNamespace NOT IN \
+('kube-system', 'monitoring'))) GROUP BY {}".format(
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+        pr.FLOW_VOLUME_COLUMN,
+        table_name,
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+    )
+
+
+def test_generate_sql_query_pod_selector():
+    sql_query = pr.generate_sql_query(
+        table_name,
+        0,
+        "",
+        "",
+        True,
+        None,
+        "dual",
+        None,
+        {
+            "matchLabels": {"app": "web"},
+            "matchExpressions": [
+                {"key": "canary", "operator": "DoesNotExist"},
+            ],
+        },
+    )
+    assert sql_query == "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName \
+== '' AND egressNetworkPolicyName == '' AND ((sourcePodName != '' AND \
+((JSONHas(sourcePodLabels, 'app') AND JSONExtractString(sourcePodLabels, \
+'app') IN ('web')) AND NOT JSONHas(sourcePodLabels, 'canary'))) OR \
+(destinationPodName != '' AND ((JSONHas(destinationPodLabels, 'app') AND \
+JSONExtractString(destinationPodLabels, 'app') IN ('web')) AND NOT \
+JSONHas(destinationPodLabels, 'canary')))) GROUP BY {}".format(
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+        pr.FLOW_VOLUME_COLUMN,
+        table_name,
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+    )
+
+
+def test_generate_sql_query_ns_scope():
+    sql_query = pr.generate_sql_query(
+        table_name, 0, "", "", True, ["antrea-test", "default"]
+    )
+    assert sql_query == "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName \
+== '' AND egressNetworkPolicyName == '' AND (sourcePodNamespace IN \
+('antrea-test', 'default') OR destinationPodNamespace IN ('antrea-test', \
+'default')) GROUP BY {}".format(
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+        pr.FLOW_VOLUME_COLUMN,
+        table_name,
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+    )
+
+
+@pytest.mark.parametrize(
+    "address_family, expected_filter",
+    [
+        ("ipv4", " AND isIPv4String(destinationIP)"),
+        ("ipv6", " AND isIPv6String(destinationIP)"),
+        ("dual", ""),
+    ],
+)
+def test_generate_sql_query_address_family(address_family, expected_filter):
+    sql_query = pr.generate_sql_query(
+        table_name, 0, "", "", True, None, address_family
+    )
+    assert sql_query == "SELECT {}, {} FROM {} WHERE ingressNetworkPolicyName \
+== '' AND egressNetworkPolicyName == ''{} GROUP BY {}".format(
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+        pr.FLOW_VOLUME_COLUMN,
+        table_name,
+        expected_filter,
+        ", ".join(pr.FLOW_TABLE_COLUMNS),
+    )
+
+
+@pytest.mark.parametrize(
+    "test_input, expected_cidr",
+    [
+        ("192.168.0.1", "192.168.0.1/32"),
+        ("2001:db8:0:0:0:0:0:1", "2001:db8::1/128"),
+        ("fe80::1", "fe80::1/128"),
+        ("::ffff:192.168.0.1", "192.168.0.1/32"),
+    ],
+)
+def test_get_IP_block_cidr(test_input, expected_cidr):
+    assert pr.get_IP_block_cidr(test_input) == expected_cidr
+
+
 @pytest.mark.parametrize(
     "test_input, expected_policies",
     [
@@ -1530,3 +1662,489 @@ def test_recommend_antrea_policies(
                 )
             policy["metadata"]["name"] = expect_policy["metadata"]["name"]
             assert policy == expect_policy
+
+
+@pytest.mark.parametrize(
+    "rule_volumes, expected_peers",
+    [
+        ({}, ["a#1", "b#2", "c#3"]),
+        (
+            {
+                ("ns#{}", "egress", "c#3"): 300,
+                ("ns#{}", "egress", "a#1"): 100,
+                ("ns#{}", "ingress", "b#2"): 1000,
+            },
+            ["c#3", "a#1", "b#2"],
+        ),
+    ],
+)
+def test_sort_rule_peers(rule_volumes, expected_peers):
+    peers = pr.sort_rule_peers(
+        "ns#{}", "egress", ["b#2", "c#3", "a#1", "c#3"], rule_volumes
+    )
+    assert peers == expected_peers
+
+
+@pytest.mark.parametrize("deny_action", ["Reject", "Drop"])
+def test_generate_reject_acnp_deny_action(deny_action):
+    policy = yaml.safe_load(pr.generate_reject_acnp("", deny_action)[0])
+    assert policy["metadata"]["name"] == "recommend-reject-all-acnp"
+    assert policy["spec"]["ingress"][0]["action"] == deny_action
+    assert policy["spec"]["egress"][0]["action"] == deny_action
+
+
+@pytest.mark.parametrize(
+    "test_input, expected_output",
+    [
+        (
+            ("s3://my-bucket/theia/artifacts/", ""),
+            ("my-bucket", "theia/artifacts", ""),
+        ),
+        (
+            ("s3://my-bucket", "http://minio.minio.svc:9000"),
+            ("my-bucket", "", "http://minio.minio.svc:9000"),
+        ),
+        (
+            ("gs://my-bucket/artifacts", ""),
+            ("my-bucket", "artifacts", pr.GCS_ENDPOINT),
+        ),
+    ],
+)
+def test_parse_artifacts_uri(test_input, expected_output):
+    artifacts_uri, artifacts_endpoint = test_input
+    assert (
+        pr.parse_artifacts_uri(artifacts_uri, artifacts_endpoint)
+        == expected_output
+    )
+
+
+@pytest.mark.parametrize(
+    "test_input",
+    ["my-bucket/artifacts", "hdfs://my-bucket/artifacts", "s3://"],
+)
+def test_parse_invalid_artifacts_uri(test_input):
+    with pytest.raises(ValueError):
+        pr.parse_artifacts_uri(test_input)
+
+
+@pytest.mark.parametrize(
+    "test_input, expected_key",
+    [
+        (("theia", "id-1", "job.log"), "theia/id-1/job.log"),
+        (("", "id-1", "result.yaml"), "id-1/result.yaml"),
+    ],
+)
+def test_get_artifact_key(test_input, expected_key):
+    assert pr.get_artifact_key(*test_input) == expected_key
+
+
+NS_A_POLICY = """apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-a
+  namespace: ns-a
+"""
+NS_B_POLICY = """apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-b
+  namespace: ns-b
+"""
+CLUSTER_POLICY = """apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-acnp
+"""
+
+
+@pytest.mark.parametrize(
+    "test_input, expected_parts",
+    [
+        (
+            ([NS_B_POLICY, NS_A_POLICY, "", CLUSTER_POLICY], 0),
+            [
+                ("", 0, [CLUSTER_POLICY]),
+                ("ns-a", 0, [NS_A_POLICY]),
+                ("ns-b", 0, [NS_B_POLICY]),
+            ],
+        ),
+        (
+            ([NS_A_POLICY, NS_A_POLICY, NS_A_POLICY, CLUSTER_POLICY], 2),
+            [
+                ("", 0, [CLUSTER_POLICY]),
+                ("ns-a", 0, [NS_A_POLICY, NS_A_POLICY]),
+                ("ns-a", 1, [NS_A_POLICY]),
+            ],
+        ),
+        (([], 2), [("", 0, [])]),
+    ],
+)
+def test_split_result(test_input, expected_parts):
+    assert pr.split_result(*test_input) == expected_parts
+
+
+EXCLUSION_ANP = """apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-{}
+  namespace: ns-a
+spec:
+  appliedTo:
+  - podSelector:
+      matchLabels:
+        app: a
+  egress:
+  - action: Allow
+    ports:
+    - port: 80
+      protocol: TCP
+    to:
+    - ipBlock:
+        cidr: 8.8.8.8/32
+  ingress:
+  - action: Allow
+    from:
+    - podSelector:
+        matchLabels:
+          app: b
+  priority: 5
+  tier: Application
+"""
+EXCLUSION_K8S_NP = """apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: recommend-k8s-np-{}
+  namespace: ns-a
+spec:
+  egress:
+  - ports:
+    - port: 53
+      protocol: UDP
+  ingress:
+  - from:
+    - podSelector:
+        matchLabels:
+          app: b
+  podSelector:
+    matchLabels:
+      app: a
+  policyTypes:
+  - Ingress
+  - Egress
+"""
+EXCLUSION_CG = """apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterGroup
+metadata:
+  name: cg-ns-a-svc-a
+spec:
+  serviceReference:
+    name: svc-a
+    namespace: ns-a
+"""
+
+
+def excluded_policy(policy, name, **spec):
+    excluded = yaml.safe_load(policy.format(name))
+    excluded["spec"].update(spec)
+    return excluded
+
+
+@pytest.mark.parametrize(
+    "result, exclusions, expected_specs",
+    [
+        (
+            [EXCLUSION_ANP.format("abcde")],
+            [],
+            [yaml.safe_load(EXCLUSION_ANP.format("abcde"))["spec"]],
+        ),
+        (
+            [EXCLUSION_ANP.format("abcde")],
+            [excluded_policy(EXCLUSION_ANP, "fghij", egress=[])],
+            [
+                dict(
+                    yaml.safe_load(EXCLUSION_ANP.format("abcde"))["spec"],
+                    ingress=[],
+                )
+            ],
+        ),
+        (
+            [EXCLUSION_ANP.format("abcde")],
+            [
+                excluded_policy(
+                    EXCLUSION_ANP, "fghij", appliedTo=[{"podSelector": {}}]
+                )
+            ],
+            [yaml.safe_load(EXCLUSION_ANP.format("abcde"))["spec"]],
+        ),
+        (
+            [EXCLUSION_ANP.format("abcde"), EXCLUSION_CG],
+            [
+                excluded_policy(EXCLUSION_ANP, "fghij"),
+                yaml.safe_load(EXCLUSION_CG),
+            ],
+            [],
+        ),
+        (
+            [EXCLUSION_K8S_NP.format("abcde")],
+            [excluded_policy(EXCLUSION_K8S_NP, "fghij", ingress=[])],
+            [
+                dict(
+                    yaml.safe_load(EXCLUSION_K8S_NP.format("abcde"))["spec"],
+                    egress=[],
+                    policyTypes=["Ingress"],
+                )
+            ],
+        ),
+    ],
+)
+def test_apply_exclusions(result, exclusions, expected_specs):
+    policies = pr.apply_exclusions(result, exclusions)
+    assert [yaml.safe_load(policy)["spec"] for policy in policies] == (
+        expected_specs
+    )
+
+
+TARGET_ANP = """apiVersion: crd.antrea.io/v1alpha1
+kind: NetworkPolicy
+metadata:
+  name: recommend-allow-anp-abcde
+  namespace: ns-a
+spec:
+  appliedTo:
+  - podSelector:
+      matchLabels:
+        app: a
+  ingress:
+  - action: Allow
+    from:
+    - podSelector:
+        matchLabels:
+          app: b
+"""
+TARGET_SVC_ACNP = """apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-svc-allow-acnp-abcde
+spec:
+  appliedTo:
+  - namespaceSelector:
+      matchLabels:
+        kubernetes.io/metadata.name: ns-b
+    podSelector:
+      matchLabels:
+        app: b
+  egress:
+  - action: Allow
+    to:
+    - group: cg-ns-c-svc-c
+"""
+TARGET_CG = """apiVersion: crd.antrea.io/v1alpha2
+kind: ClusterGroup
+metadata:
+  name: cg-ns-c-svc-c
+spec:
+  serviceReference:
+    name: svc-c
+    namespace: ns-c
+"""
+TARGET_REJECT_ALL_ACNP = """apiVersion: crd.antrea.io/v1alpha1
+kind: ClusterNetworkPolicy
+metadata:
+  name: recommend-reject-all-acnp
+spec:
+  appliedTo:
+  - namespaceSelector: {}
+    podSelector: {}
+  egress:
+  - action: Reject
+    to:
+    - podSelector: {}
+"""
+
+
+@pytest.mark.parametrize(
+    "target_allow, target_deny, expected_names, expected_expressions",
+    [
+        (
+            [],
+            [],
+            [
+                "recommend-allow-anp-abcde",
+                "recommend-svc-allow-acnp-abcde",
+                "cg-ns-c-svc-c",
+                "recommend-reject-all-acnp",
+            ],
+            None,
+        ),
+        (
+            ["ns-a"],
+            [],
+            ["recommend-allow-anp-abcde", "recommend-reject-all-acnp"],
+            [
+                {
+                    "key": "kubernetes.io/metadata.name",
+                    "operator": "In",
+                    "values": ["ns-a"],
+                }
+            ],
+        ),
+        (
+            [],
+            ["ns-a"],
+            [
+                "recommend-svc-allow-acnp-abcde",
+                "recommend-reject-all-acnp",
+                "cg-ns-c-svc-c",
+            ],
+            [
+                {
+                    "key": "kubernetes.io/metadata.name",
+                    "operator": "NotIn",
+                    "values": ["ns-a"],
+                }
+            ],
+        ),
+        (
+            ["ns-a", "ns-b"],
+            ["ns-b"],
+            ["recommend-allow-anp-abcde", "recommend-reject-all-acnp"],
+            [
+                {
+                    "key": "kubernetes.io/metadata.name",
+                    "operator": "In",
+                    "values": ["ns-a", "ns-b"],
+                },
+                {
+                    "key": "kubernetes.io/metadata.name",
+                    "operator": "NotIn",
+                    "values": ["ns-b"],
+                },
+            ],
+        ),
+    ],
+)
+def test_filter_target_namespaces(
+    target_allow, target_deny, expected_names, expected_expressions
+):
+    result = [TARGET_ANP, TARGET_SVC_ACNP, TARGET_CG, TARGET_REJECT_ALL_ACNP]
+    policies = [
+        yaml.safe_load(policy)
+        for policy in pr.filter_target_namespaces(
+            result, target_allow, target_deny
+        )
+    ]
+    assert [policy["metadata"]["name"] for policy in policies] == (
+        expected_names
+    )
+    reject_all = [
+        policy
+        for policy in policies
+        if policy["metadata"]["name"] == "recommend-reject-all-acnp"
+    ][0]
+    namespace_selector = reject_all["spec"]["appliedTo"][0][
+        "namespaceSelector"
+    ]
+    if expected_expressions is None:
+        assert namespace_selector == {}
+    else:
+        assert namespace_selector == {
+            "matchExpressions": expected_expressions
+        }
+
+
+@pytest.mark.parametrize(
+    "selector, labels, expected",
+    [
+        ({"matchLabels": {"app": "a"}}, {"app": "a", "tier": "web"}, True),
+        ({"matchLabels": {"app": "a"}}, {"app": "b"}, False),
+        (
+            {
+                "matchExpressions": [
+                    {"key": "app", "operator": "In", "values": ["a", "b"]}
+                ]
+            },
+            {"app": "b"},
+            True,
+        ),
+        (
+            {
+                "matchExpressions": [
+                    {"key": "app", "operator": "NotIn", "values": ["a"]}
+                ]
+            },
+            {"tier": "web"},
+            True,
+        ),
+        (
+            {
+                "matchExpressions": [
+                    {"key": "app", "operator": "NotIn", "values": ["a"]}
+                ]
+            },
+            {"app": "a"},
+            False,
+        ),
+        (
+            {"matchExpressions": [{"key": "app", "operator": "Exists"}]},
+            {"tier": "web"},
+            False,
+        ),
+        (
+            {"matchExpressions": [{"key": "app", "operator": "DoesNotExist"}]},
+            {"tier": "web"},
+            True,
+        ),
+    ],
+)
+def test_match_label_selector(selector, labels, expected):
+    assert pr.match_label_selector(selector, labels) == expected
+
+
+@pytest.mark.parametrize(
+    "pod_selector, expected_names",
+    [
+        (
+            None,
+            [
+                "recommend-allow-anp-abcde",
+                "recommend-svc-allow-acnp-abcde",
+                "cg-ns-c-svc-c",
+                "recommend-reject-all-acnp",
+            ],
+        ),
+        (
+            {"matchLabels": {"app": "a"}},
+            ["recommend-allow-anp-abcde", "recommend-reject-all-acnp"],
+        ),
+        (
+            {
+                "matchExpressions": [
+                    {"key": "app", "operator": "In", "values": ["b"]}
+                ]
+            },
+            [
+                "recommend-svc-allow-acnp-abcde",
+                "recommend-reject-all-acnp",
+                "cg-ns-c-svc-c",
+            ],
+        ),
+    ],
+)
+def test_filter_pod_label_selector(pod_selector, expected_names):
+    result = [TARGET_ANP, TARGET_SVC_ACNP, TARGET_CG, TARGET_REJECT_ALL_ACNP]
+    policies = [
+        yaml.safe_load(policy)
+        for policy in pr.filter_pod_label_selector(result, pod_selector)
+    ]
+    assert [policy["metadata"]["name"] for policy in policies] == (
+        expected_names
+    )
+    reject_all = [
+        policy
+        for policy in policies
+        if policy["metadata"]["name"] == "recommend-reject-all-acnp"
+    ][0]
+    pod_selector_of_reject_all = reject_all["spec"]["appliedTo"][0][
+        "podSelector"
+    ]
+    assert pod_selector_of_reject_all == (pod_selector or {})
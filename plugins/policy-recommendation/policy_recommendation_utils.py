@@ -14,7 +14,7 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
-from ipaddress import ip_address, IPv4Address
+from ipaddress import ip_address, ip_network, IPv4Address
 import json
 from re import sub
 import yaml
@@ -32,6 +32,16 @@ def get_IP_version(IP):
     return "v4" if type(ip_address(IP)) is IPv4Address else "v6"
 
 
+# Returns the CIDR of the ipBlock peer matching a single IP address, in
+# canonical form. IPv4-mapped IPv6 addresses are matched as IPv4 addresses, as
+# IPv4 traffic is only matched by IPv4 CIDRs.
+def get_IP_block_cidr(IP):
+    address = ip_address(IP)
+    if address.version == 6 and address.ipv4_mapped:
+        address = address.ipv4_mapped
+    return str(ip_network(address))
+
+
 def camel(s):
     s = sub(r"(_|-)+", " ", s).title().replace(" ", "")
     return s[0].lower() + s[1:] if s else ""
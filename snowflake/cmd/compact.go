@@ -0,0 +1,151 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
+	"antrea.io/theia/snowflake/pkg/compact"
+	"antrea.io/theia/snowflake/pkg/infra"
+)
+
+// compactDestinationPrefix returns the prefix to which the compacted files are
+// written. When the flows folder is sharded, the flows folder itself is not
+// monitored by Snowpipe, so the destination must be the folder of a shard: the
+// folder of the provided shard by default, or one of the shard folders if the
+// destination prefix is set explicitly.
+func compactDestinationPrefix(destinationPrefix string, destinationPrefixSet bool, shard int, shards int) (string, error) {
+	if err := infra.ValidateFlowsShards(shards); err != nil {
+		return "", err
+	}
+	if shards <= 1 {
+		return destinationPrefix, nil
+	}
+	folders := infra.FlowsShardFolders(shards)
+	if !destinationPrefixSet {
+		if shard < 0 || shard >= shards {
+			return "", fmt.Errorf("shard must be between 0 and %d when there are %d flows shards", shards-1, shards)
+		}
+		return folders[shard], nil
+	}
+	for _, folder := range folders {
+		if strings.Trim(destinationPrefix, "/") == folder {
+			return destinationPrefix, nil
+		}
+	}
+	return "", fmt.Errorf("destination prefix %q is not monitored by Snowpipe when there are %d flows shards, it must be one of the shard folders (%s)", destinationPrefix, shards, strings.Join(folders, ", "))
+}
+
+// compactCmd represents the compact command
+var compactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Merge small flow record files before ingestion into Snowflake",
+	Long: `This command runs a worker which merges the many small flow record
+files uploaded by the Flow Aggregators into larger gzip-compressed CSV files,
+which reduces the Snowpipe ingestion overhead (Snowflake charges a fee per file
+ingested).
+
+The Flow Aggregators must upload flow records to a prefix which is not monitored
+by Snowpipe (by default "incoming"). The worker periodically reads these files,
+writes the compacted files to the flows folder monitored by Snowpipe (by
+default "flows"), and deletes the original files. For example:
+
+"theia-sf compact --bucket-name <FLOWS BUCKET NAME>"
+
+If "onboard" was run with "--flows-shards" greater than 1, run one worker per
+shard, each one writing to the folder of its shard. For example, for the first
+of 8 shards:
+
+"theia-sf compact --bucket-name <FLOWS BUCKET NAME> --source-prefix incoming/shard-00 --flows-shards 8 --shard 0"
+
+If the worker is interrupted after a compacted file is written, and before the
+original files are deleted, some flow records may be ingested twice.
+
+To compact all the files which are ready and exit, use "--once".`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		region, _ := cmd.Flags().GetString("region")
+		bucketName, _ := cmd.Flags().GetString("bucket-name")
+		sourcePrefix, _ := cmd.Flags().GetString("source-prefix")
+		destinationPrefix, _ := cmd.Flags().GetString("destination-prefix")
+		targetSizeMB, _ := cmd.Flags().GetInt64("target-size-mb")
+		maxDelay, _ := cmd.Flags().GetDuration("max-delay")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		once, _ := cmd.Flags().GetBool("once")
+		flowsShards, _ := cmd.Flags().GetInt("flows-shards")
+		shard, _ := cmd.Flags().GetInt("shard")
+		destinationPrefix, err := compactDestinationPrefix(destinationPrefix, cmd.Flags().Changed("destination-prefix"), shard, flowsShards)
+		if err != nil {
+			return err
+		}
+		if targetSizeMB <= 0 {
+			return fmt.Errorf("target size must be positive")
+		}
+		if sourcePrefix == destinationPrefix {
+			return fmt.Errorf("source prefix and destination prefix must be different")
+		}
+		// the worker runs until interrupted, unless --once is used
+		ctx := cmd.Context()
+		if once {
+			var cancel context.CancelFunc
+			ctx, cancel = commandContext(cmd, 10*time.Minute)
+			defer cancel()
+		}
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+		if err != nil {
+			return fmt.Errorf("unable to load AWS SDK config: %w", err)
+		}
+		compactor := compact.NewCompactor(s3client.GetClient(awsCfg), compact.Config{
+			Bucket:            bucketName,
+			SourcePrefix:      sourcePrefix,
+			DestinationPrefix: destinationPrefix,
+			TargetSize:        targetSizeMB * 1024 * 1024,
+			MaxDelay:          maxDelay,
+		}, logger)
+		if once {
+			count, err := compactor.RunOnce(ctx)
+			if err != nil {
+				return withProgress(err, "after creating %d compacted file(s)", count)
+			}
+			fmt.Printf("Created %d compacted file(s)\n", count)
+			return nil
+		}
+		logger.Info("Starting compaction worker", "bucket", bucketName, "interval", interval)
+		return compactor.Run(ctx, interval)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compactCmd)
+
+	compactCmd.Flags().String("region", GetEnv("AWS_REGION", defaultRegion), "region of the flows bucket")
+	compactCmd.Flags().String("bucket-name", "", "flows bucket created by onboard")
+	compactCmd.MarkFlagRequired("bucket-name")
+	compactCmd.Flags().String("source-prefix", "incoming", "prefix to which the Flow Aggregators upload flow records; it must not be monitored by Snowpipe")
+	compactCmd.Flags().String("destination-prefix", "flows", "prefix monitored by Snowpipe, to which compacted files are written; defaults to the folder of --shard when there are multiple flows shards")
+	compactCmd.Flags().Int("flows-shards", 1, "number of shards, as provided to onboard with --flows-shards")
+	compactCmd.Flags().Int("shard", -1, "shard to which compacted files are written, required when there are multiple flows shards, unless --destination-prefix is set")
+	compactCmd.Flags().Int64("target-size-mb", 100, "size of the flow record files (in MB, as stored in S3) merged into a single compacted file")
+	compactCmd.Flags().Duration("max-delay", 10*time.Minute, "maximum time a flow record file can wait before being compacted, even if the target size has not been reached")
+	compactCmd.Flags().Duration("interval", time.Minute, "how often to check for flow record files to compact")
+	compactCmd.Flags().Bool("once", false, "compact the files which are ready and exit")
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactDestinationPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		destinationPrefix    string
+		destinationPrefixSet bool
+		shard                int
+		shards               int
+		expectedPrefix       string
+		expectedErr          string
+	}{
+		{
+			name:              "single shard",
+			destinationPrefix: "flows",
+			shard:             -1,
+			shards:            1,
+			expectedPrefix:    "flows",
+		},
+		{
+			name:                 "single shard with destination prefix",
+			destinationPrefix:    "compacted",
+			destinationPrefixSet: true,
+			shard:                -1,
+			shards:               1,
+			expectedPrefix:       "compacted",
+		},
+		{
+			name:              "shard folder",
+			destinationPrefix: "flows",
+			shard:             2,
+			shards:            8,
+			expectedPrefix:    "flows/shard-02",
+		},
+		{
+			name:              "missing shard",
+			destinationPrefix: "flows",
+			shard:             -1,
+			shards:            8,
+			expectedErr:       "shard must be between 0 and 7 when there are 8 flows shards",
+		},
+		{
+			name:              "shard out of range",
+			destinationPrefix: "flows",
+			shard:             8,
+			shards:            8,
+			expectedErr:       "shard must be between 0 and 7 when there are 8 flows shards",
+		},
+		{
+			name:                 "destination prefix is a shard folder",
+			destinationPrefix:    "flows/shard-01/",
+			destinationPrefixSet: true,
+			shard:                -1,
+			shards:               2,
+			expectedPrefix:       "flows/shard-01/",
+		},
+		{
+			name:                 "destination prefix is not monitored",
+			destinationPrefix:    "flows",
+			destinationPrefixSet: true,
+			shard:                -1,
+			shards:               2,
+			expectedErr:          `destination prefix "flows" is not monitored by Snowpipe when there are 2 flows shards, it must be one of the shard folders (flows/shard-00, flows/shard-01)`,
+		},
+		{
+			name:              "invalid number of shards",
+			destinationPrefix: "flows",
+			shards:            0,
+			expectedErr:       "number of flows shards must be between 1 and 64",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prefix, err := compactDestinationPrefix(tc.destinationPrefix, tc.destinationPrefixSet, tc.shard, tc.shards)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedPrefix, prefix)
+			}
+		})
+	}
+}
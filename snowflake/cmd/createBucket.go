@@ -44,17 +44,25 @@ To create a bucket with a specific name:
 "theia-sf create-bucket --name this-is-the-name-i-want"
 
 To create a bucket with a random name in a specific non-default region:
-"theia-sf create-bucket --region us-east-2"`,
+"theia-sf create-bucket --region us-east-2"
+
+To create a bucket with a lifecycle configuration, e.g. to transition objects to
+STANDARD_IA after 30 days and to delete them after 90 days:
+"theia-sf create-bucket --transition-ia-days 30 --expiration-days 90"`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		region, _ := cmd.Flags().GetString("region")
 		bucketName, _ := cmd.Flags().GetString("name")
 		bucketPrefix, _ := cmd.Flags().GetString("prefix")
+		lifecycleConfig, err := getLifecycleConfig(cmd)
+		if err != nil {
+			return err
+		}
 		if bucketName == "" {
 			suffix := petname.Generate(4, "-")
 			bucketName = fmt.Sprintf("%s-%s", bucketPrefix, suffix)
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := commandContext(cmd, 10*time.Second)
 		defer cancel()
 		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
 		if err != nil {
@@ -62,7 +70,7 @@ To create a bucket with a random name in a specific non-default region:
 
 		}
 		s3Client := s3client.GetClient(awsCfg)
-		if err := createBucket(ctx, s3Client, bucketName, region); err != nil {
+		if err := createBucket(ctx, s3Client, bucketName, region, lifecycleConfig); err != nil {
 			return err
 		}
 		fmt.Printf("Bucket name: %s\n", bucketName)
@@ -70,7 +78,7 @@ To create a bucket with a random name in a specific non-default region:
 	},
 }
 
-func createBucket(ctx context.Context, s3Client s3client.Interface, name string, region string) error {
+func createBucket(ctx context.Context, s3Client s3client.Interface, name string, region string, lifecycleConfig *s3client.LifecycleConfig) error {
 	logger := logger.WithValues("bucket", name, "region", region)
 	logger.Info("Checking if S3 bucket exists")
 	_, err := s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
@@ -82,19 +90,27 @@ func createBucket(ctx context.Context, s3Client s3client.Interface, name string,
 	}
 	if notFoundError == nil {
 		logger.Info("S3 bucket already exists")
+	} else {
+		logger.Info("Creating S3 bucket")
+		if _, err := s3Client.CreateBucket(ctx, &s3.CreateBucketInput{
+			Bucket: &name,
+			ACL:    s3types.BucketCannedACLPrivate,
+			CreateBucketConfiguration: &s3types.CreateBucketConfiguration{
+				LocationConstraint: s3types.BucketLocationConstraint(region),
+			},
+		}); err != nil {
+			return fmt.Errorf("error when creating bucket '%s': %w", name, err)
+		}
+		logger.Info("Created S3 bucket")
+	}
+	if lifecycleConfig.IsEmpty() {
 		return nil
 	}
-	logger.Info("Creating S3 bucket")
-	if _, err := s3Client.CreateBucket(ctx, &s3.CreateBucketInput{
-		Bucket: &name,
-		ACL:    s3types.BucketCannedACLPrivate,
-		CreateBucketConfiguration: &s3types.CreateBucketConfiguration{
-			LocationConstraint: s3types.BucketLocationConstraint(region),
-		},
-	}); err != nil {
-		return fmt.Errorf("error when creating bucket '%s': %w", name, err)
+	logger.Info("Configuring S3 bucket lifecycle")
+	if err := s3client.PutBucketLifecycle(ctx, s3Client, name, lifecycleConfig); err != nil {
+		return fmt.Errorf("error when configuring lifecycle for bucket '%s': %w", name, err)
 	}
-	logger.Info("Created S3 bucket")
+	logger.Info("Configured S3 bucket lifecycle")
 	return nil
 }
 
@@ -105,4 +121,5 @@ func init() {
 	createBucketCmd.Flags().String("name", "", "name of bucket to create")
 	createBucketCmd.Flags().String("prefix", "antrea", "prefix to use for bucket name (with auto-generated suffix)")
 	createBucketCmd.MarkFlagsMutuallyExclusive("name", "prefix")
+	addLifecycleFlags(createBucketCmd, s3client.LifecycleConfig{}, "objects")
 }
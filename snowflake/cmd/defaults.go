@@ -16,4 +16,10 @@ package cmd
 
 const (
 	defaultRegion = "us-west-2"
+	// flow records are deleted from the S3 bucket once they have been
+	// ingested into Snowflake
+	defaultFlowRecordsRetentionDays = 7
+	// how long will Snowpipe error notifications be saved in SQS queue
+	defaultSQSMessageRetentionSeconds  = 7 * 24 * 3600
+	defaultSQSVisibilityTimeoutSeconds = 30
 )
@@ -43,7 +43,7 @@ will cause all objects in the bucket to be deleted.`,
 		region, _ := cmd.Flags().GetString("region")
 		bucketName, _ := cmd.Flags().GetString("name")
 		force, _ := cmd.Flags().GetBool("force")
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+		ctx, cancel := commandContext(cmd, 120*time.Second)
 		defer cancel()
 		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
 		if err != nil {
@@ -76,15 +76,20 @@ func deleteS3Objects(ctx context.Context, s3Client s3client.Interface, bucketNam
 	logger := logger.WithValues("bucket", bucketName)
 	prefix := ""
 	var nextToken *string
+	deleted := 0
 	logger.Info("Deleting all objects in S3 bucket")
 	for {
+		// stop before listing the next page if the command was interrupted
+		if err := ctx.Err(); err != nil {
+			return withProgress(err, "after deleting %d objects", deleted)
+		}
 		output, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 			Bucket:            &bucketName,
 			ContinuationToken: nextToken,
 			Prefix:            &prefix,
 		})
 		if err != nil {
-			return fmt.Errorf("error when listing objects: %w", err)
+			return withProgress(fmt.Errorf("error when listing objects: %w", err), "after deleting %d objects", deleted)
 		}
 		keys := make([]s3types.ObjectIdentifier, 0, len(output.Contents))
 		for _, obj := range output.Contents {
@@ -96,22 +101,26 @@ func deleteS3Objects(ctx context.Context, s3Client s3client.Interface, bucketNam
 			break
 		}
 		logger.Info("Deleting objects", "count", len(keys))
-		_, err = s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		deleteOutput, err := s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: &bucketName,
 			Delete: &s3types.Delete{
 				Objects: keys,
 			},
 		})
 		if err != nil {
-			return fmt.Errorf("error when deleting objects: %w", err)
+			return withProgress(fmt.Errorf("error when deleting objects: %w", err), "after deleting %d objects", deleted)
+		}
+		deleted += len(deleteOutput.Deleted)
+		if len(deleteOutput.Errors) > 0 {
+			return fmt.Errorf("error when deleting object '%s': %s (%d objects deleted)", *deleteOutput.Errors[0].Key, *deleteOutput.Errors[0].Message, deleted)
 		}
 
-		nextToken = output.ContinuationToken
-		if nextToken == nil {
+		if !output.IsTruncated {
 			break
 		}
+		nextToken = output.NextContinuationToken
 	}
-	logger.Info("Deleted all objects in S3 bucket")
+	logger.Info("Deleted all objects in S3 bucket", "count", deleted)
 	return nil
 }
 
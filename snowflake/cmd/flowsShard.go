@@ -0,0 +1,59 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/snowflake/pkg/infra"
+)
+
+// flowsShardCmd represents the flows-shard command
+var flowsShardCmd = &cobra.Command{
+	Use:   "flows-shard",
+	Short: "Get the flows bucket prefix to use for a cluster",
+	Long: `This command prints the key prefix in the flows bucket to which a
+cluster should upload its flow records, when "onboard" was run with
+"--flows-shards" greater than 1. The prefix is selected with consistent hashing
+of the provided key (usually the cluster UUID), so that when the number of
+shards is increased, only a small fraction of the clusters need to be moved to
+a different prefix.
+
+To get the prefix for a cluster, with 8 shards:
+"theia-sf flows-shard --key <CLUSTER UUID> --shards 8"
+
+The output can be used directly as the value of the "s3Uploader.bucketPrefix"
+parameter of the Flow Aggregator Helm chart.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, _ := cmd.Flags().GetString("key")
+		shards, _ := cmd.Flags().GetInt("shards")
+		if err := infra.ValidateFlowsShards(shards); err != nil {
+			return err
+		}
+		fmt.Println(infra.FlowsShardFolder(infra.FlowsShardForKey(key, shards), shards))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(flowsShardCmd)
+
+	flowsShardCmd.Flags().String("key", "", "key used to select the shard, usually the UUID of the cluster exporting the flows")
+	flowsShardCmd.MarkFlagRequired("key")
+	flowsShardCmd.Flags().Int("shards", 1, "number of shards, as provided to onboard with --flows-shards")
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/snowflake/pkg/grafana"
+	"antrea.io/theia/snowflake/pkg/infra"
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+)
+
+// grafanaProvisionCmd represents the grafana provision command
+var grafanaProvisionCmd = &cobra.Command{
+	Use:   "provision",
+	Short: "Provision the Snowflake data source and the Theia dashboards in Grafana",
+	Long: `This command creates (or updates) a Snowflake data source in your
+Grafana instance, along with dashboards equivalent to the Theia ones, which
+query the flows stored in the Snowflake database created by "onboard".
+
+The Snowflake data source plugin (michelin-snowflake-datasource) must be
+installed in Grafana. The data source uses the Snowflake credentials exported
+as environment variables: SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER, SNOWFLAKE_PASSWORD.
+
+To provision Grafana, using the database name displayed by "onboard":
+"theia-sf grafana provision --grafana-url <URL> --grafana-api-key <KEY> --database-name <NAME>"
+
+By default, dashboard queries run in the query warehouse created by "onboard",
+so that they do not queue behind other workloads. You can use a different
+warehouse with "--warehouse-name".
+
+You can run the "provision" command multiple times as it is idempotent:
+existing dashboards with the same UIDs will be overwritten.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		grafanaURL, _ := cmd.Flags().GetString("grafana-url")
+		grafanaAPIKey, _ := cmd.Flags().GetString("grafana-api-key")
+		grafanaUser, _ := cmd.Flags().GetString("grafana-user")
+		grafanaPassword, _ := cmd.Flags().GetString("grafana-password")
+		dataSourceName, _ := cmd.Flags().GetString("datasource-name")
+		databaseName, _ := cmd.Flags().GetString("database-name")
+		schemaName, _ := cmd.Flags().GetString("schema-name")
+		warehouseName, _ := cmd.Flags().GetString("warehouse-name")
+		if warehouseName == "" {
+			warehouseName = infra.WarehouseName(databaseName, infra.QueryWorkload)
+		}
+		role, _ := cmd.Flags().GetString("role")
+		if grafanaAPIKey == "" && (grafanaUser == "" || grafanaPassword == "") {
+			return fmt.Errorf("either --grafana-api-key or both --grafana-user and --grafana-password must be provided")
+		}
+		_, cfg, err := sf.GetDSN()
+		if err != nil {
+			return fmt.Errorf("failed to create DSN: %w", err)
+		}
+		ctx, cancel := commandContext(cmd, 60*time.Second)
+		defer cancel()
+		client := grafana.NewClient(grafanaURL, grafanaAPIKey, grafanaUser, grafanaPassword, logger)
+		dataSource := &grafana.DataSource{
+			UID:    grafana.DataSourceUID,
+			Name:   dataSourceName,
+			Type:   grafana.DataSourceType,
+			Access: "proxy",
+			JSONData: map[string]interface{}{
+				"account":   cfg.Account,
+				"username":  cfg.User,
+				"role":      role,
+				"warehouse": warehouseName,
+				"database":  databaseName,
+				"schema":    schemaName,
+			},
+			SecureJSONData: map[string]string{
+				"password": cfg.Password,
+			},
+		}
+		if err := client.UpsertDataSource(ctx, dataSource); err != nil {
+			return err
+		}
+		return provisionDashboards(ctx, client)
+	},
+}
+
+func provisionDashboards(ctx context.Context, client grafana.Client) error {
+	entries, err := fs.ReadDir(grafana.Dashboards, grafana.DashboardsPath)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		data, err := fs.ReadFile(grafana.Dashboards, path.Join(grafana.DashboardsPath, entry.Name()))
+		if err != nil {
+			return err
+		}
+		logger.Info("Importing Grafana dashboard", "file", entry.Name())
+		if err := client.ImportDashboard(ctx, data); err != nil {
+			return withProgress(fmt.Errorf("failed to import dashboard %s: %w", entry.Name(), err), "after provisioning %d out of %d dashboards", i, len(entries))
+		}
+		fmt.Printf("Dashboard %s provisioned\n", entry.Name())
+	}
+	return nil
+}
+
+func init() {
+	grafanaCmd.AddCommand(grafanaProvisionCmd)
+
+	grafanaProvisionCmd.Flags().String("grafana-url", GetEnv("GRAFANA_URL", ""), "URL of the Grafana instance")
+	grafanaProvisionCmd.MarkFlagRequired("grafana-url")
+	grafanaProvisionCmd.Flags().String("grafana-api-key", GetEnv("GRAFANA_API_KEY", ""), "Grafana API key (or service account token) with the Admin role")
+	grafanaProvisionCmd.Flags().String("grafana-user", GetEnv("GRAFANA_USER", ""), "Grafana admin username, used if no API key is provided")
+	grafanaProvisionCmd.Flags().String("grafana-password", GetEnv("GRAFANA_PASSWORD", ""), "Grafana admin password, used if no API key is provided")
+	grafanaProvisionCmd.Flags().String("datasource-name", "Snowflake", "name of the Snowflake data source in Grafana")
+	grafanaProvisionCmd.Flags().String("database-name", "", "name of the Snowflake database created by onboard")
+	grafanaProvisionCmd.MarkFlagRequired("database-name")
+	grafanaProvisionCmd.Flags().String("schema-name", "THEIA", "name of the Snowflake schema created by onboard")
+	grafanaProvisionCmd.Flags().String("warehouse-name", "", "Snowflake Virtual Warehouse to use for dashboard queries, by default the query warehouse created by onboard is used")
+	grafanaProvisionCmd.Flags().String("role", "", "Snowflake role to use for dashboard queries, by default the default role of the user is used")
+}
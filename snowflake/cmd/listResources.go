@@ -0,0 +1,204 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
+	sqsclient "antrea.io/theia/snowflake/pkg/aws/client/sqs"
+	"antrea.io/theia/snowflake/pkg/infra"
+)
+
+type taggedResource struct {
+	resourceType string
+	name         string
+	region       string
+	tags         map[string]string
+}
+
+// listResourcesCmd represents the list-resources command
+var listResourcesCmd = &cobra.Command{
+	Use:   "list-resources",
+	Short: "List AWS resources created by onboard",
+	Long: `This command lists the AWS resources created by the "onboard"
+command, based on the tags applied to them: all resources are tagged with the
+name of the infrastructure stack (theia-stack) and with the version of
+theia-sf used to create or update them (theia-version), as well as with the
+tags provided with "onboard --tags". This is useful for cost attribution and to
+check that all resources have been deleted after "offboard".
+
+S3 buckets and SQS queues are listed. SNS topics and IAM roles and policies are
+tagged as well, and can be found with the AWS Resource Groups Tag Editor.
+
+To list the resources of all stacks:
+"theia-sf list-resources"
+
+To list the resources of the "prod" stack, owned by a specific team:
+"theia-sf list-resources --stack-name prod --tags owner=team-a"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		region, _ := cmd.Flags().GetString("region")
+		stackName, _ := cmd.Flags().GetString("stack-name")
+		tags, _ := cmd.Flags().GetStringToString("tags")
+		filter := map[string]string{}
+		for k, v := range tags {
+			filter[k] = v
+		}
+		if stackName != "" {
+			filter[infra.StackTagKey] = stackName
+		}
+		ctx, cancel := commandContext(cmd, 60*time.Second)
+		defer cancel()
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+		if err != nil {
+			return fmt.Errorf("unable to load AWS SDK config: %w", err)
+		}
+		buckets, err := listTaggedBuckets(ctx, awsCfg, filter)
+		if err != nil {
+			return err
+		}
+		queues, err := listTaggedQueues(ctx, sqsclient.GetClient(awsCfg), region, filter)
+		if err != nil {
+			return err
+		}
+		showResources(append(buckets, queues...))
+		return nil
+	},
+}
+
+func matchTags(tags map[string]string, filter map[string]string) bool {
+	if _, ok := tags[infra.StackTagKey]; !ok {
+		return false
+	}
+	for k, v := range filter {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func listTaggedBuckets(ctx context.Context, awsCfg aws.Config, filter map[string]string) ([]taggedResource, error) {
+	s3Client := s3client.GetClient(awsCfg)
+	output, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing S3 buckets: %w", err)
+	}
+	// bucket tags can only be retrieved with a client for the bucket's region
+	regionalClients := map[string]s3client.Interface{awsCfg.Region: s3Client}
+	var resources []taggedResource
+	for i, bucket := range output.Buckets {
+		// errors for individual buckets are ignored, but not cancellation
+		if err := ctx.Err(); err != nil {
+			return nil, withProgress(err, "after checking %d out of %d S3 buckets", i, len(output.Buckets))
+		}
+		name := *bucket.Name
+		logger := logger.WithValues("bucket", name)
+		bucketRegion, err := s3client.GetBucketRegion(ctx, s3Client, name)
+		if err != nil {
+			logger.V(2).Info("Unable to determine bucket region, skipping", "error", err)
+			continue
+		}
+		regionalClient, ok := regionalClients[bucketRegion]
+		if !ok {
+			cfg := awsCfg.Copy()
+			cfg.Region = bucketRegion
+			regionalClient = s3client.GetClient(cfg)
+			regionalClients[bucketRegion] = regionalClient
+		}
+		tagging, err := regionalClient.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{
+			Bucket: &name,
+		})
+		if err != nil {
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchTagSet" {
+				continue
+			}
+			logger.V(2).Info("Unable to get bucket tags, skipping", "error", err)
+			continue
+		}
+		tags := make(map[string]string)
+		for _, tag := range tagging.TagSet {
+			tags[*tag.Key] = *tag.Value
+		}
+		if matchTags(tags, filter) {
+			resources = append(resources, taggedResource{resourceType: "S3 Bucket", name: name, region: bucketRegion, tags: tags})
+		}
+	}
+	return resources, nil
+}
+
+func listTaggedQueues(ctx context.Context, sqsClient sqsclient.Interface, region string, filter map[string]string) ([]taggedResource, error) {
+	var resources []taggedResource
+	var nextToken *string
+	checked := 0
+	for {
+		output, err := sqsClient.ListQueues(ctx, &sqs.ListQueuesInput{
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, withProgress(fmt.Errorf("error when listing SQS queues: %w", err), "after checking %d SQS queues", checked)
+		}
+		for i := range output.QueueUrls {
+			queueURL := output.QueueUrls[i]
+			tagsOutput, err := sqsClient.ListQueueTags(ctx, &sqs.ListQueueTagsInput{
+				QueueUrl: &queueURL,
+			})
+			if err != nil {
+				return nil, withProgress(fmt.Errorf("error when listing tags for SQS queue '%s': %w", queueURL, err), "after checking %d SQS queues", checked)
+			}
+			checked++
+			if matchTags(tagsOutput.Tags, filter) {
+				resources = append(resources, taggedResource{resourceType: "SQS Queue", name: queueURL, region: region, tags: tagsOutput.Tags})
+			}
+		}
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+	return resources, nil
+}
+
+func showResources(resources []taggedResource) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Type", "Name", "Region", "Stack", "Version"})
+	for _, r := range resources {
+		table.Append([]string{r.resourceType, r.name, r.region, r.tags[infra.StackTagKey], r.tags[infra.VersionTagKey]})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(listResourcesCmd)
+
+	listResourcesCmd.Flags().String("region", GetEnv("AWS_REGION", defaultRegion), "region where AWS resources were provisioned")
+	listResourcesCmd.Flags().String("stack-name", "", "only list resources belonging to this infrastructure stack")
+	listResourcesCmd.Flags().StringToString("tags", nil, "only list resources with these tags (e.g., owner=alice,cost-center=1234)")
+}
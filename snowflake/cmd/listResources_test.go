@@ -0,0 +1,63 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/snowflake/pkg/aws/client/fake"
+	"antrea.io/theia/snowflake/pkg/infra"
+)
+
+func TestListTaggedQueues(t *testing.T) {
+	sqsClient := fake.NewSQSClient("us-west-2", "123456789012")
+	// more queues than the maximum number of results per page
+	for i := 0; i < 1005; i++ {
+		sqsClient.CreateQueue(fmt.Sprintf("queue-%04d", i), map[string]string{"team": "network"})
+	}
+	fooURL := sqsClient.CreateQueue("antrea-flows-foo", map[string]string{infra.StackTagKey: "foo", infra.VersionTagKey: "v0.3.0"})
+	sqsClient.CreateQueue("antrea-flows-bar", map[string]string{infra.StackTagKey: "bar"})
+
+	resources, err := listTaggedQueues(context.Background(), sqsClient, "us-west-2", map[string]string{infra.StackTagKey: "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, []taggedResource{
+		{
+			resourceType: "SQS Queue",
+			name:         fooURL,
+			region:       "us-west-2",
+			tags:         map[string]string{infra.StackTagKey: "foo", infra.VersionTagKey: "v0.3.0"},
+		},
+	}, resources)
+	assert.Equal(t, 2, sqsClient.Calls("ListQueues"))
+	assert.Equal(t, 1007, sqsClient.Calls("ListQueueTags"))
+}
+
+func TestListTaggedQueuesInterrupted(t *testing.T) {
+	sqsClient := fake.NewSQSClient("us-west-2", "123456789012")
+	sqsClient.CreateQueue("antrea-flows-bar", nil)
+	sqsClient.CreateQueue("antrea-flows-foo", nil)
+	// the first call succeeds
+	sqsClient.InjectError("ListQueueTags", nil, 1)
+	sqsClient.InjectError("ListQueueTags", context.DeadlineExceeded, 1)
+
+	_, err := listTaggedQueues(context.Background(), sqsClient, "us-west-2", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "interrupted after checking 1 SQS queues")
+}
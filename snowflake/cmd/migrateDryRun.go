@@ -0,0 +1,120 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/snowflake/database"
+	"antrea.io/theia/snowflake/pkg/infra"
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+)
+
+// migrateDryRunCmd represents the migrate dry-run command
+var migrateDryRunCmd = &cobra.Command{
+	Use:   "dry-run",
+	Short: "Preview the SQL statements of pending database migrations",
+	Long: `This command prints the SQL statements of the database migrations
+which have not been applied yet to the Snowflake database created by "onboard",
+in the order in which they would run, so that they can be reviewed before
+being applied. The names of the objects created by migrations are qualified
+with the database and schema names.
+
+To preview pending migrations, using the database name displayed by "onboard":
+"theia-sf migrate dry-run --database-name <NAME>"
+
+To apply them after review:
+"theia-sf migrate dry-run --database-name <NAME> --approve"
+
+With "--approve", migrations are only applied if no other migration was
+applied since the preview, and destructive changes to the flows table are
+refused unless "--allow-destructive" is provided, just like for "onboard".`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		databaseName, _ := cmd.Flags().GetString("database-name")
+		schemaName, _ := cmd.Flags().GetString("schema-name")
+		warehouseName, _ := cmd.Flags().GetString("warehouse-name")
+		workdir, _ := cmd.Flags().GetString("workdir")
+		approve, _ := cmd.Flags().GetBool("approve")
+		allowDestructive, _ := cmd.Flags().GetBool("allow-destructive")
+		if warehouseName == "" {
+			warehouseName = infra.WarehouseName(databaseName, infra.IngestionWorkload)
+		}
+		ctx, cancel := commandContext(cmd, 300*time.Second)
+		defer cancel()
+		dsn, _, err := sf.GetDSN(sf.SetWarehouse(warehouseName))
+		if err != nil {
+			return fmt.Errorf("failed to create DSN: %w", err)
+		}
+		db, err := sf.OpenDB(dsn, sf.DefaultConnectionConfig)
+		if err != nil {
+			return fmt.Errorf("failed to connect to Snowflake: %w", err)
+		}
+		defer db.Close()
+		sfClient := sf.NewClient(db, logger)
+		version, dirty, err := sfClient.GetMigrationVersion(ctx, databaseName, schemaName)
+		if err != nil {
+			return fmt.Errorf("error when getting migration version: %w", err)
+		}
+		if dirty {
+			return fmt.Errorf("last migration (version %d) failed and must be fixed manually", version)
+		}
+		migrations, err := infra.PendingMigrations(database.Migrations, database.MigrationsPath, version, databaseName, schemaName)
+		if err != nil {
+			return err
+		}
+		if len(migrations) == 0 {
+			fmt.Println("No pending migrations")
+			return nil
+		}
+		for _, m := range migrations {
+			fmt.Printf("-- Migration %d (%s)\n", m.Version, m.FileName)
+			fmt.Println(m.SQL)
+			fmt.Println()
+		}
+		if !approve {
+			fmt.Printf("%d pending migration(s), run again with --approve to apply them\n", len(migrations))
+			return nil
+		}
+		if workdir == "" {
+			workdir, err = os.MkdirTemp("", "antrea-migrate")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(workdir)
+		}
+		if err := infra.ApplyMigrations(ctx, logger, sfClient, workdir, databaseName, schemaName, warehouseName, version, allowDestructive); err != nil {
+			return err
+		}
+		fmt.Println("SUCCESS!")
+		return nil
+	},
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateDryRunCmd)
+
+	migrateDryRunCmd.Flags().String("database-name", "", "name of the Snowflake database created by onboard")
+	migrateDryRunCmd.MarkFlagRequired("database-name")
+	migrateDryRunCmd.Flags().String("schema-name", "THEIA", "name of the Snowflake schema created by onboard")
+	migrateDryRunCmd.Flags().String("warehouse-name", "", "Snowflake Virtual Warehouse to use for migrations, by default the ingestion warehouse created by onboard is used")
+	migrateDryRunCmd.Flags().String("workdir", "", "use provided local workdir to install migrate-snowflake (by default a temporary one will be created)")
+	migrateDryRunCmd.Flags().Bool("approve", false, "apply the pending migrations after printing them")
+	migrateDryRunCmd.Flags().Bool("allow-destructive", false, "allow database migrations to make destructive changes to the flows table (e.g., dropping a column); a backup clone of the table is created first")
+}
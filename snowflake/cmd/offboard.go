@@ -15,12 +15,12 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
 	"antrea.io/theia/snowflake/pkg/infra"
 )
 
@@ -49,7 +49,7 @@ For example:
 		keyRegion, _ := cmd.Flags().GetString("key-region")
 		workdir, _ := cmd.Flags().GetString("workdir")
 		verbose := verbosity >= 2
-		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+		ctx, cancel := commandContext(cmd, 300*time.Second)
 		defer cancel()
 		if bucketRegion == "" {
 			var err error
@@ -66,7 +66,7 @@ For example:
 			}
 			secretsProviderURL = infra.KmsSecretsProviderURL(keyID, keyRegion)
 		}
-		mgr := infra.NewManager(logger, stackName, stateBackendURL, secretsProviderURL, region, "", workdir, verbose)
+		mgr := infra.NewManager(logger, stackName, stateBackendURL, secretsProviderURL, region, "", infra.DefaultWarehouseSizes(), 1, workdir, verbose, nil, s3client.LifecycleConfig{}, infra.SQSQueueConfig{}, false, false)
 		if err := mgr.Offboard(ctx); err != nil {
 			return err
 		}
@@ -15,14 +15,15 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
 	"antrea.io/theia/snowflake/pkg/infra"
 )
 
@@ -53,7 +54,18 @@ You can delete all the created cloud resources at any time with:
 
 The "onboard" command requires a Snowflake warehouse to run database
 migration. By default, it will create a temporary one. You can also bring your
-own by using the "--warehouse-name" parameter.`,
+own by using the "--warehouse-name" parameter.
+
+The "onboard" command also creates one warehouse per workload (ingestion,
+query and recommendation), so that queries from one workload never queue
+behind another one. Their sizes can be set with "--ingestion-warehouse-size",
+"--query-warehouse-size" and "--recommendation-warehouse-size".
+
+Before running database migrations, the "onboard" command compares the schema
+of the existing flows table with the one expected by this version of theia-sf,
+and refuses to make destructive changes (e.g., dropping a column) unless
+"--allow-destructive" is provided, in which case the flows table is cloned
+first, as a backup.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		region, _ := cmd.Flags().GetString("region")
 		stackName, _ := cmd.Flags().GetString("stack-name")
@@ -63,12 +75,47 @@ own by using the "--warehouse-name" parameter.`,
 		keyID, _ := cmd.Flags().GetString("key-id")
 		keyRegion, _ := cmd.Flags().GetString("key-region")
 		warehouseName, _ := cmd.Flags().GetString("warehouse-name")
+		warehouseSizes, err := getWarehouseSizes(cmd)
+		if err != nil {
+			return err
+		}
 		workdir, _ := cmd.Flags().GetString("workdir")
+		tags, _ := cmd.Flags().GetStringToString("tags")
+		if err := infra.ValidateTags(tags); err != nil {
+			return err
+		}
+		flowsShards, _ := cmd.Flags().GetInt("flows-shards")
+		if err := infra.ValidateFlowsShards(flowsShards); err != nil {
+			return err
+		}
+		flowsLifecycleConfig, err := getLifecycleConfig(cmd)
+		if err != nil {
+			return err
+		}
+		allowDestructive, _ := cmd.Flags().GetBool("allow-destructive")
+		coldFlows, _ := cmd.Flags().GetBool("cold-flows")
+		if coldFlows {
+			if err := infra.ValidateColdFlowsLifecycle(flowsLifecycleConfig); err != nil {
+				return err
+			}
+		}
+		sqsEncryption, _ := cmd.Flags().GetString("sqs-encryption")
+		sqsKeyID, _ := cmd.Flags().GetString("sqs-key-id")
+		sqsMessageRetentionSeconds, _ := cmd.Flags().GetInt("sqs-message-retention-seconds")
+		sqsVisibilityTimeoutSeconds, _ := cmd.Flags().GetInt("sqs-visibility-timeout-seconds")
+		sqsQueueConfig := infra.SQSQueueConfig{
+			Encryption:               infra.SQSEncryption(sqsEncryption),
+			KMSKeyID:                 sqsKeyID,
+			MessageRetentionSeconds:  sqsMessageRetentionSeconds,
+			VisibilityTimeoutSeconds: sqsVisibilityTimeoutSeconds,
+		}
+		if err := sqsQueueConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid SQS queue configuration: %w", err)
+		}
 		verbose := verbosity >= 2
-		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+		ctx, cancel := commandContext(cmd, 300*time.Second)
 		defer cancel()
 		if bucketRegion == "" {
-			var err error
 			bucketRegion, err = GetBucketRegion(ctx, bucketName, region)
 			if err != nil {
 				return err
@@ -82,7 +129,7 @@ own by using the "--warehouse-name" parameter.`,
 			}
 			secretsProviderURL = infra.KmsSecretsProviderURL(keyID, keyRegion)
 		}
-		mgr := infra.NewManager(logger, stackName, stateBackendURL, secretsProviderURL, region, warehouseName, workdir, verbose)
+		mgr := infra.NewManager(logger, stackName, stateBackendURL, secretsProviderURL, region, warehouseName, warehouseSizes, flowsShards, workdir, verbose, tags, *flowsLifecycleConfig, sqsQueueConfig, coldFlows, allowDestructive)
 		result, err := mgr.Onboard(ctx)
 		if err != nil {
 			return err
@@ -95,17 +142,42 @@ own by using the "--warehouse-name" parameter.`,
 	},
 }
 
+func getWarehouseSizes(cmd *cobra.Command) (infra.WarehouseSizes, error) {
+	sizes := make(infra.WarehouseSizes)
+	for _, workload := range infra.Workloads {
+		sizes[workload], _ = cmd.Flags().GetString(fmt.Sprintf("%s-warehouse-size", workload))
+	}
+	if err := sizes.Validate(); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
 func showResults(result *infra.Result) {
 	table := tablewriter.NewWriter(os.Stdout)
 	data := [][]string{
 		[]string{"Region", result.Region},
 		[]string{"Bucket Name", result.BucketName},
 		[]string{"Bucket Flows Folder", result.BucketFlowsFolder},
+	}
+	if len(result.BucketFlowsShards) > 0 {
+		data = append(data, []string{"Bucket Flows Shards", strings.Join(result.BucketFlowsShards, "\n")})
+	}
+	data = append(data, [][]string{
 		[]string{"Snowflake Database Name", result.DatabaseName},
 		[]string{"Snowflake Schema Name", result.SchemaName},
 		[]string{"Snowflake Flows Table Name", result.FlowsTableName},
 		[]string{"SNS Topic ARN", result.SNSTopicARN},
 		[]string{"SQS Queue ARN", result.SQSQueueARN},
+		[]string{"Snowflake Ingestion Warehouse Name", result.WarehouseNames[infra.IngestionWorkload]},
+		[]string{"Snowflake Query Warehouse Name", result.WarehouseNames[infra.QueryWorkload]},
+		[]string{"Snowflake Recommendation Warehouse Name", result.WarehouseNames[infra.RecommendationWorkload]},
+	}...)
+	if result.ColdFlowsTableName != "" {
+		data = append(data, [][]string{
+			[]string{"Snowflake Cold Flows Table Name", result.ColdFlowsTableName},
+			[]string{"Snowflake All Flows View Name", result.AllFlowsViewName},
+		}...)
 	}
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	table.AppendBulk(data)
@@ -125,4 +197,17 @@ func init() {
 	onboardCmd.Flags().String("key-region", "", "Kms key region")
 	onboardCmd.Flags().String("workdir", "", "use provided local workdir (by default a temporary one will be created")
 	onboardCmd.Flags().String("warehouse-name", "", "Snowflake Virtual Warehouse to use for onboarding queries, by default we will use a temporary one")
+	defaultWarehouseSizes := infra.DefaultWarehouseSizes()
+	for _, workload := range infra.Workloads {
+		onboardCmd.Flags().String(fmt.Sprintf("%s-warehouse-size", workload), defaultWarehouseSizes[workload], fmt.Sprintf("size of the Snowflake Virtual Warehouse dedicated to the %s workload (e.g., XSMALL, SMALL, MEDIUM)", workload))
+	}
+	onboardCmd.Flags().Int("flows-shards", 1, "number of key prefixes (shards) to spread flow records across in the flows bucket, each one with its own Snowpipe; use more than one shard for very large fleets, to avoid being limited by the S3 request rate of a single prefix")
+	addLifecycleFlags(onboardCmd, s3client.LifecycleConfig{ExpirationDays: defaultFlowRecordsRetentionDays}, "flow records in the flows bucket")
+	onboardCmd.Flags().String("sqs-encryption", "", "server-side encryption of the SQS queue for Snowpipe error notifications, sqs (SQS-managed key) or kms; defaults to kms if --sqs-key-id is set, and to sqs otherwise")
+	onboardCmd.Flags().String("sqs-key-id", "", "Kms key ID used to encrypt the SQS queue for Snowpipe error notifications, with kms encryption")
+	onboardCmd.Flags().Int("sqs-message-retention-seconds", defaultSQSMessageRetentionSeconds, "how long Snowpipe error notifications are retained in the SQS queue")
+	onboardCmd.Flags().Int("sqs-visibility-timeout-seconds", defaultSQSVisibilityTimeoutSeconds, "how long a received Snowpipe error notification is hidden from other consumers of the SQS queue")
+	onboardCmd.Flags().Bool("cold-flows", false, "create an external table over the flow records in the bucket, so that flows deleted from the flows table after 30 days can still be queried, along with a view over all flows; flow records must not expire from the bucket before then")
+	onboardCmd.Flags().Bool("allow-destructive", false, "allow database migrations to make destructive changes to the flows table (e.g., dropping a column); a backup clone of the table is created first")
+	onboardCmd.Flags().StringToString("tags", nil, "tags to apply to all AWS resources in addition to the theia-stack and theia-version tags (e.g., owner=alice,cost-center=1234)")
 }
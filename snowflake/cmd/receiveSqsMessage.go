@@ -60,7 +60,7 @@ it will return immediately.`,
 			return fmt.Errorf("region conflict between --region flag and ARN region")
 		}
 		region = arn.Region
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := commandContext(cmd, 10*time.Second)
 		defer cancel()
 		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
 		if err != nil {
@@ -83,7 +83,7 @@ func receiveSQSMessage(ctx context.Context, sqsClient sqsclient.Interface, queue
 		return *output.QueueUrl, nil
 	}()
 	if err != nil {
-		return fmt.Errorf("error when retrieving SQS queue URL: %v", err)
+		return fmt.Errorf("error when retrieving SQS queue URL: %w", err)
 	}
 	output, err := sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
 		QueueUrl:            &queueURL,
@@ -91,7 +91,7 @@ func receiveSQSMessage(ctx context.Context, sqsClient sqsclient.Interface, queue
 		WaitTimeSeconds:     int32(0),
 	})
 	if err != nil {
-		return fmt.Errorf("error when receiving message from SQS queue: %v", err)
+		return fmt.Errorf("error when receiving message from SQS queue: %w", err)
 	}
 	if len(output.Messages) == 0 {
 		return nil
@@ -106,7 +106,7 @@ func receiveSQSMessage(ctx context.Context, sqsClient sqsclient.Interface, queue
 		ReceiptHandle: message.ReceiptHandle,
 	})
 	if err != nil {
-		return fmt.Errorf("error when deleting message from SQS queue: %v", err)
+		return fmt.Errorf("error when deleting message from SQS queue: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,83 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/snowflake/pkg/aws/client/fake"
+)
+
+func TestReceiveSQSMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		messages         []string
+		delete           bool
+		expectedMessages int
+	}{
+		{
+			name:             "empty queue",
+			messages:         nil,
+			delete:           true,
+			expectedMessages: 0,
+		},
+		{
+			name:             "keep message",
+			messages:         []string{"error 1", "error 2"},
+			delete:           false,
+			expectedMessages: 2,
+		},
+		{
+			name:             "delete message",
+			messages:         []string{"error 1", "error 2"},
+			delete:           true,
+			expectedMessages: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sqsClient := fake.NewSQSClient("us-west-2", "123456789012")
+			sqsClient.CreateQueue("antrea-flows-abc", nil)
+			for _, m := range tc.messages {
+				sqsClient.SendMessage("antrea-flows-abc", m)
+			}
+			require.NoError(t, receiveSQSMessage(context.Background(), sqsClient, "antrea-flows-abc", tc.delete))
+			assert.Equal(t, tc.expectedMessages, sqsClient.MessageCount("antrea-flows-abc"))
+			if len(tc.messages) > 0 {
+				assert.Equal(t, 1, sqsClient.Calls("ReceiveMessage"))
+			}
+		})
+	}
+}
+
+func TestReceiveSQSMessageErrors(t *testing.T) {
+	sqsClient := fake.NewSQSClient("us-west-2", "123456789012")
+	err := receiveSQSMessage(context.Background(), sqsClient, "antrea-flows-abc", false)
+	var queueDoesNotExist *sqstypes.QueueDoesNotExist
+	assert.ErrorAs(t, err, &queueDoesNotExist)
+	assert.ErrorContains(t, err, "error when retrieving SQS queue URL")
+
+	sqsClient.CreateQueue("antrea-flows-abc", nil)
+	sqsClient.SendMessage("antrea-flows-abc", "error 1")
+	sqsClient.InjectError("DeleteMessage", fake.ErrSQSThrottling, 1)
+	err = receiveSQSMessage(context.Background(), sqsClient, "antrea-flows-abc", true)
+	assert.ErrorIs(t, err, fake.ErrSQSThrottling)
+	assert.ErrorContains(t, err, "error when deleting message from SQS queue")
+	assert.Equal(t, 1, sqsClient.MessageCount("antrea-flows-abc"))
+}
@@ -15,9 +15,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -70,8 +73,12 @@ started:
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Commands are cancelled on SIGINT and SIGTERM.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -80,4 +87,5 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 
 	rootCmd.PersistentFlags().IntVarP(&verbosity, "verbosity", "v", 0, "log verbosity")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "maximum time to wait for the command to complete, by default a timeout suitable for each command is used")
 }
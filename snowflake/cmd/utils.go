@@ -16,10 +16,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
 
 	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
 )
@@ -31,6 +34,26 @@ func GetEnv(key string, defaultValue string) string {
 	return defaultValue
 }
 
+// commandContext returns a context derived from the command's context, which
+// is cancelled on SIGINT and SIGTERM, with the timeout provided with --timeout,
+// or defaultTimeout.
+func commandContext(cmd *cobra.Command, defaultTimeout time.Duration) (context.Context, context.CancelFunc) {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return context.WithTimeout(cmd.Context(), timeout)
+}
+
+// withProgress adds a description of the work completed so far to errors
+// caused by the context being cancelled or timing out.
+func withProgress(err error, format string, args ...interface{}) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w (interrupted %s)", err, fmt.Sprintf(format, args...))
+	}
+	return err
+}
+
 func GetBucketRegion(ctx context.Context, bucket string, regionHint string) (string, error) {
 	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(regionHint))
 	if err != nil {
@@ -44,3 +67,27 @@ func GetBucketRegion(ctx context.Context, bucket string, regionHint string) (str
 	}
 	return bucketRegion, err
 }
+
+func addLifecycleFlags(cmd *cobra.Command, defaults s3client.LifecycleConfig, target string) {
+	cmd.Flags().Int32("transition-ia-days", defaults.TransitionToIADays, fmt.Sprintf("number of days after which %s are transitioned to the STANDARD_IA storage class (0 to disable)", target))
+	cmd.Flags().Int32("transition-glacier-days", defaults.TransitionToGlacierDays, fmt.Sprintf("number of days after which %s are transitioned to the GLACIER storage class (0 to disable)", target))
+	cmd.Flags().Int32("expiration-days", defaults.ExpirationDays, fmt.Sprintf("number of days after which %s are deleted (0 to disable)", target))
+	cmd.Flags().Int32("abort-multipart-upload-days", defaults.AbortIncompleteMultipartUploadDays, "number of days after which incomplete multipart uploads are aborted (0 to disable)")
+}
+
+func getLifecycleConfig(cmd *cobra.Command) (*s3client.LifecycleConfig, error) {
+	transitionToIADays, _ := cmd.Flags().GetInt32("transition-ia-days")
+	transitionToGlacierDays, _ := cmd.Flags().GetInt32("transition-glacier-days")
+	expirationDays, _ := cmd.Flags().GetInt32("expiration-days")
+	abortMultipartUploadDays, _ := cmd.Flags().GetInt32("abort-multipart-upload-days")
+	config := &s3client.LifecycleConfig{
+		TransitionToIADays:                 transitionToIADays,
+		TransitionToGlacierDays:            transitionToGlacierDays,
+		ExpirationDays:                     expirationDays,
+		AbortIncompleteMultipartUploadDays: abortMultipartUploadDays,
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid lifecycle configuration: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"antrea.io/theia/snowflake/database"
+	"antrea.io/theia/snowflake/pkg/dataquality"
+	"antrea.io/theia/snowflake/pkg/infra"
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+)
+
+// validateIngestionCmd represents the validate-ingestion command
+var validateIngestionCmd = &cobra.Command{
+	Use:   "validate-ingestion",
+	Short: "Check the quality of the flows ingested into Snowflake",
+	Long: `This command runs data quality queries against the flows recently
+ingested into the Snowflake database created by "onboard", to catch the bugs
+of flow exporters early. It counts the flows with missing key columns
+(timestamps, IPs, cluster UUID), with negative byte counts, exported more than
+once (same 5-tuple, time range and cluster), or with inconsistent timestamps
+indicating clock skew, and fails if any flow violates a check.
+
+To check the flows ingested during the last hour, using the database name
+displayed by "onboard":
+"theia-sf validate-ingestion --database-name <NAME>"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		databaseName, _ := cmd.Flags().GetString("database-name")
+		schemaName, _ := cmd.Flags().GetString("schema-name")
+		warehouseName, _ := cmd.Flags().GetString("warehouse-name")
+		since, _ := cmd.Flags().GetDuration("since")
+		if since <= 0 {
+			return fmt.Errorf("--since must be a positive duration")
+		}
+		if warehouseName == "" {
+			warehouseName = infra.WarehouseName(databaseName, infra.QueryWorkload)
+		}
+		checks, err := dataquality.LoadChecks(database.Checks, database.ChecksPath)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := commandContext(cmd, 300*time.Second)
+		defer cancel()
+		dsn, _, err := sf.GetDSN(sf.SetWarehouse(warehouseName))
+		if err != nil {
+			return fmt.Errorf("failed to create DSN: %w", err)
+		}
+		db, err := sf.OpenDB(dsn, sf.DefaultConnectionConfig)
+		if err != nil {
+			return fmt.Errorf("failed to connect to Snowflake: %w", err)
+		}
+		defer db.Close()
+		results, err := dataquality.Run(ctx, db, logger, checks, databaseName, schemaName, time.Now().Add(-since))
+		if err != nil {
+			return err
+		}
+		showCheckResults(results)
+		failed := 0
+		for _, result := range results {
+			if result.Violations > 0 {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d data quality checks failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func showCheckResults(results []dataquality.Result) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Check", "Status", "Violations", "Description"})
+	for _, r := range results {
+		status := "PASS"
+		if r.Violations > 0 {
+			status = "FAIL"
+		}
+		table.Append([]string{r.Check.Name, status, strconv.FormatInt(r.Violations, 10), r.Check.Description})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(validateIngestionCmd)
+
+	validateIngestionCmd.Flags().String("database-name", "", "name of the Snowflake database created by onboard")
+	validateIngestionCmd.MarkFlagRequired("database-name")
+	validateIngestionCmd.Flags().String("schema-name", "THEIA", "name of the Snowflake schema created by onboard")
+	validateIngestionCmd.Flags().String("warehouse-name", "", "Snowflake Virtual Warehouse to use for the data quality queries, by default the query warehouse created by onboard is used")
+	validateIngestionCmd.Flags().Duration("since", time.Hour, "check the flows ingested during this duration")
+}
@@ -0,0 +1,28 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"embed"
+)
+
+// Checks are the data quality checks of the ingested flows. Each one is a
+// query returning the number of violations among the flows of the
+// recent_flows table expression, preceded by a comment describing the check.
+//
+//go:embed checks/*.sql
+var Checks embed.FS
+
+const ChecksPath = "checks"
@@ -0,0 +1,5 @@
+-- flows end before they start, or more than 5 minutes after being inserted
+SELECT COUNT(*)
+FROM recent_flows
+WHERE flowStartSeconds > flowEndSeconds
+  OR flowEndSeconds > DATEADD(minute, 5, timeInserted)
@@ -0,0 +1,9 @@
+-- records are exported more than once for the same flow and time range
+SELECT COALESCE(SUM(records - 1), 0)
+FROM (
+  SELECT COUNT(*) AS records
+  FROM recent_flows
+  GROUP BY flowStartSeconds, flowEndSeconds, sourceIP, destinationIP, sourceTransportPort,
+    destinationTransportPort, protocolIdentifier, clusterUUID
+  HAVING COUNT(*) > 1
+)
@@ -0,0 +1,5 @@
+-- byte counts are negative
+SELECT COUNT(*)
+FROM recent_flows
+WHERE octetDeltaCount < 0 OR octetTotalCount < 0
+  OR reverseOctetDeltaCount < 0 OR reverseOctetTotalCount < 0
@@ -0,0 +1,6 @@
+-- flow timestamps, IPs or cluster UUID are missing
+SELECT COUNT(*)
+FROM recent_flows
+WHERE flowStartSeconds IS NULL OR flowEndSeconds IS NULL
+  OR sourceIP IS NULL OR sourceIP = '' OR destinationIP IS NULL OR destinationIP = ''
+  OR clusterUUID IS NULL OR clusterUUID = ''
@@ -0,0 +1,79 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+// Column describes a column of a table, using the data type names reported by
+// INFORMATION_SCHEMA.COLUMNS in Snowflake (e.g., STRING(50) is reported as
+// TEXT(50)).
+type Column struct {
+	Name string
+	Type string
+}
+
+// FlowsTableColumns is the expected schema of the flows table, once all the
+// migrations have been applied. It must be updated every time a migration
+// modifies the flows table, which is checked against the embedded migrations
+// by TestFlowsTableColumns.
+var FlowsTableColumns = []Column{
+	{Name: "FLOWSTARTSECONDS", Type: "TIMESTAMP_TZ"},
+	{Name: "FLOWENDSECONDS", Type: "TIMESTAMP_TZ"},
+	{Name: "FLOWENDSECONDSFROMSOURCENODE", Type: "TIMESTAMP_TZ"},
+	{Name: "FLOWENDSECONDSFROMDESTINATIONNODE", Type: "TIMESTAMP_TZ"},
+	{Name: "FLOWENDREASON", Type: "NUMBER(3,0)"},
+	{Name: "SOURCEIP", Type: "TEXT(50)"},
+	{Name: "DESTINATIONIP", Type: "TEXT(50)"},
+	{Name: "SOURCETRANSPORTPORT", Type: "NUMBER(5,0)"},
+	{Name: "DESTINATIONTRANSPORTPORT", Type: "NUMBER(5,0)"},
+	{Name: "PROTOCOLIDENTIFIER", Type: "NUMBER(3,0)"},
+	{Name: "PACKETTOTALCOUNT", Type: "NUMBER(20,0)"},
+	{Name: "OCTETTOTALCOUNT", Type: "NUMBER(20,0)"},
+	{Name: "PACKETDELTACOUNT", Type: "NUMBER(20,0)"},
+	{Name: "OCTETDELTACOUNT", Type: "NUMBER(20,0)"},
+	{Name: "REVERSEPACKETTOTALCOUNT", Type: "NUMBER(20,0)"},
+	{Name: "REVERSEOCTETTOTALCOUNT", Type: "NUMBER(20,0)"},
+	{Name: "REVERSEPACKETDELTACOUNT", Type: "NUMBER(20,0)"},
+	{Name: "REVERSEOCTETDELTACOUNT", Type: "NUMBER(20,0)"},
+	{Name: "SOURCEPODNAME", Type: "TEXT(256)"},
+	{Name: "SOURCEPODNAMESPACE", Type: "TEXT(256)"},
+	{Name: "SOURCENODENAME", Type: "TEXT(256)"},
+	{Name: "DESTINATIONPODNAME", Type: "TEXT(256)"},
+	{Name: "DESTINATIONPODNAMESPACE", Type: "TEXT(256)"},
+	{Name: "DESTINATIONNODENAME", Type: "TEXT(256)"},
+	{Name: "DESTINATIONCLUSTERIP", Type: "TEXT(50)"},
+	{Name: "DESTINATIONSERVICEPORT", Type: "NUMBER(5,0)"},
+	{Name: "DESTINATIONSERVICEPORTNAME", Type: "TEXT(256)"},
+	{Name: "INGRESSNETWORKPOLICYNAME", Type: "TEXT(256)"},
+	{Name: "INGRESSNETWORKPOLICYNAMESPACE", Type: "TEXT(256)"},
+	{Name: "INGRESSNETWORKPOLICYRULENAME", Type: "TEXT(256)"},
+	{Name: "INGRESSNETWORKPOLICYRULEACTION", Type: "NUMBER(3,0)"},
+	{Name: "INGRESSNETWORKPOLICYTYPE", Type: "NUMBER(3,0)"},
+	{Name: "EGRESSNETWORKPOLICYNAME", Type: "TEXT(256)"},
+	{Name: "EGRESSNETWORKPOLICYNAMESPACE", Type: "TEXT(256)"},
+	{Name: "EGRESSNETWORKPOLICYRULENAME", Type: "TEXT(256)"},
+	{Name: "EGRESSNETWORKPOLICYRULEACTION", Type: "NUMBER(3,0)"},
+	{Name: "EGRESSNETWORKPOLICYTYPE", Type: "NUMBER(3,0)"},
+	{Name: "TCPSTATE", Type: "TEXT(20)"},
+	{Name: "FLOWTYPE", Type: "NUMBER(3,0)"},
+	{Name: "SOURCEPODLABELS", Type: "TEXT(10000)"},
+	{Name: "DESTINATIONPODLABELS", Type: "TEXT(10000)"},
+	{Name: "THROUGHPUT", Type: "NUMBER(20,0)"},
+	{Name: "REVERSETHROUGHPUT", Type: "NUMBER(20,0)"},
+	{Name: "THROUGHPUTFROMSOURCENODE", Type: "NUMBER(20,0)"},
+	{Name: "THROUGHPUTFROMDESTINATIONNODE", Type: "NUMBER(20,0)"},
+	{Name: "REVERSETHROUGHPUTFROMSOURCENODE", Type: "NUMBER(20,0)"},
+	{Name: "REVERSETHROUGHPUTFROMDESTINATIONNODE", Type: "NUMBER(20,0)"},
+	{Name: "CLUSTERUUID", Type: "TEXT(36)"},
+	{Name: "TIMEINSERTED", Type: "TIMESTAMP_TZ"},
+}
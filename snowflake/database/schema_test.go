@@ -0,0 +1,100 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	createFlowsTableRegexp = regexp.MustCompile(`(?is)CREATE\s+TABLE\s+flows\s*\((.*)\)\s*IF\s+NOT\s+EXISTS`)
+	addFlowsColumnRegexp   = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+flows\s+ADD\s+COLUMN\s+(\w+)\s+(.+)$`)
+	dropFlowsColumnRegexp  = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+flows\s+DROP\s+COLUMN\s+(\w+)$`)
+	alterFlowsTableRegexp  = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+flows\b`)
+	columnTypeRegexp       = regexp.MustCompile(`(?i)^(\w+(?:\s*\([\d\s,]+\))?)`)
+)
+
+// normalizeColumnType returns the data type reported by Snowflake for the data
+// type of a column definition, e.g. TEXT(50) for "STRING(50) NOT NULL".
+func normalizeColumnType(definition string) string {
+	dataType := strings.ToUpper(columnTypeRegexp.FindString(strings.TrimSpace(definition)))
+	dataType = strings.Join(strings.Fields(dataType), "")
+	for _, alias := range []string{"STRING", "VARCHAR"} {
+		dataType = strings.Replace(dataType, alias, "TEXT", 1)
+	}
+	return dataType
+}
+
+// flowsColumnsFromMigrations returns the columns of the flows table after
+// applying the embedded up migrations in order.
+func flowsColumnsFromMigrations(t *testing.T) []Column {
+	paths, err := fs.Glob(Migrations, MigrationsPath+"/*.up.sql")
+	require.NoError(t, err)
+	sort.Strings(paths)
+	var columns []Column
+	for _, path := range paths {
+		data, err := fs.ReadFile(Migrations, path)
+		require.NoError(t, err)
+		for _, statement := range strings.Split(string(data), ";") {
+			statement = strings.TrimSpace(statement)
+			if match := createFlowsTableRegexp.FindStringSubmatch(statement); match != nil {
+				for _, definition := range strings.Split(match[1], ",\n") {
+					fields := strings.Fields(definition)
+					require.GreaterOrEqual(t, len(fields), 2, "invalid column definition %q in %s", definition, path)
+					columns = append(columns, Column{
+						Name: strings.ToUpper(fields[0]),
+						Type: normalizeColumnType(strings.TrimSpace(definition)[len(fields[0]):]),
+					})
+				}
+			} else if match := addFlowsColumnRegexp.FindStringSubmatch(statement); match != nil {
+				columns = append(columns, Column{Name: strings.ToUpper(match[1]), Type: normalizeColumnType(match[2])})
+			} else if match := dropFlowsColumnRegexp.FindStringSubmatch(statement); match != nil {
+				for i, column := range columns {
+					if column.Name == strings.ToUpper(match[1]) {
+						columns = append(columns[:i], columns[i+1:]...)
+						break
+					}
+				}
+			} else {
+				require.False(t, alterFlowsTableRegexp.MatchString(statement), "unsupported change of the flows table in %s: %s", path, statement)
+			}
+		}
+	}
+	return columns
+}
+
+func TestNormalizeColumnType(t *testing.T) {
+	for definition, expectedType := range map[string]string{
+		"STRING(50)":   "TEXT(50)",
+		"NUMBER(3, 0)": "NUMBER(3,0)",
+		"TIMESTAMP_TZ DEFAULT current_timestamp()": "TIMESTAMP_TZ",
+		" varchar(36) NOT NULL":                    "TEXT(36)",
+	} {
+		assert.Equal(t, expectedType, normalizeColumnType(definition), definition)
+	}
+}
+
+// TestFlowsTableColumns checks that FlowsTableColumns, which is used to detect
+// changes of the live flows table, matches the embedded migrations.
+func TestFlowsTableColumns(t *testing.T) {
+	assert.Equal(t, flowsColumnsFromMigrations(t), FlowsTableColumns)
+}
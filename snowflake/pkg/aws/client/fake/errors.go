@@ -0,0 +1,98 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"sync"
+
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// ErrS3Throttling is the error returned by S3 when the request rate for a
+	// prefix is too high.
+	ErrS3Throttling error = &smithy.GenericAPIError{Code: "SlowDown", Message: "Please reduce your request rate.", Fault: smithy.FaultServer}
+	// ErrSQSThrottling is the error returned by SQS when the request rate is
+	// too high.
+	ErrSQSThrottling error = &smithy.GenericAPIError{Code: "RequestThrottled", Message: "Request is throttled.", Fault: smithy.FaultClient}
+)
+
+type injectedError struct {
+	err error
+	// number of calls which will fail, or -1 for all calls
+	remaining int
+}
+
+// errorInjector makes calls to client methods fail with a configured error. It
+// also counts the calls to each method.
+type errorInjector struct {
+	mutex  sync.Mutex
+	errors map[string][]*injectedError
+	calls  map[string]int
+}
+
+// InjectError makes the next times calls to the method fail with err. If times
+// is negative, all calls fail until ClearErrors is called. Errors injected for
+// the same method are returned in order.
+func (i *errorInjector) InjectError(method string, err error, times int) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.errors == nil {
+		i.errors = make(map[string][]*injectedError)
+	}
+	if times < 0 {
+		times = -1
+	}
+	i.errors[method] = append(i.errors[method], &injectedError{err: err, remaining: times})
+}
+
+// ClearErrors removes all injected errors.
+func (i *errorInjector) ClearErrors() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.errors = nil
+}
+
+// Calls returns the number of calls to the method, including failed ones.
+func (i *errorInjector) Calls(method string) int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.calls[method]
+}
+
+// call records a call to the method and returns the injected error, if any.
+func (i *errorInjector) call(method string) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.calls == nil {
+		i.calls = make(map[string]int)
+	}
+	i.calls[method]++
+	errs := i.errors[method]
+	for len(errs) > 0 {
+		e := errs[0]
+		if e.remaining == 0 {
+			errs = errs[1:]
+			continue
+		}
+		if e.remaining > 0 {
+			e.remaining--
+		}
+		i.errors[method] = errs
+		return e.err
+	}
+	delete(i.errors, method)
+	return nil
+}
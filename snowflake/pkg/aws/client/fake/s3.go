@@ -0,0 +1,405 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
+)
+
+// default and maximum number of keys returned by ListObjectsV2
+const maxListObjectsKeys = 1000
+
+type s3Object struct {
+	data         []byte
+	lastModified time.Time
+}
+
+type s3Bucket struct {
+	region       string
+	creationDate time.Time
+	objects      map[string]*s3Object
+	tags         map[string]string
+	lifecycle    *s3types.BucketLifecycleConfiguration
+	notification *s3types.NotificationConfiguration
+}
+
+// S3Client is an in-memory implementation of the S3 client interface, which
+// is safe for concurrent use. Errors can be injected for any method, with
+// InjectError, and DeleteObjects can be made to partially fail with
+// FailDeletion.
+type S3Client struct {
+	errorInjector
+	mutex        sync.Mutex
+	region       string
+	buckets      map[string]*s3Bucket
+	undeleteable map[string]bool
+	now          func() time.Time
+}
+
+var _ s3client.Interface = &S3Client{}
+
+// NewS3Client returns a client without any bucket. Buckets are created in the
+// provided region, unless another region is specified when creating them.
+func NewS3Client(region string) *S3Client {
+	return &S3Client{
+		region:       region,
+		buckets:      make(map[string]*s3Bucket),
+		undeleteable: make(map[string]bool),
+		now:          time.Now,
+	}
+}
+
+// SetBucketTags replaces the tags of an existing bucket.
+func (c *S3Client) SetBucketTags(bucket string, tags map[string]string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if b, ok := c.buckets[bucket]; ok {
+		b.tags = tags
+	}
+}
+
+// FailDeletion makes DeleteObjects fail for the provided keys, which are
+// reported in the Errors field of the output while other keys are deleted.
+func (c *S3Client) FailDeletion(keys ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range keys {
+		c.undeleteable[key] = true
+	}
+}
+
+// ObjectKeys returns the sorted keys of all the objects in the bucket.
+func (c *S3Client) ObjectKeys(bucket string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, ok := c.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	return b.sortedKeys("")
+}
+
+func (b *s3Bucket) sortedKeys(prefix string) []string {
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func noSuchBucket(bucket string) error {
+	return &s3types.NoSuchBucket{Message: &bucket}
+}
+
+// getBucket must be called with the mutex held.
+func (c *S3Client) getBucket(bucket *string) (*s3Bucket, error) {
+	if bucket == nil {
+		return nil, &smithy.GenericAPIError{Code: "InvalidBucketName", Message: "bucket name is required"}
+	}
+	b, ok := c.buckets[*bucket]
+	if !ok {
+		return nil, noSuchBucket(*bucket)
+	}
+	return b, nil
+}
+
+func (c *S3Client) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if err := c.call("HeadBucket"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.buckets[*params.Bucket]; !ok {
+		return nil, &s3types.NotFound{}
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (c *S3Client) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	if err := c.call("CreateBucket"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.buckets[*params.Bucket]; ok {
+		return nil, &s3types.BucketAlreadyOwnedByYou{}
+	}
+	region := c.region
+	if params.CreateBucketConfiguration != nil && params.CreateBucketConfiguration.LocationConstraint != "" {
+		region = string(params.CreateBucketConfiguration.LocationConstraint)
+	}
+	c.buckets[*params.Bucket] = &s3Bucket{
+		region:       region,
+		creationDate: c.now(),
+		objects:      make(map[string]*s3Object),
+	}
+	location := "/" + *params.Bucket
+	return &s3.CreateBucketOutput{Location: &location}, nil
+}
+
+func (c *S3Client) DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	if err := c.call("DeleteBucket"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.objects) > 0 {
+		return nil, &smithy.GenericAPIError{Code: "BucketNotEmpty", Message: "The bucket you tried to delete is not empty"}
+	}
+	delete(c.buckets, *params.Bucket)
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+func (c *S3Client) ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	if err := c.call("ListBuckets"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	names := make([]string, 0, len(c.buckets))
+	for name := range c.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	output := &s3.ListBucketsOutput{}
+	for i := range names {
+		creationDate := c.buckets[names[i]].creationDate
+		output.Buckets = append(output.Buckets, s3types.Bucket{Name: &names[i], CreationDate: &creationDate})
+	}
+	return output, nil
+}
+
+func (c *S3Client) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	if err := c.call("GetBucketTagging"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.tags) == 0 {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchTagSet", Message: "The TagSet does not exist"}
+	}
+	output := &s3.GetBucketTaggingOutput{}
+	for k, v := range b.tags {
+		key, value := k, v
+		output.TagSet = append(output.TagSet, s3types.Tag{Key: &key, Value: &value})
+	}
+	sort.Slice(output.TagSet, func(i, j int) bool {
+		return *output.TagSet[i].Key < *output.TagSet[j].Key
+	})
+	return output, nil
+}
+
+func (c *S3Client) PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	if err := c.call("PutBucketLifecycleConfiguration"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	b.lifecycle = params.LifecycleConfiguration
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+// GetBucketLifecycle returns the lifecycle configuration of the bucket, or nil.
+func (c *S3Client) GetBucketLifecycle(bucket string) *s3types.BucketLifecycleConfiguration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if b, ok := c.buckets[bucket]; ok {
+		return b.lifecycle
+	}
+	return nil
+}
+
+func (c *S3Client) PutBucketNotificationConfiguration(ctx context.Context, params *s3.PutBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketNotificationConfigurationOutput, error) {
+	if err := c.call("PutBucketNotificationConfiguration"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	b.notification = params.NotificationConfiguration
+	return &s3.PutBucketNotificationConfigurationOutput{}, nil
+}
+
+func (c *S3Client) GetBucketNotificationConfiguration(ctx context.Context, params *s3.GetBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketNotificationConfigurationOutput, error) {
+	if err := c.call("GetBucketNotificationConfiguration"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.GetBucketNotificationConfigurationOutput{}
+	if b.notification != nil {
+		output.QueueConfigurations = b.notification.QueueConfigurations
+		output.TopicConfigurations = b.notification.TopicConfigurations
+		output.LambdaFunctionConfigurations = b.notification.LambdaFunctionConfigurations
+	}
+	return output, nil
+}
+
+func (c *S3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if err := c.call("GetObject"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := b.objects[*params.Key]
+	if !ok {
+		return nil, &s3types.NoSuchKey{Message: params.Key}
+	}
+	lastModified := object.lastModified
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(object.data)),
+		ContentLength: int64(len(object.data)),
+		LastModified:  &lastModified,
+	}, nil
+}
+
+func (c *S3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := c.call("PutObject"); err != nil {
+		return nil, err
+	}
+	var data []byte
+	if params.Body != nil {
+		var err error
+		if data, err = io.ReadAll(params.Body); err != nil {
+			return nil, err
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	b.objects[*params.Key] = &s3Object{data: data, lastModified: c.now()}
+	return &s3.PutObjectOutput{}, nil
+}
+
+// ListObjectsV2 returns objects in lexicographical order of their keys. The
+// continuation token is the last key of the previous page.
+func (c *S3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if err := c.call("ListObjectsV2"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if params.Prefix != nil {
+		prefix = *params.Prefix
+	}
+	maxKeys := int(params.MaxKeys)
+	if maxKeys <= 0 || maxKeys > maxListObjectsKeys {
+		maxKeys = maxListObjectsKeys
+	}
+	keys := b.sortedKeys(prefix)
+	if params.ContinuationToken != nil {
+		start := sort.SearchStrings(keys, *params.ContinuationToken)
+		if start < len(keys) && keys[start] == *params.ContinuationToken {
+			start++
+		}
+		keys = keys[start:]
+	}
+	output := &s3.ListObjectsV2Output{
+		Name:              params.Bucket,
+		Prefix:            params.Prefix,
+		MaxKeys:           int32(maxKeys),
+		ContinuationToken: params.ContinuationToken,
+	}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		output.IsTruncated = true
+		nextToken := keys[len(keys)-1]
+		output.NextContinuationToken = &nextToken
+	}
+	for i := range keys {
+		object := b.objects[keys[i]]
+		lastModified := object.lastModified
+		output.Contents = append(output.Contents, s3types.Object{
+			Key:          &keys[i],
+			Size:         int64(len(object.data)),
+			LastModified: &lastModified,
+		})
+	}
+	output.KeyCount = int32(len(output.Contents))
+	return output, nil
+}
+
+func (c *S3Client) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if err := c.call("DeleteObjects"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, err := c.getBucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.DeleteObjectsOutput{}
+	for _, object := range params.Delete.Objects {
+		key := *object.Key
+		if c.undeleteable[key] {
+			code, message := "AccessDenied", "Access Denied"
+			output.Errors = append(output.Errors, s3types.Error{Key: &key, Code: &code, Message: &message})
+			continue
+		}
+		// deleting a missing object is not an error
+		delete(b.objects, key)
+		if !params.Delete.Quiet {
+			output.Deleted = append(output.Deleted, s3types.DeletedObject{Key: &key})
+		}
+	}
+	return output, nil
+}
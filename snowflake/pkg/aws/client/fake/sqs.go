@@ -0,0 +1,249 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	sqsclient "antrea.io/theia/snowflake/pkg/aws/client/sqs"
+)
+
+const (
+	// default and maximum number of queues returned by ListQueues
+	maxListQueuesResults = 1000
+	// default visibility timeout of SQS queues
+	defaultVisibilityTimeout = 30 * time.Second
+)
+
+type sqsMessage struct {
+	id             string
+	body           string
+	receiptHandle  string
+	invisibleUntil time.Time
+}
+
+type sqsQueue struct {
+	url      string
+	tags     map[string]string
+	messages []*sqsMessage
+}
+
+// SQSClient is an in-memory implementation of the SQS client interface,
+// which is safe for concurrent use. Errors can be injected for any method,
+// with InjectError.
+type SQSClient struct {
+	errorInjector
+	mutex     sync.Mutex
+	region    string
+	accountID string
+	queues    map[string]*sqsQueue
+	nextID    int
+	now       func() time.Time
+}
+
+var _ sqsclient.Interface = &SQSClient{}
+
+func NewSQSClient(region string, accountID string) *SQSClient {
+	return &SQSClient{
+		region:    region,
+		accountID: accountID,
+		queues:    make(map[string]*sqsQueue),
+		now:       time.Now,
+	}
+}
+
+// CreateQueue creates a queue and returns its URL.
+func (c *SQSClient) CreateQueue(name string, tags map[string]string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	url := fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", c.region, c.accountID, name)
+	c.queues[name] = &sqsQueue{url: url, tags: tags}
+	return url
+}
+
+// SendMessage adds a message to an existing queue.
+func (c *SQSClient) SendMessage(name string, body string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	q, ok := c.queues[name]
+	if !ok {
+		return
+	}
+	c.nextID++
+	q.messages = append(q.messages, &sqsMessage{id: strconv.Itoa(c.nextID), body: body})
+}
+
+// MessageCount returns the number of messages in the queue, including the
+// ones which are currently invisible.
+func (c *SQSClient) MessageCount(name string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if q, ok := c.queues[name]; ok {
+		return len(q.messages)
+	}
+	return 0
+}
+
+func nonExistentQueue() error {
+	return &sqstypes.QueueDoesNotExist{}
+}
+
+// getQueueByURL must be called with the mutex held.
+func (c *SQSClient) getQueueByURL(url *string) (*sqsQueue, error) {
+	for _, q := range c.queues {
+		if url != nil && q.url == *url {
+			return q, nil
+		}
+	}
+	return nil, nonExistentQueue()
+}
+
+func (c *SQSClient) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	if err := c.call("GetQueueUrl"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	q, ok := c.queues[*params.QueueName]
+	if !ok {
+		return nil, nonExistentQueue()
+	}
+	url := q.url
+	return &sqs.GetQueueUrlOutput{QueueUrl: &url}, nil
+}
+
+// ListQueues returns queue URLs in lexicographical order of the queue names.
+// The next token is the index of the first queue of the next page.
+func (c *SQSClient) ListQueues(ctx context.Context, params *sqs.ListQueuesInput, optFns ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error) {
+	if err := c.call("ListQueues"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var names []string
+	for name := range c.queues {
+		if params.QueueNamePrefix == nil || strings.HasPrefix(name, *params.QueueNamePrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	start := 0
+	if params.NextToken != nil {
+		var err error
+		if start, err = strconv.Atoi(*params.NextToken); err != nil || start > len(names) {
+			return nil, fmt.Errorf("invalid next token: %s", *params.NextToken)
+		}
+	}
+	maxResults := maxListQueuesResults
+	if params.MaxResults != nil && *params.MaxResults > 0 && *params.MaxResults < maxListQueuesResults {
+		maxResults = int(*params.MaxResults)
+	}
+	end := start + maxResults
+	output := &sqs.ListQueuesOutput{}
+	if end < len(names) {
+		nextToken := strconv.Itoa(end)
+		output.NextToken = &nextToken
+	} else {
+		end = len(names)
+	}
+	for _, name := range names[start:end] {
+		output.QueueUrls = append(output.QueueUrls, c.queues[name].url)
+	}
+	return output, nil
+}
+
+func (c *SQSClient) ListQueueTags(ctx context.Context, params *sqs.ListQueueTagsInput, optFns ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	if err := c.call("ListQueueTags"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	q, err := c.getQueueByURL(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(q.tags))
+	for k, v := range q.tags {
+		tags[k] = v
+	}
+	return &sqs.ListQueueTagsOutput{Tags: tags}, nil
+}
+
+// ReceiveMessage returns visible messages, in the order in which they were
+// sent, and makes them invisible for the visibility timeout. It never waits for
+// messages.
+func (c *SQSClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if err := c.call("ReceiveMessage"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	q, err := c.getQueueByURL(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	maxMessages := int(params.MaxNumberOfMessages)
+	if maxMessages <= 0 {
+		maxMessages = 1
+	}
+	visibilityTimeout := defaultVisibilityTimeout
+	if params.VisibilityTimeout > 0 {
+		visibilityTimeout = time.Duration(params.VisibilityTimeout) * time.Second
+	}
+	now := c.now()
+	output := &sqs.ReceiveMessageOutput{}
+	for _, m := range q.messages {
+		if len(output.Messages) >= maxMessages {
+			break
+		}
+		if now.Before(m.invisibleUntil) {
+			continue
+		}
+		c.nextID++
+		m.receiptHandle = fmt.Sprintf("%s-%d", m.id, c.nextID)
+		m.invisibleUntil = now.Add(visibilityTimeout)
+		id, body, receiptHandle := m.id, m.body, m.receiptHandle
+		output.Messages = append(output.Messages, sqstypes.Message{MessageId: &id, Body: &body, ReceiptHandle: &receiptHandle})
+	}
+	return output, nil
+}
+
+func (c *SQSClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	if err := c.call("DeleteMessage"); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	q, err := c.getQueueByURL(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range q.messages {
+		if params.ReceiptHandle != nil && m.receiptHandle == *params.ReceiptHandle {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return &sqs.DeleteMessageOutput{}, nil
+		}
+	}
+	return nil, &sqstypes.ReceiptHandleIsInvalid{}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// minimum number of days before objects can be transitioned to STANDARD_IA
+const minTransitionToIADays = 30
+
+// LifecycleConfig describes the lifecycle of the objects in a bucket. A value
+// of 0 disables the corresponding action.
+type LifecycleConfig struct {
+	TransitionToIADays                 int32
+	TransitionToGlacierDays            int32
+	ExpirationDays                     int32
+	AbortIncompleteMultipartUploadDays int32
+}
+
+func (c *LifecycleConfig) IsEmpty() bool {
+	return *c == LifecycleConfig{}
+}
+
+func (c *LifecycleConfig) Validate() error {
+	if c.TransitionToIADays < 0 || c.TransitionToGlacierDays < 0 || c.ExpirationDays < 0 || c.AbortIncompleteMultipartUploadDays < 0 {
+		return fmt.Errorf("lifecycle days cannot be negative")
+	}
+	if c.TransitionToIADays > 0 && c.TransitionToIADays < minTransitionToIADays {
+		return fmt.Errorf("objects cannot be transitioned to STANDARD_IA before %d days", minTransitionToIADays)
+	}
+	if c.TransitionToIADays > 0 && c.TransitionToGlacierDays > 0 && c.TransitionToGlacierDays <= c.TransitionToIADays {
+		return fmt.Errorf("objects must be transitioned to GLACIER after being transitioned to STANDARD_IA")
+	}
+	lastTransitionDays := c.TransitionToIADays
+	if c.TransitionToGlacierDays > lastTransitionDays {
+		lastTransitionDays = c.TransitionToGlacierDays
+	}
+	if c.ExpirationDays > 0 && c.ExpirationDays <= lastTransitionDays {
+		return fmt.Errorf("objects must expire after their last transition")
+	}
+	return nil
+}
+
+// Rule returns the lifecycle rule for the objects with the given prefix.
+func (c *LifecycleConfig) Rule(id string, prefix string) s3types.LifecycleRule {
+	rule := s3types.LifecycleRule{
+		ID:     &id,
+		Status: s3types.ExpirationStatusEnabled,
+		Filter: &s3types.LifecycleRuleFilterMemberPrefix{Value: prefix},
+	}
+	if c.TransitionToIADays > 0 {
+		rule.Transitions = append(rule.Transitions, s3types.Transition{
+			Days:         c.TransitionToIADays,
+			StorageClass: s3types.TransitionStorageClassStandardIa,
+		})
+	}
+	if c.TransitionToGlacierDays > 0 {
+		rule.Transitions = append(rule.Transitions, s3types.Transition{
+			Days:         c.TransitionToGlacierDays,
+			StorageClass: s3types.TransitionStorageClassGlacier,
+		})
+	}
+	if c.ExpirationDays > 0 {
+		rule.Expiration = &s3types.LifecycleExpiration{
+			Days: c.ExpirationDays,
+		}
+	}
+	if c.AbortIncompleteMultipartUploadDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &s3types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: c.AbortIncompleteMultipartUploadDays,
+		}
+	}
+	return rule
+}
+
+// PutBucketLifecycle replaces the lifecycle configuration of the bucket with a
+// single rule applying to all objects.
+func PutBucketLifecycle(ctx context.Context, client Interface, bucket string, config *LifecycleConfig) error {
+	_, err := client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: &bucket,
+		LifecycleConfiguration: &s3types.BucketLifecycleConfiguration{
+			Rules: []s3types.LifecycleRule{config.Rule("theia", "")},
+		},
+	})
+	return err
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"testing"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycleConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		config      LifecycleConfig
+		expectedErr string
+	}{
+		{
+			name:   "empty",
+			config: LifecycleConfig{},
+		},
+		{
+			name:   "expiration only",
+			config: LifecycleConfig{ExpirationDays: 1},
+		},
+		{
+			name:   "all actions",
+			config: LifecycleConfig{TransitionToIADays: 30, TransitionToGlacierDays: 90, ExpirationDays: 365, AbortIncompleteMultipartUploadDays: 7},
+		},
+		{
+			name:   "glacier transition without STANDARD_IA transition",
+			config: LifecycleConfig{TransitionToGlacierDays: 1, ExpirationDays: 2},
+		},
+		{
+			name:        "negative expiration",
+			config:      LifecycleConfig{ExpirationDays: -1},
+			expectedErr: "lifecycle days cannot be negative",
+		},
+		{
+			name:        "negative multipart upload abort",
+			config:      LifecycleConfig{AbortIncompleteMultipartUploadDays: -1},
+			expectedErr: "lifecycle days cannot be negative",
+		},
+		{
+			name:        "STANDARD_IA transition too early",
+			config:      LifecycleConfig{TransitionToIADays: 29},
+			expectedErr: "objects cannot be transitioned to STANDARD_IA before 30 days",
+		},
+		{
+			name:        "glacier transition before STANDARD_IA transition",
+			config:      LifecycleConfig{TransitionToIADays: 60, TransitionToGlacierDays: 45},
+			expectedErr: "objects must be transitioned to GLACIER after being transitioned to STANDARD_IA",
+		},
+		{
+			name:        "glacier transition with STANDARD_IA transition",
+			config:      LifecycleConfig{TransitionToIADays: 30, TransitionToGlacierDays: 30},
+			expectedErr: "objects must be transitioned to GLACIER after being transitioned to STANDARD_IA",
+		},
+		{
+			name:        "expiration with STANDARD_IA transition",
+			config:      LifecycleConfig{TransitionToIADays: 30, ExpirationDays: 30},
+			expectedErr: "objects must expire after their last transition",
+		},
+		{
+			name:        "expiration before glacier transition",
+			config:      LifecycleConfig{TransitionToIADays: 30, TransitionToGlacierDays: 90, ExpirationDays: 60},
+			expectedErr: "objects must expire after their last transition",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLifecycleConfigRule(t *testing.T) {
+	id := "theia"
+	for _, tc := range []struct {
+		name         string
+		config       LifecycleConfig
+		expectedRule s3types.LifecycleRule
+	}{
+		{
+			name:   "empty",
+			config: LifecycleConfig{},
+			expectedRule: s3types.LifecycleRule{
+				ID:     &id,
+				Status: s3types.ExpirationStatusEnabled,
+				Filter: &s3types.LifecycleRuleFilterMemberPrefix{Value: "flows/"},
+			},
+		},
+		{
+			name:   "expiration only",
+			config: LifecycleConfig{ExpirationDays: 7},
+			expectedRule: s3types.LifecycleRule{
+				ID:         &id,
+				Status:     s3types.ExpirationStatusEnabled,
+				Filter:     &s3types.LifecycleRuleFilterMemberPrefix{Value: "flows/"},
+				Expiration: &s3types.LifecycleExpiration{Days: 7},
+			},
+		},
+		{
+			name:   "all actions",
+			config: LifecycleConfig{TransitionToIADays: 30, TransitionToGlacierDays: 90, ExpirationDays: 365, AbortIncompleteMultipartUploadDays: 1},
+			expectedRule: s3types.LifecycleRule{
+				ID:     &id,
+				Status: s3types.ExpirationStatusEnabled,
+				Filter: &s3types.LifecycleRuleFilterMemberPrefix{Value: "flows/"},
+				Transitions: []s3types.Transition{
+					{Days: 30, StorageClass: s3types.TransitionStorageClassStandardIa},
+					{Days: 90, StorageClass: s3types.TransitionStorageClassGlacier},
+				},
+				Expiration:                     &s3types.LifecycleExpiration{Days: 365},
+				AbortIncompleteMultipartUpload: &s3types.AbortIncompleteMultipartUpload{DaysAfterInitiation: 1},
+			},
+		},
+		{
+			name:   "glacier transition only",
+			config: LifecycleConfig{TransitionToGlacierDays: 1},
+			expectedRule: s3types.LifecycleRule{
+				ID:     &id,
+				Status: s3types.ExpirationStatusEnabled,
+				Filter: &s3types.LifecycleRuleFilterMemberPrefix{Value: "flows/"},
+				Transitions: []s3types.Transition{
+					{Days: 1, StorageClass: s3types.TransitionStorageClassGlacier},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedRule, tc.config.Rule(id, "flows/"))
+		})
+	}
+}
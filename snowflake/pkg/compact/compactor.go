@@ -0,0 +1,267 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compact
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
+)
+
+// maximum number of keys in a DeleteObjects request
+const maxDeleteObjects = 1000
+
+type Config struct {
+	Bucket string
+	// SourcePrefix is the prefix to which the Flow Aggregator uploads flow
+	// records. It must not be monitored by Snowpipe.
+	SourcePrefix string
+	// DestinationPrefix is the prefix monitored by Snowpipe.
+	DestinationPrefix string
+	// TargetSize is the size (in bytes) of the source objects which are
+	// merged into a single compacted object.
+	TargetSize int64
+	// MaxDelay is the maximum time that a source object can wait for other
+	// objects before being compacted, even if the target size has not been
+	// reached.
+	MaxDelay time.Duration
+}
+
+// Compactor merges small flow record files into larger gzip-compressed CSV
+// files. Flow records are copied before the source objects are deleted, which
+// means that some records may be ingested twice if the compactor is
+// interrupted.
+type Compactor struct {
+	s3Client s3client.Interface
+	config   Config
+	logger   logr.Logger
+	now      func() time.Time
+}
+
+func NewCompactor(s3Client s3client.Interface, config Config, logger logr.Logger) *Compactor {
+	config.SourcePrefix = strings.Trim(config.SourcePrefix, "/")
+	config.DestinationPrefix = strings.Trim(config.DestinationPrefix, "/")
+	return &Compactor{
+		s3Client: s3Client,
+		config:   config,
+		logger:   logger.WithValues("bucket", config.Bucket),
+		now:      time.Now,
+	}
+}
+
+// Run compacts flow records every interval, until the context is cancelled.
+func (c *Compactor) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := c.RunOnce(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			c.logger.Error(err, "Error when compacting flow records")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce compacts the source objects which are ready and returns the number
+// of compacted objects which were created.
+func (c *Compactor) RunOnce(ctx context.Context) (int, error) {
+	objects, err := c.listSourceObjects(ctx)
+	if err != nil {
+		return 0, err
+	}
+	batches := c.getBatches(objects)
+	for i, batch := range batches {
+		// do not start a new batch if the context has been cancelled
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := c.compactBatch(ctx, batch); err != nil {
+			return i, err
+		}
+	}
+	return len(batches), nil
+}
+
+func (c *Compactor) listSourceObjects(ctx context.Context) ([]s3types.Object, error) {
+	prefix := c.config.SourcePrefix + "/"
+	var objects []s3types.Object
+	var continuationToken *string
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		output, err := c.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &c.config.Bucket,
+			Prefix:            &prefix,
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error when listing objects with prefix '%s': %w", prefix, err)
+		}
+		for _, object := range output.Contents {
+			// ignore "folders" and empty files
+			if object.Size == 0 {
+				continue
+			}
+			objects = append(objects, object)
+		}
+		if !output.IsTruncated {
+			break
+		}
+		continuationToken = output.NextContinuationToken
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].LastModified.Before(*objects[j].LastModified)
+	})
+	return objects, nil
+}
+
+// getBatches groups objects (sorted from oldest to newest) into batches of at
+// least TargetSize bytes. The last batch is only included if its oldest object
+// has been waiting for more than MaxDelay.
+func (c *Compactor) getBatches(objects []s3types.Object) [][]s3types.Object {
+	var batches [][]s3types.Object
+	var batch []s3types.Object
+	var batchSize int64
+	for _, object := range objects {
+		batch = append(batch, object)
+		batchSize += object.Size
+		if batchSize >= c.config.TargetSize {
+			batches = append(batches, batch)
+			batch = nil
+			batchSize = 0
+		}
+	}
+	if len(batch) > 0 && c.now().Sub(*batch[0].LastModified) >= c.config.MaxDelay {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func (c *Compactor) compactBatch(ctx context.Context, batch []s3types.Object) error {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	for _, object := range batch {
+		if err := c.copyObject(ctx, gzw, *object.Key); err != nil {
+			return err
+		}
+	}
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+	key, err := c.compactedObjectKey()
+	if err != nil {
+		return err
+	}
+	if _, err := c.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &c.config.Bucket,
+		Key:    &key,
+		Body:   bytes.NewReader(buf.Bytes()),
+	}); err != nil {
+		return fmt.Errorf("error when uploading compacted object '%s': %w", key, err)
+	}
+	c.logger.Info("Compacted flow records", "key", key, "sourceObjects", len(batch), "size", buf.Len())
+	return c.deleteObjects(ctx, batch)
+}
+
+// copyObject appends the flow records of an object to w, decompressing them if
+// needed.
+func (c *Compactor) copyObject(ctx context.Context, w io.Writer, key string) error {
+	output, err := c.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &c.config.Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return fmt.Errorf("error when getting object '%s': %w", key, err)
+	}
+	defer output.Body.Close()
+	var r io.Reader = output.Body
+	if strings.HasSuffix(key, ".gz") {
+		gzr, err := gzip.NewReader(output.Body)
+		if err != nil {
+			return fmt.Errorf("error when decompressing object '%s': %w", key, err)
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error when reading object '%s': %w", key, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (c *Compactor) compactedObjectKey() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("compacted-%s-%s.csv.gz", c.now().UTC().Format("20060102T150405Z"), hex.EncodeToString(suffix))
+	return path.Join(c.config.DestinationPrefix, name), nil
+}
+
+func (c *Compactor) deleteObjects(ctx context.Context, objects []s3types.Object) error {
+	for start := 0; start < len(objects); start += maxDeleteObjects {
+		end := start + maxDeleteObjects
+		if end > len(objects) {
+			end = len(objects)
+		}
+		var identifiers []s3types.ObjectIdentifier
+		for _, object := range objects[start:end] {
+			identifiers = append(identifiers, s3types.ObjectIdentifier{Key: object.Key})
+		}
+		output, err := c.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &c.config.Bucket,
+			Delete: &s3types.Delete{
+				Objects: identifiers,
+				Quiet:   true,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error when deleting compacted objects: %w", err)
+		}
+		if len(output.Errors) > 0 {
+			return fmt.Errorf("error when deleting compacted object '%s': %s", *output.Errors[0].Key, *output.Errors[0].Message)
+		}
+	}
+	return nil
+}
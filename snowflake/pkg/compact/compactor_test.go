@@ -0,0 +1,259 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compact
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/snowflake/pkg/aws/client/fake"
+)
+
+const testBucket = "flows"
+
+func newTestObject(key string, size int64, lastModified time.Time) s3types.Object {
+	return s3types.Object{
+		Key:          aws.String(key),
+		Size:         size,
+		LastModified: aws.Time(lastModified),
+	}
+}
+
+func objectKeys(objects []s3types.Object) []string {
+	keys := make([]string, len(objects))
+	for i := range objects {
+		keys[i] = *objects[i].Key
+	}
+	return keys
+}
+
+func TestGetBatches(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name            string
+		objects         []s3types.Object
+		expectedBatches [][]string
+	}{
+		{
+			name:            "no objects",
+			objects:         nil,
+			expectedBatches: nil,
+		},
+		{
+			name: "target size reached",
+			objects: []s3types.Object{
+				newTestObject("a", 40, now.Add(-time.Minute)),
+				newTestObject("b", 40, now.Add(-time.Minute)),
+				newTestObject("c", 40, now.Add(-time.Minute)),
+				newTestObject("d", 100, now.Add(-time.Minute)),
+			},
+			expectedBatches: [][]string{{"a", "b", "c"}, {"d"}},
+		},
+		{
+			name: "last batch before max delay",
+			objects: []s3types.Object{
+				newTestObject("a", 60, now.Add(-2*time.Minute)),
+				newTestObject("b", 60, now.Add(-2*time.Minute)),
+				newTestObject("c", 60, now.Add(-4*time.Minute)),
+			},
+			expectedBatches: [][]string{{"a", "b"}},
+		},
+		{
+			name: "last batch after max delay",
+			objects: []s3types.Object{
+				newTestObject("a", 10, now.Add(-5*time.Minute)),
+				newTestObject("b", 10, now.Add(-time.Minute)),
+			},
+			expectedBatches: [][]string{{"a", "b"}},
+		},
+		{
+			name: "single object before max delay",
+			objects: []s3types.Object{
+				newTestObject("a", 10, now.Add(-5*time.Minute+time.Second)),
+			},
+			expectedBatches: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCompactor(fake.NewS3Client("us-west-2"), Config{
+				Bucket:     testBucket,
+				TargetSize: 100,
+				MaxDelay:   5 * time.Minute,
+			}, logr.Discard())
+			c.now = func() time.Time { return now }
+			var batches [][]string
+			for _, batch := range c.getBatches(tc.objects) {
+				batches = append(batches, objectKeys(batch))
+			}
+			assert.Equal(t, tc.expectedBatches, batches)
+		})
+	}
+}
+
+func gzipData(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	_, err := gzw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+type testSourceObject struct {
+	key  string
+	data []byte
+}
+
+// newTestS3Client returns a client with a bucket containing the objects, which
+// are uploaded in order.
+func newTestS3Client(t *testing.T, objects []testSourceObject) *fake.S3Client {
+	ctx := context.Background()
+	s3Client := fake.NewS3Client("us-west-2")
+	_, err := s3Client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(testBucket)})
+	require.NoError(t, err)
+	for _, object := range objects {
+		_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(testBucket),
+			Key:    aws.String(object.key),
+			Body:   bytes.NewReader(object.data),
+		})
+		require.NoError(t, err)
+	}
+	return s3Client
+}
+
+func getCompactedData(t *testing.T, s3Client *fake.S3Client, key string) string {
+	output, err := s3Client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer output.Body.Close()
+	gzr, err := gzip.NewReader(output.Body)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRunOnce(t *testing.T) {
+	s3Client := newTestS3Client(t, []testSourceObject{
+		{key: "source/c.csv", data: []byte("1,c\n")},
+		{key: "source/b.csv.gz", data: gzipData(t, "1,b\n2,b\n")},
+		// missing trailing newline
+		{key: "source/a.csv", data: []byte("1,a\n2,a")},
+		// ignored empty object
+		{key: "source/d.csv", data: []byte{}},
+		// not a source object
+		{key: "other/e.csv", data: []byte("1,e\n")},
+	})
+	c := NewCompactor(s3Client, Config{
+		Bucket:            testBucket,
+		SourcePrefix:      "/source/",
+		DestinationPrefix: "compacted",
+		TargetSize:        1 << 20,
+		MaxDelay:          time.Minute,
+	}, logr.Discard())
+	c.now = func() time.Time { return time.Now().Add(time.Minute) }
+
+	count, err := c.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	keys := s3Client.ObjectKeys(testBucket)
+	require.Len(t, keys, 3)
+	assert.True(t, strings.HasPrefix(keys[0], "compacted/compacted-"))
+	assert.True(t, strings.HasSuffix(keys[0], ".csv.gz"))
+	assert.Equal(t, []string{"other/e.csv", "source/d.csv"}, keys[1:])
+	// objects are compacted from oldest to newest
+	assert.Equal(t, "1,c\n1,b\n2,b\n1,a\n2,a\n", getCompactedData(t, s3Client, keys[0]))
+}
+
+func TestRunOnceBeforeMaxDelay(t *testing.T) {
+	s3Client := newTestS3Client(t, []testSourceObject{
+		{key: "source/a.csv", data: []byte("1,a\n")},
+	})
+	c := NewCompactor(s3Client, Config{
+		Bucket:            testBucket,
+		SourcePrefix:      "source",
+		DestinationPrefix: "compacted",
+		TargetSize:        1 << 20,
+		MaxDelay:          time.Hour,
+	}, logr.Discard())
+
+	count, err := c.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, []string{"source/a.csv"}, s3Client.ObjectKeys(testBucket))
+}
+
+func TestRunOncePartialDeletionFailure(t *testing.T) {
+	s3Client := newTestS3Client(t, []testSourceObject{
+		{key: "source/a.csv", data: []byte("1,a\n")},
+		{key: "source/b.csv", data: []byte("1,b\n")},
+		{key: "source/c.csv", data: []byte("1,c\n")},
+	})
+	s3Client.FailDeletion("source/b.csv")
+	c := NewCompactor(s3Client, Config{
+		Bucket:            testBucket,
+		SourcePrefix:      "source",
+		DestinationPrefix: "compacted",
+		TargetSize:        1,
+		MaxDelay:          time.Hour,
+	}, logr.Discard())
+
+	count, err := c.RunOnce(context.Background())
+	// the second batch is uploaded but its source object cannot be deleted,
+	// and the third batch is not compacted
+	assert.ErrorContains(t, err, "error when deleting compacted object 'source/b.csv'")
+	assert.Equal(t, 1, count)
+	keys := s3Client.ObjectKeys(testBucket)
+	require.Len(t, keys, 4)
+	assert.Equal(t, []string{"source/b.csv", "source/c.csv"}, keys[2:])
+	compactedData := []string{getCompactedData(t, s3Client, keys[0]), getCompactedData(t, s3Client, keys[1])}
+	assert.ElementsMatch(t, []string{"1,a\n", "1,b\n"}, compactedData)
+}
+
+func TestRunOnceUploadFailure(t *testing.T) {
+	s3Client := newTestS3Client(t, []testSourceObject{
+		{key: "source/a.csv", data: []byte("1,a\n")},
+	})
+	s3Client.InjectError("PutObject", fake.ErrS3Throttling, 1)
+	c := NewCompactor(s3Client, Config{
+		Bucket:            testBucket,
+		SourcePrefix:      "source",
+		DestinationPrefix: "compacted",
+		TargetSize:        1,
+		MaxDelay:          time.Hour,
+	}, logr.Discard())
+
+	_, err := c.RunOnce(context.Background())
+	assert.ErrorIs(t, err, fake.ErrS3Throttling)
+	// source objects are not deleted if the compacted object is not uploaded
+	assert.Equal(t, []string{"source/a.csv"}, s3Client.ObjectKeys(testBucket))
+	assert.Equal(t, 0, s3Client.Calls("DeleteObjects"))
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataquality
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Check is a data quality check of the ingested flows.
+type Check struct {
+	Name        string
+	Description string
+	Query       string
+}
+
+// Result is the number of flows violating a check.
+type Result struct {
+	Check      Check
+	Violations int64
+}
+
+// LoadChecks reads the checks from the .sql files in dir. The name of a check
+// is the name of its file, and its description is the leading comment.
+func LoadChecks(fsys fs.FS, dir string) ([]Check, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var checks []Check
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		query := strings.TrimSpace(string(data))
+		var description string
+		if strings.HasPrefix(query, "--") {
+			firstLine, rest, _ := strings.Cut(query, "\n")
+			description = strings.TrimSpace(strings.TrimPrefix(firstLine, "--"))
+			query = strings.TrimSpace(rest)
+		}
+		checks = append(checks, Check{
+			Name:        strings.ReplaceAll(strings.TrimSuffix(e.Name(), ".sql"), "_", "-"),
+			Description: description,
+			Query:       query,
+		})
+	}
+	return checks, nil
+}
+
+// Run runs the checks against the flows inserted since the given time into the
+// flows table of the schema.
+func Run(ctx context.Context, db *sql.DB, logger logr.Logger, checks []Check, databaseName string, schemaName string, since time.Time) ([]Result, error) {
+	var results []Result
+	for _, check := range checks {
+		query := fmt.Sprintf("WITH recent_flows AS (SELECT * FROM %s.%s.FLOWS WHERE timeInserted >= ?)\n%s", databaseName, schemaName, check.Query)
+		logger.V(2).Info("Snowflake query", "query", query)
+		result := Result{Check: check}
+		if err := db.QueryRowContext(ctx, query, since).Scan(&result.Violations); err != nil {
+			return nil, fmt.Errorf("error when running data quality check %s: %w", check.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
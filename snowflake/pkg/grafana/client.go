@@ -0,0 +1,153 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+type DataSource struct {
+	ID             int                    `json:"id,omitempty"`
+	UID            string                 `json:"uid"`
+	Name           string                 `json:"name"`
+	Type           string                 `json:"type"`
+	Access         string                 `json:"access"`
+	JSONData       map[string]interface{} `json:"jsonData,omitempty"`
+	SecureJSONData map[string]string      `json:"secureJsonData,omitempty"`
+}
+
+type Client interface {
+	UpsertDataSource(ctx context.Context, dataSource *DataSource) error
+	ImportDashboard(ctx context.Context, dashboard json.RawMessage) error
+}
+
+type client struct {
+	baseURL    string
+	apiKey     string
+	username   string
+	password   string
+	httpClient *http.Client
+	logger     logr.Logger
+}
+
+// NewClient returns a client for the Grafana HTTP API. If apiKey is not empty,
+// it is used to authenticate requests, otherwise basic authentication is used.
+func NewClient(baseURL string, apiKey string, username string, password string, logger logr.Logger) Client {
+	return &client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		username:   username,
+		password:   password,
+		httpClient: http.DefaultClient,
+		logger:     logger,
+	}
+}
+
+type statusError struct {
+	statusCode int
+	message    string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("Grafana API returned status code %d: %s", e.statusCode, e.message)
+}
+
+func (c *client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	} else {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.logger.V(4).Info("Sending request to Grafana", "method", method, "path", path)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error when sending request to Grafana: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error when reading response from Grafana: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{statusCode: resp.StatusCode, message: string(data)}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// UpsertDataSource creates the data source, or updates it if a data source
+// with the same UID already exists.
+func (c *client) UpsertDataSource(ctx context.Context, dataSource *DataSource) error {
+	var existing DataSource
+	err := c.do(ctx, http.MethodGet, "/api/datasources/uid/"+dataSource.UID, nil, &existing)
+	if err != nil {
+		var sErr *statusError
+		if !errors.As(err, &sErr) || sErr.statusCode != http.StatusNotFound {
+			return fmt.Errorf("error when getting data source %s: %w", dataSource.UID, err)
+		}
+		c.logger.Info("Creating Grafana data source", "name", dataSource.Name)
+		if err := c.do(ctx, http.MethodPost, "/api/datasources", dataSource, nil); err != nil {
+			return fmt.Errorf("error when creating data source %s: %w", dataSource.Name, err)
+		}
+		return nil
+	}
+	c.logger.Info("Updating Grafana data source", "name", dataSource.Name)
+	dataSource.ID = existing.ID
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/datasources/%d", existing.ID), dataSource, nil); err != nil {
+		return fmt.Errorf("error when updating data source %s: %w", dataSource.Name, err)
+	}
+	return nil
+}
+
+// ImportDashboard creates the dashboard, overwriting any existing dashboard
+// with the same UID.
+func (c *client) ImportDashboard(ctx context.Context, dashboard json.RawMessage) error {
+	body := map[string]interface{}{
+		"dashboard": dashboard,
+		"overwrite": true,
+		"message":   "Provisioned by theia-sf",
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/dashboards/db", body, nil); err != nil {
+		return fmt.Errorf("error when importing dashboard: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafana
+
+import (
+	"embed"
+)
+
+const (
+	// DataSourceUID is the UID of the Snowflake data source referenced by
+	// all the dashboards.
+	DataSourceUID  = "theia-snowflake"
+	DataSourceType = "michelin-snowflake-datasource"
+)
+
+// Dashboards are the equivalent of the Theia dashboards, querying the
+// Snowflake flows table and views instead of ClickHouse.
+//
+//go:embed dashboards/*.json
+var Dashboards embed.FS
+
+const DashboardsPath = "dashboards"
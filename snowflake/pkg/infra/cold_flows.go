@@ -0,0 +1,165 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/s3"
+	"github.com/pulumi/pulumi-snowflake/sdk/go/snowflake"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"antrea.io/theia/snowflake/database"
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
+)
+
+// ValidateColdFlowsLifecycle checks that the flow records stay readable in the
+// bucket after they are deleted from the flows table, which is required for
+// the external table to be useful: they must not expire before then, and
+// Snowflake cannot read objects in the GLACIER storage class.
+func ValidateColdFlowsLifecycle(lifecycle *s3client.LifecycleConfig) error {
+	if lifecycle.ExpirationDays > 0 && lifecycle.ExpirationDays <= flowRetentionDays {
+		return fmt.Errorf("flow records must not expire from the bucket before %d days (or never expire) when using the external flows table", flowRetentionDays+1)
+	}
+	if lifecycle.TransitionToGlacierDays > 0 {
+		return fmt.Errorf("flow records cannot be transitioned to GLACIER when using the external flows table, as Snowflake cannot read them")
+	}
+	return nil
+}
+
+// coldFlowsColumns returns the columns of the external table, which map the
+// fields of the CSV flow records, in order, to the columns of the flows table.
+// The insertion time is not part of the flow records.
+func coldFlowsColumns() snowflake.ExternalTableColumnArray {
+	var columns snowflake.ExternalTableColumnArray
+	for i, column := range database.FlowsTableColumns {
+		if column.Name == flowsTimeInsertedColumn {
+			continue
+		}
+		columns = append(columns, snowflake.ExternalTableColumnArgs{
+			Name: pulumi.String(column.Name),
+			Type: pulumi.String(column.Type),
+			As:   pulumi.String(fmt.Sprintf("(VALUE:c%d::%s)", i+1, column.Type)),
+		})
+	}
+	return columns
+}
+
+// allFlowsViewStatement returns the query of the view over the hot (flows
+// table) and cold (external table) flows. Flows which ended during the
+// retention period are always read from the flows table, and the other ones
+// from the external table, so that no flow is returned twice.
+func allFlowsViewStatement(databaseName string) string {
+	var hotColumns, coldColumns []string
+	for _, column := range database.FlowsTableColumns {
+		hotColumns = append(hotColumns, column.Name)
+		if column.Name == flowsTimeInsertedColumn {
+			coldColumns = append(coldColumns, fmt.Sprintf("NULL::%s AS %s", column.Type, column.Name))
+		} else {
+			coldColumns = append(coldColumns, column.Name)
+		}
+	}
+	cutoff := fmt.Sprintf("DATEADD(day, -%d, CURRENT_TIMESTAMP)", flowRetentionDays)
+	return fmt.Sprintf(
+		"SELECT %s FROM %s.%s.%s WHERE flowEndSeconds >= %s UNION ALL SELECT %s FROM %s.%s.%s WHERE flowEndSeconds < %s",
+		strings.Join(hotColumns, ", "), databaseName, schemaName, flowsTableName, cutoff,
+		strings.Join(coldColumns, ", "), databaseName, schemaName, coldFlowsTableName, cutoff,
+	)
+}
+
+// declareSnowflakeColdFlows declares an external table over the flow records
+// in the bucket, so that flows deleted from the flows table after the
+// retention period can still be queried, as well as a view over both tables.
+func declareSnowflakeColdFlows(
+	db *snowflake.Database,
+	schema *snowflake.Schema,
+	bucket *s3.BucketV2,
+	storageIntegration *snowflake.StorageIntegration,
+	ingestionWarehouse *snowflake.Warehouse,
+	dependencies []pulumi.Resource,
+) func(ctx *pulumi.Context) error {
+	declareFunc := func(ctx *pulumi.Context) error {
+		fileFormat, err := snowflake.NewFileFormat(ctx, "antrea-sf-cold-flows-file-format", &snowflake.FileFormatArgs{
+			Database:   db.ID(),
+			Schema:     schema.Name,
+			Name:       pulumi.String(coldFlowsFileFormatName),
+			FormatType: pulumi.String("CSV"),
+			// compacted flow records are gzip-compressed
+			Compression: pulumi.String("AUTO"),
+		}, pulumi.Parent(schema), pulumi.DeleteBeforeReplace(true))
+		if err != nil {
+			return err
+		}
+
+		// the stage covers all the shards
+		stage, err := snowflake.NewStage(ctx, "antrea-sf-cold-flows-stage", &snowflake.StageArgs{
+			Database:           db.ID(),
+			Schema:             schema.Name,
+			Name:               pulumi.String(coldFlowsStageName),
+			Url:                pulumi.Sprintf("s3://%s/%s/", bucket.ID(), s3BucketFlowsFolder),
+			StorageIntegration: storageIntegration.ID(),
+		}, pulumi.Parent(schema), pulumi.DependsOn([]pulumi.Resource{storageIntegration}), pulumi.DeleteBeforeReplace(true))
+		if err != nil {
+			return err
+		}
+
+		// the bucket notifications are already used by the pipes, so
+		// the metadata of the external table is refreshed by a task
+		// instead
+		coldFlowsTable, err := snowflake.NewExternalTable(ctx, "antrea-sf-cold-flows-table", &snowflake.ExternalTableArgs{
+			Database:        db.ID(),
+			Schema:          schema.Name,
+			Name:            pulumi.String(coldFlowsTableName),
+			Location:        pulumi.Sprintf("@%s.%s.%s", db.Name, schemaName, coldFlowsStageName),
+			FileFormat:      pulumi.Sprintf("FORMAT_NAME = %s.%s.%s", db.Name, schemaName, coldFlowsFileFormatName),
+			Columns:         coldFlowsColumns(),
+			AutoRefresh:     pulumi.Bool(false),
+			RefreshOnCreate: pulumi.Bool(true),
+			Comment:         pulumi.String("Flow records stored in the S3 bucket, including the ones deleted from the flows table"),
+		}, pulumi.Parent(schema), pulumi.DependsOn(append([]pulumi.Resource{fileFormat, stage}, dependencies...)), pulumi.DeleteBeforeReplace(true))
+		if err != nil {
+			return err
+		}
+
+		_, err = snowflake.NewTask(ctx, "antrea-sf-cold-flows-refresh-task", &snowflake.TaskArgs{
+			Database:     db.ID(),
+			Schema:       schema.Name,
+			Name:         pulumi.String(coldFlowsRefreshTaskName),
+			Schedule:     pulumi.String("USING CRON 0 * * * * UTC"),
+			SqlStatement: pulumi.Sprintf("ALTER EXTERNAL TABLE %s REFRESH", coldFlowsTableName),
+			Warehouse:    ingestionWarehouse.Name,
+			Enabled:      pulumi.Bool(true),
+		}, pulumi.Parent(schema), pulumi.DependsOn([]pulumi.Resource{coldFlowsTable}), pulumi.DeleteBeforeReplace(true))
+		if err != nil {
+			return err
+		}
+
+		_, err = snowflake.NewView(ctx, "antrea-sf-all-flows-view", &snowflake.ViewArgs{
+			Database: db.ID(),
+			Schema:   schema.Name,
+			Name:     pulumi.String(allFlowsViewName),
+			Statement: db.Name.ApplyT(func(databaseName string) string {
+				return allFlowsViewStatement(databaseName)
+			}).(pulumi.StringOutput),
+			Comment: pulumi.String("All flow records, from the flows table for the retention period and from the bucket before"),
+		}, pulumi.Parent(schema), pulumi.DependsOn(append([]pulumi.Resource{coldFlowsTable}, dependencies...)), pulumi.DeleteBeforeReplace(true))
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+	return declareFunc
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi-snowflake/sdk/go/snowflake"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/snowflake/database"
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
+)
+
+func TestValidateColdFlowsLifecycle(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		lifecycle   s3client.LifecycleConfig
+		expectedErr string
+	}{
+		{
+			name:      "no expiration",
+			lifecycle: s3client.LifecycleConfig{TransitionToIADays: 30},
+		},
+		{
+			name:      "expiration after retention",
+			lifecycle: s3client.LifecycleConfig{ExpirationDays: 31},
+		},
+		{
+			name:        "expiration before retention",
+			lifecycle:   s3client.LifecycleConfig{ExpirationDays: 30},
+			expectedErr: "flow records must not expire from the bucket before 31 days",
+		},
+		{
+			name:        "transition to Glacier",
+			lifecycle:   s3client.LifecycleConfig{TransitionToGlacierDays: 90},
+			expectedErr: "flow records cannot be transitioned to GLACIER",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateColdFlowsLifecycle(&tc.lifecycle)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestColdFlowsColumns(t *testing.T) {
+	// The flow records do not include the insertion time, which is set by
+	// Snowflake, so the CSV fields only map to the columns of the flows
+	// table in order if TIMEINSERTED is the last column.
+	lastColumn := database.FlowsTableColumns[len(database.FlowsTableColumns)-1]
+	require.Equal(t, flowsTimeInsertedColumn, lastColumn.Name)
+
+	columns := coldFlowsColumns()
+	require.Len(t, columns, len(database.FlowsTableColumns)-1)
+	for i := range columns {
+		column, ok := columns[i].(snowflake.ExternalTableColumnArgs)
+		require.True(t, ok)
+		expected := database.FlowsTableColumns[i]
+		assert.Equal(t, pulumi.String(expected.Name), column.Name)
+		assert.Equal(t, pulumi.String(expected.Type), column.Type)
+		assert.Equal(t, pulumi.String(fmt.Sprintf("(VALUE:c%d::%s)", i+1, expected.Type)), column.As)
+	}
+	first := columns[0].(snowflake.ExternalTableColumnArgs)
+	assert.Equal(t, pulumi.String("(VALUE:c1::TIMESTAMP_TZ)"), first.As)
+	last := columns[len(columns)-1].(snowflake.ExternalTableColumnArgs)
+	assert.Equal(t, pulumi.String("CLUSTERUUID"), last.Name)
+	assert.Equal(t, pulumi.String(fmt.Sprintf("(VALUE:c%d::TEXT(36))", len(database.FlowsTableColumns)-1)), last.As)
+}
+
+func TestAllFlowsViewStatement(t *testing.T) {
+	statement := allFlowsViewStatement(testDatabaseName)
+	parts := strings.Split(statement, " UNION ALL ")
+	require.Len(t, parts, 2)
+
+	var columnNames []string
+	for _, column := range database.FlowsTableColumns {
+		columnNames = append(columnNames, column.Name)
+	}
+	hotColumns := strings.Join(columnNames, ", ")
+	coldColumns := strings.Join(append(columnNames[:len(columnNames)-1:len(columnNames)-1], "NULL::TIMESTAMP_TZ AS TIMEINSERTED"), ", ")
+	cutoff := "DATEADD(day, -30, CURRENT_TIMESTAMP)"
+	assert.Equal(t, fmt.Sprintf("SELECT %s FROM ANTREA_TEST.THEIA.FLOWS WHERE flowEndSeconds >= %s", hotColumns, cutoff), parts[0])
+	assert.Equal(t, fmt.Sprintf("SELECT %s FROM ANTREA_TEST.THEIA.FLOWS_COLD WHERE flowEndSeconds < %s", coldColumns, cutoff), parts[1])
+}
@@ -14,6 +14,8 @@
 
 package infra
 
+import "time"
+
 const (
 	projectName = "theia-infra"
 
@@ -25,13 +27,21 @@ const (
 
 	migrateSnowflakeVersion = "v0.2.0"
 
-	flowRecordsRetentionDays = 7
-	s3BucketNamePrefix       = "antrea-flows-"
-	s3BucketFlowsFolder      = "flows"
+	s3BucketNamePrefix  = "antrea-flows-"
+	s3BucketFlowsFolder = "flows"
+	// flows can be sharded across multiple key prefixes in the flows folder
+	// (flows/shard-00/, flows/shard-01/, ...), each with its own Snowpipe
+	// stage and pipe
+	s3BucketFlowsShardPrefix = "shard-"
+	maxFlowsShards           = 64
 	snsTopicNamePrefix       = "antrea-flows-"
 	sqsQueueNamePrefix       = "antrea-flows-"
-	// how long will Snowpipe error notifications be saved in SQS queue
-	sqsMessageRetentionSeconds = 7 * 24 * 3600
+	// limits enforced by SQS
+	sqsMinMessageRetentionSeconds  = 60
+	sqsMaxMessageRetentionSeconds  = 14 * 24 * 3600
+	sqsMaxVisibilityTimeoutSeconds = 12 * 3600
+	// how long SQS reuses a data key before calling KMS again
+	sqsKMSDataKeyReusePeriodSeconds = 300
 
 	storageIAMRoleNamePrefix     = "antrea-sf-storage-iam-role-"
 	storageIAMPolicyNamePrefix   = "antrea-sf-storage-iam-policy-"
@@ -42,6 +52,11 @@ const (
 	notificationIntegrationNamePrefix = "ANTREA_FLOWS_NOTIFICATION_INTEGRATION_"
 
 	databaseNamePrefix = "ANTREA_"
+	// workload warehouses are named after the database, e.g.
+	// ANTREA_93E9OJN80FGN5VWT_QUERY_WH
+	warehouseNameSuffix = "_WH"
+	// seconds of inactivity after which workload warehouses are suspended
+	warehouseAutoSuspendSeconds = 60
 
 	schemaName           = "THEIA"
 	flowRetentionDays    = 30
@@ -49,9 +64,34 @@ const (
 	udfStageName         = "UDFS"
 	ingestionStageName   = "FLOWSTAGE"
 	autoIngestPipeName   = "FLOWPIPE"
+	// objects used to query the flow records deleted from the flows table,
+	// directly from the bucket
+	coldFlowsFileFormatName  = "FLOWS_CSV"
+	coldFlowsStageName       = "FLOWS_COLD_STAGE"
+	coldFlowsTableName       = "FLOWS_COLD"
+	coldFlowsRefreshTaskName = "REFRESH_FLOWS_COLD"
+	allFlowsViewName         = "FLOWS_ALL"
 
 	// do not change!!!
 	flowsTableName = "FLOWS"
+	// set by Snowflake when flow records are ingested
+	flowsTimeInsertedColumn = "TIMEINSERTED"
+	// backups are created before applying destructive changes to the flows
+	// table, e.g. FLOWS_BACKUP_20220801T100000Z
+	flowsBackupTableNamePrefix = "FLOWS_BACKUP_"
+	// the schema check only queries metadata and clones the flows table
+	schemaCheckStatementTimeout = 5 * time.Minute
+	// time allowed to delete temporary resources after the main context is
+	// done
+	cleanupTimeout = 30 * time.Second
 
 	migrationsDir = "migrations"
 )
+
+const (
+	// StackTagKey and VersionTagKey are the keys of the tags applied to all
+	// the AWS resources created by the Manager, in addition to user-provided
+	// tags.
+	StackTagKey   = "theia-stack"
+	VersionTagKey = "theia-version"
+)
@@ -18,7 +18,7 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
-	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
@@ -36,7 +36,9 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 
 	"antrea.io/theia/snowflake/database"
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
 	sf "antrea.io/theia/snowflake/pkg/snowflake"
+	"antrea.io/theia/snowflake/pkg/version"
 )
 
 type pulumiPlugin struct {
@@ -214,6 +216,58 @@ func installMigrateSnowflakeCLI(ctx context.Context, logger logr.Logger, dir str
 	return nil
 }
 
+// SQSEncryption is the server-side encryption of the SQS queue.
+type SQSEncryption string
+
+const (
+	// SQSEncryptionSQS uses an SQS-managed key.
+	SQSEncryptionSQS SQSEncryption = "sqs"
+	// SQSEncryptionKMS uses the KMS key of SQSQueueConfig.KMSKeyID.
+	SQSEncryptionKMS SQSEncryption = "kms"
+)
+
+// SQSQueueConfig configures the SQS queue which receives Snowpipe error
+// notifications.
+type SQSQueueConfig struct {
+	// Encryption defaults to SQSEncryptionKMS if KMSKeyID is set, and to
+	// SQSEncryptionSQS otherwise.
+	Encryption SQSEncryption
+	// KMSKeyID is the ID or ARN of the KMS key used for server-side
+	// encryption with SQSEncryptionKMS.
+	KMSKeyID                 string
+	MessageRetentionSeconds  int
+	VisibilityTimeoutSeconds int
+}
+
+// Validate checks the configuration, and sets the default encryption.
+func (c *SQSQueueConfig) Validate() error {
+	if c.Encryption == "" {
+		c.Encryption = SQSEncryptionSQS
+		if c.KMSKeyID != "" {
+			c.Encryption = SQSEncryptionKMS
+		}
+	}
+	switch c.Encryption {
+	case SQSEncryptionSQS:
+		if c.KMSKeyID != "" {
+			return fmt.Errorf("a KMS key can only be used with %s encryption", SQSEncryptionKMS)
+		}
+	case SQSEncryptionKMS:
+		if c.KMSKeyID == "" {
+			return fmt.Errorf("a KMS key is required for %s encryption", SQSEncryptionKMS)
+		}
+	default:
+		return fmt.Errorf("unsupported encryption %q, it must be %s or %s", c.Encryption, SQSEncryptionSQS, SQSEncryptionKMS)
+	}
+	if c.MessageRetentionSeconds < sqsMinMessageRetentionSeconds || c.MessageRetentionSeconds > sqsMaxMessageRetentionSeconds {
+		return fmt.Errorf("message retention must be between %d and %d seconds", sqsMinMessageRetentionSeconds, sqsMaxMessageRetentionSeconds)
+	}
+	if c.VisibilityTimeoutSeconds < 0 || c.VisibilityTimeoutSeconds > sqsMaxVisibilityTimeoutSeconds {
+		return fmt.Errorf("visibility timeout must be between 0 and %d seconds", sqsMaxVisibilityTimeoutSeconds)
+	}
+	return nil
+}
+
 type Manager struct {
 	logger             logr.Logger
 	stackName          string
@@ -221,8 +275,28 @@ type Manager struct {
 	secretsProviderURL string
 	region             string
 	warehouseName      string
+	warehouseSizes     WarehouseSizes
+	flowsShards        int
 	workdir            string
 	verbose            bool
+	tags               map[string]string
+	flowsLifecycle     s3client.LifecycleConfig
+	sqsQueueConfig     SQSQueueConfig
+	// query flows deleted from the flows table from the bucket
+	coldFlows bool
+	// allow destructive changes to the schema of the flows table
+	allowDestructiveSchemaChanges bool
+}
+
+// ValidateTags checks that the user-provided tags do not use the keys of the
+// tags applied by the Manager.
+func ValidateTags(tags map[string]string) error {
+	for _, key := range []string{StackTagKey, VersionTagKey} {
+		if _, ok := tags[key]; ok {
+			return fmt.Errorf("tag %s is reserved", key)
+		}
+	}
+	return nil
 }
 
 func NewManager(
@@ -232,9 +306,24 @@ func NewManager(
 	secretsProviderURL string,
 	region string,
 	warehouseName string,
+	warehouseSizes WarehouseSizes, // sizes of the warehouses created for each workload
+	flowsShards int, // number of key-prefix shards in the flows folder
 	workdir string,
 	verbose bool, // output Pulumi progress to stdout
+	tags map[string]string, // applied to all AWS resources
+	flowsLifecycle s3client.LifecycleConfig, // lifecycle of the flow records in the S3 bucket
+	sqsQueueConfig SQSQueueConfig,
+	coldFlows bool, // create an external table over the flow records in the bucket
+	allowDestructiveSchemaChanges bool, // a backup of the flows table is created first
 ) *Manager {
+	allTags := make(map[string]string, len(tags)+2)
+	for k, v := range tags {
+		allTags[k] = v
+	}
+	// the reserved tags cannot be overwritten by the user tags, they are
+	// used to find the resources of the stack
+	allTags[StackTagKey] = stackName
+	allTags[VersionTagKey] = version.Version
 	return &Manager{
 		logger:             logger.WithValues("project", projectName, "stack", stackName),
 		stackName:          stackName,
@@ -242,8 +331,16 @@ func NewManager(
 		secretsProviderURL: secretsProviderURL,
 		region:             region,
 		warehouseName:      warehouseName,
+		warehouseSizes:     warehouseSizes,
+		flowsShards:        flowsShards,
 		workdir:            workdir,
 		verbose:            verbose,
+		tags:               allTags,
+		flowsLifecycle:     flowsLifecycle,
+		sqsQueueConfig:     sqsQueueConfig,
+		coldFlows:          coldFlows,
+
+		allowDestructiveSchemaChanges: allowDestructiveSchemaChanges,
 	}
 }
 
@@ -310,6 +407,12 @@ func (m *Manager) setup(
 	}
 	// set stack configuration specifying the AWS region to deploy
 	s.SetConfig(ctx, "aws:region", auto.ConfigValue{Value: m.region})
+	// tag all AWS resources which support it, for cost attribution and discovery
+	defaultTags, err := json.Marshal(map[string]interface{}{"tags": m.tags})
+	if err != nil {
+		return s, err
+	}
+	s.SetConfig(ctx, "aws:defaultTags", auto.ConfigValue{Value: string(defaultTags)})
 	logger.Info("Refreshing stack")
 	_, err = s.Refresh(ctx)
 	if err != nil {
@@ -323,11 +426,18 @@ type Result struct {
 	Region            string
 	BucketName        string
 	BucketFlowsFolder string
+	BucketFlowsShards []string
 	DatabaseName      string
 	SchemaName        string
 	FlowsTableName    string
 	SNSTopicARN       string
 	SQSQueueARN       string
+	// WarehouseNames maps each workload to the name of its warehouse.
+	WarehouseNames map[Workload]string
+	// ColdFlowsTableName and AllFlowsViewName are empty if the external
+	// table over the flow records in the bucket was not created.
+	ColdFlowsTableName string
+	AllFlowsViewName   string
 }
 
 func (m *Manager) run(ctx context.Context, destroy bool) (*Result, error) {
@@ -370,7 +480,7 @@ func (m *Manager) run(ctx context.Context, destroy bool) (*Result, error) {
 				return nil, fmt.Errorf("failed to create DSN: %w", err)
 			}
 
-			db, err := sql.Open("snowflake", dsn)
+			db, err := sf.OpenDB(dsn, sf.DefaultConnectionConfig)
 			if err != nil {
 				return nil, fmt.Errorf("failed to connect to Snowflake: %w", err)
 			}
@@ -381,7 +491,11 @@ func (m *Manager) run(ctx context.Context, destroy bool) (*Result, error) {
 				return nil, err
 			}
 			defer func() {
-				if err := temporaryWarehouse.Delete(ctx); err != nil {
+				// the warehouse must be deleted even if ctx was cancelled
+				// or timed out
+				cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+				defer cancel()
+				if err := temporaryWarehouse.Delete(cleanupCtx); err != nil {
 					logger.Error(err, "Failed to delete temporary warehouse, please do it manually", "name", warehouseName)
 				}
 			}()
@@ -395,7 +509,7 @@ func (m *Manager) run(ctx context.Context, destroy bool) (*Result, error) {
 		{name: "command", version: pulumiCommandPluginVersion},
 	}
 
-	s, err := m.setup(ctx, m.stackName, workdir, plugins, declareStack(warehouseName))
+	s, err := m.setup(ctx, m.stackName, workdir, plugins, declareStack(warehouseName, m.warehouseSizes, m.flowsShards, &m.flowsLifecycle, &m.sqsQueueConfig, m.coldFlows))
 	if err != nil {
 		return nil, err
 	}
@@ -449,6 +563,9 @@ func (m *Manager) run(ctx context.Context, destroy bool) (*Result, error) {
 	getStackOutputs := func(outs auto.OutputMap) (map[string]string, error) {
 		result := make(map[string]string)
 		names := []string{"bucketID", "databaseName", "storageIntegrationName", "notificationIntegrationName", "snsTopicARN", "sqsQueueARN"}
+		for _, workload := range Workloads {
+			names = append(names, warehouseOutputName(workload))
+		}
 		for _, name := range names {
 			v, ok := outs[name].Value.(string)
 			if !ok {
@@ -459,6 +576,16 @@ func (m *Manager) run(ctx context.Context, destroy bool) (*Result, error) {
 		return result, nil
 	}
 
+	// the database name is only known once the stack has been created, so
+	// there is nothing to check for a new stack
+	if prevOuts, err := s.Outputs(ctx); err != nil {
+		return nil, err
+	} else if databaseName, ok := prevOuts["databaseName"].Value.(string); ok {
+		if err := m.checkFlowsTableSchema(ctx, databaseName, warehouseName); err != nil {
+			return nil, err
+		}
+	}
+
 	upRes, err := updateFunc()
 	if err != nil {
 		return nil, err
@@ -468,18 +595,46 @@ func (m *Manager) run(ctx context.Context, destroy bool) (*Result, error) {
 		return nil, err
 	}
 
+	warehouseNames := make(map[Workload]string)
+	for _, workload := range Workloads {
+		warehouseNames[workload] = outs[warehouseOutputName(workload)]
+	}
+
+	var coldFlowsTable, allFlowsView string
+	if m.coldFlows {
+		coldFlowsTable, allFlowsView = coldFlowsTableName, allFlowsViewName
+	}
+
 	return &Result{
 		Region:            m.region,
 		BucketName:        outs["bucketID"],
 		BucketFlowsFolder: s3BucketFlowsFolder,
+		BucketFlowsShards: FlowsShardFolders(m.flowsShards),
 		DatabaseName:      outs["databaseName"],
 		SchemaName:        schemaName,
 		FlowsTableName:    flowsTableName,
 		SNSTopicARN:       outs["snsTopicARN"],
 		SQSQueueARN:       outs["sqsQueueARN"],
+		WarehouseNames:    warehouseNames,
+
+		ColdFlowsTableName: coldFlowsTable,
+		AllFlowsViewName:   allFlowsView,
 	}, nil
 }
 
+func (m *Manager) checkFlowsTableSchema(ctx context.Context, databaseName string, warehouseName string) error {
+	dsn, _, err := sf.GetDSN(sf.SetWarehouse(warehouseName), sf.SetStatementTimeout(schemaCheckStatementTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to create DSN: %w", err)
+	}
+	db, err := sf.OpenDB(dsn, sf.DefaultConnectionConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Snowflake: %w", err)
+	}
+	defer db.Close()
+	return checkFlowsTableSchema(ctx, sf.NewClient(db, m.logger), m.logger, databaseName, m.allowDestructiveSchemaChanges)
+}
+
 func (m *Manager) Onboard(ctx context.Context) (*Result, error) {
 	return m.run(ctx, false)
 }
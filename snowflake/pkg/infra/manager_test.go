@@ -0,0 +1,140 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
+	"antrea.io/theia/snowflake/pkg/version"
+)
+
+func TestSQSQueueConfigValidate(t *testing.T) {
+	const keyID = "arn:aws:kms:us-west-2:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	for _, tc := range []struct {
+		name               string
+		config             SQSQueueConfig
+		expectedEncryption SQSEncryption
+		expectedErr        string
+	}{
+		{
+			name:               "default encryption",
+			config:             SQSQueueConfig{MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 30},
+			expectedEncryption: SQSEncryptionSQS,
+		},
+		{
+			name:               "default encryption with KMS key",
+			config:             SQSQueueConfig{KMSKeyID: keyID, MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 30},
+			expectedEncryption: SQSEncryptionKMS,
+		},
+		{
+			name:               "SQS encryption",
+			config:             SQSQueueConfig{Encryption: SQSEncryptionSQS, MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 30},
+			expectedEncryption: SQSEncryptionSQS,
+		},
+		{
+			name:               "KMS encryption",
+			config:             SQSQueueConfig{Encryption: SQSEncryptionKMS, KMSKeyID: keyID, MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 30},
+			expectedEncryption: SQSEncryptionKMS,
+		},
+		{
+			name:        "KMS key without KMS encryption",
+			config:      SQSQueueConfig{Encryption: SQSEncryptionSQS, KMSKeyID: keyID, MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 30},
+			expectedErr: "a KMS key can only be used with kms encryption",
+		},
+		{
+			name:        "KMS encryption without KMS key",
+			config:      SQSQueueConfig{Encryption: SQSEncryptionKMS, MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 30},
+			expectedErr: "a KMS key is required for kms encryption",
+		},
+		{
+			name:        "unsupported encryption",
+			config:      SQSQueueConfig{Encryption: "aes256", MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 30},
+			expectedErr: `unsupported encryption "aes256", it must be sqs or kms`,
+		},
+		{
+			name:               "minimum retention and visibility timeout",
+			config:             SQSQueueConfig{MessageRetentionSeconds: 60, VisibilityTimeoutSeconds: 0},
+			expectedEncryption: SQSEncryptionSQS,
+		},
+		{
+			name:               "maximum retention and visibility timeout",
+			config:             SQSQueueConfig{MessageRetentionSeconds: 14 * 24 * 3600, VisibilityTimeoutSeconds: 12 * 3600},
+			expectedEncryption: SQSEncryptionSQS,
+		},
+		{
+			name:        "retention too short",
+			config:      SQSQueueConfig{MessageRetentionSeconds: 59, VisibilityTimeoutSeconds: 30},
+			expectedErr: "message retention must be between 60 and 1209600 seconds",
+		},
+		{
+			name:        "retention too long",
+			config:      SQSQueueConfig{MessageRetentionSeconds: 14*24*3600 + 1, VisibilityTimeoutSeconds: 30},
+			expectedErr: "message retention must be between 60 and 1209600 seconds",
+		},
+		{
+			name:        "negative visibility timeout",
+			config:      SQSQueueConfig{MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: -1},
+			expectedErr: "visibility timeout must be between 0 and 43200 seconds",
+		},
+		{
+			name:        "visibility timeout too long",
+			config:      SQSQueueConfig{MessageRetentionSeconds: 3600, VisibilityTimeoutSeconds: 12*3600 + 1},
+			expectedErr: "visibility timeout must be between 0 and 43200 seconds",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := tc.config
+			err := config.Validate()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedEncryption, config.Encryption)
+			}
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		tags        map[string]string
+		expectedErr string
+	}{
+		{name: "no tags"},
+		{name: "user tags", tags: map[string]string{"owner": "alice", "cost-center": "1234"}},
+		{name: "stack tag", tags: map[string]string{"owner": "alice", StackTagKey: "foo"}, expectedErr: "tag theia-stack is reserved"},
+		{name: "version tag", tags: map[string]string{VersionTagKey: "v0.1.0"}, expectedErr: "tag theia-version is reserved"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTags(tc.tags)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewManagerTags(t *testing.T) {
+	tags := map[string]string{"owner": "alice", StackTagKey: "bar", VersionTagKey: "v0.1.0"}
+	m := NewManager(logr.Discard(), "foo", "", "", "us-west-2", "", DefaultWarehouseSizes(), 1, "", false, tags, s3client.LifecycleConfig{}, SQSQueueConfig{}, false, false)
+	assert.Equal(t, map[string]string{"owner": "alice", StackTagKey: "foo", VersionTagKey: version.Version}, m.tags)
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"antrea.io/theia/snowflake/database"
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+)
+
+var (
+	upMigrationFileRegexp = regexp.MustCompile(`^([0-9]+)_(.+)\.up\.sql$`)
+	createdObjectRegexp   = regexp.MustCompile(`(?i)\bCREATE\s+(?:OR\s+REPLACE\s+)?(?:TABLE|VIEW)\s+(?:IF\s+NOT\s+EXISTS\s+)?([A-Za-z_][A-Za-z0-9_$]*)`)
+)
+
+// Migration is a database migration which has not been applied yet.
+type Migration struct {
+	Version  uint64
+	Name     string
+	FileName string
+	// SQL is the content of the migration file, in which the names of the
+	// objects created by migrations are qualified with the database and
+	// schema the migration runs in.
+	SQL string
+}
+
+// PendingMigrations returns the up migrations in fsys with a version greater
+// than currentVersion, in the order in which they will be applied. Qualifying
+// object names is equivalent to what migrate-snowflake does, which is to run
+// the migrations in a session using the database and schema.
+func PendingMigrations(fsys fs.FS, migrationsPath string, currentVersion int64, databaseName string, schemaName string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, migrationsPath)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	var objects []string
+	for _, e := range entries {
+		matches := upMigrationFileRegexp.FindStringSubmatch(e.Name())
+		if e.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version for migration %s: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(migrationsPath, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// objects created by previous migrations can be referenced by
+		// pending ones, so we look for them in all migrations
+		for _, m := range createdObjectRegexp.FindAllStringSubmatch(string(data), -1) {
+			objects = append(objects, regexp.QuoteMeta(m[1]))
+		}
+		if int64(version) <= currentVersion {
+			continue
+		}
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     matches[2],
+			FileName: e.Name(),
+			SQL:      string(data),
+		})
+	}
+	if len(objects) == 0 {
+		return migrations, nil
+	}
+	objectRegexp := regexp.MustCompile(fmt.Sprintf(`(?i)\b(TABLE|VIEW|FROM|JOIN|INTO|EXISTS)(\s+)(%s)\b`, strings.Join(objects, "|")))
+	for i := range migrations {
+		migrations[i].SQL = objectRegexp.ReplaceAllString(migrations[i].SQL, fmt.Sprintf("${1}${2}%s.%s.${3}", databaseName, schemaName))
+	}
+	return migrations, nil
+}
+
+// ApplyMigrations runs all pending migrations with migrate-snowflake, which is
+// installed in workdir. It fails if the schema has been migrated since
+// expectedVersion was read, as the migrations which would run could then differ
+// from the ones which were reviewed. Destructive changes to the flows table are
+// handled as during onboarding.
+func ApplyMigrations(
+	ctx context.Context,
+	logger logr.Logger,
+	sfClient sf.Client,
+	workdir string,
+	databaseName string,
+	schemaName string,
+	warehouseName string,
+	expectedVersion int64,
+	allowDestructive bool,
+) error {
+	version, dirty, err := sfClient.GetMigrationVersion(ctx, databaseName, schemaName)
+	if err != nil {
+		return fmt.Errorf("error when getting migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("last migration (version %d) failed and must be fixed manually", version)
+	}
+	if version != expectedVersion {
+		return fmt.Errorf("migration version changed from %d to %d since migrations were rendered", expectedVersion, version)
+	}
+	if err := checkFlowsTableSchema(ctx, sfClient, logger, databaseName, allowDestructive); err != nil {
+		return err
+	}
+	if err := installMigrateSnowflakeCLI(ctx, logger, workdir); err != nil {
+		return fmt.Errorf("error when installing Migrate Snowflake: %w", err)
+	}
+	if err := writeMigrationsToDisk(database.Migrations, database.MigrationsPath, filepath.Join(workdir, migrationsDir)); err != nil {
+		return err
+	}
+	logger.Info("Applying database migrations", "database", databaseName, "schema", schemaName)
+	cmd := exec.CommandContext(ctx, "./migrate-snowflake", "-source", fmt.Sprintf("file://%s", migrationsDir), "-database", databaseName, "-schema", schemaName, "-warehouse", warehouseName)
+	cmd.Dir = workdir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error when applying database migrations: %w", err)
+	}
+	logger.Info("Applied database migrations", "database", databaseName, "schema", schemaName)
+	return nil
+}
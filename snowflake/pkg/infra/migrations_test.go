@@ -0,0 +1,177 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/snowflake/database"
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+	sffake "antrea.io/theia/snowflake/pkg/snowflake/fake"
+)
+
+var testMigrations = fstest.MapFS{
+	"migrations/000001_create_flows_table.up.sql": &fstest.MapFile{
+		Data: []byte("CREATE TABLE IF NOT EXISTS flows (sourceIP STRING(50));"),
+	},
+	"migrations/000001_create_flows_table.down.sql": &fstest.MapFile{
+		Data: []byte("DROP TABLE IF EXISTS flows;"),
+	},
+	"migrations/000002_create_pods_view.up.sql": &fstest.MapFile{
+		Data: []byte("CREATE OR REPLACE VIEW pods AS SELECT sourceIP FROM flows JOIN nodes USING (sourceIP);"),
+	},
+	"migrations/000002_create_pods_view.down.sql": &fstest.MapFile{
+		Data: []byte("DROP VIEW IF EXISTS pods;"),
+	},
+	"migrations/000010_add_cluster_uuid.up.sql": &fstest.MapFile{
+		Data: []byte("ALTER TABLE flows ADD COLUMN clusterUUID STRING(36);\nINSERT INTO flows_backup SELECT * FROM Flows;"),
+	},
+	"migrations/README.md": &fstest.MapFile{
+		Data: []byte("CREATE TABLE ignored (a INT);"),
+	},
+	"migrations/000011_ignored.up.sql/nested.sql": &fstest.MapFile{
+		Data: []byte("CREATE TABLE ignored (a INT);"),
+	},
+}
+
+func TestPendingMigrations(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		currentVersion     int64
+		expectedMigrations []Migration
+	}{
+		{
+			name:           "no migration applied",
+			currentVersion: sf.NilMigrationVersion,
+			expectedMigrations: []Migration{
+				{
+					Version:  1,
+					Name:     "create_flows_table",
+					FileName: "000001_create_flows_table.up.sql",
+					SQL:      "CREATE TABLE IF NOT EXISTS ANTREA_TEST.THEIA.flows (sourceIP STRING(50));",
+				},
+				{
+					Version:  2,
+					Name:     "create_pods_view",
+					FileName: "000002_create_pods_view.up.sql",
+					SQL:      "CREATE OR REPLACE VIEW ANTREA_TEST.THEIA.pods AS SELECT sourceIP FROM ANTREA_TEST.THEIA.flows JOIN nodes USING (sourceIP);",
+				},
+				{
+					Version:  10,
+					Name:     "add_cluster_uuid",
+					FileName: "000010_add_cluster_uuid.up.sql",
+					SQL:      "ALTER TABLE ANTREA_TEST.THEIA.flows ADD COLUMN clusterUUID STRING(36);\nINSERT INTO flows_backup SELECT * FROM ANTREA_TEST.THEIA.Flows;",
+				},
+			},
+		},
+		{
+			// objects created by applied migrations are qualified too
+			name:           "some migrations applied",
+			currentVersion: 2,
+			expectedMigrations: []Migration{
+				{
+					Version:  10,
+					Name:     "add_cluster_uuid",
+					FileName: "000010_add_cluster_uuid.up.sql",
+					SQL:      "ALTER TABLE ANTREA_TEST.THEIA.flows ADD COLUMN clusterUUID STRING(36);\nINSERT INTO flows_backup SELECT * FROM ANTREA_TEST.THEIA.Flows;",
+				},
+			},
+		},
+		{
+			name:               "all migrations applied",
+			currentVersion:     10,
+			expectedMigrations: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := PendingMigrations(testMigrations, "migrations", tc.currentVersion, testDatabaseName, schemaName)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedMigrations, migrations)
+		})
+	}
+}
+
+func TestPendingMigrationsErrors(t *testing.T) {
+	_, err := PendingMigrations(testMigrations, "missing", sf.NilMigrationVersion, testDatabaseName, schemaName)
+	assert.Error(t, err)
+
+	invalidMigrations := fstest.MapFS{
+		"migrations/99999999999999999999_too_large.up.sql": &fstest.MapFile{
+			Data: []byte("SELECT 1;"),
+		},
+	}
+	_, err = PendingMigrations(invalidMigrations, "migrations", sf.NilMigrationVersion, testDatabaseName, schemaName)
+	assert.ErrorContains(t, err, "invalid version for migration 99999999999999999999_too_large.up.sql")
+}
+
+func TestPendingMigrationsEmbedded(t *testing.T) {
+	migrations, err := PendingMigrations(database.Migrations, database.MigrationsPath, sf.NilMigrationVersion, testDatabaseName, schemaName)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i := 1; i < len(migrations); i++ {
+		assert.Greater(t, migrations[i].Version, migrations[i-1].Version)
+	}
+}
+
+func TestApplyMigrationsPreconditions(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		version         int64
+		dirty           bool
+		flowsColumns    []sf.Column
+		expectedVersion int64
+		expectedErr     string
+	}{
+		{
+			name:            "dirty",
+			version:         3,
+			dirty:           true,
+			expectedVersion: 3,
+			expectedErr:     "last migration (version 3) failed and must be fixed manually",
+		},
+		{
+			name:            "version changed",
+			version:         4,
+			expectedVersion: 3,
+			expectedErr:     "migration version changed from 3 to 4 since migrations were rendered",
+		},
+		{
+			name:            "destructive change",
+			version:         3,
+			flowsColumns:    []sf.Column{{Name: "OBSOLETE", Type: "TEXT(10)"}},
+			expectedVersion: 3,
+			expectedErr:     "refusing to apply destructive changes to flows table",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sfClient := sffake.NewClient()
+			sfClient.SetMigrationVersion(testDatabaseName, schemaName, tc.version, tc.dirty)
+			if tc.flowsColumns != nil {
+				sfClient.SetTableColumns(testDatabaseName, schemaName, flowsTableName, tc.flowsColumns)
+			}
+			// the preconditions are checked before installing
+			// migrate-snowflake in workdir
+			workdir := t.TempDir()
+			err := ApplyMigrations(context.Background(), logr.Discard(), sfClient, workdir, testDatabaseName, schemaName, "TEST_WH", tc.expectedVersion, false)
+			assert.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"antrea.io/theia/snowflake/database"
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+)
+
+var columnTypeRegex = regexp.MustCompile(`^(\w+)(?:\((\d+)(?:,(\d+))?\))?$`)
+
+type schemaChange struct {
+	column      string
+	liveType    string
+	desiredType string
+	destructive bool
+}
+
+func (c *schemaChange) String() string {
+	switch {
+	case c.liveType == "":
+		return fmt.Sprintf("add column %s %s", c.column, c.desiredType)
+	case c.desiredType == "":
+		return fmt.Sprintf("drop column %s %s", c.column, c.liveType)
+	default:
+		return fmt.Sprintf("change type of column %s from %s to %s", c.column, c.liveType, c.desiredType)
+	}
+}
+
+// isWideningTypeChange returns true if values of the live type can always be
+// represented with the desired type: longer text, or numbers with more digits
+// and the same scale.
+func isWideningTypeChange(liveType string, desiredType string) bool {
+	live := columnTypeRegex.FindStringSubmatch(liveType)
+	desired := columnTypeRegex.FindStringSubmatch(desiredType)
+	if live == nil || desired == nil || live[1] != desired[1] || live[3] != desired[3] {
+		return false
+	}
+	if live[2] == "" || desired[2] == "" {
+		return false
+	}
+	liveSize, _ := strconv.Atoi(live[2])
+	desiredSize, _ := strconv.Atoi(desired[2])
+	return desiredSize >= liveSize
+}
+
+// diffSchema returns the changes required to go from the live columns to the
+// desired columns. Adding columns and widening column types are the only
+// non-destructive changes.
+func diffSchema(liveColumns []database.Column, desiredColumns []database.Column) []schemaChange {
+	liveTypes := make(map[string]string)
+	for _, c := range liveColumns {
+		liveTypes[c.Name] = c.Type
+	}
+	desiredTypes := make(map[string]string)
+	for _, c := range desiredColumns {
+		desiredTypes[c.Name] = c.Type
+	}
+	var changes []schemaChange
+	for _, c := range liveColumns {
+		if _, ok := desiredTypes[c.Name]; !ok {
+			changes = append(changes, schemaChange{column: c.Name, liveType: c.Type, destructive: true})
+		}
+	}
+	for _, c := range desiredColumns {
+		liveType, ok := liveTypes[c.Name]
+		if !ok {
+			changes = append(changes, schemaChange{column: c.Name, desiredType: c.Type})
+		} else if liveType != c.Type {
+			changes = append(changes, schemaChange{column: c.Name, liveType: liveType, desiredType: c.Type, destructive: !isWideningTypeChange(liveType, c.Type)})
+		}
+	}
+	return changes
+}
+
+// checkFlowsTableSchema compares the schema of the live flows table with the
+// schema expected by this version, before migrations are run. Destructive
+// changes are refused, unless allowDestructive is true, in which case a clone
+// of the flows table is created first, as a backup.
+func checkFlowsTableSchema(ctx context.Context, sfClient sf.Client, logger logr.Logger, databaseName string, allowDestructive bool) error {
+	logger = logger.WithValues("database", databaseName, "table", flowsTableName)
+	logger.Info("Checking schema of flows table")
+	columns, err := sfClient.GetTableColumns(ctx, databaseName, schemaName, flowsTableName)
+	if err != nil {
+		return fmt.Errorf("error when getting schema of flows table: %w", err)
+	}
+	if len(columns) == 0 {
+		logger.Info("Flows table does not exist yet")
+		return nil
+	}
+	liveColumns := make([]database.Column, len(columns))
+	for i := range columns {
+		liveColumns[i] = database.Column(columns[i])
+	}
+	changes := diffSchema(liveColumns, database.FlowsTableColumns)
+	var destructiveChanges []string
+	for i := range changes {
+		logger.Info("Flows table schema change", "change", changes[i].String(), "destructive", changes[i].destructive)
+		if changes[i].destructive {
+			destructiveChanges = append(destructiveChanges, changes[i].String())
+		}
+	}
+	if len(destructiveChanges) == 0 {
+		return nil
+	}
+	if !allowDestructive {
+		return fmt.Errorf("refusing to apply destructive changes to flows table (%s), use --allow-destructive to proceed after creating a backup of the table", strings.Join(destructiveChanges, ", "))
+	}
+	backupTableName := fmt.Sprintf("%s%s", flowsBackupTableNamePrefix, time.Now().UTC().Format("20060102T150405Z"))
+	logger.Info("Creating backup of flows table", "backup", backupTableName)
+	if err := sfClient.CloneTable(ctx, databaseName, schemaName, flowsTableName, backupTableName); err != nil {
+		return fmt.Errorf("error when creating backup of flows table: %w", err)
+	}
+	logger.Info("Created backup of flows table", "backup", backupTableName)
+	return nil
+}
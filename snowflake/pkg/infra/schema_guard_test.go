@@ -0,0 +1,151 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"antrea.io/theia/snowflake/database"
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+	sffake "antrea.io/theia/snowflake/pkg/snowflake/fake"
+)
+
+const testDatabaseName = "ANTREA_TEST"
+
+func TestDiffSchema(t *testing.T) {
+	liveColumns := []database.Column{
+		{Name: "SOURCEIP", Type: "TEXT(50)"},
+		{Name: "SOURCEPODNAME", Type: "TEXT(256)"},
+		{Name: "FLOWTYPE", Type: "NUMBER(3,0)"},
+		{Name: "THROUGHPUT", Type: "NUMBER(10,0)"},
+		{Name: "OBSOLETE", Type: "TEXT(10)"},
+	}
+	desiredColumns := []database.Column{
+		{Name: "SOURCEIP", Type: "TEXT(50)"},
+		{Name: "SOURCEPODNAME", Type: "TEXT(128)"},
+		{Name: "FLOWTYPE", Type: "NUMBER(3,1)"},
+		{Name: "THROUGHPUT", Type: "NUMBER(20,0)"},
+		{Name: "CLUSTERUUID", Type: "TEXT(36)"},
+	}
+	changes := diffSchema(liveColumns, desiredColumns)
+	var descriptions []string
+	var destructive []string
+	for i := range changes {
+		descriptions = append(descriptions, changes[i].String())
+		if changes[i].destructive {
+			destructive = append(destructive, changes[i].column)
+		}
+	}
+	assert.Equal(t, []string{
+		"drop column OBSOLETE TEXT(10)",
+		"change type of column SOURCEPODNAME from TEXT(256) to TEXT(128)",
+		"change type of column FLOWTYPE from NUMBER(3,0) to NUMBER(3,1)",
+		"change type of column THROUGHPUT from NUMBER(10,0) to NUMBER(20,0)",
+		"add column CLUSTERUUID TEXT(36)",
+	}, descriptions)
+	assert.Equal(t, []string{"OBSOLETE", "SOURCEPODNAME", "FLOWTYPE"}, destructive)
+}
+
+func toSnowflakeColumns(columns []database.Column) []sf.Column {
+	sfColumns := make([]sf.Column, len(columns))
+	for i := range columns {
+		sfColumns[i] = sf.Column(columns[i])
+	}
+	return sfColumns
+}
+
+func TestCheckFlowsTableSchema(t *testing.T) {
+	// a flows table created before the CLUSTERUUID column was added
+	previousColumns := append(append([]database.Column{}, database.FlowsTableColumns[:len(database.FlowsTableColumns)-2]...), database.FlowsTableColumns[len(database.FlowsTableColumns)-1])
+	// a flows table with a column which has been removed from the schema
+	extraColumns := append([]database.Column{{Name: "OBSOLETE", Type: "TEXT(10)"}}, database.FlowsTableColumns...)
+
+	for _, tc := range []struct {
+		name             string
+		columns          []database.Column
+		allowDestructive bool
+		expectedErr      string
+		expectedBackup   bool
+	}{
+		{
+			name:    "no flows table",
+			columns: nil,
+		},
+		{
+			name:    "no change",
+			columns: database.FlowsTableColumns,
+		},
+		{
+			name:    "non-destructive change",
+			columns: previousColumns,
+		},
+		{
+			name:        "destructive change refused",
+			columns:     extraColumns,
+			expectedErr: "refusing to apply destructive changes to flows table (drop column OBSOLETE TEXT(10))",
+		},
+		{
+			name:             "destructive change allowed",
+			columns:          extraColumns,
+			allowDestructive: true,
+			expectedBackup:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sfClient := sffake.NewClient()
+			if tc.columns != nil {
+				sfClient.SetTableColumns(testDatabaseName, schemaName, flowsTableName, toSnowflakeColumns(tc.columns))
+			}
+			err := checkFlowsTableSchema(context.Background(), sfClient, logr.Discard(), testDatabaseName, tc.allowDestructive)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			tableNames := sfClient.TableNames(testDatabaseName, schemaName)
+			if tc.expectedBackup {
+				require.Len(t, tableNames, 2)
+				assert.True(t, strings.HasPrefix(tableNames[1], flowsBackupTableNamePrefix))
+				backupColumns, err := sfClient.GetTableColumns(context.Background(), testDatabaseName, schemaName, tableNames[1])
+				require.NoError(t, err)
+				assert.Equal(t, toSnowflakeColumns(tc.columns), backupColumns)
+			} else if tc.columns != nil {
+				assert.Equal(t, []string{flowsTableName}, tableNames)
+			} else {
+				assert.Empty(t, tableNames)
+			}
+		})
+	}
+}
+
+func TestCheckFlowsTableSchemaErrors(t *testing.T) {
+	sfClient := sffake.NewClient()
+	sfClient.SetError("GetTableColumns", errors.New("warehouse does not exist"))
+	err := checkFlowsTableSchema(context.Background(), sfClient, logr.Discard(), testDatabaseName, true)
+	assert.ErrorContains(t, err, "error when getting schema of flows table: warehouse does not exist")
+
+	sfClient.SetError("GetTableColumns", nil)
+	sfClient.SetTableColumns(testDatabaseName, schemaName, flowsTableName, []sf.Column{{Name: "OBSOLETE", Type: "TEXT(10)"}})
+	sfClient.SetError("CloneTable", errors.New("insufficient privileges"))
+	err = checkFlowsTableSchema(context.Background(), sfClient, logr.Discard(), testDatabaseName, true)
+	assert.ErrorContains(t, err, "error when creating backup of flows table: insufficient privileges")
+}
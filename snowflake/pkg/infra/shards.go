@@ -0,0 +1,74 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// ValidateFlowsShards checks that the number of key-prefix shards for the
+// flows folder is supported.
+func ValidateFlowsShards(shards int) error {
+	if shards < 1 || shards > maxFlowsShards {
+		return fmt.Errorf("number of flows shards must be between 1 and %d", maxFlowsShards)
+	}
+	return nil
+}
+
+// FlowsShardFolder returns the folder (key prefix, without trailing slash) in
+// the flows bucket for the given shard. When there is a single shard, flows
+// are uploaded directly to the flows folder, which preserves the layout used
+// before sharding was introduced.
+func FlowsShardFolder(shard int, shards int) string {
+	if shards <= 1 {
+		return s3BucketFlowsFolder
+	}
+	return fmt.Sprintf("%s/%s%02d", s3BucketFlowsFolder, s3BucketFlowsShardPrefix, shard)
+}
+
+// FlowsShardFolders returns the folders of all the shards, when there is more
+// than one shard, and nil otherwise.
+func FlowsShardFolders(shards int) []string {
+	if shards <= 1 {
+		return nil
+	}
+	folders := make([]string, 0, shards)
+	for shard := 0; shard < shards; shard++ {
+		folders = append(folders, FlowsShardFolder(shard, shards))
+	}
+	return folders
+}
+
+// FlowsShardForKey maps a key (e.g., the UUID of the cluster exporting the
+// flows) to a shard, using jump consistent hashing: when the number of shards
+// increases from N to N+1, only 1/(N+1) of the keys move to a different
+// shard, and they all move to the new one.
+func FlowsShardForKey(key string, shards int) int {
+	if shards <= 1 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	k := h.Sum64()
+	// see "A Fast, Minimal Memory, Consistent Hash Algorithm" (Lamping & Veach)
+	var b, j int64 = -1, 0
+	for j < int64(shards) {
+		b = j
+		k = k*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((k>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFlowsShards(t *testing.T) {
+	for _, tc := range []struct {
+		shards      int
+		expectedErr bool
+	}{
+		{shards: -1, expectedErr: true},
+		{shards: 0, expectedErr: true},
+		{shards: 1},
+		{shards: 8},
+		{shards: maxFlowsShards},
+		{shards: maxFlowsShards + 1, expectedErr: true},
+	} {
+		t.Run(fmt.Sprintf("%d shards", tc.shards), func(t *testing.T) {
+			err := ValidateFlowsShards(tc.shards)
+			if tc.expectedErr {
+				assert.EqualError(t, err, "number of flows shards must be between 1 and 64")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFlowsShardFolder(t *testing.T) {
+	for _, tc := range []struct {
+		shard          int
+		shards         int
+		expectedFolder string
+	}{
+		{shard: 0, shards: 1, expectedFolder: "flows"},
+		{shard: 0, shards: 8, expectedFolder: "flows/shard-00"},
+		{shard: 7, shards: 8, expectedFolder: "flows/shard-07"},
+		{shard: 63, shards: 64, expectedFolder: "flows/shard-63"},
+	} {
+		assert.Equal(t, tc.expectedFolder, FlowsShardFolder(tc.shard, tc.shards))
+	}
+	assert.Nil(t, FlowsShardFolders(1))
+	assert.Equal(t, []string{"flows/shard-00", "flows/shard-01", "flows/shard-02"}, FlowsShardFolders(3))
+}
+
+func TestFlowsShardForKey(t *testing.T) {
+	// the mapping must never change, as clusters upload their flow records
+	// to the shard selected for their UUID
+	for _, tc := range []struct {
+		key           string
+		shards        int
+		expectedShard int
+	}{
+		{key: "cluster-1", shards: 1, expectedShard: 0},
+		{key: "cluster-1", shards: 2, expectedShard: 0},
+		{key: "cluster-1", shards: 8, expectedShard: 3},
+		{key: "cluster-1", shards: 64, expectedShard: 3},
+		{key: "d8f2b0e4-7c1a-4e8e-9a56-3f9c2b7e1a10", shards: 2, expectedShard: 0},
+		{key: "d8f2b0e4-7c1a-4e8e-9a56-3f9c2b7e1a10", shards: 8, expectedShard: 3},
+		{key: "d8f2b0e4-7c1a-4e8e-9a56-3f9c2b7e1a10", shards: 64, expectedShard: 60},
+		{key: "", shards: 8, expectedShard: 1},
+		{key: "", shards: 64, expectedShard: 17},
+	} {
+		t.Run(fmt.Sprintf("%s/%d", tc.key, tc.shards), func(t *testing.T) {
+			assert.Equal(t, tc.expectedShard, FlowsShardForKey(tc.key, tc.shards))
+		})
+	}
+}
+
+func TestFlowsShardForKeyBounds(t *testing.T) {
+	for _, shards := range []int{1, 2, 8, maxFlowsShards} {
+		counts := make([]int, shards)
+		for i := 0; i < 1000*shards; i++ {
+			shard := FlowsShardForKey(fmt.Sprintf("cluster-%d", i), shards)
+			if !assert.True(t, shard >= 0 && shard < shards, "shard %d out of range for %d shards", shard, shards) {
+				return
+			}
+			counts[shard]++
+		}
+		// with jump consistent hashing, keys are spread evenly
+		for shard, count := range counts {
+			assert.InDelta(t, 1000, count, 200, "unbalanced shard %d of %d", shard, shards)
+		}
+	}
+}
+
+func TestFlowsShardForKeyConsistency(t *testing.T) {
+	// when a shard is added, keys only move to the new shard
+	for shards := 1; shards < maxFlowsShards; shards++ {
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("cluster-%d", i)
+			before, after := FlowsShardForKey(key, shards), FlowsShardForKey(key, shards+1)
+			if after != before {
+				assert.Equal(t, shards, after, "key %s moved from shard %d to shard %d", key, before, after)
+			}
+		}
+	}
+}
@@ -30,6 +30,8 @@ import (
 	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
 	"github.com/pulumi/pulumi-snowflake/sdk/go/snowflake"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	s3client "antrea.io/theia/snowflake/pkg/aws/client/s3"
 )
 
 func declareSnowflakeIngestion(randomString *random.RandomString, bucket *s3.BucketV2, accountID string) func(ctx *pulumi.Context) (*snowflake.StorageIntegration, *iam.Role, error) {
@@ -215,16 +217,46 @@ func declareSnowflakeErrorNotifications(randomString *random.RandomString, snsTo
 	return declareFunc
 }
 
+func declareSnowflakeWarehouses(databaseName pulumi.StringOutput, warehouseSizes WarehouseSizes) func(ctx *pulumi.Context) (map[Workload]*snowflake.Warehouse, error) {
+	declareFunc := func(ctx *pulumi.Context) (map[Workload]*snowflake.Warehouse, error) {
+		warehouses := make(map[Workload]*snowflake.Warehouse)
+		for _, workload := range Workloads {
+			workload := workload
+			warehouseName := databaseName.ApplyT(func(name string) string {
+				return WarehouseName(name, workload)
+			}).(pulumi.StringOutput)
+			warehouse, err := snowflake.NewWarehouse(ctx, fmt.Sprintf("antrea-sf-%s-warehouse", workload), &snowflake.WarehouseArgs{
+				Name:               warehouseName,
+				WarehouseSize:      pulumi.String(warehouseSizes[workload]),
+				AutoSuspend:        pulumi.Int(warehouseAutoSuspendSeconds),
+				AutoResume:         pulumi.Bool(true),
+				InitiallySuspended: pulumi.Bool(true),
+				Comment:            pulumi.Sprintf("Antrea warehouse for %s workload", workload),
+			}, pulumi.DeleteBeforeReplace(true))
+			if err != nil {
+				return nil, err
+			}
+			ctx.Export(warehouseOutputName(workload), warehouse.Name)
+			warehouses[workload] = warehouse
+		}
+		return warehouses, nil
+	}
+	return declareFunc
+}
+
 func declareSnowflakeDatabase(
 	warehouseName string,
+	warehouseSizes WarehouseSizes,
 	randomString *random.RandomString,
 	bucket *s3.BucketV2,
 	storageIntegration *snowflake.StorageIntegration,
 	storageIAMRole *iam.Role,
 	notificationIntegration *snowflake.NotificationIntegration,
 	notificationIAMRole *iam.Role,
-) func(ctx *pulumi.Context) (*snowflake.Pipe, error) {
-	declareFunc := func(ctx *pulumi.Context) (*snowflake.Pipe, error) {
+	flowsShards int,
+	coldFlows bool,
+) func(ctx *pulumi.Context) ([]*snowflake.Pipe, error) {
+	declareFunc := func(ctx *pulumi.Context) ([]*snowflake.Pipe, error) {
 		databaseName := randomString.Result.ApplyT(func(suffix string) string {
 			return fmt.Sprintf("%s%s", databaseNamePrefix, strings.ToUpper(suffix))
 		}).(pulumi.StringOutput)
@@ -236,6 +268,13 @@ func declareSnowflakeDatabase(
 		}
 		ctx.Export("databaseName", db.Name)
 
+		// each workload gets its own warehouse, so that queries from one
+		// workload never queue behind queries from another one
+		warehouses, err := declareSnowflakeWarehouses(db.Name, warehouseSizes)(ctx)
+		if err != nil {
+			return nil, err
+		}
+
 		schema, err := snowflake.NewSchema(ctx, "antrea-sf-schema", &snowflake.SchemaArgs{
 			Database: db.ID(),
 			Name:     pulumi.String(schemaName),
@@ -253,19 +292,6 @@ func declareSnowflakeDatabase(
 			return nil, err
 		}
 
-		// IAMRoles need to be added as dependencies, but integrations are probably redundant
-		dependencies := []pulumi.Resource{storageIntegration, storageIAMRole, notificationIntegration, notificationIAMRole}
-		ingestionStage, err := snowflake.NewStage(ctx, "antrea-sf-ingestion-stage", &snowflake.StageArgs{
-			Database:           db.ID(),
-			Schema:             schema.Name,
-			Name:               pulumi.String(ingestionStageName),
-			Url:                pulumi.Sprintf("s3://%s/%s/", bucket.ID(), s3BucketFlowsFolder),
-			StorageIntegration: storageIntegration.ID(),
-		}, pulumi.Parent(schema), pulumi.DependsOn(dependencies), pulumi.DeleteBeforeReplace(true))
-		if err != nil {
-			return nil, err
-		}
-
 		// ideally this would be a dynamic provider: https://www.pulumi.com/docs/intro/concepts/resources/dynamic-providers/
 		// however, dynamic providers are not supported at the moment for Golang
 		// maybe this is a change that we can make at a future time if support is added
@@ -284,41 +310,109 @@ func declareSnowflakeDatabase(
 			return nil, err
 		}
 
-		// a bit of defensive programming: explicit dependency on ingestionStage may not be strictly required
-		pipe, err := snowflake.NewPipe(ctx, "antrea-sf-auto-ingest-pipe", &snowflake.PipeArgs{
-			Database:         db.ID(),
-			Schema:           schema.Name,
-			Name:             pulumi.String(autoIngestPipeName),
-			AutoIngest:       pulumi.Bool(true),
-			ErrorIntegration: notificationIntegration.ID(),
-			// FQN required for table and stage, see https://github.com/pulumi/pulumi-snowflake/issues/129
-			CopyStatement: pulumi.Sprintf("COPY INTO %s.%s.%s FROM @%s.%s.%s FILE_FORMAT = (TYPE = 'CSV')", databaseName, schemaName, flowsTableName, databaseName, schemaName, ingestionStageName),
-		}, pulumi.Parent(schema), pulumi.DependsOn([]pulumi.Resource{ingestionStage, dbMigrations}), pulumi.DeleteBeforeReplace(true))
-		if err != nil {
-			return nil, err
+		// IAMRoles need to be added as dependencies, but integrations are probably redundant
+		dependencies := []pulumi.Resource{storageIntegration, storageIAMRole, notificationIntegration, notificationIAMRole}
+		// with a single shard, we keep the original resource names so that
+		// existing stacks are not modified
+		var pipes []*snowflake.Pipe
+		for shard := 0; shard < flowsShards; shard++ {
+			resourceSuffix, nameSuffix := "", ""
+			if flowsShards > 1 {
+				resourceSuffix = fmt.Sprintf("-%02d", shard)
+				nameSuffix = fmt.Sprintf("_%02d", shard)
+			}
+			stageName := ingestionStageName + nameSuffix
+			ingestionStage, err := snowflake.NewStage(ctx, "antrea-sf-ingestion-stage"+resourceSuffix, &snowflake.StageArgs{
+				Database:           db.ID(),
+				Schema:             schema.Name,
+				Name:               pulumi.String(stageName),
+				Url:                pulumi.Sprintf("s3://%s/%s/", bucket.ID(), FlowsShardFolder(shard, flowsShards)),
+				StorageIntegration: storageIntegration.ID(),
+			}, pulumi.Parent(schema), pulumi.DependsOn(dependencies), pulumi.DeleteBeforeReplace(true))
+			if err != nil {
+				return nil, err
+			}
+
+			// a bit of defensive programming: explicit dependency on ingestionStage may not be strictly required
+			pipe, err := snowflake.NewPipe(ctx, "antrea-sf-auto-ingest-pipe"+resourceSuffix, &snowflake.PipeArgs{
+				Database:         db.ID(),
+				Schema:           schema.Name,
+				Name:             pulumi.String(autoIngestPipeName + nameSuffix),
+				AutoIngest:       pulumi.Bool(true),
+				ErrorIntegration: notificationIntegration.ID(),
+				// FQN required for table and stage, see https://github.com/pulumi/pulumi-snowflake/issues/129
+				CopyStatement: pulumi.Sprintf("COPY INTO %s.%s.%s FROM @%s.%s.%s FILE_FORMAT = (TYPE = 'CSV')", databaseName, schemaName, flowsTableName, databaseName, schemaName, stageName),
+			}, pulumi.Parent(schema), pulumi.DependsOn([]pulumi.Resource{ingestionStage, dbMigrations}), pulumi.DeleteBeforeReplace(true))
+			if err != nil {
+				return nil, err
+			}
+			pipes = append(pipes, pipe)
 		}
 
 		if flowRetentionDays > 0 {
 			_, err := snowflake.NewTask(ctx, "antrea-sf-flow-deletion-task", &snowflake.TaskArgs{
-				Database:                            db.ID(),
-				Schema:                              schema.Name,
-				Name:                                pulumi.String(flowDeletionTaskName),
-				Schedule:                            pulumi.String("USING CRON 0 0 * * * UTC"),
-				SqlStatement:                        pulumi.Sprintf("DELETE FROM %s WHERE DATEDIFF(day, timeInserted, CURRENT_TIMESTAMP) > %d", flowsTableName, flowRetentionDays),
-				UserTaskManagedInitialWarehouseSize: pulumi.String("XSMALL"),
-				Enabled:                             pulumi.Bool(true),
+				Database:     db.ID(),
+				Schema:       schema.Name,
+				Name:         pulumi.String(flowDeletionTaskName),
+				Schedule:     pulumi.String("USING CRON 0 0 * * * UTC"),
+				SqlStatement: pulumi.Sprintf("DELETE FROM %s WHERE DATEDIFF(day, timeInserted, CURRENT_TIMESTAMP) > %d", flowsTableName, flowRetentionDays),
+				Warehouse:    warehouses[IngestionWorkload].Name,
+				Enabled:      pulumi.Bool(true),
 			}, pulumi.Parent(schema), pulumi.DependsOn([]pulumi.Resource{dbMigrations}), pulumi.DeleteBeforeReplace(true))
 			if err != nil {
 				return nil, err
 			}
 		}
 
-		return pipe, nil
+		if coldFlows {
+			if err := declareSnowflakeColdFlows(db, schema, bucket, storageIntegration, warehouses[IngestionWorkload], []pulumi.Resource{storageIAMRole, dbMigrations})(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		return pipes, nil
 	}
 	return declareFunc
 }
 
-func declareStack(warehouseName string) func(ctx *pulumi.Context) error {
+func declareBucketLifecycleRule(lifecycle *s3client.LifecycleConfig, folder string) *s3.BucketLifecycleConfigurationV2RuleArgs {
+	rule := &s3.BucketLifecycleConfigurationV2RuleArgs{
+		Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{
+			Prefix: pulumi.Sprintf("%s/", pulumi.String(folder)),
+		},
+		Id:     pulumi.String(folder),
+		Status: pulumi.String("Enabled"),
+	}
+	var transitions s3.BucketLifecycleConfigurationV2RuleTransitionArray
+	if lifecycle.TransitionToIADays > 0 {
+		transitions = append(transitions, &s3.BucketLifecycleConfigurationV2RuleTransitionArgs{
+			Days:         pulumi.Int(int(lifecycle.TransitionToIADays)),
+			StorageClass: pulumi.String("STANDARD_IA"),
+		})
+	}
+	if lifecycle.TransitionToGlacierDays > 0 {
+		transitions = append(transitions, &s3.BucketLifecycleConfigurationV2RuleTransitionArgs{
+			Days:         pulumi.Int(int(lifecycle.TransitionToGlacierDays)),
+			StorageClass: pulumi.String("GLACIER"),
+		})
+	}
+	if len(transitions) > 0 {
+		rule.Transitions = transitions
+	}
+	if lifecycle.ExpirationDays > 0 {
+		rule.Expiration = &s3.BucketLifecycleConfigurationV2RuleExpirationArgs{
+			Days: pulumi.Int(int(lifecycle.ExpirationDays)),
+		}
+	}
+	if lifecycle.AbortIncompleteMultipartUploadDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &s3.BucketLifecycleConfigurationV2RuleAbortIncompleteMultipartUploadArgs{
+			DaysAfterInitiation: pulumi.Int(int(lifecycle.AbortIncompleteMultipartUploadDays)),
+		}
+	}
+	return rule
+}
+
+func declareStack(warehouseName string, warehouseSizes WarehouseSizes, flowsShards int, flowsLifecycle *s3client.LifecycleConfig, sqsQueueConfig *SQSQueueConfig, coldFlows bool) func(ctx *pulumi.Context) error {
 	declareFunc := func(ctx *pulumi.Context) error {
 		randomString, err := random.NewRandomString(ctx, "antrea-flows-random-pet-suffix", &random.RandomStringArgs{
 			Length:  pulumi.Int(16),
@@ -338,29 +432,31 @@ func declareStack(warehouseName string) func(ctx *pulumi.Context) error {
 		}
 		ctx.Export("bucketID", bucket.ID())
 
-		_, err = s3.NewBucketLifecycleConfigurationV2(ctx, "antrea-flows-bucket-lifecycle-configuration", &s3.BucketLifecycleConfigurationV2Args{
-			Bucket: bucket.ID(),
-			Rules: s3.BucketLifecycleConfigurationV2RuleArray{
-				&s3.BucketLifecycleConfigurationV2RuleArgs{
-					Expiration: &s3.BucketLifecycleConfigurationV2RuleExpirationArgs{
-						Days: pulumi.Int(flowRecordsRetentionDays),
-					},
-					Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{
-						Prefix: pulumi.Sprintf("%s/", pulumi.String(s3BucketFlowsFolder)),
-					},
-					Id:     pulumi.String(s3BucketFlowsFolder),
-					Status: pulumi.String("Enabled"),
+		if !flowsLifecycle.IsEmpty() {
+			_, err = s3.NewBucketLifecycleConfigurationV2(ctx, "antrea-flows-bucket-lifecycle-configuration", &s3.BucketLifecycleConfigurationV2Args{
+				Bucket: bucket.ID(),
+				Rules: s3.BucketLifecycleConfigurationV2RuleArray{
+					declareBucketLifecycleRule(flowsLifecycle, s3BucketFlowsFolder),
 				},
-			},
-		})
-		if err != nil {
-			return err
+			})
+			if err != nil {
+				return err
+			}
 		}
 
-		sqsQueue, err := sqs.NewQueue(ctx, "antrea-flows-sqs-queue", &sqs.QueueArgs{
-			Name:                    pulumi.Sprintf("%s%s", sqsQueueNamePrefix, randomString.ID()),
-			MessageRetentionSeconds: pulumi.Int(sqsMessageRetentionSeconds),
-		}, pulumi.DeleteBeforeReplace(true))
+		sqsQueueArgs := &sqs.QueueArgs{
+			Name:                     pulumi.Sprintf("%s%s", sqsQueueNamePrefix, randomString.ID()),
+			MessageRetentionSeconds:  pulumi.Int(sqsQueueConfig.MessageRetentionSeconds),
+			VisibilityTimeoutSeconds: pulumi.Int(sqsQueueConfig.VisibilityTimeoutSeconds),
+		}
+		if sqsQueueConfig.Encryption == SQSEncryptionKMS {
+			// the key policy must allow SNS to use the key (kms:GenerateDataKey* and kms:Decrypt)
+			sqsQueueArgs.KmsMasterKeyId = pulumi.String(sqsQueueConfig.KMSKeyID)
+			sqsQueueArgs.KmsDataKeyReusePeriodSeconds = pulumi.Int(sqsKMSDataKeyReusePeriodSeconds)
+		} else {
+			sqsQueueArgs.SqsManagedSseEnabled = pulumi.Bool(true)
+		}
+		sqsQueue, err := sqs.NewQueue(ctx, "antrea-flows-sqs-queue", sqsQueueArgs, pulumi.DeleteBeforeReplace(true))
 		if err != nil {
 			return err
 		}
@@ -374,6 +470,13 @@ func declareStack(warehouseName string) func(ctx *pulumi.Context) error {
 		}
 		ctx.Export("snsTopicARN", snsTopic.Arn)
 
+		current, err := aws.GetCallerIdentity(ctx, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		// only the SNS topic for Snowpipe error notifications, in the same
+		// account, can send messages to the queue, and only over TLS
 		sqsQueuePolicyDocument := iam.GetPolicyDocumentOutput(ctx, iam.GetPolicyDocumentOutputArgs{
 			Statements: iam.GetPolicyDocumentStatementArray{
 				iam.GetPolicyDocumentStatementArgs{
@@ -393,6 +496,30 @@ func declareStack(warehouseName string) func(ctx *pulumi.Context) error {
 							Variable: pulumi.String("aws:SourceArn"),
 							Values:   pulumi.StringArray([]pulumi.StringInput{snsTopic.Arn}),
 						},
+						iam.GetPolicyDocumentStatementConditionArgs{
+							Test:     pulumi.String("StringEquals"),
+							Variable: pulumi.String("aws:SourceAccount"),
+							Values:   pulumi.ToStringArray([]string{current.AccountId}),
+						},
+					},
+				},
+				iam.GetPolicyDocumentStatementArgs{
+					Sid:    pulumi.String("2"),
+					Effect: pulumi.String("Deny"),
+					Principals: iam.GetPolicyDocumentStatementPrincipalArray{
+						iam.GetPolicyDocumentStatementPrincipalArgs{
+							Type:        pulumi.String("*"),
+							Identifiers: pulumi.ToStringArray([]string{"*"}),
+						},
+					},
+					Actions:   pulumi.ToStringArray([]string{"sqs:*"}),
+					Resources: pulumi.StringArray([]pulumi.StringInput{sqsQueue.Arn}),
+					Conditions: iam.GetPolicyDocumentStatementConditionArray{
+						iam.GetPolicyDocumentStatementConditionArgs{
+							Test:     pulumi.String("Bool"),
+							Variable: pulumi.String("aws:SecureTransport"),
+							Values:   pulumi.ToStringArray([]string{"false"}),
+						},
 					},
 				},
 			},
@@ -415,11 +542,6 @@ func declareStack(warehouseName string) func(ctx *pulumi.Context) error {
 			return err
 		}
 
-		current, err := aws.GetCallerIdentity(ctx, nil, nil)
-		if err != nil {
-			return err
-		}
-
 		storageIntegration, storageIAMRole, err := declareSnowflakeIngestion(randomString, bucket, current.AccountId)(ctx)
 		if err != nil {
 			return err
@@ -430,23 +552,31 @@ func declareStack(warehouseName string) func(ctx *pulumi.Context) error {
 			return err
 		}
 
-		pipe, err := declareSnowflakeDatabase(warehouseName, randomString, bucket, storageIntegration, storageIAMRole, notificationIntegration, notificationIAMRole)(ctx)
+		pipes, err := declareSnowflakeDatabase(warehouseName, warehouseSizes, randomString, bucket, storageIntegration, storageIAMRole, notificationIntegration, notificationIAMRole, flowsShards, coldFlows)(ctx)
 		if err != nil {
 			return err
 		}
 
+		// a bucket can only have one notification configuration, so all the
+		// shards are declared in the same resource
+		var notificationQueues s3.BucketNotificationQueueArray
+		for shard, pipe := range pipes {
+			id := "Auto-ingest Snowflake"
+			if flowsShards > 1 {
+				id = fmt.Sprintf("%s %s%02d", id, s3BucketFlowsShardPrefix, shard)
+			}
+			notificationQueues = append(notificationQueues, &s3.BucketNotificationQueueArgs{
+				QueueArn: pipe.NotificationChannel,
+				Events: pulumi.StringArray{
+					pulumi.String("s3:ObjectCreated:*"),
+				},
+				FilterPrefix: pulumi.Sprintf("%s/", FlowsShardFolder(shard, flowsShards)),
+				Id:           pulumi.String(id),
+			})
+		}
 		_, err = s3.NewBucketNotification(ctx, "antrea-flows-bucket-notification", &s3.BucketNotificationArgs{
 			Bucket: bucket.ID(),
-			Queues: s3.BucketNotificationQueueArray{
-				&s3.BucketNotificationQueueArgs{
-					QueueArn: pipe.NotificationChannel,
-					Events: pulumi.StringArray{
-						pulumi.String("s3:ObjectCreated:*"),
-					},
-					FilterPrefix: pulumi.Sprintf("%s/", s3BucketFlowsFolder),
-					Id:           pulumi.String("Auto-ingest Snowflake"),
-				},
-			},
+			Queues: notificationQueues,
 		})
 		if err != nil {
 			return err
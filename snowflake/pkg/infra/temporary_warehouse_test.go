@@ -0,0 +1,56 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sffake "antrea.io/theia/snowflake/pkg/snowflake/fake"
+)
+
+func TestTemporaryWarehouse(t *testing.T) {
+	ctx := context.Background()
+	sfClient := sffake.NewClient()
+	w := newTemporaryWarehouse(sfClient, logr.Discard())
+	assert.Regexp(t, `^[A-Z]+_[A-Z]+_[A-Z]+$`, w.Name())
+
+	require.NoError(t, w.Create(ctx))
+	assert.True(t, sfClient.HasWarehouse(w.Name()))
+	require.NoError(t, sfClient.UseWarehouse(ctx, w.Name()))
+
+	require.NoError(t, w.Delete(ctx))
+	assert.False(t, sfClient.HasWarehouse(w.Name()))
+	// deleting a warehouse which no longer exists is not an error
+	assert.NoError(t, w.Delete(ctx))
+}
+
+func TestTemporaryWarehouseErrors(t *testing.T) {
+	ctx := context.Background()
+	sfClient := sffake.NewClient()
+	w := newTemporaryWarehouse(sfClient, logr.Discard())
+
+	sfClient.SetError("CreateWarehouse", errors.New("insufficient privileges"))
+	assert.EqualError(t, w.Create(ctx), "error when creating Snowflake warehouse: insufficient privileges")
+	assert.False(t, sfClient.HasWarehouse(w.Name()))
+
+	sfClient.SetError("DropWarehouse", errors.New("insufficient privileges"))
+	assert.EqualError(t, w.Delete(ctx), "error when deleting Snowflake warehouse: insufficient privileges")
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Workload identifies a class of queries which is run in its own Snowflake
+// warehouse, so that e.g. dashboard queries do not queue behind ingestion.
+type Workload string
+
+const (
+	// IngestionWorkload covers the maintenance of the flows table (e.g.,
+	// deletion of stale flows).
+	IngestionWorkload Workload = "ingestion"
+	// QueryWorkload covers ad-hoc queries, including Grafana dashboards.
+	QueryWorkload Workload = "query"
+	// RecommendationWorkload covers application jobs, such as NetworkPolicy
+	// recommendation.
+	RecommendationWorkload Workload = "recommendation"
+)
+
+// Workloads lists all the workloads, each of which gets a dedicated warehouse.
+var Workloads = []Workload{IngestionWorkload, QueryWorkload, RecommendationWorkload}
+
+var warehouseSizes = []string{"XSMALL", "SMALL", "MEDIUM", "LARGE", "XLARGE", "XXLARGE", "XXXLARGE", "X4LARGE", "X5LARGE", "X6LARGE"}
+
+// WarehouseSizes maps each workload to the size of its warehouse.
+type WarehouseSizes map[Workload]string
+
+// DefaultWarehouseSizes returns the sizes used by onboard when none are
+// provided. Recommendation jobs process all the flows in a time range, so they
+// get a larger warehouse.
+func DefaultWarehouseSizes() WarehouseSizes {
+	return WarehouseSizes{
+		IngestionWorkload:      "XSMALL",
+		QueryWorkload:          "XSMALL",
+		RecommendationWorkload: "SMALL",
+	}
+}
+
+// Validate checks that there is a valid size for every workload, and
+// normalizes sizes to upper case.
+func (s WarehouseSizes) Validate() error {
+	for _, workload := range Workloads {
+		size, ok := s[workload]
+		if !ok {
+			return fmt.Errorf("missing warehouse size for %s workload", workload)
+		}
+		size = strings.ToUpper(size)
+		if !isValidWarehouseSize(size) {
+			return fmt.Errorf("invalid warehouse size '%s' for %s workload, must be one of %s", s[workload], workload, strings.Join(warehouseSizes, ", "))
+		}
+		s[workload] = size
+	}
+	return nil
+}
+
+func isValidWarehouseSize(size string) bool {
+	for _, s := range warehouseSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// WarehouseName returns the name of the warehouse created by onboard for the
+// given workload, based on the name of the database created in the same stack.
+func WarehouseName(databaseName string, workload Workload) string {
+	return fmt.Sprintf("%s_%s%s", databaseName, strings.ToUpper(string(workload)), warehouseNameSuffix)
+}
+
+func warehouseOutputName(workload Workload) string {
+	return fmt.Sprintf("%sWarehouseName", workload)
+}
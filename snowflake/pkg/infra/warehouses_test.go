@@ -0,0 +1,82 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infra
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarehouseSizesValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		sizes         WarehouseSizes
+		expectedSizes WarehouseSizes
+		expectedErr   string
+	}{
+		{
+			name:          "default sizes",
+			sizes:         DefaultWarehouseSizes(),
+			expectedSizes: DefaultWarehouseSizes(),
+		},
+		{
+			name: "sizes are normalized",
+			sizes: WarehouseSizes{
+				IngestionWorkload:      "xsmall",
+				QueryWorkload:          "Medium",
+				RecommendationWorkload: "X4LARGE",
+			},
+			expectedSizes: WarehouseSizes{
+				IngestionWorkload:      "XSMALL",
+				QueryWorkload:          "MEDIUM",
+				RecommendationWorkload: "X4LARGE",
+			},
+		},
+		{
+			name: "missing size",
+			sizes: WarehouseSizes{
+				IngestionWorkload: "XSMALL",
+				QueryWorkload:     "XSMALL",
+			},
+			expectedErr: "missing warehouse size for recommendation workload",
+		},
+		{
+			name: "invalid size",
+			sizes: WarehouseSizes{
+				IngestionWorkload:      "XSMALL",
+				QueryWorkload:          "huge",
+				RecommendationWorkload: "SMALL",
+			},
+			expectedErr: "invalid warehouse size 'huge' for query workload, must be one of XSMALL, SMALL, MEDIUM, LARGE, XLARGE, XXLARGE, XXXLARGE, X4LARGE, X5LARGE, X6LARGE",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.sizes.Validate()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedSizes, tc.sizes)
+			}
+		})
+	}
+}
+
+func TestWarehouseName(t *testing.T) {
+	assert.Equal(t, "ANTREA_93E9OJN80FGN5VWT_INGESTION_WH", WarehouseName("ANTREA_93E9OJN80FGN5VWT", IngestionWorkload))
+	assert.Equal(t, "ANTREA_93E9OJN80FGN5VWT_QUERY_WH", WarehouseName("ANTREA_93E9OJN80FGN5VWT", QueryWorkload))
+	assert.Equal(t, "ANTREA_93E9OJN80FGN5VWT_RECOMMENDATION_WH", WarehouseName("ANTREA_93E9OJN80FGN5VWT", RecommendationWorkload))
+}
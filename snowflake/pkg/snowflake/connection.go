@@ -0,0 +1,145 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snowflake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+const (
+	// Snowflake error codes for which the query can be retried with a new
+	// session
+	errCodeSessionGone      = 390111
+	errCodeAuthTokenExpired = 390114
+)
+
+type ConnectionConfig struct {
+	MaxOpenConns int
+	MaxIdleConns int
+	// Snowflake sessions expire after 4 hours of inactivity, connections
+	// should be closed before that.
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultConnectionConfig is suitable for the CLI and for long-running
+// processes issuing a small number of concurrent queries.
+var DefaultConnectionConfig = ConnectionConfig{
+	MaxOpenConns:    4,
+	MaxIdleConns:    2,
+	ConnMaxLifetime: time.Hour,
+	ConnMaxIdleTime: 10 * time.Minute,
+}
+
+type RetryConfig struct {
+	// Attempts is the maximum number of times a query is run, including the
+	// first one.
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryConfig = RetryConfig{
+	Attempts:       5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+// SetStatementTimeout sets the STATEMENT_TIMEOUT_IN_SECONDS session parameter,
+// after which Snowflake cancels running statements. A timeout of 0 keeps the
+// default value for the account.
+func SetStatementTimeout(timeout time.Duration) func(*sf.Config) {
+	return func(cfg *sf.Config) {
+		if timeout <= 0 {
+			return
+		}
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]*string)
+		}
+		seconds := strconv.Itoa(int(timeout.Seconds()))
+		cfg.Params["STATEMENT_TIMEOUT_IN_SECONDS"] = &seconds
+	}
+}
+
+// OpenDB opens a connection pool to Snowflake, configured with the provided
+// pool sizes and connection lifetimes.
+func OpenDB(dsn string, config ConnectionConfig) (*sql.DB, error) {
+	db, err := sql.Open("snowflake", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	return db, nil
+}
+
+// IsTransientError returns true if the error is caused by a network issue or
+// an expired session, in which case the query can be retried.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var sfErr *sf.SnowflakeError
+	if errors.As(err, &sfErr) {
+		return sfErr.Number == errCodeSessionGone || sfErr.Number == errCodeAuthTokenExpired
+	}
+	return false
+}
+
+// after is used to wait between attempts, and can be overridden in tests.
+var after = time.After
+
+// withRetry calls fn until it succeeds, returns a non-transient error, or the
+// maximum number of attempts is reached, with exponential backoff.
+func withRetry(ctx context.Context, logger logr.Logger, config RetryConfig, fn func() error) error {
+	backoff := config.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= config.Attempts || !IsTransientError(err) {
+			return err
+		}
+		logger.Info("Transient error when running Snowflake query, retrying", "attempt", attempt, "backoff", backoff, "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-after(backoff):
+		}
+		backoff *= 2
+		if backoff > config.MaxBackoff {
+			backoff = config.MaxBackoff
+		}
+	}
+}
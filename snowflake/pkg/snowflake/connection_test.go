@@ -0,0 +1,213 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientError(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{
+			name:      "nil",
+			err:       nil,
+			transient: false,
+		},
+		{
+			name:      "bad connection",
+			err:       driver.ErrBadConn,
+			transient: true,
+		},
+		{
+			name:      "wrapped bad connection",
+			err:       fmt.Errorf("error when running query: %w", driver.ErrBadConn),
+			transient: true,
+		},
+		{
+			name:      "unexpected EOF",
+			err:       io.ErrUnexpectedEOF,
+			transient: true,
+		},
+		{
+			name:      "connection reset",
+			err:       &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			transient: true,
+		},
+		{
+			name:      "connection refused",
+			err:       &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			transient: true,
+		},
+		{
+			name:      "broken pipe",
+			err:       &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)},
+			transient: true,
+		},
+		{
+			name:      "network timeout",
+			err:       &net.DNSError{Err: "i/o timeout", Name: "example.snowflakecomputing.com", IsTimeout: true},
+			transient: true,
+		},
+		{
+			name:      "network error which is not a timeout",
+			err:       &net.DNSError{Err: "no such host", Name: "example.snowflakecomputing.com", IsNotFound: true},
+			transient: false,
+		},
+		{
+			name:      "session gone",
+			err:       &sf.SnowflakeError{Number: 390111},
+			transient: true,
+		},
+		{
+			name:      "authentication token expired",
+			err:       fmt.Errorf("error when running query: %w", &sf.SnowflakeError{Number: 390114}),
+			transient: true,
+		},
+		{
+			name:      "SQL compilation error",
+			err:       &sf.SnowflakeError{Number: 2003},
+			transient: false,
+		},
+		{
+			name:      "context canceled",
+			err:       context.Canceled,
+			transient: false,
+		},
+		{
+			name:      "context deadline exceeded",
+			err:       fmt.Errorf("error when running query: %w", context.DeadlineExceeded),
+			transient: false,
+		},
+		{
+			name:      "other error",
+			err:       errors.New("warehouse does not exist"),
+			transient: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.transient, IsTransientError(tc.err))
+		})
+	}
+}
+
+// fakeAfter replaces after for the duration of the test, records the requested
+// backoffs, and returns a channel which fires immediately, or never if block is
+// true.
+func fakeAfter(t *testing.T, block bool) *[]time.Duration {
+	backoffs := make([]time.Duration, 0)
+	originalAfter := after
+	after = func(d time.Duration) <-chan time.Time {
+		backoffs = append(backoffs, d)
+		ch := make(chan time.Time, 1)
+		if !block {
+			ch <- time.Now()
+		}
+		return ch
+	}
+	t.Cleanup(func() {
+		after = originalAfter
+	})
+	return &backoffs
+}
+
+func TestWithRetry(t *testing.T) {
+	retryConfig := RetryConfig{
+		Attempts:       5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     3 * time.Second,
+	}
+	transientErr := &sf.SnowflakeError{Number: 390111}
+	permanentErr := &sf.SnowflakeError{Number: 2003}
+
+	for _, tc := range []struct {
+		name             string
+		errs             []error
+		expectedErr      error
+		expectedCalls    int
+		expectedBackoffs []time.Duration
+	}{
+		{
+			name:             "success",
+			errs:             []error{nil},
+			expectedErr:      nil,
+			expectedCalls:    1,
+			expectedBackoffs: []time.Duration{},
+		},
+		{
+			name:             "success after transient errors",
+			errs:             []error{transientErr, driver.ErrBadConn, nil},
+			expectedErr:      nil,
+			expectedCalls:    3,
+			expectedBackoffs: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:             "permanent error",
+			errs:             []error{transientErr, permanentErr},
+			expectedErr:      permanentErr,
+			expectedCalls:    2,
+			expectedBackoffs: []time.Duration{time.Second},
+		},
+		{
+			name:             "attempt limit with capped backoff",
+			errs:             []error{transientErr, transientErr, transientErr, transientErr, transientErr, nil},
+			expectedErr:      transientErr,
+			expectedCalls:    5,
+			expectedBackoffs: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backoffs := fakeAfter(t, false)
+			calls := 0
+			err := withRetry(context.Background(), logr.Discard(), retryConfig, func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedCalls, calls)
+			assert.Equal(t, tc.expectedBackoffs, *backoffs)
+		})
+	}
+}
+
+func TestWithRetryContextCanceled(t *testing.T) {
+	fakeAfter(t, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := withRetry(ctx, logr.Discard(), DefaultRetryConfig, func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	assert.Equal(t, driver.ErrBadConn, err)
+	assert.Equal(t, 1, calls)
+}
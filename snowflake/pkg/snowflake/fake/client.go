@@ -0,0 +1,195 @@
+// Copyright 2022 Antrea Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	sf "antrea.io/theia/snowflake/pkg/snowflake"
+)
+
+// Client is an in-memory implementation of the Snowflake client interface,
+// which is safe for concurrent use. Errors can be injected for any method with
+// SetError.
+type Client struct {
+	mutex      sync.Mutex
+	warehouses map[string]sf.WarehouseConfig
+	warehouse  string
+	tables     map[string][]sf.Column
+	migrations map[string]migrationVersion
+	errors     map[string]error
+}
+
+type migrationVersion struct {
+	version int64
+	dirty   bool
+}
+
+var _ sf.Client = &Client{}
+
+func NewClient() *Client {
+	return &Client{
+		warehouses: make(map[string]sf.WarehouseConfig),
+		tables:     make(map[string][]sf.Column),
+		migrations: make(map[string]migrationVersion),
+		errors:     make(map[string]error),
+	}
+}
+
+func tableKey(databaseName string, schemaName string, tableName string) string {
+	return strings.ToUpper(fmt.Sprintf("%s.%s.%s", databaseName, schemaName, tableName))
+}
+
+// SetError makes all calls to the method fail with err, until SetError is
+// called again with a nil error.
+func (c *Client) SetError(method string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err == nil {
+		delete(c.errors, method)
+	} else {
+		c.errors[method] = err
+	}
+}
+
+// SetTableColumns creates or replaces a table.
+func (c *Client) SetTableColumns(databaseName string, schemaName string, tableName string, columns []sf.Column) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tables[tableKey(databaseName, schemaName, tableName)] = columns
+}
+
+// SetMigrationVersion records the version of the last database migration
+// applied to the schema.
+func (c *Client) SetMigrationVersion(databaseName string, schemaName string, version int64, dirty bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.migrations[strings.ToUpper(fmt.Sprintf("%s.%s", databaseName, schemaName))] = migrationVersion{version: version, dirty: dirty}
+}
+
+// HasTable returns true if the table exists.
+func (c *Client) HasTable(databaseName string, schemaName string, tableName string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.tables[tableKey(databaseName, schemaName, tableName)]
+	return ok
+}
+
+// TableNames returns the sorted names of the tables in the schema.
+func (c *Client) TableNames(databaseName string, schemaName string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	prefix := strings.ToUpper(fmt.Sprintf("%s.%s.", databaseName, schemaName))
+	var names []string
+	for key := range c.tables {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, strings.TrimPrefix(key, prefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasWarehouse returns true if the warehouse exists.
+func (c *Client) HasWarehouse(name string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.warehouses[name]
+	return ok
+}
+
+func (c *Client) CreateWarehouse(ctx context.Context, name string, config sf.WarehouseConfig) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.errors["CreateWarehouse"]; err != nil {
+		return err
+	}
+	if _, ok := c.warehouses[name]; ok {
+		return fmt.Errorf("warehouse %s already exists", name)
+	}
+	c.warehouses[name] = config
+	return nil
+}
+
+func (c *Client) UseWarehouse(ctx context.Context, name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.errors["UseWarehouse"]; err != nil {
+		return err
+	}
+	if _, ok := c.warehouses[name]; !ok {
+		return fmt.Errorf("warehouse %s does not exist", name)
+	}
+	c.warehouse = name
+	return nil
+}
+
+func (c *Client) DropWarehouse(ctx context.Context, name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.errors["DropWarehouse"]; err != nil {
+		return err
+	}
+	delete(c.warehouses, name)
+	if c.warehouse == name {
+		c.warehouse = ""
+	}
+	return nil
+}
+
+func (c *Client) GetTableColumns(ctx context.Context, databaseName string, schemaName string, tableName string) ([]sf.Column, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.errors["GetTableColumns"]; err != nil {
+		return nil, err
+	}
+	columns := c.tables[tableKey(databaseName, schemaName, tableName)]
+	return append(make([]sf.Column, 0, len(columns)), columns...), nil
+}
+
+func (c *Client) CloneTable(ctx context.Context, databaseName string, schemaName string, sourceTableName string, tableName string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.errors["CloneTable"]; err != nil {
+		return err
+	}
+	columns, ok := c.tables[tableKey(databaseName, schemaName, sourceTableName)]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", sourceTableName)
+	}
+	key := tableKey(databaseName, schemaName, tableName)
+	if _, ok := c.tables[key]; ok {
+		return fmt.Errorf("table %s already exists", tableName)
+	}
+	c.tables[key] = append([]sf.Column(nil), columns...)
+	return nil
+}
+
+func (c *Client) GetMigrationVersion(ctx context.Context, databaseName string, schemaName string) (int64, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.errors["GetMigrationVersion"]; err != nil {
+		return 0, false, err
+	}
+	v, ok := c.migrations[strings.ToUpper(fmt.Sprintf("%s.%s", databaseName, schemaName))]
+	if !ok {
+		return sf.NilMigrationVersion, false, nil
+	}
+	return v.version, v.dirty, nil
+}
@@ -41,24 +41,71 @@ type WarehouseConfig struct {
 	InitiallySuspended *bool
 }
 
+// Column describes a table column. Type uses the data type names reported by
+// INFORMATION_SCHEMA.COLUMNS, with the length for text types and the precision
+// and scale for numbers, e.g. TEXT(50) or NUMBER(20,0).
+type Column struct {
+	Name string
+	Type string
+}
+
+// NilMigrationVersion is returned by GetMigrationVersion when no database
+// migration has been applied yet.
+const NilMigrationVersion = -1
+
+// migrationsTableName is the table in which migrate-snowflake records the
+// version of the last applied migration; the name is quoted when the table is
+// created, so it is case-sensitive.
+const migrationsTableName = "schema_migrations"
+
 type Client interface {
 	CreateWarehouse(ctx context.Context, name string, config WarehouseConfig) error
 	UseWarehouse(ctx context.Context, name string) error
 	DropWarehouse(ctx context.Context, name string) error
+	// GetTableColumns returns the columns of the table, in order, or an
+	// empty slice if the table does not exist. A warehouse is required.
+	GetTableColumns(ctx context.Context, databaseName string, schemaName string, tableName string) ([]Column, error)
+	CloneTable(ctx context.Context, databaseName string, schemaName string, sourceTableName string, tableName string) error
+	// GetMigrationVersion returns the version of the last database
+	// migration applied to the schema, and whether it failed half-way
+	// (dirty), or NilMigrationVersion if no migration has been applied.
+	GetMigrationVersion(ctx context.Context, databaseName string, schemaName string) (int64, bool, error)
 }
 
 type client struct {
-	db     *sql.DB
-	logger logr.Logger
+	db          *sql.DB
+	logger      logr.Logger
+	retryConfig RetryConfig
 }
 
+// NewClient returns a client which retries queries and idempotent statements
+// failing with transient errors, according to DefaultRetryConfig.
 func NewClient(db *sql.DB, logger logr.Logger) *client {
 	return &client{
-		db:     db,
-		logger: logger,
+		db:          db,
+		logger:      logger,
+		retryConfig: DefaultRetryConfig,
 	}
 }
 
+// exec runs the statement once. It must be used for statements which are not
+// idempotent: if the connection is reset after Snowflake has run the
+// statement, running it again would fail (e.g. with "already exists").
+func (c *client) exec(ctx context.Context, query string) error {
+	c.logger.V(2).Info("Snowflake query", "query", query)
+	_, err := c.db.ExecContext(ctx, query)
+	return err
+}
+
+// execIdempotent runs the statement, retrying it in case of transient errors.
+func (c *client) execIdempotent(ctx context.Context, query string) error {
+	c.logger.V(2).Info("Snowflake query", "query", query)
+	return withRetry(ctx, c.logger, c.retryConfig, func() error {
+		_, err := c.db.ExecContext(ctx, query)
+		return err
+	})
+}
+
 func (c *client) CreateWarehouse(ctx context.Context, name string, config WarehouseConfig) error {
 	query := fmt.Sprintf("CREATE WAREHOUSE %s", name)
 	properties := make([]string, 0)
@@ -83,21 +130,75 @@ func (c *client) CreateWarehouse(ctx context.Context, name string, config Wareho
 	if len(properties) > 0 {
 		query += " WITH " + strings.Join(properties, " ")
 	}
-	c.logger.V(2).Info("Snowflake query", "query", query)
-	_, err := c.db.ExecContext(ctx, query)
-	return err
+	return c.exec(ctx, query)
 }
 
 func (c *client) UseWarehouse(ctx context.Context, name string) error {
 	query := fmt.Sprintf("USE WAREHOUSE %s", name)
-	c.logger.V(2).Info("Snowflake query", "query", query)
-	_, err := c.db.ExecContext(ctx, query)
-	return err
+	return c.execIdempotent(ctx, query)
 }
 
 func (c *client) DropWarehouse(ctx context.Context, name string) error {
 	query := fmt.Sprintf("DROP WAREHOUSE IF EXISTS %s", name)
+	return c.execIdempotent(ctx, query)
+}
+
+func (c *client) GetTableColumns(ctx context.Context, databaseName string, schemaName string, tableName string) ([]Column, error) {
+	query := fmt.Sprintf("SELECT COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE FROM %s.INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", databaseName)
 	c.logger.V(2).Info("Snowflake query", "query", query)
-	_, err := c.db.ExecContext(ctx, query)
-	return err
+	var columns []Column
+	err := withRetry(ctx, c.logger, c.retryConfig, func() error {
+		rows, err := c.db.QueryContext(ctx, query, strings.ToUpper(schemaName), strings.ToUpper(tableName))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		columns = make([]Column, 0)
+		for rows.Next() {
+			var name, dataType string
+			var length, precision, scale sql.NullInt64
+			if err := rows.Scan(&name, &dataType, &length, &precision, &scale); err != nil {
+				return err
+			}
+			columnType := dataType
+			if length.Valid {
+				columnType = fmt.Sprintf("%s(%d)", dataType, length.Int64)
+			} else if precision.Valid && scale.Valid {
+				columnType = fmt.Sprintf("%s(%d,%d)", dataType, precision.Int64, scale.Int64)
+			}
+			columns = append(columns, Column{Name: name, Type: columnType})
+		}
+		return rows.Err()
+	})
+	return columns, err
+}
+
+func (c *client) CloneTable(ctx context.Context, databaseName string, schemaName string, sourceTableName string, tableName string) error {
+	query := fmt.Sprintf("CREATE TABLE %s.%s.%s CLONE %s.%s.%s", databaseName, schemaName, tableName, databaseName, schemaName, sourceTableName)
+	return c.exec(ctx, query)
+}
+
+func (c *client) GetMigrationVersion(ctx context.Context, databaseName string, schemaName string) (int64, bool, error) {
+	existsQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s.INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", databaseName)
+	versionQuery := fmt.Sprintf(`SELECT version, dirty FROM %s.%s."%s" LIMIT 1`, databaseName, schemaName, migrationsTableName)
+	version := int64(NilMigrationVersion)
+	dirty := false
+	err := withRetry(ctx, c.logger, c.retryConfig, func() error {
+		c.logger.V(2).Info("Snowflake query", "query", existsQuery)
+		var count int
+		if err := c.db.QueryRowContext(ctx, existsQuery, strings.ToUpper(schemaName), migrationsTableName).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		c.logger.V(2).Info("Snowflake query", "query", versionQuery)
+		err := c.db.QueryRowContext(ctx, versionQuery).Scan(&version, &dirty)
+		if err == sql.ErrNoRows {
+			version = NilMigrationVersion
+			return nil
+		}
+		return err
+	})
+	return version, dirty, err
 }
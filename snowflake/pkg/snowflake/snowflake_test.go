@@ -0,0 +1,96 @@
+// Copyright 2022 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snowflake
+
+import (
+	"context"
+	"net"
+	"os"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*client, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	c := NewClient(db, logr.Discard())
+	c.retryConfig = RetryConfig{
+		Attempts:       3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+	return c, mock
+}
+
+func TestCreateWarehouseIsNotRetried(t *testing.T) {
+	c, mock := newTestClient(t)
+	connReset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	size := WarehouseSizeType("XSMALL")
+	mock.ExpectExec(regexp.QuoteMeta("CREATE WAREHOUSE FOO WITH WAREHOUSE_SIZE = XSMALL")).WillReturnError(connReset)
+	err := c.CreateWarehouse(context.Background(), "FOO", WarehouseConfig{Size: &size})
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloneTableIsNotRetried(t *testing.T) {
+	c, mock := newTestClient(t)
+	connReset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE DB.SCHEMA.BACKUP CLONE DB.SCHEMA.FLOWS")).WillReturnError(connReset)
+	err := c.CloneTable(context.Background(), "DB", "SCHEMA", "FLOWS", "BACKUP")
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDropWarehouseIsRetried(t *testing.T) {
+	c, mock := newTestClient(t)
+	connReset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	query := regexp.QuoteMeta("DROP WAREHOUSE IF EXISTS FOO")
+	mock.ExpectExec(query).WillReturnError(connReset)
+	mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 0))
+	err := c.DropWarehouse(context.Background(), "FOO")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTableColumnsIsRetried(t *testing.T) {
+	c, mock := newTestClient(t)
+	connReset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	query := regexp.QuoteMeta("SELECT COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE FROM DB.INFORMATION_SCHEMA.COLUMNS")
+	mock.ExpectQuery(query).WithArgs("SCHEMA", "FLOWS").WillReturnError(connReset)
+	mock.ExpectQuery(query).WithArgs("SCHEMA", "FLOWS").WillReturnRows(
+		sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE"}).
+			AddRow("SOURCEIP", "TEXT", 50, nil, nil).
+			AddRow("OCTETTOTALCOUNT", "NUMBER", nil, 20, 0).
+			AddRow("TIMEINSERTED", "TIMESTAMP_TZ", nil, nil, nil),
+	)
+	columns, err := c.GetTableColumns(context.Background(), "DB", "schema", "flows")
+	require.NoError(t, err)
+	assert.Equal(t, []Column{
+		{Name: "SOURCEIP", Type: "TEXT(50)"},
+		{Name: "OCTETTOTALCOUNT", Type: "NUMBER(20,0)"},
+		{Name: "TIMEINSERTED", Type: "TIMESTAMP_TZ"},
+	}, columns)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
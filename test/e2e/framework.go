@@ -50,6 +50,7 @@ import (
 	crdv1alpha1 "antrea.io/antrea/pkg/apis/crd/v1alpha1"
 	crdclientset "antrea.io/antrea/pkg/client/clientset/versioned"
 
+	"antrea.io/theia/pkg/util/install"
 	"antrea.io/theia/test/e2e/providers"
 )
 
@@ -62,31 +63,29 @@ const (
 	defaultInterval = 1 * time.Second
 	realizeTimeout  = 5 * time.Minute
 
-	antreaNamespace            string = "kube-system"
-	kubeNamespace              string = "kube-system"
-	flowAggregatorNamespace    string = "flow-aggregator"
-	flowVisibilityNamespace    string = "flow-visibility"
-	testNamespace              string = "antrea-test"
-	iperfPort                  int32  = 5201
-	clickHouseHTTPPort         string = "8123"
-	busyboxContainerName       string = "busybox"
-	defaultBridgeName          string = "br-int"
-	antreaYML                  string = "antrea.yml"
-	antreaDaemonSet            string = "antrea-agent"
-	antreaDeployment           string = "antrea-controller"
-	flowAggregatorDeployment   string = "flow-aggregator"
-	flowAggregatorYML          string = "flow-aggregator.yml"
-	flowVisibilityYML          string = "flow-visibility.yml"
-	flowVisibilityWithSparkYML string = "flow-visibility-with-spark.yml"
-	flowVisibilityChOnlyYML    string = "flow-visibility-ch-only.yml"
-	clickHouseOperatorYML      string = "clickhouse-operator-install-bundle.yaml"
-	clickHousePodNamePrefix    string = "chi-clickhouse-clickhouse"
-	policyOutputYML            string = "output.yaml"
-	sparkOperatorPodLabel      string = "app.kubernetes.io/name=spark-operator"
-	grafanaPodLabel            string = "app=grafana"
-	antreaPodLabel             string = "app=antrea,component=antrea-agent"
-	clickHouseLocalPvLabel     string = "antrea.io/clickhouse-data-node"
-	clickHouseLocalPvPath      string = "/data/clickhouse"
+	antreaNamespace          string = "kube-system"
+	kubeNamespace            string = "kube-system"
+	flowAggregatorNamespace  string = "flow-aggregator"
+	flowVisibilityNamespace  string = "flow-visibility"
+	testNamespace            string = "antrea-test"
+	iperfPort                int32  = 5201
+	clickHouseHTTPPort       string = "8123"
+	busyboxContainerName     string = "busybox"
+	defaultBridgeName        string = "br-int"
+	antreaYML                string = "antrea.yml"
+	antreaDaemonSet          string = "antrea-agent"
+	antreaDeployment         string = "antrea-controller"
+	flowAggregatorDeployment string = "flow-aggregator"
+	flowAggregatorYML        string = "flow-aggregator.yml"
+	flowVisibilityChOnlyYML  string = "flow-visibility-ch-only.yml"
+	clickHouseOperatorYML    string = "clickhouse-operator-install-bundle.yaml"
+	clickHousePodNamePrefix  string = "chi-clickhouse-clickhouse"
+	policyOutputYML          string = "output.yaml"
+	sparkOperatorPodLabel    string = "app.kubernetes.io/name=spark-operator"
+	grafanaPodLabel          string = "app=grafana"
+	antreaPodLabel           string = "app=antrea,component=antrea-agent"
+	clickHouseLocalPvLabel   string = "antrea.io/clickhouse-data-node"
+	clickHouseLocalPvPath    string = "/data/clickhouse"
 
 	agnhostImage  = "k8s.gcr.io/e2e-test-images/agnhost:2.29"
 	busyboxImage  = "projects.registry.vmware.com/antrea/busybox"
@@ -1125,21 +1124,28 @@ func (data *TestData) deployFlowVisibility(config FlowVisibiltiySetUpConfig) (ch
 			return "", fmt.Errorf("error when creating directory for ClickHouse PersistentVolume on Node %s: %v", workerNodeName(1), stderr)
 		}
 	}
-	var flowVisibilityManifest string
-	if !config.withGrafana && !config.withSparkOperator {
-		flowVisibilityManifest = flowVisibilityChOnlyYML
-	} else if config.withSparkOperator {
-		flowVisibilityManifest = flowVisibilityWithSparkYML
+	if config.withClickHouseLocalPv {
+		// The upgrade test deploys the manifests of the Theia version it
+		// upgrades from, which are not embedded in this version.
+		rc, _, _, err := data.provider.RunCommandOnNode(controlPlaneNodeName(), fmt.Sprintf("kubectl apply -f %s", clickHouseOperatorYML))
+		if err != nil || rc != 0 {
+			return "", fmt.Errorf("error when deploying the ClickHouse Operator YML; %s not available on the control-plane Node", clickHouseOperatorYML)
+		}
+		if err := data.deployFlowVisibilityCommon(flowVisibilityChOnlyYML); err != nil {
+			return "", err
+		}
 	} else {
-		flowVisibilityManifest = flowVisibilityYML
-	}
-	rc, _, _, err := data.provider.RunCommandOnNode(controlPlaneNodeName(), fmt.Sprintf("kubectl apply -f %s", clickHouseOperatorYML))
-	if err != nil || rc != 0 {
-		return "", fmt.Errorf("error when deploying the ClickHouse Operator YML; %s not available on the control-plane Node", clickHouseOperatorYML)
-	}
-	err = data.deployFlowVisibilityCommon(flowVisibilityManifest)
-	if err != nil {
-		return "", err
+		objects, err := install.Manifests(flowVisibilityInstallOptions(config))
+		if err != nil {
+			return "", err
+		}
+		installer, err := install.NewInstallerForConfig(data.kubeConfig, nil, io.Discard)
+		if err != nil {
+			return "", err
+		}
+		if err := installer.Apply(context.TODO(), objects); err != nil {
+			return "", fmt.Errorf("error when installing flow visibility: %v", err)
+		}
 	}
 	if config.withSparkOperator {
 		sparkOperatorPodName, err := data.getPodByLabel(sparkOperatorPodLabel, flowVisibilityNamespace)
@@ -1190,6 +1196,23 @@ func (data *TestData) deployFlowVisibility(config FlowVisibiltiySetUpConfig) (ch
 	return chSvc.Spec.ClusterIP, nil
 }
 
+// flowVisibilityInstallOptions returns the options with which flow visibility
+// is installed from the embedded manifests, like with "theia install
+// flow-visibility".
+func flowVisibilityInstallOptions(config FlowVisibiltiySetUpConfig) install.Options {
+	options := install.Options{
+		WithSparkOperator: config.withSparkOperator,
+		WithGrafana:       config.withGrafana,
+	}
+	if config.withGrafana && !config.withSparkOperator {
+		// Use a small storage so that the ClickHouse monitor can be
+		// tested.
+		options.ClickHouseStorageSize = "100Mi"
+		options.ClickHouseMonitorThreshold = "0.1"
+	}
+	return options
+}
+
 func (data *TestData) deployFlowVisibilityCommon(yamlFile string) error {
 	if err := wait.Poll(2*time.Second, 10*time.Second, func() (bool, error) {
 		rc, stdout, stderr, err := data.provider.RunCommandOnNode(controlPlaneNodeName(), fmt.Sprintf("kubectl apply -f %s", yamlFile))
@@ -1316,20 +1339,16 @@ func teardownFlowVisibility(tb testing.TB, data *TestData, config FlowVisibiltiy
 	if err := data.deleteFlowVisibility(config); err != nil {
 		tb.Logf("Error when deleting K8s resources created by flow visibility: %v", err)
 	}
-	if err := data.deleteClickHouseOperator(); err != nil {
-		tb.Logf("Error when deleting ClickHouse Operator: %v", err)
+	// The ClickHouse Operator is deleted with the other embedded manifests
+	// otherwise.
+	if config.withClickHouseLocalPv {
+		if err := data.deleteClickHouseOperator(); err != nil {
+			tb.Logf("Error when deleting ClickHouse Operator: %v", err)
+		}
 	}
 }
 
 func (data *TestData) deleteFlowVisibility(config FlowVisibiltiySetUpConfig) error {
-	var flowVisibilityManifest string
-	if !config.withGrafana && !config.withSparkOperator {
-		flowVisibilityManifest = flowVisibilityChOnlyYML
-	} else if config.withSparkOperator {
-		flowVisibilityManifest = flowVisibilityWithSparkYML
-	} else {
-		flowVisibilityManifest = flowVisibilityYML
-	}
 	startTime := time.Now()
 	defer func() {
 		log.Infof("Deleting K8s resources created by flow visibility YAML took %v", time.Since(startTime))
@@ -1339,11 +1358,34 @@ func (data *TestData) deleteFlowVisibility(config FlowVisibiltiySetUpConfig) err
 		return fmt.Errorf("error when deleting ClickHouse StatefulSet: %v, stderr: %s", err, stderr)
 	}
 
-	rc, _, stderr, err = data.provider.RunCommandOnNode(controlPlaneNodeName(), fmt.Sprintf("kubectl delete -f %s --ignore-not-found=true", flowVisibilityManifest))
-	if err != nil || rc != 0 {
-		return fmt.Errorf("error when deleting K8s resources created by flow visibility YAML: %v, stderr: %s", err, stderr)
+	if config.withClickHouseLocalPv {
+		rc, _, stderr, err = data.provider.RunCommandOnNode(controlPlaneNodeName(), fmt.Sprintf("kubectl delete -f %s --ignore-not-found=true", flowVisibilityChOnlyYML))
+		if err != nil || rc != 0 {
+			return fmt.Errorf("error when deleting K8s resources created by flow visibility YAML: %v, stderr: %s", err, stderr)
+		}
+		return nil
 	}
-	return nil
+	objects, err := install.Manifests(flowVisibilityInstallOptions(config))
+	if err != nil {
+		return err
+	}
+	installer, err := install.NewInstallerForConfig(data.kubeConfig, nil, io.Discard)
+	if err != nil {
+		return err
+	}
+	if err := installer.Delete(context.TODO(), objects); err != nil {
+		return fmt.Errorf("error when deleting K8s resources created by flow visibility: %v", err)
+	}
+	// Unlike "kubectl delete", the installer does not wait for the deletion,
+	// and flow visibility cannot be installed again before the Namespace is
+	// deleted.
+	return wait.Poll(defaultInterval, defaultTimeout, func() (bool, error) {
+		_, err := data.clientset.CoreV1().Namespaces().Get(context.TODO(), flowVisibilityNamespace, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
 }
 
 func deleteRecommendedPolicies(tb testing.TB, data *TestData) {
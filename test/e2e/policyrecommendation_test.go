@@ -15,6 +15,7 @@
 package e2e
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"strings"
@@ -185,13 +186,17 @@ func testPolicyRecommendationStatus(t *testing.T, data *TestData) {
 	require.NoError(t, err)
 	assert := assert.New(t)
 	assert.Containsf(stdout, "Status of this policy recommendation job is", "stdout: %s", stdout)
+	status, err := getJobStatusJSON(t, data, jobId)
+	require.NoError(t, err)
+	assert.Equal(jobId, status.ID)
+	assert.NotEmpty(status.State)
 }
 
 // Example output:
-// CreationTime          CompletionTime        ID                                   Status
-// 2022-06-17 15:03:24 N/A                 615026a0-1856-4107-87d9-08f7d69819ae RUNNING
-// 2022-06-17 15:03:22 2022-06-17 18:08:37 7bebe4f9-408b-4dd8-9d63-9dc538073089 COMPLETED
-// 2022-06-17 15:03:39 N/A                 c7a9e768-559a-4bfb-b0c8-a0291b4c208c SUBMITTED
+// CreationTime          CompletionTime        ID                                   Backend Status
+// 2022-06-17 15:03:24 N/A                 615026a0-1856-4107-87d9-08f7d69819ae spark   RUNNING
+// 2022-06-17 15:03:22 2022-06-17 18:08:37 7bebe4f9-408b-4dd8-9d63-9dc538073089 spark   COMPLETED
+// 2022-06-17 15:03:39 N/A                 c7a9e768-559a-4bfb-b0c8-a0291b4c208c spark   SUBMITTED
 func testPolicyRecommendationList(t *testing.T, data *TestData) {
 	_, jobId, err := runJob(t, data)
 	require.NoError(t, err)
@@ -219,17 +224,16 @@ func testPolicyRecommendationDelete(t *testing.T, data *TestData) {
 }
 
 // Example output:
-// Status of this policy recommendation job is Failed
-// Error message: driver pod not found
+// {"id": "e998433e-accb-4888-9fc8-06563f073e86", "state": "FAILED", "errorMessage": "driver pod not found", ...}
 // Or
-// Error message: driver container failed
+// {"id": "e998433e-accb-4888-9fc8-06563f073e86", "state": "FAILED", "errorMessage": "driver container failed", ...}
 func testPolicyRecommendationFailed(t *testing.T, data *TestData) {
-	stdout, jobId, err := runJob(t, data)
+	_, jobId, err := runJob(t, data)
 	require.NoError(t, err)
 	err = wait.PollImmediate(defaultInterval, jobSubmitTimeout, func() (bool, error) {
-		stdout, err = getJobStatus(t, data, jobId)
+		status, err := getJobStatusJSON(t, data, jobId)
 		require.NoError(t, err)
-		if strings.Contains(stdout, "Status of this policy recommendation job is RUNNING") {
+		if status.State == "RUNNING" {
 			return true, nil
 		}
 		// Keep trying
@@ -240,10 +244,11 @@ func testPolicyRecommendationFailed(t *testing.T, data *TestData) {
 	if err := data.DeletePod(flowVisibilityNamespace, driverPodName); err != nil {
 		t.Logf("Error when deleting Driver Pod: %v", err)
 	}
+	var status *jobStatus
 	err = wait.PollImmediate(defaultInterval, jobFailedTimeout, func() (bool, error) {
-		stdout, err = getJobStatus(t, data, jobId)
+		status, err = getJobStatusJSON(t, data, jobId)
 		require.NoError(t, err)
-		if strings.Contains(stdout, "Status of this policy recommendation job is FAILED") {
+		if status.State == "FAILED" {
 			return true, nil
 		}
 		// Keep trying
@@ -251,7 +256,7 @@ func testPolicyRecommendationFailed(t *testing.T, data *TestData) {
 	})
 	require.NoError(t, err)
 	assert := assert.New(t)
-	assert.Truef(strings.Contains(stdout, "Error message: driver pod not found") || strings.Contains(stdout, "Error message: driver container failed") || strings.Contains(stdout, "Error message: driver container status missing"), "stdout: %s", stdout)
+	assert.Containsf([]string{"driver pod not found", "driver container failed", "driver container status missing"}, status.ErrorMessage, "status: %+v", status)
 }
 
 // Example output:
@@ -300,7 +305,13 @@ func testPolicyRecommendationRetrieve(t *testing.T, data *TestData, isIPv6 bool,
 		expectedRejectACNPCnt -= 1
 	}
 
-	_, jobId, err := runJob(t, data)
+	// In dual-stack clusters, the flows of the other address family are not
+	// considered.
+	addressFamily := "ipv4"
+	if isIPv6 {
+		addressFamily = "ipv6"
+	}
+	_, jobId, err := runJob(t, data, "--address-family", addressFamily)
 	require.NoError(t, err)
 	err = waitJobComplete(t, data, jobId, jobCompleteTimeout)
 	require.NoErrorf(t, err, "Policy recommendation Spark job failed to complete")
@@ -352,13 +363,14 @@ func testPolicyRecommendationRetrieve(t *testing.T, data *TestData, isIPv6 bool,
 	assert.Equalf(expectedRejectACNPCnt, rejectACNPCnt, fmt.Sprintf("Expected reject ACNP count is: %d. Actual count is: %d. Recommended policies:\n%s\nCheck command output:\n%s", expectedRejectACNPCnt, rejectACNPCnt, allPolicies, stdout))
 }
 
-func runJob(t *testing.T, data *TestData) (stdout string, jobId string, err error) {
+func runJob(t *testing.T, data *TestData, args ...string) (stdout string, jobId string, err error) {
 	cmd := "chmod +x ./theia"
 	rc, stdout, stderr, err := data.RunCommandOnNode(controlPlaneNodeName(), cmd)
 	if err != nil || rc != 0 {
 		return "", "", fmt.Errorf("error when running %s from %s: %v\nstdout:%s\nstderr:%s", cmd, controlPlaneNodeName(), err, stdout, stderr)
 	}
-	rc, stdout, stderr, err = data.RunCommandOnNode(controlPlaneNodeName(), startCmd)
+	cmd = strings.Join(append([]string{startCmd}, args...), " ")
+	rc, stdout, stderr, err = data.RunCommandOnNode(controlPlaneNodeName(), cmd)
 	if err != nil || rc != 0 {
 		return "", "", fmt.Errorf("error when running %s from %s: %v\nstdout:%s\nstderr:%s", cmd, controlPlaneNodeName(), err, stdout, stderr)
 	}
@@ -377,6 +389,30 @@ func getJobStatus(t *testing.T, data *TestData, jobId string) (stdout string, er
 	return strings.TrimSuffix(stdout, "\n"), nil
 }
 
+// jobStatus is the status of a policy recommendation job printed by the
+// status command with --output json.
+type jobStatus struct {
+	ID                   string `json:"id"`
+	State                string `json:"state"`
+	SubmissionTime       string `json:"submissionTime"`
+	CompletionTime       string `json:"completionTime"`
+	ErrorMessage         string `json:"errorMessage"`
+	SparkApplicationName string `json:"sparkApplicationName"`
+}
+
+func getJobStatusJSON(t *testing.T, data *TestData, jobId string) (*jobStatus, error) {
+	cmd := fmt.Sprintf("%s %s -o json", statusCmd, jobId)
+	rc, stdout, stderr, err := data.RunCommandOnNode(controlPlaneNodeName(), cmd)
+	if err != nil || rc != 0 {
+		return nil, fmt.Errorf("error when running %s from %s: %v\nstdout:%s\nstderr:%s", cmd, controlPlaneNodeName(), err, stdout, stderr)
+	}
+	var status jobStatus
+	if err := json.Unmarshal([]byte(stdout), &status); err != nil {
+		return nil, fmt.Errorf("error when decoding the status of job %s: %v\nstdout:%s", jobId, err, stdout)
+	}
+	return &status, nil
+}
+
 func listJobs(t *testing.T, data *TestData) (stdout string, err error) {
 	rc, stdout, stderr, err := data.RunCommandOnNode(controlPlaneNodeName(), listCmd)
 	if err != nil || rc != 0 {
@@ -405,18 +441,19 @@ func retrieveJobResult(t *testing.T, data *TestData, jobId string) error {
 
 // waitJobComplete waits for the policy recommendation Spark job completes
 func waitJobComplete(t *testing.T, data *TestData, jobId string, timeout time.Duration) error {
-	stdout := ""
+	var status *jobStatus
 	err := wait.PollImmediate(defaultInterval, timeout, func() (bool, error) {
-		stdout, err := getJobStatus(t, data, jobId)
+		var err error
+		status, err = getJobStatusJSON(t, data, jobId)
 		require.NoError(t, err)
-		if strings.Contains(stdout, "Status of this policy recommendation job is COMPLETED") {
+		if status.State == "COMPLETED" {
 			return true, nil
 		}
 		// Keep trying
 		return false, nil
 	})
 	if err == wait.ErrWaitTimeout {
-		return fmt.Errorf("policy recommendation Spark job not completed after %v\nstatus:%s", timeout, stdout)
+		return fmt.Errorf("policy recommendation Spark job not completed after %v\nstatus:%+v", timeout, status)
 	} else if err != nil {
 		return err
 	}